@@ -0,0 +1,36 @@
+package pulsecheck
+
+import "time"
+
+// adaptiveStep is the granularity of adaptive heartbeat intervals, so small
+// changes in the peer count do not reset the ticker on every heartbeat
+const adaptiveStep = 100 * time.Millisecond
+
+// adaptiveInterval returns the heartbeat interval that keeps a node sending
+// to peers within budget packets per second, bounded by min and max. Each
+// heartbeat costs one packet per peer, so the interval grows linearly with
+// the mesh
+func adaptiveInterval(peers int, budget float64, min, max time.Duration) time.Duration {
+	interval := time.Duration(float64(peers) / budget * float64(time.Second))
+	// Round up so the budget is never exceeded
+	if rem := interval % adaptiveStep; rem != 0 {
+		interval += adaptiveStep - rem
+	}
+	if interval < min {
+		return min
+	}
+	if interval > max {
+		return max
+	}
+	return interval
+}
+
+// nextHeartbeatInterval returns the interval for the current peer count,
+// or HeartbeatInterval when adaptive heartbeats are off
+func (n *Node) nextHeartbeatInterval() time.Duration {
+	if n.config.HeartbeatBudget <= 0 {
+		return n.config.HeartbeatInterval
+	}
+	return adaptiveInterval(n.udpNode.PeerCount(), n.config.HeartbeatBudget,
+		n.config.HeartbeatInterval, n.config.MaxHeartbeatInterval)
+}
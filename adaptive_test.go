@@ -0,0 +1,52 @@
+package pulsecheck
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveInterval(t *testing.T) {
+	const (
+		min = time.Second
+		max = 10 * time.Second
+	)
+	testCases := []struct {
+		peers  int
+		budget float64
+		want   time.Duration
+	}{
+		{0, 50, min},                       // No peers: floor
+		{20, 50, min},                      // 0.4s fits under the floor
+		{100, 50, 2 * time.Second},         // 100 packets per 2s is 50/s
+		{130, 50, 2600 * time.Millisecond}, // Exact multiple of the step
+		{101, 50, 2100 * time.Millisecond}, // 2.02s rounds up, never over budget
+		{10000, 50, max},                   // Ceiling bounds detection latency
+		{3, 0.5, 6 * time.Second},          // Budgets below one packet per second
+	}
+	for _, tc := range testCases {
+		if got := adaptiveInterval(tc.peers, tc.budget, min, max); got != tc.want {
+			t.Errorf("adaptiveInterval(%d, %v) = %v, want %v", tc.peers, tc.budget, got, tc.want)
+		}
+	}
+}
+
+func TestNewAdaptiveHeartbeatConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.HeartbeatBudget = 10
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	node.Stop()
+	if want := cfg.Timeout / 3; node.config.MaxHeartbeatInterval != want {
+		t.Errorf("MaxHeartbeatInterval = %v, want default %v", node.config.MaxHeartbeatInterval, want)
+	}
+
+	for _, max := range []time.Duration{cfg.HeartbeatInterval / 2, cfg.Timeout} {
+		cfg.MaxHeartbeatInterval = max
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New() with max heartbeat interval %v should return error", max)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package pulsecheck
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/telemetry"
+)
+
+// ChaosPath is the HTTP endpoint for fault injection, served on IngestAddr
+// to requests carrying IngestToken when EnableChaos is set
+const ChaosPath = "/chaos"
+
+// ErrChaosDisabled is returned by fault injection on a node created without
+// EnableChaos
+var ErrChaosDisabled = errors.New("chaos testing is disabled")
+
+// ChaosState is the set of faults currently injected into a node
+type ChaosState struct {
+	Status      string    `json:"status,omitempty"` // Forced status, empty if none
+	StatusUntil time.Time `json:"status_until,omitempty"`
+	LossPercent float64   `json:"loss_percent"`
+	PausedUntil time.Time `json:"paused_until,omitempty"`
+}
+
+// chaos holds the faults injected into a node. It only exists with
+// EnableChaos set, so a production node has no way to enable it at runtime
+type chaos struct {
+	mu          sync.Mutex
+	status      telemetry.StatusCode
+	statusUntil time.Time // Zero when no status is forced
+	lossPercent float64
+	pauseUntil  time.Time
+	random      func() float64 // In [0, 1)
+}
+
+// newChaos creates a fault injector with nothing injected
+func newChaos() *chaos {
+	return &chaos{random: rand.Float64}
+}
+
+// statusAt returns the forced status if one is active at now, or status
+func (c *chaos) statusAt(status telemetry.StatusCode, now time.Time) telemetry.StatusCode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Before(c.statusUntil) {
+		return c.status
+	}
+	return status
+}
+
+// pausedAt reports whether heartbeats are paused at now
+func (c *chaos) pausedAt(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return now.Before(c.pauseUntil)
+}
+
+// dropSend reports whether to discard one send. Suitable for
+// UDPNode.SetSendFilter
+func (c *chaos) dropSend() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lossPercent > 0 && c.random()*100 < c.lossPercent
+}
+
+// state returns the faults active at now
+func (c *chaos) state(now time.Time) ChaosState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := ChaosState{LossPercent: c.lossPercent}
+	if now.Before(c.statusUntil) {
+		s.Status = statusName(c.status)
+		s.StatusUntil = c.statusUntil
+	}
+	if now.Before(c.pauseUntil) {
+		s.PausedUntil = c.pauseUntil
+	}
+	return s
+}
+
+// InjectStatus makes the node report status (0 OK, 1 WARN, 2 CRITICAL)
+// instead of its measured status for d, to exercise alerting without real
+// load. Testing only
+func (n *Node) InjectStatus(status uint8, d time.Duration) error {
+	if n.chaos == nil {
+		return ErrChaosDisabled
+	}
+	if int(status) >= len(statusNames) {
+		return fmt.Errorf("invalid status %d", status)
+	}
+	n.chaos.mu.Lock()
+	defer n.chaos.mu.Unlock()
+	n.chaos.status = telemetry.StatusCode(status)
+	n.chaos.statusUntil = time.Now().Add(d)
+	log.Printf("Chaos: reporting %s for %v", statusName(n.chaos.status), d)
+	return nil
+}
+
+// InjectSendLoss drops percent of outgoing heartbeat packets (0 stops it)
+// Testing only
+func (n *Node) InjectSendLoss(percent float64) error {
+	if n.chaos == nil {
+		return ErrChaosDisabled
+	}
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("loss %v%% must be between 0 and 100", percent)
+	}
+	n.chaos.mu.Lock()
+	defer n.chaos.mu.Unlock()
+	n.chaos.lossPercent = percent
+	log.Printf("Chaos: dropping %v%% of sent packets", percent)
+	return nil
+}
+
+// PauseHeartbeats stops sending heartbeats and pushed reports for d, so
+// peers see the node fail while it keeps running. Testing only
+func (n *Node) PauseHeartbeats(d time.Duration) error {
+	if n.chaos == nil {
+		return ErrChaosDisabled
+	}
+	n.chaos.mu.Lock()
+	defer n.chaos.mu.Unlock()
+	n.chaos.pauseUntil = time.Now().Add(d)
+	log.Printf("Chaos: heartbeats paused for %v", d)
+	return nil
+}
+
+// ClearChaos removes every injected fault
+func (n *Node) ClearChaos() error {
+	if n.chaos == nil {
+		return ErrChaosDisabled
+	}
+	n.chaos.mu.Lock()
+	defer n.chaos.mu.Unlock()
+	n.chaos.statusUntil = time.Time{}
+	n.chaos.lossPercent = 0
+	n.chaos.pauseUntil = time.Time{}
+	log.Println("Chaos: cleared all injected faults")
+	return nil
+}
+
+// chaosHandler serves ChaosPath. GET returns the active faults, POST
+// injects the faults named by its form values (status and for, loss,
+// pause) and DELETE clears them all
+func (n *Node) chaosHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			if err := n.injectForm(req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case http.MethodDelete:
+			n.ClearChaos()
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(n.chaos.state(time.Now()))
+	})
+}
+
+// injectForm applies the faults in a POST to ChaosPath. Every value is
+// validated before any fault is injected
+func (n *Node) injectForm(req *http.Request) error {
+	if err := req.ParseForm(); err != nil {
+		return err
+	}
+	var inject []func() error
+	if v := req.Form.Get("status"); v != "" {
+		status, err := parseStatusName(v)
+		if err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(req.Form.Get("for"))
+		if err != nil || d <= 0 {
+			return fmt.Errorf("status needs a positive duration in for, e.g. for=2m")
+		}
+		inject = append(inject, func() error { return n.InjectStatus(uint8(status), d) })
+	}
+	if v := req.Form.Get("loss"); v != "" {
+		percent, err := strconv.ParseFloat(v, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return fmt.Errorf("invalid loss %q: expected a percentage between 0 and 100", v)
+		}
+		inject = append(inject, func() error { return n.InjectSendLoss(percent) })
+	}
+	if v := req.Form.Get("pause"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid pause %q: expected a positive duration", v)
+		}
+		inject = append(inject, func() error { return n.PauseHeartbeats(d) })
+	}
+	if len(inject) == 0 {
+		return errors.New("nothing to inject: set status and for, loss, or pause")
+	}
+	for _, f := range inject {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statusNames are the names accepted for injected statuses
+var statusNames = []string{"OK", "WARN", "CRITICAL"}
+
+// statusName returns the name of a local status code
+func statusName(status telemetry.StatusCode) string {
+	if int(status) < len(statusNames) {
+		return statusNames[status]
+	}
+	return strconv.Itoa(int(status))
+}
+
+// parseStatusName parses ok, warn or critical in any case
+func parseStatusName(name string) (telemetry.StatusCode, error) {
+	for i, s := range statusNames {
+		if strings.EqualFold(name, s) {
+			return telemetry.StatusCode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid status %q: expected ok, warn or critical", name)
+}
@@ -0,0 +1,126 @@
+package pulsecheck
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/telemetry"
+)
+
+func newChaosNode(t *testing.T) *Node {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	cfg.EnableChaos = true
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(node.Stop)
+	return node
+}
+
+func TestChaosDisabled(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer node.Stop()
+
+	for name, err := range map[string]error{
+		"InjectStatus":    node.InjectStatus(2, time.Minute),
+		"InjectSendLoss":  node.InjectSendLoss(50),
+		"PauseHeartbeats": node.PauseHeartbeats(time.Minute),
+		"ClearChaos":      node.ClearChaos(),
+	} {
+		if !errors.Is(err, ErrChaosDisabled) {
+			t.Errorf("%s() error = %v, want ErrChaosDisabled", name, err)
+		}
+	}
+}
+
+func TestChaosHeartbeat(t *testing.T) {
+	node := newChaosNode(t)
+	if err := node.udpNode.AddPeer("127.0.0.1:1"); err != nil {
+		t.Fatalf("AddPeer() error = %v", err)
+	}
+	localAddr := node.udpNode.Conn().LocalAddr().String()
+	healthy := &sample{metrics: &telemetry.Metrics{}, status: telemetry.StatusOK}
+
+	// A forced status replaces the measured one locally and on the wire
+	if err := node.InjectStatus(2, time.Minute); err != nil {
+		t.Fatalf("InjectStatus() error = %v", err)
+	}
+	node.heartbeat(healthy)
+	if info, _ := node.Monitor().GetNodeInfo(localAddr); info.StatusCode != 2 {
+		t.Errorf("local status = %d, want forced CRITICAL", info.StatusCode)
+	}
+	if got := node.Stats().PacketsSent; got != 1 {
+		t.Errorf("PacketsSent = %d, want 1", got)
+	}
+
+	// Paused heartbeats send nothing
+	node.PauseHeartbeats(time.Minute)
+	node.heartbeat(healthy)
+	if got := node.Stats().PacketsSent; got != 1 {
+		t.Errorf("PacketsSent = %d while paused, want 1", got)
+	}
+
+	// Total loss drops every send, and clearing restores the real status
+	node.ClearChaos()
+	node.InjectSendLoss(100)
+	node.heartbeat(healthy)
+	if got := node.Stats(); got.PacketsSent != 1 || got.ChaosDropped != 1 {
+		t.Errorf("Stats() = %+v, want the send dropped", got)
+	}
+	if info, _ := node.Monitor().GetNodeInfo(localAddr); info.StatusCode != 0 {
+		t.Errorf("local status = %d after ClearChaos, want OK", info.StatusCode)
+	}
+}
+
+func TestChaosHandler(t *testing.T) {
+	node := newChaosNode(t)
+	handler := node.chaosHandler()
+	do := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/chaos?status=critical&for=1m&loss=25&pause=30s")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST status = %d: %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `"status":"CRITICAL"`) || !strings.Contains(body, `"loss_percent":25`) || !strings.Contains(body, `"paused_until"`) {
+		t.Errorf("POST body = %s, want all three faults", body)
+	}
+
+	// Invalid requests inject nothing
+	for _, target := range []string{"/chaos", "/chaos?status=down&for=1m", "/chaos?status=warn", "/chaos?loss=150", "/chaos?pause=soon"} {
+		if rec := do(http.MethodPost, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST %s status = %d, want 400", target, rec.Code)
+		}
+	}
+	if rec := do(http.MethodPost, "/chaos?loss=50&pause=bad"); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST with a bad pause status = %d, want 400", rec.Code)
+	}
+	if got := node.chaos.state(time.Now()).LossPercent; got != 25 {
+		t.Errorf("loss = %v after a rejected request, want 25", got)
+	}
+
+	if rec := do(http.MethodDelete, "/chaos"); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "CRITICAL") {
+		t.Errorf("DELETE = %d %s, want cleared", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPut, "/chaos"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT status = %d, want 405", rec.Code)
+	}
+}
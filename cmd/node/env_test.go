@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestApplyEnv(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	port := fs.Int("port", 9999, "")
+	cpuWarn := fs.Float64("cpu-warn-threshold", 70, "")
+	interval := fs.Duration("heartbeat-interval", 5*time.Second, "")
+	seed := fs.String("seed-node", "", "")
+	if err := fs.Parse([]string{"-port", "7000"}); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"PULSECHECK_PORT":               "8000", // Loses to the flag
+		"PULSECHECK_CPU_WARN_THRESHOLD": "55.5",
+		"PULSECHECK_SEED_NODE":          "seed:9999",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	if err := applyEnv(fs, lookup); err != nil {
+		t.Fatalf("applyEnv() error = %v", err)
+	}
+	if *port != 7000 {
+		t.Errorf("port = %d, want the flag value 7000", *port)
+	}
+	if *cpuWarn != 55.5 || *seed != "seed:9999" {
+		t.Errorf("cpu warn = %v, seed = %q, want values from the environment", *cpuWarn, *seed)
+	}
+	if *interval != 5*time.Second {
+		t.Errorf("heartbeat interval = %v, want the default", *interval)
+	}
+
+	env["PULSECHECK_HEARTBEAT_INTERVAL"] = "often"
+	if err := applyEnv(fs, lookup); err == nil {
+		t.Error("applyEnv() with an invalid duration should return error")
+	}
+}
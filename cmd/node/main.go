@@ -1,176 +1,540 @@
 package main
 
 import (
-	"crypto/rand"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/rafaelmarinho/pulsecheck"
 	"github.com/rafaelmarinho/pulsecheck/internal/display"
-	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+	"github.com/rafaelmarinho/pulsecheck/internal/probe"
+	"github.com/rafaelmarinho/pulsecheck/internal/protocol"
 	"github.com/rafaelmarinho/pulsecheck/internal/telemetry"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "selftest" {
+		os.Exit(selfTest(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "loadgen" {
+		os.Exit(loadGen(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify-report" {
+		os.Exit(verifyReport(os.Args[2:]))
+	}
+	
+	defaults := pulsecheck.DefaultConfig()
+
 	// Parse command-line flags
-	port := flag.Int("port", 9999, "UDP port to listen on")
-	heartbeatInterval := flag.Duration("heartbeat-interval", 5*time.Second, "Time between heartbeats")
-	timeout := flag.Duration("timeout", 15*time.Second, "Time before marking node offline")
+	port := flag.Int("port", defaults.Port, "UDP port to listen on")
+	heartbeatInterval := flag.Duration("heartbeat-interval", defaults.HeartbeatInterval, "Time between heartbeats")
+	heartbeatBudget := flag.Float64("heartbeat-budget", 0, "Max heartbeat packets per second; the interval grows with the peer count to stay within it (0 disables)")
+	maxHeartbeatInterval := flag.Duration("max-heartbeat-interval", 0, "Longest interval -heartbeat-budget may stretch heartbeats to (default: a third of -timeout)")
+	collectTimeout := flag.Duration("collect-timeout", defaults.CollectTimeout, "Max wait for each metric source (cpu, memory, disk) before using its last-known value (0 waits)")
+	netInterface := flag.String("net-interface", "", "Comma-separated network interfaces (e.g. eth0) to report receive/transmit throughput for (disabled if empty)")
+	telemetryInterval := flag.Duration("telemetry-interval", defaults.TelemetryInterval, "Time between telemetry samples; heartbeats in between reuse the last sample (0 samples every heartbeat)")
+	timeout := flag.Duration("timeout", defaults.Timeout, "Time before marking node offline")
+	failureDetector := flag.String("failure-detector", defaults.FailureDetector, "Failure detector for the reaper: timeout or phi")
+	phiThreshold := flag.Float64("phi-threshold", defaults.PhiThreshold, "Phi value above which a node is considered failed (phi detector only)")
+	reapBurst := flag.Int("reap-burst", defaults.ReapBurst, "Expired nodes the reaper removes per shard lock, so mass timeouts pause heartbeats only briefly (0 uses the default of 64)")
 	nodeID := flag.String("node-id", "", "Unique identifier for this node (default: hostname)")
+	identityFile := flag.String("identity-file", "", "Keep this node's UUID in this file, created on first start, so it survives restarts (disabled if empty)")
 	seedNode := flag.String("seed-node", "", "Seed node address (e.g., 192.168.1.100:9999) for peer discovery")
+	peers := flag.String("peers", "", "Comma-separated peer addresses to heartbeat from startup")
+	staticPeers := flag.Bool("static-peers", false, "Only exchange heartbeats with -peers and -collectors; disables discovery")
+	peersPerHeartbeat := flag.Int("peers-per-heartbeat", 0, "Send each heartbeat to at most this many peers, rotating round-robin through them (0 sends to all)")
+	maxPeers := flag.Int("max-peers", defaults.MaxPeers, "Forget the least recently heard learned peer beyond this many; -peers and the seed are kept (0 is unbounded)")
+	maxNodes := flag.Int("max-nodes", defaults.MaxNodes, "Evict the least recently seen node beyond about this many tracked nodes (0 is unbounded)")
+	dscp := flag.Int("dscp", 0, "Mark outgoing packets with this DSCP value (0-63, e.g. 46 for expedited forwarding) so routers can prioritize them (0 leaves them unmarked)")
+	socketRcvbuf := flag.Int("socket-rcvbuf", 0, "Request this many bytes of UDP socket receive buffer so heartbeat bursts are not dropped by the kernel; the size granted is logged (0 keeps the OS default)")
 	jsonOutput := flag.Bool("json", false, "Output status in JSON format (for tool consumption)")
+	probeTargets := flag.String("probe", "", "Comma-separated agentless targets to poll (http://host/health, tcp://host:port)")
+	fifoPath := flag.String("fifo-path", "", "Read JSON metric records, one per line, from a named pipe at this path, created if missing (disabled if empty)")
+	probeInterval := flag.Duration("probe-interval", defaults.ProbeInterval, "Time between probe rounds")
+	probeTimeout := flag.Duration("probe-timeout", defaults.ProbeTimeout, "Timeout for a single probe")
+	probeWarnLatency := flag.Duration("probe-warn-latency", defaults.ProbeWarnLatency, "Probes slower than this report WARN (0 disables)")
+	output := flag.String("output", "", "Report format: human, json, json-compact, oneline or template (overrides -json)")
+	reportTemplate := flag.String("template", "", "Inline Go text/template to render reports with")
+	templateFile := flag.String("template-file", "", "File holding a Go text/template to render reports with")
+	jsonCompact := flag.Bool("json-compact", false, "Emit single-line JSON instead of indented (with -json)")
+	unbufferedReports := flag.Bool("unbuffered-reports", false, "Write reports as they are rendered instead of in a single write per report (saves memory on very large reports, but output may interleave)")
+	listNoTelemetry := flag.Bool("list-no-telemetry", false, "List nodes that heartbeat but have never sent telemetry (older agents or status-only relays) in reports")
+	maxDisplayAge := flag.Duration("max-display-age", 0, "Report nodes not seen for longer than this in a separate stale section (0 disables)")
+	groupBy := flag.String("group-by", "", "Group report nodes with per-group status rollups: subnet (IPv4 /24, IPv6 /64)")
+	reportOnChange := flag.Duration("report-on-change", 0, "Report at once when a node changes status or leaves, at most this often, e.g. 1s (0 waits for the periodic report)")
+	timeFormat := flag.String("time-format", "rfc3339", "Report timestamp format: rfc3339, unix, unixmilli or a Go layout such as \"2006-01-02 15:04:05\"")
+	jsonFull := flag.Bool("json-full", false, "Always include telemetry fields in JSON, even when zero (with -json)")
+	suppressErrors := flag.Bool("suppress-repeated-errors", defaults.SuppressRepeatedErrors, "Log repeated telemetry collection failures only on the 1st, 2nd, 4th, 8th... occurrence")
+	once := flag.Bool("once", false, "Listen for one reporting interval, print a single report and exit with a health code (0 OK, 1 WARN, 2 CRITICAL)")
+	ioTimeout := flag.Duration("io-timeout", defaults.IOTimeout, "Socket read/write deadline; also bounds how quickly the listener notices shutdown")
+	location := flag.String("location", "", "This node's location for map dashboards, e.g. datacenter=fra1,lat=50.11,lon=8.68 (any field may be left out)")
+	maxWorkers := flag.Int("max-workers", 0, "Add packet workers up to this many while the packet queue stays nearly full, retiring them when idle (0 keeps -workers fixed)")
+	workers := flag.Int("workers", 0, "Packet processing workers (0 follows GOMAXPROCS, capped by the container CPU limit, minimum 2)")
+	noChecksum := flag.Bool("no-checksum", false, "Skip CRC32 on packets for benchmarking/local links (must match all peers)")
+	packetMagic := flag.Uint("packet-magic", protocol.DefaultMagic, "16-bit prefix identifying PulseCheck packets on a shared port, e.g. 0x5043 (must match all peers)")
+	requireMagic := flag.Bool("require-magic", false, "Drop legacy v1/v2 packets, which carry no magic (enable once every peer is upgraded)")
+	wireVersion := flag.Int("wire-version", defaults.WireVersion, "Packet version to send, 1-6; raise it only once every peer decodes the newer version")
+	tui := flag.Bool("tui", false, "Show an interactive dashboard that refreshes in place (logs are suppressed)")
+	averageNoTelemetry := flag.Bool("average-no-telemetry", false, "Count nodes without telemetry as 0% in the dashboard's fleet averages")
+	dot := flag.Bool("dot", false, "Like -once, but print this node's view of the mesh as a Graphviz DOT graph")
+	warnExitCode := flag.Int("warn-exit-code", defaults.Severity.WarnExitCode, "Exit code for a WARN cluster in -once mode")
+	criticalExitCode := flag.Int("critical-exit-code", defaults.Severity.CriticalExitCode, "Exit code for a CRITICAL cluster in -once mode")
+	warnActionable := flag.Bool("warn-actionable", defaults.Severity.WarnActionable, "Treat WARN as actionable; if false, a WARN cluster exits like OK")
+	exitCodes := flag.String("exit-codes", "", "Comma-separated condition=code overrides for -once exit codes; conditions are ok, any-warn, any-critical and below-min-nodes (e.g. any-warn=0,below-min-nodes=3)")
+	collectors := flag.String("collectors", "", "Comma-separated addresses of the other collectors in a primary/standby group; only the lease holder reports")
+	leaseTTL := flag.Duration("lease-ttl", 0, "How long a silent collector keeps its lease before a standby takes over (default: -timeout)")
+	priority := flag.Int("priority", 0, "Priority advertised to peers, 0-255; the live collector with the highest priority is active, the lowest UUID among equals")
+	signingKey := flag.String("signing-key", "", "Sign heartbeats with the Ed25519 private key in this PEM file")
+	anonymize := flag.Bool("anonymize", false, "Replace node addresses in reports, -dot output and the dashboard with stable aliases such as node-a1b2, for sharing reports publicly")
+	signReports := flag.Bool("sign-reports", false, "Follow each report with an Ed25519 signature line made with -signing-key, so stored reports are tamper-evident")
+	trustedKeys := flag.String("trusted-keys", "", "Only accept heartbeats signed by the node keys listed in this file (one \"<uuid> <base64 public key>\" per line)")
+	pushURL := flag.String("push-url", "", "Also push status and telemetry over HTTP to this collector ingest URL, e.g. https://collector:8080/ingest")
+	pushBreakerThreshold := flag.Int("push-breaker-threshold", defaults.PushBreakerThreshold, "Consecutive push failures that pause pushing for -push-breaker-cooldown (0 disables)")
+	pushPeerView := flag.Bool("push-peer-view", false, "List the peers this node sees in pushed reports, so the collector counts how many peers see each node (needs a collector of this version)")
+	pushBreakerCooldown := flag.Duration("push-breaker-cooldown", defaults.PushBreakerCooldown, "How long pushing pauses after -push-breaker-threshold failures before a trial push")
+	eventStreamClients := flag.Int("event-stream-clients", defaults.EventStreamClients, "Max concurrent clients of the Server-Sent Events endpoint /events/stream on -status-addr (0 disables it)")
+	recomputeStatus := flag.Bool("recompute-status", false, "Re-evaluate pushed telemetry against this collector's thresholds and flag nodes whose self-reported status differs")
+	federateFrom := flag.String("federate-from", "", "Comma-separated status URLs of other collectors to pull and merge into this view, e.g. http://collector-b:8080/status")
+	ingestAddr := flag.String("ingest-addr", "", "Accept reports pushed over HTTP on this address, e.g. :8080; requires -ingest-token or -trusted-keys (disabled if empty)")
+	ingestToken := flag.String("ingest-token", "", "Shared secret pushed reports carry as a bearer token, and the -ingest-addr collector requires (prefer PULSECHECK_INGEST_TOKEN over the command line)")
+	statusAddr := flag.String("status-addr", "", "Serve the read-only endpoints /status, /status/nodes, /config, /healthz, /readyz and /events/stream on this address, e.g. :8081 (disabled if empty)")
+	conditionalHeartbeat := flag.Bool("conditional-heartbeat", false, "Broadcast only when the local status changes, plus a periodic keepalive")
+	criticalRetransmit := flag.Int("critical-retransmit", 0, "Send this many extra copies (50ms apart) of a heartbeat changing the status to or from CRITICAL, to survive packet loss (0 disables, max 5)")
+	keepaliveInterval := flag.Duration("keepalive-interval", 0, "Time between keepalives with -conditional-heartbeat (0 uses half of -timeout)")
+	skewTolerance := flag.Duration("clock-skew-tolerance", defaults.ClockSkewTolerance, "How far ahead of ours a peer's clock may be before its packet timestamps are clamped")
+	packetDedupTTL := flag.Duration("packet-dedup-ttl", defaults.PacketDedupTTL, "Drop a heartbeat whose UUID and timestamp match one received less than this ago, e.g. a retransmitted copy (0 disables)")
+	packetDedupSize := flag.Int("packet-dedup-size", defaults.PacketDedupSize, "Most heartbeats remembered for -packet-dedup-ttl, oldest first out (0 disables)")
+	duplicateWindow := flag.Duration("duplicate-window", defaults.DuplicateWindow, "Warn when one node UUID is reported from two addresses less than this apart (0 disables)")
+	snapshotDir := flag.String("snapshot-dir", os.TempDir(), "Directory for the full state snapshots written on SIGUSR1")
+	eventLog := flag.Bool("event-log", false, "Log every registry event (join, status change, timeout, identity conflict) to stderr as one JSON line")
+	enableChaos := flag.Bool("enable-chaos", false, "TESTING ONLY: accept injected faults (forced status, packet loss, paused heartbeats) on /chaos of -ingest-addr, with -ingest-token")
+	alertGracePeriod := flag.Duration("alert-grace-period", 0, "After startup, mark reports as settling and exit OK in -once mode for this long while discovery fills in the cluster view (0 disables)")
+	expectMinNodes := flag.Int("expect-min-nodes", 0, "Report CRITICAL and exit with the below-min-nodes code (critical unless set by -exit-codes) in -once mode while fewer than this many peers are known, not counting the node itself (0 disables)")
+	selfCheckAfter := flag.Duration("self-check-after", defaults.SelfCheckAfter, "Report this node's own monitoring unhealthy, and fail /healthz, once every send has failed for this long, and fail /readyz once nothing was received from its peers for this long (default twice -timeout, at least 3 heartbeat intervals; negative disables)")
+	textfileOut := flag.String("textfile-out", "", "Write this node's metrics in Prometheus text format to this file on every heartbeat, for the node_exporter textfile collector (disabled if empty)")
+	storePath := flag.String("store-path", "", "Store node snapshots and events in an embedded database in this file so history survives restarts (disabled if empty)")
+	storeInterval := flag.Duration("store-interval", defaults.StoreInterval, "Time between node snapshots written to -store-path")
+	historySegment := flag.Duration("history-segment", defaults.HistorySegment, "Close the -store-path records into a gzipped segment this often (0 keeps them all indexed and uncompressed)")
+	historyRetention := flag.String("history-retention", "", "Delete -store-path segments older than this, e.g. 7d or 36h (empty keeps them all)")
+	onceDuration := flag.Duration("once-duration", defaults.ReportInterval, "How long to listen before reporting in -once mode")
+	silence := flag.String("silence", "", "Comma-separated maintenance windows as addr=duration (e.g. 10.0.0.5:9999=2h); silenced nodes report MAINTENANCE")
+	dependsOn := flag.String("depends-on", "", "Comma-separated dependencies as dependent=dependency addresses (e.g. 10.0.0.3:9999=10.0.0.1:9999); a failing node whose dependency is CRITICAL or was reaped is reported with it and left out of the exit code")
 	
 	// Telemetry thresholds
-	cpuWarn := flag.Float64("cpu-warn-threshold", 70.0, "CPU percentage for Warn status")
-	cpuCritical := flag.Float64("cpu-critical-threshold", 90.0, "CPU percentage for Critical status")
-	ramWarn := flag.Float64("ram-warn-threshold", 80.0, "RAM percentage for Warn status")
-	ramCritical := flag.Float64("ram-critical-threshold", 95.0, "RAM percentage for Critical status")
-	diskWarn := flag.Float64("disk-warn-threshold", 85.0, "Disk percentage for Warn status")
-	diskCritical := flag.Float64("disk-critical-threshold", 95.0, "Disk percentage for Critical status")
+	cpuWarn := flag.Float64("cpu-warn-threshold", defaults.Thresholds.CPUWarn, "CPU percentage for Warn status")
+	cpuCritical := flag.Float64("cpu-critical-threshold", defaults.Thresholds.CPUCritical, "CPU percentage for Critical status")
+	ramWarn := flag.Float64("ram-warn-threshold", defaults.Thresholds.RAMWarn, "RAM percentage for Warn status")
+	ramCritical := flag.Float64("ram-critical-threshold", defaults.Thresholds.RAMCritical, "RAM percentage for Critical status")
+	diskWarn := flag.Float64("disk-warn-threshold", defaults.Thresholds.DiskWarn, "Disk percentage for Warn status")
+	diskCritical := flag.Float64("disk-critical-threshold", defaults.Thresholds.DiskCritical, "Disk percentage for Critical status")
+	cpuSteal := flag.Bool("cpu-steal", false, "Sample CPU steal time, the share stolen by the hypervisor on a VM, and judge it against the steal thresholds (Linux only)")
+	stealWarn := flag.Float64("steal-warn-threshold", defaults.Thresholds.StealWarn, "CPU steal percentage for Warn status with -cpu-steal (0 disables)")
+	stealCritical := flag.Float64("steal-critical-threshold", defaults.Thresholds.StealCritical, "CPU steal percentage for Critical status with -cpu-steal (0 disables)")
+	criticalSustain := flag.Duration("critical-sustain", 0, "Time a metric must stay past its critical threshold before reporting Critical; shorter breaches report Warn (0 is immediate)")
+	ramFreeWarn := flag.Uint64("ram-free-warn-bytes", 0, "Available RAM in bytes below which status is Warn (0 disables)")
+	ramFreeCritical := flag.Uint64("ram-free-critical-bytes", 0, "Available RAM in bytes below which status is Critical (0 disables)")
+	diskFreeWarn := flag.Uint64("disk-free-warn-bytes", 0, "Free disk in bytes below which status is Warn (0 disables)")
+	diskFreeCritical := flag.Uint64("disk-free-critical-bytes", 0, "Free disk in bytes below which status is Critical (0 disables)")
+	diskAllMounts := flag.Bool("disk-all-mounts", false, "Watch every mounted filesystem with the disk thresholds, not just the root and -disk-mounts (pseudo-filesystems, container layers and snaps are skipped)")
+	diskExclude := flag.String("disk-exclude", "", "Comma-separated glob patterns for mount points or devices that -disk-all-mounts skips, e.g. /mnt/backup*,/dev/nbd*")
+	diskMounts := flag.String("disk-mounts", "", "Comma-separated per-mount disk thresholds as path=warn:critical (e.g. /var/lib/postgresql=75:85,/tmp=99:100); the worst mount sets the status, and a / entry replaces the disk thresholds")
 	
 	flag.Parse()
-	
-	// Generate or use node UUID
-	nodeUUID := generateNodeUUID(*nodeID)
-	
-	// Create thresholds
-	thresholds := telemetry.Thresholds{
-		CPUWarn:     *cpuWarn,
-		CPUCritical: *cpuCritical,
-		RAMWarn:     *ramWarn,
-		RAMCritical: *ramCritical,
-		DiskWarn:    *diskWarn,
-		DiskCritical: *diskCritical,
+	if err := applyEnv(flag.CommandLine, os.LookupEnv); err != nil {
+		log.Fatal(err)
 	}
 	
-	// Initialize monitor
-	monitor := registry.NewMonitor()
+	if *packetMagic == 0 || *packetMagic > 0xFFFF {
+		log.Fatalf("Invalid -packet-magic: %#x must be between 0x1 and 0xffff", *packetMagic)
+	}
 	
-	// Create UDP node
-	udpNode, err := registry.NewUDPNode(*port, nodeUUID, monitor)
+	targets, err := probe.ParseTargets(*probeTargets)
 	if err != nil {
-		log.Fatalf("Failed to create UDP node: %v", err)
+		log.Fatalf("Invalid -probe: %v", err)
+	}
+	silences, err := pulsecheck.ParseSilences(*silence)
+	if err != nil {
+		log.Fatalf("Invalid -silence: %v", err)
+	}
+	dependencies, err := pulsecheck.ParseDependencies(*dependsOn)
+	if err != nil {
+		log.Fatalf("Invalid -depends-on: %v", err)
+	}
+	mounts, err := telemetry.ParseMountThresholds(*diskMounts)
+	if err != nil {
+		log.Fatalf("Invalid -disk-mounts: %v", err)
+	}
+	loc, err := pulsecheck.ParseLocation(*location)
+	if err != nil {
+		log.Fatalf("Invalid -location: %v", err)
+	}
+	retention, err := pulsecheck.ParseRetention(*historyRetention)
+	if err != nil {
+		log.Fatalf("Invalid -history-retention: %v", err)
+	}
+	// Too few nodes exits like CRITICAL unless -exit-codes says otherwise
+	severity, err := pulsecheck.ParseExitCodes(*exitCodes, pulsecheck.SeverityPolicy{
+		OKExitCode:       defaults.Severity.OKExitCode,
+		WarnExitCode:     *warnExitCode,
+		CriticalExitCode: *criticalExitCode,
+		WarnActionable:   *warnActionable,
+	})
+	if err != nil {
+		log.Fatalf("Invalid -exit-codes: %v", err)
 	}
 	
-	// Start UDP listener in background
-	go udpNode.Start()
-	
-	// Connect to seed node if provided (for peer discovery)
-	if *seedNode != "" {
-		// Collect initial metrics for seed node connection
-		metrics, err := telemetry.CollectMetrics()
-		if err != nil {
-			log.Printf("Warning: Failed to collect metrics for seed node: %v", err)
-			metrics = &telemetry.Metrics{} // Use zero values
+	var signer ed25519.PrivateKey
+	if *signingKey != "" {
+		if signer, err = pulsecheck.LoadSigningKey(*signingKey); err != nil {
+			log.Fatalf("Invalid -signing-key: %v", err)
 		}
-		statusCode := telemetry.CalculateStatus(metrics, thresholds)
-		
-		// Send initial heartbeat to seed node
-		if err := udpNode.SendToSeedNode(*seedNode, uint8(statusCode)); err != nil {
-			log.Printf("Warning: Failed to connect to seed node %s: %v", *seedNode, err)
-			log.Println("Continuing without seed node - peer discovery may be limited")
-		} else {
-			log.Printf("Connected to seed node: %s", *seedNode)
+	}
+	var trusted map[[16]byte]ed25519.PublicKey
+	if *trustedKeys != "" {
+		if trusted, err = pulsecheck.LoadTrustedKeys(*trustedKeys); err != nil {
+			log.Fatalf("Invalid -trusted-keys: %v", err)
 		}
 	}
 	
-	// Start reaper goroutine
-	go monitor.StartReaper(1*time.Second, *timeout)
+	cfg := pulsecheck.Config{
+		Port:              *port,
+		NodeID:            *nodeID,
+		IdentityFile:      *identityFile,
+		SeedNode:          *seedNode,
+		HeartbeatInterval: *heartbeatInterval,
+		TelemetryInterval: *telemetryInterval,
+		CollectTimeout:    *collectTimeout,
+		Timeout:           *timeout,
+		FailureDetector:   *failureDetector,
+		PhiThreshold:      *phiThreshold,
+		ReapBurst:         *reapBurst,
+		Thresholds: telemetry.Thresholds{
+			CPUWarn:      *cpuWarn,
+			CPUCritical:  *cpuCritical,
+			RAMWarn:      *ramWarn,
+			RAMCritical:  *ramCritical,
+			DiskWarn:     *diskWarn,
+			DiskCritical: *diskCritical,
+
+			RAMFreeWarnBytes:      *ramFreeWarn,
+			RAMFreeCriticalBytes:  *ramFreeCritical,
+			DiskFreeWarnBytes:     *diskFreeWarn,
+			DiskFreeCriticalBytes: *diskFreeCritical,
+
+			StealWarn:     *stealWarn,
+			StealCritical: *stealCritical,
+
+			Mounts: mounts,
+		},
+		CriticalSustain:        *criticalSustain,
+		SuppressRepeatedErrors: *suppressErrors,
+		ReportInterval:         defaults.ReportInterval,
+		ReportOnChange:         *reportOnChange,
+		JSONOutput:             *jsonOutput,
+		JSONCompact:            *jsonCompact,
+		Output:                 *output,
+		Template:               *reportTemplate,
+		TemplateFile:           *templateFile,
+		JSONFull:               *jsonFull,
+		GroupBy:                *groupBy,
+		TimeFormat:             *timeFormat,
+		MaxDisplayAge:          *maxDisplayAge,
+		ListNoTelemetry:        *listNoTelemetry,
+		UnbufferedReports:      *unbufferedReports,
+		NoChecksum:             *noChecksum,
+		PacketMagic:            uint16(*packetMagic),
+		RequireMagic:           *requireMagic,
+		WireVersion:            *wireVersion,
+		IOTimeout:              *ioTimeout,
+		Workers:                *workers,
+		MaxWorkers:             *maxWorkers,
+		Location:               loc,
+		Kubernetes:             pulsecheck.KubernetesFromEnv(),
+		DiskAllMounts:          *diskAllMounts,
+		DiskExclude:            strings.Split(*diskExclude, ","),
+		ProbeTargets:           targets,
+		FIFOPath:               *fifoPath,
+		ProbeInterval:          *probeInterval,
+		ProbeTimeout:           *probeTimeout,
+		ProbeWarnLatency:       *probeWarnLatency,
+		Silences:               silences,
+		Dependencies:           dependencies,
+		Collectors:             strings.Split(*collectors, ","),
+		Peers:                  strings.Split(*peers, ","),
+		StaticPeers:            *staticPeers,
+		PeersPerHeartbeat:      *peersPerHeartbeat,
+		MaxPeers:               *maxPeers,
+		MaxNodes:               *maxNodes,
+		DSCP:                   *dscp,
+		SocketReadBuffer:       *socketRcvbuf,
+		NetInterfaces:          strings.Split(*netInterface, ","),
+		CPUSteal:               *cpuSteal,
+		LeaseTTL:               *leaseTTL,
+		Priority:               *priority,
+		PushURL:                *pushURL,
+		IngestAddr:             *ingestAddr,
+		IngestToken:            *ingestToken,
+		StatusAddr:             *statusAddr,
+		FederateFrom:           strings.Split(*federateFrom, ","),
+		RecomputeStatus:        *recomputeStatus,
+		EventStreamClients:     *eventStreamClients,
+		PushBreakerThreshold:   *pushBreakerThreshold,
+		PushBreakerCooldown:    *pushBreakerCooldown,
+		PushPeerView:           *pushPeerView,
+		StorePath:              *storePath,
+		TextfileOut:            *textfileOut,
+		EnableChaos:            *enableChaos,
+		AlertGracePeriod:       *alertGracePeriod,
+		ExpectMinNodes:         *expectMinNodes,
+		SelfCheckAfter:         *selfCheckAfter,
+		DuplicateWindow:        *duplicateWindow,
+		PacketDedupTTL:         *packetDedupTTL,
+		PacketDedupSize:        *packetDedupSize,
+		ClockSkewTolerance:     *skewTolerance,
+		ConditionalHeartbeat:   *conditionalHeartbeat,
+		KeepaliveInterval:      *keepaliveInterval,
+		CriticalRetransmit:     *criticalRetransmit,
+		HeartbeatBudget:        *heartbeatBudget,
+		MaxHeartbeatInterval:   *maxHeartbeatInterval,
+		StoreInterval:          *storeInterval,
+		HistorySegment:         *historySegment,
+		HistoryRetention:       retention,
+		SigningKey:             signer,
+		TrustedKeys:            trusted,
+		SignReports:            *signReports,
+		Anonymize:              *anonymize,
+		Severity:               severity,
+	}
 	
-	// Initialize status reporter
-	reporter := display.NewReporter(monitor, *jsonOutput)
-	go reporter.Start(10 * time.Second)
-	defer reporter.Stop()
+	if *eventLog {
+		cfg.EventLog = os.Stderr
+	}
 	
-	// Setup graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	// In one-shot mode the reporter is only used for the final report,
+	// and the dashboard replaces it entirely
+	if *dot {
+		*once = true
+	}
+	if *once || *tui {
+		cfg.ReportInterval = 0
+	}
+	if *tui {
+		// Log lines would corrupt the in-place display
+		log.SetOutput(io.Discard)
+	}
 	
-	// Start heartbeat ticker
-	heartbeatTicker := time.NewTicker(*heartbeatInterval)
-	defer heartbeatTicker.Stop()
+	node, err := pulsecheck.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create node: %v", err)
+	}
+	
+	// Setup graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	
-	log.Printf("PulseCheck node started (UUID: %x, Port: %d)", nodeUUID, *port)
-	log.Printf("Heartbeat interval: %v, Timeout: %v", *heartbeatInterval, *timeout)
-	if *seedNode != "" {
-		log.Printf("Seed node: %s", *seedNode)
+	if err := node.Start(ctx); err != nil {
+		log.Fatalf("Failed to start node: %v", err)
 	}
 	if *jsonOutput {
 		log.Println("JSON output mode enabled")
 	}
 	
-	// Main loop - handles heartbeat and shutdown
-	for {
+	// Dump the full state to a file on demand for incident forensics
+	if len(snapshotSignals) > 0 {
+		snapshots := make(chan os.Signal, 1)
+		signal.Notify(snapshots, snapshotSignals...)
+		go func() {
+			for range snapshots {
+				path, err := writeSnapshot(node, *snapshotDir)
+				if err != nil {
+					log.Printf("Failed to write snapshot: %v", err)
+					continue
+				}
+				log.Printf("Wrote snapshot to %s", path)
+			}
+		}()
+	}
+	
+	// Toggle periodic reports, e.g. while tailing logs for something else
+	if len(pauseSignals) > 0 {
+		pauses := make(chan os.Signal, 1)
+		signal.Notify(pauses, pauseSignals...)
+		go func() {
+			for range pauses {
+				if node.ReportsPaused() {
+					log.Println("Reports resumed")
+					node.ResumeReports()
+				} else {
+					node.PauseReports()
+					log.Println("Reports paused; send SIGUSR2 again to resume")
+				}
+			}
+		}()
+	}
+	
+	if *once {
+		log.Printf("One-shot mode: reporting after %v", *onceDuration)
 		select {
-		case <-sigChan:
+		case <-ctx.Done():
 			log.Println("Shutting down...")
-			udpNode.Stop()
+			node.Stop()
 			return
-			
-		case <-heartbeatTicker.C:
-			// Collect telemetry
-			metrics, err := telemetry.CollectMetrics()
-			if err != nil {
-				log.Printf("Failed to collect metrics: %v", err)
-				continue
-			}
-			
-			// Calculate status
-			statusCode := telemetry.CalculateStatus(metrics, thresholds)
-			
-			// Update local monitor with telemetry (use local address)
-			localAddr := udpNode.Conn().LocalAddr().String()
-			monitor.UpdateWithTelemetry(
-				localAddr,
-				metrics.CPUPercent,
-				metrics.RAMPercent,
-				metrics.DiskPercent,
-				uint8(statusCode),
-			)
-			
-			// Broadcast heartbeat
-			if err := udpNode.BroadcastHeartbeat(uint8(statusCode)); err != nil {
-				log.Printf("Failed to broadcast heartbeat: %v", err)
+		case <-time.After(*onceDuration):
+		}
+		
+		// Print a single report and exit with the worst cluster status
+		if *dot {
+			if err := node.WriteTopology(os.Stdout); err != nil {
+				log.Printf("Failed to write topology: %v", err)
 			}
+		} else {
+			node.Report()
 		}
+		node.Stop()
+		os.Exit(node.ExitCode())
 	}
-}
-
-// generateNodeUUID generates a 16-byte UUID from node ID or random
-func generateNodeUUID(nodeID string) [16]byte {
-	var uuid [16]byte
 	
-	if nodeID == "" {
-		// Use hostname
-		hostname, err := os.Hostname()
-		if err != nil {
-			hostname = "unknown"
+	if *tui {
+		dashboard := display.NewDashboard(node.Monitor())
+		dashboard.SetAverageNoTelemetry(*averageNoTelemetry)
+		if a := node.Anonymizer(); a != nil {
+			dashboard.SetAnonymizer(a)
+		}
+		if *failureDetector != pulsecheck.FailureDetectorPhi {
+			dashboard.SetReapTimeout(*timeout)
 		}
-		nodeID = hostname
+		if err := dashboard.Run(ctx, 1*time.Second); err != nil {
+			node.Stop()
+			log.SetOutput(os.Stderr)
+			log.Fatalf("Dashboard failed: %v", err)
+		}
+		node.Stop()
+		return
 	}
 	
-	// Generate UUID from node ID (simple hash-based approach)
-	// For production, consider using a proper UUID library
-	hash := simpleHash(nodeID)
-	copy(uuid[:], hash[:16])
+	<-ctx.Done()
+	log.Println("Shutting down...")
+	node.Stop()
+}
+
+// writeSnapshot dumps the node state to a new timestamped file in dir. The
+// file is written under a temporary name and renamed, so a snapshot is
+// never seen half-written
+func writeSnapshot(node *pulsecheck.Node, dir string) (string, error) {
+	tmp, err := os.CreateTemp(dir, ".pulsecheck-snapshot-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
 	
-	// Fill remaining bytes with random if needed
-	if len(nodeID) < 16 {
-		rand.Read(uuid[len(nodeID):])
+	if err := node.DumpSnapshot(tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
 	}
 	
-	return uuid
+	path := filepath.Join(dir, "pulsecheck-snapshot-"+time.Now().Format("20060102T150405.000")+".json")
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
 }
 
-// simpleHash creates a simple hash from string
-func simpleHash(s string) []byte {
-	hash := make([]byte, 16)
-	for i := 0; i < len(s) && i < 16; i++ {
-		hash[i] = s[i]
+// selfTest runs the loopback self-test and returns the process exit code
+func selfTest(args []string) int {
+	flags := flag.NewFlagSet("selftest", flag.ExitOnError)
+	timeout := flags.Duration("timeout", 2*time.Second, "How long to wait for the heartbeat to come back")
+	flags.Parse(args)
+	
+	rtt, err := pulsecheck.SelfTest(*timeout)
+	if err != nil {
+		fmt.Printf("Self-test FAILED: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Self-test passed: bound, sent, received and decoded a heartbeat on loopback in %v\n", rtt)
+	return 0
+}
+
+// loadGen sends synthetic heartbeats at a collector and returns the process exit code
+func loadGen(args []string) int {
+	flags := flag.NewFlagSet("loadgen", flag.ExitOnError)
+	target := flags.String("target", "", "Collector address to send heartbeats to, e.g. 10.0.0.5:9999")
+	nodes := flags.Int("nodes", 1000, "Virtual nodes to send as, each with its own UUID")
+	rate := flags.Float64("rate", 1000, "Packets per second across all virtual nodes")
+	duration := flags.Duration("duration", 10*time.Second, "How long to send for")
+	noChecksum := flags.Bool("no-checksum", false, "Send packets without CRC32 (must match the target)")
+	packetMagic := flags.Uint("packet-magic", protocol.DefaultMagic, "16-bit packet prefix (must match the target)")
+	flags.Parse(args)
+	
+	if *target == "" {
+		fmt.Println("loadgen: -target is required")
+		return 2
+	}
+	if *packetMagic == 0 || *packetMagic > 0xFFFF {
+		fmt.Printf("loadgen: -packet-magic %#x must be between 0x1 and 0xffff\n", *packetMagic)
+		return 2
+	}
+	
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	fmt.Printf("Sending %.0f packets/s from %d virtual nodes to %s for %v...\n", *rate, *nodes, *target, *duration)
+	result, err := pulsecheck.LoadGen(ctx, pulsecheck.LoadGenConfig{
+		Target:     *target,
+		Nodes:      *nodes,
+		Rate:       *rate,
+		Duration:   *duration,
+		NoChecksum: *noChecksum,
+		Magic:      uint16(*packetMagic),
+	})
+	if err != nil {
+		fmt.Printf("loadgen: %v\n", err)
+		return 1
 	}
-	return hash
+	fmt.Printf("Sent %d packets in %v: %.0f packets/s achieved of %.0f requested, %d send errors\n",
+		result.Sent, result.Elapsed.Round(time.Millisecond), result.Rate(), *rate, result.SendErrors)
+	return 0
 }
 
+// verifyReport checks signed reports read from a file or stdin and returns
+// the process exit code
+func verifyReport(args []string) int {
+	flags := flag.NewFlagSet("verify-report", flag.ExitOnError)
+	publicKey := flags.String("public-key", "", "Base64 Ed25519 public key of the node that wrote the reports, as logged on its start")
+	flags.Parse(args)
+	
+	key, err := base64.StdEncoding.DecodeString(*publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		fmt.Println("verify-report: -public-key must be a base64 Ed25519 public key")
+		return 2
+	}
+	in := io.Reader(os.Stdin)
+	if flags.NArg() > 0 {
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			fmt.Printf("verify-report: %v\n", err)
+			return 2
+		}
+		defer f.Close()
+		in = f
+	}
+	
+	n, err := pulsecheck.VerifyReports(in, ed25519.PublicKey(key))
+	if err != nil {
+		fmt.Printf("verify-report: %v (verified %d before it)\n", err, n)
+		return 1
+	}
+	fmt.Printf("Verified %d reports\n", n)
+	return 0
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// snapshotSignals request a state snapshot file
+var snapshotSignals = []os.Signal{syscall.SIGUSR1}
+
+// pauseSignals toggle periodic reports
+var pauseSignals = []os.Signal{syscall.SIGUSR2}
@@ -0,0 +1,11 @@
+package main
+
+import "os"
+
+// snapshotSignals is empty because Windows has no SIGUSR1. Embedders can
+// call Node.DumpSnapshot directly
+var snapshotSignals []os.Signal
+
+// pauseSignals is empty because Windows has no SIGUSR2. Embedders can call
+// Node.PauseReports and Node.ResumeReports directly
+var pauseSignals []os.Signal
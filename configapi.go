@@ -0,0 +1,78 @@
+package pulsecheck
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+)
+
+// ConfigPath is the HTTP endpoint serving the node's active thresholds
+const ConfigPath = "/config"
+
+// thresholdsJSON is the JSON form of Thresholds. Absolute free-space
+// thresholds are omitted when disabled
+type thresholdsJSON struct {
+	CPUWarn               float64 `json:"cpu_warn"`
+	CPUCritical           float64 `json:"cpu_critical"`
+	RAMWarn               float64 `json:"ram_warn"`
+	RAMCritical           float64 `json:"ram_critical"`
+	DiskWarn              float64 `json:"disk_warn"`
+	DiskCritical          float64 `json:"disk_critical"`
+	RAMFreeWarnBytes      uint64  `json:"ram_free_warn_bytes,omitempty"`
+	RAMFreeCriticalBytes  uint64  `json:"ram_free_critical_bytes,omitempty"`
+	DiskFreeWarnBytes     uint64  `json:"disk_free_warn_bytes,omitempty"`
+	DiskFreeCriticalBytes uint64  `json:"disk_free_critical_bytes,omitempty"`
+
+	Mounts []mountJSON `json:"mounts,omitempty"`
+}
+
+// mountJSON is the JSON form of a MountThreshold
+type mountJSON struct {
+	Path     string  `json:"path"`
+	Warn     float64 `json:"warn"`
+	Critical float64 `json:"critical"`
+}
+
+// configJSON is the body served on ConfigPath
+type configJSON struct {
+	NodeID          string         `json:"node_id"`
+	UUID            string         `json:"uuid"`
+	Thresholds      thresholdsJSON `json:"thresholds"`
+	CriticalSustain string         `json:"critical_sustain"`
+}
+
+// configHandler serves the thresholds this node computes its status from,
+// so operators can check a node's configuration without logging in.
+// Thresholds are not secret and nothing is redacted
+func (n *Node) configHandler() http.Handler {
+	t := n.config.Thresholds
+	body := configJSON{
+		NodeID: n.config.NodeID,
+		UUID:   hex.EncodeToString(n.uuid[:]),
+		Thresholds: thresholdsJSON{
+			CPUWarn:               t.CPUWarn,
+			CPUCritical:           t.CPUCritical,
+			RAMWarn:               t.RAMWarn,
+			RAMCritical:           t.RAMCritical,
+			DiskWarn:              t.DiskWarn,
+			DiskCritical:          t.DiskCritical,
+			RAMFreeWarnBytes:      t.RAMFreeWarnBytes,
+			RAMFreeCriticalBytes:  t.RAMFreeCriticalBytes,
+			DiskFreeWarnBytes:     t.DiskFreeWarnBytes,
+			DiskFreeCriticalBytes: t.DiskFreeCriticalBytes,
+		},
+		CriticalSustain: n.config.CriticalSustain.String(),
+	}
+	for _, m := range t.Mounts {
+		body.Thresholds.Mounts = append(body.Thresholds.Mounts, mountJSON{Path: m.Path, Warn: m.Warn, Critical: m.Critical})
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	})
+}
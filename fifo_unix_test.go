@@ -0,0 +1,42 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package pulsecheck
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// fifoHasReader reports whether some descriptor still holds the FIFO at
+// path open: a non-blocking open for writing only succeeds then
+func fifoHasReader(path string) bool {
+	f, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+func TestNewInvalidConfigReleasesFIFO(t *testing.T) {
+	for name, broken := range map[string]func(*Config){
+		"collectors":    func(cfg *Config) { cfg.Collectors = []string{"not a host:port"} },
+		"push URL":      func(cfg *Config) { cfg.PushURL = "ftp://collector" },
+		"federate from": func(cfg *Config) { cfg.FederateFrom = []string{"not a url"} },
+		"store path":    func(cfg *Config) { cfg.StorePath = filepath.Join(t.TempDir(), "missing", "history.db") },
+	} {
+		cfg := DefaultConfig()
+		cfg.Port = 0
+		cfg.FIFOPath = filepath.Join(t.TempDir(), "metrics.fifo")
+		broken(&cfg)
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New() with a bad %s should return error", name)
+			continue
+		}
+		if fifoHasReader(cfg.FIFOPath) {
+			t.Errorf("New() with a bad %s left the FIFO open", name)
+		}
+	}
+}
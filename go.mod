@@ -2,7 +2,11 @@ module github.com/rafaelmarinho/pulsecheck
 
 go 1.21
 
-require github.com/shirou/gopsutil/v3 v3.24.1
+require (
+	github.com/shirou/gopsutil/v3 v3.24.1
+	go.etcd.io/bbolt v1.3.8
+	golang.org/x/term v0.16.0
+)
 
 require (
 	github.com/go-ole/go-ole v1.2.6 // indirect
@@ -0,0 +1,47 @@
+package pulsecheck
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HealthzPath is the HTTP endpoint for a liveness probe, served on
+// StatusAddr. It fails with 503 only on local faults that a restart may
+// fix: a dead listener or every send failing, see Config.SelfCheckAfter
+const HealthzPath = "/healthz"
+
+// ReadyzPath is the HTTP endpoint for a readiness probe, served on
+// StatusAddr. It also fails while nothing is received from the peers and
+// during the AlertGracePeriod
+const ReadyzPath = "/readyz"
+
+// healthJSON is the body served on HealthzPath and ReadyzPath
+type healthJSON struct {
+	TrafficHealth
+	Ready    bool `json:"ready"`
+	Settling bool `json:"settling,omitempty"`
+}
+
+// healthHandler serves HealthzPath, or ReadyzPath when ready is set
+func (n *Node) healthHandler(ready bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		health := n.TrafficHealth()
+		body := healthJSON{TrafficHealth: health, Ready: health.Ready()}
+		code := http.StatusOK
+		if !body.Healthy || ready && !body.Ready {
+			code = http.StatusServiceUnavailable
+		}
+		if ready && n.InAlertGrace() {
+			body.Settling = true
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(body)
+	})
+}
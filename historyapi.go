@@ -0,0 +1,63 @@
+package pulsecheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HistoryPath is the HTTP endpoint querying the persisted history, served
+// on StatusAddr when StorePath is set
+const HistoryPath = "/history"
+
+// historyHandler serves HistoryPath. The optional node, from and to query
+// parameters select the records as in History, with the times in RFC 3339.
+// The records are returned as a JSON array, oldest first
+func (n *Node) historyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := req.URL.Query()
+		from, err := parseHistoryTime(query, "from")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		to, err := parseHistoryTime(query, "to")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		records, err := n.History(query.Get("node"), from, to)
+		if err != nil {
+			log.Printf("History query failed: %v", err)
+			http.Error(w, "history query failed", http.StatusInternalServerError)
+			return
+		}
+		if records == nil {
+			records = []HistoryRecord{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(records)
+	})
+}
+
+// parseHistoryTime parses the RFC 3339 time in the query parameter name,
+// with the zero time if it is absent
+func parseHistoryTime(query url.Values, name string) (time.Time, error) {
+	v := query.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: expected an RFC 3339 time", name, v)
+	}
+	return t, nil
+}
@@ -0,0 +1,51 @@
+package pulsecheck
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadIdentity returns the node UUID stored in path. If the file does not
+// exist, a UUID is generated from nodeID as without an identity file and
+// saved there, so later starts reuse it. The file holds 32 hex digits
+func loadIdentity(path, nodeID string) ([16]byte, error) {
+	var uuid [16]byte
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		uuid = generateNodeUUID(nodeID)
+		return uuid, saveIdentity(path, uuid)
+	}
+	if err != nil {
+		return uuid, err
+	}
+	b, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(b) != len(uuid) {
+		return uuid, fmt.Errorf("%s: expected a node UUID as 32 hex digits", path)
+	}
+	copy(uuid[:], b)
+	return uuid, nil
+}
+
+// saveIdentity writes uuid to path under a temporary name and renames it,
+// so a crash never leaves a truncated identity behind
+func saveIdentity(path string, uuid [16]byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".pulsecheck-identity-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := fmt.Fprintln(tmp, hex.EncodeToString(uuid[:])); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,86 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeNow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	if !f.Now().Equal(start) {
+		t.Errorf("Now() = %v, want %v", f.Now(), start)
+	}
+	f.Advance(time.Minute)
+	if got := f.Now().Sub(start); got != time.Minute {
+		t.Errorf("Now() after Advance = start+%v, want start+1m", got)
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	ticker := f.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	f.Advance(9 * time.Second)
+	select {
+	case tick := <-ticker.C():
+		t.Fatalf("ticker fired early at %v", tick)
+	default:
+	}
+
+	f.Advance(time.Second)
+	select {
+	case tick := <-ticker.C():
+		if want := start.Add(10 * time.Second); !tick.Equal(want) {
+			t.Errorf("tick = %v, want %v", tick, want)
+		}
+	default:
+		t.Fatal("ticker did not fire when due")
+	}
+
+	// Ticks that are not received are dropped, like time.Ticker
+	f.Advance(time.Minute)
+	<-ticker.C()
+	select {
+	case tick := <-ticker.C():
+		t.Errorf("got a second buffered tick %v, want dropped", tick)
+	default:
+	}
+}
+
+func TestFakeTickerStop(t *testing.T) {
+	f := NewFake(time.Now())
+	ticker := f.NewTicker(time.Second)
+	ticker.Stop()
+	f.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		t.Error("stopped ticker fired")
+	default:
+	}
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	f := NewFake(time.Now())
+	done := make(chan struct{})
+	go func() {
+		f.BlockUntil(2)
+		close(done)
+	}()
+
+	f.NewTicker(time.Second)
+	select {
+	case <-done:
+		t.Fatal("BlockUntil(2) returned with one ticker")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	f.NewTicker(time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("BlockUntil(2) did not return once two tickers existed")
+	}
+}
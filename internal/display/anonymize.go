@@ -0,0 +1,196 @@
+package display
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+// aliasHexLen is the length of the hash part of a fresh alias. A collision
+// lengthens the later alias rather than reusing the earlier one
+const aliasHexLen = 4
+
+// Anonymizer replaces addresses with stable pseudonyms such as node-a1b2, so
+// a report can be attached to a public issue without leaking internal IPs.
+// Aliases are HMACs under a random per-Anonymizer key: the same address gets
+// the same alias for the whole run, but hashing candidate IPs cannot reverse
+// them. Safe for concurrent use
+type Anonymizer struct {
+	key []byte
+
+	mu      sync.Mutex
+	aliases map[string]string // prefix NUL original to alias
+	taken   map[string]bool
+}
+
+// NewAnonymizer returns an Anonymizer with a fresh random key
+func NewAnonymizer() (*Anonymizer, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate anonymizer key: %w", err)
+	}
+	return &Anonymizer{
+		key:     key,
+		aliases: make(map[string]string),
+		taken:   make(map[string]bool),
+	}, nil
+}
+
+// Addr returns the alias of a node address
+func (a *Anonymizer) Addr(addr string) string {
+	return a.alias("node", addr)
+}
+
+// alias returns the alias of s, e.g. prefix-a1b2
+func (a *Anonymizer) alias(prefix, s string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	id := prefix + "\x00" + s
+	if alias, ok := a.aliases[id]; ok {
+		return alias
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(id))
+	sum := hex.EncodeToString(mac.Sum(nil))
+	alias := prefix + "-" + sum[:aliasHexLen]
+	for n := aliasHexLen + 2; a.taken[alias] && n <= len(sum); n += 2 {
+		alias = prefix + "-" + sum[:n]
+	}
+	a.aliases[id] = alias
+	a.taken[alias] = true
+	return alias
+}
+
+// Nodes returns a copy of nodes keyed by alias, with every field that can
+// name a host aliased as well
+func (a *Anonymizer) Nodes(nodes map[string]registry.NodeInfo) map[string]registry.NodeInfo {
+	result := make(map[string]registry.NodeInfo, len(nodes))
+	for addr, info := range nodes {
+		info.Address = a.Addr(addr)
+		if info.FederatedFrom != "" {
+			info.FederatedFrom = a.alias("collector", info.FederatedFrom)
+		}
+		info.Location = a.location(info.Location)
+		info.Kubernetes = a.kubernetes(info.Kubernetes)
+		result[info.Address] = info
+	}
+	return result
+}
+
+// location returns l with its datacenter aliased. Coordinates are kept,
+// since map dashboards need them and they do not identify a host
+func (a *Anonymizer) location(l registry.Location) registry.Location {
+	if l.Datacenter != "" {
+		l.Datacenter = a.alias("dc", l.Datacenter)
+	}
+	return l
+}
+
+// kubernetes returns k with every name aliased. Kubernetes node names
+// often embed the host IP, as on EKS
+func (a *Anonymizer) kubernetes(k registry.Kubernetes) registry.Kubernetes {
+	if k.Pod != "" {
+		k.Pod = a.alias("pod", k.Pod)
+	}
+	if k.Namespace != "" {
+		k.Namespace = a.alias("ns", k.Namespace)
+	}
+	if k.Node != "" {
+		k.Node = a.alias("k8s-node", k.Node)
+	}
+	return k
+}
+
+// scrub replaces every address in report with its alias: node addresses,
+// federation sources, subnet group names, datacenters and Kubernetes names. Lists sorted by address are
+// sorted again by alias, so their order gives nothing away
+func (a *Anonymizer) scrub(report *StatusReport) {
+	report.Nodes = a.scrubNodes(report.Nodes, report.GroupBy)
+	report.Stale = a.scrubNodes(report.Stale, report.GroupBy)
+
+	if report.GroupBy == GroupSubnet && report.Groups != nil {
+		groups := make(map[string]GroupStatus, len(report.Groups))
+		for name, g := range report.Groups {
+			groups[a.group(name)] = g
+		}
+		report.Groups = groups
+	}
+
+	for i := range report.DuplicateIdentities {
+		addrs := report.DuplicateIdentities[i].Addresses
+		scrubbed := make([]string, len(addrs))
+		for j, addr := range addrs {
+			scrubbed[j] = a.Addr(addr)
+		}
+		sort.Strings(scrubbed)
+		report.DuplicateIdentities[i].Addresses = scrubbed
+	}
+	for i := range report.ConfigWarnings {
+		report.ConfigWarnings[i].Address = a.Addr(report.ConfigWarnings[i].Address)
+	}
+	sort.Slice(report.ConfigWarnings, func(i, j int) bool {
+		return report.ConfigWarnings[i].Address < report.ConfigWarnings[j].Address
+	})
+	for i, addr := range report.NoTelemetry {
+		report.NoTelemetry[i] = a.Addr(addr)
+	}
+	sort.Strings(report.NoTelemetry)
+	for i := range report.Offline {
+		report.Offline[i].Address = a.Addr(report.Offline[i].Address)
+		report.Offline[i].BlockedBy = a.scrubDependencies(report.Offline[i].BlockedBy)
+	}
+}
+
+// scrubDependencies returns a copy of deps with aliased addresses
+func (a *Anonymizer) scrubDependencies(deps []DependencyStatus) []DependencyStatus {
+	if deps == nil {
+		return nil
+	}
+	result := make([]DependencyStatus, len(deps))
+	for i, d := range deps {
+		result[i] = DependencyStatus{Address: a.Addr(d.Address), Status: d.Status}
+	}
+	return result
+}
+
+// scrubNodes returns nodes keyed and labeled by alias
+func (a *Anonymizer) scrubNodes(nodes map[string]NodeStatus, groupBy GroupBy) map[string]NodeStatus {
+	if nodes == nil {
+		return nil
+	}
+	result := make(map[string]NodeStatus, len(nodes))
+	for addr, n := range nodes {
+		n.Address = a.Addr(addr)
+		n.BlockedBy = a.scrubDependencies(n.BlockedBy)
+		if n.FederatedFrom != "" {
+			n.FederatedFrom = a.alias("collector", n.FederatedFrom)
+		}
+		if n.Location != nil {
+			loc := a.location(*n.Location)
+			n.Location = &loc
+		}
+		if n.Kubernetes != nil {
+			k := a.kubernetes(*n.Kubernetes)
+			n.Kubernetes = &k
+		}
+		if groupBy == GroupSubnet {
+			n.Group = a.group(n.Group)
+		}
+		result[n.Address] = n
+	}
+	return result
+}
+
+// group returns the alias of a subnet group name
+func (a *Anonymizer) group(name string) string {
+	if name == defaultGroup {
+		return name
+	}
+	return a.alias("subnet", name)
+}
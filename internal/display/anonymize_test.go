@@ -0,0 +1,112 @@
+package display
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+func TestAnonymizerAliases(t *testing.T) {
+	a, err := NewAnonymizer()
+	if err != nil {
+		t.Fatalf("NewAnonymizer() error = %v", err)
+	}
+	first := a.Addr("10.0.0.1:9999")
+	if !strings.HasPrefix(first, "node-") || strings.Contains(first, "10.0.0.1") {
+		t.Errorf("Addr() = %q, want a node- alias", first)
+	}
+	if again := a.Addr("10.0.0.1:9999"); again != first {
+		t.Errorf("Addr() = %q then %q, want a stable alias", first, again)
+	}
+
+	// Aliases stay unique even when their short hashes collide
+	seen := make(map[string]bool)
+	for i := 0; i < 2000; i++ {
+		alias := a.Addr(fmt.Sprintf("10.0.%d.%d:9999", i/256, i%256))
+		if seen[alias] {
+			t.Fatalf("Addr() returned %q for two addresses", alias)
+		}
+		seen[alias] = true
+	}
+
+	other, _ := NewAnonymizer()
+	if other.Addr("10.0.0.1:9999") == first {
+		t.Error("two Anonymizers gave the same alias; the key should be per run")
+	}
+}
+
+func TestReporterAnonymize(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithTelemetry("10.0.0.1:9999", 40, 50, 60, 0)
+	monitor.UpdateWithStatus("10.0.0.2:9999", 2, 0)
+	monitor.UpdateWithStatus("192.168.7.3:9999", 1, 0)
+	monitor.UpdateLocation("10.0.0.1:9999", registry.Location{Datacenter: "dc-10.0.0.0", Latitude: 1, Longitude: 2, HasCoordinates: true})
+	monitor.UpdateKubernetes("10.0.0.1:9999", registry.Kubernetes{Pod: "api-10-0-0-1", Namespace: "prod", Node: "ip-10-0-0-1.ec2.internal"})
+
+	a, err := NewAnonymizer()
+	if err != nil {
+		t.Fatalf("NewAnonymizer() error = %v", err)
+	}
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, true)
+	reporter.SetAnonymizer(a)
+	reporter.SetGroupBy(GroupSubnet)
+	reporter.SetListNoTelemetry(true)
+	reporter.output = &buf
+	reporter.Report()
+
+	for _, leak := range []string{"10.0.0", "10-0-0", "192.168", "prod"} {
+		if strings.Contains(buf.String(), leak) {
+			t.Errorf("JSON report leaks %s:\n%s", leak, buf.String())
+		}
+	}
+	var report StatusReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	alias := a.Addr("10.0.0.1:9999")
+	n, ok := report.Nodes[alias]
+	if !ok || n.Address != alias || n.CPUPercent != 40 {
+		t.Errorf("Nodes[%s] = %+v, %v, want the node's telemetry under its alias", alias, n, ok)
+	}
+	if n.Kubernetes == nil || !strings.HasPrefix(n.Kubernetes.Node, "k8s-node-") || n.Location == nil || n.Location.Latitude != 1 {
+		t.Errorf("Nodes[%s] Kubernetes = %+v, Location = %+v, want aliases and the coordinates", alias, n.Kubernetes, n.Location)
+	}
+	if len(report.Groups) != 2 {
+		t.Errorf("Groups = %v, want the two subnets, aliased", report.Groups)
+	}
+	if g := report.Groups[n.Group]; g.NodeCount != 2 {
+		t.Errorf("Groups[%s].NodeCount = %d, want 2", n.Group, g.NodeCount)
+	}
+	if len(report.NoTelemetry) != 2 {
+		t.Errorf("NoTelemetry = %v, want 2 aliases", report.NoTelemetry)
+	}
+
+	// The same addresses keep their aliases in the next report and format
+	buf.Reset()
+	reporter.jsonMode = false
+	reporter.Report()
+	if !strings.Contains(buf.String(), alias) || strings.Contains(buf.String(), "10.0.0") {
+		t.Errorf("Human report should show %s and no addresses:\n%s", alias, buf.String())
+	}
+}
+
+func TestDashboardAnonymize(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithTelemetry("10.0.0.1:9999", 40, 50, 60, 0)
+	a, err := NewAnonymizer()
+	if err != nil {
+		t.Fatalf("NewAnonymizer() error = %v", err)
+	}
+
+	d, buf := newTestDashboard(monitor)
+	d.SetAnonymizer(a)
+	d.Render()
+	if !strings.Contains(buf.String(), a.Addr("10.0.0.1:9999")) || strings.Contains(buf.String(), "10.0.0") {
+		t.Errorf("Render() should show the alias and no addresses:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,75 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+// WriteDOT writes this node's view of the mesh as a Graphviz DOT graph
+// Nodes are colored by status and edges point from self to each known peer
+func WriteDOT(w io.Writer, self string, peers []string, nodes map[string]registry.NodeInfo) error {
+	// Collect every vertex: self, known peers, and nodes seen by the monitor
+	vertices := map[string]struct{}{self: {}}
+	for _, peer := range peers {
+		vertices[peer] = struct{}{}
+	}
+	for addr := range nodes {
+		vertices[addr] = struct{}{}
+	}
+
+	names := make([]string, 0, len(vertices))
+	for name := range vertices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sortedPeers := append([]string(nil), peers...)
+	sort.Strings(sortedPeers)
+
+	if _, err := fmt.Fprintln(w, "digraph pulsecheck {"); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "  node [shape=box, style=filled, fontname=\"monospace\"];")
+
+	for _, name := range names {
+		color := "gray"
+		label := name
+		if info, ok := nodes[name]; ok {
+			color = dotColor(info.StatusCode)
+			label = name + "\\n" + statusCodeToString(info.StatusCode)
+		}
+		if name == self {
+			label += "\\n(self)"
+		}
+		fmt.Fprintf(w, "  %s [label=%s, fillcolor=%s];\n", dotQuote(name), dotQuote(label), color)
+	}
+
+	for _, peer := range sortedPeers {
+		fmt.Fprintf(w, "  %s -> %s;\n", dotQuote(self), dotQuote(peer))
+	}
+
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// dotQuote returns s as a DOT quoted string. Only double quotes are escaped
+// so that DOT escapes such as \n in labels are preserved
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// dotColor returns the Graphviz fill color for a status code
+func dotColor(code uint8) string {
+	switch code {
+	case 0:
+		return "palegreen"
+	case 1:
+		return "gold"
+	default:
+		return "tomato"
+	}
+}
@@ -0,0 +1,51 @@
+package display
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+func TestWriteDOT(t *testing.T) {
+	nodes := map[string]registry.NodeInfo{
+		"10.0.0.1:9999": {Address: "10.0.0.1:9999", StatusCode: 0},
+		"10.0.0.2:9999": {Address: "10.0.0.2:9999", StatusCode: 2},
+	}
+	peers := []string{"10.0.0.2:9999", "10.0.0.3:9999"}
+
+	var buf bytes.Buffer
+	if err := WriteDOT(&buf, "10.0.0.1:9999", peers, nodes); err != nil {
+		t.Fatalf("WriteDOT() error = %v", err)
+	}
+
+	output := buf.String()
+	if !strings.HasPrefix(output, "digraph pulsecheck {") || !strings.HasSuffix(output, "}\n") {
+		t.Errorf("WriteDOT() output is not a digraph:\n%s", output)
+	}
+
+	wants := []string{
+		`"10.0.0.1:9999" -> "10.0.0.2:9999";`,
+		`"10.0.0.1:9999" -> "10.0.0.3:9999";`,
+		`"10.0.0.2:9999" [label="10.0.0.2:9999\nCRITICAL", fillcolor=tomato];`,
+		`"10.0.0.3:9999" [label="10.0.0.3:9999", fillcolor=gray];`,
+		`(self)`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(output, want) {
+			t.Errorf("WriteDOT() output missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestWriteDOTIPv6(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDOT(&buf, "[::1]:9999", []string{"[fe80::1]:9999"}, nil); err != nil {
+		t.Fatalf("WriteDOT() error = %v", err)
+	}
+
+	if !strings.Contains(buf.String(), `"[::1]:9999" -> "[fe80::1]:9999";`) {
+		t.Errorf("WriteDOT() output missing IPv6 edge:\n%s", buf.String())
+	}
+}
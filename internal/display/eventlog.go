@@ -0,0 +1,135 @@
+package display
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+// eventLogQueueSize bounds the events waiting to be written
+const eventLogQueueSize = 1024
+
+// EventLine is one registry event as written by EventLogger
+type EventLine struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Address    string    `json:"addr"`
+	Status     string    `json:"status,omitempty"`      // Status after the event; absent for duplicate_identity
+	PrevStatus string    `json:"prev_status,omitempty"` // status_changed only
+	UUID       string    `json:"uuid,omitempty"`        // duplicate_identity only
+}
+
+// EventLogger writes every registry event as a single JSON line, for log
+// pipelines that index cluster transitions. Events are queued so a slow
+// writer never blocks the monitor; when the queue is full they are dropped
+// and counted
+type EventLogger struct {
+	w        io.Writer
+	queue    chan registry.Event
+	dropped  atomic.Uint64
+	stopChan chan struct{}
+	stopOnce sync.Once
+	doneChan chan struct{} // Closed when the writer loop exits
+	running  atomic.Bool
+}
+
+// NewEventLogger creates a logger writing to w, e.g. os.Stderr
+func NewEventLogger(w io.Writer) *EventLogger {
+	return &EventLogger{
+		w:        w,
+		queue:    make(chan registry.Event, eventLogQueueSize),
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+}
+
+// RecordEvent queues an event without blocking
+// Suitable for Monitor.SetEventHandler
+func (l *EventLogger) RecordEvent(e registry.Event) {
+	select {
+	case l.queue <- e:
+	default:
+		l.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of events discarded because the queue was full
+func (l *EventLogger) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
+// Start writes queued events until Stop is called
+func (l *EventLogger) Start() {
+	if !l.running.CompareAndSwap(false, true) {
+		return
+	}
+	defer close(l.doneChan)
+
+	encoder := json.NewEncoder(l.w)
+	for {
+		select {
+		case <-l.stopChan:
+			l.drain(encoder)
+			return
+		case e := <-l.queue:
+			l.write(encoder, e)
+		}
+	}
+}
+
+// Stop writes the events still queued and stops the logger. Safe to call
+// more than once
+func (l *EventLogger) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stopChan)
+		// As in store.Stop, drain here if Start never ran
+		if l.running.CompareAndSwap(false, true) {
+			l.drain(json.NewEncoder(l.w))
+		} else {
+			<-l.doneChan
+		}
+	})
+}
+
+// drain writes everything still queued
+func (l *EventLogger) drain(encoder *json.Encoder) {
+	for {
+		select {
+		case e := <-l.queue:
+			l.write(encoder, e)
+		default:
+			return
+		}
+	}
+}
+
+// write encodes one event as a JSON line
+func (l *EventLogger) write(encoder *json.Encoder, e registry.Event) {
+	if err := encoder.Encode(newEventLine(e)); err != nil {
+		log.Printf("Failed to write event log: %v", err)
+	}
+}
+
+// newEventLine converts an event to its logged form
+func newEventLine(e registry.Event) EventLine {
+	line := EventLine{
+		Time:    e.Time,
+		Type:    string(e.Type),
+		Address: e.Address,
+	}
+	if e.Type == registry.EventDuplicateIdentity {
+		line.UUID = hex.EncodeToString(e.UUID[:])
+		return line
+	}
+	line.Status = statusCodeToString(e.StatusCode)
+	if e.Type == registry.EventStatusChanged {
+		line.PrevStatus = statusCodeToString(e.PrevStatus)
+	}
+	return line
+}
@@ -0,0 +1,60 @@
+package display
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+func TestEventLoggerLines(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewEventLogger(&buf)
+	go logger.Start()
+
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var uuid [16]byte
+	uuid[15] = 0xab
+	logger.RecordEvent(registry.Event{Time: at, Type: registry.EventJoined, Address: "10.0.0.1:9999"})
+	logger.RecordEvent(registry.Event{Time: at, Type: registry.EventStatusChanged, Address: "10.0.0.1:9999", StatusCode: 1})
+	logger.RecordEvent(registry.Event{Time: at, Type: registry.EventDuplicateIdentity, Address: "10.0.0.2:9999", UUID: uuid})
+	logger.Stop()
+
+	want := []string{
+		`{"time":"2025-01-01T12:00:00Z","type":"joined","addr":"10.0.0.1:9999","status":"OK"}`,
+		`{"time":"2025-01-01T12:00:00Z","type":"status_changed","addr":"10.0.0.1:9999","status":"WARN","prev_status":"OK"}`,
+		`{"time":"2025-01-01T12:00:00Z","type":"duplicate_identity","addr":"10.0.0.2:9999","uuid":"000000000000000000000000000000ab"}`,
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("event log =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEventLoggerStopWithoutStart(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewEventLogger(&buf)
+	logger.RecordEvent(registry.Event{Type: registry.EventLeft, Address: "10.0.0.1:9999", StatusCode: 2})
+	logger.Stop()
+	logger.Stop()
+
+	var line EventLine
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Stop() did not flush a valid line: %v (%q)", err, buf.String())
+	}
+	if line.Type != "left" || line.Status != "CRITICAL" {
+		t.Errorf("line = %+v, want left CRITICAL", line)
+	}
+}
+
+func TestEventLoggerDropsWhenFull(t *testing.T) {
+	logger := NewEventLogger(&bytes.Buffer{})
+	for i := 0; i < eventLogQueueSize+3; i++ {
+		logger.RecordEvent(registry.Event{Type: registry.EventJoined})
+	}
+	if got := logger.Dropped(); got != 3 {
+		t.Errorf("Dropped() = %d, want 3", got)
+	}
+}
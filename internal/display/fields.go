@@ -0,0 +1,142 @@
+package display
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// fieldAliases maps short field names accepted by the status API's fields
+// parameter to the JSON keys of NodeStatus
+var fieldAliases = map[string]string{
+	"cpu":  "cpu_percent",
+	"ram":  "ram_percent",
+	"disk": "disk_percent",
+	"loss": "packet_loss_percent",
+	"via":  "federated_from",
+}
+
+// parseFields parses a comma-separated field list, e.g. "address,status,cpu",
+// into the NodeStatus JSON keys to keep. Aliases are resolved and empty
+// entries skipped. Unknown names are kept and simply match nothing
+func parseFields(s string) map[string]bool {
+	fields := make(map[string]bool)
+	for _, f := range strings.Split(s, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		if key, ok := fieldAliases[f]; ok {
+			f = key
+		}
+		fields[f] = true
+	}
+	return fields
+}
+
+// projectReport marshals report with each node in the nodes and stale
+// listings cut down to the given fields. Report-level fields are kept
+func projectReport(report StatusReport, fields map[string]bool) ([]byte, error) {
+	type plain StatusReport
+	// Shallower fields take precedence over the embedded ones
+	return json.Marshal(struct {
+		plain
+		Timestamp formattedTime            `json:"timestamp"`
+		Nodes     map[string]projectedNode `json:"nodes"`
+		Stale     map[string]projectedNode `json:"stale,omitempty"`
+	}{
+		plain(report),
+		formattedTime{report.Timestamp, report.TimeFormat},
+		projectNodes(report.Nodes, fields),
+		projectNodes(report.Stale, fields),
+	})
+}
+
+// projectNodes wraps each node of a listing in a projectedNode
+func projectNodes(nodes map[string]NodeStatus, fields map[string]bool) map[string]projectedNode {
+	if nodes == nil {
+		return nil
+	}
+	projected := make(map[string]projectedNode, len(nodes))
+	for addr, node := range nodes {
+		projected[addr] = projectedNode{node, fields}
+	}
+	return projected
+}
+
+// nodeField is a JSON field of NodeStatus
+type nodeField struct {
+	key       string
+	index     int
+	omitEmpty bool
+}
+
+// nodeFields lists the JSON fields of NodeStatus in declaration order
+var nodeFields = func() []nodeField {
+	var fields []nodeField
+	t := reflect.TypeOf(NodeStatus{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		key, opts, _ := strings.Cut(tag, ",")
+		fields = append(fields, nodeField{key: key, index: i, omitEmpty: opts == "omitempty"})
+	}
+	return fields
+}()
+
+// projectedNode marshals only the given fields of a node, straight from
+// the struct. It follows NodeStatus.MarshalJSON for telemetry and last_seen
+type projectedNode struct {
+	node   NodeStatus
+	fields map[string]bool
+}
+
+func (p projectedNode) MarshalJSON() ([]byte, error) {
+	n := p.node
+	showTelemetry := n.fullTelemetry || n.HasTelemetry
+	v := reflect.ValueOf(n)
+	buf := []byte{'{'}
+	for _, f := range nodeFields {
+		if !p.fields[f.key] {
+			continue
+		}
+		field := v.Field(f.index)
+		value := field.Interface()
+		switch f.key {
+		case "last_seen":
+			value = formattedTime{n.LastSeen, n.timeFormat}
+		case "cpu_percent", "ram_percent", "disk_percent":
+			if !showTelemetry && f.omitEmpty && emptyJSON(field) {
+				continue
+			}
+		default:
+			if f.omitEmpty && emptyJSON(field) {
+				continue
+			}
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(strconv.AppendQuote(buf, f.key), ':')
+		buf = append(buf, data...)
+	}
+	return append(buf, '}'), nil
+}
+
+// emptyJSON reports whether encoding/json omits v from an omitempty field
+func emptyJSON(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Struct:
+		return false
+	}
+	return v.IsZero()
+}
@@ -0,0 +1,169 @@
+package display
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+func TestParseFields(t *testing.T) {
+	got := parseFields(" address, Status,cpu,,bogus")
+	want := map[string]bool{"address": true, "status": true, "cpu_percent": true, "bogus": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFields() = %v, want %v", got, want)
+	}
+}
+
+func TestProjectedNodeMatchesMarshal(t *testing.T) {
+	loss := 2.5
+	all := make(map[string]bool)
+	for _, f := range nodeFields {
+		all[f.key] = true
+	}
+	for _, node := range []NodeStatus{
+		{Address: "10.0.0.1:9999", Status: "OK", LastSeen: time.Unix(1700000000, 0), Age: "1s", RTT: "2ms", PacketLoss: &loss},
+		{Address: "10.0.0.2:9999", Status: "WARN", StatusCode: 1, HasTelemetry: true, CPUPercent: 0, RAMPercent: 40},
+		{Address: "10.0.0.3:9999", timeFormat: TimeUnix, fullTelemetry: true, BlockedBy: []DependencyStatus{}},
+	} {
+		want, err := json.Marshal(node)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		got, err := json.Marshal(projectedNode{node, all})
+		if err != nil {
+			t.Fatalf("Marshal(projectedNode) error = %v", err)
+		}
+		var wantMap, gotMap map[string]json.RawMessage
+		json.Unmarshal(want, &wantMap)
+		if err := json.Unmarshal(got, &gotMap); err != nil {
+			t.Fatalf("projectedNode marshalled invalid JSON %s: %v", got, err)
+		}
+		if !reflect.DeepEqual(gotMap, wantMap) {
+			t.Errorf("projectedNode with every field = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestStatusHandlerFields(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithReport("10.0.0.1:9999", [16]byte{1}, 1, 1, 55, 40, 30)
+	handler := NewReporter(monitor, false).StatusHandler()
+
+	get := func(query string) map[string]json.RawMessage {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, StatusPath+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d, want 200", query, rec.Code)
+		}
+		var report struct {
+			NodeCount int                                   `json:"node_count"`
+			Nodes     map[string]map[string]json.RawMessage `json:"nodes"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("GET %s: %v", query, err)
+		}
+		if report.NodeCount != 1 {
+			t.Errorf("GET %s node_count = %d, want 1", query, report.NodeCount)
+		}
+		return report.Nodes["10.0.0.1:9999"]
+	}
+	keys := func(node map[string]json.RawMessage) []string {
+		var keys []string
+		for k := range node {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	if full := get(""); len(full) <= 3 {
+		t.Errorf("unfiltered node has only %v", keys(full))
+	}
+	node := get("?fields=address,status,cpu,unknown")
+	if got, want := keys(node), []string{"address", "cpu_percent", "status"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("projected fields = %v, want %v", got, want)
+	}
+	if string(node["status"]) != `"WARN"` || string(node["cpu_percent"]) != "55" {
+		t.Errorf("projected node = %s %s, want WARN 55", node["status"], node["cpu_percent"])
+	}
+	if node := get("?fields="); len(node) != 0 {
+		t.Errorf("empty field list kept %v", keys(node))
+	}
+}
+
+func TestNodesHandler(t *testing.T) {
+	monitor := registry.NewMonitor()
+	for i := 0; i < 50; i++ {
+		monitor.UpdateWithStatus(fmt.Sprintf("10.0.0.%d:9999", i), uint8(i%3), 1)
+	}
+	handler := NewReporter(monitor, false).NodesHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, NodesPath+"?fields=address,status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d, want 200", NodesPath, rec.Code)
+	}
+
+	seen := make(map[string]string)
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var node map[string]string
+		if err := json.Unmarshal(scanner.Bytes(), &node); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		if len(node) != 2 {
+			t.Errorf("line %q, want only address and status", scanner.Text())
+		}
+		seen[node["address"]] = node["status"]
+	}
+	if len(seen) != 50 || seen["10.0.0.4:9999"] != "WARN" {
+		t.Errorf("streamed %d nodes (10.0.0.4: %s), want 50 (WARN)", len(seen), seen["10.0.0.4:9999"])
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, NodesPath, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST %s = %d, want 405", NodesPath, rec.Code)
+	}
+}
+
+func benchmarkHandler(b *testing.B, handler func(*Reporter) http.Handler, path string) {
+	monitor := registry.NewMonitor()
+	for i := 0; i < 50000; i++ {
+		monitor.UpdateWithStatus(fmt.Sprintf("10.%d.%d.%d:9999", i>>16, (i>>8)&0xff, i&0xff), 0, 1)
+	}
+	h := handler(NewReporter(monitor, false))
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.ServeHTTP(discardResponse{}, req)
+	}
+}
+
+func BenchmarkStatusHandler50k(b *testing.B) {
+	benchmarkHandler(b, (*Reporter).StatusHandler, StatusPath)
+}
+
+func BenchmarkNodesHandler50k(b *testing.B) {
+	benchmarkHandler(b, (*Reporter).NodesHandler, NodesPath)
+}
+
+// discardResponse is a ResponseWriter that throws the body away, so
+// benchmarks measure the handler rather than a growing recorder buffer
+type discardResponse struct{}
+
+func (discardResponse) Header() http.Header         { return http.Header{} }
+func (discardResponse) Write(p []byte) (int, error) { return io.Discard.Write(p) }
+func (discardResponse) WriteHeader(int)             {}
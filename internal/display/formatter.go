@@ -0,0 +1,434 @@
+package display
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// ReportFormatter renders a status report. The built-in human and JSON
+// outputs are formatters; others can be added with RegisterFormatter
+type ReportFormatter interface {
+	Format(report StatusReport, w io.Writer) error
+}
+
+var (
+	formattersMu sync.RWMutex
+	formatters   = map[string]ReportFormatter{
+		"human":        HumanFormatter{},
+		"json":         JSONFormatter{},
+		"json-compact": JSONFormatter{Compact: true},
+		"oneline":      OneLineFormatter{},
+	}
+)
+
+// RegisterFormatter makes a formatter available by name, e.g. for the
+// -output flag. It panics if the name is already registered
+func RegisterFormatter(name string, f ReportFormatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	if f == nil {
+		panic("display: RegisterFormatter formatter is nil")
+	}
+	if _, dup := formatters[name]; dup {
+		panic("display: RegisterFormatter called twice for " + name)
+	}
+	formatters[name] = f
+}
+
+// LookupFormatter returns the formatter registered under name
+func LookupFormatter(name string) (ReportFormatter, error) {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	f, ok := formatters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q: must be one of %s", name, strings.Join(formatterNames(), ", "))
+	}
+	return f, nil
+}
+
+// formatterNames returns the registered names, sorted
+// Caller must hold formattersMu
+func formatterNames() []string {
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HumanFormatter renders the plain-text report
+type HumanFormatter struct{}
+
+// Format writes the report as text, one line per node, sorted by address
+// within each group, followed by the stale section
+func (HumanFormatter) Format(report StatusReport, w io.Writer) error {
+	// The header has carried no time by default; show it once a format is chosen
+	if report.TimeFormat != TimeRFC3339 {
+		fmt.Fprintf(w, "\n=== PulseCheck Status (Nodes: %d) at %s ===\n", report.NodeCount, report.TimeFormat.Format(report.Timestamp))
+	} else {
+		fmt.Fprintf(w, "\n=== PulseCheck Status (Nodes: %d) ===\n", report.NodeCount)
+	}
+
+	for _, dup := range report.DuplicateIdentities {
+		fmt.Fprintf(w, "WARNING: duplicate node identity %s reported by %s\n",
+			dup.UUID, strings.Join(dup.Addresses, ", "))
+	}
+	if report.Settling {
+		fmt.Fprintln(w, "NOTE: cluster view still settling after startup; status is not alerted on yet")
+	}
+	if report.TooFewNodes {
+		fmt.Fprintf(w, "CRITICAL: only %d peers known, expected at least %d\n", report.KnownPeers, report.ExpectedMinNodes)
+	}
+	if report.Self != nil && !report.Self.Healthy {
+		fmt.Fprintf(w, "CRITICAL: this node's monitoring is broken (%s); the view below may be stale\n",
+			strings.Join(report.Self.Problems, "; "))
+	}
+	if report.Self != nil && len(report.Self.Warnings) > 0 {
+		fmt.Fprintf(w, "WARNING: this node hears nothing from its peers (%s); the view below may be stale\n",
+			strings.Join(report.Self.Warnings, "; "))
+	}
+	for _, cw := range report.ConfigWarnings {
+		fmt.Fprintf(w, "WARNING: %s heartbeats every %s, too slow for the %s timeout; expect it to flap offline\n",
+			cw.Address, cw.HeartbeatInterval, cw.Timeout)
+	}
+
+	if len(report.NoTelemetry) > 0 {
+		fmt.Fprintf(w, "Nodes without telemetry (%d): %s\n", len(report.NoTelemetry), strings.Join(report.NoTelemetry, ", "))
+	}
+	for _, o := range report.Offline {
+		at := o.Time.Format("15:04:05")
+		if report.TimeFormat != TimeRFC3339 {
+			at = report.TimeFormat.Format(o.Time)
+		}
+		fmt.Fprintf(w, "OFFLINE (reaped): %s went offline at %s, last status %s", o.Address, at, o.Status)
+		if len(o.BlockedBy) > 0 {
+			fmt.Fprintf(w, "; %s", dependsOn(o.BlockedBy))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if report.NodeCount == 0 {
+		_, err := fmt.Fprintln(w, "No active nodes")
+		return err
+	}
+
+	addrs := sortedAddrs(report.Nodes)
+	if len(report.Groups) == 0 {
+		for _, addr := range addrs {
+			writeNodeLine(w, report.Nodes[addr])
+		}
+	} else {
+		for _, name := range sortedGroupNames(report.Groups) {
+			g := report.Groups[name]
+			fmt.Fprintf(w, "\n--- %s: %s (Nodes: %d | OK: %d | WARN: %d | CRITICAL: %d",
+				report.GroupBy, name, g.NodeCount, g.OK, g.Warn, g.Critical)
+			if g.Maintenance > 0 {
+				fmt.Fprintf(w, " | MAINTENANCE: %d", g.Maintenance)
+			}
+			if g.Suppressed > 0 {
+				fmt.Fprintf(w, " | SUPPRESSED: %d", g.Suppressed)
+			}
+			fmt.Fprintln(w, ") ---")
+			for _, addr := range addrs {
+				if n := report.Nodes[addr]; n.Group == name {
+					writeNodeLine(w, n)
+				}
+			}
+		}
+	}
+
+	if len(report.Stale) > 0 {
+		fmt.Fprintf(w, "\n--- Stale (Nodes: %d | Age > %v) ---\n", len(report.Stale), report.MaxDisplayAge)
+		for _, addr := range oldestFirst(report.Stale) {
+			writeNodeLine(w, report.Stale[addr])
+		}
+	}
+	return nil
+}
+
+// dependsOn describes down dependencies, e.g. "depends on 10.0.0.1:9999
+// which is DOWN"
+func dependsOn(deps []DependencyStatus) string {
+	parts := make([]string, len(deps))
+	for i, d := range deps {
+		parts[i] = d.Address + " which is " + d.Status
+	}
+	return "depends on " + strings.Join(parts, ", ")
+}
+
+// writeNodeLine outputs a single human-readable node line
+func writeNodeLine(w io.Writer, n NodeStatus) {
+	fmt.Fprintf(w, "Node: %s | Status: %s", n.Address, n.Status)
+	if n.ComputedStatus != "" {
+		fmt.Fprintf(w, " (collector: %s)", n.ComputedStatus)
+	}
+	if n.Suppressed {
+		fmt.Fprintf(w, " (suppressed: %s)", dependsOn(n.BlockedBy))
+	} else if len(n.BlockedBy) > 0 {
+		fmt.Fprintf(w, " (%s)", dependsOn(n.BlockedBy))
+	}
+	fmt.Fprintf(w, " | Age: %s", n.Age)
+
+	if n.HasTelemetry {
+		fmt.Fprintf(w, " | CPU: %.1f%% RAM: %.1f%% Disk: %.1f%%",
+			n.CPUPercent, n.RAMPercent, n.DiskPercent)
+	} else {
+		// Status-only heartbeats carry no telemetry; zero would read as idle
+		fmt.Fprint(w, " | Telemetry: n/a")
+	}
+
+	if n.HasNetwork {
+		fmt.Fprintf(w, " | Net: rx %s tx %s", formatRate(n.NetRxBytesPerSec), formatRate(n.NetTxBytesPerSec))
+	}
+
+	if n.RTT != "" {
+		fmt.Fprintf(w, " | RTT: %s", n.RTT)
+	}
+
+	if n.Phi > 0 {
+		fmt.Fprintf(w, " | Phi: %.2f", n.Phi)
+	}
+
+	if n.PacketLoss != nil {
+		fmt.Fprintf(w, " | Loss: %.1f%%", *n.PacketLoss)
+	}
+
+	if n.Probed {
+		fmt.Fprint(w, " | Probe")
+	}
+
+	if n.Priority > 0 {
+		fmt.Fprintf(w, " | Priority: %d", n.Priority)
+	}
+
+	if n.FederatedFrom != "" {
+		fmt.Fprintf(w, " | Via: %s", n.FederatedFrom)
+	}
+
+	if n.reapSoon {
+		fmt.Fprintf(w, " | Reaped in: %s", n.ReapIn)
+	}
+
+	if n.SeenByCount != nil {
+		fmt.Fprintf(w, " | Seen by: %d/%d", *n.SeenByCount, n.SeenByOf)
+		if *n.SeenByCount < n.SeenByOf {
+			io.WriteString(w, " (partial)")
+		}
+	}
+
+	fmt.Fprintln(w)
+}
+
+// formatRate renders a throughput in bytes per second with a binary unit
+func formatRate(bytesPerSec float64) string {
+	units := []string{"B/s", "KiB/s", "MiB/s", "GiB/s"}
+	i := 0
+	for bytesPerSec >= 1024 && i < len(units)-1 {
+		bytesPerSec /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", bytesPerSec, units[i])
+}
+
+// sortedAddrs returns the node addresses in order
+func sortedAddrs(nodes map[string]NodeStatus) []string {
+	addrs := make([]string, 0, len(nodes))
+	for addr := range nodes {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// oldestFirst returns the node addresses by last seen time, oldest first
+func oldestFirst(nodes map[string]NodeStatus) []string {
+	addrs := sortedAddrs(nodes)
+	sort.SliceStable(addrs, func(i, j int) bool {
+		return nodes[addrs[i]].LastSeen.Before(nodes[addrs[j]].LastSeen)
+	})
+	return addrs
+}
+
+// sortedGroupNames orders groups by name with the default bucket last, as groupNodes does
+func sortedGroupNames(groups map[string]GroupStatus) []string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == defaultGroup) != (names[j] == defaultGroup) {
+			return names[j] == defaultGroup
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// JSONFormatter renders the report as a JSON document
+type JSONFormatter struct {
+	Compact bool // Single-line output instead of indented
+}
+
+// Format writes the report as JSON
+func (f JSONFormatter) Format(report StatusReport, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	if !f.Compact {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(report)
+}
+
+// OneLineFormatter renders the whole report as a single glanceable line,
+// e.g. for a status bar or a cron mail:
+//
+//	15 nodes: 12 OK, 2 WARN, 1 CRITICAL (hottest: 10.0.0.3:9999 94% cpu)
+//
+// Stale nodes are counted. The hottest node is the one with the highest
+// CPU, RAM or disk usage among those reporting telemetry
+type OneLineFormatter struct{}
+
+// Format writes the report as one line
+func (OneLineFormatter) Format(report StatusReport, w io.Writer) error {
+	counts := make(map[string]int)
+	var hottest struct {
+		addr, metric string
+		percent      float64
+	}
+	for _, nodes := range []map[string]NodeStatus{report.Nodes, report.Stale} {
+		for _, addr := range sortedAddrs(nodes) {
+			n := nodes[addr]
+			if n.Suppressed {
+				counts["SUPPRESSED"]++
+			} else {
+				counts[n.Status]++
+			}
+			if !n.HasTelemetry {
+				continue
+			}
+			for _, m := range []struct {
+				name    string
+				percent float64
+			}{{"cpu", n.CPUPercent}, {"ram", n.RAMPercent}, {"disk", n.DiskPercent}} {
+				if hottest.addr == "" || m.percent > hottest.percent {
+					hottest.addr, hottest.metric, hottest.percent = addr, m.name, m.percent
+				}
+			}
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d nodes: %d OK, %d WARN, %d CRITICAL", report.NodeCount, counts["OK"], counts["WARN"], counts["CRITICAL"])
+	for _, status := range []string{"UNKNOWN", "MAINTENANCE", "SUPPRESSED"} {
+		if counts[status] > 0 {
+			fmt.Fprintf(&sb, ", %d %s", counts[status], status)
+		}
+	}
+	if hottest.addr != "" {
+		fmt.Fprintf(&sb, " (hottest: %s %.0f%% %s)", hottest.addr, hottest.percent, hottest.metric)
+	}
+	if report.Settling {
+		sb.WriteString(" [settling]")
+	}
+	if report.TooFewNodes {
+		fmt.Fprintf(&sb, " [CRITICAL: expected at least %d peers]", report.ExpectedMinNodes)
+	}
+	if report.Self != nil && !report.Self.Healthy {
+		sb.WriteString(" [CRITICAL: self-check failed]")
+	} else if report.Self != nil && len(report.Self.Warnings) > 0 {
+		sb.WriteString(" [WARNING: no peer traffic]")
+	}
+	sb.WriteByte('\n')
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// TemplateFormatter renders the report with a text/template, which is
+// executed with the StatusReport as its data
+type TemplateFormatter struct {
+	tmpl *template.Template
+}
+
+// templateFuncs are the helpers available to report templates
+var templateFuncs = template.FuncMap{
+	"color":     colorStatus,
+	"colorName": statusColorName,
+	"duration":  formatDuration,
+	"join":      strings.Join,
+}
+
+// NewTemplateFormatter parses a report template, so that a bad template is
+// reported at startup rather than at the first report. name identifies the
+// template in error messages
+func NewTemplateFormatter(name, text string) (*TemplateFormatter, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	// Fields are only resolved on execution, so render a sample report to
+	// catch misspelled field names too
+	if err := tmpl.Execute(io.Discard, sampleReport()); err != nil {
+		return nil, err
+	}
+	return &TemplateFormatter{tmpl: tmpl}, nil
+}
+
+// sampleReport fills every section of a report for validating templates
+func sampleReport() StatusReport {
+	node := NodeStatus{Address: "192.0.2.1:9999", Status: "OK", Age: "0s", Group: defaultGroup}
+	return StatusReport{
+		NodeCount:           2,
+		Nodes:               map[string]NodeStatus{node.Address: node},
+		Groups:              map[string]GroupStatus{defaultGroup: {Status: "OK", NodeCount: 1, OK: 1}},
+		Stale:               map[string]NodeStatus{node.Address: node},
+		DuplicateIdentities: []DuplicateStatus{{UUID: "00000000000000000000000000000000", Addresses: []string{node.Address}}},
+		ConfigWarnings:      []ConfigWarning{{Address: node.Address, HeartbeatInterval: "10s", Timeout: "15s"}},
+		NoTelemetry:         []string{node.Address},
+	}
+}
+
+// Format executes the template
+func (f *TemplateFormatter) Format(report StatusReport, w io.Writer) error {
+	return f.tmpl.Execute(w, report)
+}
+
+// statusColorName returns a color name for a status label, for templates
+// producing HTML or chat messages
+func statusColorName(status string) string {
+	switch status {
+	case "OK":
+		return "green"
+	case "WARN":
+		return "yellow"
+	case "MAINTENANCE":
+		return "blue"
+	default:
+		return "red"
+	}
+}
+
+// colorStatus wraps a status label in the ANSI color the dashboard uses for it
+func colorStatus(status string) string {
+	color := map[string]string{
+		"green":  ansiGreen,
+		"yellow": ansiYellow,
+		"blue":   ansiBlue,
+		"red":    ansiRed,
+	}[statusColorName(status)]
+	return color + status + ansiReset
+}
+
+// formatDuration rounds a duration for display: to the millisecond below a
+// second, otherwise to the second
+func formatDuration(d time.Duration) string {
+	if d < time.Second && d > -time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,211 @@
+package display
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+// countFormatter writes only the node count
+type countFormatter struct{}
+
+func (countFormatter) Format(report StatusReport, w io.Writer) error {
+	_, err := io.WriteString(w, strings.Repeat("*", report.NodeCount))
+	return err
+}
+
+func TestLookupFormatter(t *testing.T) {
+	for _, name := range []string{"human", "json", "json-compact", "oneline"} {
+		if _, err := LookupFormatter(name); err != nil {
+			t.Errorf("LookupFormatter(%q) error = %v", name, err)
+		}
+	}
+	if _, err := LookupFormatter("bogus"); err == nil {
+		t.Error("LookupFormatter() should return error for an unknown name")
+	}
+
+	RegisterFormatter("test-count", countFormatter{})
+	t.Cleanup(func() {
+		formattersMu.Lock()
+		defer formattersMu.Unlock()
+		delete(formatters, "test-count")
+	})
+	if f, err := LookupFormatter("test-count"); err != nil || f != (countFormatter{}) {
+		t.Errorf("LookupFormatter(test-count) = %v, %v", f, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterFormatter() should panic for a duplicate name")
+		}
+	}()
+	RegisterFormatter("json", countFormatter{})
+}
+
+func TestReporterSetFormatter(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	monitor.UpdateWithStatus("10.0.0.2:9999", 0, 0)
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, true)
+	reporter.SetFormatter(countFormatter{})
+	reporter.output = &buf
+	reporter.Report()
+
+	if buf.String() != "**" {
+		t.Errorf("Report() with custom formatter = %q, want %q", buf.String(), "**")
+	}
+}
+
+func TestTemplateFormatter(t *testing.T) {
+	if _, err := NewTemplateFormatter("test", "{{.Nodes"); err == nil {
+		t.Error("NewTemplateFormatter() should return error for a bad template")
+	}
+
+	f, err := NewTemplateFormatter("test", "{{.NodeCount}} nodes{{range .Nodes}} {{.Address}}={{.Status}}{{end}}")
+	if err != nil {
+		t.Fatalf("NewTemplateFormatter() error = %v", err)
+	}
+
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("10.0.0.1:9999", 2, 0)
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.SetFormatter(f)
+	reporter.output = &buf
+	reporter.Report()
+
+	if want := "1 nodes 10.0.0.1:9999=CRITICAL"; buf.String() != want {
+		t.Errorf("Report() with template = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	// Misspelled fields fail at startup, not at the first report
+	if _, err := NewTemplateFormatter("test", `{{range .Nodes}}{{.Adress}}{{end}}`); err == nil {
+		t.Error("NewTemplateFormatter() should return error for an unknown field")
+	}
+
+	f, err := NewTemplateFormatter("test",
+		`{{range .Nodes}}{{colorName .Status}} {{color .Status}} {{duration ($.Timestamp.Sub .LastSeen)}}{{end}}`)
+	if err != nil {
+		t.Fatalf("NewTemplateFormatter() error = %v", err)
+	}
+
+	fake := clock.NewFake(time.Now())
+	monitor := registry.NewMonitor()
+	monitor.SetClock(fake)
+	monitor.UpdateWithStatus("10.0.0.1:9999", 1, 0)
+	fake.Advance(250 * time.Millisecond)
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.SetClock(fake)
+	reporter.SetFormatter(f)
+	reporter.output = &buf
+	reporter.Report()
+
+	if want := "yellow " + ansiYellow + "WARN" + ansiReset + " 250ms"; buf.String() != want {
+		t.Errorf("Report() with template funcs = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	testCases := []struct {
+		d    time.Duration
+		want string
+	}{
+		{1500 * time.Microsecond, "2ms"},
+		{1400 * time.Millisecond, "1s"},
+		{90 * time.Second, "1m30s"},
+	}
+	for _, tc := range testCases {
+		if got := formatDuration(tc.d); got != tc.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tc.d, got, tc.want)
+		}
+	}
+}
+
+func TestFormatRate(t *testing.T) {
+	testCases := []struct {
+		rate float64
+		want string
+	}{
+		{0, "0.0 B/s"},
+		{512, "512.0 B/s"},
+		{1536, "1.5 KiB/s"},
+		{3 << 30, "3.0 GiB/s"},
+		{2 << 40, "2048.0 GiB/s"},
+	}
+	for _, tc := range testCases {
+		if got := formatRate(tc.rate); got != tc.want {
+			t.Errorf("formatRate(%v) = %q, want %q", tc.rate, got, tc.want)
+		}
+	}
+}
+
+func TestHumanFormatterSortsNodes(t *testing.T) {
+	monitor := registry.NewMonitor()
+	for _, addr := range []string{"10.0.0.3:9999", "10.0.0.1:9999", "10.0.0.2:9999"} {
+		monitor.UpdateWithStatus(addr, 0, 0)
+	}
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.output = &buf
+	reporter.Report()
+
+	output := buf.String()
+	first, second, third := strings.Index(output, "10.0.0.1"), strings.Index(output, "10.0.0.2"), strings.Index(output, "10.0.0.3")
+	if first < 0 || first > second || second > third {
+		t.Errorf("Human output should list nodes by address:\n%s", output)
+	}
+}
+
+func TestOneLineFormatter(t *testing.T) {
+	report := StatusReport{
+		NodeCount: 5,
+		Nodes: map[string]NodeStatus{
+			"10.0.0.1:9999": {Status: "OK", HasTelemetry: true, CPUPercent: 20, RAMPercent: 40, DiskPercent: 50},
+			"10.0.0.2:9999": {Status: "WARN", HasTelemetry: true, CPUPercent: 75, RAMPercent: 30, DiskPercent: 10},
+			"10.0.0.3:9999": {Status: "CRITICAL", HasTelemetry: true, CPUPercent: 93.6, RAMPercent: 50, DiskPercent: 60},
+			"10.0.0.4:9999": {Status: "MAINTENANCE"},
+		},
+		Stale: map[string]NodeStatus{
+			"10.0.0.5:9999": {Status: "OK"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := (OneLineFormatter{}).Format(report, &buf); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	want := "5 nodes: 2 OK, 1 WARN, 1 CRITICAL, 1 MAINTENANCE (hottest: 10.0.0.3:9999 94% cpu)\n"
+	if buf.String() != want {
+		t.Errorf("Format() = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	(OneLineFormatter{}).Format(StatusReport{Settling: true}, &buf)
+	if want := "0 nodes: 0 OK, 0 WARN, 0 CRITICAL [settling]\n"; buf.String() != want {
+		t.Errorf("Format(empty) = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	(OneLineFormatter{}).Format(StatusReport{ExpectedMinNodes: 3, TooFewNodes: true}, &buf)
+	if want := "0 nodes: 0 OK, 0 WARN, 0 CRITICAL [CRITICAL: expected at least 3 peers]\n"; buf.String() != want {
+		t.Errorf("Format(too few) = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	(OneLineFormatter{}).Format(StatusReport{Self: &registry.TrafficHealth{Problems: []string{"UDP listener is down"}}}, &buf)
+	if want := "0 nodes: 0 OK, 0 WARN, 0 CRITICAL [CRITICAL: self-check failed]\n"; buf.String() != want {
+		t.Errorf("Format(self-check failed) = %q, want %q", buf.String(), want)
+	}
+}
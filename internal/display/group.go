@@ -0,0 +1,87 @@
+package display
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+// GroupBy selects how nodes are partitioned in reports
+type GroupBy string
+
+const (
+	GroupNone   GroupBy = ""       // A single flat list
+	GroupSubnet GroupBy = "subnet" // IPv4 /24 or IPv6 /64 of the node address
+)
+
+// defaultGroup holds nodes that cannot be placed in any group
+const defaultGroup = "ungrouped"
+
+// ParseGroupBy parses the -group-by flag value
+func ParseGroupBy(s string) (GroupBy, error) {
+	switch GroupBy(s) {
+	case GroupNone, GroupSubnet:
+		return GroupBy(s), nil
+	default:
+		return GroupNone, fmt.Errorf("invalid group-by %q: must be %s", s, GroupSubnet)
+	}
+}
+
+// nodeGroup is one partition of the report with its status rollup
+type nodeGroup struct {
+	Name    string
+	Summary registry.Summary
+	Addrs   []string // Sorted node addresses
+}
+
+// groupKey returns the group a node address belongs to
+func groupKey(by GroupBy, addr string) string {
+	switch by {
+	case GroupSubnet:
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return defaultGroup
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+		}
+		return (&net.IPNet{IP: ip.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+	default:
+		return defaultGroup
+	}
+}
+
+// groupNodes partitions nodes into groups sorted by name, with the default
+// bucket last
+func groupNodes(by GroupBy, nodes map[string]registry.NodeInfo) []nodeGroup {
+	index := make(map[string]*nodeGroup)
+	for addr, info := range nodes {
+		key := groupKey(by, addr)
+		g, ok := index[key]
+		if !ok {
+			g = &nodeGroup{Name: key}
+			index[key] = g
+		}
+		g.Summary.Add(info)
+		g.Addrs = append(g.Addrs, addr)
+	}
+
+	groups := make([]nodeGroup, 0, len(index))
+	for _, g := range index {
+		sort.Strings(g.Addrs)
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if (groups[i].Name == defaultGroup) != (groups[j].Name == defaultGroup) {
+			return groups[j].Name == defaultGroup
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
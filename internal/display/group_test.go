@@ -0,0 +1,67 @@
+package display
+
+import (
+	"testing"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+func TestParseGroupBy(t *testing.T) {
+	for _, s := range []string{"", "subnet"} {
+		if _, err := ParseGroupBy(s); err != nil {
+			t.Errorf("ParseGroupBy(%q) error = %v", s, err)
+		}
+	}
+	if _, err := ParseGroupBy("rack"); err == nil {
+		t.Error("ParseGroupBy() should reject unknown modes")
+	}
+}
+
+func TestGroupKeySubnet(t *testing.T) {
+	testCases := []struct {
+		addr string
+		want string
+	}{
+		{"192.168.1.100:9999", "192.168.1.0/24"},
+		{"192.168.1.7:9999", "192.168.1.0/24"},
+		{"10.0.2.1:9999", "10.0.2.0/24"},
+		{"[2001:db8::10]:9999", "2001:db8::/64"},
+		{"db-proxy:5432", defaultGroup},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.addr, func(t *testing.T) {
+			if got := groupKey(GroupSubnet, tc.addr); got != tc.want {
+				t.Errorf("groupKey(%s) = %s, want %s", tc.addr, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGroupNodes(t *testing.T) {
+	nodes := map[string]registry.NodeInfo{
+		"192.168.1.2:9999": {StatusCode: 0},
+		"192.168.1.1:9999": {StatusCode: 2},
+		"10.0.0.1:9999":    {StatusCode: 1},
+		"db-proxy:5432":    {StatusCode: 0},
+	}
+
+	groups := groupNodes(GroupSubnet, nodes)
+	if len(groups) != 3 {
+		t.Fatalf("groupNodes() returned %d groups, want 3", len(groups))
+	}
+
+	// Sorted by name with the default bucket last
+	names := []string{groups[0].Name, groups[1].Name, groups[2].Name}
+	if names[0] != "10.0.0.0/24" || names[1] != "192.168.1.0/24" || names[2] != defaultGroup {
+		t.Errorf("groupNodes() order = %v", names)
+	}
+
+	g := groups[1]
+	if g.Summary.Total != 2 || g.Summary.Critical != 1 || g.Summary.WorstStatus() != 2 {
+		t.Errorf("192.168.1.0/24 summary = %+v", g.Summary)
+	}
+	if g.Addrs[0] != "192.168.1.1:9999" || g.Addrs[1] != "192.168.1.2:9999" {
+		t.Errorf("192.168.1.0/24 addrs not sorted: %v", g.Addrs)
+	}
+}
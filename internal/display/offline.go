@@ -0,0 +1,135 @@
+package display
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+// OfflineNode is a node the reaper removed since the previous report
+type OfflineNode struct {
+	Address string    `json:"address"`
+	Time    time.Time `json:"time"`   // When it was reaped
+	Status  string    `json:"status"` // Last status before it went silent
+
+	// Dependencies that are down, likely why the node went silent
+	BlockedBy []DependencyStatus `json:"blocked_by,omitempty"`
+
+	timeFormat TimeFormat
+}
+
+// MarshalJSON writes the time in the report's time format
+func (o OfflineNode) MarshalJSON() ([]byte, error) {
+	type plain OfflineNode
+	if o.timeFormat == TimeRFC3339 {
+		return json.Marshal(plain(o))
+	}
+	return json.Marshal(struct {
+		plain
+		Time formattedTime `json:"time"`
+	}{plain(o), formattedTime{o.Time, o.timeFormat}})
+}
+
+// SetReapTimeout shows how long each node has left before the reaper
+// removes it for silence, as reap_in in JSON and, once less than half the
+// timeout is left, in the human report. Only meaningful for the timeout
+// failure detector; 0 disables. Must be called before Start
+func (r *Reporter) SetReapTimeout(timeout time.Duration) {
+	r.reapTimeout = timeout
+}
+
+// reapIn is the time a node last seen at lastSeen has left before it is
+// reaped, never negative
+func reapIn(timeout time.Duration, lastSeen, now time.Time) time.Duration {
+	if left := timeout - now.Sub(lastSeen); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// recordOffline remembers reaped nodes for the next report. A node that
+// rejoins is forgotten, so one that flaps out, in and out again between
+// reports is listed once, at its latest departure
+func (r *Reporter) recordOffline(e registry.Event) {
+	r.offlineMu.Lock()
+	defer r.offlineMu.Unlock()
+	switch e.Type {
+	case registry.EventJoined:
+		delete(r.offline, e.Address)
+	case registry.EventLeft:
+		if r.offline == nil {
+			r.offline = make(map[string]OfflineNode)
+		}
+		r.offline[e.Address] = OfflineNode{Address: e.Address, Time: e.Time, Status: statusCodeToString(e.StatusCode)}
+	}
+}
+
+// listedOfflineTTL is how long a node listed offline is kept from being
+// listed again unless a report shows it back, so one that never returns is
+// eventually forgotten
+const listedOfflineTTL = time.Hour
+
+// takeOffline returns the nodes reaped since the previous call, oldest
+// first, and forgets them so each is reported once. A node already listed
+// is only listed again once report, or one before it, showed it back: one
+// that rejoins and leaves again between reports is not repeated
+func (r *Reporter) takeOffline(report StatusReport, format TimeFormat) []OfflineNode {
+	r.offlineMu.Lock()
+	defer r.offlineMu.Unlock()
+	for addr := range r.listed {
+		_, live := report.Nodes[addr]
+		_, stale := report.Stale[addr]
+		if live || stale {
+			delete(r.listed, addr)
+		}
+	}
+	r.pruneListed(report.Timestamp)
+
+	nodes := make([]OfflineNode, 0, len(r.offline))
+	for addr, o := range r.offline {
+		if _, ok := r.listed[addr]; ok {
+			continue
+		}
+		if r.listed == nil {
+			r.listed = make(map[string]time.Time)
+		}
+		r.listed[addr] = report.Timestamp
+		o.timeFormat = format
+		nodes = append(nodes, o)
+	}
+	r.offline = nil
+	sort.Slice(nodes, func(i, j int) bool {
+		if !nodes[i].Time.Equal(nodes[j].Time) {
+			return nodes[i].Time.Before(nodes[j].Time)
+		}
+		return nodes[i].Address < nodes[j].Address
+	})
+	return nodes
+}
+
+// pruneOffline forgets the nodes reaped before cutoff that are still
+// waiting for a report, and listed nodes past listedOfflineTTL. It runs on
+// ticks that skip the report, while paused or on standby, so neither set
+// grows without bound and the next report lists only recent departures
+func (r *Reporter) pruneOffline(cutoff time.Time) {
+	r.offlineMu.Lock()
+	defer r.offlineMu.Unlock()
+	for addr, o := range r.offline {
+		if o.Time.Before(cutoff) {
+			delete(r.offline, addr)
+		}
+	}
+	r.pruneListed(r.clock.Now())
+}
+
+// pruneListed forgets nodes listed offline more than listedOfflineTTL
+// before now. offlineMu must be held
+func (r *Reporter) pruneListed(now time.Time) {
+	for addr, at := range r.listed {
+		if now.Sub(at) > listedOfflineTTL {
+			delete(r.listed, addr)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package display
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+func TestReporterOffline(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.output = &buf
+
+	at := time.Date(2024, 5, 1, 14, 30, 5, 0, time.UTC)
+	reporter.RecordEvent(registry.Event{Time: at, Type: registry.EventLeft, Address: "10.0.0.2:9999", StatusCode: 1})
+	// A node that flaps back in and out before the report is listed once
+	reporter.RecordEvent(registry.Event{Time: at, Type: registry.EventLeft, Address: "10.0.0.3:9999"})
+	reporter.RecordEvent(registry.Event{Time: at.Add(time.Second), Type: registry.EventJoined, Address: "10.0.0.3:9999"})
+	reporter.RecordEvent(registry.Event{Time: at.Add(2 * time.Second), Type: registry.EventLeft, Address: "10.0.0.3:9999", StatusCode: 2})
+	// One that comes back for good is not listed
+	reporter.RecordEvent(registry.Event{Time: at, Type: registry.EventLeft, Address: "10.0.0.4:9999"})
+	reporter.RecordEvent(registry.Event{Time: at.Add(time.Second), Type: registry.EventJoined, Address: "10.0.0.4:9999"})
+
+	reporter.Report()
+	want := "OFFLINE (reaped): 10.0.0.2:9999 went offline at 14:30:05, last status WARN\n" +
+		"OFFLINE (reaped): 10.0.0.3:9999 went offline at 14:30:07, last status CRITICAL\n"
+	if !strings.Contains(buf.String(), want) || strings.Count(buf.String(), "OFFLINE") != 2 {
+		t.Errorf("report = %q, want offline lines %q", buf.String(), want)
+	}
+
+	// Each departure is reported once
+	buf.Reset()
+	reporter.Report()
+	if strings.Contains(buf.String(), "OFFLINE") {
+		t.Errorf("second report repeats offline nodes: %q", buf.String())
+	}
+}
+
+func TestReporterOfflineNotRelisted(t *testing.T) {
+	monitor := registry.NewMonitor()
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.output = &buf
+	at := time.Date(2024, 5, 1, 14, 30, 5, 0, time.UTC)
+	addr := "10.0.0.2:9999"
+
+	reporter.RecordEvent(registry.Event{Time: at, Type: registry.EventLeft, Address: addr})
+	reporter.Report()
+	if strings.Count(buf.String(), "OFFLINE") != 1 {
+		t.Fatalf("first report = %q, want %s offline", buf.String(), addr)
+	}
+
+	// Back and gone again before the next report: it was never shown back
+	reporter.RecordEvent(registry.Event{Time: at.Add(time.Second), Type: registry.EventJoined, Address: addr})
+	reporter.RecordEvent(registry.Event{Time: at.Add(2 * time.Second), Type: registry.EventLeft, Address: addr})
+	buf.Reset()
+	reporter.Report()
+	if strings.Contains(buf.String(), "OFFLINE") {
+		t.Errorf("second report re-lists %s: %q", addr, buf.String())
+	}
+
+	// Once a report shows it back, its next departure is listed
+	monitor.UpdateWithStatus(addr, 0, 0)
+	reporter.RecordEvent(registry.Event{Time: at.Add(3 * time.Second), Type: registry.EventJoined, Address: addr})
+	reporter.Report()
+	reporter.RecordEvent(registry.Event{Time: at.Add(4 * time.Second), Type: registry.EventLeft, Address: addr})
+	buf.Reset()
+	reporter.Report()
+	if strings.Count(buf.String(), "OFFLINE") != 1 {
+		t.Errorf("report after it was seen back = %q, want %s offline", buf.String(), addr)
+	}
+}
+
+func TestReporterPruneOffline(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC))
+	reporter := NewReporter(registry.NewMonitor(), false)
+	reporter.SetClock(fake)
+
+	// While paused, departures older than the cutoff are dropped
+	reporter.RecordEvent(registry.Event{Time: fake.Now(), Type: registry.EventLeft, Address: "10.0.0.1:9999"})
+	fake.Advance(time.Minute)
+	reporter.RecordEvent(registry.Event{Time: fake.Now(), Type: registry.EventLeft, Address: "10.0.0.2:9999"})
+	reporter.pruneOffline(fake.Now().Add(-30 * time.Second))
+	if _, ok := reporter.offline["10.0.0.1:9999"]; ok || len(reporter.offline) != 1 {
+		t.Errorf("offline after pruning = %v, want 10.0.0.2:9999 only", reporter.offline)
+	}
+
+	// Listed nodes are forgotten after listedOfflineTTL
+	reporter.output = &bytes.Buffer{}
+	reporter.Report()
+	if len(reporter.listed) != 1 {
+		t.Fatalf("listed = %v, want the reported node", reporter.listed)
+	}
+	fake.Advance(listedOfflineTTL + time.Second)
+	reporter.pruneOffline(fake.Now())
+	if len(reporter.listed) != 0 {
+		t.Errorf("listed after the TTL = %v, want none", reporter.listed)
+	}
+}
+
+func TestReporterOfflineJSON(t *testing.T) {
+	monitor := registry.NewMonitor()
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, true)
+	reporter.output = &buf
+	reporter.SetTimeFormat(TimeUnix)
+
+	reporter.RecordEvent(registry.Event{Time: time.Unix(1714573805, 0), Type: registry.EventLeft, Address: "10.0.0.2:9999"})
+	reporter.Report()
+	var report struct {
+		Offline []map[string]interface{} `json:"offline"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Offline) != 1 || report.Offline[0]["address"] != "10.0.0.2:9999" ||
+		report.Offline[0]["time"] != float64(1714573805) || report.Offline[0]["status"] != "OK" {
+		t.Errorf("offline = %v", report.Offline)
+	}
+}
+
+func TestReporterReapIn(t *testing.T) {
+	monitor := registry.NewMonitor()
+	fake := clock.NewFake(time.Now())
+	monitor.SetClock(fake)
+	monitor.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	fake.Advance(4 * time.Second)
+	monitor.UpdateWithStatus("10.0.0.2:9999", 0, 0)
+	fake.Advance(3 * time.Second)
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.output = &buf
+	reporter.SetClock(fake)
+	reporter.SetReapTimeout(10 * time.Second)
+
+	report := reporter.buildReportAs(TimeRFC3339)
+	if got := report.Nodes["10.0.0.1:9999"].ReapIn; got != "3s" {
+		t.Errorf("reap_in = %q, want 3s", got)
+	}
+	if got := report.Nodes["10.0.0.2:9999"].ReapIn; got != "7s" {
+		t.Errorf("reap_in = %q, want 7s", got)
+	}
+
+	// Only nodes with less than half the timeout left show it
+	reporter.Report()
+	for _, line := range strings.Split(buf.String(), "\n") {
+		soon := strings.HasPrefix(line, "Node: 10.0.0.1:9999")
+		if strings.HasPrefix(line, "Node: ") && strings.Contains(line, "Reaped in: 3s") != soon {
+			t.Errorf("node line %q", line)
+		}
+	}
+}
+
+func TestDashboardTrackOffline(t *testing.T) {
+	d, _ := newTestDashboard(registry.NewMonitor())
+	now := time.Now()
+	a := registry.NodeInfo{StatusCode: 1}
+	d.trackOffline(map[string]registry.NodeInfo{"10.0.0.1:9999": a, "10.0.0.2:9999": a}, now)
+
+	offline := d.trackOffline(map[string]registry.NodeInfo{"10.0.0.2:9999": a}, now.Add(time.Second))
+	if len(offline) != 1 || offline[0].Address != "10.0.0.1:9999" || offline[0].Status != "WARN" {
+		t.Fatalf("offline = %+v, want 10.0.0.1:9999 last WARN", offline)
+	}
+	if offline := d.trackOffline(map[string]registry.NodeInfo{"10.0.0.2:9999": a}, now.Add(30*time.Second)); len(offline) != 1 {
+		t.Errorf("offline notice gone after 30s: %+v", offline)
+	}
+	if offline := d.trackOffline(map[string]registry.NodeInfo{"10.0.0.2:9999": a}, now.Add(time.Second+offlineNoticeTTL)); len(offline) != 0 {
+		t.Errorf("offline notice kept past its TTL: %+v", offline)
+	}
+
+	// A node that comes back is no longer listed
+	d.trackOffline(map[string]registry.NodeInfo{}, now.Add(2*time.Minute))
+	if offline := d.trackOffline(map[string]registry.NodeInfo{"10.0.0.2:9999": a}, now.Add(2*time.Minute+time.Second)); len(offline) != 0 {
+		t.Errorf("returned node still listed offline: %+v", offline)
+	}
+}
@@ -1,41 +1,276 @@
 package display
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"net/http"
 	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
 	"github.com/rafaelmarinho/pulsecheck/internal/registry"
 )
 
+// StatusPath is the HTTP endpoint serving the current report as JSON
+const StatusPath = "/status"
+
+// NodesPath is the HTTP endpoint streaming every node as JSON lines
+const NodesPath = "/status/nodes"
+
 // Reporter handles status reporting in various formats
 type Reporter struct {
-	monitor   *registry.Monitor
-	jsonMode  bool
-	output    io.Writer
-	stopChan  chan struct{}
+	monitor  *registry.Monitor
+	jsonMode bool
+	jsonOpts JSONOptions
+	groupBy  GroupBy
+	active   func() bool   // Periodic reports are skipped while this returns false
+	maxAge   time.Duration // Nodes older than this are listed as stale (0 disables)
+	clock    clock.Clock
+	output   io.Writer
+	stopChan chan struct{}
+	stopOnce sync.Once
+
+	formatter ReportFormatter // Overrides the human or JSON mode when set
+
+	timeFormat TimeFormat // Format of report timestamps
+
+	// Recomputes a node's status from its telemetry; false skips the node
+	statusCheck func(registry.NodeInfo) (uint8, bool)
+
+	// Peers advertising a heartbeat interval above half of timeout are
+	// reported as config warnings (0 disables)
+	timeout time.Duration
+
+	// Lists nodes that have never sent telemetry
+	listNoTelemetry bool
+
+	// Reports before this time are marked as settling
+	settleUntil time.Time
+
+	// Reports knowing fewer peers than this are marked CRITICAL (0 disables)
+	expectMinNodes int
+	selfAddr       string // Not counted towards expectMinNodes
+
+	// Replaces addresses in printed reports when set
+	anonymizer *Anonymizer
+
+	// Assesses this node's own monitoring traffic (nil disables)
+	selfCheck func() registry.TrafficHealth
+
+	// Periodic reports are skipped while paused; resumed asks Start for an
+	// immediate report
+	paused  atomic.Bool
+	resumed chan struct{}
+
+	// Status changes and departures signal changed for an immediate
+	// report, at most once per changeFloor (0 disables)
+	changeFloor time.Duration
+	changed     chan struct{}
+
+	// Signs each report, see SetSigningKey (nil disables). signMu keeps
+	// signed reports in sequence order in the output
+	signingKey ed25519.PrivateKey
+	signMu     sync.Mutex
+	signSeq    uint64
+
+	// Writes reports as they are rendered rather than in one piece
+	unbuffered bool
+
+	// Reaper timeout for the reap_in countdown (0 disables)
+	reapTimeout time.Duration
+
+	// Nodes reaped since the previous report, and those already listed
+	// offline and not seen in a report since, with when they were listed
+	offlineMu sync.Mutex
+	offline   map[string]OfflineNode
+	listed    map[string]time.Time
 }
 
-// StatusReport represents the JSON output structure
+// JSONOptions controls the shape of JSON reports
+type JSONOptions struct {
+	Compact       bool // Single-line output instead of indented
+	FullTelemetry bool // Always include telemetry fields, even when zero
+}
+
+// StatusReport is the data handed to report formatters, and the JSON output structure
 type StatusReport struct {
 	Timestamp time.Time              `json:"timestamp"`
 	NodeCount int                    `json:"node_count"`
 	Nodes     map[string]NodeStatus  `json:"nodes"`
+	Groups    map[string]GroupStatus `json:"groups,omitempty"`
+	Stale     map[string]NodeStatus  `json:"stale,omitempty"` // Nodes past the max display age
+
+	DuplicateIdentities []DuplicateStatus `json:"duplicate_identities,omitempty"`
+	ConfigWarnings      []ConfigWarning   `json:"config_warnings,omitempty"`
+	NoTelemetry         []string          `json:"no_telemetry,omitempty"` // Sorted addresses, when enabled
+
+	// Nodes reaped since the previous report, oldest first. Each is listed
+	// in exactly one stdout report; the status endpoint never lists them
+	Offline []OfflineNode `json:"offline,omitempty"`
+
+	// True during the startup grace period, while the cluster view may
+	// still be incomplete and the status should not be alerted on
+	Settling bool `json:"settling,omitempty"`
+
+	// Set when fewer peers are known than SetExpectMinNodes requires, so
+	// an empty view is not mistaken for a healthy one
+	ExpectedMinNodes int  `json:"expected_min_nodes,omitempty"`
+	KnownPeers       int  `json:"known_peers,omitempty"`
+	TooFewNodes      bool `json:"too_few_nodes,omitempty"`
+
+	// The reporting node's own monitoring traffic, see SetSelfCheck. When
+	// it is unhealthy the rest of the report may be silently out of date
+	Self *registry.TrafficHealth `json:"self,omitempty"`
+
+	// Report settings, for formatters
+	GroupBy       GroupBy       `json:"-"`
+	MaxDisplayAge time.Duration `json:"-"` // 0 when there is no stale section
+	TimeFormat    TimeFormat    `json:"-"` // How timestamps are written
+}
+
+// DuplicateStatus is a node UUID reported from several addresses in JSON output
+type DuplicateStatus struct {
+	UUID      string   `json:"uuid"`
+	Addresses []string `json:"addresses"`
+}
+
+// ConfigWarning is a peer whose advertised heartbeat interval is too slow
+// for this node's timeout, so it is likely to flap between offline and
+// online. Sorted by address in reports
+type ConfigWarning struct {
+	Address           string `json:"address"`
+	HeartbeatInterval string `json:"heartbeat_interval"`
+	Timeout           string `json:"timeout"`
+}
+
+// DependencyStatus is a dependency of a node that is down: CRITICAL, or
+// DOWN once it was reaped
+type DependencyStatus struct {
+	Address string `json:"address"`
+	Status  string `json:"status"`
+}
+
+// GroupStatus is the status rollup for one group of nodes in JSON output
+type GroupStatus struct {
+	Status      string `json:"status"` // Worst status in the group
+	NodeCount   int    `json:"node_count"`
+	OK          int    `json:"ok"`
+	Warn        int    `json:"warn"`
+	Critical    int    `json:"critical"`
+	Maintenance int    `json:"maintenance,omitempty"`
+	Suppressed  int    `json:"suppressed,omitempty"`
 }
 
 // NodeStatus represents a single node's status in JSON output
 type NodeStatus struct {
-	Address     string        `json:"address"`
-	Status      string        `json:"status"`
-	StatusCode  uint8         `json:"status_code"`
-	LastSeen    time.Time     `json:"last_seen"`
-	Age         string        `json:"age"`
-	CPUPercent  float64       `json:"cpu_percent,omitempty"`
-	RAMPercent  float64       `json:"ram_percent,omitempty"`
-	DiskPercent float64       `json:"disk_percent,omitempty"`
-	RTT         string        `json:"rtt,omitempty"`
+	Address      string    `json:"address"`
+	UUID         string    `json:"uuid,omitempty"`
+	Status       string    `json:"status"`
+	StatusCode   uint8     `json:"status_code"`
+	LastSeen     time.Time `json:"last_seen"`
+	Age          string    `json:"age"`
+	CPUPercent   float64   `json:"cpu_percent,omitempty"`
+	RAMPercent   float64   `json:"ram_percent,omitempty"`
+	DiskPercent  float64   `json:"disk_percent,omitempty"`
+	HasTelemetry bool      `json:"has_telemetry"`
+	RTT          string    `json:"rtt,omitempty"`
+	Phi          float64   `json:"phi,omitempty"`
+	Probed       bool      `json:"probed,omitempty"`
+	Group        string    `json:"group,omitempty"`
+	ClockSkew    string    `json:"clock_skew,omitempty"` // Positive if the node's clock is ahead
+	ReapIn       string    `json:"reap_in,omitempty"`    // Time left before the reaper removes the node, see SetReapTimeout
+	Priority     uint8     `json:"priority,omitempty"`   // Operator-assigned preference in leader election
+
+	// Datacenter and coordinates, when known, for map dashboards
+	Location *registry.Location `json:"location,omitempty"`
+
+	// Pod, namespace and Kubernetes node, when run in Kubernetes
+	Kubernetes *registry.Kubernetes `json:"kubernetes,omitempty"`
+
+	// Estimated heartbeat loss in percent, when known
+	PacketLoss *float64 `json:"packet_loss_percent,omitempty"`
+
+	// Collector the node was merged from, for nodes not seen directly
+	FederatedFrom string `json:"federated_from,omitempty"`
+
+	// How many of the other nodes sharing their peer view see this node,
+	// when any do
+	SeenByCount *int `json:"seen_by_count,omitempty"`
+	SeenByOf    int  `json:"seen_by_of,omitempty"`
+
+	// Dependencies that are down, see registry.Monitor.SetDependencies.
+	// Suppressed is set while the node itself fails, so its status is
+	// likely a consequence and not counted towards the cluster status
+	BlockedBy  []DependencyStatus `json:"blocked_by,omitempty"`
+	Suppressed bool               `json:"suppressed,omitempty"`
+
+	// Status recomputed by this collector from the node's telemetry, set
+	// only when it differs from the self-reported Status
+	ComputedStatus string `json:"computed_status,omitempty"`
+
+	// Network throughput in bytes per second, when sampled
+	NetRxBytesPerSec float64 `json:"net_rx_bytes_per_sec,omitempty"`
+	NetTxBytesPerSec float64 `json:"net_tx_bytes_per_sec,omitempty"`
+	HasNetwork       bool    `json:"-"`
+
+	fullTelemetry bool       // Emit telemetry fields even when zero
+	timeFormat    TimeFormat // Format of last_seen
+	reapSoon      bool       // Less than half the reap timeout is left
+}
+
+// MarshalJSON emits explicit zero telemetry for nodes that have reported it,
+// and omits the fields for nodes that never did unless full telemetry was
+// requested. last_seen is written in the report's time format
+func (n NodeStatus) MarshalJSON() ([]byte, error) {
+	type plain NodeStatus
+	// Shallower fields take precedence over the embedded omitempty ones
+	type withTelemetry struct {
+		plain
+		CPUPercent  float64 `json:"cpu_percent"`
+		RAMPercent  float64 `json:"ram_percent"`
+		DiskPercent float64 `json:"disk_percent"`
+	}
+	hideTelemetry := !n.fullTelemetry && !n.HasTelemetry
+	full := withTelemetry{plain(n), n.CPUPercent, n.RAMPercent, n.DiskPercent}
+	if n.timeFormat == TimeRFC3339 {
+		if hideTelemetry {
+			return json.Marshal(plain(n))
+		}
+		return json.Marshal(full)
+	}
+
+	lastSeen := formattedTime{n.LastSeen, n.timeFormat}
+	if hideTelemetry {
+		return json.Marshal(struct {
+			plain
+			LastSeen formattedTime `json:"last_seen"`
+		}{plain(n), lastSeen})
+	}
+	return json.Marshal(struct {
+		withTelemetry
+		LastSeen formattedTime `json:"last_seen"`
+	}{full, lastSeen})
+}
+
+// MarshalJSON writes the timestamp in the report's time format
+func (r StatusReport) MarshalJSON() ([]byte, error) {
+	type plain StatusReport
+	if r.TimeFormat == TimeRFC3339 {
+		return json.Marshal(plain(r))
+	}
+	return json.Marshal(struct {
+		plain
+		Timestamp formattedTime `json:"timestamp"`
+	}{plain(r), formattedTime{r.Timestamp, r.TimeFormat}})
 }
 
 // NewReporter creates a new status reporter
@@ -44,110 +279,556 @@ func NewReporter(monitor *registry.Monitor, jsonMode bool) *Reporter {
 		monitor:  monitor,
 		jsonMode: jsonMode,
 		output:   os.Stdout,
+		clock:    clock.Real(),
 		stopChan: make(chan struct{}),
+		resumed:  make(chan struct{}, 1),
+		changed:  make(chan struct{}, 1),
 	}
 }
 
+// SetJSONOptions configures compact output and telemetry field omission for JSON reports
+func (r *Reporter) SetJSONOptions(opts JSONOptions) {
+	r.jsonOpts = opts
+}
+
+// SetTimeFormat writes report timestamps in f instead of RFC 3339: the JSON
+// timestamp and last_seen fields, and the time in the human report header.
+// The status endpoint always uses RFC 3339 so that collectors can federate
+func (r *Reporter) SetTimeFormat(f TimeFormat) {
+	r.timeFormat = f
+}
+
+// SetGroupBy partitions reports into groups with per-group status rollups
+func (r *Reporter) SetGroupBy(by GroupBy) {
+	r.groupBy = by
+}
+
+// SetMaxDisplayAge moves nodes not seen for longer than maxAge out of the
+// main listing into a separate stale section. The monitor is unaffected
+func (r *Reporter) SetMaxDisplayAge(maxAge time.Duration) {
+	r.maxAge = maxAge
+}
+
+// SetFormatter renders reports with f instead of the human or JSON output
+func (r *Reporter) SetFormatter(f ReportFormatter) {
+	r.formatter = f
+}
+
+// SetStatusCheck recomputes each node's status from its telemetry, e.g.
+// with the collector's own thresholds. Nodes whose self-reported status
+// differs are shown with both. check returns false to skip a node
+func (r *Reporter) SetStatusCheck(check func(registry.NodeInfo) (uint8, bool)) {
+	r.statusCheck = check
+}
+
+// SetTimeout enables config warnings for peers whose advertised heartbeat
+// interval exceeds half of timeout: a single lost heartbeat then gets them
+// reaped, and they rejoin with the next one
+func (r *Reporter) SetTimeout(timeout time.Duration) {
+	r.timeout = timeout
+}
+
+// SetClock replaces the clock used for ages, timestamps and the report ticker
+// Must be called before Start
+func (r *Reporter) SetClock(c clock.Clock) {
+	r.clock = c
+}
+
+// SetListNoTelemetry lists the nodes that heartbeat but have never sent
+// telemetry, e.g. to find agents that need upgrading
+func (r *Reporter) SetListNoTelemetry(list bool) {
+	r.listNoTelemetry = list
+}
+
+// SetAnonymizer replaces node addresses with aliases in printed reports.
+// The status endpoint keeps the real addresses. Must be called before Start
+func (r *Reporter) SetAnonymizer(a *Anonymizer) {
+	r.anonymizer = a
+}
+
+// SetSettlePeriod marks reports as settling for d from now on the
+// reporter's clock. Must be called after SetClock and before Start
+func (r *Reporter) SetSettlePeriod(d time.Duration) {
+	r.settleUntil = r.clock.Now().Add(d)
+}
+
+// Settling reports whether the settle period is still running
+func (r *Reporter) Settling() bool {
+	return r.clock.Now().Before(r.settleUntil)
+}
+
+// SetExpectMinNodes marks reports that know fewer than n peers, stale ones
+// included, as too few to trust (0 disables). The entry at self, this
+// node's own, is not counted. Must be called before Start
+func (r *Reporter) SetExpectMinNodes(n int, self string) {
+	r.expectMinNodes = n
+	r.selfAddr = registry.CanonicalAddr(self)
+}
+
+// SetSelfCheck adds the reporting node's own traffic health to reports, so
+// a node that no longer sends or receives heartbeats says so instead of
+// reporting a frozen view. Must be called before Start
+func (r *Reporter) SetSelfCheck(check func() registry.TrafficHealth) {
+	r.selfCheck = check
+}
+
+// SetUnbuffered writes each report to the output as it is rendered,
+// instead of building it in memory and writing it at once. This saves
+// memory on very large reports, but a report may reach the output in many
+// writes and interleave with other output. Signed reports are always
+// written at once. Must be called before Start
+func (r *Reporter) SetUnbuffered(unbuffered bool) {
+	r.unbuffered = unbuffered
+}
+
+// SetActive gates periodic reports, e.g. so only the active collector of a
+// primary/standby pair reports. Report itself is not gated
+func (r *Reporter) SetActive(active func() bool) {
+	r.active = active
+}
+
 // Start begins periodic status reporting
 func (r *Reporter) Start(interval time.Duration) {
-	ticker := time.NewTicker(interval)
+	ticker := r.clock.NewTicker(interval)
 	defer ticker.Stop()
 
+	// Changes within the floor of the last report wait for a floor tick
+	var floor <-chan time.Time
+	if r.changeFloor > 0 {
+		floorTicker := r.clock.NewTicker(r.changeFloor)
+		defer floorTicker.Stop()
+		floor = floorTicker.C()
+	}
+	var last time.Time
+	pending := false
+	report := func() {
+		r.Report()
+		last, pending = r.clock.Now(), false
+	}
+
 	for {
 		select {
 		case <-r.stopChan:
 			return
-		case <-ticker.C:
-			r.Report()
+		case <-ticker.C():
+			if r.paused.Load() || (r.active != nil && !r.active()) {
+				r.pruneOffline(r.clock.Now().Add(-interval))
+				continue
+			}
+			report()
+		case <-r.resumed:
+			if r.active != nil && !r.active() {
+				continue
+			}
+			report()
+		case <-r.changed:
+			if r.paused.Load() || (r.active != nil && !r.active()) {
+				continue
+			}
+			if r.clock.Now().Sub(last) >= r.changeFloor {
+				report()
+			} else {
+				pending = true
+			}
+		case <-floor:
+			if pending && r.clock.Now().Sub(last) >= r.changeFloor &&
+				!r.paused.Load() && (r.active == nil || r.active()) {
+				report()
+			}
 		}
 	}
 }
 
-// Stop stops the reporter
+// SetReportOnChange reports at once when a node changes status or leaves,
+// instead of waiting for the next periodic report, but never sooner than
+// floor after the previous report, so a storm of changes cannot flood the
+// output. A change inside the floor is reported within two floors. Events
+// reach the reporter through RecordEvent. 0 disables. Must be called
+// before Start
+func (r *Reporter) SetReportOnChange(floor time.Duration) {
+	r.changeFloor = floor
+}
+
+// RecordEvent notes reaped nodes for the offline section of the next
+// report, and asks for an immediate report on status changes and
+// departures when SetReportOnChange is enabled. It never blocks, so it can
+// be used as the monitor's event handler
+func (r *Reporter) RecordEvent(e registry.Event) {
+	r.recordOffline(e)
+	if r.changeFloor <= 0 || (e.Type != registry.EventStatusChanged && e.Type != registry.EventLeft) {
+		return
+	}
+	select {
+	case r.changed <- struct{}{}:
+	default: // A report is already pending
+	}
+}
+
+// Pause skips periodic reports until Resume, e.g. while tailing logs for
+// something else. The monitor keeps updating
+func (r *Reporter) Pause() {
+	r.paused.Store(true)
+}
+
+// Resume restarts periodic reports, starting with an immediate one
+func (r *Reporter) Resume() {
+	if !r.paused.CompareAndSwap(true, false) {
+		return
+	}
+	select {
+	case r.resumed <- struct{}{}:
+	default: // A report is already pending
+	}
+}
+
+// Paused reports whether periodic reports are paused
+func (r *Reporter) Paused() bool {
+	return r.paused.Load()
+}
+
+// Stop stops the reporter. Safe to call more than once
 func (r *Reporter) Stop() {
-	close(r.stopChan)
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+	})
+}
+
+// StatusHandler serves the current report as JSON on StatusPath, whatever
+// the reporter's own output format, e.g. for other collectors to federate.
+// A fields query parameter, e.g. ?fields=address,status,cpu, cuts each node
+// down to the listed fields to shrink the response on large clusters
+func (r *Reporter) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var body []byte
+		var err error
+		if req.URL.Query().Has("fields") {
+			body, err = projectReport(r.buildReportAs(TimeRFC3339), parseFields(req.URL.Query().Get("fields")))
+		} else {
+			body, err = json.Marshal(r.buildReportAs(TimeRFC3339))
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// NodesHandler streams every known node on NodesPath as one JSON
+// NodeStatus per line, for clusters too large to build the whole report in
+// memory. Nodes are copied from the monitor one shard at a time and
+// written as they are encoded, in no particular order. Stale nodes are
+// included, and fields projects each node like StatusHandler
+func (r *Reporter) NodesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var fields map[string]bool
+		if req.URL.Query().Has("fields") {
+			fields = parseFields(req.URL.Query().Get("fields"))
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		out := bufio.NewWriter(w)
+		enc := json.NewEncoder(out)
+		now := r.clock.Now()
+		r.monitor.ForEachNode(func(addr string, info registry.NodeInfo) bool {
+			status := r.nodeStatus(addr, info, now)
+			var v interface{} = status
+			if fields != nil {
+				v = projectedNode{status, fields}
+			}
+			// A failed write means the client went away
+			return enc.Encode(v) == nil
+		})
+		out.Flush()
+	})
 }
 
 // Report outputs the current status
 func (r *Reporter) Report() {
-	if r.jsonMode {
-		r.reportJSON()
-	} else {
-		r.reportHuman()
+	f := r.formatter
+	if f == nil {
+		if r.jsonMode {
+			f = JSONFormatter{Compact: r.jsonOpts.Compact}
+		} else {
+			f = HumanFormatter{}
+		}
+	}
+	if r.unbuffered && r.signingKey == nil {
+		if err := f.Format(r.buildReport(), r.output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+		}
+		return
+	}
+
+	// The report is written in one piece, so a slow sink sees one write
+	// and other writers to the same file cannot tear it
+	var body bytes.Buffer
+	if err := f.Format(r.buildReport(), &body); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+		return
+	}
+	if r.signingKey != nil {
+		// The signature goes on a line of its own
+		if body.Len() > 0 && body.Bytes()[body.Len()-1] != '\n' {
+			body.WriteByte('\n')
+		}
+		r.signMu.Lock()
+		defer r.signMu.Unlock()
+		r.signSeq++
+		body.Write(signatureLine(body.Bytes(), r.signSeq, r.signingKey))
+	}
+	if _, err := r.output.Write(body.Bytes()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
 	}
 }
 
-// reportHuman outputs human-readable status
-func (r *Reporter) reportHuman() {
+// splitStale separates nodes older than the max display age. Without a max
+// display age every node is fresh
+func (r *Reporter) splitStale(nodes map[string]registry.NodeInfo, now time.Time) (fresh, stale map[string]registry.NodeInfo) {
+	if r.maxAge <= 0 {
+		return nodes, nil
+	}
+	fresh = make(map[string]registry.NodeInfo, len(nodes))
+	for addr, info := range nodes {
+		if now.Sub(info.LastSeen) > r.maxAge {
+			if stale == nil {
+				stale = make(map[string]registry.NodeInfo)
+			}
+			stale[addr] = info
+			continue
+		}
+		fresh[addr] = info
+	}
+	return fresh, stale
+}
+
+// buildReport builds the stdout report, which also lists the nodes reaped
+// since the previous one
+func (r *Reporter) buildReport() StatusReport {
+	report := r.buildReportAs(r.timeFormat)
+	report.Offline = r.takeOffline(report, r.timeFormat)
+	if len(report.Offline) > 0 {
+		blocked := r.monitor.DownDependencies()
+		for i := range report.Offline {
+			report.Offline[i].BlockedBy = dependencyStatuses(blocked[report.Offline[i].Address])
+		}
+	}
+	if r.anonymizer != nil {
+		r.anonymizer.scrub(&report)
+	}
+	return report
+}
+
+// buildReportAs builds the report with timestamps in the given format
+func (r *Reporter) buildReportAs(format TimeFormat) StatusReport {
+	// Counts come from the same snapshot as the listed nodes, so a join or
+	// reap in between cannot make them disagree
 	nodes := r.monitor.GetNodes()
-	count := r.monitor.GetNodeCount()
+	count := len(nodes)
 
-	fmt.Fprintf(r.output, "\n=== PulseCheck Status (Nodes: %d) ===\n", count)
+	report := StatusReport{
+		Timestamp:     r.clock.Now(),
+		NodeCount:     count,
+		Nodes:         make(map[string]NodeStatus, count),
+		GroupBy:       r.groupBy,
+		MaxDisplayAge: r.maxAge,
+		TimeFormat:    format,
+	}
+	report.Settling = report.Timestamp.Before(r.settleUntil)
+	if r.expectMinNodes > 0 {
+		peers := count
+		if _, ok := nodes[r.selfAddr]; ok {
+			peers--
+		}
+		report.ExpectedMinNodes = r.expectMinNodes
+		report.KnownPeers = peers
+		report.TooFewNodes = peers < r.expectMinNodes
+	}
+	if r.selfCheck != nil {
+		self := r.selfCheck()
+		report.Self = &self
+	}
 
-	if count == 0 {
-		fmt.Fprintln(r.output, "No active nodes")
-		return
+	fresh, stale := r.splitStale(nodes, report.Timestamp)
+	if len(stale) > 0 {
+		report.Stale = make(map[string]NodeStatus, len(stale))
 	}
+	visibility := r.monitor.Visibility()
+	blocked := r.monitor.DownDependencies()
 
 	for addr, info := range nodes {
-		statusStr := statusCodeToString(info.StatusCode)
-		age := time.Since(info.LastSeen)
+		nodeStatus := r.nodeStatus(addr, info, report.Timestamp)
+		nodeStatus.timeFormat = format
+		if v, ok := visibility[addr]; ok {
+			nodeStatus.SeenByCount = &v.SeenBy
+			nodeStatus.SeenByOf = v.Viewers
+		}
+		nodeStatus.BlockedBy = dependencyStatuses(blocked[addr])
+		nodeStatus.Suppressed = info.Suppressed
+
+		if r.timeout > 0 && info.HeartbeatInterval*2 > r.timeout {
+			report.ConfigWarnings = append(report.ConfigWarnings, ConfigWarning{
+				Address:           addr,
+				HeartbeatInterval: info.HeartbeatInterval.String(),
+				Timeout:           r.timeout.String(),
+			})
+		}
 
-		fmt.Fprintf(r.output, "Node: %s | Status: %s | Age: %v", 
-			addr, statusStr, age.Round(time.Second))
+		if _, ok := stale[addr]; ok {
+			report.Stale[addr] = nodeStatus
+			continue
+		}
+		report.Nodes[addr] = nodeStatus
+	}
 
-		if info.CPUPercent > 0 || info.RAMPercent > 0 || info.DiskPercent > 0 {
-			fmt.Fprintf(r.output, " | CPU: %.1f%% RAM: %.1f%% Disk: %.1f%%",
-				info.CPUPercent, info.RAMPercent, info.DiskPercent)
+	if r.groupBy != GroupNone {
+		report.Groups = make(map[string]GroupStatus)
+		for _, g := range groupNodes(r.groupBy, fresh) {
+			report.Groups[g.Name] = GroupStatus{
+				Status:      statusCodeToString(g.Summary.WorstStatus()),
+				NodeCount:   g.Summary.Total,
+				OK:          g.Summary.OK,
+				Warn:        g.Summary.Warn,
+				Critical:    g.Summary.Critical,
+				Maintenance: g.Summary.Maintenance,
+				Suppressed:  g.Summary.Suppressed,
+			}
 		}
+	}
+
+	sort.Slice(report.ConfigWarnings, func(i, j int) bool {
+		return report.ConfigWarnings[i].Address < report.ConfigWarnings[j].Address
+	})
 
-		if info.RTT > 0 {
-			fmt.Fprintf(r.output, " | RTT: %v", info.RTT.Round(time.Millisecond))
+	if r.listNoTelemetry {
+		for addr := range r.monitor.GetNodesWithoutTelemetry() {
+			report.NoTelemetry = append(report.NoTelemetry, addr)
 		}
+		sort.Strings(report.NoTelemetry)
+	}
 
-		fmt.Fprintln(r.output)
+	for _, dup := range r.monitor.DuplicateIdentities() {
+		report.DuplicateIdentities = append(report.DuplicateIdentities, DuplicateStatus{
+			UUID:      hex.EncodeToString(dup.UUID[:]),
+			Addresses: dup.Addresses,
+		})
 	}
+
+	return report
 }
 
-// reportJSON outputs JSON-formatted status
-func (r *Reporter) reportJSON() {
-	nodes := r.monitor.GetNodes()
-	count := r.monitor.GetNodeCount()
+// nodeStatus builds the report entry for one node
+func (r *Reporter) nodeStatus(addr string, info registry.NodeInfo, now time.Time) NodeStatus {
+	age := now.Sub(info.LastSeen)
+	nodeStatus := NodeStatus{
+		Address:       addr,
+		Status:        nodeStatusString(info),
+		StatusCode:    info.StatusCode,
+		LastSeen:      info.LastSeen,
+		Age:           age.Round(time.Second).String(),
+		fullTelemetry: r.jsonOpts.FullTelemetry,
+	}
 
-	report := StatusReport{
-		Timestamp: time.Now(),
-		NodeCount: count,
-		Nodes:     make(map[string]NodeStatus, count),
+	if info.HasTelemetry {
+		nodeStatus.CPUPercent = finite(info.CPUPercent)
+		nodeStatus.RAMPercent = finite(info.RAMPercent)
+		nodeStatus.DiskPercent = finite(info.DiskPercent)
+		nodeStatus.HasTelemetry = true
 	}
 
-	for addr, info := range nodes {
-		age := time.Since(info.LastSeen)
-		nodeStatus := NodeStatus{
-			Address:    addr,
-			Status:      statusCodeToString(info.StatusCode),
-			StatusCode:  info.StatusCode,
-			LastSeen:   info.LastSeen,
-			Age:        age.Round(time.Second).String(),
-		}
+	if info.HasNetwork {
+		nodeStatus.NetRxBytesPerSec = finite(info.NetRxBytesPerSec)
+		nodeStatus.NetTxBytesPerSec = finite(info.NetTxBytesPerSec)
+		nodeStatus.HasNetwork = true
+	}
+
+	if info.RTT > 0 {
+		nodeStatus.RTT = info.RTT.Round(time.Millisecond).String()
+	}
+
+	if r.reapTimeout > 0 {
+		left := reapIn(r.reapTimeout, info.LastSeen, now)
+		nodeStatus.ReapIn = left.Round(time.Second).String()
+		nodeStatus.reapSoon = left < r.reapTimeout/2
+	}
 
-		if info.CPUPercent > 0 || info.RAMPercent > 0 || info.DiskPercent > 0 {
-			nodeStatus.CPUPercent = info.CPUPercent
-			nodeStatus.RAMPercent = info.RAMPercent
-			nodeStatus.DiskPercent = info.DiskPercent
+	if skew := info.ClockSkew.Round(time.Millisecond); skew != 0 {
+		nodeStatus.ClockSkew = skew.String()
+	}
+
+	if r.statusCheck != nil && !info.Silenced {
+		if computed, ok := r.statusCheck(info); ok && computed != info.StatusCode {
+			nodeStatus.ComputedStatus = statusCodeToString(computed)
 		}
+	}
+
+	nodeStatus.Phi = finite(info.Phi)
+	if info.HasPacketLoss {
+		loss := finite(info.PacketLoss)
+		nodeStatus.PacketLoss = &loss
+	}
+	if info.UUID != ([16]byte{}) {
+		nodeStatus.UUID = hex.EncodeToString(info.UUID[:])
+	}
+	nodeStatus.Probed = info.Probed
+	nodeStatus.Priority = info.Priority
+	nodeStatus.FederatedFrom = info.FederatedFrom
+	if !info.Location.IsZero() {
+		loc := info.Location
+		nodeStatus.Location = &loc
+	}
+	if !info.Kubernetes.IsZero() {
+		k := info.Kubernetes
+		nodeStatus.Kubernetes = &k
+	}
+
+	if r.groupBy != GroupNone {
+		nodeStatus.Group = groupKey(r.groupBy, addr)
+	}
+	return nodeStatus
+}
 
-		if info.RTT > 0 {
-			nodeStatus.RTT = info.RTT.Round(time.Millisecond).String()
+// dependencyStatuses converts down dependencies for reports
+func dependencyStatuses(down []registry.DownDependency) []DependencyStatus {
+	if len(down) == 0 {
+		return nil
+	}
+	result := make([]DependencyStatus, len(down))
+	for i, d := range down {
+		result[i] = DependencyStatus{Address: d.Address, Status: "CRITICAL"}
+		if d.Missing {
+			result[i].Status = "DOWN"
 		}
+	}
+	return result
+}
 
-		report.Nodes[addr] = nodeStatus
+// finite returns v, or 0 when v is NaN or infinite. encoding/json refuses
+// non-finite floats, so one bad metric would otherwise fail the whole report
+func finite(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
 	}
+	return v
+}
 
-	encoder := json.NewEncoder(r.output)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(report); err != nil {
-		fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+// nodeStatusString returns the status label for a node, which is MAINTENANCE
+// while the node is silenced regardless of its reported status code
+func nodeStatusString(info registry.NodeInfo) string {
+	if info.Silenced {
+		return "MAINTENANCE"
 	}
+	return statusCodeToString(info.StatusCode)
 }
 
 // statusCodeToString converts status code to string
@@ -3,10 +3,15 @@ package display
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
 	"github.com/rafaelmarinho/pulsecheck/internal/registry"
 )
 
@@ -228,3 +233,1003 @@ func TestReporterJSONTimestamp(t *testing.T) {
 			report.Timestamp, before, after)
 	}
 }
+
+func TestReporterJSONCompact(t *testing.T) {
+	monitor := registry.NewMonitor()
+	reporter := NewReporter(monitor, true)
+	reporter.SetJSONOptions(JSONOptions{Compact: true})
+
+	var buf bytes.Buffer
+	reporter.output = &buf
+
+	monitor.UpdateWithTelemetry("192.168.1.100:9999", 75.5, 80.2, 85.1, 1)
+	reporter.Report()
+
+	output := strings.TrimSuffix(buf.String(), "\n")
+	if strings.Contains(output, "\n") {
+		t.Errorf("Compact JSON output should be a single line, got:\n%s", output)
+	}
+
+	var report StatusReport
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("Compact JSON output is invalid: %v", err)
+	}
+}
+
+func TestReporterJSONFullTelemetry(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("192.168.1.100:9999", 0, time.Now().UnixNano())
+
+	testCases := []struct {
+		name string
+		full bool
+		want bool
+	}{
+		{"omit empty", false, false},
+		{"full", true, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter := NewReporter(monitor, true)
+			reporter.SetJSONOptions(JSONOptions{FullTelemetry: tc.full})
+
+			var buf bytes.Buffer
+			reporter.output = &buf
+			reporter.Report()
+
+			var raw struct {
+				Nodes map[string]map[string]interface{} `json:"nodes"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+				t.Fatalf("JSON output is invalid: %v", err)
+			}
+
+			node := raw.Nodes["192.168.1.100:9999"]
+			for _, field := range []string{"cpu_percent", "ram_percent", "disk_percent"} {
+				if _, ok := node[field]; ok != tc.want {
+					t.Errorf("field %s present = %v, want %v", field, ok, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReporterJSONIdleNode(t *testing.T) {
+	monitor := registry.NewMonitor()
+	reporter := NewReporter(monitor, true)
+
+	var buf bytes.Buffer
+	reporter.output = &buf
+
+	// An idle node legitimately at 0% everywhere vs a node with no telemetry at all
+	monitor.UpdateWithTelemetry("192.168.1.100:9999", 0, 0, 0, 0)
+	monitor.UpdateWithStatus("192.168.1.101:9999", 0, time.Now().UnixNano())
+
+	reporter.Report()
+
+	var raw struct {
+		Nodes map[string]map[string]interface{} `json:"nodes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatalf("JSON output is invalid: %v", err)
+	}
+
+	idle := raw.Nodes["192.168.1.100:9999"]
+	if idle["has_telemetry"] != true {
+		t.Errorf("idle node has_telemetry = %v, want true", idle["has_telemetry"])
+	}
+	for _, field := range []string{"cpu_percent", "ram_percent", "disk_percent"} {
+		if v, ok := idle[field]; !ok || v != 0.0 {
+			t.Errorf("idle node %s = %v (present %v), want explicit 0", field, v, ok)
+		}
+	}
+
+	silent := raw.Nodes["192.168.1.101:9999"]
+	if silent["has_telemetry"] != false {
+		t.Errorf("status-only node has_telemetry = %v, want false", silent["has_telemetry"])
+	}
+	if _, ok := silent["cpu_percent"]; ok {
+		t.Error("status-only node should omit cpu_percent")
+	}
+}
+
+func TestReporterJSONNonFinite(t *testing.T) {
+	monitor := registry.NewMonitor()
+	reporter := NewReporter(monitor, true)
+
+	var buf bytes.Buffer
+	reporter.output = &buf
+
+	monitor.UpdateWithTelemetry("192.168.1.100:9999", math.NaN(), math.Inf(1), 30, 0)
+	monitor.UpdateWithTelemetry("192.168.1.101:9999", 10, 20, 30, 0)
+	reporter.Report()
+
+	var report StatusReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("JSON output is invalid: %v\n%s", err, buf.String())
+	}
+	if len(report.Nodes) != 2 {
+		t.Fatalf("got %d nodes, want 2", len(report.Nodes))
+	}
+
+	bad := report.Nodes["192.168.1.100:9999"]
+	if bad.CPUPercent != 0 || bad.RAMPercent != 0 || bad.DiskPercent != 30 {
+		t.Errorf("non-finite node = cpu %v ram %v disk %v, want 0 0 30", bad.CPUPercent, bad.RAMPercent, bad.DiskPercent)
+	}
+	if good := report.Nodes["192.168.1.101:9999"]; good.CPUPercent != 10 {
+		t.Errorf("finite node cpu = %v, want 10", good.CPUPercent)
+	}
+}
+
+func TestReporterPacketLoss(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("192.168.1.100:9999", 0, 0)
+	monitor.UpdateWithStatus("192.168.1.101:9999", 0, 0)
+	// One heartbeat lost between two received
+	monitor.UpdatePacketLoss("192.168.1.100:9999", int64(time.Second), 0, time.Second)
+	monitor.UpdatePacketLoss("192.168.1.100:9999", int64(3*time.Second), 0, time.Second)
+
+	reporter := NewReporter(monitor, true)
+	var buf bytes.Buffer
+	reporter.output = &buf
+	reporter.Report()
+
+	var raw struct {
+		Nodes map[string]map[string]interface{} `json:"nodes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatalf("JSON output is invalid: %v", err)
+	}
+	// 2 received of 3 expected
+	if got, _ := raw.Nodes["192.168.1.100:9999"]["packet_loss_percent"].(float64); math.Abs(got-100.0/3) > 1e-9 {
+		t.Errorf("packet_loss_percent = %v, want 33.3", got)
+	}
+	if _, ok := raw.Nodes["192.168.1.101:9999"]["packet_loss_percent"]; ok {
+		t.Error("node without a loss estimate should omit packet_loss_percent")
+	}
+
+	buf.Reset()
+	reporter.jsonMode = false
+	reporter.Report()
+	if !strings.Contains(buf.String(), "Loss: 33.3%") {
+		t.Errorf("human output missing loss:\n%s", buf.String())
+	}
+}
+
+func TestReporterPriority(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithHeartbeat("192.168.1.100:9999", [16]byte{1}, 0, 1)
+	monitor.UpdatePriority("192.168.1.100:9999", 10)
+	monitor.UpdateWithHeartbeat("192.168.1.101:9999", [16]byte{2}, 0, 1)
+
+	reporter := NewReporter(monitor, true)
+	var buf bytes.Buffer
+	reporter.output = &buf
+	reporter.Report()
+
+	var raw struct {
+		Nodes map[string]map[string]interface{} `json:"nodes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatalf("JSON output is invalid: %v", err)
+	}
+	if got, _ := raw.Nodes["192.168.1.100:9999"]["priority"].(float64); got != 10 {
+		t.Errorf("priority = %v, want 10", got)
+	}
+	if _, ok := raw.Nodes["192.168.1.101:9999"]["priority"]; ok {
+		t.Error("node with the default priority should omit priority")
+	}
+
+	buf.Reset()
+	reporter.jsonMode = false
+	reporter.Report()
+	if !strings.Contains(buf.String(), "Priority: 10") || strings.Count(buf.String(), "Priority:") != 1 {
+		t.Errorf("human output should show only the non-default priority:\n%s", buf.String())
+	}
+}
+
+// countingWriter counts the writes it receives
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestReporterSingleWrite(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithHeartbeat("192.168.1.100:9999", [16]byte{1}, 0, 1)
+	monitor.UpdateWithHeartbeat("192.168.1.101:9999", [16]byte{2}, 1, 1)
+
+	for _, jsonMode := range []bool{false, true} {
+		reporter := NewReporter(monitor, jsonMode)
+		out := &countingWriter{}
+		reporter.output = out
+		reporter.Report()
+		if out.writes != 1 {
+			t.Errorf("json=%v: Report() made %d writes, want 1", jsonMode, out.writes)
+		}
+		if !strings.Contains(out.String(), "192.168.1.101:9999") {
+			t.Errorf("json=%v: report is incomplete:\n%s", jsonMode, out.String())
+		}
+	}
+
+	reporter := NewReporter(monitor, false)
+	out := &countingWriter{}
+	reporter.output = out
+	reporter.SetUnbuffered(true)
+	reporter.Report()
+	if out.writes <= 1 {
+		t.Errorf("unbuffered Report() made %d writes, want one per line", out.writes)
+	}
+}
+
+func TestReporterDependencies(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.SetDependencies(map[string][]string{
+		"192.168.1.103:9999": {"192.168.1.101:9999"},
+		"192.168.1.104:9999": {"192.168.1.102:9999"},
+	})
+	monitor.UpdateWithHeartbeat("192.168.1.101:9999", [16]byte{1}, 2, 1)
+	monitor.UpdateWithHeartbeat("192.168.1.102:9999", [16]byte{2}, 2, 1)
+	monitor.UpdateWithHeartbeat("192.168.1.103:9999", [16]byte{3}, 2, 1)
+	monitor.UpdateWithHeartbeat("192.168.1.104:9999", [16]byte{4}, 0, 1)
+
+	reporter := NewReporter(monitor, true)
+	var buf bytes.Buffer
+	reporter.output = &buf
+	reporter.Report()
+
+	var report StatusReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("JSON output is invalid: %v", err)
+	}
+	web := report.Nodes["192.168.1.103:9999"]
+	if !web.Suppressed || len(web.BlockedBy) != 1 || web.BlockedBy[0] != (DependencyStatus{"192.168.1.101:9999", "CRITICAL"}) {
+		t.Errorf("dependent of a critical node = %+v, want suppressed and blocked by it", web)
+	}
+	healthy := report.Nodes["192.168.1.104:9999"]
+	if healthy.Suppressed || len(healthy.BlockedBy) != 1 || healthy.BlockedBy[0].Status != "CRITICAL" {
+		t.Errorf("healthy dependent of a critical node = %+v, want blocked by it but not suppressed", healthy)
+	}
+	if report.Nodes["192.168.1.101:9999"].Suppressed || report.Nodes["192.168.1.102:9999"].Suppressed {
+		t.Error("the root failures should not be suppressed")
+	}
+
+	buf.Reset()
+	reporter.jsonMode = false
+	reporter.Report()
+	out := buf.String()
+	for _, want := range []string{
+		"Status: CRITICAL (suppressed: depends on 192.168.1.101:9999 which is CRITICAL)",
+		"Status: OK (depends on 192.168.1.102:9999 which is CRITICAL)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("human output missing %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	reporter.SetFormatter(OneLineFormatter{})
+	reporter.Report()
+	if !strings.Contains(buf.String(), "4 nodes: 1 OK, 0 WARN, 2 CRITICAL, 1 SUPPRESSED") {
+		t.Errorf("one-line output should count the dependent as suppressed: %s", buf.String())
+	}
+}
+
+func TestReporterSeenBy(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithHeartbeat("192.168.1.100:9999", [16]byte{1}, 0, 1)
+	monitor.UpdateWithHeartbeat("192.168.1.101:9999", [16]byte{2}, 0, 1)
+	monitor.UpdateWithHeartbeat("192.168.1.102:9999", [16]byte{3}, 0, 1)
+	monitor.UpdatePeerView("192.168.1.100:9999", [][16]byte{{2}, {3}})
+	monitor.UpdatePeerView("192.168.1.101:9999", [][16]byte{{1}})
+
+	reporter := NewReporter(monitor, true)
+	var buf bytes.Buffer
+	reporter.output = &buf
+	reporter.Report()
+
+	var raw struct {
+		Nodes map[string]map[string]interface{} `json:"nodes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatalf("JSON output is invalid: %v", err)
+	}
+	node := raw.Nodes["192.168.1.102:9999"]
+	if node["seen_by_count"] != 1.0 || node["seen_by_of"] != 2.0 {
+		t.Errorf("seen_by = %v of %v, want 1 of 2", node["seen_by_count"], node["seen_by_of"])
+	}
+
+	buf.Reset()
+	reporter.jsonMode = false
+	reporter.Report()
+	if !strings.Contains(buf.String(), "Seen by: 1/2 (partial)") || !strings.Contains(buf.String(), "Seen by: 1/1\n") {
+		t.Errorf("human output missing seen-by counts:\n%s", buf.String())
+	}
+}
+
+func TestReporterHumanIdleNode(t *testing.T) {
+	monitor := registry.NewMonitor()
+	reporter := NewReporter(monitor, false)
+
+	var buf bytes.Buffer
+	reporter.output = &buf
+
+	monitor.UpdateWithTelemetry("192.168.1.100:9999", 0, 0, 0, 0)
+	reporter.Report()
+
+	if !strings.Contains(buf.String(), "CPU: 0.0% RAM: 0.0% Disk: 0.0%") {
+		t.Errorf("Human output should show explicit zero telemetry, got:\n%s", buf.String())
+	}
+}
+
+func TestReporterHumanStatusOnlyNode(t *testing.T) {
+	monitor := registry.NewMonitor()
+	reporter := NewReporter(monitor, false)
+
+	var buf bytes.Buffer
+	reporter.output = &buf
+
+	monitor.UpdateWithStatus("192.168.1.100:9999", 0, 0)
+	reporter.Report()
+
+	output := buf.String()
+	if !strings.Contains(output, "Telemetry: n/a") {
+		t.Errorf("Status-only node should show unknown telemetry, got:\n%s", output)
+	}
+	if strings.Contains(output, "CPU:") {
+		t.Errorf("Status-only node should not show zero telemetry, got:\n%s", output)
+	}
+}
+
+func TestReporterSilencedNode(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithTelemetry("192.168.1.100:9999", 95.0, 50.0, 50.0, 2)
+	monitor.Silence("192.168.1.100:9999", time.Now().Add(time.Hour))
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.output = &buf
+	reporter.Report()
+	if !strings.Contains(buf.String(), "Status: MAINTENANCE") {
+		t.Errorf("Human output should report silenced node as MAINTENANCE:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	reporter = NewReporter(monitor, true)
+	reporter.output = &buf
+	reporter.Report()
+
+	var report StatusReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	node := report.Nodes["192.168.1.100:9999"]
+	if node.Status != "MAINTENANCE" || node.StatusCode != 2 {
+		t.Errorf("JSON status = %s (%d), want MAINTENANCE with the reported code 2", node.Status, node.StatusCode)
+	}
+}
+
+func TestReporterGroupBySubnet(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("192.168.1.1:9999", 0, 0)
+	monitor.UpdateWithStatus("192.168.1.2:9999", 2, 0)
+	monitor.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.SetGroupBy(GroupSubnet)
+	reporter.output = &buf
+	reporter.Report()
+
+	output := buf.String()
+	for _, want := range []string{
+		"--- subnet: 10.0.0.0/24 (Nodes: 1 | OK: 1 | WARN: 0 | CRITICAL: 0) ---",
+		"--- subnet: 192.168.1.0/24 (Nodes: 2 | OK: 1 | WARN: 0 | CRITICAL: 1) ---",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Grouped output missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Index(output, "10.0.0.0/24") > strings.Index(output, "192.168.1.0/24") {
+		t.Error("Groups should be sorted by name")
+	}
+
+	buf.Reset()
+	reporter.jsonMode = true
+	reporter.Report()
+
+	var report StatusReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	group := report.Groups["192.168.1.0/24"]
+	if group.Status != "CRITICAL" || group.NodeCount != 2 || group.Critical != 1 {
+		t.Errorf("JSON group rollup = %+v", group)
+	}
+	if report.Nodes["10.0.0.1:9999"].Group != "10.0.0.0/24" {
+		t.Errorf("JSON node group = %q, want 10.0.0.0/24", report.Nodes["10.0.0.1:9999"].Group)
+	}
+}
+
+func TestReporterSetActive(t *testing.T) {
+	monitor := registry.NewMonitor()
+	reporter := NewReporter(monitor, false)
+	fake := clock.NewFake(time.Now())
+	reporter.SetClock(fake)
+	var buf bytes.Buffer
+	reporter.output = &buf
+
+	// Standby: periodic reports are suppressed
+	checks := make(chan bool)
+	var active atomic.Bool
+	reporter.SetActive(func() bool {
+		a := active.Load()
+		checks <- a
+		return a
+	})
+
+	done := make(chan struct{})
+	go func() {
+		reporter.Start(time.Second)
+		close(done)
+	}()
+	fake.BlockUntil(1)
+
+	fake.Advance(time.Second)
+	<-checks
+	fake.Advance(time.Second)
+	<-checks
+	// The gate runs before Report, so the previous tick's report (if any)
+	// has been written by the time the next check arrives
+	if buf.Len() != 0 {
+		t.Errorf("standby reporter wrote output:\n%s", buf.String())
+	}
+
+	active.Store(true)
+	fake.Advance(time.Second)
+	<-checks
+	reporter.Stop()
+	<-done
+	if !strings.Contains(buf.String(), "PulseCheck Status") {
+		t.Errorf("active reporter output = %q, want status header", buf.String())
+	}
+}
+
+func TestReporterMaxDisplayAge(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	monitor := registry.NewMonitor()
+	monitor.SetClock(fake)
+	monitor.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	fake.Advance(60 * time.Millisecond)
+	monitor.UpdateWithStatus("10.0.0.2:9999", 0, 0)
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.SetClock(fake)
+	reporter.SetMaxDisplayAge(30 * time.Millisecond)
+	reporter.output = &buf
+	reporter.Report()
+
+	output := buf.String()
+	staleAt := strings.Index(output, "--- Stale (Nodes: 1 | Age > 30ms) ---")
+	if staleAt < 0 {
+		t.Fatalf("Output missing stale section:\n%s", output)
+	}
+	if i := strings.Index(output, "10.0.0.1:9999"); i < staleAt {
+		t.Errorf("Old node should be listed under the stale section:\n%s", output)
+	}
+	if i := strings.Index(output, "10.0.0.2:9999"); i < 0 || i > staleAt {
+		t.Errorf("Fresh node should be listed before the stale section:\n%s", output)
+	}
+
+	buf.Reset()
+	reporter.jsonMode = true
+	reporter.Report()
+
+	var report StatusReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if _, ok := report.Nodes["10.0.0.2:9999"]; !ok || len(report.Nodes) != 1 {
+		t.Errorf("JSON nodes = %v, want only the fresh node", report.Nodes)
+	}
+	if _, ok := report.Stale["10.0.0.1:9999"]; !ok || len(report.Stale) != 1 {
+		t.Errorf("JSON stale = %v, want only the old node", report.Stale)
+	}
+	if report.NodeCount != 2 {
+		t.Errorf("JSON node_count = %d, want 2 (stale nodes are still tracked)", report.NodeCount)
+	}
+	if monitor.GetNodeCount() != 2 {
+		t.Error("Max display age must not remove nodes from the monitor")
+	}
+}
+
+func TestReporterDuplicateIdentity(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.SetDuplicateWindow(time.Minute)
+	var uuid [16]byte
+	copy(uuid[:], "cloned-vm")
+	monitor.UpdateWithHeartbeat("10.0.0.1:9999", uuid, 0, 0)
+	monitor.UpdateWithHeartbeat("10.0.0.2:9999", uuid, 0, 0)
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.output = &buf
+	reporter.Report()
+
+	want := "WARNING: duplicate node identity 636c6f6e65642d766d00000000000000 reported by 10.0.0.1:9999, 10.0.0.2:9999"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Human output missing %q:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	reporter.jsonMode = true
+	reporter.Report()
+
+	var report StatusReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if len(report.DuplicateIdentities) != 1 || report.DuplicateIdentities[0].UUID != "636c6f6e65642d766d00000000000000" ||
+		len(report.DuplicateIdentities[0].Addresses) != 2 {
+		t.Errorf("JSON duplicate_identities = %+v", report.DuplicateIdentities)
+	}
+}
+
+func TestReporterJSONClockSkew(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	monitor := registry.NewMonitor()
+	monitor.SetClock(fake)
+	var uuid [16]byte
+	monitor.UpdateWithHeartbeat("10.0.0.1:9999", uuid, 0, fake.Now().Add(10*time.Second).UnixNano())
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, true)
+	reporter.SetClock(fake)
+	reporter.output = &buf
+	reporter.Report()
+
+	var report StatusReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	node := report.Nodes["10.0.0.1:9999"]
+	if node.ClockSkew != "10s" || node.Age != "0s" {
+		t.Errorf("JSON clock_skew = %q, age = %q, want 10s and 0s", node.ClockSkew, node.Age)
+	}
+}
+
+func TestReporterStatusCheck(t *testing.T) {
+	monitor := registry.NewMonitor()
+	var uuid [16]byte
+	monitor.UpdateWithReport("10.0.0.1:9999", uuid, 0, 0, 75, 10, 10) // Says OK at 75% CPU
+	monitor.UpdateWithReport("10.0.0.2:9999", uuid, 1, 0, 75, 10, 10) // Agrees with the collector
+
+	// The collector warns at 70% CPU
+	check := func(info registry.NodeInfo) (uint8, bool) {
+		if info.CPUPercent >= 70 {
+			return 1, true
+		}
+		return 0, true
+	}
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.SetStatusCheck(check)
+	reporter.output = &buf
+	reporter.Report()
+
+	out := buf.String()
+	if !strings.Contains(out, "Node: 10.0.0.1:9999 | Status: OK (collector: WARN) | Age:") {
+		t.Errorf("diverging node not flagged:\n%s", out)
+	}
+	if strings.Contains(out, "10.0.0.2:9999 | Status: WARN (collector") {
+		t.Errorf("agreeing node flagged:\n%s", out)
+	}
+
+	report := reporter.buildReport()
+	if got := report.Nodes["10.0.0.1:9999"].ComputedStatus; got != "WARN" {
+		t.Errorf("ComputedStatus = %q, want WARN", got)
+	}
+	if got := report.Nodes["10.0.0.2:9999"].ComputedStatus; got != "" {
+		t.Errorf("ComputedStatus for agreeing node = %q, want empty", got)
+	}
+}
+
+// signalFormatter announces every report on a channel
+type signalFormatter chan struct{}
+
+func (f signalFormatter) Format(StatusReport, io.Writer) error {
+	f <- struct{}{}
+	return nil
+}
+
+func TestReporterPauseResume(t *testing.T) {
+	monitor := registry.NewMonitor()
+	reporter := NewReporter(monitor, false)
+	fake := clock.NewFake(time.Now())
+	reporter.SetClock(fake)
+	reports := make(signalFormatter)
+	reporter.SetFormatter(reports)
+
+	done := make(chan struct{})
+	go func() {
+		reporter.Start(time.Second)
+		close(done)
+	}()
+	defer func() {
+		reporter.Stop()
+		<-done
+	}()
+	fake.BlockUntil(1)
+
+	fake.Advance(time.Second)
+	<-reports
+
+	reporter.Pause()
+	if !reporter.Paused() {
+		t.Fatal("Paused() = false after Pause")
+	}
+	// The monitor keeps updating while paused
+	monitor.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	for i := 0; i < 3; i++ {
+		fake.Advance(time.Second)
+	}
+	select {
+	case <-reports:
+		t.Fatal("paused reporter wrote a report")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got := monitor.GetNodeCount(); got != 1 {
+		t.Errorf("GetNodeCount() = %d while paused, want 1", got)
+	}
+
+	// Resume reports at once, without waiting for the next tick
+	reporter.Resume()
+	select {
+	case <-reports:
+	case <-time.After(time.Second):
+		t.Fatal("Resume() did not emit an immediate report")
+	}
+	if reporter.Paused() {
+		t.Error("Paused() = true after Resume")
+	}
+	// Resuming a running reporter is a no-op
+	reporter.Resume()
+	select {
+	case <-reports:
+		t.Error("second Resume() emitted a report")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestReporterReportOnChange(t *testing.T) {
+	monitor := registry.NewMonitor()
+	reporter := NewReporter(monitor, false)
+	fake := clock.NewFake(time.Now())
+	reporter.SetClock(fake)
+	reporter.SetReportOnChange(time.Second)
+	monitor.SetEventHandler(reporter.RecordEvent)
+	reports := make(signalFormatter)
+	reporter.SetFormatter(reports)
+
+	done := make(chan struct{})
+	go func() {
+		reporter.Start(10 * time.Second)
+		close(done)
+	}()
+	defer func() {
+		reporter.Stop()
+		<-done
+	}()
+	fake.BlockUntil(2)
+
+	expect := func(want bool, what string) {
+		t.Helper()
+		select {
+		case <-reports:
+			if !want {
+				t.Fatalf("%s: unexpected report", what)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if want {
+				t.Fatalf("%s: no report", what)
+			}
+		}
+	}
+
+	// Joins wait for the periodic report
+	monitor.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	expect(false, "join")
+
+	monitor.UpdateWithStatus("10.0.0.1:9999", 2, 0)
+	expect(true, "status change")
+
+	// Changes inside the floor are folded into one report at the next floor tick
+	monitor.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	monitor.UpdateWithStatus("10.0.0.1:9999", 2, 0)
+	expect(false, "change inside the floor")
+	fake.Advance(time.Second)
+	expect(true, "floor tick with a pending change")
+	fake.Advance(time.Second)
+	expect(false, "floor tick without a change")
+
+	// The periodic report still fires
+	for i := 0; i < 8; i++ {
+		fake.Advance(time.Second)
+	}
+	expect(true, "periodic report")
+}
+
+func TestReporterIntervalMismatch(t *testing.T) {
+	monitor := registry.NewMonitor()
+	for addr, interval := range map[string]time.Duration{
+		"10.0.0.1:9999": 5 * time.Second, // Exceeds the 3s timeout
+		"10.0.0.2:9999": time.Second,     // Three heartbeats per timeout
+		"10.0.0.3:9999": 0,               // Not advertised
+		"10.0.0.4:9999": 2 * time.Second, // One lost packet from a reap
+	} {
+		monitor.UpdateWithStatus(addr, 0, 0)
+		monitor.UpdateHeartbeatInterval(addr, interval)
+	}
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.output = &buf
+	reporter.Report()
+	if strings.Contains(buf.String(), "flap") {
+		t.Errorf("reporter without a timeout warned:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	reporter.SetTimeout(3 * time.Second)
+	reporter.Report()
+	want := "WARNING: 10.0.0.1:9999 heartbeats every 5s, too slow for the 3s timeout; expect it to flap offline"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Human output missing %q:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	reporter.jsonMode = true
+	reporter.Report()
+	var report StatusReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	want2 := []ConfigWarning{
+		{Address: "10.0.0.1:9999", HeartbeatInterval: "5s", Timeout: "3s"},
+		{Address: "10.0.0.4:9999", HeartbeatInterval: "2s", Timeout: "3s"},
+	}
+	if len(report.ConfigWarnings) != 2 || report.ConfigWarnings[0] != want2[0] || report.ConfigWarnings[1] != want2[1] {
+		t.Errorf("JSON config_warnings = %+v, want %+v", report.ConfigWarnings, want2)
+	}
+}
+
+func TestReporterSettling(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("10.0.0.1:9999", 2, 0)
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.SetClock(fake)
+	if reporter.Settling() {
+		t.Error("Settling() = true before SetSettlePeriod")
+	}
+	reporter.SetSettlePeriod(30 * time.Second)
+	if !reporter.Settling() {
+		t.Error("Settling() = false right after SetSettlePeriod")
+	}
+	reporter.output = &buf
+	reporter.Report()
+	if !strings.Contains(buf.String(), "still settling after startup") {
+		t.Errorf("Human output missing settling note:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	reporter.jsonMode = true
+	reporter.Report()
+	if !strings.Contains(buf.String(), `"settling": true`) {
+		t.Errorf("JSON output missing settling flag:\n%s", buf.String())
+	}
+
+	fake.Advance(30 * time.Second)
+	if reporter.Settling() {
+		t.Error("Settling() = true after the settle period")
+	}
+	buf.Reset()
+	reporter.Report()
+	if strings.Contains(buf.String(), "settling") {
+		t.Errorf("JSON output after the grace period still settling:\n%s", buf.String())
+	}
+}
+
+func TestReporterExpectMinNodes(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("[::]:9999", 0, 0) // The reporting node itself
+	monitor.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.SetExpectMinNodes(2, "[::]:9999")
+	reporter.output = &buf
+	reporter.Report()
+	if !strings.Contains(buf.String(), "CRITICAL: only 1 peers known, expected at least 2") {
+		t.Errorf("Human output missing too-few-nodes line:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	reporter.jsonMode = true
+	reporter.Report()
+	if !strings.Contains(buf.String(), `"too_few_nodes": true`) {
+		t.Errorf("JSON output missing too_few_nodes flag:\n%s", buf.String())
+	}
+
+	monitor.UpdateWithStatus("10.0.0.2:9999", 0, 0)
+	buf.Reset()
+	reporter.Report()
+	if strings.Contains(buf.String(), "too_few_nodes") {
+		t.Errorf("JSON output with enough nodes still too few:\n%s", buf.String())
+	}
+}
+
+func TestReporterExpectMinNodesConsistent(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("[::]:9999", 0, 0)
+	reporter := NewReporter(monitor, true)
+	reporter.SetExpectMinNodes(1000, "[::]:9999")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5000; i++ {
+			monitor.UpdateWithStatus(fmt.Sprintf("10.0.%d.%d:9999", i>>8, i&0xff), 0, 0)
+		}
+	}()
+
+	// Nodes join while reports are built; the counts always match the
+	// nodes listed
+	for {
+		report := reporter.buildReportAs(reporter.timeFormat)
+		listed := len(report.Nodes) + len(report.Stale)
+		if report.NodeCount != listed || report.KnownPeers != listed-1 {
+			t.Fatalf("NodeCount = %d, KnownPeers = %d with %d nodes listed", report.NodeCount, report.KnownPeers, listed)
+		}
+		if report.TooFewNodes != (listed-1 < 1000) {
+			t.Fatalf("TooFewNodes = %v with %d peers listed", report.TooFewNodes, listed-1)
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
+func TestReporterSelfCheck(t *testing.T) {
+	health := registry.TrafficHealth{Healthy: true}
+	var buf bytes.Buffer
+	reporter := NewReporter(registry.NewMonitor(), false)
+	reporter.SetSelfCheck(func() registry.TrafficHealth { return health })
+	reporter.output = &buf
+	reporter.Report()
+	if strings.Contains(buf.String(), "monitoring is broken") {
+		t.Errorf("Human output flags healthy traffic:\n%s", buf.String())
+	}
+
+	health = registry.TrafficHealth{Problems: []string{"every send has failed for 45s", "UDP listener is down"}, Warnings: []string{"nothing received for 45s"}}
+	buf.Reset()
+	reporter.Report()
+	want := "CRITICAL: this node's monitoring is broken (every send has failed for 45s; UDP listener is down)"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Human output missing self-check line:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "WARNING: this node hears nothing from its peers (nothing received for 45s)") {
+		t.Errorf("Human output missing peer silence line:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	reporter.jsonMode = true
+	reporter.Report()
+	if !strings.Contains(buf.String(), `"healthy": false`) || !strings.Contains(buf.String(), "nothing received for 45s") {
+		t.Errorf("JSON output missing self section:\n%s", buf.String())
+	}
+}
+
+func TestReporterListNoTelemetry(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("10.0.0.2:9999", 0, 0)
+	monitor.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	monitor.UpdateWithTelemetry("10.0.0.3:9999", 10, 20, 30, 0)
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, false)
+	reporter.output = &buf
+	reporter.Report()
+	if strings.Contains(buf.String(), "without telemetry") {
+		t.Errorf("Nodes without telemetry listed when disabled:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	reporter.SetListNoTelemetry(true)
+	reporter.Report()
+	want := "Nodes without telemetry (2): 10.0.0.1:9999, 10.0.0.2:9999"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Human output missing %q:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	reporter.jsonMode = true
+	reporter.Report()
+	var report StatusReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if len(report.NoTelemetry) != 2 || report.NoTelemetry[0] != "10.0.0.1:9999" || report.NoTelemetry[1] != "10.0.0.2:9999" {
+		t.Errorf("JSON no_telemetry = %v", report.NoTelemetry)
+	}
+}
+
+func TestReporterJSONLocation(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	monitor.UpdateWithStatus("10.0.0.2:9999", 0, 0)
+	monitor.UpdateLocation("10.0.0.1:9999", registry.Location{Datacenter: "fra1", Latitude: 50.11, Longitude: 8.68, HasCoordinates: true})
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, true)
+	reporter.output = &buf
+	reporter.Report()
+
+	if !strings.Contains(buf.String(), `"location": {
+        "datacenter": "fra1",
+        "lat": 50.11,
+        "lon": 8.68
+      }`) {
+		t.Errorf("JSON output missing location:\n%s", buf.String())
+	}
+	if strings.Count(buf.String(), `"location"`) != 1 {
+		t.Errorf("JSON output has a location for a node without one:\n%s", buf.String())
+	}
+}
+
+func TestReporterJSONKubernetes(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	monitor.UpdateWithStatus("10.0.0.2:9999", 0, 0)
+	monitor.UpdateKubernetes("10.0.0.1:9999", registry.Kubernetes{Pod: "web-0", Namespace: "shop"})
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, true)
+	reporter.output = &buf
+	reporter.Report()
+
+	if !strings.Contains(buf.String(), `"kubernetes": {
+        "pod": "web-0",
+        "namespace": "shop"
+      }`) {
+		t.Errorf("JSON output missing kubernetes identity:\n%s", buf.String())
+	}
+	if strings.Count(buf.String(), `"kubernetes"`) != 1 {
+		t.Errorf("JSON output has a kubernetes identity for a node without one:\n%s", buf.String())
+	}
+}
+
+func TestReporterStopTwice(t *testing.T) {
+	reporter := NewReporter(registry.NewMonitor(), true)
+	reporter.output = io.Discard
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reporter.Start(time.Hour)
+	}()
+	reporter.Stop()
+	reporter.Stop()
+	<-done
+}
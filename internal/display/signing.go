@@ -0,0 +1,87 @@
+package display
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// SignaturePrefix starts the line that follows each signed report. The
+// rest of the line is the report's sequence number and a base64 Ed25519
+// signature over the sequence number and every byte of the report since
+// the previous signature line
+const SignaturePrefix = "signature: ed25519 "
+
+// ErrReportTampered is returned by VerifyReports for a report whose
+// signature does not match its body
+var ErrReportTampered = errors.New("report signature verification failed")
+
+// ErrReportMissing is returned by VerifyReports when the sequence numbers
+// of two reports are not consecutive, i.e. reports were removed, reordered
+// or replayed
+var ErrReportMissing = errors.New("report sequence broken")
+
+// SetSigningKey signs every report with key, writing a signature line
+// after it so that a consumer holding the public key can detect tampering
+// in storage or transit. nil disables signing. Must be called before Start
+func (r *Reporter) SetSigningKey(key ed25519.PrivateKey) {
+	r.signingKey = key
+}
+
+// signedPayload returns the bytes signed for a report: its sequence
+// number followed by its body
+func signedPayload(body []byte, seq uint64) []byte {
+	payload := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(body)), seq)
+	return append(payload, body...)
+}
+
+// signatureLine returns the signature line for a report body
+func signatureLine(body []byte, seq uint64, key ed25519.PrivateKey) []byte {
+	sig := ed25519.Sign(key, signedPayload(body, seq))
+	return []byte(SignaturePrefix + strconv.FormatUint(seq, 10) + " " + base64.StdEncoding.EncodeToString(sig) + "\n")
+}
+
+// VerifyReports checks every signed report in a stream written with
+// SetSigningKey and returns how many verified. Each report must carry the
+// sequence number after the previous one, except that a node restart
+// starts again at 1 and the first report may have any number, e.g. in a
+// rotated log. Output after the last signature line, e.g. a report cut off
+// by a crash, is not counted. It fails on the first report that does not
+// verify
+func VerifyReports(r io.Reader, key ed25519.PublicKey) (int, error) {
+	reader := bufio.NewReader(r)
+	var body bytes.Buffer
+	var last uint64
+	verified := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if rest, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r\n"), []byte(SignaturePrefix)); ok {
+			seqField, sig, _ := bytes.Cut(rest, []byte(" "))
+			seq, seqErr := strconv.ParseUint(string(seqField), 10, 64)
+			decoded, decodeErr := base64.StdEncoding.DecodeString(string(sig))
+			if seqErr != nil || decodeErr != nil || !ed25519.Verify(key, signedPayload(body.Bytes(), seq), decoded) {
+				return verified, fmt.Errorf("report %d: %w", verified+1, ErrReportTampered)
+			}
+			if verified > 0 && seq != 1 && seq != last+1 {
+				return verified, fmt.Errorf("report %d: %w: sequence %d follows %d", verified+1, ErrReportMissing, seq, last)
+			}
+			last = seq
+			verified++
+			body.Reset()
+		} else {
+			body.Write(line)
+		}
+		if err == io.EOF {
+			return verified, nil
+		}
+		if err != nil {
+			return verified, err
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package display
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+// rawFormatter writes a fixed body, e.g. one without a trailing newline
+type rawFormatter string
+
+func (f rawFormatter) Format(_ StatusReport, w io.Writer) error {
+	_, err := io.WriteString(w, string(f))
+	return err
+}
+
+func TestReporterSigningKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+
+	var buf bytes.Buffer
+	reporter := NewReporter(monitor, true)
+	reporter.output = &buf
+	reporter.SetSigningKey(priv)
+	reporter.Report()
+	monitor.UpdateWithStatus("10.0.0.2:9999", 2, 0)
+	reporter.Report()
+	reporter.SetFormatter(rawFormatter("no newline"))
+	reporter.Report()
+
+	if got := strings.Count(buf.String(), "\n"+SignaturePrefix); got != 3 {
+		t.Fatalf("%d signature lines, want 3:\n%s", got, buf.String())
+	}
+	log := buf.String()
+	if n, err := VerifyReports(strings.NewReader(log), pub); err != nil || n != 3 {
+		t.Errorf("VerifyReports() = %d, %v, want 3 verified", n, err)
+	}
+
+	// A partial report after the last signature is not counted
+	if n, err := VerifyReports(strings.NewReader(log+`{"timestamp": `), pub); err != nil || n != 3 {
+		t.Errorf("VerifyReports() with a trailing partial report = %d, %v, want 3", n, err)
+	}
+
+	tampered := strings.Replace(log, `"10.0.0.2:9999"`, `"10.0.0.3:9999"`, 1)
+	if n, err := VerifyReports(strings.NewReader(tampered), pub); !errors.Is(err, ErrReportTampered) || n != 1 {
+		t.Errorf("VerifyReports() on a tampered log = %d, %v, want 1 and ErrReportTampered", n, err)
+	}
+
+	// Removing, reordering or replaying whole reports breaks the sequence
+	reports := splitReports(log)
+	if len(reports) != 3 || !strings.Contains(reports[0], SignaturePrefix+"1 ") {
+		t.Fatalf("reports not numbered from 1:\n%s", log)
+	}
+	for name, stream := range map[string]string{
+		"removed":   reports[0] + reports[2],
+		"reordered": reports[0] + reports[2] + reports[1],
+		"replayed":  reports[1] + reports[1],
+	} {
+		if _, err := VerifyReports(strings.NewReader(stream), pub); !errors.Is(err, ErrReportMissing) {
+			t.Errorf("VerifyReports() with a report %s = %v, want ErrReportMissing", name, err)
+		}
+	}
+
+	// A rotated log may start mid-sequence, and a restart starts again at 1
+	if n, err := VerifyReports(strings.NewReader(reports[1]+reports[2]+log), pub); err != nil || n != 5 {
+		t.Errorf("VerifyReports() across a restart = %d, %v, want 5", n, err)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	if _, err := VerifyReports(strings.NewReader(log), otherPub); !errors.Is(err, ErrReportTampered) {
+		t.Errorf("VerifyReports() with the wrong key = %v, want ErrReportTampered", err)
+	}
+}
+
+// splitReports splits a signed log into reports, each ending with its
+// signature line
+func splitReports(log string) []string {
+	var reports []string
+	for len(log) > 0 {
+		i := strings.Index(log, SignaturePrefix)
+		if i < 0 {
+			break
+		}
+		end := i + strings.IndexByte(log[i:], '\n') + 1
+		reports = append(reports, log[:end])
+		log = log[end:]
+	}
+	return reports
+}
@@ -0,0 +1,136 @@
+package display
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+// EventStreamPath is the HTTP endpoint serving registry events as
+// Server-Sent Events
+const EventStreamPath = "/events/stream"
+
+const (
+	// streamBuffer bounds the events queued for one slow client
+	streamBuffer = 64
+
+	// streamKeepalive is the interval of comment lines that keep idle
+	// connections open through proxies and reveal dead clients
+	streamKeepalive = 15 * time.Second
+)
+
+// EventStream fans registry events out to HTTP clients as Server-Sent
+// Events, one JSON EventLine per message. At most maxClients are connected
+// at once; a client that falls behind loses events rather than blocking
+// the monitor
+type EventStream struct {
+	maxClients int
+
+	mu      sync.Mutex
+	clients map[chan registry.Event]struct{}
+	dropped atomic.Uint64
+}
+
+// NewEventStream creates a stream accepting up to maxClients subscribers
+func NewEventStream(maxClients int) *EventStream {
+	return &EventStream{
+		maxClients: maxClients,
+		clients:    make(map[chan registry.Event]struct{}),
+	}
+}
+
+// RecordEvent sends an event to every connected client without blocking
+// Suitable for Monitor.SetEventHandler
+func (s *EventStream) RecordEvent(e registry.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.clients {
+		select {
+		case ch <- e:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Clients returns the number of connected clients
+func (s *EventStream) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// Dropped returns the number of events not delivered to slow clients
+func (s *EventStream) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// subscribe registers a client, or returns nil if the stream is full
+func (s *EventStream) subscribe() chan registry.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.clients) >= s.maxClients {
+		return nil
+	}
+	ch := make(chan registry.Event, streamBuffer)
+	s.clients[ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe removes a client
+func (s *EventStream) unsubscribe(ch chan registry.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, ch)
+}
+
+// ServeHTTP streams events to the client until it disconnects
+func (s *EventStream) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch := s.subscribe()
+	if ch == nil {
+		http.Error(w, "too many event stream clients", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-keepalive.C:
+			if _, err := w.Write([]byte(": keepalive\n\n")); err != nil {
+				return
+			}
+		case e := <-ch:
+			data, err := json.Marshal(newEventLine(e))
+			if err != nil {
+				continue
+			}
+			if _, err := w.Write(append(append([]byte("data: "), data...), '\n', '\n')); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
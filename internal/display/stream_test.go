@@ -0,0 +1,94 @@
+package display
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+// waitClients waits until the stream has n connected clients
+func waitClients(t *testing.T, s *EventStream, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Clients() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Clients() = %d, want %d", s.Clients(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	stream := NewEventStream(1)
+	server := httptest.NewServer(stream)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	waitClients(t, stream, 1)
+
+	// The subscriber limit turns further clients away
+	second, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("second GET error: %v", err)
+	}
+	second.Body.Close()
+	if second.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("second client status = %d, want %d", second.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	stream.RecordEvent(registry.Event{Time: at, Type: registry.EventStatusChanged, Address: "10.0.0.1:9999", StatusCode: 2, PrevStatus: 1})
+
+	lines := bufio.NewScanner(resp.Body)
+	if !lines.Scan() {
+		t.Fatalf("stream closed before an event: %v", lines.Err())
+	}
+	want := `data: {"time":"2025-01-01T12:00:00Z","type":"status_changed","addr":"10.0.0.1:9999","status":"CRITICAL","prev_status":"WARN"}`
+	if got := lines.Text(); got != want {
+		t.Errorf("event = %q, want %q", got, want)
+	}
+	if !lines.Scan() || lines.Text() != "" {
+		t.Errorf("event not terminated by a blank line, got %q", lines.Text())
+	}
+
+	// A disconnect frees the slot
+	resp.Body.Close()
+	waitClients(t, stream, 0)
+}
+
+func TestEventStreamSlowClientDrops(t *testing.T) {
+	stream := NewEventStream(1)
+	ch := stream.subscribe()
+	if ch == nil {
+		t.Fatal("subscribe() = nil with room for a client")
+	}
+	for i := 0; i < streamBuffer+2; i++ {
+		stream.RecordEvent(registry.Event{Type: registry.EventJoined})
+	}
+	if got := stream.Dropped(); got != 2 {
+		t.Errorf("Dropped() = %d, want 2", got)
+	}
+	if stream.subscribe() != nil {
+		t.Error("subscribe() beyond the limit succeeded")
+	}
+	stream.unsubscribe(ch)
+}
+
+func TestEventStreamMethod(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewEventStream(1).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, EventStreamPath, strings.NewReader("")))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
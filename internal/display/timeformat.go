@@ -0,0 +1,66 @@
+package display
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// TimeFormat selects how report timestamps are written. Besides the named
+// formats it may be any Go time layout, e.g. "2006-01-02 15:04:05"
+type TimeFormat string
+
+const (
+	TimeRFC3339   TimeFormat = ""          // RFC 3339 with nanoseconds, the encoding/json default
+	TimeUnix      TimeFormat = "unix"      // Seconds since the Unix epoch, as a JSON number
+	TimeUnixMilli TimeFormat = "unixmilli" // Milliseconds since the Unix epoch, as a JSON number
+)
+
+// ParseTimeFormat parses the -time-format flag value: rfc3339, unix,
+// unixmilli, or a Go layout containing at least one layout element
+func ParseTimeFormat(s string) (TimeFormat, error) {
+	switch s {
+	case "", "rfc3339":
+		return TimeRFC3339, nil
+	case string(TimeUnix), string(TimeUnixMilli):
+		return TimeFormat(s), nil
+	}
+	// A layout without elements formats every time as itself
+	if time.Unix(0, 0).UTC().Format(s) == s {
+		return TimeRFC3339, errors.New("invalid time format " + strconv.Quote(s) + ": must be rfc3339, unix, unixmilli or a Go time layout")
+	}
+	return TimeFormat(s), nil
+}
+
+// Format renders t in the format, as it appears in human output
+func (f TimeFormat) Format(t time.Time) string {
+	switch f {
+	case TimeRFC3339:
+		return t.Format(time.RFC3339Nano)
+	case TimeUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case TimeUnixMilli:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	default:
+		return t.Format(string(f))
+	}
+}
+
+// formattedTime marshals a report timestamp in its TimeFormat
+type formattedTime struct {
+	time.Time
+	format TimeFormat
+}
+
+// MarshalJSON writes epoch formats as numbers and the others as strings
+func (t formattedTime) MarshalJSON() ([]byte, error) {
+	switch t.format {
+	case TimeRFC3339:
+		return t.Time.MarshalJSON()
+	case TimeUnix, TimeUnixMilli:
+		return []byte(t.format.Format(t.Time)), nil
+	default:
+		return json.Marshal(t.format.Format(t.Time))
+	}
+}
@@ -0,0 +1,105 @@
+package display
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+func TestParseTimeFormat(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want TimeFormat
+	}{
+		{"", TimeRFC3339},
+		{"rfc3339", TimeRFC3339},
+		{"unix", TimeUnix},
+		{"unixmilli", TimeUnixMilli},
+		{"2006-01-02 15:04", TimeFormat("2006-01-02 15:04")},
+	} {
+		if got, err := ParseTimeFormat(tc.in); err != nil || got != tc.want {
+			t.Errorf("ParseTimeFormat(%q) = %q, %v, want %q", tc.in, got, err, tc.want)
+		}
+	}
+	for _, bad := range []string{"iso", "epoch"} {
+		if _, err := ParseTimeFormat(bad); err == nil {
+			t.Errorf("ParseTimeFormat(%q) should return error", bad)
+		}
+	}
+}
+
+func TestReporterTimeFormat(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 600e6, time.UTC)
+	monitor := registry.NewMonitor()
+	monitor.SetClock(clock.NewFake(now.Add(-time.Second)))
+	monitor.UpdateWithTelemetry("10.0.0.1:9999", 10, 20, 30, 0)
+	monitor.UpdateWithStatus("10.0.0.2:9999", 0, 1)
+
+	testCases := []struct {
+		format TimeFormat
+		want   string // timestamp, last_seen is one second earlier
+		seen   string
+	}{
+		{TimeRFC3339, `"2025-01-02T03:04:05.6Z"`, `"2025-01-02T03:04:04.6Z"`},
+		{TimeUnix, `1735787045`, `1735787044`},
+		{TimeUnixMilli, `1735787045600`, `1735787044600`},
+		{"2006-01-02 15:04:05", `"2025-01-02 03:04:05"`, `"2025-01-02 03:04:04"`},
+	}
+	for _, tc := range testCases {
+		reporter := NewReporter(monitor, true)
+		reporter.clock = clock.NewFake(now)
+		reporter.SetTimeFormat(tc.format)
+		var buf bytes.Buffer
+		reporter.output = &buf
+		reporter.Report()
+
+		var raw struct {
+			Timestamp json.RawMessage                       `json:"timestamp"`
+			Nodes     map[string]map[string]json.RawMessage `json:"nodes"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+			t.Fatalf("%q: invalid JSON: %v", tc.format, err)
+		}
+		if string(raw.Timestamp) != tc.want {
+			t.Errorf("%q: timestamp = %s, want %s", tc.format, raw.Timestamp, tc.want)
+		}
+		for addr, node := range raw.Nodes {
+			if string(node["last_seen"]) != tc.seen {
+				t.Errorf("%q: %s last_seen = %s, want %s", tc.format, addr, node["last_seen"], tc.seen)
+			}
+		}
+		if string(raw.Nodes["10.0.0.1:9999"]["cpu_percent"]) != "10" {
+			t.Errorf("%q: telemetry lost: %v", tc.format, raw.Nodes["10.0.0.1:9999"])
+		}
+		if _, ok := raw.Nodes["10.0.0.2:9999"]["cpu_percent"]; ok {
+			t.Errorf("%q: status-only node has telemetry", tc.format)
+		}
+	}
+
+	reporter := NewReporter(monitor, false)
+	reporter.clock = clock.NewFake(now)
+	reporter.SetTimeFormat(TimeUnix)
+	var buf bytes.Buffer
+	reporter.output = &buf
+	reporter.Report()
+	if !strings.Contains(buf.String(), "(Nodes: 2) at 1735787045 ===") {
+		t.Errorf("human header missing report time:\n%s", buf.String())
+	}
+
+	// The status endpoint stays parseable for federation
+	rec := httptest.NewRecorder()
+	reporter.StatusHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, StatusPath, nil))
+	var report struct {
+		Timestamp time.Time `json:"timestamp"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || !report.Timestamp.Equal(now) {
+		t.Errorf("status endpoint timestamp = %v (%v), want %v in RFC 3339", report.Timestamp, err, now)
+	}
+}
@@ -0,0 +1,408 @@
+package display
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+// ANSI escape sequences used by the dashboard
+const (
+	ansiClear  = "\033[H\033[2J"
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiBlue   = "\033[34m"
+)
+
+// sortKey selects the column the dashboard table is sorted by
+type sortKey int
+
+const (
+	sortByAddress sortKey = iota
+	sortByStatus
+	sortByCPU
+	sortByRAM
+	sortByDisk
+	sortByAge
+	numSortKeys
+)
+
+// sortKeyNames are the column labels shown in the dashboard header
+var sortKeyNames = [numSortKeys]string{"address", "status", "cpu", "ram", "disk", "age"}
+
+// filterAll disables status filtering
+const filterAll = -1
+
+// Dashboard is a read-only terminal UI that redraws the node table in place
+type Dashboard struct {
+	monitor *registry.Monitor
+	input   io.Reader
+	output  io.Writer
+	mu      sync.Mutex
+	sortBy  sortKey
+	reverse bool
+	filter  int // Status code to show, or filterAll
+
+	// Count nodes without telemetry as 0% in the averages
+	averageNoTelemetry bool
+
+	// Reaper timeout for the REAP IN countdown (0 hides the column)
+	reapTimeout time.Duration
+
+	anonymizer *Anonymizer
+
+	// Nodes in the previous frame, and those that have since vanished,
+	// shown for offlineNoticeTTL
+	prev     map[string]registry.NodeInfo
+	vanished map[string]OfflineNode
+}
+
+// offlineNoticeTTL is how long the dashboard lists a node that went offline
+const offlineNoticeTTL = time.Minute
+
+// NewDashboard creates a dashboard reading keys from stdin and drawing to stdout
+func NewDashboard(monitor *registry.Monitor) *Dashboard {
+	return &Dashboard{
+		monitor: monitor,
+		input:   os.Stdin,
+		output:  os.Stdout,
+		filter:  filterAll,
+	}
+}
+
+// SetAverageNoTelemetry counts nodes that have never reported telemetry as
+// 0% in the fleet averages. By default only nodes with telemetry are counted,
+// so status-only nodes do not dilute them. Must be called before Run
+func (d *Dashboard) SetAverageNoTelemetry(include bool) {
+	d.averageNoTelemetry = include
+}
+
+// SetReapTimeout adds a REAP IN column counting down to each node's
+// removal by the timeout reaper. Must be called before Run
+func (d *Dashboard) SetReapTimeout(timeout time.Duration) {
+	d.reapTimeout = timeout
+}
+
+// SetAnonymizer shows node aliases instead of addresses, as in reports.
+// Must be called before Run
+func (d *Dashboard) SetAnonymizer(a *Anonymizer) {
+	d.anonymizer = a
+}
+
+// Run redraws the dashboard every refresh interval until ctx is cancelled
+// or the user presses q. Keys: s cycles the sort column, r reverses the
+// order, f cycles the status filter
+func (d *Dashboard) Run(ctx context.Context, refresh time.Duration) error {
+	// Put the terminal in raw mode so keys are read without Enter
+	if f, ok := d.input.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		state, err := term.MakeRaw(int(f.Fd()))
+		if err != nil {
+			return fmt.Errorf("failed to enter raw mode: %w", err)
+		}
+		defer term.Restore(int(f.Fd()), state)
+	}
+
+	keys := make(chan byte)
+	done := make(chan struct{})
+	defer close(done)
+	go readKeys(d.input, keys, done)
+
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+
+	d.Render()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			d.Render()
+		case key, ok := <-keys:
+			if !ok {
+				// Input closed - keep refreshing until cancelled
+				keys = nil
+				continue
+			}
+			if d.handleKey(key) {
+				return nil
+			}
+			d.Render()
+		}
+	}
+}
+
+// readKeys forwards single bytes from r until it fails or done is closed
+func readKeys(r io.Reader, keys chan<- byte, done <-chan struct{}) {
+	defer close(keys)
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return
+		}
+		select {
+		case keys <- b:
+		case <-done:
+			return
+		}
+	}
+}
+
+// handleKey applies a keyboard shortcut and reports whether to quit
+func (d *Dashboard) handleKey(key byte) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch key {
+	case 'q', 'Q', 3: // 3 is Ctrl-C in raw mode
+		return true
+	case 's':
+		d.sortBy = (d.sortBy + 1) % numSortKeys
+	case 'r':
+		d.reverse = !d.reverse
+	case 'f':
+		// Cycle all -> OK -> WARN -> CRITICAL -> all
+		d.filter++
+		if d.filter > 2 {
+			d.filter = filterAll
+		}
+	}
+	return false
+}
+
+// Render draws a single frame of the dashboard
+func (d *Dashboard) Render() {
+	d.mu.Lock()
+	sortBy, reverse, filter := d.sortBy, d.reverse, d.filter
+	d.mu.Unlock()
+
+	nodes := d.monitor.GetNodes()
+	if d.anonymizer != nil {
+		nodes = d.anonymizer.Nodes(nodes)
+	}
+	now := time.Now()
+	offline := d.trackOffline(nodes, now)
+
+	var sb strings.Builder
+	sb.WriteString(ansiClear)
+
+	// Aggregate stats
+	var ok, warn, critical, maintenance int
+	for _, info := range nodes {
+		if info.Silenced {
+			maintenance++
+		} else {
+			switch info.StatusCode {
+			case 0:
+				ok++
+			case 1:
+				warn++
+			default:
+				critical++
+			}
+		}
+	}
+
+	fmt.Fprintf(&sb, "%s=== PulseCheck Dashboard ===%s  %s\r\n", ansiBold, ansiReset, now.Format("15:04:05"))
+	fmt.Fprintf(&sb, "Nodes: %d | %sOK: %d%s | %sWARN: %d%s | %sCRITICAL: %d%s",
+		len(nodes), ansiGreen, ok, ansiReset, ansiYellow, warn, ansiReset, ansiRed, critical, ansiReset)
+	if maintenance > 0 {
+		fmt.Fprintf(&sb, " | %sMAINTENANCE: %d%s", ansiBlue, maintenance, ansiReset)
+	}
+	sb.WriteString("\r\n")
+	if avg := averageTelemetry(nodes, d.averageNoTelemetry); avg.Nodes > 0 {
+		fmt.Fprintf(&sb, "Avg CPU: %.1f%% | Avg RAM: %.1f%% | Avg Disk: %.1f%% (%d of %d nodes)\r\n",
+			avg.CPU, avg.RAM, avg.Disk, avg.Nodes, len(nodes))
+	}
+
+	order := "asc"
+	if reverse {
+		order = "desc"
+	}
+	filterName := "all"
+	if filter != filterAll {
+		filterName = statusCodeToString(uint8(filter))
+	}
+	fmt.Fprintf(&sb, "Sort: %s (%s) | Filter: %s | [s]ort [r]everse [f]ilter [q]uit\r\n\r\n", sortKeyNames[sortBy], order, filterName)
+
+	fmt.Fprintf(&sb, "%s%-28s %-11s %7s %7s %7s %8s", ansiBold, "ADDRESS", "STATUS", "CPU", "RAM", "DISK", "AGE")
+	if d.reapTimeout > 0 {
+		fmt.Fprintf(&sb, " %8s", "REAP IN")
+	}
+	sb.WriteString(ansiReset + "\r\n")
+
+	rows := make([]registry.NodeInfo, 0, len(nodes))
+	for addr, info := range nodes {
+		if filter != filterAll && int(info.StatusCode) != filter {
+			continue
+		}
+		info.Address = addr
+		rows = append(rows, info)
+	}
+	sortRows(rows, sortBy, reverse)
+
+	if len(rows) == 0 {
+		sb.WriteString("No matching nodes\r\n")
+	}
+	for _, info := range rows {
+		fmt.Fprintf(&sb, "%-28s %s%-11s%s %7s %7s %7s %8v",
+			info.Address,
+			nodeStatusColor(info), nodeStatusString(info), ansiReset,
+			percentCell(info, info.CPUPercent), percentCell(info, info.RAMPercent), percentCell(info, info.DiskPercent),
+			now.Sub(info.LastSeen).Round(time.Second))
+		if d.reapTimeout > 0 {
+			fmt.Fprintf(&sb, " %8v", reapIn(d.reapTimeout, info.LastSeen, now).Round(time.Second))
+		}
+		sb.WriteString("\r\n")
+	}
+
+	if len(offline) > 0 {
+		sb.WriteString("\r\n")
+	}
+	for _, o := range offline {
+		fmt.Fprintf(&sb, "%sOFFLINE (reaped)%s %s went offline at %s, last status %s\r\n",
+			ansiRed, ansiReset, o.Address, o.Time.Format("15:04:05"), o.Status)
+	}
+
+	io.WriteString(d.output, sb.String())
+}
+
+// trackOffline notes the nodes that vanished since the previous frame and
+// returns those that did so within offlineNoticeTTL, oldest first. A node
+// that comes back is no longer listed
+func (d *Dashboard) trackOffline(nodes map[string]registry.NodeInfo, now time.Time) []OfflineNode {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.vanished == nil {
+		d.vanished = make(map[string]OfflineNode)
+	}
+	for addr, info := range d.prev {
+		if _, ok := nodes[addr]; !ok {
+			d.vanished[addr] = OfflineNode{Address: addr, Time: now, Status: nodeStatusString(info)}
+		}
+	}
+	d.prev = nodes
+
+	offline := make([]OfflineNode, 0, len(d.vanished))
+	for addr, o := range d.vanished {
+		if _, back := nodes[addr]; back || now.Sub(o.Time) >= offlineNoticeTTL {
+			delete(d.vanished, addr)
+			continue
+		}
+		offline = append(offline, o)
+	}
+	sort.Slice(offline, func(i, j int) bool {
+		if !offline[i].Time.Equal(offline[j].Time) {
+			return offline[i].Time.Before(offline[j].Time)
+		}
+		return offline[i].Address < offline[j].Address
+	})
+	return offline
+}
+
+// telemetryAverage is the fleet-wide mean of each telemetry percentage
+type telemetryAverage struct {
+	CPU, RAM, Disk float64
+	Nodes          int // Nodes counted in the denominator
+}
+
+// averageTelemetry averages telemetry over the nodes that have reported it.
+// With includeNoTelemetry, nodes that never did are counted as 0%
+func averageTelemetry(nodes map[string]registry.NodeInfo, includeNoTelemetry bool) telemetryAverage {
+	var avg telemetryAverage
+	for _, info := range nodes {
+		if !info.HasTelemetry && !includeNoTelemetry {
+			continue
+		}
+		avg.Nodes++
+		if info.HasTelemetry {
+			avg.CPU += info.CPUPercent
+			avg.RAM += info.RAMPercent
+			avg.Disk += info.DiskPercent
+		}
+	}
+	if avg.Nodes > 0 {
+		n := float64(avg.Nodes)
+		avg.CPU, avg.RAM, avg.Disk = avg.CPU/n, avg.RAM/n, avg.Disk/n
+	}
+	return avg
+}
+
+// percentCell formats a telemetry percentage, or n/a for status-only nodes
+func percentCell(info registry.NodeInfo, percent float64) string {
+	if !info.HasTelemetry {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f%%", percent)
+}
+
+// sortRows orders rows by the given column, using address as a tiebreaker
+func sortRows(rows []registry.NodeInfo, by sortKey, reverse bool) {
+	less := func(a, b registry.NodeInfo) bool {
+		switch by {
+		case sortByStatus:
+			if a.StatusCode != b.StatusCode {
+				return a.StatusCode < b.StatusCode
+			}
+		case sortByCPU:
+			if a.CPUPercent != b.CPUPercent {
+				return a.CPUPercent < b.CPUPercent
+			}
+		case sortByRAM:
+			if a.RAMPercent != b.RAMPercent {
+				return a.RAMPercent < b.RAMPercent
+			}
+		case sortByDisk:
+			if a.DiskPercent != b.DiskPercent {
+				return a.DiskPercent < b.DiskPercent
+			}
+		case sortByAge:
+			// Older LastSeen means larger age
+			if !a.LastSeen.Equal(b.LastSeen) {
+				return a.LastSeen.After(b.LastSeen)
+			}
+		}
+		return a.Address < b.Address
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if reverse {
+			return less(rows[j], rows[i])
+		}
+		return less(rows[i], rows[j])
+	})
+}
+
+// nodeStatusColor returns the ANSI color for a node, accounting for maintenance
+func nodeStatusColor(info registry.NodeInfo) string {
+	if info.Silenced {
+		return ansiBlue
+	}
+	return statusColor(info.StatusCode)
+}
+
+// statusColor returns the ANSI color for a status code
+func statusColor(code uint8) string {
+	switch code {
+	case 0:
+		return ansiGreen
+	case 1:
+		return ansiYellow
+	default:
+		return ansiRed
+	}
+}
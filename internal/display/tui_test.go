@@ -0,0 +1,161 @@
+package display
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+func newTestDashboard(monitor *registry.Monitor) (*Dashboard, *bytes.Buffer) {
+	d := NewDashboard(monitor)
+	var buf bytes.Buffer
+	d.output = &buf
+	d.input = strings.NewReader("")
+	return d, &buf
+}
+
+func TestDashboardRender(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithTelemetry("192.168.1.100:9999", 75.5, 85.2, 90.1, 1)
+	monitor.UpdateWithStatus("192.168.1.101:9999", 2, time.Now().UnixNano())
+
+	d, buf := newTestDashboard(monitor)
+	d.Render()
+
+	output := buf.String()
+	for _, want := range []string{"Nodes: 2", "192.168.1.100:9999", "192.168.1.101:9999", "WARN", "CRITICAL", "Avg CPU: 75.5%"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Render() output missing %q", want)
+		}
+	}
+
+	// The status-only node has unknown telemetry, not 0%
+	for _, line := range strings.Split(output, "\r\n") {
+		if strings.HasPrefix(line, "192.168.1.101:9999") && strings.Count(line, "n/a") != 3 {
+			t.Errorf("status-only row = %q, want n/a for CPU, RAM and disk", line)
+		}
+		if strings.HasPrefix(line, "192.168.1.100:9999") && !strings.Contains(line, "  75.5%") {
+			t.Errorf("telemetry row = %q, want CPU 75.5%%", line)
+		}
+	}
+}
+
+func TestDashboardFilter(t *testing.T) {
+	monitor := registry.NewMonitor()
+	monitor.UpdateWithStatus("192.168.1.100:9999", 0, time.Now().UnixNano())
+	monitor.UpdateWithStatus("192.168.1.101:9999", 1, time.Now().UnixNano())
+
+	d, buf := newTestDashboard(monitor)
+
+	// all -> OK -> WARN
+	d.handleKey('f')
+	d.handleKey('f')
+	d.Render()
+
+	output := buf.String()
+	if !strings.Contains(output, "Filter: WARN") {
+		t.Error("Render() header should show WARN filter")
+	}
+	if strings.Contains(output, "192.168.1.100:9999") {
+		t.Error("Render() with WARN filter should hide OK node")
+	}
+	if !strings.Contains(output, "192.168.1.101:9999") {
+		t.Error("Render() with WARN filter should show WARN node")
+	}
+
+	// WARN -> CRITICAL -> all
+	d.handleKey('f')
+	d.handleKey('f')
+	if d.filter != filterAll {
+		t.Errorf("filter after full cycle = %d, want filterAll", d.filter)
+	}
+}
+
+func TestSortRows(t *testing.T) {
+	rows := []registry.NodeInfo{
+		{Address: "b", CPUPercent: 10, StatusCode: 2},
+		{Address: "a", CPUPercent: 30, StatusCode: 0},
+		{Address: "c", CPUPercent: 20, StatusCode: 1},
+	}
+
+	sortRows(rows, sortByAddress, false)
+	if rows[0].Address != "a" || rows[2].Address != "c" {
+		t.Errorf("sortRows(address) = %v", rows)
+	}
+
+	sortRows(rows, sortByCPU, true)
+	if rows[0].Address != "a" || rows[2].Address != "b" {
+		t.Errorf("sortRows(cpu, reverse) = %v", rows)
+	}
+
+	sortRows(rows, sortByStatus, false)
+	if rows[0].StatusCode != 0 || rows[2].StatusCode != 2 {
+		t.Errorf("sortRows(status) = %v", rows)
+	}
+}
+
+func TestDashboardHandleKey(t *testing.T) {
+	d, _ := newTestDashboard(registry.NewMonitor())
+
+	if d.handleKey('s') {
+		t.Error("handleKey('s') should not quit")
+	}
+	if d.sortBy != sortByStatus {
+		t.Errorf("sortBy after 's' = %d, want %d", d.sortBy, sortByStatus)
+	}
+	d.handleKey('r')
+	if !d.reverse {
+		t.Error("reverse after 'r' = false, want true")
+	}
+	if !d.handleKey('q') {
+		t.Error("handleKey('q') should quit")
+	}
+}
+
+func TestDashboardRunQuit(t *testing.T) {
+	d, buf := newTestDashboard(registry.NewMonitor())
+	d.input = strings.NewReader("q")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Run(context.Background(), time.Hour)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return after 'q'")
+	}
+
+	if !strings.Contains(buf.String(), "PulseCheck Dashboard") {
+		t.Error("Run() did not render the dashboard")
+	}
+}
+
+func TestAverageTelemetry(t *testing.T) {
+	nodes := map[string]registry.NodeInfo{
+		"a": {HasTelemetry: true, CPUPercent: 80, RAMPercent: 40, DiskPercent: 20},
+		"b": {HasTelemetry: true, CPUPercent: 40, RAMPercent: 20, DiskPercent: 10},
+		"c": {StatusCode: 1},
+		"d": {StatusCode: 0},
+	}
+
+	avg := averageTelemetry(nodes, false)
+	if avg != (telemetryAverage{CPU: 60, RAM: 30, Disk: 15, Nodes: 2}) {
+		t.Errorf("averageTelemetry() = %+v, want 60/30/15 over 2 nodes", avg)
+	}
+	avg = averageTelemetry(nodes, true)
+	if avg != (telemetryAverage{CPU: 30, RAM: 15, Disk: 7.5, Nodes: 4}) {
+		t.Errorf("averageTelemetry(include) = %+v, want 30/15/7.5 over 4 nodes", avg)
+	}
+	if avg := averageTelemetry(map[string]registry.NodeInfo{"c": {}}, false); avg.Nodes != 0 {
+		t.Errorf("averageTelemetry() without telemetry = %+v, want no nodes", avg)
+	}
+}
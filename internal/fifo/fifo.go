@@ -0,0 +1,181 @@
+// Package fifo ingests node telemetry written as JSON lines to a named pipe,
+// for local apps that can neither embed the library nor push over HTTP
+package fifo
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sync/atomic"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+	"github.com/rafaelmarinho/pulsecheck/internal/telemetry"
+)
+
+// maxLineSize bounds one record. Longer lines are skipped as malformed
+const maxLineSize = 64 * 1024
+
+var errLineTooLong = fmt.Errorf("line longer than %d bytes", maxLineSize)
+
+// Record is one line written to the FIFO, e.g.
+//
+//	{"node":"billing-app","status_code":1,"cpu_percent":72.5}
+//
+// Node keys the entry in reports. The metrics are optional; a record with
+// none of them updates only the status
+type Record struct {
+	Node        string   `json:"node"`
+	StatusCode  *uint8   `json:"status_code"`
+	CPUPercent  *float64 `json:"cpu_percent,omitempty"`
+	RAMPercent  *float64 `json:"ram_percent,omitempty"`
+	DiskPercent *float64 `json:"disk_percent,omitempty"`
+}
+
+// Stats counts the lines read from the FIFO
+type Stats struct {
+	Records     uint64 `json:"records"`      // Applied to the monitor
+	ParseErrors uint64 `json:"parse_errors"` // Skipped as malformed
+}
+
+// Reader records each line written to a FIFO in the monitor as a heartbeat
+// from the node it names. Nodes that stop writing are reaped like any other
+type Reader struct {
+	path    string
+	monitor *registry.Monitor
+	file    *os.File
+
+	records     atomic.Uint64
+	parseErrors atomic.Uint64
+	failures    *telemetry.FailureTracker // Only the reading goroutine touches this
+	running     atomic.Bool
+}
+
+// Open creates the FIFO at path if it does not exist, with mode 0660, and
+// opens it. An existing file that is not a FIFO is an error
+func Open(path string, monitor *registry.Monitor) (*Reader, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if err := mkfifo(path, 0o660); err != nil {
+			return nil, fmt.Errorf("failed to create FIFO: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open FIFO: %w", err)
+	} else if info.Mode()&fs.ModeNamedPipe == 0 {
+		return nil, fmt.Errorf("failed to open FIFO: %s exists and is not a named pipe", path)
+	}
+
+	// Opening for writing too keeps a writer attached, so the open does not
+	// wait for the first app and reads never see EOF between apps
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open FIFO: %w", err)
+	}
+	return &Reader{
+		path:     path,
+		monitor:  monitor,
+		file:     file,
+		failures: telemetry.NewFailureTracker(true),
+	}, nil
+}
+
+// Start reads records until Stop is called
+func (r *Reader) Start() {
+	if !r.running.CompareAndSwap(false, true) {
+		return
+	}
+	reader := bufio.NewReaderSize(r.file, maxLineSize)
+	for {
+		line, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				log.Printf("Failed to read FIFO %s: %v", r.path, err)
+			}
+			return
+		}
+		if isPrefix {
+			// Too long to be a record: drop the rest of the line
+			for isPrefix && err == nil {
+				_, isPrefix, err = reader.ReadLine()
+			}
+			r.parseError(errLineTooLong)
+			continue
+		}
+		if len(line) == 0 {
+			continue
+		}
+		rec, err := ParseRecord(line)
+		if err != nil {
+			r.parseError(err)
+			continue
+		}
+		r.apply(rec)
+	}
+}
+
+// parseError counts a malformed line, logging only the first of a run
+func (r *Reader) parseError(err error) {
+	r.parseErrors.Add(1)
+	if shouldLog, count := r.failures.Failure(); shouldLog {
+		log.Printf("Skipping malformed FIFO record (%d in a row): %v", count, err)
+	}
+}
+
+// apply records a parsed record in the monitor
+func (r *Reader) apply(rec Record) {
+	r.failures.Success()
+	r.records.Add(1)
+	if rec.CPUPercent == nil && rec.RAMPercent == nil && rec.DiskPercent == nil {
+		r.monitor.UpdateWithStatus(rec.Node, *rec.StatusCode, 0)
+		return
+	}
+	r.monitor.UpdateWithTelemetry(rec.Node, value(rec.CPUPercent), value(rec.RAMPercent), value(rec.DiskPercent), *rec.StatusCode)
+}
+
+// value returns *p, or 0 if p is nil
+func value(p *float64) float64 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+// ParseRecord decodes and validates one line
+func ParseRecord(line []byte) (Record, error) {
+	var rec Record
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return rec, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if rec.Node == "" {
+		return rec, errors.New("missing node")
+	}
+	if rec.StatusCode == nil {
+		return rec, errors.New("missing status_code")
+	}
+	if *rec.StatusCode > uint8(telemetry.StatusUnknown) {
+		return rec, fmt.Errorf("status_code %d out of range", *rec.StatusCode)
+	}
+	for _, m := range []struct {
+		name  string
+		value *float64
+	}{{"cpu_percent", rec.CPUPercent}, {"ram_percent", rec.RAMPercent}, {"disk_percent", rec.DiskPercent}} {
+		if m.value != nil && (*m.value < 0 || *m.value > 100) {
+			return rec, fmt.Errorf("%s %v out of range", m.name, *m.value)
+		}
+	}
+	return rec, nil
+}
+
+// Stats returns the number of records applied and skipped so far
+func (r *Reader) Stats() Stats {
+	return Stats{Records: r.records.Load(), ParseErrors: r.parseErrors.Load()}
+}
+
+// Stop closes the FIFO, which ends Start. It does not wait for Start to
+// return: on some platforms a read from a FIFO only ends at the next write
+func (r *Reader) Stop() error {
+	return r.file.Close()
+}
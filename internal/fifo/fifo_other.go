@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package fifo
+
+import "errors"
+
+// mkfifo reports that named pipes cannot be created on this platform
+func mkfifo(path string, mode uint32) error {
+	return errors.New("named pipes are not supported on this platform")
+}
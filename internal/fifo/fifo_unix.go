@@ -0,0 +1,10 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package fifo
+
+import "syscall"
+
+// mkfifo creates a named pipe at path
+func mkfifo(path string, mode uint32) error {
+	return syscall.Mkfifo(path, mode)
+}
@@ -0,0 +1,97 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package fifo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+func TestReaderIngestsRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.fifo")
+	monitor := registry.NewMonitor()
+	r, err := Open(path, monitor)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Stop()
+	go r.Start()
+
+	w, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open FIFO for writing: %v", err)
+	}
+	lines := []string{
+		`{"node":"billing-app","status_code":1,"cpu_percent":72.5,"ram_percent":40}`,
+		``,
+		`not json`,
+		`{"node":"billing-app"}`,
+		`{"node":"cron","status_code":0}`,
+		strings.Repeat("x", maxLineSize+10),
+		`{"node":"cron","status_code":9}`,
+	}
+	if _, err := w.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		t.Fatalf("write FIFO: %v", err)
+	}
+	// Apps come and go; the reader keeps going after a writer closes
+	w.Close()
+	w, err = os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("reopen FIFO for writing: %v", err)
+	}
+	w.WriteString(`{"node":"cron","status_code":2}` + "\n")
+	w.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for r.Stats().Records+r.Stats().ParseErrors < 7 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stats() = %+v, want 3 records and 4 parse errors", r.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, want := r.Stats(), (Stats{Records: 3, ParseErrors: 4}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	info, ok := monitor.GetNodeInfo("billing-app")
+	if !ok || info.StatusCode != 1 || !info.HasTelemetry || info.CPUPercent != 72.5 || info.RAMPercent != 40 {
+		t.Errorf("billing-app = %+v, %v, want WARN with its telemetry", info, ok)
+	}
+	info, ok = monitor.GetNodeInfo("cron")
+	if !ok || info.StatusCode != 2 || info.HasTelemetry {
+		t.Errorf("cron = %+v, %v, want CRITICAL without telemetry", info, ok)
+	}
+}
+
+func TestOpenRejectsRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, registry.NewMonitor()); err == nil {
+		t.Error("Open() should refuse a file that is not a FIFO")
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	for _, line := range []string{
+		`{"status_code":0}`,                              // Missing node
+		`{"node":"a","status_code":4}`,                   // Unknown status
+		`{"node":"a","status_code":-1}`,                  // Not a uint8
+		`{"node":"a","status_code":0,"cpu_percent":101}`, // Out of range
+		`{"node":"a","status_code":0,"disk_percent":-5}`,
+		`{"node":"a",`,
+	} {
+		if _, err := ParseRecord([]byte(line)); err == nil {
+			t.Errorf("ParseRecord(%s) should return error", line)
+		}
+	}
+	if _, err := ParseRecord([]byte(`{"node":"a","status_code":3,"disk_percent":0}`)); err != nil {
+		t.Errorf("ParseRecord() error = %v", err)
+	}
+}
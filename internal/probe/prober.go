@@ -0,0 +1,186 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+// Status codes reported for probed targets (same model as agent heartbeats)
+const (
+	statusOK       uint8 = 0
+	statusWarn     uint8 = 1
+	statusCritical uint8 = 2
+)
+
+// Target is an agentless endpoint polled by the prober
+type Target struct {
+	Scheme  string // "http", "https", or "tcp"
+	Address string // URL for HTTP targets, host:port for TCP targets
+}
+
+// String returns the target as it appears in reports (e.g. tcp://db:5432)
+func (t Target) String() string {
+	if t.Scheme == "tcp" {
+		return "tcp://" + t.Address
+	}
+	return t.Address
+}
+
+// ParseTarget parses a probe target such as http://host/health or tcp://host:5432
+func ParseTarget(s string) (Target, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Target{}, fmt.Errorf("invalid probe target %q: %w", s, err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return Target{}, fmt.Errorf("invalid probe target %q: missing host", s)
+		}
+		return Target{Scheme: u.Scheme, Address: s}, nil
+	case "tcp":
+		// Only validate the host:port shape - resolution happens on every probe
+		if _, port, err := net.SplitHostPort(u.Host); err != nil || port == "" {
+			return Target{}, fmt.Errorf("invalid probe target %q: expected tcp://host:port", s)
+		}
+		return Target{Scheme: "tcp", Address: u.Host}, nil
+	default:
+		return Target{}, fmt.Errorf("invalid probe target %q: scheme must be http, https, or tcp", s)
+	}
+}
+
+// ParseTargets parses a comma-separated list of probe targets
+func ParseTargets(list string) ([]Target, error) {
+	var targets []Target
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		t, err := ParseTarget(s)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// Prober actively polls targets that cannot run the agent and feeds the
+// results into the monitor alongside heartbeat-based nodes
+type Prober struct {
+	monitor     *registry.Monitor
+	targets     []Target
+	timeout     time.Duration
+	warnLatency time.Duration
+	client      *http.Client
+	stopChan    chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewProber creates a prober. Targets slower than warnLatency are reported as WARN,
+// unreachable or failing targets as CRITICAL
+func NewProber(monitor *registry.Monitor, targets []Target, timeout, warnLatency time.Duration) *Prober {
+	return &Prober{
+		monitor:     monitor,
+		targets:     targets,
+		timeout:     timeout,
+		warnLatency: warnLatency,
+		client:      &http.Client{Timeout: timeout},
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// Start polls all targets immediately and then on every interval
+func (p *Prober) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	p.ProbeAll()
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case <-ticker.C:
+			p.ProbeAll()
+		}
+	}
+}
+
+// Stop stops the prober. Safe to call more than once
+func (p *Prober) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopChan)
+	})
+}
+
+// ProbeAll polls every target concurrently and updates the monitor
+func (p *Prober) ProbeAll() {
+	var wg sync.WaitGroup
+	for _, target := range p.targets {
+		wg.Add(1)
+		go func(t Target) {
+			defer wg.Done()
+			latency, err := p.probe(t)
+			status := p.statusFor(latency, err)
+			if err != nil {
+				log.Printf("Probe %s failed: %v", t, err)
+			}
+			p.monitor.UpdateWithProbe(t.String(), status, latency)
+		}(target)
+	}
+	wg.Wait()
+}
+
+// statusFor maps a probe result to a status code
+func (p *Prober) statusFor(latency time.Duration, err error) uint8 {
+	if err != nil {
+		return statusCritical
+	}
+	if p.warnLatency > 0 && latency > p.warnLatency {
+		return statusWarn
+	}
+	return statusOK
+}
+
+// probe performs a single check and returns its latency
+func (p *Prober) probe(t Target) (time.Duration, error) {
+	start := time.Now()
+
+	if t.Scheme == "tcp" {
+		conn, err := net.DialTimeout("tcp", t.Address, p.timeout)
+		if err != nil {
+			return 0, err
+		}
+		conn.Close()
+		return time.Since(start), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.Address, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	latency := time.Since(start)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return latency, fmt.Errorf("unhealthy HTTP status %d", resp.StatusCode)
+	}
+	return latency, nil
+}
@@ -0,0 +1,154 @@
+package probe
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+func TestParseTarget(t *testing.T) {
+	testCases := []struct {
+		input   string
+		want    Target
+		wantErr bool
+	}{
+		{"http://10.0.0.5/health", Target{Scheme: "http", Address: "http://10.0.0.5/health"}, false},
+		{"https://db.example.com:8443/ping", Target{Scheme: "https", Address: "https://db.example.com:8443/ping"}, false},
+		{"tcp://10.0.0.5:5432", Target{Scheme: "tcp", Address: "10.0.0.5:5432"}, false},
+		{"tcp://[2001:db8::5]:5432", Target{Scheme: "tcp", Address: "[2001:db8::5]:5432"}, false},
+		{"tcp://10.0.0.5", Target{}, true},
+		{"udp://10.0.0.5:53", Target{}, true},
+		{"http:///health", Target{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseTarget(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("ParseTarget(%q) = %+v, want error", tc.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTarget(%q) error = %v", tc.input, err)
+			}
+			if got != tc.want {
+				t.Errorf("ParseTarget(%q) = %+v, want %+v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets("http://a/health, tcp://b:22,")
+	if err != nil {
+		t.Fatalf("ParseTargets() error = %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("ParseTargets() returned %d targets, want 2", len(targets))
+	}
+	if targets[1].String() != "tcp://b:22" {
+		t.Errorf("targets[1].String() = %s, want tcp://b:22", targets[1])
+	}
+
+	if targets, err := ParseTargets(""); err != nil || len(targets) != 0 {
+		t.Errorf("ParseTargets(\"\") = %v, %v, want no targets", targets, err)
+	}
+}
+
+func TestProberHTTP(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	monitor := registry.NewMonitor()
+	targets := []Target{
+		{Scheme: "http", Address: healthy.URL},
+		{Scheme: "http", Address: failing.URL},
+	}
+	p := NewProber(monitor, targets, time.Second, time.Second)
+	p.ProbeAll()
+
+	info, ok := monitor.GetNodeInfo(healthy.URL)
+	if !ok {
+		t.Fatal("ProbeAll() did not record healthy target")
+	}
+	if info.StatusCode != statusOK || !info.Probed {
+		t.Errorf("healthy target = status %d probed %v, want OK probed", info.StatusCode, info.Probed)
+	}
+
+	info, ok = monitor.GetNodeInfo(failing.URL)
+	if !ok {
+		t.Fatal("ProbeAll() did not record failing target")
+	}
+	if info.StatusCode != statusCritical {
+		t.Errorf("failing target status = %d, want CRITICAL", info.StatusCode)
+	}
+}
+
+func TestProberTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	addr := ln.Addr().String()
+
+	monitor := registry.NewMonitor()
+	p := NewProber(monitor, []Target{{Scheme: "tcp", Address: addr}}, time.Second, time.Second)
+	p.ProbeAll()
+
+	info, ok := monitor.GetNodeInfo("tcp://" + addr)
+	if !ok || info.StatusCode != statusOK {
+		t.Errorf("open TCP target = %+v (found %v), want OK", info, ok)
+	}
+
+	// Closing the listener makes the target unreachable
+	ln.Close()
+	p.ProbeAll()
+
+	info, _ = monitor.GetNodeInfo("tcp://" + addr)
+	if info.StatusCode != statusCritical {
+		t.Errorf("closed TCP target status = %d, want CRITICAL", info.StatusCode)
+	}
+}
+
+func TestProberStatusFor(t *testing.T) {
+	p := NewProber(registry.NewMonitor(), nil, time.Second, 100*time.Millisecond)
+
+	if got := p.statusFor(10*time.Millisecond, nil); got != statusOK {
+		t.Errorf("statusFor(fast) = %d, want OK", got)
+	}
+	if got := p.statusFor(200*time.Millisecond, nil); got != statusWarn {
+		t.Errorf("statusFor(slow) = %d, want WARN", got)
+	}
+	if got := p.statusFor(0, errors.New("refused")); got != statusCritical {
+		t.Errorf("statusFor(error) = %d, want CRITICAL", got)
+	}
+}
+
+func TestProberStopTwice(t *testing.T) {
+	p := NewProber(registry.NewMonitor(), nil, time.Second, 100*time.Millisecond)
+	p.Stop()
+	p.Stop()
+}
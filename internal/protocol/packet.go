@@ -3,73 +3,466 @@ package protocol
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"hash/crc32"
 	"time"
 )
 
 const (
-	PacketSize     = 30  // 26 bytes data + 4 bytes CRC32 checksum
-	PacketDataSize = 26  // Size of data before checksum
-	Version        = 1
+	// Version 6 packets are extensible: the fixed fields, then optional
+	// fields as type-length-value records, see Record. A new field is a new
+	// record type, which older v6 decoders skip, so it needs no new version
+	// and no new packet size
+	Version          = 6
+	MinPacketSizeV6  = 35  // 2 bytes magic + 29 bytes data + 4 bytes CRC32 checksum, no records
+	MaxPacketSizeV6  = 255 // The length byte bounds the whole packet
+	
+	// DefaultMagic prefixes v3 and later packets ("PC") unless Options.Magic is set
+	DefaultMagic = 0x5043
+	
+	// Version 5 packets are the last fixed layout and are still accepted on decode
+	PacketSizeV5     = 37 // 2 bytes magic + 31 bytes data + 4 bytes CRC32 checksum
+	PacketDataSizeV5 = 33
+	VersionV5        = 5
+	
+	// Version 4 packets predate Priority and are still accepted on decode
+	PacketSizeV4     = 36 // 2 bytes magic + 30 bytes data + 4 bytes CRC32 checksum
+	PacketDataSizeV4 = 32
+	VersionV4        = 4
+	
+	// Version 3 packets predate Interval and are still accepted on decode
+	PacketSizeV3     = 34 // 2 bytes magic + 28 bytes data + 4 bytes CRC32 checksum
+	PacketDataSizeV3 = 30
+	VersionV3        = 3
+	
+	// Version 2 packets predate the magic prefix and are still accepted on decode
+	PacketSizeV2     = 32 // 28 bytes data + 4 bytes CRC32 checksum
+	PacketDataSizeV2 = 28
+	VersionV2        = 2
+	
+	// Version 1 packets predate ListenPort and are still accepted on decode
+	PacketSizeV1     = 30 // 26 bytes data + 4 bytes CRC32 checksum
+	PacketDataSizeV1 = 26
+	VersionV1        = 1
+	
+	// MinPacketSize and MaxPacketSize bound the sizes accepted by Decode
+	MinPacketSize = PacketSizeV1
+	MaxPacketSize = MaxPacketSizeV6
+)
+
+// Record types of v6 packets. Each record is a type byte, a length byte and
+// that many bytes of value. Fields at their zero value are not sent
+const (
+	RecordInterval = 1 // uint16 Packet.Interval
+	RecordPriority = 2 // uint8 Packet.Priority
+	RecordSequence = 3 // uint32 Packet.Sequence
+	RecordFlags    = 4 // uint8 Packet.Flags
+)
+
+// Packet.Flags bits
+const (
+	// FlagNoLearn asks the receiver to record the sender without learning it
+	// as a peer, for synthetic traffic such as the load generator's
+	FlagNoLearn uint8 = 1 << iota
+)
+
+// recordHeaderSize is the type and length bytes before a record's value
+const recordHeaderSize = 2
+
+// v6HeaderSize is the magic, version and length bytes of a v6 packet
+const v6HeaderSize = 4
+
+// checksumSize is the length of the trailing CRC32
+const checksumSize = 4
+
+// magicSize is the length of the v3 and later magic prefix
+const magicSize = 2
+
+// IntervalUnit is the resolution of Packet.Interval
+const IntervalUnit = 100 * time.Millisecond
+
+// Decode errors, distinguishable with errors.Is
+var (
+	// ErrInvalidSize is returned for data that is not a v1 to v5 packet
+	// length, or for a v6 packet whose length byte or records do not add up
+	ErrInvalidSize = errors.New("invalid packet size")
+	
+	// ErrChecksumMismatch is returned when the CRC32 does not match the data
+	ErrChecksumMismatch = errors.New("packet checksum verification failed - packet may be corrupted")
+	
+	// ErrUnknownVersion is returned when the version byte does not match the packet layout
+	ErrUnknownVersion = errors.New("unknown packet version")
+	
+	// ErrBadMagic is returned for a v3 or later packet with a foreign magic prefix,
+	// or a v1/v2 packet when Options.RequireMagic is set. It is checked
+	// before the checksum, so stray datagrams are rejected cheaply
+	ErrBadMagic = errors.New("packet magic mismatch")
 )
 
-// Packet represents a 30-byte heartbeat packet (26 bytes data + 4 bytes CRC32)
+// Packet represents a heartbeat packet. Version selects the layout it is
+// encoded in; fields the layout predates are dropped
 type Packet struct {
 	Version    uint8
 	NodeUUID   [16]byte
-	Timestamp  int64
+	Timestamp  int64 // Sender's clock in Unix nanoseconds, see the README for the range
 	StatusCode uint8
-	Checksum   uint32 // CRC32 checksum of the first 26 bytes
+	ListenPort uint16 // Port the sender listens on (0 if unknown)
+	Interval   uint16 // Sender's heartbeat interval in IntervalUnits (0 if unknown)
+	Priority   uint8  // Operator-assigned preference, higher first (0 is the default)
+	Sequence   uint32 // Heartbeat counter of the sender, from 1 and wrapping past 0 (v6 only, 0 if unknown)
+	Flags      uint8  // FlagNoLearn and future bits (v6 only)
+	Checksum   uint32 // CRC32 checksum of the magic and data
+}
+
+// IntervalUnits converts a heartbeat interval to Packet.Interval, rounding
+// up so a short interval is never advertised as unknown and saturating at
+// the largest value the field holds
+func IntervalUnits(d time.Duration) uint16 {
+	if d <= 0 {
+		return 0
+	}
+	units := (d + IntervalUnit - 1) / IntervalUnit
+	if units > 0xFFFF {
+		return 0xFFFF
+	}
+	return uint16(units)
+}
+
+// HeartbeatInterval returns the advertised heartbeat interval, or 0 if the
+// sender did not advertise one
+func (p *Packet) HeartbeatInterval() time.Duration {
+	return time.Duration(p.Interval) * IntervalUnit
+}
+
+// Options controls packet encoding and decoding
+// Both peers must use the same options
+type Options struct {
+	// NoChecksum skips CRC32 computation and verification. The checksum
+	// bytes are sent as zeros so the packet size is unchanged. Only intended
+	// for benchmarking and reliable local links
+	NoChecksum bool
+	
+	// Magic is the prefix written to and expected on v3 and later packets, so
+	// unrelated applications sharing a port can be told apart (0 uses
+	// DefaultMagic)
+	Magic uint16
+	
+	// RequireMagic rejects v1 and v2 packets, which carry no magic, with
+	// ErrBadMagic. Leave it off while older peers are being upgraded
+	RequireMagic bool
+}
+
+// magic returns the magic prefix in effect
+func (o Options) magic() uint16 {
+	if o.Magic == 0 {
+		return DefaultMagic
+	}
+	return o.Magic
 }
 
-// Encode encodes a packet into exactly 30 bytes (26 bytes data + 4 bytes CRC32)
+// Encode encodes a packet into Size bytes
 func (p *Packet) Encode() ([]byte, error) {
-	buf := make([]byte, PacketSize)
+	return p.EncodeWith(Options{})
+}
+
+// EncodeWith encodes a packet using the given options
+func (p *Packet) EncodeWith(opts Options) ([]byte, error) {
+	buf := make([]byte, MaxPacketSize)
+	if err := p.EncodeIntoWith(buf, opts); err != nil {
+		return nil, err
+	}
+	return buf[:p.Size()], nil
+}
+
+// Size returns the encoded length of the packet in the layout of its
+// Version, or 0 for a version that cannot be encoded
+func (p *Packet) Size() int {
+	switch p.Version {
+	case Version:
+		size := MinPacketSizeV6
+		if p.Interval != 0 {
+			size += recordHeaderSize + 2
+		}
+		if p.Priority != 0 {
+			size += recordHeaderSize + 1
+		}
+		if p.Sequence != 0 {
+			size += recordHeaderSize + 4
+		}
+		if p.Flags != 0 {
+			size += recordHeaderSize + 1
+		}
+		return size
+	case VersionV5:
+		return PacketSizeV5
+	case VersionV4:
+		return PacketSizeV4
+	case VersionV3:
+		return PacketSizeV3
+	case VersionV2:
+		return PacketSizeV2
+	case VersionV1:
+		return PacketSizeV1
+	}
+	return 0
+}
+
+// EncodeInto encodes a packet into the first Size bytes of dst without allocating
+func (p *Packet) EncodeInto(dst []byte) error {
+	return p.EncodeIntoWith(dst, Options{})
+}
+
+// EncodeIntoWith encodes a packet into dst using the given options, in the
+// layout of p.Version. Older layouts drop the fields they predate, so a
+// node can keep talking to peers that do not decode the newest one yet
+func (p *Packet) EncodeIntoWith(dst []byte, opts Options) error {
+	size := p.Size()
+	if size == 0 {
+		return fmt.Errorf("%w: cannot encode version %d", ErrUnknownVersion, p.Version)
+	}
+	if len(dst) < size {
+		return errors.New("buffer too small for packet")
+	}
+	buf := dst[:size]
+	dataSize := size - checksumSize
 	
-	// Pack data fields (first 26 bytes)
-	buf[0] = p.Version
-	copy(buf[1:17], p.NodeUUID[:])
-	binary.BigEndian.PutUint64(buf[17:25], uint64(p.Timestamp))
-	buf[25] = p.StatusCode
+	if p.Version == Version {
+		p.encodeV6(buf, opts)
+	} else {
+		p.encodeFixed(buf, opts)
+	}
+	
+	if opts.NoChecksum {
+		p.Checksum = 0
+		binary.BigEndian.PutUint32(buf[dataSize:size], 0)
+		return nil
+	}
 	
-	// Calculate CRC32 checksum over the data portion (first 26 bytes)
-	checksum := crc32.ChecksumIEEE(buf[0:PacketDataSize])
+	// Calculate CRC32 checksum over the magic and data
+	checksum := crc32.ChecksumIEEE(buf[0:dataSize])
 	p.Checksum = checksum
 	
 	// Append checksum (last 4 bytes)
-	binary.BigEndian.PutUint32(buf[PacketDataSize:PacketSize], checksum)
+	binary.BigEndian.PutUint32(buf[dataSize:size], checksum)
+	
+	return nil
+}
+
+// encodeV6 packs the fixed fields and records of a v6 packet into buf,
+// leaving the checksum
+func (p *Packet) encodeV6(buf []byte, opts Options) {
+	binary.BigEndian.PutUint16(buf[0:2], opts.magic())
+	buf[2] = p.Version
+	buf[3] = uint8(len(buf))
+	copy(buf[4:20], p.NodeUUID[:])
+	binary.BigEndian.PutUint64(buf[20:28], uint64(p.Timestamp))
+	buf[28] = p.StatusCode
+	binary.BigEndian.PutUint16(buf[29:31], p.ListenPort)
 	
-	return buf, nil
+	records := buf[31:]
+	if p.Interval != 0 {
+		records[0], records[1] = RecordInterval, 2
+		binary.BigEndian.PutUint16(records[2:4], p.Interval)
+		records = records[4:]
+	}
+	if p.Priority != 0 {
+		records[0], records[1] = RecordPriority, 1
+		records[2] = p.Priority
+		records = records[3:]
+	}
+	if p.Sequence != 0 {
+		records[0], records[1] = RecordSequence, 4
+		binary.BigEndian.PutUint32(records[2:6], p.Sequence)
+		records = records[6:]
+	}
+	if p.Flags != 0 {
+		records[0], records[1] = RecordFlags, 1
+		records[2] = p.Flags
+	}
+}
+
+// encodeFixed packs the fields of a v1 to v5 packet into buf, leaving the
+// checksum
+func (p *Packet) encodeFixed(buf []byte, opts Options) {
+	// Pack the magic, from v3 on, and the data fields
+	data := buf
+	if p.Version >= VersionV3 {
+		binary.BigEndian.PutUint16(buf[0:2], opts.magic())
+		data = buf[magicSize:]
+	}
+	data[0] = p.Version
+	copy(data[1:17], p.NodeUUID[:])
+	binary.BigEndian.PutUint64(data[17:25], uint64(p.Timestamp))
+	data[25] = p.StatusCode
+	if p.Version >= VersionV2 {
+		binary.BigEndian.PutUint16(data[26:28], p.ListenPort)
+	}
+	if p.Version >= VersionV4 {
+		binary.BigEndian.PutUint16(data[28:30], p.Interval)
+	}
+	if p.Version >= VersionV5 {
+		data[30] = p.Priority
+	}
+}
+
+// isV6 reports whether data looks like a v6 packet. Earlier layouts that
+// are at least as long carry their own version in the same byte
+func isV6(data []byte) bool {
+	return len(data) >= MinPacketSizeV6 && data[magicSize] == Version
 }
 
-// Decode decodes a 30-byte buffer into a packet and verifies CRC32 checksum
+// Decode decodes a v1 (30-byte), v2 (32-byte), v3 (34-byte), v4 (36-byte), v5 (37-byte) or v6 (35 to 255-byte) buffer into a packet and verifies CRC32 checksum
 func Decode(data []byte) (*Packet, error) {
-	if len(data) != PacketSize {
-		return nil, errors.New("invalid packet size")
+	return DecodeWith(data, Options{})
+}
+
+// DecodeWith decodes a packet using the given options
+func DecodeWith(data []byte, opts Options) (*Packet, error) {
+	p := &Packet{}
+	if err := DecodeIntoWith(p, data, opts); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DecodeInto decodes data into an existing packet without allocating
+func DecodeInto(p *Packet, data []byte) error {
+	return DecodeIntoWith(p, data, Options{})
+}
+
+// DecodeIntoWith decodes data into an existing packet using the given options
+// Arbitrary input returns an error rather than panicking, since data comes
+// straight off the network. On error p is left unchanged
+func DecodeIntoWith(p *Packet, data []byte, opts Options) error {
+	if isV6(data) {
+		return decodeV6(p, data, opts)
+	}
+	
+	// The size selects the layout; the version byte must then agree with it
+	var dataSize, offset int
+	var version uint8
+	switch len(data) {
+	case PacketSizeV5:
+		dataSize, offset, version = PacketDataSizeV5, magicSize, VersionV5
+	case PacketSizeV4:
+		dataSize, offset, version = PacketDataSizeV4, magicSize, VersionV4
+	case PacketSizeV3:
+		dataSize, offset, version = PacketDataSizeV3, magicSize, VersionV3
+	case PacketSizeV2:
+		dataSize, version = PacketDataSizeV2, VersionV2
+	case PacketSizeV1:
+		dataSize, version = PacketDataSizeV1, VersionV1
+	default:
+		return ErrInvalidSize
+	}
+	
+	// The magic is the cheapest check, so foreign traffic fails it first
+	if offset > 0 {
+		if magic := binary.BigEndian.Uint16(data[0:magicSize]); magic != opts.magic() {
+			return fmt.Errorf("%w: got %#04x, want %#04x", ErrBadMagic, magic, opts.magic())
+		}
+	} else if opts.RequireMagic {
+		return fmt.Errorf("%w: v%d packet has no magic", ErrBadMagic, version)
 	}
 	
 	// Extract checksum from last 4 bytes
-	receivedChecksum := binary.BigEndian.Uint32(data[PacketDataSize:PacketSize])
+	receivedChecksum := binary.BigEndian.Uint32(data[dataSize : dataSize+checksumSize])
 	
-	// Calculate expected checksum over data portion (first 26 bytes)
-	expectedChecksum := crc32.ChecksumIEEE(data[0:PacketDataSize])
+	if !opts.NoChecksum {
+		// Calculate expected checksum over data portion
+		expectedChecksum := crc32.ChecksumIEEE(data[0:dataSize])
+		
+		// Verify checksum
+		if receivedChecksum != expectedChecksum {
+			return ErrChecksumMismatch
+		}
+	}
 	
-	// Verify checksum
-	if receivedChecksum != expectedChecksum {
-		return nil, errors.New("packet checksum verification failed - packet may be corrupted")
+	fields := data[offset:]
+	if fields[0] != version {
+		return fmt.Errorf("%w: %d does not match %d-byte layout", ErrUnknownVersion, fields[0], len(data))
 	}
 	
-	// Decode packet fields
-	p := &Packet{
-		Version:    data[0],
-		Timestamp:  int64(binary.BigEndian.Uint64(data[17:25])),
-		StatusCode: data[25],
+	// Decode packet fields, resetting any left over from a previous decode
+	*p = Packet{
+		Version:    fields[0],
+		Timestamp:  int64(binary.BigEndian.Uint64(fields[17:25])),
+		StatusCode: fields[25],
 		Checksum:   receivedChecksum,
 	}
+	if version >= 2 {
+		p.ListenPort = binary.BigEndian.Uint16(fields[26:28])
+	}
+	if version >= 4 {
+		p.Interval = binary.BigEndian.Uint16(fields[28:30])
+	}
+	if version >= 5 {
+		p.Priority = fields[30]
+	}
 	
-	copy(p.NodeUUID[:], data[1:17])
+	copy(p.NodeUUID[:], fields[1:17])
 	
-	return p, nil
+	return nil
+}
+
+// decodeV6 decodes a v6 packet, skipping records of unknown types
+func decodeV6(p *Packet, data []byte, opts Options) error {
+	if magic := binary.BigEndian.Uint16(data[0:magicSize]); magic != opts.magic() {
+		return fmt.Errorf("%w: got %#04x, want %#04x", ErrBadMagic, magic, opts.magic())
+	}
+	if int(data[3]) != len(data) {
+		return fmt.Errorf("%w: v6 length byte %d does not match %d bytes", ErrInvalidSize, data[3], len(data))
+	}
+	
+	dataSize := len(data) - checksumSize
+	receivedChecksum := binary.BigEndian.Uint32(data[dataSize:])
+	if !opts.NoChecksum && receivedChecksum != crc32.ChecksumIEEE(data[:dataSize]) {
+		return ErrChecksumMismatch
+	}
+	
+	// Check every record before touching p, so that on error it is unchanged
+	decoded := Packet{
+		Version:    Version,
+		Timestamp:  int64(binary.BigEndian.Uint64(data[20:28])),
+		StatusCode: data[28],
+		ListenPort: binary.BigEndian.Uint16(data[29:31]),
+		Checksum:   receivedChecksum,
+	}
+	copy(decoded.NodeUUID[:], data[4:20])
+	
+	records := data[31:dataSize]
+	for len(records) > 0 {
+		if len(records) < recordHeaderSize || len(records) < recordHeaderSize+int(records[1]) {
+			return fmt.Errorf("%w: truncated v6 record", ErrInvalidSize)
+		}
+		kind, value := records[0], records[recordHeaderSize:recordHeaderSize+int(records[1])]
+		records = records[recordHeaderSize+len(value):]
+		switch kind {
+		case RecordInterval:
+			if len(value) != 2 {
+				return fmt.Errorf("%w: interval record of %d bytes", ErrInvalidSize, len(value))
+			}
+			decoded.Interval = binary.BigEndian.Uint16(value)
+		case RecordPriority:
+			if len(value) != 1 {
+				return fmt.Errorf("%w: priority record of %d bytes", ErrInvalidSize, len(value))
+			}
+			decoded.Priority = value[0]
+		case RecordSequence:
+			if len(value) != 4 {
+				return fmt.Errorf("%w: sequence record of %d bytes", ErrInvalidSize, len(value))
+			}
+			decoded.Sequence = binary.BigEndian.Uint32(value)
+		case RecordFlags:
+			if len(value) != 1 {
+				return fmt.Errorf("%w: flags record of %d bytes", ErrInvalidSize, len(value))
+			}
+			decoded.Flags = value[0]
+		}
+		// Other types are fields from newer senders
+	}
+	*p = decoded
+	return nil
 }
 
 // NewPacket creates a new packet with current timestamp
@@ -1,19 +1,26 @@
 package protocol
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math"
 	"testing"
 	"time"
 )
 
-func TestPacketEncode(t *testing.T) {
+func TestPacketEncodeV5(t *testing.T) {
 	var nodeUUID [16]byte
 	copy(nodeUUID[:], "test-node-uuid-01")
 
 	pkt := &Packet{
-		Version:    Version,
+		Version:    VersionV5,
 		NodeUUID:   nodeUUID,
 		Timestamp:  1234567890123456789,
 		StatusCode: 0,
+		Interval:   50,
+		Priority:   7,
 	}
 
 	data, err := pkt.Encode()
@@ -21,30 +28,45 @@ func TestPacketEncode(t *testing.T) {
 		t.Fatalf("Encode() error = %v", err)
 	}
 
-	if len(data) != PacketSize {
-		t.Errorf("Encode() length = %d, want %d", len(data), PacketSize)
+	if len(data) != PacketSizeV5 {
+		t.Errorf("Encode() length = %d, want %d", len(data), PacketSizeV5)
+	}
+
+	// Verify magic
+	if magic := binary.BigEndian.Uint16(data[0:2]); magic != DefaultMagic {
+		t.Errorf("Encode() magic = %#04x, want %#04x", magic, DefaultMagic)
 	}
 
 	// Verify version
-	if data[0] != Version {
-		t.Errorf("Encode() version = %d, want %d", data[0], Version)
+	if data[2] != VersionV5 {
+		t.Errorf("Encode() version = %d, want %d", data[2], VersionV5)
 	}
 
 	// Verify UUID
 	for i := 0; i < 16; i++ {
-		if data[1+i] != nodeUUID[i] {
-			t.Errorf("Encode() UUID[%d] = %d, want %d", i, data[1+i], nodeUUID[i])
+		if data[3+i] != nodeUUID[i] {
+			t.Errorf("Encode() UUID[%d] = %d, want %d", i, data[3+i], nodeUUID[i])
 		}
 	}
 
 	// Verify status code
-	if data[25] != 0 {
-		t.Errorf("Encode() status code = %d, want 0", data[25])
+	if data[27] != 0 {
+		t.Errorf("Encode() status code = %d, want 0", data[27])
+	}
+
+	// Verify interval
+	if interval := binary.BigEndian.Uint16(data[30:32]); interval != 50 {
+		t.Errorf("Encode() interval = %d, want 50", interval)
+	}
+
+	// Verify priority
+	if data[32] != 7 {
+		t.Errorf("Encode() priority = %d, want 7", data[32])
 	}
 
 	// Verify checksum is present (last 4 bytes should not be all zeros)
 	hasChecksum := false
-	for i := PacketDataSize; i < PacketSize; i++ {
+	for i := PacketDataSizeV5; i < PacketSizeV5; i++ {
 		if data[i] != 0 {
 			hasChecksum = true
 			break
@@ -64,6 +86,7 @@ func TestPacketDecode(t *testing.T) {
 		NodeUUID:   nodeUUID,
 		Timestamp:  9876543210987654,
 		StatusCode: 1,
+		ListenPort: 10001,
 	}
 
 	data, err := pkt.Encode()
@@ -91,14 +114,18 @@ func TestPacketDecode(t *testing.T) {
 	if decoded.StatusCode != 1 {
 		t.Errorf("Decode() StatusCode = %d, want 1", decoded.StatusCode)
 	}
+
+	if decoded.ListenPort != 10001 {
+		t.Errorf("Decode() ListenPort = %d, want 10001", decoded.ListenPort)
+	}
 }
 
 func TestPacketDecodeInvalidSize(t *testing.T) {
 	invalidData := make([]byte, 20) // Wrong size
 
 	_, err := Decode(invalidData)
-	if err == nil {
-		t.Error("Decode() should return error for invalid packet size")
+	if !errors.Is(err, ErrInvalidSize) {
+		t.Errorf("Decode() error = %v, want ErrInvalidSize", err)
 	}
 }
 
@@ -119,11 +146,11 @@ func TestPacketDecodeCorruptedChecksum(t *testing.T) {
 	}
 
 	// Corrupt the checksum
-	data[PacketSize-1] ^= 0xFF
+	data[len(data)-1] ^= 0xFF
 
 	_, err = Decode(data)
-	if err == nil {
-		t.Error("Decode() should return error for corrupted checksum")
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Decode() error = %v, want ErrChecksumMismatch", err)
 	}
 }
 
@@ -204,3 +231,636 @@ func TestNewPacket(t *testing.T) {
 			pkt.Timestamp, before.UnixNano(), after.UnixNano())
 	}
 }
+
+func TestPacketNoChecksum(t *testing.T) {
+	var nodeUUID [16]byte
+	copy(nodeUUID[:], "no-checksum-test")
+
+	pkt := NewPacket(nodeUUID, 1)
+	opts := Options{NoChecksum: true}
+
+	data, err := pkt.EncodeWith(opts)
+	if err != nil {
+		t.Fatalf("EncodeWith() error = %v", err)
+	}
+
+	if len(data) != MinPacketSizeV6 {
+		t.Errorf("EncodeWith() length = %d, want %d", len(data), MinPacketSizeV6)
+	}
+
+	for i := len(data) - 4; i < len(data); i++ {
+		if data[i] != 0 {
+			t.Fatal("EncodeWith(NoChecksum) should leave checksum bytes zeroed")
+		}
+	}
+
+	decoded, err := DecodeWith(data, opts)
+	if err != nil {
+		t.Fatalf("DecodeWith() error = %v", err)
+	}
+	if decoded.StatusCode != 1 || decoded.NodeUUID != nodeUUID {
+		t.Errorf("DecodeWith() = %+v, want status 1 and matching UUID", decoded)
+	}
+
+	// A checksum-verifying peer must reject packets sent without a checksum
+	if _, err := Decode(data); err == nil {
+		t.Error("Decode() should reject a packet encoded without checksum")
+	}
+}
+
+func TestPacketTimestampRange(t *testing.T) {
+	y2038 := time.Date(2038, 1, 19, 3, 14, 7, 0, time.UTC) // Last second of int32 Unix time
+
+	testCases := []struct {
+		name      string
+		timestamp int64
+	}{
+		{"epoch", 0},
+		{"before epoch", -1},
+		{"int32 seconds max", y2038.UnixNano()},
+		{"int32 seconds overflow", y2038.Add(time.Second).UnixNano()},
+		{"uint32 seconds overflow", int64(math.MaxUint32+1) * int64(time.Second)},
+		{"uint32 nanoseconds overflow", math.MaxUint32 + 1},
+		{"max", math.MaxInt64},
+		{"min", math.MinInt64},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pkt := &Packet{Version: Version, Timestamp: tc.timestamp}
+			data, err := pkt.Encode()
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			decoded, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if decoded.Timestamp != tc.timestamp {
+				t.Errorf("Decode() v2 Timestamp = %d, want %d", decoded.Timestamp, tc.timestamp)
+			}
+
+			decoded, err = Decode(encodeV1([16]byte{}, tc.timestamp, 0))
+			if err != nil {
+				t.Fatalf("Decode() v1 error = %v", err)
+			}
+			if decoded.Timestamp != tc.timestamp {
+				t.Errorf("Decode() v1 Timestamp = %d, want %d", decoded.Timestamp, tc.timestamp)
+			}
+		})
+	}
+
+	// The full int64 range maps back to the documented calendar limits
+	if got := time.Unix(0, math.MaxInt64).UTC(); got.Year() != 2262 {
+		t.Errorf("max timestamp = %v, want year 2262", got)
+	}
+	if got := time.Unix(0, math.MinInt64).UTC(); got.Year() != 1677 {
+		t.Errorf("min timestamp = %v, want year 1677", got)
+	}
+	if got := time.Unix(0, y2038.Add(time.Second).UnixNano()).UTC(); !got.Equal(y2038.Add(time.Second)) {
+		t.Errorf("post-2038 timestamp = %v, want %v", got, y2038.Add(time.Second))
+	}
+}
+
+// encodeV1 builds a legacy 30-byte version 1 packet
+func encodeV1(nodeUUID [16]byte, timestamp int64, statusCode uint8) []byte {
+	buf := make([]byte, PacketSizeV1)
+	buf[0] = VersionV1
+	copy(buf[1:17], nodeUUID[:])
+	binary.BigEndian.PutUint64(buf[17:25], uint64(timestamp))
+	buf[25] = statusCode
+	binary.BigEndian.PutUint32(buf[PacketDataSizeV1:], crc32.ChecksumIEEE(buf[:PacketDataSizeV1]))
+	return buf
+}
+
+func TestPacketDecodeV1(t *testing.T) {
+	var nodeUUID [16]byte
+	copy(nodeUUID[:], "legacy-node")
+
+	decoded, err := Decode(encodeV1(nodeUUID, 1234567890, 2))
+	if err != nil {
+		t.Fatalf("Decode() v1 error = %v", err)
+	}
+	if decoded.Version != VersionV1 || decoded.NodeUUID != nodeUUID ||
+		decoded.Timestamp != 1234567890 || decoded.StatusCode != 2 || decoded.ListenPort != 0 {
+		t.Errorf("Decode() v1 = %+v", decoded)
+	}
+}
+
+// encodeV2 builds a legacy 32-byte version 2 packet, which has no magic
+func encodeV2(nodeUUID [16]byte, timestamp int64, statusCode uint8, listenPort uint16) []byte {
+	buf := make([]byte, PacketSizeV2)
+	buf[0] = VersionV2
+	copy(buf[1:17], nodeUUID[:])
+	binary.BigEndian.PutUint64(buf[17:25], uint64(timestamp))
+	buf[25] = statusCode
+	binary.BigEndian.PutUint16(buf[26:28], listenPort)
+	binary.BigEndian.PutUint32(buf[PacketDataSizeV2:], crc32.ChecksumIEEE(buf[:PacketDataSizeV2]))
+	return buf
+}
+
+func TestPacketDecodeV2(t *testing.T) {
+	var nodeUUID [16]byte
+	copy(nodeUUID[:], "v2-node")
+
+	decoded, err := Decode(encodeV2(nodeUUID, 1234567890, 1, 9999))
+	if err != nil {
+		t.Fatalf("Decode() v2 error = %v", err)
+	}
+	if decoded.Version != VersionV2 || decoded.NodeUUID != nodeUUID ||
+		decoded.Timestamp != 1234567890 || decoded.StatusCode != 1 || decoded.ListenPort != 9999 {
+		t.Errorf("Decode() v2 = %+v", decoded)
+	}
+}
+
+// encodeV3 builds a 34-byte version 3 packet, which has no interval
+func encodeV3(nodeUUID [16]byte, timestamp int64, statusCode uint8, listenPort uint16) []byte {
+	buf := make([]byte, PacketSizeV3)
+	binary.BigEndian.PutUint16(buf[0:2], DefaultMagic)
+	copy(buf[2:], encodeV2(nodeUUID, timestamp, statusCode, listenPort)[:PacketDataSizeV2])
+	buf[2] = VersionV3
+	binary.BigEndian.PutUint32(buf[PacketDataSizeV3:], crc32.ChecksumIEEE(buf[:PacketDataSizeV3]))
+	return buf
+}
+
+func TestPacketDecodeV3(t *testing.T) {
+	var nodeUUID [16]byte
+	copy(nodeUUID[:], "v3-node")
+
+	for _, opts := range []Options{{}, {RequireMagic: true}} {
+		decoded, err := DecodeWith(encodeV3(nodeUUID, 1234567890, 2, 9999), opts)
+		if err != nil {
+			t.Fatalf("DecodeWith(%+v) v3 error = %v", opts, err)
+		}
+		if decoded.Version != VersionV3 || decoded.NodeUUID != nodeUUID || decoded.Timestamp != 1234567890 ||
+			decoded.StatusCode != 2 || decoded.ListenPort != 9999 || decoded.Interval != 0 {
+			t.Errorf("DecodeWith(%+v) v3 = %+v", opts, decoded)
+		}
+	}
+}
+
+// encodeV4 builds a 36-byte version 4 packet, which has no priority
+func encodeV4(nodeUUID [16]byte, timestamp int64, statusCode uint8, listenPort, interval uint16) []byte {
+	buf := make([]byte, PacketSizeV4)
+	copy(buf, encodeV3(nodeUUID, timestamp, statusCode, listenPort)[:PacketDataSizeV3])
+	buf[2] = VersionV4
+	binary.BigEndian.PutUint16(buf[30:32], interval)
+	binary.BigEndian.PutUint32(buf[PacketDataSizeV4:], crc32.ChecksumIEEE(buf[:PacketDataSizeV4]))
+	return buf
+}
+
+func TestPacketDecodeV4(t *testing.T) {
+	var nodeUUID [16]byte
+	copy(nodeUUID[:], "v4-node")
+
+	for _, opts := range []Options{{}, {RequireMagic: true}} {
+		decoded, err := DecodeWith(encodeV4(nodeUUID, 1234567890, 1, 9999, 50), opts)
+		if err != nil {
+			t.Fatalf("DecodeWith(%+v) v4 error = %v", opts, err)
+		}
+		if decoded.Version != VersionV4 || decoded.NodeUUID != nodeUUID || decoded.Timestamp != 1234567890 ||
+			decoded.StatusCode != 1 || decoded.ListenPort != 9999 || decoded.Interval != 50 || decoded.Priority != 0 {
+			t.Errorf("DecodeWith(%+v) v4 = %+v", opts, decoded)
+		}
+	}
+}
+
+func TestPacketEncodeOlderVersions(t *testing.T) {
+	var nodeUUID [16]byte
+	copy(nodeUUID[:], "compat-node")
+	want := map[uint8][]byte{
+		VersionV4: encodeV4(nodeUUID, 1234567890, 1, 9999, 50),
+		VersionV3: encodeV3(nodeUUID, 1234567890, 1, 9999),
+		VersionV2: encodeV2(nodeUUID, 1234567890, 1, 9999),
+		VersionV1: encodeV1(nodeUUID, 1234567890, 1),
+	}
+	for version, legacy := range want {
+		// Fields the layout predates are dropped
+		pkt := &Packet{Version: version, NodeUUID: nodeUUID, Timestamp: 1234567890, StatusCode: 1,
+			ListenPort: 9999, Interval: 50, Priority: 7}
+		data, err := pkt.Encode()
+		if err != nil {
+			t.Fatalf("Encode() v%d error = %v", version, err)
+		}
+		if !bytes.Equal(data, legacy) {
+			t.Errorf("Encode() v%d = %x, want %x", version, data, legacy)
+		}
+	}
+
+	if _, err := (&Packet{Version: 99}).Encode(); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Encode() of version 99 error = %v, want ErrUnknownVersion", err)
+	}
+}
+
+// withRecord appends a record to an encoded v6 packet, fixing up the
+// length byte and checksum
+func withRecord(data []byte, kind uint8, value []byte) []byte {
+	return withRecordBytes(data, append([]byte{kind, uint8(len(value))}, value...))
+}
+
+// withRecordBytes appends raw record bytes to an encoded v6 packet, fixing
+// up the length byte and checksum
+func withRecordBytes(data, record []byte) []byte {
+	out := append([]byte(nil), data[:len(data)-4]...)
+	out = append(out, record...)
+	out = append(out, 0, 0, 0, 0)
+	out[3] = uint8(len(out))
+	binary.BigEndian.PutUint32(out[len(out)-4:], crc32.ChecksumIEEE(out[:len(out)-4]))
+	return out
+}
+
+func TestPacketV6(t *testing.T) {
+	var nodeUUID [16]byte
+	copy(nodeUUID[:], "v6-node")
+	pkt := &Packet{Version: Version, NodeUUID: nodeUUID, Timestamp: 1234567890, StatusCode: 1, ListenPort: 9999}
+
+	// Zero optional fields are left out
+	data, err := pkt.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if len(data) != MinPacketSizeV6 || data[2] != Version || int(data[3]) != len(data) {
+		t.Fatalf("Encode() = %x, want a bare %d-byte v6 packet", data, MinPacketSizeV6)
+	}
+
+	pkt.Interval, pkt.Priority, pkt.Sequence, pkt.Flags = 50, 7, 0x01020304, FlagNoLearn
+	data, err = pkt.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if len(data) != MinPacketSizeV6+16 {
+		t.Errorf("Encode() length = %d, want %d", len(data), MinPacketSizeV6+16)
+	}
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if *decoded != *pkt {
+		t.Errorf("Decode() = %+v, want %+v", decoded, pkt)
+	}
+
+	// Records from newer senders are skipped
+	decoded, err = Decode(withRecord(data, 99, []byte("future field")))
+	if err != nil {
+		t.Fatalf("Decode() with an unknown record error = %v", err)
+	}
+	if decoded.Interval != 50 || decoded.Priority != 7 || decoded.Sequence != 0x01020304 || decoded.Flags != FlagNoLearn || decoded.NodeUUID != nodeUUID {
+		t.Errorf("Decode() with an unknown record = %+v", decoded)
+	}
+
+	// Malformed records and lengths are rejected
+	for name, bad := range map[string][]byte{
+		"wrong interval size": withRecord(data, RecordInterval, []byte{1}),
+		"wrong sequence size": withRecord(data, RecordSequence, []byte{1, 2}),
+		"wrong flags size":    withRecord(data, RecordFlags, []byte{1, 2}),
+		"truncated record":    withRecordBytes(data, []byte{99, 10, 1}),
+		"length byte":         append(append([]byte(nil), data...), 0),
+	} {
+		if _, err := Decode(bad); !errors.Is(err, ErrInvalidSize) {
+			t.Errorf("Decode() with %s error = %v, want ErrInvalidSize", name, err)
+		}
+	}
+}
+
+func TestPacketInterval(t *testing.T) {
+	testCases := []struct {
+		d    time.Duration
+		want uint16
+	}{
+		{0, 0},
+		{-time.Second, 0},
+		{time.Millisecond, 1}, // Never rounds down to unknown
+		{5 * time.Second, 50},
+		{5050 * time.Millisecond, 51},
+		{24 * time.Hour, 0xFFFF}, // Saturates
+	}
+	for _, tc := range testCases {
+		if got := IntervalUnits(tc.d); got != tc.want {
+			t.Errorf("IntervalUnits(%v) = %d, want %d", tc.d, got, tc.want)
+		}
+	}
+
+	pkt := NewPacket([16]byte{1}, 0)
+	pkt.Interval = IntervalUnits(5 * time.Second)
+	data, err := pkt.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got := decoded.HeartbeatInterval(); got != 5*time.Second {
+		t.Errorf("HeartbeatInterval() = %v, want 5s", got)
+	}
+}
+
+func TestPacketMagic(t *testing.T) {
+	pkt := NewPacket([16]byte{1}, 0)
+	data, err := pkt.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	// Foreign traffic fails on the magic even though its checksum is also wrong
+	foreign := append([]byte(nil), data...)
+	foreign[0] ^= 0xFF
+	if _, err := Decode(foreign); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("Decode(foreign magic) error = %v, want ErrBadMagic", err)
+	}
+
+	// A custom magic must match on both ends
+	custom := Options{Magic: 0xBEEF}
+	data, err = pkt.EncodeWith(custom)
+	if err != nil {
+		t.Fatalf("EncodeWith() error = %v", err)
+	}
+	if binary.BigEndian.Uint16(data) != 0xBEEF {
+		t.Errorf("EncodeWith() magic = %#04x, want 0xbeef", binary.BigEndian.Uint16(data))
+	}
+	if _, err := DecodeWith(data, custom); err != nil {
+		t.Errorf("DecodeWith(custom magic) error = %v", err)
+	}
+	if _, err := Decode(data); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("Decode(custom magic) with the default error = %v, want ErrBadMagic", err)
+	}
+
+	// Legacy packets carry no magic and are rejected only on request
+	for _, legacy := range [][]byte{encodeV2([16]byte{}, 1, 0, 9999), encodeV1([16]byte{}, 1, 0)} {
+		if _, err := Decode(legacy); err != nil {
+			t.Errorf("Decode(%d-byte legacy) error = %v", len(legacy), err)
+		}
+		if _, err := DecodeWith(legacy, Options{RequireMagic: true}); !errors.Is(err, ErrBadMagic) {
+			t.Errorf("DecodeWith(%d-byte legacy, RequireMagic) error = %v, want ErrBadMagic", len(legacy), err)
+		}
+	}
+}
+
+func TestPacketDecodeVersionMismatch(t *testing.T) {
+	// A well-formed 37-byte packet claiming to be version 1
+	data, err := (&Packet{Version: VersionV5}).Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	data[2] = VersionV1
+	binary.BigEndian.PutUint32(data[PacketDataSizeV5:], crc32.ChecksumIEEE(data[:PacketDataSizeV5]))
+	if _, err := Decode(data); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Decode() error = %v, want ErrUnknownVersion", err)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	var nodeUUID [16]byte
+	copy(nodeUUID[:], "fuzz-node")
+	pkt := NewPacket(nodeUUID, 1)
+	pkt.ListenPort = 9999
+	pkt.Interval = 50
+	pkt.Priority = 3
+	v6, _ := pkt.Encode()
+	pkt.Version = VersionV5
+	v5, _ := pkt.Encode()
+
+	f.Add(v6)
+	f.Add(withRecord(v6, 99, []byte{1, 2, 3}))
+	f.Add(v5)
+	f.Add(encodeV4(nodeUUID, time.Now().UnixNano(), 1, 9999, 50))
+	f.Add(encodeV3(nodeUUID, time.Now().UnixNano(), 1, 9999))
+	f.Add(encodeV2(nodeUUID, time.Now().UnixNano(), 1, 9999))
+	f.Add(encodeV1(nodeUUID, time.Now().UnixNano(), 2))
+	f.Add([]byte{})
+	f.Add([]byte{Version})
+	f.Add(v5[:PacketSizeV1])
+	f.Add(append(v5, 0))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, opts := range []Options{{}, {NoChecksum: true}, {RequireMagic: true}} {
+			decoded, err := DecodeWith(data, opts)
+			if err != nil {
+				continue
+			}
+			if decoded.Version == Version {
+				if len(data) < MinPacketSizeV6 || len(data) > MaxPacketSizeV6 || int(data[3]) != len(data) {
+					t.Fatalf("DecodeWith() accepted a %d-byte v6 packet", len(data))
+				}
+			} else if len(data) != PacketSizeV5 && len(data) != PacketSizeV4 && len(data) != PacketSizeV3 &&
+				len(data) != PacketSizeV2 && len(data) != PacketSizeV1 {
+				t.Fatalf("DecodeWith() accepted %d-byte input", len(data))
+			}
+			if opts.RequireMagic && (len(data) == PacketSizeV2 || len(data) == PacketSizeV1) && decoded.Version < VersionV3 {
+				t.Fatalf("DecodeWith() accepted a %d-byte packet without magic", len(data))
+			}
+			if opts.NoChecksum {
+				continue
+			}
+			encoded, err := decoded.Encode()
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			// v5 has one encoding. A v6 packet may carry records from newer
+			// senders, which are dropped, but the fields must survive
+			if decoded.Version == VersionV5 && !bytes.Equal(encoded, data) {
+				t.Fatalf("round trip mismatch: %x != %x", encoded, data)
+			}
+			if decoded.Version == Version {
+				again, err := Decode(encoded)
+				if err != nil {
+					t.Fatalf("Decode() of re-encoded packet error = %v", err)
+				}
+				again.Checksum = decoded.Checksum
+				if *again != *decoded {
+					t.Fatalf("round trip mismatch: %+v != %+v", again, decoded)
+				}
+			}
+		}
+	})
+}
+
+func benchmarkRoundTrip(b *testing.B, opts Options) {
+	var nodeUUID [16]byte
+	copy(nodeUUID[:], "bench-node")
+	pkt := NewPacket(nodeUUID, 0)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := pkt.EncodeWith(opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := DecodeWith(data, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRoundTripChecksum(b *testing.B) {
+	benchmarkRoundTrip(b, Options{})
+}
+
+func BenchmarkRoundTripNoChecksum(b *testing.B) {
+	benchmarkRoundTrip(b, Options{NoChecksum: true})
+}
+
+func TestPacketEncodeInto(t *testing.T) {
+	var nodeUUID [16]byte
+	copy(nodeUUID[:], "encode-into")
+	pkt := NewPacket(nodeUUID, 2)
+	pkt.ListenPort = 9999
+
+	want, err := pkt.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	// Stale bytes in a reused buffer must be overwritten
+	buf := bytes.Repeat([]byte{0xFF}, len(want)+8)
+	if err := pkt.EncodeInto(buf); err != nil {
+		t.Fatalf("EncodeInto() error = %v", err)
+	}
+	if !bytes.Equal(buf[:len(want)], want) {
+		t.Errorf("EncodeInto() = %x, want %x", buf[:len(want)], want)
+	}
+
+	if err := pkt.EncodeIntoWith(buf, Options{NoChecksum: true}); err != nil {
+		t.Fatalf("EncodeIntoWith() error = %v", err)
+	}
+	for i := len(want) - 4; i < len(want); i++ {
+		if buf[i] != 0 {
+			t.Fatal("EncodeIntoWith(NoChecksum) should zero checksum bytes in a reused buffer")
+		}
+	}
+
+	if err := pkt.EncodeInto(make([]byte, len(want)-1)); err == nil {
+		t.Error("EncodeInto() should return error for a short buffer")
+	}
+}
+
+func TestPacketEncodeIntoAllocs(t *testing.T) {
+	pkt := NewPacket([16]byte{}, 0)
+	buf := make([]byte, MaxPacketSize)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		pkt.EncodeInto(buf)
+	})
+	if allocs != 0 {
+		t.Errorf("EncodeInto() allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	pkt := NewPacket([16]byte{}, 0)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := pkt.Encode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeInto(b *testing.B) {
+	pkt := NewPacket([16]byte{}, 0)
+	buf := make([]byte, MaxPacketSize)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := pkt.EncodeInto(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	data, err := NewPacket([16]byte{}, 0).Encode()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRoundTrip(b *testing.B) {
+	pkt := NewPacket([16]byte{}, 0)
+	buf := make([]byte, pkt.Size())
+	var decoded Packet
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := pkt.EncodeInto(buf); err != nil {
+			b.Fatal(err)
+		}
+		if err := DecodeInto(&decoded, buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPacketDecodeInto(t *testing.T) {
+	var nodeUUID [16]byte
+	copy(nodeUUID[:], "decode-into")
+	src := NewPacket(nodeUUID, 1)
+	src.ListenPort = 9999
+	v2, err := src.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	var pkt Packet
+	if err := DecodeInto(&pkt, v2); err != nil {
+		t.Fatalf("DecodeInto() error = %v", err)
+	}
+	if pkt != *src {
+		t.Errorf("DecodeInto() = %+v, want %+v", pkt, *src)
+	}
+
+	// Reusing the packet for a v1 decode must not leak the old listen port
+	if err := DecodeInto(&pkt, encodeV1(nodeUUID, 42, 2)); err != nil {
+		t.Fatalf("DecodeInto() v1 error = %v", err)
+	}
+	if pkt.ListenPort != 0 || pkt.Timestamp != 42 || pkt.StatusCode != 2 {
+		t.Errorf("DecodeInto() v1 = %+v, want zero listen port", pkt)
+	}
+
+	// A failed decode leaves the packet untouched
+	before := pkt
+	if err := DecodeInto(&pkt, v2[:10]); err == nil {
+		t.Error("DecodeInto() should return error for short input")
+	}
+	if pkt != before {
+		t.Error("DecodeInto() modified the packet on error")
+	}
+}
+
+func TestPacketDecodeIntoAllocs(t *testing.T) {
+	data, err := NewPacket([16]byte{}, 0).Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	var pkt Packet
+
+	allocs := testing.AllocsPerRun(100, func() {
+		DecodeInto(&pkt, data)
+	})
+	if allocs != 0 {
+		t.Errorf("DecodeInto() allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkDecodeInto(b *testing.B) {
+	data, err := NewPacket([16]byte{}, 0).Encode()
+	if err != nil {
+		b.Fatal(err)
+	}
+	var pkt Packet
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := DecodeInto(&pkt, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"errors"
+)
+
+const (
+	// SignatureSize is the length of the Ed25519 signature trailing a signed packet
+	SignatureSize = ed25519.SignatureSize
+
+	// MaxSignedPacketSize bounds a signed packet: any packet is followed
+	// by an Ed25519 signature over all of its bytes, magic and checksum
+	// included. A v6 packet's length byte tells where the signature starts
+	MaxSignedPacketSize = MaxPacketSize + SignatureSize
+
+	// SignedPacketSizeV5 is a signed v5 packet, still accepted on receive
+	SignedPacketSizeV5 = PacketSizeV5 + SignatureSize
+
+	// SignedPacketSizeV4 is a signed v4 packet, still accepted on receive
+	SignedPacketSizeV4 = PacketSizeV4 + SignatureSize
+
+	// SignedPacketSizeV3 is a signed v3 packet, still accepted on receive
+	SignedPacketSizeV3 = PacketSizeV3 + SignatureSize
+
+	// SignedPacketSizeV2 is a signed v2 packet, still accepted on receive
+	SignedPacketSizeV2 = PacketSizeV2 + SignatureSize
+)
+
+// ErrUnsigned is returned when verification is required but the packet has no signature
+var ErrUnsigned = errors.New("packet is not signed")
+
+// ErrBadSignature is returned when a packet signature does not verify
+var ErrBadSignature = errors.New("packet signature verification failed")
+
+// Sign signs the size-byte packet encoded at the start of buf, writing the
+// signature to buf[size:size+SignatureSize]. Only v2 and later packets can
+// be signed, since SplitSignature does not recognize a signed v1 one
+func Sign(buf []byte, size int, key ed25519.PrivateKey) error {
+	if len(buf) < size+SignatureSize {
+		return errors.New("buffer too small for signed packet")
+	}
+	if size < PacketSizeV2 {
+		return errors.New("v1 packets cannot be signed")
+	}
+	copy(buf[size:size+SignatureSize], ed25519.Sign(key, buf[:size]))
+	return nil
+}
+
+// SplitSignature separates a received datagram into the packet bytes and
+// its signature. Unsigned datagrams return a nil signature
+func SplitSignature(data []byte) (packet, signature []byte) {
+	if isV6(data) {
+		if size := int(data[3]); len(data) == size+SignatureSize {
+			return data[:size], data[size:]
+		}
+		return data, nil
+	}
+	switch len(data) {
+	case SignedPacketSizeV5:
+		return data[:PacketSizeV5], data[PacketSizeV5:]
+	case SignedPacketSizeV4:
+		return data[:PacketSizeV4], data[PacketSizeV4:]
+	case SignedPacketSizeV3:
+		return data[:PacketSizeV3], data[PacketSizeV3:]
+	case SignedPacketSizeV2:
+		return data[:PacketSizeV2], data[PacketSizeV2:]
+	}
+	return data, nil
+}
+
+// Verify checks a signature returned by SplitSignature against the sender's public key
+func Verify(packet, signature []byte, key ed25519.PublicKey) error {
+	if signature == nil {
+		return ErrUnsigned
+	}
+	if !ed25519.Verify(key, packet, signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+
+	var nodeUUID [16]byte
+	copy(nodeUUID[:], "signed-node")
+	pkt := NewPacket(nodeUUID, 1)
+	pkt.Interval = 50
+	size := pkt.Size()
+	buf := make([]byte, MaxSignedPacketSize)
+	if err := pkt.EncodeInto(buf); err != nil {
+		t.Fatalf("EncodeInto() error = %v", err)
+	}
+	if err := Sign(buf, size, priv); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	buf = buf[:size+SignatureSize]
+
+	packet, signature := SplitSignature(buf)
+	if len(packet) != size || len(signature) != SignatureSize {
+		t.Fatalf("SplitSignature() = %d/%d bytes, want %d/%d", len(packet), len(signature), size, SignatureSize)
+	}
+	if err := Verify(packet, signature, pub); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err := Verify(packet, signature, otherPub); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Verify() with wrong key error = %v, want ErrBadSignature", err)
+	}
+
+	// Any change to the packet, checksum included, invalidates the signature
+	tampered := append([]byte(nil), buf...)
+	tampered[25] = 0
+	packet, signature = SplitSignature(tampered)
+	if err := Verify(packet, signature, pub); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Verify() of tampered packet error = %v, want ErrBadSignature", err)
+	}
+
+	packet, signature = SplitSignature(buf[:size])
+	if signature != nil {
+		t.Error("SplitSignature() of unsigned packet returned a signature")
+	}
+	if err := Verify(packet, signature, pub); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Verify() of unsigned packet error = %v, want ErrUnsigned", err)
+	}
+
+	// Older layouts are signed too, for nodes sending them to older peers
+	pkt.Version = VersionV4
+	if err := pkt.EncodeInto(buf); err != nil {
+		t.Fatalf("EncodeInto() v4 error = %v", err)
+	}
+	if err := Sign(buf, PacketSizeV4, priv); err != nil {
+		t.Fatalf("Sign() v4 error = %v", err)
+	}
+	packet, signature = SplitSignature(buf[:SignedPacketSizeV4])
+	if len(packet) != PacketSizeV4 || Verify(packet, signature, pub) != nil {
+		t.Errorf("signed v4 packet did not verify")
+	}
+}
+
+func TestSignRejectsV1(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(nil)
+	buf := make([]byte, SignedPacketSizeV5)
+	buf[0] = VersionV1
+	if err := Sign(buf, PacketSizeV1, priv); err == nil {
+		t.Error("Sign() should reject v1 packets")
+	}
+	if err := Sign(buf[:PacketSizeV5], PacketSizeV5, priv); err == nil {
+		t.Error("Sign() should reject a buffer without room for the signature")
+	}
+}
@@ -0,0 +1,36 @@
+package registry
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// CanonicalAddr returns addr with an IPv4-mapped IPv6 host written as plain
+// IPv4, e.g. "[::ffff:192.168.1.5]:9999" as "192.168.1.5:9999", so a node on
+// a dual-stack host is keyed once whichever socket family it was seen on.
+// Other addresses, including unparsable ones, are returned unchanged
+func CanonicalAddr(addr string) string {
+	// Cheap check first: this runs for every heartbeat
+	if !strings.Contains(addr, "ffff:") && !strings.Contains(addr, "FFFF:") {
+		return addr
+	}
+	i := strings.LastIndexByte(addr, ':')
+	ip, err := netip.ParseAddr(strings.Trim(addr[:i], "[]"))
+	if err != nil || !ip.Is4In6() {
+		return addr
+	}
+	return net.JoinHostPort(ip.Unmap().String(), addr[i+1:])
+}
+
+// IsUnspecifiedAddr reports whether addr's host is the unspecified address,
+// e.g. "[::]:9999" or "0.0.0.0:9999". A collector keys its own entry by its
+// listening address, which is only meaningful to that collector
+func IsUnspecifiedAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsUnspecified()
+}
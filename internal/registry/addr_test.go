@@ -0,0 +1,99 @@
+package registry
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/protocol"
+)
+
+func TestCanonicalAddr(t *testing.T) {
+	tests := map[string]string{
+		"[::ffff:192.168.1.5]:9999": "192.168.1.5:9999",
+		"[::FFFF:192.168.1.5]:9999": "192.168.1.5:9999",
+		"[::ffff:c0a8:105]:9999":    "192.168.1.5:9999",
+		"::ffff:192.168.1.5:9999":   "192.168.1.5:9999",
+		"192.168.1.5:9999":          "192.168.1.5:9999",
+		"[fd00::ffff:1]:9999":       "[fd00::ffff:1]:9999", // Not IPv4-mapped
+		"[2001:db8::1]:9999":        "[2001:db8::1]:9999",
+		"node-a.example:9999":       "node-a.example:9999",
+		"ffff:":                     "ffff:",
+	}
+	for addr, want := range tests {
+		if got := CanonicalAddr(addr); got != want {
+			t.Errorf("CanonicalAddr(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestIsUnspecifiedAddr(t *testing.T) {
+	tests := map[string]bool{
+		"[::]:9999":          true,
+		"0.0.0.0:9999":       true,
+		"10.0.0.1:9999":      false,
+		"[2001:db8::1]:9999": false,
+		"node-a:9999":        false,
+		"[::]":               false,
+	}
+	for addr, want := range tests {
+		if got := IsUnspecifiedAddr(addr); got != want {
+			t.Errorf("IsUnspecifiedAddr(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestMonitorCollapsesIPv4MappedAddr(t *testing.T) {
+	m := NewMonitor()
+	m.UpdateWithStatus("192.168.1.5:9999", 0, 1)
+	m.UpdateWithTelemetry("[::ffff:192.168.1.5]:9999", 40, 50, 60, 1)
+
+	if got := m.GetNodeCount(); got != 1 {
+		t.Fatalf("GetNodeCount() = %d, want 1 for both forms of one address", got)
+	}
+	for _, addr := range []string{"192.168.1.5:9999", "[::ffff:192.168.1.5]:9999"} {
+		info, ok := m.GetNodeInfo(addr)
+		if !ok || info.StatusCode != 1 || info.CPUPercent != 40 {
+			t.Errorf("GetNodeInfo(%q) = %+v, %v, want the merged node", addr, info, ok)
+		}
+	}
+	if _, ok := m.GetNodes()["192.168.1.5:9999"]; !ok {
+		t.Errorf("GetNodes() keys = %v, want the plain IPv4 form", m.GetNodes())
+	}
+}
+
+func TestHandlePacketIPv4MappedSource(t *testing.T) {
+	monitor := NewMonitor()
+	node, err := NewUDPNode(0, [16]byte{1}, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	send := func(src *net.UDPAddr, timestamp int64) {
+		t.Helper()
+		pkt := protocol.NewPacket([16]byte{2}, 0)
+		pkt.ListenPort = 9999
+		pkt.Timestamp = timestamp
+		data, err := pkt.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		node.handlePacket(data, src)
+	}
+
+	// The same host seen through an IPv4 and a dual-stack IPv6 socket
+	send(&net.UDPAddr{IP: net.IPv4(192, 168, 1, 5).To4(), Port: 40000}, 100)
+	mapped := netip.MustParseAddr("::ffff:192.168.1.5").As16()
+	send(&net.UDPAddr{IP: mapped[:], Port: 40001}, 200)
+
+	if got := monitor.GetNodeCount(); got != 1 {
+		t.Errorf("GetNodeCount() = %d, want 1", got)
+	}
+	if _, ok := monitor.GetNodeInfo("192.168.1.5:9999"); !ok {
+		t.Error("handlePacket() did not register the node at its IPv4 address")
+	}
+	if peers := node.Peers(); len(peers) != 1 {
+		t.Errorf("Peers() = %v, want one peer", peers)
+	}
+}
@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"container/list"
+	"time"
+)
+
+// DefaultMaxNodes is the default cap on tracked nodes, room for every
+// learned peer (DefaultMaxPeers) several times over, plus probed, relayed
+// and federated nodes
+const DefaultMaxNodes = 1 << 18
+
+// SetMaxNodes caps the nodes the monitor tracks at about n, so heartbeats
+// from spoofed source addresses, relayed reports or a federated view
+// cannot grow it without bound between reaper runs. The cap is split
+// evenly across the shards, so a shard may fill slightly before n nodes
+// are tracked. A new node in a full shard evicts the one seen least
+// recently there, which emits EventLeft and is counted in
+// ReaperStats.NodesEvicted. Each shard keeps its nodes in recency order,
+// so eviction takes constant time however full it is. Merged and
+// imported nodes older than the oldest in a full shard are dropped
+// instead. 0 is unbounded
+// Must be called before the monitor is used
+func (m *Monitor) SetMaxNodes(n int) {
+	m.shardCap = (n + numShards - 1) / numShards
+}
+
+// makeRoom makes room in s for a new node last seen at lastSeen, evicting
+// the node s updated least recently when it is full. It returns false, and
+// evicts nothing, when that node was seen after lastSeen
+// Caller must hold the shard write lock
+func (m *Monitor) makeRoom(s *shard, lastSeen time.Time) bool {
+	if m.shardCap <= 0 || len(s.nodes) < m.shardCap || s.order == nil || s.order.Len() == 0 {
+		return true
+	}
+	oldest := s.order.Back().Value.(string)
+	oldestInfo := s.nodes[oldest]
+	if oldestInfo.LastSeen.After(lastSeen) {
+		return false
+	}
+	s.remove(oldest)
+	m.markReaped(oldest)
+	m.recordEviction()
+	m.emit(Event{Time: lastSeen, Type: EventLeft, Address: oldest, StatusCode: oldestInfo.StatusCode})
+	return true
+}
+
+// touch places addr, just stored as last seen at lastSeen, in s's recency
+// order, which runs from the node seen most recently at the front to the
+// one seen least recently at the back. A live update goes straight to the
+// front. A merged or imported node may carry an older LastSeen, so it is
+// placed by it, searching from the back, and does not outlive nodes seen
+// after it
+// Caller must hold the shard write lock
+func (m *Monitor) touch(s *shard, addr string, lastSeen time.Time) {
+	if m.shardCap <= 0 {
+		return
+	}
+	if s.order == nil {
+		s.order = list.New()
+		s.elems = make(map[string]*list.Element)
+	}
+	elem, ok := s.elems[addr]
+	if front := s.order.Front(); front == nil || front == elem || !lastSeen.Before(s.nodes[front.Value.(string)].LastSeen) {
+		if ok {
+			s.order.MoveToFront(elem)
+		} else {
+			s.elems[addr] = s.order.PushFront(addr)
+		}
+		return
+	}
+	// The front was seen after lastSeen, so the search stops there at the
+	// latest
+	mark := s.order.Back()
+	for mark == elem || s.nodes[mark.Value.(string)].LastSeen.Before(lastSeen) {
+		mark = mark.Prev()
+	}
+	if ok {
+		s.order.MoveAfter(elem, mark)
+	} else {
+		s.elems[addr] = s.order.InsertAfter(addr, mark)
+	}
+}
@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPacketDedupTTL is how long a received heartbeat is remembered,
+	// far longer than retransmitted or relayed copies take to arrive
+	DefaultPacketDedupTTL = 10 * time.Second
+
+	// DefaultPacketDedupSize bounds the remembered heartbeats, about 1MB
+	DefaultPacketDedupSize = 16384
+)
+
+// dedupKey identifies one logical heartbeat: copies share the sender's
+// UUID and timestamp
+type dedupKey struct {
+	uuid      [16]byte
+	timestamp int64
+}
+
+// dedupEntry is a remembered heartbeat in arrival order
+type dedupEntry struct {
+	key dedupKey
+	at  time.Time
+}
+
+// dedupCache remembers recently received heartbeats for a TTL, up to a
+// fixed number, oldest first out
+type dedupCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	seen    map[dedupKey]time.Time
+	entries []dedupEntry // Ring buffer of len(entries) slots
+	head    int          // Oldest entry
+	count   int
+}
+
+// newDedupCache returns a cache remembering up to size heartbeats for ttl
+func newDedupCache(ttl time.Duration, size int) *dedupCache {
+	return &dedupCache{
+		ttl:     ttl,
+		seen:    make(map[dedupKey]time.Time, size),
+		entries: make([]dedupEntry, size),
+	}
+}
+
+// duplicate reports whether key was seen within the TTL, and remembers it
+// otherwise
+func (c *dedupCache) duplicate(key dedupKey, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if at, ok := c.seen[key]; ok && now.Sub(at) < c.ttl {
+		return true
+	}
+	for c.count > 0 && (c.count == len(c.entries) || now.Sub(c.entries[c.head].at) >= c.ttl) {
+		c.evictOldest()
+	}
+	c.entries[(c.head+c.count)%len(c.entries)] = dedupEntry{key: key, at: now}
+	c.count++
+	c.seen[key] = now
+	return false
+}
+
+// evictOldest forgets the oldest entry, unless its key was remembered
+// again since
+func (c *dedupCache) evictOldest() {
+	oldest := c.entries[c.head]
+	if c.seen[oldest.key].Equal(oldest.at) {
+		delete(c.seen, oldest.key)
+	}
+	c.head = (c.head + 1) % len(c.entries)
+	c.count--
+}
+
+// SetPacketDedup drops heartbeats whose UUID and timestamp match one
+// received less than ttl ago, before they touch the peer list or the
+// monitor. This catches retransmitted or relayed copies that arrive out of
+// order or from another source address, which the monitor's check against
+// the previous heartbeat misses. At most size heartbeats are remembered,
+// oldest first out. Drops are counted in NetworkStats.Duplicates. A ttl or
+// size of 0 disables the cache. Must be called before Start
+func (u *UDPNode) SetPacketDedup(ttl time.Duration, size int) {
+	if ttl <= 0 || size <= 0 {
+		u.dedup = nil
+		return
+	}
+	u.dedup = newDedupCache(ttl, size)
+}
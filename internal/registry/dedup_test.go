@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+	"github.com/rafaelmarinho/pulsecheck/internal/protocol"
+)
+
+func TestDedupCache(t *testing.T) {
+	now := time.Now()
+	c := newDedupCache(time.Second, 2)
+	a, b, d := dedupKey{timestamp: 1}, dedupKey{timestamp: 2}, dedupKey{timestamp: 3}
+
+	if c.duplicate(a, now) {
+		t.Error("first sighting reported as duplicate")
+	}
+	if !c.duplicate(a, now.Add(999*time.Millisecond)) {
+		t.Error("repeat within the TTL not reported as duplicate")
+	}
+	if c.duplicate(a, now.Add(time.Second)) {
+		t.Error("repeat after the TTL reported as duplicate")
+	}
+
+	// The oldest entry makes room once the cache is full
+	c.duplicate(b, now.Add(time.Second))
+	c.duplicate(d, now.Add(time.Second))
+	if got := len(c.seen); got != 2 {
+		t.Errorf("cache holds %d heartbeats, want at most 2", got)
+	}
+	if !c.duplicate(d, now.Add(time.Second)) || c.duplicate(a, now.Add(time.Second)) {
+		t.Error("eviction did not drop the oldest heartbeat")
+	}
+}
+
+func TestUDPNodePacketDedup(t *testing.T) {
+	monitor := NewMonitor()
+	var events []Event
+	monitor.SetEventHandler(func(e Event) { events = append(events, e) })
+	node, err := NewUDPNode(0, [16]byte{1}, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	fake := clock.NewFake(time.Now())
+	node.SetClock(fake)
+	node.SetPacketDedup(time.Second, 16)
+
+	send := func(status uint8, timestamp int64, srcPort int) {
+		t.Helper()
+		pkt := protocol.NewPacket([16]byte{2}, status)
+		pkt.ListenPort = 9999
+		pkt.Timestamp = timestamp
+		data, err := pkt.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		node.handlePacket(data, &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: srcPort})
+	}
+
+	send(2, 100, 40000)
+	send(2, 100, 40000) // Replayed as is
+	send(0, 200, 40000)
+	send(2, 100, 40001) // Late copy of the first, from another source port
+	if got := node.Stats().Duplicates; got != 2 {
+		t.Errorf("Duplicates = %d, want 2", got)
+	}
+	if info, _ := monitor.GetNodeInfo("10.0.0.2:9999"); info.StatusCode != 0 {
+		t.Errorf("StatusCode = %d, want 0: a late copy overwrote the newer heartbeat", info.StatusCode)
+	}
+	if len(events) != 2 || events[0].Type != EventJoined || events[1].Type != EventStatusChanged {
+		t.Errorf("events = %+v, want joined and one status change", events)
+	}
+
+	// Once the TTL passes the heartbeat is processed again
+	fake.Advance(time.Second)
+	send(2, 100, 40000)
+	if got := node.Stats().Duplicates; got != 2 {
+		t.Errorf("Duplicates after the TTL = %d, want 2", got)
+	}
+}
@@ -0,0 +1,184 @@
+package registry
+
+import (
+	"sort"
+	"strings"
+)
+
+// SetDependencies declares the nodes each node depends on, keyed by the
+// dependent's address. A dependency is down while it reports CRITICAL, or
+// once it was seen and then reaped; one never heard from is not down, so
+// a mistyped address suppresses nothing. A WARN or CRITICAL node behind a
+// down dependency is Suppressed: still listed with its status, but no
+// longer counted towards the cluster status, so one root failure does not
+// alert for every node behind it. A node that is itself a down dependency
+// is never suppressed. Callers should reject cycles with DependencyCycle.
+// Must be called before the monitor is shared
+func (m *Monitor) SetDependencies(deps map[string][]string) {
+	m.dependencies = make(map[string][]string, len(deps))
+	m.dependedOn = make(map[string]bool)
+	for dependent, on := range deps {
+		key := CanonicalAddr(dependent)
+		for _, addr := range on {
+			addr = CanonicalAddr(addr)
+			m.dependencies[key] = append(m.dependencies[key], addr)
+			m.dependedOn[addr] = true
+		}
+	}
+}
+
+// DependencyCycle returns a cycle in deps as the addresses along it, first
+// and last the same, or nil if there is none
+func DependencyCycle(deps map[string][]string) []string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(addr string) []string
+	visit = func(addr string) []string {
+		switch state[addr] {
+		case visiting:
+			for i, a := range path {
+				if a == addr {
+					return append(append([]string(nil), path[i:]...), addr)
+				}
+			}
+		case done:
+			return nil
+		}
+		state[addr] = visiting
+		path = append(path, addr)
+		for _, dep := range deps[addr] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[addr] = done
+		return nil
+	}
+
+	// Visit in a fixed order so the same cycle is always reported
+	dependents := make([]string, 0, len(deps))
+	for addr := range deps {
+		dependents = append(dependents, addr)
+	}
+	sort.Strings(dependents)
+	for _, addr := range dependents {
+		if cycle := visit(addr); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// FormatCycle joins a cycle from DependencyCycle for messages
+func FormatCycle(cycle []string) string {
+	return strings.Join(cycle, " -> ")
+}
+
+// DownDependency is a dependency found down, see SetDependencies
+type DownDependency struct {
+	Address string
+	Missing bool // Reaped rather than reporting CRITICAL
+}
+
+// DownDependencies returns the down dependencies of each dependent that
+// has any, sorted by address, whatever the dependent's own status
+func (m *Monitor) DownDependencies() map[string][]DownDependency {
+	down := m.downDependencies()
+	if len(down) == 0 {
+		return nil
+	}
+	result := make(map[string][]DownDependency)
+	for dependent, on := range m.dependencies {
+		for _, addr := range on {
+			if d, ok := down[addr]; ok {
+				result[dependent] = append(result[dependent], d)
+			}
+		}
+		sort.Slice(result[dependent], func(i, j int) bool {
+			return result[dependent][i].Address < result[dependent][j].Address
+		})
+	}
+	return result
+}
+
+// UnknownDependencies returns the dependencies never heard from, sorted.
+// Shortly after startup these are usually mistyped addresses, or written
+// in a form other than the one the node is keyed by
+func (m *Monitor) UnknownDependencies() []string {
+	var unknown []string
+	for addr := range m.dependedOn {
+		if _, ok := m.GetNodeInfo(addr); ok || m.wasReaped(addr) {
+			continue
+		}
+		unknown = append(unknown, addr)
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// downDependencies looks up every dependency once, before the shards are
+// walked, so no two shard locks are ever held together
+func (m *Monitor) downDependencies() map[string]DownDependency {
+	return m.downDependenciesIn(m.GetNodeInfo)
+}
+
+// downDependenciesIn looks up every dependency once with lookup
+func (m *Monitor) downDependenciesIn(lookup func(addr string) (NodeInfo, bool)) map[string]DownDependency {
+	if len(m.dependedOn) == 0 {
+		return nil
+	}
+	down := make(map[string]DownDependency)
+	for addr := range m.dependedOn {
+		info, ok := lookup(addr)
+		switch {
+		case ok && info.StatusCode >= 2:
+			down[addr] = DownDependency{Address: addr}
+		case !ok && m.wasReaped(addr):
+			down[addr] = DownDependency{Address: addr, Missing: true}
+		}
+	}
+	return down
+}
+
+// suppressed reports whether a failing node has a dependency in down. A
+// node that is down itself is a root cause, never suppressed
+func (m *Monitor) suppressed(addr string, info NodeInfo, down map[string]DownDependency) bool {
+	if info.StatusCode == 0 || len(down) == 0 {
+		return false
+	}
+	if _, ok := down[addr]; ok {
+		return false
+	}
+	for _, dep := range m.dependencies[addr] {
+		if _, ok := down[dep]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// markReaped remembers that a dependency was reaped, so it counts as down
+// until it is heard from again
+func (m *Monitor) markReaped(addr string) {
+	if !m.dependedOn[addr] {
+		return
+	}
+	m.reapedDepsMu.Lock()
+	defer m.reapedDepsMu.Unlock()
+	if m.reapedDeps == nil {
+		m.reapedDeps = make(map[string]bool)
+	}
+	m.reapedDeps[addr] = true
+}
+
+// wasReaped reports whether the dependency at addr was ever reaped
+func (m *Monitor) wasReaped(addr string) bool {
+	m.reapedDepsMu.Lock()
+	defer m.reapedDepsMu.Unlock()
+	return m.reapedDeps[addr]
+}
@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+)
+
+func TestDependencies(t *testing.T) {
+	m := NewMonitor()
+	m.SetDependencies(map[string][]string{
+		"10.0.0.3:9999": {"10.0.0.1:9999", "10.0.0.2:9999"},
+		"10.0.0.4:9999": {"10.0.0.1:9999"},
+	})
+	m.UpdateWithStatus("10.0.0.1:9999", 2, 0) // db-1, CRITICAL
+	m.UpdateWithStatus("10.0.0.3:9999", 2, 0) // web-3, failing behind it
+	m.UpdateWithStatus("10.0.0.4:9999", 0, 0) // web-4, fine regardless
+	m.UpdateWithStatus("10.0.0.5:9999", 1, 0) // no dependencies
+
+	// 10.0.0.2 was never heard from, so it is not down
+	want := map[string][]DownDependency{
+		"10.0.0.3:9999": {{Address: "10.0.0.1:9999"}},
+		"10.0.0.4:9999": {{Address: "10.0.0.1:9999"}},
+	}
+	if got := m.DownDependencies(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DownDependencies() = %v, want %v", got, want)
+	}
+	if got := m.UnknownDependencies(); !reflect.DeepEqual(got, []string{"10.0.0.2:9999"}) {
+		t.Errorf("UnknownDependencies() = %v, want the never-seen dependency", got)
+	}
+
+	nodes := m.GetNodes()
+	if !nodes["10.0.0.3:9999"].Suppressed {
+		t.Error("failing dependent of a down node should be suppressed")
+	}
+	for _, addr := range []string{"10.0.0.1:9999", "10.0.0.4:9999", "10.0.0.5:9999"} {
+		if nodes[addr].Suppressed {
+			t.Errorf("%s should not be suppressed", addr)
+		}
+	}
+	want2 := Summary{Total: 4, OK: 1, Warn: 1, Critical: 1, Suppressed: 1}
+	if got := m.Summarize(); got != want2 {
+		t.Errorf("Summarize() = %+v, want %+v", got, want2)
+	}
+	if got := m.Snapshot().Summary; got != want2 {
+		t.Errorf("Snapshot().Summary = %+v, want %+v", got, want2)
+	}
+
+	// Once the root recovers, the dependent counts again
+	m.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	if got := m.DownDependencies(); got != nil {
+		t.Errorf("DownDependencies() = %v, want none", got)
+	}
+	if got := m.Summarize(); got.Suppressed != 0 || got.Critical != 1 {
+		t.Errorf("Summarize() = %+v, want the dependent critical again", got)
+	}
+}
+
+func TestDependencyUnknownNeverSuppresses(t *testing.T) {
+	m := NewMonitor()
+	// A typo, and the local node's own key, neither ever heard from
+	m.SetDependencies(map[string][]string{
+		"10.0.0.3:9999": {"10.0.0.l:9999"},
+		"10.0.0.4:9999": {"[::]:9999"},
+	})
+	m.UpdateWithStatus("10.0.0.3:9999", 2, 0)
+	m.UpdateWithStatus("10.0.0.4:9999", 2, 0)
+	if got := m.Summarize(); got.Suppressed != 0 || got.Critical != 2 {
+		t.Errorf("Summarize() = %+v, want both dependents critical", got)
+	}
+}
+
+func TestDependencyReaped(t *testing.T) {
+	m := NewMonitor()
+	fake := clock.NewFake(time.Now())
+	m.SetClock(fake)
+	m.SetDependencies(map[string][]string{"10.0.0.3:9999": {"10.0.0.1:9999"}})
+	m.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	fake.Advance(time.Minute)
+	m.UpdateWithStatus("10.0.0.3:9999", 2, 0)
+
+	s, _ := m.getShard("10.0.0.1:9999")
+	if n := m.reapShard(s, fake.Now(), timeoutExpiry(fake.Now(), 30*time.Second)); n != 1 {
+		t.Fatalf("reapShard() = %d, want the dependency reaped", n)
+	}
+	want := map[string][]DownDependency{"10.0.0.3:9999": {{Address: "10.0.0.1:9999", Missing: true}}}
+	if got := m.DownDependencies(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DownDependencies() = %v, want %v", got, want)
+	}
+	if got := m.Summarize(); got.Suppressed != 1 || got.Critical != 0 {
+		t.Errorf("Summarize() = %+v, want the dependent suppressed", got)
+	}
+	if got := m.UnknownDependencies(); got != nil {
+		t.Errorf("UnknownDependencies() = %v, want a reaped dependency known", got)
+	}
+}
+
+func TestDependencyDownRootNotSuppressed(t *testing.T) {
+	// Even if a cycle slipped through, a node others depend on and that is
+	// down itself stays counted, so the run cannot exit OK
+	m := NewMonitor()
+	m.SetDependencies(map[string][]string{
+		"10.0.0.1:9999": {"10.0.0.2:9999"},
+		"10.0.0.2:9999": {"10.0.0.1:9999"},
+	})
+	m.UpdateWithStatus("10.0.0.1:9999", 2, 0)
+	m.UpdateWithStatus("10.0.0.2:9999", 2, 0)
+	if got := m.Summarize(); got.Suppressed != 0 || got.WorstStatus() != 2 {
+		t.Errorf("Summarize() = %+v, want both nodes critical", got)
+	}
+}
+
+func TestDependencyCycle(t *testing.T) {
+	tests := []struct {
+		name string
+		deps map[string][]string
+		want []string
+	}{
+		{"none", map[string][]string{"a": {"b", "c"}, "b": {"c"}}, nil},
+		{"pair", map[string][]string{"a": {"b"}, "b": {"a"}}, []string{"a", "b", "a"}},
+		{"longer", map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}, "d": {"a"}}, []string{"a", "b", "c", "a"}},
+		{"self", map[string][]string{"a": {"a"}}, []string{"a", "a"}},
+	}
+	for _, tt := range tests {
+		if got := DependencyCycle(tt.deps); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: DependencyCycle() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// MaxDSCP is the largest DSCP value; the field is 6 bits wide
+const MaxDSCP = 63
+
+// errDSCPUnsupported is returned where sockets cannot be marked
+var errDSCPUnsupported = errors.New("DSCP marking is not supported on this platform")
+
+// SetDSCP marks every outgoing packet with the DSCP value, e.g. 46 for
+// expedited forwarding, so routers can prioritize heartbeats over bulk
+// traffic on congested links. 0 leaves packets unmarked. The mark is kept
+// when the listener watchdog re-creates the socket. It returns an error
+// if the platform or socket does not support it; the node works unmarked
+// either way
+func (u *UDPNode) SetDSCP(dscp int) error {
+	if dscp < 0 || dscp > MaxDSCP {
+		return fmt.Errorf("DSCP must be between 0 and %d", MaxDSCP)
+	}
+	u.dscp = dscp
+	if dscp == 0 {
+		return nil
+	}
+	return setTOS(u.socket(), dscp<<2)
+}
+
+// remark applies the DSCP mark to a re-created socket
+func (u *UDPNode) remark() {
+	if u.dscp == 0 {
+		return
+	}
+	if err := setTOS(u.socket(), u.dscp<<2); err != nil {
+		log.Printf("Warning: failed to mark the restarted listener with DSCP %d: %v", u.dscp, err)
+	}
+}
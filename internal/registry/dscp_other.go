@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package registry
+
+import "net"
+
+// setTOS is not implemented on this platform
+func setTOS(conn *net.UDPConn, tos int) error {
+	return errDSCPUnsupported
+}
@@ -0,0 +1,29 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package registry
+
+import (
+	"net"
+	"syscall"
+)
+
+// setTOS sets the traffic class of an IPv6 socket and the TOS byte of IPv4
+// traffic, including IPv4 sent from a dual-stack socket. It succeeds if
+// either applies
+func setTOS(conn *net.UDPConn, tos int) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var v4Err, v6Err error
+	if err := raw.Control(func(fd uintptr) {
+		v4Err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+		v6Err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos)
+	}); err != nil {
+		return err
+	}
+	if v4Err != nil && v6Err != nil {
+		return v4Err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package registry
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestUDPNodeSetDSCP(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{1}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	if err := node.SetDSCP(64); err == nil {
+		t.Error("SetDSCP(64) should fail")
+	}
+	if err := node.SetDSCP(46); err != nil {
+		t.Fatalf("SetDSCP(46) error = %v", err)
+	}
+	tos := func() int {
+		raw, err := node.socket().SyscallConn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v4, v6 int
+		var v4Err, v6Err error
+		raw.Control(func(fd uintptr) {
+			v4, v4Err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+			v6, v6Err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS)
+		})
+		if v6Err == nil {
+			return v6
+		}
+		if v4Err != nil {
+			t.Fatalf("reading TOS: %v", v4Err)
+		}
+		return v4
+	}
+	if got := tos(); got != 46<<2 {
+		t.Errorf("TOS = %#x, want %#x", got, 46<<2)
+	}
+
+	// The mark survives the watchdog re-creating the socket
+	node.socket().Close()
+	if !node.restartListener() {
+		t.Fatal("restartListener() failed")
+	}
+	if got := tos(); got != 46<<2 {
+		t.Errorf("TOS after restart = %#x, want %#x", got, 46<<2)
+	}
+}
@@ -0,0 +1,28 @@
+package registry
+
+import (
+	"bytes"
+	"time"
+)
+
+// IsLeader reports whether self holds the lease among a set of collectors.
+// Each candidate address that has sent a heartbeat within ttl holds a lease;
+// the live candidate with the highest priority wins, the lowest UUID among
+// equal priorities, so exactly one collector is active while the others
+// stand by. When the leader stops heartbeating its lease lapses after ttl
+// and the next candidate in that order takes over
+func (m *Monitor) IsLeader(self [16]byte, selfPriority uint8, candidates []string, ttl time.Duration, now time.Time) bool {
+	for _, addr := range candidates {
+		info, ok := m.GetNodeInfo(addr)
+		if !ok || info.UUID == ([16]byte{}) || now.Sub(info.LastSeen) > ttl {
+			continue
+		}
+		if info.Priority > selfPriority {
+			return false
+		}
+		if info.Priority == selfPriority && bytes.Compare(info.UUID[:], self[:]) < 0 {
+			return false
+		}
+	}
+	return true
+}
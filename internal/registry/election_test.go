@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsLeader(t *testing.T) {
+	m := NewMonitor()
+	low := [16]byte{1}
+	high := [16]byte{2}
+	ttl := time.Second
+
+	// No peer collector seen yet - self is active
+	if !m.IsLeader(high, 0, []string{"10.0.0.1:9999"}, ttl, time.Now()) {
+		t.Error("IsLeader() = false with no live candidates")
+	}
+
+	m.UpdateWithHeartbeat("10.0.0.1:9999", low, 0, 0)
+	if m.IsLeader(high, 0, []string{"10.0.0.1:9999"}, ttl, time.Now()) {
+		t.Error("IsLeader() = true while a lower UUID holds the lease")
+	}
+
+	// The lower UUID's lease lapses once it stops heartbeating
+	if !m.IsLeader(high, 0, []string{"10.0.0.1:9999"}, ttl, time.Now().Add(2*ttl)) {
+		t.Error("IsLeader() = false after the leader's lease expired")
+	}
+
+	// The lowest UUID is always active
+	m.UpdateWithHeartbeat("10.0.0.2:9999", high, 0, 0)
+	if !m.IsLeader(low, 0, []string{"10.0.0.2:9999"}, ttl, time.Now()) {
+		t.Error("IsLeader() = false for the lowest UUID")
+	}
+}
+
+func TestIsLeaderPriority(t *testing.T) {
+	m := NewMonitor()
+	low := [16]byte{1}
+	high := [16]byte{2}
+	ttl := time.Second
+
+	// A higher priority wins over a lower UUID
+	m.UpdateWithHeartbeat("10.0.0.1:9999", low, 0, 0)
+	m.UpdatePriority("10.0.0.1:9999", 1)
+	if !m.IsLeader(high, 2, []string{"10.0.0.1:9999"}, ttl, time.Now()) {
+		t.Error("IsLeader() = false with a higher priority than the lower UUID")
+	}
+	if m.IsLeader(high, 0, []string{"10.0.0.1:9999"}, ttl, time.Now()) {
+		t.Error("IsLeader() = true below the candidate's priority")
+	}
+
+	// Equal priorities fall back to the lowest UUID
+	if m.IsLeader(high, 1, []string{"10.0.0.1:9999"}, ttl, time.Now()) {
+		t.Error("IsLeader() = true at equal priority with a higher UUID")
+	}
+	m.UpdateWithHeartbeat("10.0.0.2:9999", high, 0, 0)
+	m.UpdatePriority("10.0.0.2:9999", 1)
+	if !m.IsLeader(low, 1, []string{"10.0.0.2:9999"}, ttl, time.Now()) {
+		t.Error("IsLeader() = false at equal priority with the lowest UUID")
+	}
+
+	// A preferred collector's lease still lapses
+	if !m.IsLeader(high, 0, []string{"10.0.0.1:9999"}, ttl, time.Now().Add(2*ttl)) {
+		t.Error("IsLeader() = false after the preferred leader's lease expired")
+	}
+}
+
+func TestUpdateWithHeartbeatKeepsUUID(t *testing.T) {
+	m := NewMonitor()
+	id := [16]byte{7}
+
+	m.UpdateWithHeartbeat("10.0.0.1:9999", id, 0, 0)
+	m.UpdateWithStatus("10.0.0.1:9999", 1, 0)
+
+	info, _ := m.GetNodeInfo("10.0.0.1:9999")
+	if info.UUID != id || info.StatusCode != 1 {
+		t.Errorf("GetNodeInfo() = %+v, want UUID kept and status updated", info)
+	}
+}
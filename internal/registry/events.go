@@ -0,0 +1,54 @@
+package registry
+
+import "time"
+
+// EventType identifies a change in the node registry
+type EventType string
+
+const (
+	EventJoined        EventType = "joined"         // First heartbeat from a node
+	EventStatusChanged EventType = "status_changed" // Reported status code changed
+	EventLeft          EventType = "left"           // Node removed by the reaper
+
+	// One UUID reported from several addresses, see SetDuplicateWindow
+	EventDuplicateIdentity EventType = "duplicate_identity"
+)
+
+// Event describes a single change in the node registry
+type Event struct {
+	Time       time.Time
+	Type       EventType
+	Address    string
+	StatusCode uint8 // Status after the change
+	PrevStatus uint8 // Status before the change (EventStatusChanged only)
+
+	UUID [16]byte // Shared UUID (EventDuplicateIdentity only, see DuplicateIdentities)
+}
+
+// SetEventHandler registers fn to be called for every registry change.
+// Must be called before the monitor is updated. fn runs with a shard lock
+// held, so it must not block or call back into the Monitor
+func (m *Monitor) SetEventHandler(fn func(Event)) {
+	m.onEvent = fn
+}
+
+// emit delivers an event to the registered handler, if any
+func (m *Monitor) emit(e Event) {
+	if m.onEvent != nil {
+		m.onEvent(e)
+	}
+}
+
+// emitUpdate emits the events implied by replacing prev with info
+func (m *Monitor) emitUpdate(prev NodeInfo, existed bool, info NodeInfo) {
+	if m.onEvent == nil {
+		return
+	}
+	switch {
+	case !existed:
+		m.emit(Event{Time: info.LastSeen, Type: EventJoined, Address: info.Address, StatusCode: info.StatusCode})
+	case prev.StatusCode != info.StatusCode:
+		m.emit(Event{Time: info.LastSeen, Type: EventStatusChanged, Address: info.Address,
+			StatusCode: info.StatusCode, PrevStatus: prev.StatusCode})
+	}
+}
@@ -0,0 +1,60 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonitorEvents(t *testing.T) {
+	m := NewMonitor()
+	var events []Event
+	m.SetEventHandler(func(e Event) {
+		events = append(events, e)
+	})
+
+	m.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	m.UpdateWithStatus("10.0.0.1:9999", 0, 0) // Unchanged - no event
+	m.UpdateWithStatus("10.0.0.1:9999", 2, 0)
+	m.UpdateWithTelemetry("10.0.0.2:9999", 10, 10, 10, 1)
+
+	want := []Event{
+		{Type: EventJoined, Address: "10.0.0.1:9999", StatusCode: 0},
+		{Type: EventStatusChanged, Address: "10.0.0.1:9999", StatusCode: 2, PrevStatus: 0},
+		{Type: EventJoined, Address: "10.0.0.2:9999", StatusCode: 1},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, e := range events {
+		if e.Time.IsZero() {
+			t.Errorf("event %d has no time", i)
+		}
+		e.Time = time.Time{}
+		if e != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func TestMonitorEventLeft(t *testing.T) {
+	m := NewMonitor()
+	left := make(chan Event, 1)
+	m.SetEventHandler(func(e Event) {
+		if e.Type == EventLeft {
+			left <- e
+		}
+	})
+
+	m.UpdateWithStatus("10.0.0.1:9999", 1, 0)
+	go m.StartReaper(10*time.Millisecond, 20*time.Millisecond)
+	defer m.Stop()
+
+	select {
+	case e := <-left:
+		if e.Address != "10.0.0.1:9999" || e.StatusCode != 1 {
+			t.Errorf("left event = %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("reaper did not emit a left event")
+	}
+}
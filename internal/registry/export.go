@@ -0,0 +1,305 @@
+package registry
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// StateVersion is the version of the binary state format written by
+// EncodeState. Version 1 states, which predate the priority, location,
+// Kubernetes identity and federation source, are still decoded
+const (
+	StateVersion   = 2
+	StateVersionV1 = 1
+)
+
+// MaxStateSize and MaxStateNodes bound a state accepted by DecodeState, so
+// a bogus or hostile import cannot exhaust memory
+const (
+	MaxStateSize  = 64 << 20
+	MaxStateNodes = 1 << 20
+)
+
+// stateMagic starts every encoded state
+var stateMagic = [4]byte{'P', 'C', 'S', 'T'}
+
+const (
+	stateHeaderSize = 4 + 1 + 8 + 4 // Magic, version, time, node count
+	stateCRCSize    = 4
+
+	// stateNodeSizeV1 is the fixed part of a version 1 node entry; the
+	// address and its 2-byte length come first
+	stateNodeSizeV1 = 8 + 3*8 + 1 + 1 + 8 + 8 + 16 + 8 + 2*8 + 8
+
+	// stateNodeSize adds the priority and coordinates. The entry ends with
+	// stateStrings more strings, each after its 2-byte length
+	stateNodeSize = stateNodeSizeV1 + 1 + 2*8
+	stateStrings  = 5
+
+	// maxStateString is the most bytes a 2-byte length can describe
+	maxStateString = math.MaxUint16
+)
+
+// Node entry flags
+const (
+	stateHasTelemetry = 1 << iota
+	stateProbed
+	stateHasNetwork
+	stateHasCoordinates
+)
+
+var (
+	// ErrStateCorrupt is returned when an encoded state fails its checksum
+	// or is truncated
+	ErrStateCorrupt = errors.New("monitor state is corrupt")
+
+	// ErrStateVersion is returned for a state written by an unknown format version
+	ErrStateVersion = errors.New("unknown monitor state version")
+
+	// ErrStateTooLarge is returned for a state over MaxStateSize bytes or
+	// MaxStateNodes nodes
+	ErrStateTooLarge = errors.New("monitor state is too large")
+)
+
+// EncodeState serializes nodes into the compact binary state format: a
+// header with the version, t and the node count, one entry per node and a
+// CRC32 over everything before it. Phi, PacketLoss, Silenced and
+// Suppressed are computed on read and are not encoded. Strings longer than
+// 65,535 bytes, such as a datacenter from a pushed report, are truncated
+func EncodeState(t time.Time, nodes map[string]NodeInfo) []byte {
+	size := stateHeaderSize + stateCRCSize
+	for addr, info := range nodes {
+		size += 2 + len(stateString(addr)) + stateNodeSize + 2*stateStrings
+		for _, s := range stateStringsOf(&info) {
+			size += len(stateString(*s))
+		}
+	}
+
+	buf := make([]byte, 0, size)
+	buf = append(buf, stateMagic[:]...)
+	buf = append(buf, StateVersion)
+	buf = appendTime(buf, t)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(nodes)))
+	for addr, info := range nodes {
+		buf = appendStateString(buf, addr)
+		buf = appendTime(buf, info.LastSeen)
+		buf = appendFloat(buf, info.CPUPercent)
+		buf = appendFloat(buf, info.RAMPercent)
+		buf = appendFloat(buf, info.DiskPercent)
+
+		var flags byte
+		if info.HasTelemetry {
+			flags |= stateHasTelemetry
+		}
+		if info.Probed {
+			flags |= stateProbed
+		}
+		if info.HasNetwork {
+			flags |= stateHasNetwork
+		}
+		if info.Location.HasCoordinates {
+			flags |= stateHasCoordinates
+		}
+		buf = append(buf, flags, info.StatusCode)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(info.PacketTime))
+		buf = binary.BigEndian.AppendUint64(buf, uint64(info.RTT))
+		buf = append(buf, info.UUID[:]...)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(info.ClockSkew))
+		buf = appendFloat(buf, info.NetRxBytesPerSec)
+		buf = appendFloat(buf, info.NetTxBytesPerSec)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(info.HeartbeatInterval))
+		buf = append(buf, info.Priority)
+		buf = appendFloat(buf, info.Location.Latitude)
+		buf = appendFloat(buf, info.Location.Longitude)
+		for _, s := range stateStringsOf(&info) {
+			buf = appendStateString(buf, *s)
+		}
+	}
+	return binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+}
+
+// stateStringsOf returns the string fields of a node entry after the fixed
+// part, in encoding order
+func stateStringsOf(info *NodeInfo) [stateStrings]*string {
+	return [stateStrings]*string{
+		&info.Location.Datacenter,
+		&info.Kubernetes.Pod,
+		&info.Kubernetes.Namespace,
+		&info.Kubernetes.Node,
+		&info.FederatedFrom,
+	}
+}
+
+// DecodeState parses a state written by EncodeState, returning the time it
+// was taken and its nodes
+func DecodeState(data []byte) (time.Time, map[string]NodeInfo, error) {
+	if len(data) > MaxStateSize {
+		return time.Time{}, nil, fmt.Errorf("%w: %d bytes", ErrStateTooLarge, len(data))
+	}
+	if len(data) < stateHeaderSize+stateCRCSize {
+		return time.Time{}, nil, ErrStateCorrupt
+	}
+	body := data[:len(data)-stateCRCSize]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[len(body):]) {
+		return time.Time{}, nil, ErrStateCorrupt
+	}
+	if [4]byte(body[0:4]) != stateMagic {
+		return time.Time{}, nil, ErrStateCorrupt
+	}
+	version := body[4]
+	entrySize := stateNodeSize
+	switch version {
+	case StateVersion:
+	case StateVersionV1:
+		entrySize = stateNodeSizeV1
+	default:
+		return time.Time{}, nil, fmt.Errorf("%w: %d", ErrStateVersion, version)
+	}
+
+	t := readTime(body[5:13])
+	count := binary.BigEndian.Uint32(body[13:17])
+	if count > MaxStateNodes {
+		return time.Time{}, nil, fmt.Errorf("%w: %d nodes", ErrStateTooLarge, count)
+	}
+	// Every entry takes at least entrySize bytes, so a bogus count is
+	// caught before allocating for it
+	if uint64(count)*uint64(2+entrySize) > uint64(len(body)-stateHeaderSize) {
+		return time.Time{}, nil, ErrStateCorrupt
+	}
+
+	nodes := make(map[string]NodeInfo, count)
+	b := body[stateHeaderSize:]
+	for i := uint32(0); i < count; i++ {
+		if len(b) < 2 {
+			return time.Time{}, nil, ErrStateCorrupt
+		}
+		addrLen := int(binary.BigEndian.Uint16(b))
+		if len(b) < 2+addrLen+entrySize {
+			return time.Time{}, nil, ErrStateCorrupt
+		}
+		var info NodeInfo
+		info.Address = string(b[2 : 2+addrLen])
+		e := b[2+addrLen:]
+		info.LastSeen = readTime(e[0:8])
+		info.CPUPercent = readFloat(e[8:16])
+		info.RAMPercent = readFloat(e[16:24])
+		info.DiskPercent = readFloat(e[24:32])
+		flags := e[32]
+		info.HasTelemetry = flags&stateHasTelemetry != 0
+		info.Probed = flags&stateProbed != 0
+		info.HasNetwork = flags&stateHasNetwork != 0
+		info.StatusCode = e[33]
+		info.PacketTime = int64(binary.BigEndian.Uint64(e[34:42]))
+		info.RTT = time.Duration(binary.BigEndian.Uint64(e[42:50]))
+		copy(info.UUID[:], e[50:66])
+		info.ClockSkew = time.Duration(binary.BigEndian.Uint64(e[66:74]))
+		info.NetRxBytesPerSec = readFloat(e[74:82])
+		info.NetTxBytesPerSec = readFloat(e[82:90])
+		info.HeartbeatInterval = time.Duration(binary.BigEndian.Uint64(e[90:98]))
+		b = e[entrySize:]
+		if version >= StateVersion {
+			info.Priority = e[98]
+			info.Location.Latitude = readFloat(e[99:107])
+			info.Location.Longitude = readFloat(e[107:115])
+			info.Location.HasCoordinates = flags&stateHasCoordinates != 0
+			if info.Location.Validate() != nil {
+				return time.Time{}, nil, ErrStateCorrupt
+			}
+			for _, s := range stateStringsOf(&info) {
+				if len(b) < 2 || len(b) < 2+int(binary.BigEndian.Uint16(b)) {
+					return time.Time{}, nil, ErrStateCorrupt
+				}
+				n := int(binary.BigEndian.Uint16(b))
+				*s = string(b[2 : 2+n])
+				b = b[2+n:]
+			}
+		}
+		nodes[info.Address] = info
+	}
+	if len(b) != 0 {
+		return time.Time{}, nil, ErrStateCorrupt
+	}
+	return t, nodes, nil
+}
+
+// ExportState encodes an atomic snapshot of every node with EncodeState
+func (m *Monitor) ExportState() []byte {
+	snap := m.Snapshot()
+	return EncodeState(snap.Time, snap.Nodes)
+}
+
+// ImportState loads a state written by ExportState, such as a primary
+// collector's view handed to a starting standby. A node is only taken when
+// it is unknown here or was seen more recently in the state; imported nodes
+// emit no events and age out through the reaper like any other. New nodes
+// are subject to SetMaxNodes. Returns the number of nodes taken
+func (m *Monitor) ImportState(data []byte) (int, error) {
+	_, nodes, err := DecodeState(data)
+	if err != nil {
+		return 0, err
+	}
+	imported := 0
+	for addr, info := range nodes {
+		shard, addr := m.getShard(addr)
+		shard.mu.Lock()
+		prev, ok := shard.nodes[addr]
+		if (ok && info.LastSeen.After(prev.LastSeen)) || (!ok && m.makeRoom(shard, info.LastSeen)) {
+			shard.nodes[addr] = info
+			m.touch(shard, addr, info.LastSeen)
+			imported++
+		}
+		shard.mu.Unlock()
+	}
+	return imported, nil
+}
+
+// appendStateString appends s after its 2-byte length, truncated by
+// stateString
+func appendStateString(buf []byte, s string) []byte {
+	s = stateString(s)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+// stateString truncates s to maxStateString bytes, at a UTF-8 boundary
+func stateString(s string) string {
+	if len(s) <= maxStateString {
+		return s
+	}
+	n := maxStateString
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// appendTime appends t in Unix nanoseconds, with 0 for the zero time
+func appendTime(buf []byte, t time.Time) []byte {
+	if t.IsZero() {
+		return binary.BigEndian.AppendUint64(buf, 0)
+	}
+	return binary.BigEndian.AppendUint64(buf, uint64(t.UnixNano()))
+}
+
+// readTime reads a time written by appendTime
+func readTime(b []byte) time.Time {
+	if ns := int64(binary.BigEndian.Uint64(b)); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+// appendFloat appends the IEEE 754 bits of v
+func appendFloat(buf []byte, v float64) []byte {
+	return binary.BigEndian.AppendUint64(buf, math.Float64bits(v))
+}
+
+// readFloat reads a float64 written by appendFloat
+func readFloat(b []byte) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(b))
+}
@@ -0,0 +1,182 @@
+package registry
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	var uuid [16]byte
+	copy(uuid[:], "node-uuid")
+	taken := time.Unix(1700000000, 123)
+	nodes := map[string]NodeInfo{
+		"10.0.0.1:9999": {
+			LastSeen:          time.Unix(1700000000, 0),
+			Address:           "10.0.0.1:9999",
+			CPUPercent:        12.5,
+			RAMPercent:        40,
+			DiskPercent:       90.25,
+			HasTelemetry:      true,
+			StatusCode:        2,
+			PacketTime:        1699999999999,
+			RTT:               3 * time.Millisecond,
+			UUID:              uuid,
+			ClockSkew:         -250 * time.Millisecond,
+			NetRxBytesPerSec:  1024,
+			NetTxBytesPerSec:  2048,
+			HasNetwork:        true,
+			HeartbeatInterval: 2 * time.Second,
+			Priority:          3,
+			Location:          Location{Datacenter: "eu-west-1a", Latitude: 53.35, Longitude: -6.26, HasCoordinates: true},
+			Kubernetes:        Kubernetes{Pod: "api-0", Namespace: "prod", Node: "ip-10-0-0-1.ec2.internal"},
+			FederatedFrom:     "http://collector-b:8080",
+		},
+		"db.internal:5432": {Address: "db.internal:5432", Probed: true, RTT: time.Second},
+	}
+
+	data := EncodeState(taken, nodes)
+	gotTime, got, err := DecodeState(data)
+	if err != nil {
+		t.Fatalf("DecodeState() error = %v", err)
+	}
+	if !gotTime.Equal(taken) {
+		t.Errorf("DecodeState() time = %v, want %v", gotTime, taken)
+	}
+	if len(got) != len(nodes) {
+		t.Fatalf("DecodeState() has %d nodes, want %d", len(got), len(nodes))
+	}
+	for addr, want := range nodes {
+		info := got[addr]
+		if !info.LastSeen.Equal(want.LastSeen) {
+			t.Errorf("%s LastSeen = %v, want %v", addr, info.LastSeen, want.LastSeen)
+		}
+		info.LastSeen = want.LastSeen
+		if info != want {
+			t.Errorf("%s = %+v, want %+v", addr, info, want)
+		}
+	}
+
+	// Entries are fixed-size apart from the address and strings
+	strs := len("eu-west-1a") + len("api-0") + len("prod") + len("ip-10-0-0-1.ec2.internal") + len("http://collector-b:8080")
+	if len(data) != stateHeaderSize+stateCRCSize+2*(2+stateNodeSize+2*stateStrings)+len("10.0.0.1:9999")+len("db.internal:5432")+strs {
+		t.Errorf("encoded size = %d", len(data))
+	}
+}
+
+func TestStateLongStrings(t *testing.T) {
+	// A pushed report can carry a datacenter too long for a 2-byte length
+	long := strings.Repeat("d", 70000)
+	nodes := map[string]NodeInfo{
+		"10.0.0.1:9999": {Address: "10.0.0.1:9999", Location: Location{Datacenter: long}},
+		"10.0.0.2:9999": {Address: "10.0.0.2:9999", Kubernetes: Kubernetes{Pod: strings.Repeat("é", 40000)}},
+	}
+
+	_, got, err := DecodeState(EncodeState(time.Unix(1700000000, 0), nodes))
+	if err != nil {
+		t.Fatalf("DecodeState() error = %v", err)
+	}
+	if dc := got["10.0.0.1:9999"].Location.Datacenter; dc != long[:maxStateString] {
+		t.Errorf("Datacenter has %d bytes, want truncated to %d", len(dc), maxStateString)
+	}
+	// Truncation does not split a rune
+	if pod := got["10.0.0.2:9999"].Kubernetes.Pod; len(pod) != maxStateString-1 || !utf8.ValidString(pod) {
+		t.Errorf("Pod has %d bytes (valid UTF-8 %v), want %d", len(pod), utf8.ValidString(pod), maxStateString-1)
+	}
+}
+
+func TestDecodeStateV1(t *testing.T) {
+	const addr = "10.0.0.1:9999"
+	want := NodeInfo{Address: addr, CPUPercent: 12.5, HasTelemetry: true, StatusCode: 1, HeartbeatInterval: time.Second}
+	data := EncodeState(time.Unix(1700000000, 0), map[string]NodeInfo{addr: want})
+
+	// A version 1 entry is the version 2 one without its tail
+	v1 := append([]byte(nil), data[:stateHeaderSize+2+len(addr)+stateNodeSizeV1]...)
+	v1[4] = StateVersionV1
+	v1 = binary.BigEndian.AppendUint32(v1, crc32.ChecksumIEEE(v1))
+
+	_, got, err := DecodeState(v1)
+	if err != nil {
+		t.Fatalf("DecodeState(v1) error = %v", err)
+	}
+	if got[addr] != want {
+		t.Errorf("DecodeState(v1) = %+v, want %+v", got[addr], want)
+	}
+}
+
+func TestDecodeStateLimits(t *testing.T) {
+	if _, _, err := DecodeState(make([]byte, MaxStateSize+1)); !errors.Is(err, ErrStateTooLarge) {
+		t.Errorf("DecodeState(oversized) error = %v, want ErrStateTooLarge", err)
+	}
+
+	data := EncodeState(time.Now(), nil)
+	many := append([]byte(nil), data[:stateHeaderSize]...)
+	binary.BigEndian.PutUint32(many[13:17], MaxStateNodes+1)
+	many = binary.BigEndian.AppendUint32(many, crc32.ChecksumIEEE(many))
+	if _, _, err := DecodeState(many); !errors.Is(err, ErrStateTooLarge) {
+		t.Errorf("DecodeState(%d nodes) error = %v, want ErrStateTooLarge", MaxStateNodes+1, err)
+	}
+}
+
+func TestDecodeStateErrors(t *testing.T) {
+	nodes := map[string]NodeInfo{"10.0.0.1:9999": {Address: "10.0.0.1:9999"}}
+	data := EncodeState(time.Now(), nodes)
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[stateHeaderSize+4] ^= 0xFF
+	if _, _, err := DecodeState(corrupt); !errors.Is(err, ErrStateCorrupt) {
+		t.Errorf("DecodeState(corrupt) error = %v, want ErrStateCorrupt", err)
+	}
+	if _, _, err := DecodeState(data[:len(data)-10]); !errors.Is(err, ErrStateCorrupt) {
+		t.Errorf("DecodeState(truncated) error = %v, want ErrStateCorrupt", err)
+	}
+	if _, _, err := DecodeState(nil); !errors.Is(err, ErrStateCorrupt) {
+		t.Errorf("DecodeState(nil) error = %v, want ErrStateCorrupt", err)
+	}
+
+	// A future version with a valid checksum is rejected by version
+	future := append([]byte(nil), data[:len(data)-stateCRCSize]...)
+	future[4] = StateVersion + 1
+	future = binary.BigEndian.AppendUint32(future, crc32.ChecksumIEEE(future))
+	if _, _, err := DecodeState(future); !errors.Is(err, ErrStateVersion) {
+		t.Errorf("DecodeState(v%d) error = %v, want ErrStateVersion", StateVersion+1, err)
+	}
+}
+
+func TestMonitorExportImportState(t *testing.T) {
+	primary := NewMonitor()
+	for i := 1; i <= 100; i++ {
+		primary.UpdateWithTelemetry(fmt.Sprintf("10.0.0.%d:9999", i), float64(i), 20, 30, 0)
+	}
+	primary.UpdateWithStatus("10.0.0.1:9999", 1, 42)
+
+	standby := NewMonitor()
+	data := primary.ExportState()
+	standby.UpdateWithStatus("10.0.0.2:9999", 2, 0) // Seen more recently than the export
+
+	n, err := standby.ImportState(data)
+	if err != nil {
+		t.Fatalf("ImportState() error = %v", err)
+	}
+	if n != 99 {
+		t.Errorf("ImportState() took %d nodes, want 99", n)
+	}
+	if got := standby.GetNodeCount(); got != 100 {
+		t.Errorf("GetNodeCount() = %d, want 100", got)
+	}
+	if info, _ := standby.GetNodeInfo("10.0.0.1:9999"); info.StatusCode != 1 || info.PacketTime != 42 || info.CPUPercent != 1 {
+		t.Errorf("imported node = %+v", info)
+	}
+	if info, _ := standby.GetNodeInfo("10.0.0.2:9999"); info.StatusCode != 2 {
+		t.Errorf("newer local node was overwritten: %+v", info)
+	}
+
+	if _, err := standby.ImportState(data[1:]); err == nil {
+		t.Error("ImportState(corrupt) should return error")
+	}
+}
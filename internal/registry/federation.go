@@ -0,0 +1,49 @@
+package registry
+
+// MergeFederated merges nodes pulled from another collector into this view,
+// marking them as federated from source. When both collectors know a node
+// the freshest LastSeen wins, so a node seen directly here is only replaced
+// by a more recent sighting elsewhere. Merged nodes emit no events and age
+// out through the reaper like any other. Nodes keyed by an unspecified
+// address are another collector's self-entry and would overwrite ours, so
+// they are skipped; re-key them first. A node carrying this node's UUID
+// (see SetSelf) is skipped too, and one carrying the UUID of a node seen
+// directly here is merged into that node, whatever address the other
+// collector knows it by. New nodes are subject to SetMaxNodes. Returns the
+// number of nodes taken
+func (m *Monitor) MergeFederated(source string, nodes []NodeInfo) int {
+	direct := make(map[[16]byte]string)
+	m.ForEachNode(func(addr string, info NodeInfo) bool {
+		if info.UUID != ([16]byte{}) && info.FederatedFrom == "" && !info.Probed {
+			direct[info.UUID] = addr
+		}
+		return true
+	})
+
+	merged := 0
+	for _, info := range nodes {
+		if IsUnspecifiedAddr(info.Address) {
+			continue
+		}
+		if info.UUID != ([16]byte{}) {
+			if info.UUID == m.self {
+				continue
+			}
+			if addr, ok := direct[info.UUID]; ok {
+				info.Address = addr
+			}
+		}
+		var shard *shard
+		shard, info.Address = m.getShard(info.Address)
+		shard.mu.Lock()
+		prev, ok := shard.nodes[info.Address]
+		if (ok && info.LastSeen.After(prev.LastSeen)) || (!ok && m.makeRoom(shard, info.LastSeen)) {
+			info.FederatedFrom = source
+			shard.nodes[info.Address] = info
+			m.touch(shard, info.Address, info.LastSeen)
+			merged++
+		}
+		shard.mu.Unlock()
+	}
+	return merged
+}
@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"bytes"
+	"log"
+	"sort"
+	"time"
+)
+
+// DuplicateIdentity is a node UUID reported from more than one address
+// within the duplicate window, typically hosts cloned from one image
+type DuplicateIdentity struct {
+	UUID      [16]byte
+	Addresses []string // Sorted
+}
+
+// SetDuplicateWindow enables duplicate identity detection: a UUID seen from
+// two addresses less than window apart is flagged. 0 disables detection.
+// Must be called before the monitor is updated
+func (m *Monitor) SetDuplicateWindow(window time.Duration) {
+	m.dupWindow = window
+}
+
+// SetSelf tells the monitor this node's UUID, so that other collectors'
+// views of it are not merged back and it is not shared as one of its own
+// peers. Must be called before the monitor is updated
+func (m *Monitor) SetSelf(uuid [16]byte) {
+	m.self = uuid
+}
+
+// trackIdentity records that uuid was seen from addr, emitting
+// EventDuplicateIdentity when addr joins other recent addresses for it
+func (m *Monitor) trackIdentity(uuid [16]byte, addr string, now time.Time) {
+	if m.dupWindow <= 0 || uuid == ([16]byte{}) {
+		return
+	}
+	m.identityMu.Lock()
+	defer m.identityMu.Unlock()
+
+	seen, ok := m.identities[uuid]
+	if !ok {
+		seen = make(map[string]time.Time)
+		m.identities[uuid] = seen
+	}
+	m.pruneIdentity(seen, now)
+	_, known := seen[addr]
+	seen[addr] = now
+	if known || len(seen) < 2 {
+		return
+	}
+
+	others := make([]string, 0, len(seen)-1)
+	for other := range seen {
+		if other != addr {
+			others = append(others, other)
+		}
+	}
+	sort.Strings(others)
+	log.Printf("Warning: duplicate node identity %x reported by %s and %v", uuid, addr, others)
+	m.emit(Event{Time: now, Type: EventDuplicateIdentity, Address: addr, UUID: uuid})
+}
+
+// pruneIdentity drops addresses not seen within the duplicate window
+// Caller must hold identityMu
+func (m *Monitor) pruneIdentity(seen map[string]time.Time, now time.Time) {
+	for addr, last := range seen {
+		if now.Sub(last) > m.dupWindow {
+			delete(seen, addr)
+		}
+	}
+}
+
+// DuplicateIdentities returns the UUIDs currently reported from more than
+// one address, sorted by UUID
+func (m *Monitor) DuplicateIdentities() []DuplicateIdentity {
+	if m.dupWindow <= 0 {
+		return nil
+	}
+	m.identityMu.Lock()
+	defer m.identityMu.Unlock()
+	return m.duplicateIdentities(m.clock.Now())
+}
+
+// duplicateIdentities implements DuplicateIdentities
+// Caller must hold identityMu
+func (m *Monitor) duplicateIdentities(now time.Time) []DuplicateIdentity {
+	var dups []DuplicateIdentity
+	for uuid, seen := range m.identities {
+		m.pruneIdentity(seen, now)
+		if len(seen) == 0 {
+			delete(m.identities, uuid)
+			continue
+		}
+		if len(seen) < 2 {
+			continue
+		}
+		addrs := make([]string, 0, len(seen))
+		for addr := range seen {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+		dups = append(dups, DuplicateIdentity{UUID: uuid, Addresses: addrs})
+	}
+	sort.Slice(dups, func(i, j int) bool {
+		return bytes.Compare(dups[i].UUID[:], dups[j].UUID[:]) < 0
+	})
+	return dups
+}
@@ -0,0 +1,77 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+)
+
+func TestDuplicateIdentity(t *testing.T) {
+	m := NewMonitor()
+	fake := clock.NewFake(time.Now())
+	m.SetClock(fake)
+	m.SetDuplicateWindow(10 * time.Second)
+
+	var events []Event
+	m.SetEventHandler(func(e Event) {
+		if e.Type == EventDuplicateIdentity {
+			events = append(events, e)
+		}
+	})
+
+	var cloned, other [16]byte
+	copy(cloned[:], "cloned-vm")
+	copy(other[:], "other-node")
+
+	m.UpdateWithHeartbeat("10.0.0.1:9999", cloned, 0, 0)
+	m.UpdateWithHeartbeat("10.0.0.3:9999", other, 0, 0)
+	m.UpdateWithHeartbeat("10.0.0.1:9999", cloned, 0, 0)
+	if dups := m.DuplicateIdentities(); len(dups) != 0 {
+		t.Fatalf("DuplicateIdentities() = %v, want none", dups)
+	}
+
+	fake.Advance(5 * time.Second)
+	m.UpdateWithHeartbeat("10.0.0.2:9999", cloned, 0, 0)
+	m.UpdateWithHeartbeat("10.0.0.2:9999", cloned, 0, 0)
+
+	if len(events) != 1 {
+		t.Fatalf("got %d duplicate events, want 1 (only when an address first conflicts)", len(events))
+	}
+	if e := events[0]; e.Address != "10.0.0.2:9999" || e.UUID != cloned {
+		t.Errorf("duplicate event = %+v", e)
+	}
+
+	dups := m.DuplicateIdentities()
+	if len(dups) != 1 || dups[0].UUID != cloned ||
+		len(dups[0].Addresses) != 2 || dups[0].Addresses[0] != "10.0.0.1:9999" || dups[0].Addresses[1] != "10.0.0.2:9999" {
+		t.Errorf("DuplicateIdentities() = %+v", dups)
+	}
+
+	// Once one address goes quiet for the window the conflict clears,
+	// e.g. a node that moved to a new address
+	fake.Advance(6 * time.Second)
+	m.UpdateWithHeartbeat("10.0.0.2:9999", cloned, 0, 0)
+	if dups := m.DuplicateIdentities(); len(dups) != 0 {
+		t.Errorf("DuplicateIdentities() after window = %v, want none", dups)
+	}
+}
+
+func TestDuplicateIdentityDisabled(t *testing.T) {
+	m := NewMonitor()
+	var uuid [16]byte
+	copy(uuid[:], "cloned-vm")
+	m.UpdateWithHeartbeat("10.0.0.1:9999", uuid, 0, 0)
+	m.UpdateWithHeartbeat("10.0.0.2:9999", uuid, 0, 0)
+	if dups := m.DuplicateIdentities(); len(dups) != 0 {
+		t.Errorf("DuplicateIdentities() without a window = %v, want none", dups)
+	}
+
+	// The zero UUID means unknown, never a conflict
+	m.SetDuplicateWindow(time.Minute)
+	m.UpdateWithHeartbeat("10.0.0.3:9999", [16]byte{}, 0, 0)
+	m.UpdateWithHeartbeat("10.0.0.4:9999", [16]byte{}, 0, 0)
+	if dups := m.DuplicateIdentities(); len(dups) != 0 {
+		t.Errorf("DuplicateIdentities() for zero UUIDs = %v, want none", dups)
+	}
+}
@@ -0,0 +1,41 @@
+package registry
+
+import "os"
+
+// Kubernetes identifies the pod a node runs in, so its UDP address can be
+// matched to what kubectl shows. The zero value is a node outside
+// Kubernetes
+type Kubernetes struct {
+	Pod       string `json:"pod,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Node      string `json:"node,omitempty"` // Kubernetes node the pod is scheduled on
+}
+
+// KubernetesFromEnv reads POD_NAME, POD_NAMESPACE and NODE_NAME, as set
+// from the downward API. Unset variables leave their field empty
+func KubernetesFromEnv() Kubernetes {
+	return Kubernetes{
+		Pod:       os.Getenv("POD_NAME"),
+		Namespace: os.Getenv("POD_NAMESPACE"),
+		Node:      os.Getenv("NODE_NAME"),
+	}
+}
+
+// IsZero reports whether no Kubernetes identity is known
+func (k Kubernetes) IsZero() bool {
+	return k == Kubernetes{}
+}
+
+// UpdateKubernetes attaches a Kubernetes identity to a node already
+// recorded. Unknown nodes are ignored
+func (m *Monitor) UpdateKubernetes(addr string, k Kubernetes) {
+	shard, addr := m.getShard(addr)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	info, ok := shard.nodes[addr]
+	if !ok {
+		return
+	}
+	info.Kubernetes = k
+	shard.nodes[addr] = info
+}
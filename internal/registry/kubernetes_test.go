@@ -0,0 +1,34 @@
+package registry
+
+import "testing"
+
+func TestKubernetesFromEnv(t *testing.T) {
+	t.Setenv("POD_NAME", "pulsecheck-7d9f")
+	t.Setenv("POD_NAMESPACE", "monitoring")
+	t.Setenv("NODE_NAME", "")
+	want := Kubernetes{Pod: "pulsecheck-7d9f", Namespace: "monitoring"}
+	if got := KubernetesFromEnv(); got != want {
+		t.Errorf("KubernetesFromEnv() = %+v, want %+v", got, want)
+	}
+
+	t.Setenv("POD_NAME", "")
+	t.Setenv("POD_NAMESPACE", "")
+	if got := KubernetesFromEnv(); !got.IsZero() {
+		t.Errorf("KubernetesFromEnv() = %+v without the variables, want zero", got)
+	}
+}
+
+func TestMonitorUpdateKubernetes(t *testing.T) {
+	m := NewMonitor()
+	k := Kubernetes{Pod: "web-0", Namespace: "shop", Node: "worker-2"}
+	m.UpdateKubernetes("10.0.0.1:9999", k) // Unknown nodes are ignored
+	if _, ok := m.GetNodeInfo("10.0.0.1:9999"); ok {
+		t.Fatal("UpdateKubernetes() recorded an unknown node")
+	}
+
+	m.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	m.UpdateKubernetes("10.0.0.1:9999", k)
+	if info, _ := m.GetNodeInfo("10.0.0.1:9999"); info.Kubernetes != k {
+		t.Errorf("Kubernetes = %+v, want %+v", info.Kubernetes, k)
+	}
+}
@@ -0,0 +1,129 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Location places a node on a map by coordinates, a datacenter code, or
+// both. The zero value is an unknown location
+type Location struct {
+	Datacenter     string
+	Latitude       float64
+	Longitude      float64
+	HasCoordinates bool // False when only the datacenter is known
+}
+
+// locationJSON is the JSON form of a Location, with coordinates omitted
+// when unknown
+type locationJSON struct {
+	Datacenter string   `json:"datacenter,omitempty"`
+	Latitude   *float64 `json:"lat,omitempty"`
+	Longitude  *float64 `json:"lon,omitempty"`
+}
+
+// IsZero reports whether the location is unknown
+func (l Location) IsZero() bool {
+	return l == Location{}
+}
+
+// Validate checks the coordinates are in range. NaN is never in range,
+// and neither is an infinity
+func (l Location) Validate() error {
+	if !l.HasCoordinates {
+		return nil
+	}
+	if !(l.Latitude >= -90 && l.Latitude <= 90) {
+		return fmt.Errorf("latitude %v must be between -90 and 90", l.Latitude)
+	}
+	if !(l.Longitude >= -180 && l.Longitude <= 180) {
+		return fmt.Errorf("longitude %v must be between -180 and 180", l.Longitude)
+	}
+	return nil
+}
+
+// MarshalJSON writes {"datacenter": ..., "lat": ..., "lon": ...}
+func (l Location) MarshalJSON() ([]byte, error) {
+	j := locationJSON{Datacenter: l.Datacenter}
+	if l.HasCoordinates {
+		j.Latitude, j.Longitude = &l.Latitude, &l.Longitude
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON reads the form written by MarshalJSON. lat and lon must
+// come together
+func (l *Location) UnmarshalJSON(data []byte) error {
+	var j locationJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if (j.Latitude == nil) != (j.Longitude == nil) {
+		return errors.New("location needs both lat and lon")
+	}
+	loc := Location{Datacenter: j.Datacenter}
+	if j.Latitude != nil {
+		loc.Latitude, loc.Longitude, loc.HasCoordinates = *j.Latitude, *j.Longitude, true
+	}
+	if err := loc.Validate(); err != nil {
+		return err
+	}
+	*l = loc
+	return nil
+}
+
+// ParseLocation parses a comma-separated list of datacenter=, lat= and lon=
+// fields, e.g. "datacenter=fra1,lat=50.11,lon=8.68". An empty string is the
+// unknown location
+func ParseLocation(s string) (Location, error) {
+	var loc Location
+	var hasLat, hasLon bool
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return Location{}, fmt.Errorf("invalid location field %q: expected key=value", field)
+		}
+		switch key {
+		case "datacenter":
+			loc.Datacenter = value
+		case "lat", "lon":
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return Location{}, fmt.Errorf("invalid %s %q", key, value)
+			}
+			if key == "lat" {
+				loc.Latitude, hasLat = v, true
+			} else {
+				loc.Longitude, hasLon = v, true
+			}
+		default:
+			return Location{}, fmt.Errorf("unknown location field %q: expected datacenter, lat or lon", key)
+		}
+	}
+	if hasLat != hasLon {
+		return Location{}, errors.New("location needs both lat and lon")
+	}
+	loc.HasCoordinates = hasLat
+	return loc, loc.Validate()
+}
+
+// UpdateLocation attaches a location to a node already recorded. Unknown
+// nodes are ignored
+func (m *Monitor) UpdateLocation(addr string, loc Location) {
+	shard, addr := m.getShard(addr)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	info, ok := shard.nodes[addr]
+	if !ok {
+		return
+	}
+	info.Location = loc
+	shard.nodes[addr] = info
+}
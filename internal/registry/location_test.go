@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseLocation(t *testing.T) {
+	testCases := []struct {
+		spec string
+		want Location
+	}{
+		{"", Location{}},
+		{"datacenter=fra1", Location{Datacenter: "fra1"}},
+		{"lat=50.11, lon=8.68", Location{Latitude: 50.11, Longitude: 8.68, HasCoordinates: true}},
+		{"datacenter=fra1,lat=0,lon=0", Location{Datacenter: "fra1", HasCoordinates: true}}, // Null Island is a place
+	}
+	for _, tc := range testCases {
+		got, err := ParseLocation(tc.spec)
+		if err != nil {
+			t.Errorf("ParseLocation(%q) error = %v", tc.spec, err)
+		} else if got != tc.want {
+			t.Errorf("ParseLocation(%q) = %+v, want %+v", tc.spec, got, tc.want)
+		}
+	}
+
+	for _, spec := range []string{
+		"lat=50.11",       // Missing lon
+		"lat=north,lon=8", // Not a number
+		"lat=91,lon=0",    // Out of range
+		"lat=0,lon=181",   // Out of range
+		"lat=NaN,lon=0",   // Not a coordinate
+		"lat=0,lon=NaN",   // Not a coordinate
+		"lat=+Inf,lon=0",  // Not a coordinate
+		"lat=0,lon=-Inf",  // Not a coordinate
+		"region=eu",       // Unknown field
+		"datacenter",      // Missing value
+	} {
+		if _, err := ParseLocation(spec); err == nil {
+			t.Errorf("ParseLocation(%q) should return error", spec)
+		}
+	}
+
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if err := (Location{Latitude: v, HasCoordinates: true}).Validate(); err == nil {
+			t.Errorf("Validate() accepted latitude %v", v)
+		}
+		if err := (Location{Longitude: v, HasCoordinates: true}).Validate(); err == nil {
+			t.Errorf("Validate() accepted longitude %v", v)
+		}
+	}
+}
+
+func TestLocationJSON(t *testing.T) {
+	loc := Location{Datacenter: "fra1", Latitude: 50.11, Longitude: 8.68, HasCoordinates: true}
+	data, err := json.Marshal(loc)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := `{"datacenter":"fra1","lat":50.11,"lon":8.68}`; string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+	var decoded Location
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded != loc {
+		t.Errorf("Unmarshal() = %+v, %v; want %+v", decoded, err, loc)
+	}
+
+	if data, _ := json.Marshal(Location{Datacenter: "fra1"}); string(data) != `{"datacenter":"fra1"}` {
+		t.Errorf("Marshal() without coordinates = %s", data)
+	}
+	if err := json.Unmarshal([]byte(`{"lat":50}`), &decoded); err == nil {
+		t.Error("Unmarshal() with lat but no lon should return error")
+	}
+}
+
+func TestMonitorUpdateLocation(t *testing.T) {
+	m := NewMonitor()
+	loc := Location{Datacenter: "fra1"}
+	m.UpdateLocation("10.0.0.1:9999", loc) // Unknown nodes are ignored
+	if m.GetNodeCount() != 0 {
+		t.Fatal("UpdateLocation() added an unknown node")
+	}
+
+	m.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	m.UpdateLocation("10.0.0.1:9999", loc)
+	m.UpdateWithStatus("10.0.0.1:9999", 1, 0)
+	if info, _ := m.GetNodeInfo("10.0.0.1:9999"); info.Location != loc {
+		t.Errorf("Location = %+v after a heartbeat, want %+v kept", info.Location, loc)
+	}
+}
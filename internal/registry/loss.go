@@ -0,0 +1,102 @@
+package registry
+
+import (
+	"math"
+	"time"
+)
+
+// LossWindow is how long heartbeats are counted before the packet loss
+// estimate starts afresh, so it reflects recent conditions
+const LossWindow = 5 * time.Minute
+
+// lossCounter estimates the packet loss from one sender by comparing the
+// heartbeats received with those it sent. Senders numbering their
+// heartbeats give the count directly. For older senders it is estimated
+// from the gaps between timestamps and the advertised interval
+type lossCounter struct {
+	lastTimestamp int64  // Sender timestamp of the previous heartbeat
+	lastSequence  uint32 // Sequence number of the previous heartbeat, 0 if none
+	windowStart   time.Time
+
+	received, expected uint64 // Current window
+	// The previous window, so a fresh window does not start from no data
+	prevReceived, prevExpected uint64
+}
+
+// add counts a heartbeat sent at timestamp, numbered sequence (0 if the
+// sender does not number them) by a sender advertising interval
+func (c *lossCounter) add(timestamp int64, sequence uint32, interval time.Duration, now time.Time) {
+	if now.Sub(c.windowStart) >= LossWindow {
+		c.prevReceived, c.prevExpected = c.received, c.expected
+		c.received, c.expected = 0, 0
+		c.windowStart = now
+	}
+
+	// The first heartbeat, or one after the sender restarted (its sequence
+	// or clock went backwards), gives no gap to measure
+	expected := uint64(1)
+	switch {
+	case sequence != 0 && c.lastSequence != 0:
+		// Unsigned subtraction also spans the wrap, less the skipped 0
+		gap := sequence - c.lastSequence
+		if sequence < c.lastSequence {
+			gap--
+		}
+		if gap > 1 && gap < 1<<31 {
+			expected = uint64(gap)
+		}
+	case sequence == 0 && interval > 0:
+		if gap := timestamp - c.lastTimestamp; c.lastTimestamp != 0 && gap > 0 {
+			if n := math.Round(float64(gap) / float64(interval)); n > 1 {
+				expected = uint64(n)
+			}
+		}
+	}
+	c.lastTimestamp = timestamp
+	c.lastSequence = sequence
+	c.received++
+	c.expected += expected
+}
+
+// loss returns the estimated loss in percent over the current and previous
+// windows, and false before any gap has been measured
+func (c *lossCounter) loss() (float64, bool) {
+	received, expected := c.received+c.prevReceived, c.expected+c.prevExpected
+	if received < 2 {
+		return 0, false
+	}
+	return float64(expected-received) / float64(expected) * 100, true
+}
+
+// UpdatePacketLoss counts a heartbeat towards the node's packet loss
+// estimate. timestamp is the sender's packet timestamp, sequence its
+// heartbeat number and interval its advertised heartbeat interval.
+// Heartbeats with neither a sequence number nor a timestamp and interval
+// are not counted. Without sequence numbers, senders using conditional
+// heartbeats send less often than they advertise and show as lossy.
+// UpdateWithPacket counts the loss itself
+func (m *Monitor) UpdatePacketLoss(addr string, timestamp int64, sequence uint32, interval time.Duration) {
+	shard, addr := m.getShard(addr)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if _, ok := shard.nodes[addr]; !ok {
+		return
+	}
+	shard.countLoss(addr, timestamp, sequence, interval, m.clock.Now())
+}
+
+// countLoss is UpdatePacketLoss for a known node, with the shard locked
+func (s *shard) countLoss(addr string, timestamp int64, sequence uint32, interval time.Duration, now time.Time) {
+	if sequence == 0 && (timestamp == 0 || interval <= 0) {
+		return
+	}
+	if s.losses == nil {
+		s.losses = make(map[string]*lossCounter)
+	}
+	c, ok := s.losses[addr]
+	if !ok {
+		c = &lossCounter{windowStart: now}
+		s.losses[addr] = c
+	}
+	c.add(timestamp, sequence, interval, now)
+}
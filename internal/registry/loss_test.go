@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+	"github.com/rafaelmarinho/pulsecheck/internal/protocol"
+)
+
+func TestMonitorPacketLoss(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	c := clock.NewFake(start)
+	m := NewMonitor()
+	m.SetClock(c)
+	addr := "10.0.0.1:9999"
+	interval := time.Second
+
+	// Heartbeats 0-9 with 2, 5 and 6 lost
+	for i := 0; i < 10; i++ {
+		if i == 2 || i == 5 || i == 6 {
+			continue
+		}
+		ts := start.Add(time.Duration(i) * interval).UnixNano()
+		m.UpdateWithStatus(addr, 0, ts)
+		m.UpdatePacketLoss(addr, ts, 0, interval)
+	}
+
+	info, _ := m.GetNodeInfo(addr)
+	if !info.HasPacketLoss || math.Abs(info.PacketLoss-30) > 0.01 {
+		t.Errorf("PacketLoss = %v (known %v), want 30", info.PacketLoss, info.HasPacketLoss)
+	}
+
+	// Two windows later the early losses have aged out
+	ts := start.Add(9 * interval).UnixNano()
+	for w := 0; w < 2; w++ {
+		c.Advance(LossWindow)
+		for i := 0; i < 5; i++ {
+			ts += int64(interval)
+			m.UpdatePacketLoss(addr, ts, 0, interval)
+		}
+	}
+	if info, _ := m.GetNodeInfo(addr); info.PacketLoss != 0 {
+		t.Errorf("PacketLoss after clean windows = %v, want 0", info.PacketLoss)
+	}
+}
+
+func TestMonitorPacketLossUnknown(t *testing.T) {
+	m := NewMonitor()
+	addr := "10.0.0.1:9999"
+
+	m.UpdatePacketLoss(addr, 1, 0, time.Second) // Unknown node
+	m.UpdateWithStatus(addr, 0, 1)
+	m.UpdatePacketLoss(addr, 1, 0, time.Second)
+	m.UpdatePacketLoss(addr, 2, 0, 0) // No advertised interval
+	if info, _ := m.GetNodeInfo(addr); info.HasPacketLoss {
+		t.Errorf("loss known after a single heartbeat: %+v", info)
+	}
+
+	// A sender clock going backwards, e.g. after a restart, is not loss
+	m.UpdatePacketLoss(addr, 1-int64(time.Hour), 0, time.Second)
+	if info, _ := m.GetNodeInfo(addr); !info.HasPacketLoss || info.PacketLoss != 0 {
+		t.Errorf("PacketLoss after a clock jump = %v (known %v), want 0", info.PacketLoss, info.HasPacketLoss)
+	}
+
+	// Reaped nodes start over
+	shard, _ := m.getShard(addr)
+	shard.mu.Lock()
+	shard.remove(addr)
+	shard.mu.Unlock()
+	m.UpdateWithStatus(addr, 0, 1)
+	if info, _ := m.GetNodeInfo(addr); info.HasPacketLoss {
+		t.Errorf("loss kept across removal: %+v", info)
+	}
+}
+
+func TestMonitorPacketLossSequence(t *testing.T) {
+	m := NewMonitor()
+	addr := "10.0.0.1:9999"
+	pkt := &protocol.Packet{NodeUUID: [16]byte{1}, Interval: protocol.IntervalUnits(time.Second), Priority: 4}
+
+	// Sequences 1-10 with 3, 6 and 7 lost. Timestamps an hour apart, as
+	// from a conditional sender, do not count: only the sequence does
+	for seq := uint32(1); seq <= 10; seq++ {
+		if seq == 3 || seq == 6 || seq == 7 {
+			continue
+		}
+		pkt.Sequence = seq
+		pkt.Timestamp = int64(seq) * int64(time.Hour)
+		if !m.UpdateWithPacket(addr, pkt) {
+			t.Fatalf("UpdateWithPacket(%d) = false", seq)
+		}
+	}
+	info, _ := m.GetNodeInfo(addr)
+	if !info.HasPacketLoss || math.Abs(info.PacketLoss-30) > 0.01 {
+		t.Errorf("PacketLoss = %v (known %v), want 30", info.PacketLoss, info.HasPacketLoss)
+	}
+	if info.Priority != 4 || info.HeartbeatInterval != time.Second {
+		t.Errorf("UpdateWithPacket() Priority = %d, HeartbeatInterval = %v, want 4 and 1s", info.Priority, info.HeartbeatInterval)
+	}
+
+	// Wrapping past 0 is no loss, nor is a restart from 1
+	m = NewMonitor()
+	for i, seq := range []uint32{math.MaxUint32 - 1, math.MaxUint32, 1, 2, 1, 2} {
+		pkt.Sequence = seq
+		pkt.Timestamp = int64(i + 1)
+		m.UpdateWithPacket(addr, pkt)
+	}
+	if info, _ := m.GetNodeInfo(addr); !info.HasPacketLoss || info.PacketLoss != 0 {
+		t.Errorf("PacketLoss across a wrap and restart = %v (known %v), want 0", info.PacketLoss, info.HasPacketLoss)
+	}
+}
@@ -1,10 +1,13 @@
 package registry
 
 import (
+	"container/list"
 	"hash/fnv"
-	"log"
 	"sync"
 	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+	"github.com/rafaelmarinho/pulsecheck/internal/protocol"
 )
 
 const (
@@ -14,123 +17,497 @@ const (
 )
 
 type NodeInfo struct {
-	LastSeen    time.Time // Local time when packet was received (handles clock skew)
-	Address     string
-	CPUPercent  float64
-	RAMPercent  float64
-	DiskPercent float64
-	StatusCode  uint8
-	PacketTime  int64         // Sender's timestamp (for RTT calculation)
-	RTT         time.Duration // Calculated round-trip time
+	LastSeen     time.Time // Local time when packet was received (handles clock skew)
+	Address      string
+	CPUPercent   float64
+	RAMPercent   float64
+	DiskPercent  float64
+	HasTelemetry bool // True once telemetry has been received; false means the percentages are unknown, not 0%
+	StatusCode   uint8
+	PacketTime   int64         // Sender's timestamp (for RTT calculation), clamped to the skew tolerance
+	RTT          time.Duration // Calculated round-trip time
+	Phi          float64       // Phi accrual suspicion level (computed on read)
+	Probed       bool          // True for agentless targets polled by the prober
+	Silenced     bool          // True while inside a maintenance window (computed on read)
+	Suppressed   bool          // True while failing behind a down dependency, see SetDependencies (computed on read, except by GetNodeInfo)
+	UUID         [16]byte      // Sender's node UUID from its heartbeats (zero if unknown)
+	ClockSkew    time.Duration // Sender's timestamp minus our receive time, latency included (positive if its clock is ahead)
+
+	// Network throughput in bytes per second; only known for the local node
+	// when interfaces are selected for sampling
+	NetRxBytesPerSec float64
+	NetTxBytesPerSec float64
+	HasNetwork       bool
+
+	// Heartbeat interval advertised by the sender (0 if unknown, e.g. from
+	// peers predating packet version 4)
+	HeartbeatInterval time.Duration
+
+	// Operator-assigned priority advertised by the sender, higher preferred
+	// by leader election (0, the default, for peers predating packet
+	// version 5)
+	Priority uint8
+
+	// Where the node is, for map dashboards; known for the local node and
+	// nodes pushing reports over HTTP
+	Location Location
+
+	// Pod, namespace and Kubernetes node, known for the local node and
+	// nodes pushing reports over HTTP when run in Kubernetes
+	Kubernetes Kubernetes
+
+	// Estimated share of the node's heartbeats lost in transit over the
+	// last LossWindow or two, in percent (computed on read). Only known for
+	// UDP peers advertising their heartbeat interval
+	PacketLoss    float64
+	HasPacketLoss bool
+
+	// Collector this node was merged from by federation, empty for nodes
+	// seen directly
+	FederatedFrom string
 }
 
 // shard represents a single shard of the sharded map
 type shard struct {
-	nodes map[string]NodeInfo
-	mu    sync.RWMutex
+	nodes    map[string]NodeInfo
+	arrivals map[string]*arrivalWindow // Heartbeat inter-arrival history for phi accrual
+	losses   map[string]*lossCounter   // Packet loss estimates, see UpdatePacketLoss
+	mu       sync.RWMutex
+
+	// Nodes ordered by when they were last updated, most recent first, so
+	// SetMaxNodes evicts without scanning. Only kept when the monitor is
+	// capped, see touch
+	order *list.List
+	elems map[string]*list.Element
+}
+
+// recordArrival records a heartbeat arrival for the phi accrual detector
+// Caller must hold the shard write lock
+func (s *shard) recordArrival(addr string, now time.Time) {
+	if s.arrivals == nil {
+		s.arrivals = make(map[string]*arrivalWindow)
+	}
+	w, ok := s.arrivals[addr]
+	if !ok {
+		w = &arrivalWindow{}
+		s.arrivals[addr] = w
+	}
+	w.add(now)
+}
+
+// withComputed returns info with its phi value computed at the given time
+// and its packet loss estimate. Caller must hold the shard read lock
+func (s *shard) withComputed(addr string, info NodeInfo, now time.Time) NodeInfo {
+	if w, ok := s.arrivals[addr]; ok {
+		info.Phi = w.phi(now)
+	}
+	if c, ok := s.losses[addr]; ok {
+		info.PacketLoss, info.HasPacketLoss = c.loss()
+	}
+	return info
+}
+
+// remove deletes a node, its arrival history and its loss estimate
+// Caller must hold the shard write lock
+func (s *shard) remove(addr string) {
+	delete(s.nodes, addr)
+	delete(s.arrivals, addr)
+	delete(s.losses, addr)
+	if elem, ok := s.elems[addr]; ok {
+		s.order.Remove(elem)
+		delete(s.elems, addr)
+	}
 }
 
 // Monitor uses a sharded map to reduce lock contention
 // Operations on different shards can proceed concurrently
 type Monitor struct {
-	shards [numShards]*shard
+	shards    [numShards]*shard
+	silences  map[string]time.Time // Maintenance windows keyed by address
+	silenceMu sync.RWMutex
+	onEvent   func(Event) // Optional change handler, see SetEventHandler
+	clock     clock.Clock
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+
+	// Duplicate identity detection, see SetDuplicateWindow
+	dupWindow  time.Duration
+	identities map[[16]byte]map[string]time.Time // Last sighting per address for each UUID
+	identityMu sync.Mutex
+
+	skewTolerance time.Duration // See SetSkewTolerance
+
+	self [16]byte // This node's UUID, see SetSelf
+
+	// Peer UUIDs each node reported seeing, see UpdatePeerView
+	views  map[string]map[[16]byte]struct{}
+	viewMu sync.Mutex
+
+	reaper    ReaperStats
+	reaperMu  sync.Mutex
+	reapBurst int // See SetReapBurst
+	shardCap  int // Nodes per shard, see SetMaxNodes (0 is unbounded)
+
+	dependencies map[string][]string // See SetDependencies
+	dependedOn   map[string]bool     // Every address some node depends on
+	reapedDeps   map[string]bool     // Dependencies reaped since startup
+	reapedDepsMu sync.Mutex
 }
 
 // NewMonitor creates a new monitor instance with sharded map
 func NewMonitor() *Monitor {
-	m := &Monitor{}
+	m := &Monitor{
+		silences: make(map[string]time.Time),
+		clock:    clock.Real(),
+		stopChan: make(chan struct{}),
+
+		identities: make(map[[16]byte]map[string]time.Time),
+		views:      make(map[string]map[[16]byte]struct{}),
+
+		skewTolerance: DefaultSkewTolerance,
+	}
 	for i := 0; i < numShards; i++ {
 		m.shards[i] = &shard{
-			nodes: make(map[string]NodeInfo),
+			nodes:    make(map[string]NodeInfo),
+			arrivals: make(map[string]*arrivalWindow),
+			losses:   make(map[string]*lossCounter),
 		}
 	}
 	return m
 }
 
-// getShard returns the shard for a given address
+// SetClock replaces the clock used for LastSeen, phi and the reapers
+// Must be called before the monitor is used
+func (m *Monitor) SetClock(c clock.Clock) {
+	m.clock = c
+}
+
+// Now returns the time on the monitor's clock, which LastSeen is stamped
+// with, so that callers comparing against LastSeen use the same clock
+func (m *Monitor) Now() time.Time {
+	return m.clock.Now()
+}
+
+// getShard returns the shard for a given address and the canonical form
+// of the address that keys it there (see CanonicalAddr)
 // Uses FNV-1a hash for good distribution
-func (m *Monitor) getShard(addr string) *shard {
+func (m *Monitor) getShard(addr string) (*shard, string) {
+	addr = CanonicalAddr(addr)
 	h := fnv.New32a()
 	h.Write([]byte(addr))
 	// Use bitwise AND instead of modulo for efficiency (numShards is power of 2)
 	shardIndex := h.Sum32() & (numShards - 1)
-	return m.shards[shardIndex]
+	return m.shards[shardIndex], addr
 }
 
 // Update updates the heartbeat for a node
 func (m *Monitor) Update(addr string) {
-	shard := m.getShard(addr)
+	shard, addr := m.getShard(addr)
 	shard.mu.Lock()
 	defer shard.mu.Unlock()
 	if shard.nodes == nil {
 		shard.nodes = make(map[string]NodeInfo)
 	}
-	shard.nodes[addr] = NodeInfo{
-		LastSeen: time.Now(),
+	now := m.clock.Now()
+	prev, existed := shard.nodes[addr]
+	if !existed {
+		m.makeRoom(shard, now)
+	}
+	info := NodeInfo{
+		LastSeen: now,
 		Address:  addr,
 	}
+	shard.nodes[addr] = info
+	m.touch(shard, addr, now)
+	shard.recordArrival(addr, now)
+	m.emitUpdate(prev, existed, info)
 }
 
 // UpdateWithStatus updates the heartbeat with status code and timestamp
 // Uses local time.Now() for LastSeen to handle clock skew, but stores packet timestamp for RTT
 func (m *Monitor) UpdateWithStatus(addr string, statusCode uint8, packetTimestamp int64) {
-	shard := m.getShard(addr)
+	m.updateHeartbeat(addr, nil, statusCode, packetTimestamp, nil)
+}
+
+// UpdateWithHeartbeat is UpdateWithStatus that also records the sender's UUID
+// Returns false for a retransmitted copy of the last heartbeat, which is ignored
+func (m *Monitor) UpdateWithHeartbeat(addr string, uuid [16]byte, statusCode uint8, packetTimestamp int64) bool {
+	return m.updateHeartbeat(addr, &uuid, statusCode, packetTimestamp, nil)
+}
+
+// UpdateWithPacket is UpdateWithHeartbeat for a received packet, which also
+// records its priority and advertised interval and counts it towards the
+// packet loss estimate, all in one update
+func (m *Monitor) UpdateWithPacket(addr string, pkt *protocol.Packet) bool {
+	return m.updateHeartbeat(addr, &pkt.NodeUUID, pkt.StatusCode, pkt.Timestamp, pkt)
+}
+
+// updateHeartbeat records a heartbeat, keeping the stored UUID if uuid is nil.
+// A heartbeat with the same non-zero timestamp as the previous one is a
+// retransmitted copy: it is ignored so it neither emits events nor skews
+// the phi inter-arrival history, and false is returned. The packet's
+// other fields are recorded when pkt is not nil
+func (m *Monitor) updateHeartbeat(addr string, uuid *[16]byte, statusCode uint8, packetTimestamp int64, pkt *protocol.Packet) bool {
+	shard, addr := m.getShard(addr)
 	shard.mu.Lock()
 	defer shard.mu.Unlock()
 	if shard.nodes == nil {
 		shard.nodes = make(map[string]NodeInfo)
 	}
+	if w := shard.arrivals[addr]; w != nil && packetTimestamp != 0 && packetTimestamp == w.lastTimestamp {
+		return false
+	}
 
-	now := time.Now()
-	info := shard.nodes[addr]
+	now := m.clock.Now()
+	prev, existed := shard.nodes[addr]
+	if !existed {
+		m.makeRoom(shard, now)
+	}
+	info := prev
 
 	// Use local time for LastSeen to handle clock skew between nodes
 	// This ensures reaper logic works correctly even with time differences
 	info.LastSeen = now
 	info.Address = addr
 	info.StatusCode = statusCode
-	info.PacketTime = packetTimestamp
+	info.FederatedFrom = ""
+	if uuid != nil {
+		info.UUID = *uuid
+	}
+
+	// LastSeen is local time, so the sender's clock never affects staleness.
+	// Its timestamp is kept for skew and latency analysis
+	m.recordPacketTime(&info, prev, packetTimestamp, now)
 
-	// Calculate RTT estimation
-	// Note: True RTT requires echo packets, but we can estimate based on clock differences
-	// For now, we store the packet timestamp for potential future RTT calculation
-	// The packet timestamp can be used to detect clock skew and estimate network latency
-	if packetTimestamp > 0 {
-		// Store packet timestamp for RTT/latency analysis
-		// In a production system, you might implement echo packets for true RTT
+	if pkt != nil {
+		// Older packets carry no priority and decode as the default, 0
+		info.Priority = pkt.Priority
+		if pkt.Interval != 0 {
+			info.HeartbeatInterval = pkt.HeartbeatInterval()
+		}
 	}
 
 	shard.nodes[addr] = info
+	m.touch(shard, addr, now)
+	shard.recordArrival(addr, now)
+	shard.arrivals[addr].lastTimestamp = packetTimestamp
+	if pkt != nil {
+		shard.countLoss(addr, pkt.Timestamp, pkt.Sequence, pkt.HeartbeatInterval(), now)
+	}
+	m.emitUpdate(prev, existed, info)
+	if uuid != nil {
+		m.trackIdentity(*uuid, addr, now)
+	}
+	return true
 }
 
 // UpdateWithTelemetry updates the heartbeat with full telemetry data
 func (m *Monitor) UpdateWithTelemetry(addr string, cpuPercent, ramPercent, diskPercent float64, statusCode uint8) {
-	shard := m.getShard(addr)
+	shard, addr := m.getShard(addr)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if shard.nodes == nil {
+		shard.nodes = make(map[string]NodeInfo)
+	}
+	now := m.clock.Now()
+	prev, existed := shard.nodes[addr]
+	if !existed {
+		m.makeRoom(shard, now)
+	}
+	info := NodeInfo{
+		LastSeen:     now,
+		Address:      addr,
+		CPUPercent:   cpuPercent,
+		RAMPercent:   ramPercent,
+		DiskPercent:  diskPercent,
+		HasTelemetry: true,
+		StatusCode:   statusCode,
+	}
+	shard.nodes[addr] = info
+	m.touch(shard, addr, now)
+	shard.recordArrival(addr, now)
+	m.emitUpdate(prev, existed, info)
+}
+
+// LocalUpdate is one heartbeat of the local node, see UpdateLocal
+type LocalUpdate struct {
+	UUID       [16]byte
+	StatusCode uint8
+
+	// Telemetry, when HasTelemetry is set. Without it the last-known
+	// readings stay
+	HasTelemetry bool
+	CPUPercent   float64
+	RAMPercent   float64
+	DiskPercent  float64
+
+	// Network throughput, when HasNetwork is set
+	HasNetwork       bool
+	NetRxBytesPerSec float64
+	NetTxBytesPerSec float64
+
+	// Location is the configured datacenter and coordinates, if any
+	Location Location
+
+	// Kubernetes is the pod the node runs in, if any
+	Kubernetes Kubernetes
+}
+
+// UpdateLocal records a heartbeat of the local node in one locked update,
+// so readers never see the entry without the fields its heartbeats carry
+// separately from UpdateWithTelemetry, such as its UUID, location or pod
+func (m *Monitor) UpdateLocal(addr string, u LocalUpdate) {
+	shard, addr := m.getShard(addr)
 	shard.mu.Lock()
 	defer shard.mu.Unlock()
 	if shard.nodes == nil {
 		shard.nodes = make(map[string]NodeInfo)
 	}
-	shard.nodes[addr] = NodeInfo{
-		LastSeen:    time.Now(),
-		Address:     addr,
-		CPUPercent:  cpuPercent,
-		RAMPercent:  ramPercent,
-		DiskPercent: diskPercent,
-		StatusCode:  statusCode,
+	now := m.clock.Now()
+	prev, existed := shard.nodes[addr]
+	if !existed {
+		m.makeRoom(shard, now)
+	}
+	info := prev
+	info.LastSeen = now
+	info.Address = addr
+	info.UUID = u.UUID
+	info.StatusCode = u.StatusCode
+	info.FederatedFrom = ""
+	info.Location = u.Location
+	info.Kubernetes = u.Kubernetes
+	if u.HasTelemetry {
+		info.CPUPercent = u.CPUPercent
+		info.RAMPercent = u.RAMPercent
+		info.DiskPercent = u.DiskPercent
+		info.HasTelemetry = true
+		info.NetRxBytesPerSec = u.NetRxBytesPerSec
+		info.NetTxBytesPerSec = u.NetTxBytesPerSec
+		info.HasNetwork = u.HasNetwork
+	}
+	shard.nodes[addr] = info
+	m.touch(shard, addr, now)
+	shard.recordArrival(addr, now)
+	m.emitUpdate(prev, existed, info)
+}
+
+// UpdateNetwork attaches network throughput to a node already recorded by
+// UpdateWithTelemetry. Unknown nodes are ignored
+func (m *Monitor) UpdateNetwork(addr string, rxBytesPerSec, txBytesPerSec float64) {
+	shard, addr := m.getShard(addr)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	info, ok := shard.nodes[addr]
+	if !ok {
+		return
 	}
+	info.NetRxBytesPerSec = rxBytesPerSec
+	info.NetTxBytesPerSec = txBytesPerSec
+	info.HasNetwork = true
+	shard.nodes[addr] = info
+}
+
+// UpdateHeartbeatInterval records the heartbeat interval a node advertises
+// Unknown nodes are ignored
+func (m *Monitor) UpdateHeartbeatInterval(addr string, interval time.Duration) {
+	shard, addr := m.getShard(addr)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	info, ok := shard.nodes[addr]
+	if !ok {
+		return
+	}
+	info.HeartbeatInterval = interval
+	shard.nodes[addr] = info
+}
+
+// UpdatePriority records the priority a node advertises
+// Unknown nodes are ignored
+func (m *Monitor) UpdatePriority(addr string, priority uint8) {
+	shard, addr := m.getShard(addr)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	info, ok := shard.nodes[addr]
+	if !ok {
+		return
+	}
+	info.Priority = priority
+	shard.nodes[addr] = info
+}
+
+// UpdateWithReport records a heartbeat that carries the sender's UUID and
+// telemetry together, as relayed over HTTP by nodes that cannot use UDP
+func (m *Monitor) UpdateWithReport(addr string, uuid [16]byte, statusCode uint8, packetTimestamp int64, cpuPercent, ramPercent, diskPercent float64) {
+	shard, addr := m.getShard(addr)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if shard.nodes == nil {
+		shard.nodes = make(map[string]NodeInfo)
+	}
+	now := m.clock.Now()
+	prev, existed := shard.nodes[addr]
+	if !existed {
+		m.makeRoom(shard, now)
+	}
+	info := prev
+	info.LastSeen = now
+	info.Address = addr
+	info.UUID = uuid
+	info.StatusCode = statusCode
+	info.FederatedFrom = ""
+	m.recordPacketTime(&info, prev, packetTimestamp, now)
+	info.CPUPercent = cpuPercent
+	info.RAMPercent = ramPercent
+	info.DiskPercent = diskPercent
+	info.HasTelemetry = true
+	shard.nodes[addr] = info
+	m.touch(shard, addr, now)
+	shard.recordArrival(addr, now)
+	m.emitUpdate(prev, existed, info)
+	m.trackIdentity(uuid, addr, now)
+}
+
+// UpdateWithProbe records the result of actively polling an agentless target
+// The probe latency is stored as the node's RTT
+func (m *Monitor) UpdateWithProbe(addr string, statusCode uint8, rtt time.Duration) {
+	shard, addr := m.getShard(addr)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if shard.nodes == nil {
+		shard.nodes = make(map[string]NodeInfo)
+	}
+	now := m.clock.Now()
+	prev, existed := shard.nodes[addr]
+	if !existed {
+		m.makeRoom(shard, now)
+	}
+	info := NodeInfo{
+		LastSeen:   now,
+		Address:    addr,
+		StatusCode: statusCode,
+		RTT:        rtt,
+		Probed:     true,
+	}
+	shard.nodes[addr] = info
+	m.touch(shard, addr, now)
+	shard.recordArrival(addr, now)
+	m.emitUpdate(prev, existed, info)
 }
 
 // GetNodes returns a copy of all known nodes from all shards
 func (m *Monitor) GetNodes() map[string]NodeInfo {
 	// Lock all shards for reading (could be optimized with concurrent reads)
 	result := make(map[string]NodeInfo)
+	now := m.clock.Now()
+	down := m.downDependencies()
 
 	for i := 0; i < numShards; i++ {
 		shard := m.shards[i]
 		shard.mu.RLock()
 		for k, v := range shard.nodes {
-			result[k] = v
+			v.Silenced = m.isSilenced(k, now)
+			v.Suppressed = m.suppressed(k, v, down)
+			result[k] = shard.withComputed(k, v, now)
 		}
 		shard.mu.RUnlock()
 	}
@@ -138,6 +515,51 @@ func (m *Monitor) GetNodes() map[string]NodeInfo {
 	return result
 }
 
+// ForEachNode calls fn for every known node, one shard at a time. Unlike
+// GetNodes it never holds more than one shard's copy, so very large
+// clusters can be walked, e.g. to stream a report, without copying the
+// whole registry. fn runs without locks held and may call the monitor.
+// Nodes are visited in no particular order and fn returning false stops
+// the walk
+func (m *Monitor) ForEachNode(fn func(addr string, info NodeInfo) bool) {
+	type entry struct {
+		addr string
+		info NodeInfo
+	}
+	var batch []entry
+	down := m.downDependencies()
+	for i := 0; i < numShards; i++ {
+		shard := m.shards[i]
+		now := m.clock.Now()
+		batch = batch[:0]
+		shard.mu.RLock()
+		for k, v := range shard.nodes {
+			v.Silenced = m.isSilenced(k, now)
+			v.Suppressed = m.suppressed(k, v, down)
+			batch = append(batch, entry{k, shard.withComputed(k, v, now)})
+		}
+		shard.mu.RUnlock()
+		for _, e := range batch {
+			if !fn(e.addr, e.info) {
+				return
+			}
+		}
+	}
+}
+
+// GetNodesWithoutTelemetry returns the heartbeating nodes that have never
+// sent telemetry, such as older agents or status-only relays. Probed
+// targets are not agents and are left out
+func (m *Monitor) GetNodesWithoutTelemetry() map[string]NodeInfo {
+	result := make(map[string]NodeInfo)
+	for addr, info := range m.GetNodes() {
+		if !info.HasTelemetry && !info.Probed {
+			result[addr] = info
+		}
+	}
+	return result
+}
+
 // GetNodeCount returns the total number of active nodes across all shards
 func (m *Monitor) GetNodeCount() int {
 	total := 0
@@ -150,31 +572,129 @@ func (m *Monitor) GetNodeCount() int {
 	return total
 }
 
+// Summary aggregates node counts by status code
+// Silenced nodes are counted only in Maintenance, and suppressed ones only
+// in Suppressed
+type Summary struct {
+	Total       int
+	OK          int
+	Warn        int
+	Critical    int
+	Maintenance int
+	Suppressed  int
+}
+
+// WorstStatus returns the most severe status code present (0: OK, 1: Warn, 2: Critical)
+func (s Summary) WorstStatus() uint8 {
+	if s.Critical > 0 {
+		return 2
+	}
+	if s.Warn > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Add counts a single node in the summary
+// Unknown status codes are counted as Critical
+func (s *Summary) Add(info NodeInfo) {
+	s.Total++
+	if info.Silenced {
+		s.Maintenance++
+		return
+	}
+	if info.Suppressed {
+		s.Suppressed++
+		return
+	}
+	switch info.StatusCode {
+	case 0:
+		s.OK++
+	case 1:
+		s.Warn++
+	default:
+		s.Critical++
+	}
+}
+
+// Summarize returns node counts by status across all shards
+// Unknown status codes are counted as Critical
+func (m *Monitor) Summarize() Summary {
+	var sum Summary
+	now := m.clock.Now()
+	down := m.downDependencies()
+	for i := 0; i < numShards; i++ {
+		shard := m.shards[i]
+		shard.mu.RLock()
+		for addr, info := range shard.nodes {
+			info.Silenced = m.isSilenced(addr, now)
+			info.Suppressed = m.suppressed(addr, info, down)
+			sum.Add(info)
+		}
+		shard.mu.RUnlock()
+	}
+	return sum
+}
+
 // GetNodeInfo returns information about a specific node
 func (m *Monitor) GetNodeInfo(addr string) (NodeInfo, bool) {
-	shard := m.getShard(addr)
+	shard, addr := m.getShard(addr)
 	shard.mu.RLock()
 	defer shard.mu.RUnlock()
 	info, ok := shard.nodes[addr]
-	return info, ok
+	if !ok {
+		return info, false
+	}
+	now := m.clock.Now()
+	info.Silenced = m.isSilenced(addr, now)
+	return shard.withComputed(addr, info, now), true
 }
 
 // StartReaper runs in a goroutine to remove stale nodes
 // With sharded map, reaper processes each shard independently, reducing lock contention
 func (m *Monitor) StartReaper(interval time.Duration, timeout time.Duration) {
-	ticker := time.NewTicker(interval)
-	for range ticker.C {
+	ticker := m.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stopChan:
+			return
+		case <-ticker.C():
+		}
+		now := m.clock.Now()
+		reaped := 0
 		// Process each shard independently - allows concurrent operations on other shards
 		for i := 0; i < numShards; i++ {
-			shard := m.shards[i]
-			shard.mu.Lock()
-			for addr, info := range shard.nodes {
-				if time.Since(info.LastSeen) > timeout {
-					delete(shard.nodes, addr)
-					log.Printf("Node %s timed out", addr)
-				}
-			}
-			shard.mu.Unlock()
+			reaped += m.reapShard(m.shards[i], now, timeoutExpiry(now, timeout))
 		}
+		m.recordReapCycle(reaped, now)
 	}
 }
+
+// StartPhiReaper runs in a goroutine to remove nodes using the phi accrual failure detector
+// A node is removed once its phi exceeds threshold. Nodes without enough heartbeat
+// history to estimate phi fall back to the fixed timeout
+func (m *Monitor) StartPhiReaper(interval time.Duration, threshold float64, fallbackTimeout time.Duration) {
+	ticker := m.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stopChan:
+			return
+		case <-ticker.C():
+		}
+		now := m.clock.Now()
+		reaped := 0
+		for i := 0; i < numShards; i++ {
+			reaped += m.reapShard(m.shards[i], now, phiExpiry(now, threshold, fallbackTimeout))
+		}
+		m.recordReapCycle(reaped, now)
+	}
+}
+
+// Stop stops any running reaper goroutines. Safe to call more than once
+func (m *Monitor) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopChan)
+	})
+}
@@ -1,8 +1,11 @@
 package registry
 
 import (
+	"fmt"
 	"testing"
 	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
 )
 
 func TestNewMonitor(t *testing.T) {
@@ -102,6 +105,65 @@ func TestMonitorUpdateWithTelemetry(t *testing.T) {
 	}
 }
 
+func TestMonitorHasTelemetry(t *testing.T) {
+	m := NewMonitor()
+	addr := "192.168.1.100:9999"
+
+	m.UpdateWithStatus(addr, 0, time.Now().UnixNano())
+	if info, _ := m.GetNodeInfo(addr); info.HasTelemetry {
+		t.Error("HasTelemetry = true before any telemetry was received")
+	}
+
+	m.UpdateWithTelemetry(addr, 0, 0, 0, 0)
+	if info, _ := m.GetNodeInfo(addr); !info.HasTelemetry {
+		t.Error("HasTelemetry = false after zero-valued telemetry")
+	}
+
+	// A later status-only heartbeat keeps the telemetry flag
+	m.UpdateWithStatus(addr, 1, time.Now().UnixNano())
+	if info, _ := m.GetNodeInfo(addr); !info.HasTelemetry {
+		t.Error("HasTelemetry lost after status-only update")
+	}
+}
+
+func TestMonitorGetNodesWithoutTelemetry(t *testing.T) {
+	m := NewMonitor()
+	m.UpdateWithStatus("10.0.0.1:9999", 0, 0)          // Old agent
+	m.UpdateWithTelemetry("10.0.0.2:9999", 0, 0, 0, 0) // Reports telemetry
+	m.UpdateWithStatus("10.0.0.3:9999", 0, 0)
+	m.UpdateWithTelemetry("10.0.0.3:9999", 10, 20, 30, 0) // Upgraded since
+	m.UpdateWithProbe("db.internal:5432", 0, time.Millisecond)
+
+	got := m.GetNodesWithoutTelemetry()
+	if _, ok := got["10.0.0.1:9999"]; !ok || len(got) != 1 {
+		t.Errorf("GetNodesWithoutTelemetry() = %v, want only 10.0.0.1:9999", got)
+	}
+}
+
+func TestMonitorIgnoresRetransmittedHeartbeat(t *testing.T) {
+	m := NewMonitor()
+	var uuid [16]byte
+	addr := "10.0.0.1:9999"
+
+	if !m.UpdateWithHeartbeat(addr, uuid, 2, 1000) {
+		t.Error("UpdateWithHeartbeat() = false for a new heartbeat")
+	}
+	if m.UpdateWithHeartbeat(addr, uuid, 2, 1000) {
+		t.Error("UpdateWithHeartbeat() = true for a retransmitted copy")
+	}
+	if !m.UpdateWithHeartbeat(addr, uuid, 0, 2000) {
+		t.Error("UpdateWithHeartbeat() = false for the next heartbeat")
+	}
+	if info, _ := m.GetNodeInfo(addr); info.StatusCode != 0 || info.PacketTime != 2000 {
+		t.Errorf("node = %+v, want the latest heartbeat", info)
+	}
+
+	// Senders without timestamps are never treated as copies
+	if !m.UpdateWithHeartbeat("10.0.0.2:9999", uuid, 0, 0) || !m.UpdateWithHeartbeat("10.0.0.2:9999", uuid, 0, 0) {
+		t.Error("UpdateWithHeartbeat() = false for a heartbeat without timestamp")
+	}
+}
+
 func TestMonitorGetNodeCount(t *testing.T) {
 	m := NewMonitor()
 
@@ -132,6 +194,31 @@ func TestMonitorGetNodeCount(t *testing.T) {
 	}
 }
 
+func TestMonitorSummarize(t *testing.T) {
+	m := NewMonitor()
+
+	if got := m.Summarize().WorstStatus(); got != 0 {
+		t.Errorf("WorstStatus() on empty monitor = %d, want 0", got)
+	}
+
+	m.UpdateWithStatus("192.168.1.10:9999", 0, time.Now().UnixNano())
+	m.UpdateWithStatus("192.168.1.11:9999", 0, time.Now().UnixNano())
+	m.UpdateWithStatus("192.168.1.12:9999", 1, time.Now().UnixNano())
+
+	sum := m.Summarize()
+	if sum.Total != 3 || sum.OK != 2 || sum.Warn != 1 || sum.Critical != 0 {
+		t.Errorf("Summarize() = %+v, want Total=3 OK=2 Warn=1 Critical=0", sum)
+	}
+	if got := sum.WorstStatus(); got != 1 {
+		t.Errorf("WorstStatus() = %d, want 1", got)
+	}
+
+	m.UpdateWithStatus("192.168.1.13:9999", 2, time.Now().UnixNano())
+	if got := m.Summarize().WorstStatus(); got != 2 {
+		t.Errorf("WorstStatus() with critical node = %d, want 2", got)
+	}
+}
+
 func TestMonitorGetNodes(t *testing.T) {
 	m := NewMonitor()
 
@@ -191,13 +278,28 @@ func TestMonitorConcurrentUpdates(t *testing.T) {
 	}
 }
 
+// reapedChan returns a channel receiving the address of every node the
+// monitor's reapers remove
+func reapedChan(m *Monitor) <-chan string {
+	reaped := make(chan string, 16)
+	m.SetEventHandler(func(e Event) {
+		if e.Type == EventLeft {
+			reaped <- e.Address
+		}
+	})
+	return reaped
+}
+
 func TestMonitorReaper(t *testing.T) {
 	m := NewMonitor()
-	shortTimeout := 100 * time.Millisecond
-	reaperInterval := 50 * time.Millisecond
+	fake := clock.NewFake(time.Now())
+	m.SetClock(fake)
+	reaped := reapedChan(m)
+	defer m.Stop()
 
 	// Start reaper
-	go m.StartReaper(reaperInterval, shortTimeout)
+	go m.StartReaper(50*time.Millisecond, 100*time.Millisecond)
+	fake.BlockUntil(1)
 
 	// Add a node
 	addr := "192.168.1.100:9999"
@@ -208,8 +310,16 @@ func TestMonitorReaper(t *testing.T) {
 		t.Errorf("GetNodeCount() before timeout = %d, want 1", count)
 	}
 
-	// Wait for reaper to clean up
-	time.Sleep(shortTimeout + reaperInterval + 50*time.Millisecond)
+	// Move past the timeout and the next reaper tick
+	fake.Advance(150 * time.Millisecond)
+	select {
+	case got := <-reaped:
+		if got != addr {
+			t.Errorf("reaped %s, want %s", got, addr)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("reaper did not remove the timed out node")
+	}
 
 	// Node should be removed
 	if count := m.GetNodeCount(); count != 0 {
@@ -222,26 +332,93 @@ func TestMonitorReaper(t *testing.T) {
 	}
 }
 
+func TestMonitorReaperStats(t *testing.T) {
+	m := NewMonitor()
+	fake := clock.NewFake(time.Now())
+	m.SetClock(fake)
+	defer m.Stop()
+
+	go m.StartReaper(50*time.Millisecond, 100*time.Millisecond)
+	fake.BlockUntil(1)
+
+	// waitCycles waits for the reaper to finish n cycles in total
+	waitCycles := func(n uint64) ReaperStats {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			stats := m.ReaperStats()
+			if stats.Cycles >= n {
+				return stats
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("reaper ran %d cycles, want %d", stats.Cycles, n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	m.Update("10.0.0.1:9999")
+	m.Update("10.0.0.2:9999")
+	fake.Advance(60 * time.Millisecond)
+	if stats := waitCycles(1); stats.NodesReapedTotal != 0 || stats.LastBatchSize != 0 {
+		t.Errorf("ReaperStats() before any timeout = %+v", stats)
+	}
+	m.Update("10.0.0.3:9999")
+
+	fake.Advance(50 * time.Millisecond)
+	stats := waitCycles(2)
+	if stats.NodesReapedTotal != 2 || stats.LastBatchSize != 2 || !stats.LastReap.Equal(fake.Now()) {
+		t.Errorf("ReaperStats() = %+v, want 2 reaped in one batch at %v", stats, fake.Now())
+	}
+
+	// A quiet cycle resets the batch size but not the total
+	fake.Advance(50 * time.Millisecond)
+	if stats = waitCycles(3); stats.NodesReapedTotal != 2 || stats.LastBatchSize != 0 {
+		t.Errorf("ReaperStats() after a quiet cycle = %+v, want total 2 and batch 0", stats)
+	}
+}
+
 func TestMonitorReaperKeepsActiveNodes(t *testing.T) {
 	m := NewMonitor()
+	fake := clock.NewFake(time.Now())
+	m.SetClock(fake)
+	reaped := reapedChan(m)
+	defer m.Stop()
 	shortTimeout := 200 * time.Millisecond
-	reaperInterval := 50 * time.Millisecond
 
 	// Start reaper
-	go m.StartReaper(reaperInterval, shortTimeout)
+	go m.StartReaper(50*time.Millisecond, shortTimeout)
+	fake.BlockUntil(1)
 
 	addr := "192.168.1.100:9999"
 	m.Update(addr)
 
-	// Keep updating the node to prevent timeout
+	// Keep updating the node to prevent timeout. The node is never older
+	// than the timeout at any fake instant, so no reaper tick can remove it
 	for i := 0; i < 5; i++ {
-		time.Sleep(shortTimeout / 3)
+		fake.Advance(shortTimeout / 3)
 		m.Update(addr)
 	}
 
+	// A node that stops updating is still reaped, so the ticks above ran
+	m.Update("192.168.1.101:9999")
+	fake.Advance(time.Millisecond)
+	m.Update(addr)
+	fake.Advance(shortTimeout)
+	m.Update(addr)
+	fake.Advance(100 * time.Millisecond)
+	select {
+	case got := <-reaped:
+		if got != "192.168.1.101:9999" {
+			t.Errorf("reaped %s, want only the silent node", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("reaper did not remove the silent node")
+	}
+
 	// Node should still exist
-	if count := m.GetNodeCount(); count != 1 {
-		t.Errorf("GetNodeCount() with active updates = %d, want 1", count)
+	if _, ok := m.GetNodeInfo(addr); !ok {
+		t.Error("GetNodeInfo() with active updates = not found, want found")
 	}
 }
 
@@ -285,3 +462,219 @@ func TestMonitorShardDistribution(t *testing.T) {
 		t.Error("No shards have nodes, distribution may be broken")
 	}
 }
+
+func TestMonitorSilence(t *testing.T) {
+	m := NewMonitor()
+	m.UpdateWithStatus("10.0.0.1:9999", 2, 0)
+	m.UpdateWithStatus("10.0.0.2:9999", 0, 0)
+
+	m.Silence("10.0.0.1:9999", time.Now().Add(time.Hour))
+
+	info, _ := m.GetNodeInfo("10.0.0.1:9999")
+	if !info.Silenced {
+		t.Error("GetNodeInfo() Silenced = false for node in maintenance window")
+	}
+	if nodes := m.GetNodes(); !nodes["10.0.0.1:9999"].Silenced || nodes["10.0.0.2:9999"].Silenced {
+		t.Errorf("GetNodes() Silenced flags wrong: %+v", nodes)
+	}
+
+	sum := m.Summarize()
+	if sum.Total != 2 || sum.Maintenance != 1 || sum.Critical != 0 || sum.OK != 1 {
+		t.Errorf("Summarize() = %+v, want 2 total, 1 maintenance, 1 OK", sum)
+	}
+	if sum.WorstStatus() != 0 {
+		t.Errorf("WorstStatus() = %d, want 0 while the critical node is silenced", sum.WorstStatus())
+	}
+
+	m.Unsilence("10.0.0.1:9999")
+	if sum := m.Summarize(); sum.Critical != 1 || sum.Maintenance != 0 {
+		t.Errorf("Summarize() after Unsilence = %+v, want 1 critical", sum)
+	}
+}
+
+func TestMonitorSilenceExpires(t *testing.T) {
+	m := NewMonitor()
+	m.UpdateWithStatus("10.0.0.1:9999", 1, 0)
+	m.Silence("10.0.0.1:9999", time.Now().Add(-time.Second))
+	m.Silence("10.0.0.2:9999", time.Now().Add(time.Hour))
+
+	if info, _ := m.GetNodeInfo("10.0.0.1:9999"); info.Silenced {
+		t.Error("GetNodeInfo() Silenced = true after window expired")
+	}
+
+	silences := m.Silences()
+	if _, ok := silences["10.0.0.1:9999"]; ok {
+		t.Error("Silences() should drop expired windows")
+	}
+	if _, ok := silences["10.0.0.2:9999"]; !ok {
+		t.Error("Silences() missing active window")
+	}
+}
+
+func TestMonitorForEachNode(t *testing.T) {
+	m := NewMonitor()
+	for i := 0; i < 100; i++ {
+		m.UpdateWithStatus(fmt.Sprintf("10.0.0.%d:9999", i), uint8(i%3), 1)
+	}
+
+	seen := make(map[string]uint8)
+	m.ForEachNode(func(addr string, info NodeInfo) bool {
+		seen[addr] = info.StatusCode
+		// Callbacks run without locks held, so they may use the monitor
+		m.GetNodeCount()
+		return true
+	})
+	if len(seen) != 100 || seen["10.0.0.5:9999"] != 2 {
+		t.Errorf("ForEachNode() visited %d nodes (10.0.0.5: %d), want 100 (2)", len(seen), seen["10.0.0.5:9999"])
+	}
+
+	visited := 0
+	m.ForEachNode(func(string, NodeInfo) bool {
+		visited++
+		return visited < 10
+	})
+	if visited != 10 {
+		t.Errorf("ForEachNode() visited %d nodes after stopping, want 10", visited)
+	}
+}
+
+func TestMonitorMaxNodes(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	m := NewMonitor()
+	m.SetClock(fake)
+	m.SetMaxNodes(numShards * 4)
+	var left []string
+	m.SetEventHandler(func(e Event) {
+		if e.Type == EventLeft {
+			left = append(left, e.Address)
+		}
+	})
+
+	m.UpdateWithStatus("10.0.0.1:9999", 0, 1)
+	for i := 0; i < 1000; i++ {
+		fake.Advance(time.Millisecond)
+		m.UpdateWithStatus(fmt.Sprintf("10.1.%d.%d:9999", i>>8, i&0xff), 0, 1)
+		if n := m.GetNodeCount(); n > numShards*4 {
+			t.Fatalf("%d nodes tracked after %d updates, want at most %d", n, i+1, numShards*4)
+		}
+	}
+	if _, ok := m.GetNodeInfo("10.0.0.1:9999"); ok {
+		t.Error("least recently seen node was not evicted")
+	}
+	if _, ok := m.GetNodeInfo("10.1.3.231:9999"); !ok {
+		t.Error("newest node is not tracked")
+	}
+	evicted := m.ReaperStats().NodesEvicted
+	if evicted == 0 || int(evicted) != len(left) || int(evicted)+m.GetNodeCount() != 1001 {
+		t.Errorf("NodesEvicted = %d with %d left events and %d nodes tracked, want them to add up to 1001", evicted, len(left), m.GetNodeCount())
+	}
+
+	// Merged nodes older than a full shard's are dropped
+	full := m.GetNodeCount()
+	stale := make([]NodeInfo, 100)
+	for i := range stale {
+		stale[i] = NodeInfo{Address: fmt.Sprintf("10.2.0.%d:9999", i), LastSeen: fake.Now().Add(-time.Hour)}
+	}
+	m.MergeFederated("other", stale)
+	if got := m.GetNodeCount(); got > numShards*4 || got < full {
+		t.Errorf("%d nodes tracked after merging stale nodes, want %d to %d", got, full, numShards*4)
+	}
+	if _, ok := m.GetNodeInfo("10.1.3.231:9999"); !ok {
+		t.Error("stale merged node evicted a fresh one")
+	}
+}
+
+func TestMonitorMaxNodesMergedOrder(t *testing.T) {
+	start := time.Now()
+	fake := clock.NewFake(start)
+	m := NewMonitor()
+	m.SetClock(fake)
+	m.SetMaxNodes(numShards * 2)
+
+	// Three addresses sharing a shard, which holds two nodes
+	var addrs []string
+	want, _ := m.getShard("10.0.0.0:9999")
+	for i := 1; len(addrs) < 3; i++ {
+		addr := fmt.Sprintf("10.0.%d.%d:9999", i>>8, i&0xff)
+		if s, _ := m.getShard(addr); s == want {
+			addrs = append(addrs, addr)
+		}
+	}
+	a, b, c := addrs[0], addrs[1], addrs[2]
+
+	m.UpdateWithStatus(a, 0, 1)
+	fake.Advance(10 * time.Second)
+	m.UpdateWithStatus(b, 0, 1)
+
+	// A fresher sighting of a elsewhere is still older than b
+	m.MergeFederated("other", []NodeInfo{{Address: a, LastSeen: start.Add(5 * time.Second)}})
+
+	fake.Advance(time.Second)
+	m.UpdateWithStatus(c, 0, 1)
+	if _, ok := m.GetNodeInfo(a); ok {
+		t.Errorf("merged node %s outlived a node seen after it", a)
+	}
+	if _, ok := m.GetNodeInfo(b); !ok {
+		t.Errorf("node %s was evicted before an older merged node", b)
+	}
+}
+
+// newLargeMonitor returns a monitor with n status-only nodes
+func newLargeMonitor(n int) *Monitor {
+	m := NewMonitor()
+	for i := 0; i < n; i++ {
+		m.UpdateWithStatus(fmt.Sprintf("10.%d.%d.%d:9999", i>>16, (i>>8)&0xff, i&0xff), 0, 1)
+	}
+	return m
+}
+
+func BenchmarkGetNodes50k(b *testing.B) {
+	m := newLargeMonitor(50000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(m.GetNodes()) != 50000 {
+			b.Fatal("missing nodes")
+		}
+	}
+}
+
+// BenchmarkMaxNodesFlood50k inserts new nodes into a monitor whose shards
+// are full at 50k nodes, as a spoofed-source flood does, so every insert
+// evicts
+func BenchmarkMaxNodesFlood50k(b *testing.B) {
+	m := NewMonitor()
+	m.SetMaxNodes(50000)
+	for i := 0; i < 50000; i++ {
+		m.UpdateWithStatus(fmt.Sprintf("10.%d.%d.%d:9999", i>>16, (i>>8)&0xff, i&0xff), 0, 1)
+	}
+	addrs := make([]string, b.N)
+	for i := range addrs {
+		addrs[i] = fmt.Sprintf("172.%d.%d.%d:9999", 16+i>>16, (i>>8)&0xff, i&0xff)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for _, addr := range addrs {
+		m.UpdateWithStatus(addr, 0, 1)
+	}
+	b.StopTimer()
+	if n := m.GetNodeCount(); n > 50000+numShards {
+		b.Fatalf("%d nodes tracked, want at most about 50000", n)
+	}
+}
+
+func BenchmarkForEachNode50k(b *testing.B) {
+	m := newLargeMonitor(50000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		m.ForEachNode(func(string, NodeInfo) bool {
+			n++
+			return true
+		})
+		if n != 50000 {
+			b.Fatal("missing nodes")
+		}
+	}
+}
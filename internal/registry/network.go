@@ -1,40 +1,209 @@
 package registry
 
 import (
+	"container/list"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net"
-	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
 	"github.com/rafaelmarinho/pulsecheck/internal/protocol"
 )
 
+// RetransmitSpacing is the delay between retransmitted copies of a heartbeat
+const RetransmitSpacing = 50 * time.Millisecond
+
+// DefaultIOTimeout bounds each socket read and write. Reads time out
+// periodically so the receive loop can notice shutdown without a socket close
+const DefaultIOTimeout = 500 * time.Millisecond
+
 // packetJob represents a packet to be processed
 type packetJob struct {
 	data []byte
+	buf  *[]byte // Pooled buffer backing data, returned once the job is handled
 	addr *net.UDPAddr
 }
 
+// NetworkStats is a snapshot of the heartbeat traffic handled by a UDPNode
+type NetworkStats struct {
+	PacketsSent     uint64
+	BytesSent       uint64
+	PacketsReceived uint64
+	BytesReceived   uint64
+
+	// Packets dropped because they were unsigned, signed by an unknown
+	// node, or carried a signature that did not verify
+	SignatureFailures uint64
+
+	// Packets dropped as undecodable, by cause: a length that is not a
+	// known packet size, a CRC32 mismatch, or a version byte that does not
+	// match the packet layout
+	InvalidSize      uint64
+	ChecksumFailures uint64
+	UnknownVersion   uint64
+
+	// Packets dropped for a foreign magic prefix, or for carrying none
+	// when magic is required; usually traffic from another application
+	BadMagic uint64
+
+	// Heartbeats dropped in static peer mode because the sender is not a
+	// configured peer
+	UnknownPeers uint64
+
+	// Sends discarded by an injected packet loss (chaos testing only)
+	ChaosDropped uint64
+
+	// Heartbeats dropped for carrying this node's own UUID, e.g. its own
+	// announcements looped back, unless SetAcceptSelf is enabled
+	SelfPackets uint64
+
+	// Received heartbeats ignored as retransmitted or relayed copies of one
+	// already processed, see SetPacketDedup
+	Duplicates uint64
+
+	// Packets dropped on receive because the worker queue was full
+	QueueDropped uint64
+
+	// Learned peers forgotten to stay within SetMaxPeers, least recently
+	// heard first
+	PeersEvicted uint64
+
+	// Times the watchdog re-created the UDP socket after persistent read
+	// errors
+	ListenerRestarts uint64
+
+	// Datagrams the kernel dropped because the socket receive buffer was
+	// full, before QueueDropped could see them; see SetReadBuffer. Only
+	// reported on Linux, and counted from the current socket on
+	KernelDrops uint64
+
+	// Packet workers currently running; varies under load with SetMaxWorkers
+	Workers int
+}
+
 // UDPNode represents a UDP network node
 type UDPNode struct {
-	conn         *net.UDPConn
+	conn         *net.UDPConn // Replaced by the listener watchdog; read with socket()
+	connMu       sync.RWMutex
 	monitor      *Monitor
 	nodeUUID     [16]byte
+	listenPort   uint16
+	codec        protocol.Options
 	peers        map[string]*net.UDPAddr
+	peerKeys     []string       // Keys of peers, sorted, so broadcasts rotate without sorting
+	peerAddrs    []*net.UDPAddr // Addresses of peerKeys, at the same index
 	peersMu      sync.RWMutex
 	stopChan     chan struct{}
+	stopOnce     sync.Once
+	doneChan     chan struct{} // Closed when the receive loop exits
+	running      atomic.Bool
+	ioTimeout    time.Duration
 	packetChan   chan packetJob
 	workerWg     sync.WaitGroup
-	bufferPool   sync.Pool
-	workerCount  int
+	bufferPool   sync.Pool // *[]byte buffers shared by the send and receive paths
+	workerCount  int // Minimum workers; the fixed count without maxWorkers
+	clock        clock.Clock // Stamps sent packets; socket deadlines always use real time
+
+	// Traffic counters, updated atomically from the send and receive paths
+	packetsSent     atomic.Uint64
+	bytesSent       atomic.Uint64
+	packetsReceived atomic.Uint64
+	bytesReceived   atomic.Uint64
+
+	signatureFailures atomic.Uint64
+	invalidSize       atomic.Uint64
+	checksumFailures  atomic.Uint64
+	unknownVersion    atomic.Uint64
+	badMagic          atomic.Uint64
+	unknownPeers      atomic.Uint64
+	chaosDropped      atomic.Uint64
+	duplicates        atomic.Uint64
+	selfPackets       atomic.Uint64
+	queueDropped      atomic.Uint64
+	peersEvicted      atomic.Uint64
+	listenerRestarts  atomic.Uint64
+
+	// Last kernel drop count read for Stats, see kernelDropsTTL
+	kernelDropsMu sync.Mutex
+	kernelDrops   uint64
+	kernelDropsAt time.Time
+
+	// Ed25519 signing, configured by SetSigning
+	signingKey  ed25519.PrivateKey
+	trustedKeys map[[16]byte]ed25519.PublicKey // nil accepts unsigned packets
+
+	// staticPeers fixes the peer list to the peers added before Start
+	staticPeers bool
+
+	// Cap on learned peers, see SetMaxPeers. Learned peers are ordered by
+	// when they were last heard from, most recent first; pinned peers added
+	// with AddPeer or as the seed are not in the order and never evicted
+	maxPeers  int // 0 is unbounded
+	peerOrder *list.List
+	peerElems map[string]*list.Element
+
+	// dedup drops recently received heartbeats, see SetPacketDedup (nil
+	// disables)
+	dedup *dedupCache
+
+	// dscp marks outgoing packets, see SetDSCP (0 leaves them unmarked)
+	dscp int
+
+	// readBuffer is the requested socket receive buffer, see SetReadBuffer
+	// (0 keeps the OS default)
+	readBuffer int
+
+	// acceptSelf records heartbeats carrying our own UUID, see SetAcceptSelf
+	acceptSelf bool
+
+	// wireVersion is the packet layout sent, see SetWireVersion (0 sends
+	// protocol.Version)
+	wireVersion uint8
+
+	// Send rotation, see SetPeersPerBroadcast
+	peersPerBroadcast int           // 0 sends to every peer
+	sendCursor        atomic.Uint64 // Position of the next broadcast's first peer
+
+	// sequence numbers sent heartbeats, so receivers can count lost ones
+	sequence atomic.Uint32
+
+	// dropSend discards a send when it returns true, set by SetSendFilter
+	dropSend func() bool
+
+	// interval is the heartbeat interval advertised in sent packets, in
+	// protocol.IntervalUnits
+	interval atomic.Uint32
+
+	// priority is advertised in sent packets, see SetPriority
+	priority uint8
+
+	// Traffic health, see TrafficHealth. Times are clock UnixNano, 0 unset
+	startedAt        atomic.Int64
+	lastSent         atomic.Int64
+	lastReceived     atomic.Int64
+	sendFailingSince atomic.Int64 // First failed send since the last success
+	deaf             atomic.Bool  // The watchdog gave up on the listener
+
+	// Adaptive worker pool, see SetMaxWorkers
+	maxWorkers    int
+	workers       atomic.Int32   // Running workers
+	nextWorkerID  int            // Only the scaler touches this once started
+	retire        chan struct{}  // Each receive stops one worker
+	queueOverflow atomic.Bool    // Set when a packet is dropped on a full queue
+	scalerWg      sync.WaitGroup
 }
 
 // NewUDPNode creates a new UDP node
 func NewUDPNode(port int, nodeUUID [16]byte, monitor *Monitor) (*UDPNode, error) {
+	// A nil IP listens on all interfaces, both IPv4 and IPv6 where supported
 	addr := &net.UDPAddr{
 		Port: port,
-		IP:   net.ParseIP("0.0.0.0"),
 	}
 	
 	conn, err := net.ListenUDP("udp", addr)
@@ -42,10 +211,7 @@ func NewUDPNode(port int, nodeUUID [16]byte, monitor *Monitor) (*UDPNode, error)
 		return nil, err
 	}
 	
-	workerCount := runtime.NumCPU()
-	if workerCount < 2 {
-		workerCount = 2 // Minimum 2 workers
-	}
+	workerCount := DefaultWorkerCount()
 	
 	// Channel buffer size: allow some queuing during traffic spikes
 	// Buffer size of 2x worker count provides headroom
@@ -55,70 +221,257 @@ func NewUDPNode(port int, nodeUUID [16]byte, monitor *Monitor) (*UDPNode, error)
 		conn:        conn,
 		monitor:     monitor,
 		nodeUUID:    nodeUUID,
+		listenPort:  uint16(conn.LocalAddr().(*net.UDPAddr).Port),
 		peers:       make(map[string]*net.UDPAddr),
+		peerOrder:   list.New(),
+		peerElems:   make(map[string]*list.Element),
 		stopChan:    make(chan struct{}),
+		doneChan:    make(chan struct{}),
+		ioTimeout:   DefaultIOTimeout,
 		packetChan:  make(chan packetJob, packetChanSize),
 		workerCount: workerCount,
+		clock:       clock.Real(),
+		retire:      make(chan struct{}),
 	}
 	
-	// Initialize buffer pool for receive buffers
+	// Initialize buffer pool for packet buffers
+	// Pointers avoid an allocation when a slice is put back into the pool
 	node.bufferPool = sync.Pool{
 		New: func() interface{} {
-			return make([]byte, protocol.PacketSize)
+			// One byte of headroom so oversized datagrams are seen as
+			// oversized instead of being truncated to a valid length
+			buf := make([]byte, protocol.MaxSignedPacketSize+1)
+			return &buf
 		},
 	}
 	
 	return node, nil
 }
 
+// SetChecksum enables or disables CRC32 on sent and received packets
+// Must be called before Start, and all peers must use the same setting
+func (u *UDPNode) SetChecksum(enabled bool) {
+	u.codec.NoChecksum = !enabled
+}
+
+// SetMagic sets the packet magic prefix (0 keeps protocol.DefaultMagic).
+// With require set, legacy v1/v2 packets, which carry no magic, are
+// dropped. Must be called before Start, and all peers must use the same magic
+func (u *UDPNode) SetMagic(magic uint16, require bool) {
+	u.codec.Magic = magic
+	u.codec.RequireMagic = require
+}
+
+// SetSigning signs outgoing packets with key (if non-nil) and, when trusted is
+// non-nil, drops any packet not signed by the public key listed for its
+// node UUID. Must be called before Start
+func (u *UDPNode) SetSigning(key ed25519.PrivateKey, trusted map[[16]byte]ed25519.PublicKey) {
+	u.signingKey = key
+	u.trustedKeys = trusted
+}
+
+// SetWorkers overrides the number of packet processing workers (0 keeps
+// DefaultWorkerCount). Must be called before Start
+func (u *UDPNode) SetWorkers(n int) {
+	if n > 0 {
+		u.workerCount = n
+		u.packetChan = make(chan packetJob, n*2)
+	}
+}
+
+// SetMaxWorkers lets the worker pool grow up to max workers while the
+// packet queue stays nearly full, and shrink back to the SetWorkers count
+// once it is idle. A max at or below that count keeps the pool fixed. The
+// queue is sized for max. Must be called before Start, after SetWorkers
+func (u *UDPNode) SetMaxWorkers(max int) {
+	u.maxWorkers = max
+	if max > u.workerCount {
+		u.packetChan = make(chan packetJob, max*2)
+	}
+}
+
+// SetStaticPeers stops the node from learning peers: heartbeats are sent
+// only to peers added with AddPeer, and heartbeats from any other address
+// are dropped. Must be called before Start
+func (u *UDPNode) SetStaticPeers(static bool) {
+	u.staticPeers = static
+}
+
+// SetPeersPerBroadcast sends each broadcast to at most n peers, rotating
+// round-robin through the peers sorted by address so that every peer is
+// served in turn. 0 sends to every peer. Must be called before Start
+func (u *UDPNode) SetPeersPerBroadcast(n int) {
+	u.peersPerBroadcast = n
+}
+
+// SetWireVersion sends packets in the layout of this protocol version
+// instead of the newest, so peers that do not decode the newest yet still
+// hear this node during a rolling upgrade. Fields the layout predates are
+// not sent. Must be called before Start
+func (u *UDPNode) SetWireVersion(version uint8) error {
+	if (&protocol.Packet{Version: version}).Size() == 0 {
+		return fmt.Errorf("%w: %d", protocol.ErrUnknownVersion, version)
+	}
+	u.wireVersion = version
+	return nil
+}
+
+// BroadcastRounds returns how many broadcasts pass between two sends to
+// the same peer: ceil(peers / peers per broadcast), or 1 when every
+// broadcast reaches every peer
+func (u *UDPNode) BroadcastRounds() int {
+	n, k := u.PeerCount(), u.peersPerBroadcast
+	if k <= 0 || k >= n {
+		return 1
+	}
+	return (n + k - 1) / k
+}
+
+// AdvertisedInterval returns the heartbeat interval advertised to peers:
+// the interval set by SetHeartbeatInterval, stretched by the rotation to
+// how often each peer actually hears from this node
+func (u *UDPNode) AdvertisedInterval() time.Duration {
+	return time.Duration(u.advertisedInterval()) * protocol.IntervalUnit
+}
+
+// advertisedInterval is AdvertisedInterval in protocol.IntervalUnits
+func (u *UDPNode) advertisedInterval() uint16 {
+	return uint16(min(uint64(u.interval.Load())*uint64(u.BroadcastRounds()), math.MaxUint16))
+}
+
+// SetAcceptSelf records heartbeats carrying this node's own UUID instead of
+// dropping them, for loopback self-tests. Otherwise a node listed among its
+// own peers, or hearing its own broadcasts, would count itself as a peer
+// Must be called before Start
+func (u *UDPNode) SetAcceptSelf(accept bool) {
+	u.acceptSelf = accept
+}
+
+// SetClock replaces the clock used to timestamp sent packets and to age
+// the packet dedup cache
+// Must be called before Start
+func (u *UDPNode) SetClock(c clock.Clock) {
+	u.clock = c
+}
+
+// SetSendFilter discards every send for which drop returns true, to inject
+// packet loss in chaos tests. drop must be safe for concurrent use. Must be
+// called before Start
+func (u *UDPNode) SetSendFilter(drop func() bool) {
+	u.dropSend = drop
+}
+
+// SetHeartbeatInterval sets the heartbeat interval advertised to peers, so
+// they can detect a timeout too short for it. Safe to call while running,
+// e.g. when adaptive heartbeats change the interval
+func (u *UDPNode) SetHeartbeatInterval(d time.Duration) {
+	u.interval.Store(uint32(protocol.IntervalUnits(d)))
+}
+
+// SetPriority sets the priority advertised to peers, which prefer higher
+// priorities in leader election. Must be called before Start
+func (u *UDPNode) SetPriority(priority uint8) {
+	u.priority = priority
+}
+
+// SetIOTimeout sets the per-operation socket read/write deadline
+// Must be called before Start
+func (u *UDPNode) SetIOTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		u.ioTimeout = timeout
+	}
+}
+
 // Start begins listening for UDP packets
 func (u *UDPNode) Start() {
-	log.Printf("UDP listener started on %s (workers: %d)", u.conn.LocalAddr(), u.workerCount)
+	if !u.running.CompareAndSwap(false, true) {
+		return
+	}
+	u.startedAt.Store(u.clock.Now().UnixNano())
+	defer close(u.doneChan)
+	
+	if u.maxWorkers > u.workerCount {
+		log.Printf("UDP listener started on %s (workers: %d-%d)", u.socket().LocalAddr(), u.workerCount, u.maxWorkers)
+	} else {
+		log.Printf("UDP listener started on %s (workers: %d)", u.socket().LocalAddr(), u.workerCount)
+	}
 	
 	// Start worker pool
 	u.startWorkers()
+	if u.maxWorkers > u.workerCount {
+		u.scalerWg.Add(1)
+		go u.scaleWorkers()
+	}
 	
 	// Main receive loop
+	readErrors := 0 // Consecutive failed reads, for the listener watchdog
 	for {
 		select {
 		case <-u.stopChan:
-			// Close packet channel to signal workers to stop
-			close(u.packetChan)
-			// Wait for all workers to finish
-			u.workerWg.Wait()
+			u.stopWorkers()
 			return
 		default:
+			if readErrors >= listenerErrorLimit {
+				if !u.restartListener() {
+					// Deaf until stopped, but no longer spinning on errors
+					u.deaf.Store(true)
+					<-u.stopChan
+					u.stopWorkers()
+					return
+				}
+				readErrors = 0
+			}
+			
 			// Get buffer from pool
-			buf := u.bufferPool.Get().([]byte)
+			bufPtr := u.bufferPool.Get().(*[]byte)
+			buf := *bufPtr
+			conn := u.socket()
 			
-			n, addr, err := u.conn.ReadFromUDP(buf)
+			// Bound the read so the loop re-checks stopChan regularly
+			if err := conn.SetReadDeadline(time.Now().Add(u.ioTimeout)); err != nil {
+				u.bufferPool.Put(bufPtr)
+				readErrors++
+				continue
+			}
+			
+			n, addr, err := conn.ReadFromUDP(buf)
 			if err != nil {
 				// Return buffer to pool on error
-				u.bufferPool.Put(buf)
+				u.bufferPool.Put(bufPtr)
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					// Deadline expired with no traffic - not a real error
+					readErrors = 0
+					continue
+				}
+				readErrors++
+				log.Printf("UDP read error: %v", err)
 				continue
 			}
+			readErrors = 0
+			u.lastReceived.Store(u.clock.Now().UnixNano())
+			
+			u.packetsReceived.Add(1)
+			u.bytesReceived.Add(uint64(n))
 			
-			if n != protocol.PacketSize {
+			if n < protocol.MinPacketSize || n > protocol.MaxSignedPacketSize {
 				// Return buffer to pool if packet size is wrong
-				u.bufferPool.Put(buf)
+				u.bufferPool.Put(bufPtr)
+				u.invalidSize.Add(1)
 				continue
 			}
 			
-			// Allocate packet data (26 bytes - minimal allocation)
-			// We need a copy because buf will be returned to pool and reused
-			packetData := make([]byte, protocol.PacketSize)
-			copy(packetData, buf[:n])
-			
-			// Return receive buffer to pool immediately for reuse
-			u.bufferPool.Put(buf)
-			
-			// Send to worker pool (non-blocking with buffered channel)
+			// Hand the buffer itself to a worker, which returns it to the pool
+			// once the packet is handled - no per-packet copy
 			select {
-			case u.packetChan <- packetJob{data: packetData, addr: addr}:
+			case u.packetChan <- packetJob{data: buf[:n], buf: bufPtr, addr: addr}:
 				// Successfully queued
 			default:
 				// Channel full - drop packet to prevent blocking
 				// In high-traffic scenarios, this prevents memory buildup
+				u.bufferPool.Put(bufPtr)
+				u.queueDropped.Add(1)
+				u.queueOverflow.Store(true)
 				log.Printf("Packet channel full, dropping packet from %s", addr)
 			}
 		}
@@ -128,97 +481,321 @@ func (u *UDPNode) Start() {
 // startWorkers starts the worker pool goroutines
 func (u *UDPNode) startWorkers() {
 	for i := 0; i < u.workerCount; i++ {
-		u.workerWg.Add(1)
-		go u.worker(i)
+		u.startWorker()
 	}
 }
 
-// worker processes packets from the channel
+// startWorker starts one more worker
+func (u *UDPNode) startWorker() {
+	u.workers.Add(1)
+	u.workerWg.Add(1)
+	go u.worker(u.nextWorkerID)
+	u.nextWorkerID++
+}
+
+// worker processes packets from the channel until it is closed or the
+// worker is retired. The scaler counts a retired worker out when retiring
+// it, so the count is never stale
 func (u *UDPNode) worker(id int) {
 	defer u.workerWg.Done()
 	
-	for job := range u.packetChan {
-		u.handlePacket(job.data, job.addr)
+	for {
+		select {
+		case job, ok := <-u.packetChan:
+			if !ok {
+				u.workers.Add(-1)
+				return
+			}
+			u.handlePacket(job.data, job.addr)
+			u.bufferPool.Put(job.buf)
+		case <-u.retire:
+			return
+		}
 	}
 }
 
 // handlePacket processes an incoming heartbeat packet
 func (u *UDPNode) handlePacket(data []byte, addr *net.UDPAddr) {
-	pkt, err := protocol.Decode(data)
-	if err != nil {
-		log.Printf("Failed to decode packet from %s: %v", addr, err)
+	var pkt protocol.Packet
+	packet, signature := protocol.SplitSignature(data)
+	if err := protocol.DecodeIntoWith(&pkt, packet, u.codec); err != nil {
+		u.countDecodeError(err)
+		// Foreign traffic on a shared port is expected; it is only counted
+		if !errors.Is(err, protocol.ErrBadMagic) {
+			log.Printf("Failed to decode packet from %s: %v", addr, err)
+		}
+		return
+	}
+	
+	if u.isSelf(pkt.NodeUUID) && !u.acceptSelf {
+		u.selfPackets.Add(1)
+		return
+	}
+	
+	if u.trustedKeys != nil {
+		if err := u.verify(&pkt, packet, signature); err != nil {
+			u.signatureFailures.Add(1)
+			log.Printf("Dropping packet from %s: %v", addr, err)
+			return
+		}
+	}
+	
+	if u.dedup != nil && pkt.Timestamp != 0 &&
+		u.dedup.duplicate(dedupKey{uuid: pkt.NodeUUID, timestamp: pkt.Timestamp}, u.clock.Now()) {
+		u.duplicates.Add(1)
 		return
 	}
 	
-	// Add peer to known peers
-	addrStr := addr.String()
-	u.peersMu.Lock()
-	u.peers[addrStr] = addr
-	u.peersMu.Unlock()
+	// Register the peer at its advertised listen address rather than the
+	// (possibly ephemeral) source port so we can reliably send back to it
+	peerAddr := advertisedAddr(addr, pkt.ListenPort)
+	addrStr := CanonicalAddr(peerAddr.String())
+	if u.staticPeers {
+		u.peersMu.RLock()
+		_, known := u.peers[addrStr]
+		u.peersMu.RUnlock()
+		if !known {
+			u.unknownPeers.Add(1)
+			return
+		}
+	} else if pkt.Flags&protocol.FlagNoLearn == 0 {
+		u.learnPeer(addrStr, peerAddr)
+	}
 	
 	// Update monitor with node info
 	// Note: We don't have telemetry in the packet, so we use defaults
 	// The status code tells us the health state
-	u.monitor.UpdateWithStatus(addrStr, pkt.StatusCode, pkt.Timestamp)
+	if !u.monitor.UpdateWithPacket(addrStr, &pkt) {
+		u.duplicates.Add(1)
+	}
+}
+
+// isSelf reports whether uuid is this node's own. The zero UUID means an
+// unknown identity and never matches
+func (u *UDPNode) isSelf(uuid [16]byte) bool {
+	return uuid == u.nodeUUID && uuid != [16]byte{}
+}
+
+// countDecodeError buckets an undecodable packet by cause for Stats
+func (u *UDPNode) countDecodeError(err error) {
+	switch {
+	case errors.Is(err, protocol.ErrInvalidSize):
+		u.invalidSize.Add(1)
+	case errors.Is(err, protocol.ErrChecksumMismatch):
+		u.checksumFailures.Add(1)
+	case errors.Is(err, protocol.ErrUnknownVersion):
+		u.unknownVersion.Add(1)
+	case errors.Is(err, protocol.ErrBadMagic):
+		u.badMagic.Add(1)
+	}
+}
+
+// verify checks a packet's signature against the trusted key for its node UUID
+func (u *UDPNode) verify(pkt *protocol.Packet, packet, signature []byte) error {
+	key, ok := u.trustedKeys[pkt.NodeUUID]
+	if !ok {
+		return fmt.Errorf("no trusted key for node %x", pkt.NodeUUID)
+	}
+	return protocol.Verify(packet, signature, key)
+}
+
+// advertisedAddr combines the source IP of a packet with the listen port the
+// sender advertised. Falls back to the source address if no port was advertised.
+func advertisedAddr(src *net.UDPAddr, listenPort uint16) *net.UDPAddr {
+	if listenPort == 0 {
+		return src
+	}
+	return &net.UDPAddr{
+		IP:   src.IP,
+		Port: int(listenPort),
+		Zone: src.Zone,
+	}
+}
+
+// ResolvePeerAddr parses a host:port peer address. IPv6 literals must be
+// bracketed (e.g. [fe80::1]:9999) so the port can be split unambiguously
+func ResolvePeerAddr(hostPort string) (*net.UDPAddr, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	if port == "" {
+		return nil, fmt.Errorf("address %s: missing port", hostPort)
+	}
+	return net.ResolveUDPAddr("udp", net.JoinHostPort(host, port))
+}
+
+// newPacket creates a heartbeat packet carrying this node's listen port
+func (u *UDPNode) newPacket(statusCode uint8) *protocol.Packet {
+	pkt := protocol.NewPacket(u.nodeUUID, statusCode)
+	if u.wireVersion != 0 {
+		pkt.Version = u.wireVersion
+	}
+	pkt.Timestamp = u.clock.Now().UnixNano()
+	pkt.ListenPort = u.listenPort
+	pkt.Interval = u.advertisedInterval()
+	pkt.Priority = u.priority
+	// With rotation each peer only hears some of the numbered heartbeats,
+	// so none are numbered and receivers fall back to the interval
+	if u.BroadcastRounds() == 1 {
+		pkt.Sequence = u.nextSequence()
+	}
+	return pkt
+}
+
+// nextSequence returns the next heartbeat sequence number, skipping 0,
+// which means unnumbered
+func (u *UDPNode) nextSequence() uint32 {
+	for {
+		if seq := u.sequence.Add(1); seq != 0 {
+			return seq
+		}
+	}
+}
+
+// encode writes a heartbeat into buf and returns the bytes to send, which
+// carry a signature when a signing key is set
+func (u *UDPNode) encode(buf []byte, statusCode uint8) ([]byte, error) {
+	pkt := u.newPacket(statusCode)
+	if err := pkt.EncodeIntoWith(buf, u.codec); err != nil {
+		return nil, err
+	}
+	size := pkt.Size()
+	if u.signingKey == nil {
+		return buf[:size], nil
+	}
+	if err := protocol.Sign(buf, size, u.signingKey); err != nil {
+		return nil, err
+	}
+	return buf[:size+protocol.SignatureSize], nil
 }
 
 // BroadcastHeartbeat sends a heartbeat packet to all known peers
 func (u *UDPNode) BroadcastHeartbeat(statusCode uint8) error {
-	pkt := protocol.NewPacket(u.nodeUUID, statusCode)
-	data, err := pkt.Encode()
+	// Encode once into a pooled buffer and reuse it for every peer
+	bufPtr := u.bufferPool.Get().(*[]byte)
+	defer u.bufferPool.Put(bufPtr)
+	data, err := u.encode(*bufPtr, statusCode)
 	if err != nil {
 		return err
 	}
+	u.sendTo(data, u.broadcastPeers())
+	return nil
+}
+
+// BroadcastHeartbeatRetransmit is BroadcastHeartbeat followed by copies more
+// sends of the same packet, RetransmitSpacing apart, so a single lost packet
+// does not delay an important status change by a whole interval. Receivers
+// recognize the copies by their identical timestamp and ignore them. The
+// copies go to the same peers as the original, not the next in rotation
+func (u *UDPNode) BroadcastHeartbeatRetransmit(statusCode uint8, copies int) error {
+	// The copies outlive the call, so they get their own buffer
+	data, err := u.encode(make([]byte, protocol.MaxSignedPacketSize), statusCode)
+	if err != nil {
+		return err
+	}
+	peers := u.broadcastPeers()
+	u.sendTo(data, peers)
 	
+	go func() {
+		timer := time.NewTimer(RetransmitSpacing)
+		defer timer.Stop()
+		for i := 0; i < copies; i++ {
+			select {
+			case <-u.stopChan:
+				return
+			case <-timer.C:
+			}
+			u.sendTo(data, peers)
+			timer.Reset(RetransmitSpacing)
+		}
+	}()
+	return nil
+}
+
+// broadcastPeers returns the peers the next broadcast goes to, advancing
+// the rotation
+func (u *UDPNode) broadcastPeers() []*net.UDPAddr {
 	u.peersMu.RLock()
-	peers := make([]*net.UDPAddr, 0, len(u.peers))
-	for _, addr := range u.peers {
-		peers = append(peers, addr)
+	defer u.peersMu.RUnlock()
+	return u.rotate()
+}
+
+// sendTo sends an encoded packet to each of peers
+func (u *UDPNode) sendTo(data []byte, peers []*net.UDPAddr) {
+	for _, addr := range peers {
+		if err := u.send(data, addr); err != nil {
+			log.Printf("Failed to send heartbeat to %s: %v", addr, err)
+		}
 	}
-	u.peersMu.RUnlock()
-	
-	// If no peers, broadcast to local network
-	if len(peers) == 0 {
-		// Broadcast to subnet (optional, for discovery)
+}
+
+// rotate orders the peers for one broadcast, starting where the previous
+// one stopped so the same peers are not always served first, and keeps at
+// most peersPerBroadcast of them. The order is deterministic, by address,
+// and comes from peerAddrs so that no sort runs per broadcast. The caller
+// holds peersMu
+func (u *UDPNode) rotate() []*net.UDPAddr {
+	n := len(u.peerAddrs)
+	if n == 0 {
 		return nil
 	}
 	
-	// Send to all known peers
-	for _, addr := range peers {
-		_, err := u.conn.WriteToUDP(data, addr)
-		if err != nil {
-			log.Printf("Failed to send heartbeat to %s: %v", addr, err)
-		}
+	limit, step := n, 1
+	if u.peersPerBroadcast > 0 && u.peersPerBroadcast < n {
+		limit, step = u.peersPerBroadcast, u.peersPerBroadcast
 	}
+	start := int((u.sendCursor.Add(uint64(step)) - uint64(step)) % uint64(n))
 	
+	ordered := make([]*net.UDPAddr, 0, limit)
+	for i := 0; i < limit; i++ {
+		ordered = append(ordered, u.peerAddrs[(start+i)%n])
+	}
+	return ordered
+}
+
+// send writes a packet to addr and updates the traffic counters
+func (u *UDPNode) send(data []byte, addr *net.UDPAddr) error {
+	if u.dropSend != nil && u.dropSend() {
+		u.chaosDropped.Add(1)
+		return nil
+	}
+	conn := u.socket()
+	if err := conn.SetWriteDeadline(time.Now().Add(u.ioTimeout)); err != nil {
+		u.sendFailed()
+		return err
+	}
+	n, err := conn.WriteToUDP(data, addr)
+	if err != nil {
+		u.sendFailed()
+		return err
+	}
+	u.sendSucceeded()
+	u.packetsSent.Add(1)
+	u.bytesSent.Add(uint64(n))
 	return nil
 }
 
 // SendToSeedNode sends a heartbeat to a seed node to bootstrap peer discovery
 // This allows a new node to "check in" with a known stable IP
 func (u *UDPNode) SendToSeedNode(seedAddr string, statusCode uint8) error {
-	addr, err := net.ResolveUDPAddr("udp", seedAddr)
+	addr, err := ResolvePeerAddr(seedAddr)
 	if err != nil {
 		return fmt.Errorf("invalid seed node address: %w", err)
 	}
 	
-	pkt := protocol.NewPacket(u.nodeUUID, statusCode)
-	data, err := pkt.Encode()
+	data, err := u.encode(make([]byte, protocol.MaxSignedPacketSize), statusCode)
 	if err != nil {
 		return err
 	}
 	
-	_, err = u.conn.WriteToUDP(data, addr)
-	if err != nil {
+	if err := u.send(data, addr); err != nil {
 		return fmt.Errorf("failed to send to seed node: %w", err)
 	}
 	
 	// Add seed node as a peer so we'll receive its heartbeats
 	// and discover other peers through it
-	addrStr := addr.String()
-	u.peersMu.Lock()
-	u.peers[addrStr] = addr
-	u.peersMu.Unlock()
+	u.pinPeer(addr.String(), addr)
 	
 	log.Printf("Sent heartbeat to seed node: %s", seedAddr)
 	return nil
@@ -226,25 +803,97 @@ func (u *UDPNode) SendToSeedNode(seedAddr string, statusCode uint8) error {
 
 // AddPeer adds a peer address to the known peers list
 func (u *UDPNode) AddPeer(addrStr string) error {
-	addr, err := net.ResolveUDPAddr("udp", addrStr)
+	addr, err := ResolvePeerAddr(addrStr)
 	if err != nil {
 		return err
 	}
 	
-	u.peersMu.Lock()
-	u.peers[addrStr] = addr
-	u.peersMu.Unlock()
+	// Key by the resolved address so it matches the keys used for received packets
+	u.pinPeer(addr.String(), addr)
 	
 	return nil
 }
 
-// Conn returns the UDP connection (for getting local address)
+// Peers returns the addresses of all known peers
+func (u *UDPNode) Peers() []string {
+	u.peersMu.RLock()
+	defer u.peersMu.RUnlock()
+	peers := make([]string, 0, len(u.peers))
+	for addr := range u.peers {
+		peers = append(peers, addr)
+	}
+	return peers
+}
+
+// PeerCount returns the number of known peers
+func (u *UDPNode) PeerCount() int {
+	u.peersMu.RLock()
+	defer u.peersMu.RUnlock()
+	return len(u.peers)
+}
+
+// Conn returns the UDP connection (for getting local address). The
+// listener watchdog may replace it, so do not hold on to it
 func (u *UDPNode) Conn() *net.UDPConn {
-	return u.conn
+	return u.socket()
+}
+
+// Stats returns a snapshot of the packets and bytes sent and received
+func (u *UDPNode) Stats() NetworkStats {
+	return NetworkStats{
+		PacketsSent:     u.packetsSent.Load(),
+		BytesSent:       u.bytesSent.Load(),
+		PacketsReceived: u.packetsReceived.Load(),
+		BytesReceived:   u.bytesReceived.Load(),
+
+		SignatureFailures: u.signatureFailures.Load(),
+		InvalidSize:       u.invalidSize.Load(),
+		ChecksumFailures:  u.checksumFailures.Load(),
+		UnknownVersion:    u.unknownVersion.Load(),
+		BadMagic:          u.badMagic.Load(),
+		UnknownPeers:      u.unknownPeers.Load(),
+		ChaosDropped:      u.chaosDropped.Load(),
+		Duplicates:        u.duplicates.Load(),
+		SelfPackets:       u.selfPackets.Load(),
+		QueueDropped:      u.queueDropped.Load(),
+		PeersEvicted:      u.peersEvicted.Load(),
+		ListenerRestarts:  u.listenerRestarts.Load(),
+		KernelDrops:       u.cachedKernelDrops(),
+		Workers:           int(u.workers.Load()),
+	}
+}
+
+// kernelDropsTTL is how long Stats reuses the kernel drop count. On Linux
+// reading it scans the table of every UDP socket on the host, which is too
+// slow to repeat on every call on a busy host
+const kernelDropsTTL = time.Second
+
+// cachedKernelDrops returns the kernel drop count, read again at most
+// once per kernelDropsTTL
+func (u *UDPNode) cachedKernelDrops() uint64 {
+	u.kernelDropsMu.Lock()
+	defer u.kernelDropsMu.Unlock()
+	now := u.clock.Now()
+	if u.kernelDropsAt.IsZero() || now.Sub(u.kernelDropsAt) >= kernelDropsTTL {
+		u.kernelDrops = socketDrops(u.socket())
+		u.kernelDropsAt = now
+	}
+	return u.kernelDrops
 }
 
-// Stop stops the UDP listener
+// Port returns the UDP port this node is listening on
+func (u *UDPNode) Port() int {
+	return int(u.listenPort)
+}
+
+// Stop stops the UDP listener. Safe to call more than once
+// The receive loop exits on its next read deadline, after which the socket is closed
 func (u *UDPNode) Stop() {
-	close(u.stopChan)
-	u.conn.Close()
+	u.stopOnce.Do(func() {
+		close(u.stopChan)
+		if u.running.Load() {
+			<-u.doneChan
+		}
+		u.socket().Close()
+	})
 }
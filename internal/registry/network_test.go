@@ -0,0 +1,924 @@
+package registry
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+	"github.com/rafaelmarinho/pulsecheck/internal/protocol"
+)
+
+func TestAdvertisedAddr(t *testing.T) {
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.100"), Port: 54321}
+
+	addr := advertisedAddr(src, 9999)
+	if addr.String() != "192.168.1.100:9999" {
+		t.Errorf("advertisedAddr() = %s, want 192.168.1.100:9999", addr)
+	}
+
+	// No advertised port falls back to the source address
+	addr = advertisedAddr(src, 0)
+	if addr.String() != "192.168.1.100:54321" {
+		t.Errorf("advertisedAddr() without port = %s, want 192.168.1.100:54321", addr)
+	}
+}
+
+func TestHandlePacketUsesAdvertisedPort(t *testing.T) {
+	monitor := NewMonitor()
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	var peerUUID [16]byte
+	copy(peerUUID[:], "peer-node")
+	pkt := protocol.NewPacket(peerUUID, 0)
+	pkt.ListenPort = 10001
+	data, err := pkt.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	src := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 54321}
+	node.handlePacket(data, src)
+
+	if _, ok := monitor.GetNodeInfo("127.0.0.1:10001"); !ok {
+		t.Error("handlePacket() did not register node at advertised address")
+	}
+	if _, ok := monitor.GetNodeInfo("127.0.0.1:54321"); ok {
+		t.Error("handlePacket() registered node at ephemeral source address")
+	}
+
+	node.peersMu.RLock()
+	_, ok := node.peers["127.0.0.1:10001"]
+	node.peersMu.RUnlock()
+	if !ok {
+		t.Error("handlePacket() did not add peer at advertised address")
+	}
+}
+
+func TestNewUDPNodeListenPort(t *testing.T) {
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	want := node.Conn().LocalAddr().(*net.UDPAddr).Port
+	pkt := node.newPacket(1)
+	if int(pkt.ListenPort) != want {
+		t.Errorf("newPacket() ListenPort = %d, want %d", pkt.ListenPort, want)
+	}
+	if time.Since(time.Unix(0, pkt.Timestamp)) > time.Second {
+		t.Error("newPacket() Timestamp is too old")
+	}
+}
+
+func TestUDPNodeStopTwice(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	node.Stop()
+	node.Stop()
+}
+
+func TestUDPNodeKernelDropsCached(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	fake := clock.NewFake(time.Now())
+	node.clock = fake
+	node.kernelDrops, node.kernelDropsAt = 42, fake.Now()
+
+	if got := node.Stats().KernelDrops; got != 42 {
+		t.Errorf("KernelDrops = %d within the TTL, want the cached 42", got)
+	}
+	// Nothing was sent, so the fresh socket has dropped nothing
+	fake.Advance(kernelDropsTTL)
+	if got := node.Stats().KernelDrops; got != 0 {
+		t.Errorf("KernelDrops = %d after the TTL, want it read again as 0", got)
+	}
+}
+
+func TestUDPNodeStats(t *testing.T) {
+	var uuidA, uuidB [16]byte
+	copy(uuidA[:], "node-a")
+	copy(uuidB[:], "node-b")
+
+	nodeA, err := NewUDPNode(0, uuidA, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer nodeA.Stop()
+
+	monitorB := NewMonitor()
+	nodeB, err := NewUDPNode(0, uuidB, monitorB)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer nodeB.Stop()
+	go nodeB.Start()
+
+	seed := net.JoinHostPort("127.0.0.1", strconv.Itoa(nodeB.Port()))
+	if err := nodeA.SendToSeedNode(seed, 0); err != nil {
+		t.Fatalf("SendToSeedNode() error = %v", err)
+	}
+
+	// A bare v6 packet but for its sequence record
+	const size = protocol.MinPacketSizeV6 + 6
+	sent := nodeA.Stats()
+	if sent.PacketsSent != 1 || sent.BytesSent != size {
+		t.Errorf("Stats() sent = %d packets / %d bytes, want 1 / %d",
+			sent.PacketsSent, sent.BytesSent, size)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for nodeB.Stats().PacketsReceived == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	received := nodeB.Stats()
+	if received.PacketsReceived != 1 || received.BytesReceived != size {
+		t.Errorf("Stats() received = %d packets / %d bytes, want 1 / %d",
+			received.PacketsReceived, received.BytesReceived, size)
+	}
+}
+
+func TestHandlePacketNoChecksum(t *testing.T) {
+	monitor := NewMonitor()
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	node.SetChecksum(false)
+
+	pkt := protocol.NewPacket(nodeUUID, 0)
+	pkt.ListenPort = 10002
+	data, err := pkt.EncodeWith(protocol.Options{NoChecksum: true})
+	if err != nil {
+		t.Fatalf("EncodeWith() error = %v", err)
+	}
+
+	node.handlePacket(data, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 54321})
+
+	if _, ok := monitor.GetNodeInfo("127.0.0.1:10002"); !ok {
+		t.Error("handlePacket() with checksum disabled did not accept packet")
+	}
+}
+
+func TestHandlePacketDecodeFailureStats(t *testing.T) {
+	monitor := NewMonitor()
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	good, err := protocol.NewPacket(nodeUUID, 0).Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	corrupt := append([]byte(nil), good...)
+	corrupt[len(corrupt)-1] ^= 0xFF
+	// A well-formed v5 packet claiming to be v1
+	mismatched, err := (&protocol.Packet{Version: protocol.VersionV5}).Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	mismatched[2] = protocol.VersionV1
+	binary.BigEndian.PutUint32(mismatched[protocol.PacketDataSizeV5:], crc32.ChecksumIEEE(mismatched[:protocol.PacketDataSizeV5]))
+
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 54321}
+	node.handlePacket(good[:31], addr)
+	node.handlePacket(corrupt, addr)
+	node.handlePacket(corrupt, addr)
+	node.handlePacket(mismatched, addr)
+	foreign := append([]byte(nil), good...)
+	foreign[0] ^= 0xFF
+	node.handlePacket(foreign, addr)
+
+	stats := node.Stats()
+	if stats.InvalidSize != 1 || stats.ChecksumFailures != 2 || stats.UnknownVersion != 1 || stats.BadMagic != 1 {
+		t.Errorf("Stats() = %+v, want 1 invalid size, 2 checksum failures, 1 unknown version, 1 bad magic", stats)
+	}
+	if monitor.GetNodeCount() != 0 {
+		t.Error("handlePacket() accepted an undecodable packet")
+	}
+}
+
+func TestHandlePacketRequireMagic(t *testing.T) {
+	monitor := NewMonitor()
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	node.SetMagic(0xBEEF, true)
+
+	pkt := protocol.NewPacket(nodeUUID, 0)
+	pkt.ListenPort = 9999
+	own, err := pkt.EncodeWith(protocol.Options{Magic: 0xBEEF})
+	if err != nil {
+		t.Fatalf("EncodeWith() error = %v", err)
+	}
+	other, err := pkt.Encode() // Default magic
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	legacy := make([]byte, protocol.PacketSizeV2)
+	copy(legacy, other[2:2+protocol.PacketDataSizeV2])
+	legacy[0] = protocol.VersionV2
+	binary.BigEndian.PutUint32(legacy[protocol.PacketDataSizeV2:], crc32.ChecksumIEEE(legacy[:protocol.PacketDataSizeV2]))
+
+	node.handlePacket(other, &net.UDPAddr{IP: net.ParseIP("10.0.0.2"), Port: 1})
+	node.handlePacket(legacy, &net.UDPAddr{IP: net.ParseIP("10.0.0.3"), Port: 1})
+	node.handlePacket(own, &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1})
+
+	if stats := node.Stats(); stats.BadMagic != 2 {
+		t.Errorf("Stats().BadMagic = %d, want 2", stats.BadMagic)
+	}
+	if got := monitor.GetNodeCount(); got != 1 {
+		t.Errorf("GetNodeCount() = %d, want only the packet with our magic", got)
+	}
+	if _, ok := monitor.GetNodeInfo("10.0.0.1:9999"); !ok {
+		t.Error("packet with our magic was not recorded")
+	}
+}
+
+func TestHandlePacketStaticPeers(t *testing.T) {
+	monitor := NewMonitor()
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	node.SetStaticPeers(true)
+	if err := node.AddPeer("127.0.0.1:10003"); err != nil {
+		t.Fatalf("AddPeer() error = %v", err)
+	}
+
+	for _, port := range []uint16{10003, 10004} {
+		pkt := protocol.NewPacket(nodeUUID, 0)
+		pkt.ListenPort = port
+		data, err := pkt.Encode()
+		if err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+		node.handlePacket(data, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 54321})
+	}
+
+	if _, ok := monitor.GetNodeInfo("127.0.0.1:10003"); !ok {
+		t.Error("handlePacket() dropped a heartbeat from a static peer")
+	}
+	if _, ok := monitor.GetNodeInfo("127.0.0.1:10004"); ok {
+		t.Error("handlePacket() accepted a heartbeat from an unlisted address")
+	}
+	if peers := node.Peers(); len(peers) != 1 {
+		t.Errorf("Peers() = %v, want only the static peer", peers)
+	}
+	if got := node.Stats().UnknownPeers; got != 1 {
+		t.Errorf("Stats().UnknownPeers = %d, want 1", got)
+	}
+}
+
+func TestHandlePacketSelf(t *testing.T) {
+	monitor := NewMonitor()
+	var nodeUUID [16]byte
+	copy(nodeUUID[:], "self-node")
+	node, err := NewUDPNode(0, nodeUUID, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	pkt := protocol.NewPacket(nodeUUID, 0)
+	pkt.ListenPort = 10005
+	data, err := pkt.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	src := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 54321}
+
+	node.handlePacket(data, src)
+	if got := monitor.GetNodeCount(); got != 0 {
+		t.Errorf("GetNodeCount() = %d after our own heartbeat, want 0", got)
+	}
+	if peers := node.Peers(); len(peers) != 0 {
+		t.Errorf("Peers() = %v, want our own address ignored", peers)
+	}
+	if got := node.Stats().SelfPackets; got != 1 {
+		t.Errorf("Stats().SelfPackets = %d, want 1", got)
+	}
+
+	// Loopback self-tests opt in
+	node.SetAcceptSelf(true)
+	node.handlePacket(data, src)
+	if info, ok := monitor.GetNodeInfo("127.0.0.1:10005"); !ok || info.UUID != nodeUUID {
+		t.Errorf("self heartbeat with SetAcceptSelf = %+v (found %v)", info, ok)
+	}
+}
+
+func TestUDPNodeRotate(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	if got := node.broadcastPeers(); len(got) != 0 {
+		t.Errorf("broadcastPeers() without peers = %v, want none", got)
+	}
+
+	// Added out of order
+	for _, port := range []int{10004, 10001, 10005, 10003, 10002} {
+		if err := node.AddPeer(fmt.Sprintf("127.0.0.1:%d", port)); err != nil {
+			t.Fatalf("AddPeer() error = %v", err)
+		}
+	}
+	ports := func(addrs []*net.UDPAddr) []int {
+		var p []int
+		for _, a := range addrs {
+			p = append(p, a.Port-10000)
+		}
+		return p
+	}
+
+	// Full broadcasts reach everyone, starting one peer later each time
+	for _, want := range [][]int{{1, 2, 3, 4, 5}, {2, 3, 4, 5, 1}} {
+		if got := ports(node.broadcastPeers()); !reflect.DeepEqual(got, want) {
+			t.Errorf("broadcastPeers() = %v, want %v", got, want)
+		}
+	}
+
+	// Throttled broadcasts serve consecutive windows
+	node.SetPeersPerBroadcast(2)
+	node.sendCursor.Store(0)
+	for _, want := range [][]int{{1, 2}, {3, 4}, {5, 1}, {2, 3}} {
+		if got := ports(node.broadcastPeers()); !reflect.DeepEqual(got, want) {
+			t.Errorf("broadcastPeers() with 2 per broadcast = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestWireVersion(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{1}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	node.SetHeartbeatInterval(time.Second)
+	node.SetPriority(7)
+	if err := node.SetWireVersion(protocol.VersionV4); err != nil {
+		t.Fatalf("SetWireVersion() error = %v", err)
+	}
+
+	// A v4 peer decodes it, without the priority it predates
+	data, err := node.encode(make([]byte, protocol.MaxSignedPacketSize), 0)
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+	if len(data) != protocol.PacketSizeV4 {
+		t.Fatalf("encode() = %d bytes, want %d", len(data), protocol.PacketSizeV4)
+	}
+	pkt, err := protocol.Decode(data)
+	if err != nil || pkt.Version != protocol.VersionV4 || pkt.Interval != 10 || pkt.Priority != 0 {
+		t.Errorf("Decode() = %+v, %v; want v4 with the interval", pkt, err)
+	}
+
+	if err := node.SetWireVersion(99); !errors.Is(err, protocol.ErrUnknownVersion) {
+		t.Errorf("SetWireVersion(99) error = %v, want ErrUnknownVersion", err)
+	}
+}
+
+func TestAdvertisedIntervalWithRotation(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{1}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	node.SetHeartbeatInterval(time.Second)
+	for port := 10001; port <= 10005; port++ {
+		if err := node.AddPeer(fmt.Sprintf("127.0.0.1:%d", port)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := node.AdvertisedInterval(); got != time.Second {
+		t.Errorf("AdvertisedInterval() without rotation = %v, want 1s", got)
+	}
+	first, second := node.newPacket(0), node.newPacket(0)
+	if first.Sequence == 0 || second.Sequence != first.Sequence+1 {
+		t.Errorf("packet sequences = %d, %d, want consecutive", first.Sequence, second.Sequence)
+	}
+
+	// 5 peers, 2 per broadcast: each peer is served every third broadcast
+	node.SetPeersPerBroadcast(2)
+	if got := node.BroadcastRounds(); got != 3 {
+		t.Errorf("BroadcastRounds() = %d, want 3", got)
+	}
+	if got := node.AdvertisedInterval(); got != 3*time.Second {
+		t.Errorf("AdvertisedInterval() = %v, want 3s", got)
+	}
+	if pkt := node.newPacket(0); pkt.HeartbeatInterval() != 3*time.Second || pkt.Sequence != 0 {
+		t.Errorf("packet interval = %v, sequence = %d, want 3s and unnumbered", pkt.HeartbeatInterval(), pkt.Sequence)
+	}
+}
+
+func TestRetransmitSamePeers(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{1}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	var receivers []*net.UDPConn
+	for i := 0; i < 4; i++ {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		receivers = append(receivers, conn)
+		if err := node.AddPeer(conn.LocalAddr().String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	node.SetPeersPerBroadcast(2)
+
+	if err := node.BroadcastHeartbeatRetransmit(2, 2); err != nil {
+		t.Fatalf("BroadcastHeartbeatRetransmit() error = %v", err)
+	}
+	time.Sleep(3*RetransmitSpacing + 100*time.Millisecond)
+
+	// Two peers get the original and both copies, the others nothing
+	var counts []int
+	buf := make([]byte, protocol.MaxSignedPacketSize)
+	for _, conn := range receivers {
+		n := 0
+		conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		for {
+			if _, _, err := conn.ReadFromUDP(buf); err != nil {
+				break
+			}
+			n++
+		}
+		counts = append(counts, n)
+	}
+	sort.Ints(counts)
+	if !reflect.DeepEqual(counts, []int{0, 0, 3, 3}) {
+		t.Errorf("packets per peer = %v, want [0 0 3 3]", counts)
+	}
+}
+
+func TestResolvePeerAddr(t *testing.T) {
+	testCases := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"192.168.1.100:9999", "192.168.1.100:9999", false},
+		{"[::1]:9999", "[::1]:9999", false},
+		{"[fe80::1%lo]:9999", "[fe80::1%lo]:9999", false},
+		{"[2001:db8::10]:10001", "[2001:db8::10]:10001", false},
+		{"::1:9999", "", true},      // Unbracketed IPv6 is ambiguous
+		{"192.168.1.100", "", true}, // Missing port
+		{"[::1]:", "", true},        // Empty port
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			addr, err := ResolvePeerAddr(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("ResolvePeerAddr(%q) = %v, want error", tc.input, addr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolvePeerAddr(%q) error = %v", tc.input, err)
+			}
+			if addr.String() != tc.want {
+				t.Errorf("ResolvePeerAddr(%q) = %s, want %s", tc.input, addr, tc.want)
+			}
+		})
+	}
+}
+
+func TestAdvertisedAddrIPv6(t *testing.T) {
+	src := &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 54321, Zone: "eth0"}
+
+	addr := advertisedAddr(src, 9999)
+	if addr.String() != "[fe80::1%eth0]:9999" {
+		t.Errorf("advertisedAddr() = %s, want [fe80::1%%eth0]:9999", addr)
+	}
+}
+
+func TestAddPeerIPv6(t *testing.T) {
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	if err := node.AddPeer("[2001:db8::10]:9999"); err != nil {
+		t.Fatalf("AddPeer() error = %v", err)
+	}
+	if err := node.AddPeer("2001:db8::10:9999"); err == nil {
+		t.Error("AddPeer() with unbracketed IPv6 should return error")
+	}
+
+	peers := node.Peers()
+	if len(peers) != 1 || peers[0] != "[2001:db8::10]:9999" {
+		t.Errorf("Peers() = %v, want [[2001:db8::10]:9999]", peers)
+	}
+}
+
+func TestHandlePacketIPv6(t *testing.T) {
+	monitor := NewMonitor()
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	pkt := protocol.NewPacket(nodeUUID, 1)
+	pkt.ListenPort = 10001
+	data, err := pkt.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	node.handlePacket(data, &net.UDPAddr{IP: net.ParseIP("2001:db8::10"), Port: 54321})
+
+	if _, ok := monitor.GetNodeInfo("[2001:db8::10]:10001"); !ok {
+		t.Error("handlePacket() did not register IPv6 node at bracketed advertised address")
+	}
+}
+
+func TestUDPNodeStopWithoutTraffic(t *testing.T) {
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	node.SetIOTimeout(20 * time.Millisecond)
+
+	started := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		close(started)
+		node.Start()
+		close(exited)
+	}()
+	<-started
+	time.Sleep(50 * time.Millisecond) // Let a few read deadlines expire
+
+	node.Stop()
+
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("Start() did not return after Stop()")
+	}
+}
+
+func TestUDPNodeReceiveSizes(t *testing.T) {
+	monitor := NewMonitor()
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	go node.Start()
+
+	target := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: node.Port()}
+	oversized, err := net.DialUDP("udp", nil, target)
+	if err != nil {
+		t.Fatalf("DialUDP() error = %v", err)
+	}
+	defer oversized.Close()
+	legacy, err := net.DialUDP("udp", nil, target)
+	if err != nil {
+		t.Fatalf("DialUDP() error = %v", err)
+	}
+	defer legacy.Close()
+
+	// A valid packet with trailing garbage must not be truncated into a valid one
+	data, err := protocol.NewPacket(nodeUUID, 0).Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	oversized.Write(append(data, 0xFF))
+
+	// Version 1 packets carry no listen port, so they register at the source address
+	pkt := protocol.NewPacket(nodeUUID, 1)
+	pkt.Version = protocol.VersionV1
+	v1, _ := pkt.Encode()
+	legacy.Write(v1)
+
+	legacyAddr := legacy.LocalAddr().String()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := monitor.GetNodeInfo(legacyAddr); ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if info, ok := monitor.GetNodeInfo(legacyAddr); !ok || info.StatusCode != 1 {
+		t.Errorf("v1 packet not registered at %s (info=%+v)", legacyAddr, info)
+	}
+	if _, ok := monitor.GetNodeInfo(oversized.LocalAddr().String()); ok {
+		t.Error("oversized datagram should be dropped")
+	}
+}
+
+func BenchmarkHandlePacket(b *testing.B) {
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, NewMonitor())
+	if err != nil {
+		b.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	pkt := protocol.NewPacket(nodeUUID, 0)
+	pkt.ListenPort = 10001
+	data, err := pkt.Encode()
+	if err != nil {
+		b.Fatal(err)
+	}
+	src := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 54321}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		node.handlePacket(data, src)
+	}
+}
+
+func BenchmarkBroadcastHeartbeat(b *testing.B) {
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, NewMonitor())
+	if err != nil {
+		b.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	// Peers point at a socket nobody reads; datagrams are simply dropped
+	sink, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		b.Fatalf("ListenUDP() error = %v", err)
+	}
+	defer sink.Close()
+	for i := 0; i < 8; i++ {
+		node.AddPeer(net.JoinHostPort("127.0.0.1", strconv.Itoa(sink.LocalAddr().(*net.UDPAddr).Port+i)))
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := node.BroadcastHeartbeat(0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestHandlePacketSignatures(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+
+	var trustedUUID, unknownUUID [16]byte
+	copy(trustedUUID[:], "trusted-node")
+	copy(unknownUUID[:], "unknown-node")
+
+	monitor := NewMonitor()
+	node, err := NewUDPNode(0, [16]byte{}, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	node.SetSigning(nil, map[[16]byte]ed25519.PublicKey{trustedUUID: pub})
+
+	encode := func(uuid [16]byte, key ed25519.PrivateKey) []byte {
+		sender := &UDPNode{nodeUUID: uuid, listenPort: 10001, signingKey: key, clock: clock.Real()}
+		data, err := sender.encode(make([]byte, protocol.MaxSignedPacketSize), 0)
+		if err != nil {
+			t.Fatalf("encode() error = %v", err)
+		}
+		return data
+	}
+	tampered := encode(trustedUUID, priv)
+	tampered[len(tampered)-1] ^= 0xFF
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+		port int
+	}{
+		{"unsigned", encode(trustedUUID, nil), 1},
+		{"wrong key", encode(trustedUUID, otherPriv), 2},
+		{"unknown node", encode(unknownUUID, priv), 3},
+		{"bad signature", tampered, 4},
+	} {
+		node.handlePacket(tt.data, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: tt.port})
+		if monitor.GetNodeCount() != 0 {
+			t.Fatalf("%s packet was accepted", tt.name)
+		}
+	}
+	if got := node.Stats().SignatureFailures; got != 4 {
+		t.Errorf("SignatureFailures = %d, want 4", got)
+	}
+
+	node.handlePacket(encode(trustedUUID, priv), &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5})
+	if _, ok := monitor.GetNodeInfo("127.0.0.1:10001"); !ok {
+		t.Error("validly signed packet was dropped")
+	}
+}
+
+func TestUDPNodeSignedHeartbeat(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	var uuidA, uuidB [16]byte
+	copy(uuidA[:], "node-a")
+	copy(uuidB[:], "node-b")
+
+	nodeA, err := NewUDPNode(0, uuidA, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer nodeA.Stop()
+	nodeA.SetSigning(priv, nil)
+
+	monitorB := NewMonitor()
+	nodeB, err := NewUDPNode(0, uuidB, monitorB)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer nodeB.Stop()
+	nodeB.SetSigning(nil, map[[16]byte]ed25519.PublicKey{uuidA: pub})
+	go nodeB.Start()
+
+	if err := nodeA.AddPeer("127.0.0.1:" + strconv.Itoa(nodeB.Port())); err != nil {
+		t.Fatalf("AddPeer() error = %v", err)
+	}
+	if err := nodeA.BroadcastHeartbeat(1); err != nil {
+		t.Fatalf("BroadcastHeartbeat() error = %v", err)
+	}
+	// A bare v6 packet but for its sequence record, and the signature
+	if sent := nodeA.Stats(); sent.BytesSent != protocol.MinPacketSizeV6+6+protocol.SignatureSize {
+		t.Errorf("BytesSent = %d, want %d", sent.BytesSent, protocol.MinPacketSizeV6+6+protocol.SignatureSize)
+	}
+
+	addrA := "127.0.0.1:" + strconv.Itoa(nodeA.Port())
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := monitorB.GetNodeInfo(addrA); ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if info, ok := monitorB.GetNodeInfo(addrA); !ok || info.UUID != uuidA {
+		t.Errorf("signed heartbeat not registered at %s (info=%+v)", addrA, info)
+	}
+}
+
+func TestHandlePacketHeartbeatInterval(t *testing.T) {
+	monitor := NewMonitor()
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	node.SetHeartbeatInterval(5 * time.Second)
+
+	data, err := node.encode(make([]byte, protocol.MaxSignedPacketSize), 0)
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+	node.handlePacket(data, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 54321})
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(node.Port()))
+	if info, _ := monitor.GetNodeInfo(addr); info.HeartbeatInterval != 5*time.Second {
+		t.Errorf("HeartbeatInterval = %v, want the advertised 5s", info.HeartbeatInterval)
+	}
+
+	// Older peers advertise nothing, which leaves the interval unknown
+	pkt := protocol.NewPacket(nodeUUID, 0)
+	pkt.ListenPort = 10003
+	data, err = pkt.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	node.handlePacket(data, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 54321})
+	if info, _ := monitor.GetNodeInfo("127.0.0.1:10003"); info.HeartbeatInterval != 0 {
+		t.Errorf("HeartbeatInterval = %v without an advertised interval, want 0", info.HeartbeatInterval)
+	}
+}
+
+func TestHandlePacketPriority(t *testing.T) {
+	monitor := NewMonitor()
+	node, err := NewUDPNode(0, [16]byte{}, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	node.SetPriority(200)
+
+	data, err := node.encode(make([]byte, protocol.MaxSignedPacketSize), 0)
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+	node.handlePacket(data, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 54321})
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(node.Port()))
+	if info, _ := monitor.GetNodeInfo(addr); info.Priority != 200 {
+		t.Errorf("Priority = %d, want the advertised 200", info.Priority)
+	}
+
+	// A restart with the default priority lowers it again
+	node.SetPriority(0)
+	data, err = node.encode(make([]byte, protocol.MaxSignedPacketSize), 0)
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+	node.handlePacket(data, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 54321})
+	if info, _ := monitor.GetNodeInfo(addr); info.Priority != 0 {
+		t.Errorf("Priority = %d after advertising the default, want 0", info.Priority)
+	}
+}
+
+func TestUDPNodeRetransmit(t *testing.T) {
+	var uuidA, uuidB [16]byte
+	copy(uuidA[:], "node-a")
+	copy(uuidB[:], "node-b")
+
+	nodeA, err := NewUDPNode(0, uuidA, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer nodeA.Stop()
+
+	monitorB := NewMonitor()
+	var events []Event
+	var eventsMu sync.Mutex
+	monitorB.SetEventHandler(func(e Event) {
+		eventsMu.Lock()
+		events = append(events, e)
+		eventsMu.Unlock()
+	})
+	nodeB, err := NewUDPNode(0, uuidB, monitorB)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer nodeB.Stop()
+	go nodeB.Start()
+
+	if err := nodeA.AddPeer(net.JoinHostPort("127.0.0.1", strconv.Itoa(nodeB.Port()))); err != nil {
+		t.Fatalf("AddPeer() error = %v", err)
+	}
+	if err := nodeA.BroadcastHeartbeatRetransmit(2, 2); err != nil {
+		t.Fatalf("BroadcastHeartbeatRetransmit() error = %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for nodeB.Stats().Duplicates < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if sent := nodeA.Stats().PacketsSent; sent != 3 {
+		t.Errorf("PacketsSent = %d, want the heartbeat and 2 copies", sent)
+	}
+	if dups := nodeB.Stats().Duplicates; dups != 2 {
+		t.Errorf("Duplicates = %d, want 2", dups)
+	}
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	if len(events) != 1 || events[0].Type != EventJoined {
+		t.Errorf("events = %+v, want a single join", events)
+	}
+}
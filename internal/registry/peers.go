@@ -0,0 +1,86 @@
+package registry
+
+import (
+	"net"
+	"sort"
+)
+
+// DefaultMaxPeers is the default cap on learned peers, far above any
+// expected mesh but well short of what a spoofed-source flood could create
+const DefaultMaxPeers = 65536
+
+// SetMaxPeers caps the peers learned from received heartbeats at n, so a
+// flood of spoofed source addresses or a very large mesh cannot grow the
+// peer list without bound. Once the cap is reached, the peer heard from
+// least recently is forgotten to make room, and counted in
+// NetworkStats.PeersEvicted. Peers added with AddPeer or as the seed node
+// do not count towards the cap and are never evicted. 0 is unbounded
+// Must be called before Start
+func (u *UDPNode) SetMaxPeers(n int) {
+	u.maxPeers = n
+}
+
+// learnPeer records a heartbeat from a peer, adding it if it is new
+func (u *UDPNode) learnPeer(key string, addr *net.UDPAddr) {
+	u.peersMu.Lock()
+	defer u.peersMu.Unlock()
+
+	if elem, ok := u.peerElems[key]; ok {
+		u.setPeer(key, addr)
+		u.peerOrder.MoveToFront(elem)
+		return
+	}
+	if _, pinned := u.peers[key]; pinned || u.maxPeers <= 0 {
+		u.setPeer(key, addr)
+		return
+	}
+
+	u.setPeer(key, addr)
+	u.peerElems[key] = u.peerOrder.PushFront(key)
+	for u.peerOrder.Len() > u.maxPeers {
+		oldest := u.peerOrder.Remove(u.peerOrder.Back()).(string)
+		delete(u.peerElems, oldest)
+		u.deletePeer(oldest)
+		u.peersEvicted.Add(1)
+	}
+}
+
+// pinPeer adds a peer that is kept regardless of the peer cap
+func (u *UDPNode) pinPeer(key string, addr *net.UDPAddr) {
+	u.peersMu.Lock()
+	defer u.peersMu.Unlock()
+
+	if elem, ok := u.peerElems[key]; ok {
+		u.peerOrder.Remove(elem)
+		delete(u.peerElems, key)
+	}
+	u.setPeer(key, addr)
+}
+
+// setPeer stores a peer, keeping peerKeys and peerAddrs sorted by key. The
+// caller holds peersMu
+func (u *UDPNode) setPeer(key string, addr *net.UDPAddr) {
+	i := sort.SearchStrings(u.peerKeys, key)
+	if _, ok := u.peers[key]; !ok {
+		u.peerKeys = append(u.peerKeys, "")
+		copy(u.peerKeys[i+1:], u.peerKeys[i:])
+		u.peerKeys[i] = key
+		u.peerAddrs = append(u.peerAddrs, nil)
+		copy(u.peerAddrs[i+1:], u.peerAddrs[i:])
+	}
+	u.peerAddrs[i] = addr
+	u.peers[key] = addr
+}
+
+// deletePeer forgets a peer, keeping peerKeys and peerAddrs sorted by key.
+// The caller holds peersMu
+func (u *UDPNode) deletePeer(key string) {
+	if _, ok := u.peers[key]; !ok {
+		return
+	}
+	i := sort.SearchStrings(u.peerKeys, key)
+	u.peerKeys = append(u.peerKeys[:i], u.peerKeys[i+1:]...)
+	u.peerAddrs[len(u.peerAddrs)-1] = nil
+	u.peerAddrs = append(u.peerAddrs[:i], u.peerAddrs[i+1:]...)
+	delete(u.peers, key)
+}
@@ -0,0 +1,133 @@
+package registry
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/protocol"
+)
+
+func TestUDPNodeMaxPeers(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{1}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	node.SetMaxPeers(2)
+	if err := node.AddPeer("10.0.0.100:9999"); err != nil {
+		t.Fatal(err)
+	}
+
+	heard := func(i int) {
+		pkt := protocol.NewPacket([16]byte{2, byte(i)}, 0)
+		pkt.ListenPort = 9999
+		pkt.Timestamp = time.Now().UnixNano()
+		data, err := pkt.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		node.handlePacket(data, &net.UDPAddr{IP: net.IPv4(10, 0, 0, byte(i)), Port: 40000})
+	}
+	heard(1)
+	heard(2)
+	heard(1) // 2 is now the least recently heard
+	heard(3)
+	heard(100) // Pinned peers stay pinned when heard from
+
+	peers := node.Peers()
+	sort.Strings(peers)
+	want := []string{"10.0.0.100:9999", "10.0.0.1:9999", "10.0.0.3:9999"}
+	if fmt.Sprint(peers) != fmt.Sprint(want) {
+		t.Errorf("Peers() = %v, want %v", peers, want)
+	}
+	if got := node.Stats().PeersEvicted; got != 1 {
+		t.Errorf("PeersEvicted = %d, want 1", got)
+	}
+
+	// Adding a learned peer explicitly pins it
+	if err := node.AddPeer("10.0.0.1:9999"); err != nil {
+		t.Fatal(err)
+	}
+	heard(4)
+	heard(5)
+	peers = node.Peers()
+	sort.Strings(peers)
+	want = []string{"10.0.0.100:9999", "10.0.0.1:9999", "10.0.0.4:9999", "10.0.0.5:9999"}
+	if fmt.Sprint(peers) != fmt.Sprint(want) {
+		t.Errorf("Peers() after pinning = %v, want %v", peers, want)
+	}
+}
+
+func TestUDPNodeRotateAfterEviction(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	node.SetMaxPeers(2)
+
+	for _, port := range []int{10003, 10001, 10002} {
+		addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: port}
+		node.learnPeer(addr.String(), addr)
+	}
+
+	// 10003 was heard from least recently and is no longer sent to
+	var got []int
+	for _, addr := range node.broadcastPeers() {
+		got = append(got, addr.Port)
+	}
+	if want := []int{10001, 10002}; !reflect.DeepEqual(got, want) {
+		t.Errorf("broadcastPeers() after eviction = %v, want %v", got, want)
+	}
+}
+
+func BenchmarkLearnPeerFlood(b *testing.B) {
+	node, err := NewUDPNode(0, [16]byte{1}, NewMonitor())
+	if err != nil {
+		b.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	node.SetMaxPeers(50000)
+
+	addrs := make([]*net.UDPAddr, 100000)
+	keys := make([]string, len(addrs))
+	for i := range addrs {
+		addrs[i] = &net.UDPAddr{IP: net.IPv4(10, byte(i>>16), byte(i>>8), byte(i)), Port: 9999}
+		keys[i] = addrs[i].String()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		j := i % len(addrs)
+		node.learnPeer(keys[j], addrs[j])
+	}
+	b.StopTimer()
+	if n := node.PeerCount(); n > 50000 {
+		b.Fatalf("PeerCount() = %d, want at most 50000", n)
+	}
+}
+
+func BenchmarkBroadcastPeers50k(b *testing.B) {
+	node, err := NewUDPNode(0, [16]byte{1}, NewMonitor())
+	if err != nil {
+		b.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	for i := 0; i < 50000; i++ {
+		addr := &net.UDPAddr{IP: net.IPv4(10, byte(i>>16), byte(i>>8), byte(i)), Port: 9999}
+		node.learnPeer(addr.String(), addr)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(node.broadcastPeers()) != 50000 {
+			b.Fatal("broadcastPeers() missed peers")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// phiWindowSize is the number of inter-arrival samples kept per node
+	phiWindowSize = 100
+
+	// phiMinStdDev prevents a perfectly regular heartbeat from producing a
+	// zero standard deviation, which would make phi jump to infinity on the
+	// slightest delay
+	phiMinStdDev = 100 * time.Millisecond
+)
+
+// arrivalWindow keeps a sliding window of heartbeat inter-arrival times
+// for a single node, used by the phi accrual failure detector
+type arrivalWindow struct {
+	intervals   [phiWindowSize]float64 // Inter-arrival times in milliseconds (ring buffer)
+	count       int
+	next        int
+	sum         float64
+	sumSquares  float64
+	lastArrival time.Time
+
+	// Sender timestamp of the last heartbeat, to recognize retransmitted
+	// copies of it
+	lastTimestamp int64
+}
+
+// add records a heartbeat arrival at the given time
+func (w *arrivalWindow) add(now time.Time) {
+	if !w.lastArrival.IsZero() {
+		interval := float64(now.Sub(w.lastArrival)) / float64(time.Millisecond)
+		if w.count == phiWindowSize {
+			old := w.intervals[w.next]
+			w.sum -= old
+			w.sumSquares -= old * old
+		} else {
+			w.count++
+		}
+		w.intervals[w.next] = interval
+		w.sum += interval
+		w.sumSquares += interval * interval
+		w.next = (w.next + 1) % phiWindowSize
+	}
+	w.lastArrival = now
+}
+
+// samples returns the number of inter-arrival samples collected
+func (w *arrivalWindow) samples() int {
+	return w.count
+}
+
+// phi returns the suspicion level for the node at the given time
+// Returns 0 until at least one inter-arrival sample is available
+func (w *arrivalWindow) phi(now time.Time) float64 {
+	if w.count == 0 {
+		return 0
+	}
+
+	mean := w.sum / float64(w.count)
+	variance := w.sumSquares/float64(w.count) - mean*mean
+	stdDev := math.Sqrt(math.Max(variance, 0))
+	minStdDev := float64(phiMinStdDev) / float64(time.Millisecond)
+	if stdDev < minStdDev {
+		stdDev = minStdDev
+	}
+
+	elapsed := float64(now.Sub(w.lastArrival)) / float64(time.Millisecond)
+	return phiNormal(elapsed, mean, stdDev)
+}
+
+// phiNormal computes -log10 of the probability that a heartbeat arrives
+// later than elapsed, assuming normally distributed inter-arrival times.
+// Uses the logistic approximation of the normal CDF (as in Akka's detector),
+// evaluated in log space so long silences yield a large finite phi rather than +Inf
+func phiNormal(elapsed, mean, stdDev float64) float64 {
+	y := (elapsed - mean) / stdDev
+	a := y * (1.5976 + 0.070566*y*y)
+	if elapsed > mean {
+		// -log10(e / (1 + e)) with e = exp(-a)
+		return (a + math.Log1p(math.Exp(-a))) / math.Ln10
+	}
+	// -log10(1 - 1 / (1 + e)) with e = exp(-a)
+	return math.Log1p(math.Exp(a)) / math.Ln10
+}
@@ -0,0 +1,125 @@
+package registry
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+)
+
+func TestArrivalWindowPhiNoSamples(t *testing.T) {
+	var w arrivalWindow
+	now := time.Now()
+	w.add(now)
+
+	if got := w.phi(now.Add(time.Hour)); got != 0 {
+		t.Errorf("phi() with no samples = %f, want 0", got)
+	}
+}
+
+func TestArrivalWindowPhiGrowsWithSilence(t *testing.T) {
+	var w arrivalWindow
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		w.add(start.Add(time.Duration(i) * time.Second))
+	}
+	last := start.Add(9 * time.Second)
+
+	onTime := w.phi(last.Add(time.Second))
+	late := w.phi(last.Add(2 * time.Second))
+	veryLate := w.phi(last.Add(time.Hour))
+
+	if onTime >= 1 {
+		t.Errorf("phi() at expected arrival = %f, want < 1", onTime)
+	}
+	if late <= onTime {
+		t.Errorf("phi() should grow with silence: late=%f onTime=%f", late, onTime)
+	}
+	if veryLate <= late {
+		t.Errorf("phi() should grow with silence: veryLate=%f late=%f", veryLate, late)
+	}
+	if math.IsInf(veryLate, 0) || math.IsNaN(veryLate) {
+		t.Errorf("phi() after long silence = %f, want finite value", veryLate)
+	}
+}
+
+func TestArrivalWindowSlides(t *testing.T) {
+	var w arrivalWindow
+	start := time.Now()
+	for i := 0; i <= phiWindowSize+10; i++ {
+		w.add(start.Add(time.Duration(i) * time.Second))
+	}
+
+	if w.samples() != phiWindowSize {
+		t.Errorf("samples() = %d, want %d", w.samples(), phiWindowSize)
+	}
+
+	mean := w.sum / float64(w.count)
+	if math.Abs(mean-1000) > 0.001 {
+		t.Errorf("window mean = %f ms, want 1000", mean)
+	}
+}
+
+func TestMonitorPhiReaper(t *testing.T) {
+	m := NewMonitor()
+	fake := clock.NewFake(time.Now())
+	m.SetClock(fake)
+	reaped := reapedChan(m)
+	defer m.Stop()
+	addr := "192.168.1.100:9999"
+
+	// Establish a regular 20ms heartbeat
+	for i := 0; i < 10; i++ {
+		m.Update(addr)
+		fake.Advance(20 * time.Millisecond)
+	}
+
+	go m.StartPhiReaper(20*time.Millisecond, 3.0, time.Hour)
+	fake.BlockUntil(1)
+
+	// Silence is far beyond the learned interval (and the minimum std dev),
+	// so phi should exceed the threshold
+	fake.Advance(500 * time.Millisecond)
+	select {
+	case <-reaped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("phi reaper did not remove the silent node")
+	}
+
+	if _, ok := m.GetNodeInfo(addr); ok {
+		t.Error("GetNodeInfo() after silence = found, want removed by phi reaper")
+	}
+}
+
+func TestMonitorPhiReaperFallbackTimeout(t *testing.T) {
+	m := NewMonitor()
+	fake := clock.NewFake(time.Now())
+	m.SetClock(fake)
+	reaped := reapedChan(m)
+	defer m.Stop()
+	addr := "192.168.1.100:9999"
+
+	// A single heartbeat gives no inter-arrival history
+	m.Update(addr)
+
+	go m.StartPhiReaper(20*time.Millisecond, 8.0, 100*time.Millisecond)
+	fake.BlockUntil(1)
+
+	// Within the fallback timeout the node survives any number of ticks
+	fake.Advance(100 * time.Millisecond)
+	if _, ok := m.GetNodeInfo(addr); !ok {
+		t.Fatal("GetNodeInfo() within fallback timeout = not found, want found")
+	}
+
+	fake.Advance(20 * time.Millisecond)
+	select {
+	case <-reaped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("phi reaper did not apply the fallback timeout")
+	}
+
+	if _, ok := m.GetNodeInfo(addr); ok {
+		t.Error("GetNodeInfo() after fallback timeout = found, want removed")
+	}
+}
@@ -0,0 +1,38 @@
+package registry
+
+import (
+	"errors"
+	"log"
+)
+
+// SetReadBuffer asks the kernel for a socket receive buffer of bytes, so a
+// burst of heartbeats, e.g. after a partition heals, is queued rather than
+// dropped before the node can read it. The kernel may grant less, up to
+// net.core.rmem_max on Linux; the size granted is returned, or 0 where the
+// platform cannot report it. The size is kept when the listener watchdog
+// re-creates the socket
+func (u *UDPNode) SetReadBuffer(bytes int) (int, error) {
+	if bytes <= 0 {
+		return 0, errors.New("receive buffer size must be positive")
+	}
+	u.readBuffer = bytes
+	conn := u.socket()
+	if err := conn.SetReadBuffer(bytes); err != nil {
+		return 0, err
+	}
+	granted, err := readBufferSize(conn)
+	if err != nil {
+		return 0, nil
+	}
+	return granted, nil
+}
+
+// rebuffer applies the receive buffer size to a re-created socket
+func (u *UDPNode) rebuffer() {
+	if u.readBuffer == 0 {
+		return
+	}
+	if err := u.socket().SetReadBuffer(u.readBuffer); err != nil {
+		log.Printf("Warning: failed to size the restarted listener's receive buffer to %d bytes: %v", u.readBuffer, err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package registry
+
+import "net"
+
+// readBufferSize returns the receive buffer the kernel granted
+func readBufferSize(conn *net.UDPConn) (int, error) {
+	return getsockoptRcvbuf(conn)
+}
+
+// socketDrops is not reported on this platform
+func socketDrops(conn *net.UDPConn) uint64 {
+	return 0
+}
@@ -0,0 +1,61 @@
+package registry
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// readBufferSize returns the receive buffer the kernel granted. Linux
+// reports twice the usable size to account for its bookkeeping overhead
+func readBufferSize(conn *net.UDPConn) (int, error) {
+	size, err := getsockoptRcvbuf(conn)
+	return size / 2, err
+}
+
+// socketDrops returns the datagrams the kernel dropped on the socket, read
+// from the drops column of /proc/net/udp and udp6 for the socket's inode
+func socketDrops(conn *net.UDPConn) uint64 {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	var st syscall.Stat_t
+	var statErr error
+	if err := raw.Control(func(fd uintptr) {
+		statErr = syscall.Fstat(int(fd), &st)
+	}); err != nil || statErr != nil {
+		return 0
+	}
+	inode := strconv.FormatUint(st.Ino, 10)
+	for _, path := range []string{"/proc/net/udp6", "/proc/net/udp"} {
+		if drops, ok := procUDPDrops(path, inode); ok {
+			return drops
+		}
+	}
+	return 0
+}
+
+// procUDPDrops finds the socket with inode in a /proc/net/udp table
+func procUDPDrops(path, inode string) (uint64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// sl local rem st tx:rx tr:when retrnsmt uid timeout inode ref pointer drops
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 13 || fields[9] != inode {
+			continue
+		}
+		drops, err := strconv.ParseUint(fields[12], 10, 64)
+		return drops, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,63 @@
+package registry
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestUDPNodeSetReadBuffer(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{1}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	if _, err := node.SetReadBuffer(0); err == nil {
+		t.Error("SetReadBuffer(0) should fail")
+	}
+	granted, err := node.SetReadBuffer(64 << 10)
+	if err != nil {
+		t.Fatalf("SetReadBuffer() error = %v", err)
+	}
+	if granted != 64<<10 {
+		t.Errorf("SetReadBuffer() granted %d, want %d", granted, 64<<10)
+	}
+
+	// The size survives the watchdog re-creating the socket
+	node.socket().Close()
+	if !node.restartListener() {
+		t.Fatal("restartListener() failed")
+	}
+	if got, err := readBufferSize(node.socket()); err != nil || got != 64<<10 {
+		t.Errorf("readBufferSize() after restart = %d, %v, want %d", got, err, 64<<10)
+	}
+}
+
+func TestUDPNodeKernelDrops(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{1}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	if _, err := node.SetReadBuffer(1); err != nil {
+		t.Fatalf("SetReadBuffer() error = %v", err)
+	}
+
+	// Nothing reads the socket, so the smallest buffer overflows at once
+	sender, err := net.Dial("udp", net.JoinHostPort("127.0.0.1", strconv.Itoa(node.Port())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	deadline := time.Now().Add(3 * time.Second)
+	for node.Stats().KernelDrops == 0 && time.Now().Before(deadline) {
+		for i := 0; i < 100; i++ {
+			sender.Write(make([]byte, 512))
+		}
+	}
+	if node.Stats().KernelDrops == 0 {
+		t.Error("KernelDrops = 0 after overflowing the receive buffer")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package registry
+
+import (
+	"errors"
+	"net"
+)
+
+// readBufferSize is not implemented on this platform
+func readBufferSize(conn *net.UDPConn) (int, error) {
+	return 0, errors.New("reading the receive buffer size is not supported on this platform")
+}
+
+// socketDrops is not reported on this platform
+func socketDrops(conn *net.UDPConn) uint64 {
+	return 0
+}
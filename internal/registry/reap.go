@@ -0,0 +1,110 @@
+package registry
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultReapBurst is how many expired nodes the reaper removes each time it
+// takes a shard's write lock
+const DefaultReapBurst = 64
+
+// SetReapBurst sets how many expired nodes the reaper removes each time it
+// takes a shard's write lock (0 uses DefaultReapBurst). Smaller bursts keep
+// the pauses updates see short when many nodes time out at once on a large
+// shard. Must be called before the reaper starts
+func (m *Monitor) SetReapBurst(n int) {
+	m.reapBurst = n
+}
+
+// expiry decides whether a node is gone at the time of a reap. Both funcs
+// are called with the shard lock held, read or write, so they must not
+// modify the shard. gone runs for every node, so it must be cheap; message
+// returns the line to log and runs only for nodes removed
+type expiry struct {
+	gone    func(s *shard, addr string, info NodeInfo) bool
+	message func(s *shard, addr string) string
+}
+
+// reapShard removes the expired nodes of s and returns how many it removed.
+// Candidates are found under the read lock, so updates wait only while a
+// burst of them is removed. Each is checked again under the write lock, so
+// a node refreshed since the scan is kept. Left events are emitted under the
+// lock, ordered with the updates' own events
+func (m *Monitor) reapShard(s *shard, now time.Time, expired expiry) int {
+	var candidates []string
+	s.mu.RLock()
+	for addr, info := range s.nodes {
+		if expired.gone(s, addr, info) {
+			candidates = append(candidates, addr)
+		}
+	}
+	s.mu.RUnlock()
+
+	burst := m.reapBurst
+	if burst <= 0 {
+		burst = DefaultReapBurst
+	}
+	reaped := 0
+	var messages []string
+	for len(candidates) > 0 {
+		n := min(burst, len(candidates))
+		messages = messages[:0]
+		s.mu.Lock()
+		for _, addr := range candidates[:n] {
+			info, ok := s.nodes[addr]
+			if !ok {
+				continue
+			}
+			if !expired.gone(s, addr, info) {
+				continue
+			}
+			messages = append(messages, expired.message(s, addr))
+			s.remove(addr)
+			m.markReaped(addr)
+			reaped++
+			m.emit(Event{Time: now, Type: EventLeft, Address: addr, StatusCode: info.StatusCode})
+		}
+		s.mu.Unlock()
+		for _, msg := range messages {
+			log.Print(msg)
+		}
+		candidates = candidates[n:]
+	}
+	return reaped
+}
+
+// timeoutExpiry expires nodes not heard from for longer than timeout
+func timeoutExpiry(now time.Time, timeout time.Duration) expiry {
+	return expiry{
+		gone: func(_ *shard, _ string, info NodeInfo) bool {
+			return now.Sub(info.LastSeen) > timeout
+		},
+		message: func(_ *shard, addr string) string {
+			return fmt.Sprintf("Node %s timed out", addr)
+		},
+	}
+}
+
+// phiExpiry expires nodes whose phi exceeds threshold, and nodes without
+// enough heartbeat history for phi after fallbackTimeout
+func phiExpiry(now time.Time, threshold float64, fallbackTimeout time.Duration) expiry {
+	fallback := timeoutExpiry(now, fallbackTimeout)
+	return expiry{
+		gone: func(s *shard, addr string, info NodeInfo) bool {
+			w, ok := s.arrivals[addr]
+			if !ok || w.samples() == 0 {
+				return fallback.gone(s, addr, info)
+			}
+			return w.phi(now) > threshold
+		},
+		message: func(s *shard, addr string) string {
+			w, ok := s.arrivals[addr]
+			if !ok || w.samples() == 0 {
+				return fallback.message(s, addr)
+			}
+			return fmt.Sprintf("Node %s suspected failed (phi=%.2f)", addr, w.phi(now))
+		},
+	}
+}
@@ -0,0 +1,158 @@
+package registry
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+)
+
+// sameShardAddrs returns n addresses that land in one shard
+func sameShardAddrs(m *Monitor, n int) (*shard, []string) {
+	first, _ := m.getShard("10.0.0.0:9999")
+	addrs := []string{"10.0.0.0:9999"}
+	for i := 1; len(addrs) < n; i++ {
+		addr := fmt.Sprintf("10.%d.%d.%d:9999", i>>16, i>>8&0xff, i&0xff)
+		if s, _ := m.getShard(addr); s == first {
+			addrs = append(addrs, addr)
+		}
+	}
+	return first, addrs
+}
+
+func TestReapShardBursts(t *testing.T) {
+	m := NewMonitor()
+	fake := clock.NewFake(time.Now())
+	m.SetClock(fake)
+	m.SetReapBurst(1)
+	reaped := reapedChan(m)
+
+	s, addrs := sameShardAddrs(m, 5)
+	for _, addr := range addrs {
+		m.UpdateWithStatus(addr, 0, 0)
+	}
+	fake.Advance(time.Minute)
+
+	if n := m.reapShard(s, fake.Now(), timeoutExpiry(fake.Now(), 30*time.Second)); n != len(addrs) {
+		t.Fatalf("reapShard() = %d, want %d", n, len(addrs))
+	}
+	if n := m.GetNodeCount(); n != 0 {
+		t.Errorf("GetNodeCount() = %d, want 0", n)
+	}
+	var left []string
+	for range addrs {
+		left = append(left, <-reaped)
+	}
+	sort.Strings(left)
+	sort.Strings(addrs)
+	if fmt.Sprint(left) != fmt.Sprint(addrs) {
+		t.Errorf("EventLeft for %v, want %v", left, addrs)
+	}
+}
+
+func TestReapShardKeepsRefreshedNode(t *testing.T) {
+	m := NewMonitor()
+	fake := clock.NewFake(time.Now())
+	m.SetClock(fake)
+	m.SetReapBurst(1)
+
+	s, addrs := sameShardAddrs(m, 2)
+	for _, addr := range addrs {
+		m.UpdateWithStatus(addr, 0, 0)
+	}
+	fake.Advance(time.Minute)
+	now := fake.Now()
+
+	// Refresh whichever candidate is left once the first burst has begun,
+	// as a heartbeat arriving between bursts would
+	timedOut := timeoutExpiry(now, 30*time.Second)
+	calls := 0
+	refreshed := ""
+	expired := timedOut
+	expired.gone = func(s *shard, addr string, info NodeInfo) bool {
+		calls++
+		if calls == len(addrs)+1 {
+			for _, other := range addrs {
+				if other != addr {
+					refreshed = other
+					fresh := s.nodes[other]
+					fresh.LastSeen = now
+					s.nodes[other] = fresh
+				}
+			}
+		}
+		return timedOut.gone(s, addr, info)
+	}
+
+	if n := m.reapShard(s, now, expired); n != 1 {
+		t.Fatalf("reapShard() = %d, want 1", n)
+	}
+	if _, ok := m.GetNodeInfo(refreshed); !ok {
+		t.Errorf("node %s refreshed before its burst was reaped", refreshed)
+	}
+}
+
+// BenchmarkUpdateDuringReap measures heartbeats to a shard whose other
+// 50k nodes keep expiring, reporting the p99 and worst update latency. A
+// burst as large as the shard holds its write lock for every removal at once
+func BenchmarkUpdateDuringReap(b *testing.B) {
+	for _, burst := range []int{DefaultReapBurst, math.MaxInt32} {
+		b.Run(fmt.Sprintf("burst=%d", burst), func(b *testing.B) {
+			benchmarkUpdateDuringReap(b, burst)
+		})
+	}
+}
+
+func benchmarkUpdateDuringReap(b *testing.B, burst int) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	m := NewMonitor()
+	m.SetReapBurst(burst)
+	s, addrs := sameShardAddrs(m, 50100)
+	live, expired := addrs[:100], addrs[100:]
+	stale := time.Now().Add(-time.Hour)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			for _, addr := range expired {
+				s.mu.Lock()
+				s.nodes[addr] = NodeInfo{LastSeen: stale, Address: addr}
+				s.mu.Unlock()
+			}
+			now := time.Now()
+			m.reapShard(s, now, timeoutExpiry(now, time.Minute))
+		}
+	}()
+
+	latencies := make([]time.Duration, b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		m.UpdateWithStatus(live[i%len(live)], 0, 0)
+		latencies[i] = time.Since(start)
+	}
+	b.StopTimer()
+	close(stop)
+	wg.Wait()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+	b.ReportMetric(float64(latencies[len(latencies)-1].Nanoseconds()), "max-ns")
+}
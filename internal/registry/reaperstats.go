@@ -0,0 +1,39 @@
+package registry
+
+import "time"
+
+// ReaperStats counts the nodes removed by the reapers. A jump in the batch
+// size points at a network partition or a mass restart
+type ReaperStats struct {
+	NodesReapedTotal uint64    `json:"nodes_reaped_total"`
+	LastBatchSize    int       `json:"last_reap_batch_size"` // Nodes removed by the most recent reaper cycle
+	Cycles           uint64    `json:"cycles"`
+	LastReap         time.Time `json:"last_reap,omitempty"` // When a node was last removed (zero if never)
+	NodesEvicted     uint64    `json:"nodes_evicted_total"` // Nodes removed to stay within SetMaxNodes
+}
+
+// ReaperStats returns the reaper counters
+func (m *Monitor) ReaperStats() ReaperStats {
+	m.reaperMu.Lock()
+	defer m.reaperMu.Unlock()
+	return m.reaper
+}
+
+// recordReapCycle updates the reaper counters after a cycle removed n nodes
+func (m *Monitor) recordReapCycle(n int, now time.Time) {
+	m.reaperMu.Lock()
+	defer m.reaperMu.Unlock()
+	m.reaper.Cycles++
+	m.reaper.LastBatchSize = n
+	if n > 0 {
+		m.reaper.NodesReapedTotal += uint64(n)
+		m.reaper.LastReap = now
+	}
+}
+
+// recordEviction counts a node evicted to stay within SetMaxNodes
+func (m *Monitor) recordEviction() {
+	m.reaperMu.Lock()
+	defer m.reaperMu.Unlock()
+	m.reaper.NodesEvicted++
+}
@@ -0,0 +1,151 @@
+package registry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SeverityPolicy decides which statuses are actionable and maps the cluster
+// status to a process exit code. It is shared by one-shot checks and alerting
+// so org-specific semantics live in one place
+type SeverityPolicy struct {
+	OKExitCode       int
+	WarnExitCode     int
+	CriticalExitCode int
+	WarnActionable   bool // If false, WARN is treated like OK (CRITICAL is always actionable)
+
+	// Exit code when fewer nodes are known than expected. It wins over
+	// the cluster status, since that status is built on too little. Nil
+	// exits with CriticalExitCode
+	BelowMinNodesExitCode *int
+}
+
+// Exit conditions, as named in ParseExitCodes specs
+const (
+	ConditionOK            = "ok"
+	ConditionAnyWarn       = "any-warn"
+	ConditionAnyCritical   = "any-critical"
+	ConditionBelowMinNodes = "below-min-nodes"
+)
+
+// MaxExitCode is the highest exit code a process can return
+const MaxExitCode = 255
+
+// DefaultSeverityPolicy returns the policy OK=0, WARN=1, CRITICAL=2 with WARN
+// actionable, and the critical code for too few nodes
+func DefaultSeverityPolicy() SeverityPolicy {
+	return SeverityPolicy{
+		OKExitCode:       0,
+		WarnExitCode:     1,
+		CriticalExitCode: 2,
+		WarnActionable:   true,
+	}
+}
+
+// BelowMinNodes returns the exit code when fewer nodes are known than
+// expected: BelowMinNodesExitCode, or CriticalExitCode when it is unset
+func (p SeverityPolicy) BelowMinNodes() int {
+	if p.BelowMinNodesExitCode == nil {
+		return p.CriticalExitCode
+	}
+	return *p.BelowMinNodesExitCode
+}
+
+// codes returns a pointer to the exit code for each condition, so the
+// mapping is total by construction. An unset below-min-nodes code is
+// allocated with its effective value
+func (p *SeverityPolicy) codes() map[string]*int {
+	below := p.BelowMinNodes()
+	return map[string]*int{
+		ConditionOK:            &p.OKExitCode,
+		ConditionAnyWarn:       &p.WarnExitCode,
+		ConditionAnyCritical:   &p.CriticalExitCode,
+		ConditionBelowMinNodes: &below,
+	}
+}
+
+// ParseExitCodes overrides the exit codes of p from a comma-separated list
+// of condition=code pairs, e.g. "any-warn=0,below-min-nodes=3". Conditions
+// left out keep their code from p
+func ParseExitCodes(spec string, p SeverityPolicy) (SeverityPolicy, error) {
+	codes := p.codes()
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		condition, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return p, fmt.Errorf("invalid exit code %q: expected condition=code", entry)
+		}
+		code, known := codes[condition]
+		if !known {
+			return p, fmt.Errorf("invalid exit code %q: unknown condition %q (want %s, %s, %s or %s)", entry, condition,
+				ConditionOK, ConditionAnyWarn, ConditionAnyCritical, ConditionBelowMinNodes)
+		}
+		if seen[condition] {
+			return p, fmt.Errorf("invalid exit code %q: %s given twice", entry, condition)
+		}
+		seen[condition] = true
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return p, fmt.Errorf("invalid exit code %q: %w", entry, err)
+		}
+		*code = n
+	}
+	// Left out, below-min-nodes keeps following the critical code
+	if seen[ConditionBelowMinNodes] {
+		p.BelowMinNodesExitCode = codes[ConditionBelowMinNodes]
+	}
+	return p, p.Validate()
+}
+
+// Validate checks that every condition maps to a code a process can exit with
+func (p SeverityPolicy) Validate() error {
+	for _, condition := range []string{ConditionOK, ConditionAnyWarn, ConditionAnyCritical, ConditionBelowMinNodes} {
+		if code := *p.codes()[condition]; code < 0 || code > MaxExitCode {
+			return fmt.Errorf("exit code %d for %s must be between 0 and %d", code, condition, MaxExitCode)
+		}
+	}
+	return nil
+}
+
+// Actionable reports whether a status code should trigger an alert
+// Unknown status codes are treated as Critical
+func (p SeverityPolicy) Actionable(status uint8) bool {
+	switch status {
+	case 0:
+		return false
+	case 1:
+		return p.WarnActionable
+	default:
+		return true
+	}
+}
+
+// ExitCode maps a status code to an exit code. Non-actionable statuses map to OKExitCode
+func (p SeverityPolicy) ExitCode(status uint8) int {
+	if !p.Actionable(status) {
+		return p.OKExitCode
+	}
+	if status == 1 {
+		return p.WarnExitCode
+	}
+	return p.CriticalExitCode
+}
+
+// ExitCodeFor returns the exit code for the worst status in a summary
+func (p SeverityPolicy) ExitCodeFor(s Summary) int {
+	return p.ExitCode(s.WorstStatus())
+}
+
+// ExitCodeForMin is ExitCodeFor, but returns BelowMinNodesExitCode when
+// fewer than minNodes peers are known
+func (p SeverityPolicy) ExitCodeForMin(s Summary, peers, minNodes int) int {
+	if peers < minNodes {
+		return p.BelowMinNodes()
+	}
+	return p.ExitCodeFor(s)
+}
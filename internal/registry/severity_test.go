@@ -0,0 +1,94 @@
+package registry
+
+import "testing"
+
+func TestDefaultSeverityPolicy(t *testing.T) {
+	p := DefaultSeverityPolicy()
+
+	testCases := []struct {
+		status     uint8
+		actionable bool
+		exitCode   int
+	}{
+		{0, false, 0},
+		{1, true, 1},
+		{2, true, 2},
+		{7, true, 2}, // Unknown counts as Critical
+	}
+
+	for _, tc := range testCases {
+		if got := p.Actionable(tc.status); got != tc.actionable {
+			t.Errorf("Actionable(%d) = %v, want %v", tc.status, got, tc.actionable)
+		}
+		if got := p.ExitCode(tc.status); got != tc.exitCode {
+			t.Errorf("ExitCode(%d) = %d, want %d", tc.status, got, tc.exitCode)
+		}
+	}
+}
+
+func TestSeverityPolicyCustom(t *testing.T) {
+	// An org that ignores WARN and uses Nagios-style codes for CRITICAL
+	p := SeverityPolicy{OKExitCode: 0, WarnExitCode: 1, CriticalExitCode: 3, WarnActionable: false}
+
+	if p.Actionable(1) {
+		t.Error("Actionable(WARN) = true with WarnActionable disabled")
+	}
+	if got := p.ExitCodeFor(Summary{Total: 2, OK: 1, Warn: 1}); got != 0 {
+		t.Errorf("ExitCodeFor(warn cluster) = %d, want 0", got)
+	}
+	if got := p.ExitCodeFor(Summary{Total: 2, Warn: 1, Critical: 1}); got != 3 {
+		t.Errorf("ExitCodeFor(critical cluster) = %d, want 3", got)
+	}
+}
+
+func TestBelowMinNodesUnset(t *testing.T) {
+	// A policy built without a below-min-nodes code must not exit OK
+	p := SeverityPolicy{WarnExitCode: 1, CriticalExitCode: 2}
+	if got := p.ExitCodeForMin(Summary{}, 0, 1); got != 2 {
+		t.Errorf("ExitCodeForMin(too few) = %d, want the critical code 2", got)
+	}
+	zero := 0
+	p.BelowMinNodesExitCode = &zero
+	if got := p.ExitCodeForMin(Summary{}, 0, 1); got != 0 {
+		t.Errorf("ExitCodeForMin(too few) = %d, want the explicit 0", got)
+	}
+}
+
+func TestParseExitCodes(t *testing.T) {
+	// Exit 0 unless discovery came up short, whatever the nodes' health
+	p, err := ParseExitCodes(" any-warn=0, any-critical=0,below-min-nodes=3,", DefaultSeverityPolicy())
+	if err != nil {
+		t.Fatalf("ParseExitCodes() error = %v", err)
+	}
+	if p.OKExitCode != 0 || p.WarnExitCode != 0 || p.CriticalExitCode != 0 || !p.WarnActionable || p.BelowMinNodes() != 3 {
+		t.Errorf("ParseExitCodes() = %+v, below-min-nodes %d", p, p.BelowMinNodes())
+	}
+	if got := p.ExitCodeForMin(Summary{Total: 2, Critical: 2}, 2, 3); got != 3 {
+		t.Errorf("ExitCodeForMin(too few) = %d, want 3", got)
+	}
+	if got := p.ExitCodeForMin(Summary{Total: 3, Critical: 3}, 3, 3); got != 0 {
+		t.Errorf("ExitCodeForMin(critical cluster) = %d, want 0", got)
+	}
+
+	if p, err := ParseExitCodes("", DefaultSeverityPolicy()); err != nil || p != DefaultSeverityPolicy() {
+		t.Errorf("ParseExitCodes(\"\") = %+v, %v, want the defaults", p, err)
+	}
+
+	// Left out, below-min-nodes follows the critical code
+	if p, err := ParseExitCodes("any-critical=3", DefaultSeverityPolicy()); err != nil || p.BelowMinNodes() != 3 {
+		t.Errorf("ParseExitCodes(any-critical=3) below-min-nodes = %d, %v, want 3", p.BelowMinNodes(), err)
+	}
+
+	for _, spec := range []string{
+		"any-warn",              // Missing code
+		"quorum=2",              // Unknown condition
+		"ok=zero",               // Not a number
+		"any-critical=256",      // Out of range
+		"ok=-1",                 // Out of range
+		"any-warn=1,any-warn=0", // Duplicate
+	} {
+		if _, err := ParseExitCodes(spec, DefaultSeverityPolicy()); err == nil {
+			t.Errorf("ParseExitCodes(%q) should return error", spec)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package registry
+
+import "time"
+
+// Silence puts a node into maintenance until the given time. Silenced nodes are
+// reported as MAINTENANCE and excluded from the alerting status in Summarize
+func (m *Monitor) Silence(addr string, until time.Time) {
+	m.silenceMu.Lock()
+	defer m.silenceMu.Unlock()
+	if m.silences == nil {
+		m.silences = make(map[string]time.Time)
+	}
+	m.silences[CanonicalAddr(addr)] = until
+}
+
+// Unsilence ends a node's maintenance window early
+func (m *Monitor) Unsilence(addr string) {
+	m.silenceMu.Lock()
+	defer m.silenceMu.Unlock()
+	delete(m.silences, CanonicalAddr(addr))
+}
+
+// Silences returns the active maintenance windows, dropping expired ones
+func (m *Monitor) Silences() map[string]time.Time {
+	m.silenceMu.Lock()
+	defer m.silenceMu.Unlock()
+	now := m.clock.Now()
+	result := make(map[string]time.Time, len(m.silences))
+	for addr, until := range m.silences {
+		if !now.Before(until) {
+			delete(m.silences, addr)
+			continue
+		}
+		result[addr] = until
+	}
+	return result
+}
+
+// isSilenced reports whether addr is inside a maintenance window at now
+func (m *Monitor) isSilenced(addr string, now time.Time) bool {
+	m.silenceMu.RLock()
+	defer m.silenceMu.RUnlock()
+	until, ok := m.silences[addr]
+	return ok && now.Before(until)
+}
@@ -0,0 +1,43 @@
+package registry
+
+import (
+	"log"
+	"time"
+)
+
+// DefaultSkewTolerance is how far ahead of the local clock a sender's packet
+// timestamp may be before it is clamped
+const DefaultSkewTolerance = time.Second
+
+// SetSkewTolerance sets how far in the future a sender's packet timestamp
+// may be. Later timestamps are clamped to the receive time plus tolerance,
+// so a fast peer clock never yields a negative age or RTT. The measured
+// skew is kept in NodeInfo.ClockSkew. Must be called before the monitor is used
+func (m *Monitor) SetSkewTolerance(tolerance time.Duration) {
+	if tolerance < 0 {
+		tolerance = 0
+	}
+	m.skewTolerance = tolerance
+}
+
+// recordPacketTime stores a sender's timestamp in info along with its clock
+// skew against the local receive time, clamping future timestamps. prev is
+// the node's previous state, used to log only when a node crosses the
+// tolerance rather than on every heartbeat
+func (m *Monitor) recordPacketTime(info *NodeInfo, prev NodeInfo, packetTimestamp int64, now time.Time) {
+	info.PacketTime = packetTimestamp
+	if packetTimestamp <= 0 {
+		info.ClockSkew = 0
+		return
+	}
+
+	info.ClockSkew = time.Unix(0, packetTimestamp).Sub(now)
+	if info.ClockSkew <= m.skewTolerance {
+		return
+	}
+	info.PacketTime = now.Add(m.skewTolerance).UnixNano()
+	if prev.ClockSkew <= m.skewTolerance {
+		log.Printf("Warning: clock of %s is %v ahead of ours; clamping its packet timestamps to +%v",
+			info.Address, info.ClockSkew.Round(time.Millisecond), m.skewTolerance)
+	}
+}
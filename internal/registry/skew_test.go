@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+)
+
+func TestMonitorFutureTimestamps(t *testing.T) {
+	m := NewMonitor()
+	fake := clock.NewFake(time.Now())
+	m.SetClock(fake)
+	var uuid [16]byte
+	addr := "10.0.0.1:9999"
+
+	// A peer whose clock runs 10s ahead of ours
+	now := fake.Now()
+	m.UpdateWithHeartbeat(addr, uuid, 0, now.Add(10*time.Second).UnixNano())
+
+	info, _ := m.GetNodeInfo(addr)
+	if info.ClockSkew != 10*time.Second {
+		t.Errorf("ClockSkew = %v, want 10s", info.ClockSkew)
+	}
+	if want := now.Add(DefaultSkewTolerance).UnixNano(); info.PacketTime != want {
+		t.Errorf("PacketTime = %d, want clamped to %d", info.PacketTime, want)
+	}
+	if !info.LastSeen.Equal(now) {
+		t.Errorf("LastSeen = %v, want local receive time %v", info.LastSeen, now)
+	}
+
+	// Its age follows our clock, so the reaper treats it like any other node
+	reaped := reapedChan(m)
+	defer m.Stop()
+	go m.StartReaper(50*time.Millisecond, 100*time.Millisecond)
+	fake.BlockUntil(1)
+	fake.Advance(50 * time.Millisecond)
+	if _, ok := m.GetNodeInfo(addr); !ok {
+		t.Fatal("future-skewed node was removed before the timeout")
+	}
+	fake.Advance(100 * time.Millisecond)
+	select {
+	case <-reaped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("reaper did not remove the silent future-skewed node")
+	}
+
+	// A timestamp within the tolerance, or behind ours, is kept as sent
+	now = fake.Now()
+	for _, skew := range []time.Duration{500 * time.Millisecond, -3 * time.Second} {
+		m.UpdateWithReport(addr, uuid, 0, now.Add(skew).UnixNano(), 1, 2, 3)
+		info, _ = m.GetNodeInfo(addr)
+		if info.ClockSkew != skew || info.PacketTime != now.Add(skew).UnixNano() {
+			t.Errorf("skew %v: ClockSkew = %v, PacketTime = %d, want unclamped", skew, info.ClockSkew, info.PacketTime)
+		}
+	}
+
+	// Senders without a timestamp have no measurable skew
+	m.UpdateWithStatus(addr, 0, 0)
+	if info, _ = m.GetNodeInfo(addr); info.ClockSkew != 0 || info.PacketTime != 0 {
+		t.Errorf("without timestamp: ClockSkew = %v, PacketTime = %d, want 0", info.ClockSkew, info.PacketTime)
+	}
+}
+
+func TestMonitorSkewTolerance(t *testing.T) {
+	m := NewMonitor()
+	fake := clock.NewFake(time.Now())
+	m.SetClock(fake)
+	m.SetSkewTolerance(0)
+	var uuid [16]byte
+
+	now := fake.Now()
+	m.UpdateWithHeartbeat("10.0.0.1:9999", uuid, 0, now.Add(time.Millisecond).UnixNano())
+	info, _ := m.GetNodeInfo("10.0.0.1:9999")
+	if info.PacketTime != now.UnixNano() || info.ClockSkew != time.Millisecond {
+		t.Errorf("zero tolerance: PacketTime = %d, ClockSkew = %v, want clamped to now with 1ms skew",
+			info.PacketTime, info.ClockSkew)
+	}
+}
@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Snapshot is a point-in-time copy of the full monitor state, including
+// internal fields such as RTT, phi and packet timestamps that reports omit
+type Snapshot struct {
+	Time                time.Time            `json:"time"`
+	Summary             Summary              `json:"summary"`
+	Nodes               map[string]NodeInfo  `json:"nodes"`
+	Silences            map[string]time.Time `json:"silences,omitempty"`
+	DuplicateIdentities []DuplicateIdentity  `json:"duplicate_identities,omitempty"`
+	Reaper              ReaperStats          `json:"reaper"`
+}
+
+// Snapshot copies the monitor state atomically: every shard is locked for
+// the duration of the copy, so no update lands halfway through
+func (m *Monitor) Snapshot() Snapshot {
+	// Shards are always locked in index order, and single-shard updates
+	// never wait on another shard, so this cannot deadlock
+	for i := 0; i < numShards; i++ {
+		m.shards[i].mu.RLock()
+	}
+	defer func() {
+		for i := 0; i < numShards; i++ {
+			m.shards[i].mu.RUnlock()
+		}
+	}()
+
+	snap := Snapshot{
+		Time:     m.clock.Now(),
+		Nodes:    make(map[string]NodeInfo),
+		Silences: make(map[string]time.Time),
+	}
+
+	m.silenceMu.RLock()
+	for addr, until := range m.silences {
+		if snap.Time.Before(until) {
+			snap.Silences[addr] = until
+		}
+	}
+	m.silenceMu.RUnlock()
+
+	for i := 0; i < numShards; i++ {
+		shard := m.shards[i]
+		for addr, info := range shard.nodes {
+			_, info.Silenced = snap.Silences[addr]
+			snap.Nodes[addr] = shard.withComputed(addr, info, snap.Time)
+		}
+	}
+	// Dependencies are looked up in the copy; the shards are already locked
+	down := m.downDependenciesIn(func(addr string) (NodeInfo, bool) {
+		info, ok := snap.Nodes[addr]
+		return info, ok
+	})
+	for addr, info := range snap.Nodes {
+		if m.suppressed(addr, info, down) {
+			info.Suppressed = true
+			snap.Nodes[addr] = info
+		}
+		snap.Summary.Add(info)
+	}
+
+	if m.dupWindow > 0 {
+		m.identityMu.Lock()
+		snap.DuplicateIdentities = m.duplicateIdentities(snap.Time)
+		m.identityMu.Unlock()
+	}
+	snap.Reaper = m.ReaperStats()
+	return snap
+}
+
+// DumpSnapshot writes an atomic snapshot of the monitor state to w as indented JSON
+func (m *Monitor) DumpSnapshot(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m.Snapshot())
+}
@@ -0,0 +1,85 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMonitorSnapshot(t *testing.T) {
+	m := NewMonitor()
+	m.SetDuplicateWindow(time.Minute)
+	var uuid [16]byte
+	copy(uuid[:], "cloned-vm")
+	m.UpdateWithHeartbeat("10.0.0.1:9999", uuid, 0, 42)
+	m.UpdateWithHeartbeat("10.0.0.2:9999", uuid, 2, 43)
+	m.UpdateWithTelemetry("10.0.0.3:9999", 10, 20, 30, 1)
+	m.Silence("10.0.0.3:9999", time.Now().Add(time.Hour))
+
+	snap := m.Snapshot()
+	if len(snap.Nodes) != 3 {
+		t.Fatalf("Snapshot() has %d nodes, want 3", len(snap.Nodes))
+	}
+	if info := snap.Nodes["10.0.0.1:9999"]; info.PacketTime != 42 || info.UUID != uuid {
+		t.Errorf("Snapshot() node = %+v, want internal fields kept", info)
+	}
+	if !snap.Nodes["10.0.0.3:9999"].Silenced || len(snap.Silences) != 1 {
+		t.Errorf("Snapshot() silences = %v, want 10.0.0.3:9999 silenced", snap.Silences)
+	}
+	if want := (Summary{Total: 3, OK: 1, Critical: 1, Maintenance: 1}); snap.Summary != want {
+		t.Errorf("Snapshot() summary = %+v, want %+v", snap.Summary, want)
+	}
+	if len(snap.DuplicateIdentities) != 1 {
+		t.Errorf("Snapshot() duplicates = %v, want 1", snap.DuplicateIdentities)
+	}
+
+	var buf bytes.Buffer
+	if err := m.DumpSnapshot(&buf); err != nil {
+		t.Fatalf("DumpSnapshot() error = %v", err)
+	}
+	var decoded Snapshot
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("DumpSnapshot() wrote invalid JSON: %v", err)
+	}
+	if len(decoded.Nodes) != 3 || decoded.Summary != snap.Summary {
+		t.Errorf("decoded snapshot = %+v", decoded)
+	}
+}
+
+func TestMonitorSnapshotConcurrentUpdates(t *testing.T) {
+	m := NewMonitor()
+	addrs := []string{"10.0.0.1:9999", "10.0.0.2:9999", "10.0.0.3:9999", "10.0.0.4:9999"}
+
+	// Snapshots taken while nodes are updated stay internally consistent
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for status := uint8(0); ; status = (status + 1) % 3 {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			for _, addr := range addrs {
+				m.UpdateWithStatus(addr, status, 0)
+			}
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		snap := m.Snapshot()
+		if snap.Summary.Total != len(snap.Nodes) {
+			t.Fatalf("summary total %d != %d nodes", snap.Summary.Total, len(snap.Nodes))
+		}
+		counts := snap.Summary.OK + snap.Summary.Warn + snap.Summary.Critical
+		if counts != snap.Summary.Total {
+			t.Fatalf("summary counts %d != total %d", counts, snap.Summary.Total)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,77 @@
+package registry
+
+import (
+	"fmt"
+	"time"
+)
+
+// TrafficHealth is a node's assessment of its own monitoring traffic, as
+// opposed to the health of the host it runs on. Problems are local faults
+// that a restart may fix; Warnings are peer silence, which may be anywhere
+// on the path and which a restart would not fix
+type TrafficHealth struct {
+	Healthy      bool       `json:"healthy"`
+	Problems     []string   `json:"problems,omitempty"`
+	Warnings     []string   `json:"warnings,omitempty"`
+	LastSent     *time.Time `json:"last_sent,omitempty"`     // nil until a send succeeds
+	LastReceived *time.Time `json:"last_received,omitempty"` // nil until a packet arrives
+}
+
+// TrafficHealth reports the traffic unhealthy once every send has failed
+// for longer than after, or once the listener could not be restarted. It
+// warns once nothing was received for longer than after while there are
+// peers to hear from. These catch a process that is alive but no longer
+// monitors, e.g. a wedged socket or lost routes. A zero after checks the
+// listener only
+func (u *UDPNode) TrafficHealth(after time.Duration) TrafficHealth {
+	now := u.clock.Now()
+	h := TrafficHealth{
+		LastSent:     unixNanoTime(u.lastSent.Load()),
+		LastReceived: unixNanoTime(u.lastReceived.Load()),
+	}
+	if u.deaf.Load() {
+		h.Problems = append(h.Problems, "UDP listener is down")
+	}
+	if after > 0 {
+		if since := u.sendFailingSince.Load(); since != 0 {
+			if d := now.Sub(time.Unix(0, since)); d > after {
+				h.Problems = append(h.Problems, fmt.Sprintf("every send has failed for %s", d.Round(time.Second)))
+			}
+		}
+		last := u.lastReceived.Load()
+		if last == 0 {
+			last = u.startedAt.Load()
+		}
+		if last != 0 && u.PeerCount() > 0 {
+			if d := now.Sub(time.Unix(0, last)); d > after {
+				h.Warnings = append(h.Warnings, fmt.Sprintf("nothing received for %s", d.Round(time.Second)))
+			}
+		}
+	}
+	h.Healthy = len(h.Problems) == 0
+	return h
+}
+
+// Ready reports whether the traffic is healthy and the peers are heard from
+func (h TrafficHealth) Ready() bool {
+	return h.Healthy && len(h.Warnings) == 0
+}
+
+// sendSucceeded and sendFailed track the sends for TrafficHealth
+func (u *UDPNode) sendSucceeded() {
+	u.lastSent.Store(u.clock.Now().UnixNano())
+	u.sendFailingSince.Store(0)
+}
+
+func (u *UDPNode) sendFailed() {
+	u.sendFailingSince.CompareAndSwap(0, u.clock.Now().UnixNano())
+}
+
+// unixNanoTime returns the time ns nanoseconds after the epoch, or nil for 0
+func unixNanoTime(ns int64) *time.Time {
+	if ns == 0 {
+		return nil
+	}
+	t := time.Unix(0, ns)
+	return &t
+}
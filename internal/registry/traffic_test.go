@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+	"github.com/rafaelmarinho/pulsecheck/internal/protocol"
+)
+
+func TestTrafficHealthSends(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{1}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	fake := clock.NewFake(time.Now())
+	node.SetClock(fake)
+	if err := node.AddPeer("127.0.0.1:9"); err != nil {
+		t.Fatal(err)
+	}
+
+	node.BroadcastHeartbeat(0)
+	h := node.TrafficHealth(30 * time.Second)
+	if !h.Healthy || h.LastSent == nil {
+		t.Fatalf("TrafficHealth() = %+v, want healthy with a send", h)
+	}
+
+	// A wedged socket fails every send, but only for long is it a problem
+	node.Conn().Close()
+	node.BroadcastHeartbeat(0)
+	fake.Advance(10 * time.Second)
+	node.BroadcastHeartbeat(0)
+	if h := node.TrafficHealth(30 * time.Second); !h.Healthy {
+		t.Errorf("TrafficHealth() = %+v, want healthy 10s into failing sends", h)
+	}
+	fake.Advance(25 * time.Second)
+	h = node.TrafficHealth(30 * time.Second)
+	if h.Healthy || len(h.Problems) != 1 || !strings.Contains(h.Problems[0], "every send has failed for 35s") {
+		t.Errorf("TrafficHealth() = %+v, want failing sends", h)
+	}
+	if h := node.TrafficHealth(0); !h.Healthy {
+		t.Errorf("TrafficHealth(0) = %+v, want only the listener checked", h)
+	}
+}
+
+func TestTrafficHealthReceives(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{1}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	fake := clock.NewFake(time.Now())
+	node.SetClock(fake)
+	node.ioTimeout = 50 * time.Millisecond
+	go node.Start()
+	defer node.Stop()
+	deadline := time.Now().Add(3 * time.Second)
+	for node.startedAt.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Silence is expected with nobody to hear from
+	fake.Advance(time.Minute)
+	if h := node.TrafficHealth(30 * time.Second); !h.Healthy {
+		t.Errorf("TrafficHealth() = %+v, want healthy without peers", h)
+	}
+	if err := node.AddPeer("127.0.0.1:9"); err != nil {
+		t.Fatal(err)
+	}
+	// Silence is not a local fault, so it only warns
+	h := node.TrafficHealth(30 * time.Second)
+	if !h.Healthy || h.Ready() || len(h.Warnings) != 1 || !strings.Contains(h.Warnings[0], "nothing received for 1m0s") {
+		t.Errorf("TrafficHealth() = %+v, want healthy with nothing received", h)
+	}
+
+	sender, err := net.Dial("udp", net.JoinHostPort("127.0.0.1", strconv.Itoa(node.Port())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	data, err := protocol.NewPacket([16]byte{2}, 0).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for node.Stats().PacketsReceived == 0 && time.Now().Before(deadline) {
+		sender.Write(data)
+		time.Sleep(20 * time.Millisecond)
+	}
+	if h := node.TrafficHealth(30 * time.Second); !h.Ready() || h.LastReceived == nil {
+		t.Errorf("TrafficHealth() = %+v, want healthy after a packet", h)
+	}
+}
@@ -0,0 +1,88 @@
+package registry
+
+// Visibility is how many of the nodes sharing their peer view currently
+// see a node
+type Visibility struct {
+	SeenBy  int // Viewers whose latest view includes the node
+	Viewers int // Nodes sharing a view, not counting the node itself
+}
+
+// Partial reports whether some viewers do not see the node
+func (v Visibility) Partial() bool {
+	return v.SeenBy < v.Viewers
+}
+
+// UpdatePeerView records the UUIDs of the peers the node at addr currently
+// sees, replacing its previous view. Views of unknown nodes are ignored, and
+// a view is forgotten once its node is reaped
+func (m *Monitor) UpdatePeerView(addr string, sees [][16]byte) {
+	addr = CanonicalAddr(addr)
+	if _, ok := m.GetNodeInfo(addr); !ok {
+		return
+	}
+	view := make(map[[16]byte]struct{}, len(sees))
+	for _, uuid := range sees {
+		view[uuid] = struct{}{}
+	}
+	m.viewMu.Lock()
+	m.views[addr] = view
+	m.viewMu.Unlock()
+}
+
+// PeerUUIDs returns the UUIDs of the peers this monitor sees directly, for
+// sharing as a peer view. Probed targets, federated nodes and this node
+// itself (see SetSelf) are left out
+func (m *Monitor) PeerUUIDs() [][16]byte {
+	var uuids [][16]byte
+	m.ForEachNode(func(_ string, info NodeInfo) bool {
+		if info.UUID != [16]byte{} && info.UUID != m.self && !info.Probed && info.FederatedFrom == "" {
+			uuids = append(uuids, info.UUID)
+		}
+		return true
+	})
+	return uuids
+}
+
+// Visibility returns, for each node with a known UUID, how many of the
+// other nodes sharing a peer view see it. Nodes are keyed by address and
+// left out when no other node has shared a view
+func (m *Monitor) Visibility() map[string]Visibility {
+	nodes := m.GetNodes()
+
+	m.viewMu.Lock()
+	defer m.viewMu.Unlock()
+	for viewer := range m.views {
+		if _, ok := nodes[viewer]; !ok {
+			delete(m.views, viewer)
+		}
+	}
+	if len(m.views) == 0 {
+		return nil
+	}
+
+	seenBy := make(map[[16]byte]int)
+	for _, view := range m.views {
+		for uuid := range view {
+			seenBy[uuid]++
+		}
+	}
+
+	result := make(map[string]Visibility)
+	for addr, info := range nodes {
+		if info.UUID == [16]byte{} {
+			continue
+		}
+		v := Visibility{SeenBy: seenBy[info.UUID], Viewers: len(m.views)}
+		if own, ok := m.views[addr]; ok {
+			// A node does not count as seeing itself
+			v.Viewers--
+			if _, ok := own[info.UUID]; ok {
+				v.SeenBy--
+			}
+		}
+		if v.Viewers > 0 {
+			result[addr] = v
+		}
+	}
+	return result
+}
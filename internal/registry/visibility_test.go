@@ -0,0 +1,60 @@
+package registry
+
+import "testing"
+
+func TestMonitorVisibility(t *testing.T) {
+	m := NewMonitor()
+	uuids := map[string][16]byte{
+		"10.0.0.1:9999": {1},
+		"10.0.0.2:9999": {2},
+		"10.0.0.3:9999": {3},
+		"10.0.0.4:9999": {4},
+	}
+	for addr, uuid := range uuids {
+		m.UpdateWithHeartbeat(addr, uuid, 0, 1)
+	}
+	if vis := m.Visibility(); vis != nil {
+		t.Errorf("Visibility() without views = %v, want nil", vis)
+	}
+
+	// Node 4 is only seen by node 1; views may include the viewer itself
+	m.UpdatePeerView("10.0.0.1:9999", [][16]byte{{1}, {2}, {3}, {4}})
+	m.UpdatePeerView("10.0.0.2:9999", [][16]byte{{1}, {3}})
+	m.UpdatePeerView("10.0.0.3:9999", [][16]byte{{1}, {2}})
+	m.UpdatePeerView("10.0.0.9:9999", [][16]byte{{4}}) // Unknown viewer
+
+	want := map[string]Visibility{
+		"10.0.0.1:9999": {SeenBy: 2, Viewers: 2},
+		"10.0.0.2:9999": {SeenBy: 2, Viewers: 2},
+		"10.0.0.3:9999": {SeenBy: 2, Viewers: 2},
+		"10.0.0.4:9999": {SeenBy: 1, Viewers: 3},
+	}
+	vis := m.Visibility()
+	for addr, w := range want {
+		if vis[addr] != w {
+			t.Errorf("Visibility()[%s] = %+v, want %+v", addr, vis[addr], w)
+		}
+	}
+	if !vis["10.0.0.4:9999"].Partial() || vis["10.0.0.1:9999"].Partial() {
+		t.Errorf("Partial() wrong: %v", vis)
+	}
+
+	// A reaped viewer's view is forgotten
+	shard, _ := m.getShard("10.0.0.1:9999")
+	shard.mu.Lock()
+	shard.remove("10.0.0.1:9999")
+	shard.mu.Unlock()
+	if got := m.Visibility()["10.0.0.4:9999"]; got != (Visibility{SeenBy: 0, Viewers: 2}) {
+		t.Errorf("Visibility() after reaping a viewer = %+v, want 0 of 2", got)
+	}
+}
+
+func TestMonitorPeerUUIDs(t *testing.T) {
+	m := NewMonitor()
+	m.UpdateWithHeartbeat("10.0.0.1:9999", [16]byte{1}, 0, 1)
+	m.UpdateWithStatus("10.0.0.2:9999", 0, 1) // No UUID
+	m.UpdateWithProbe("10.0.0.3:80", 0, 0)
+	if got := m.PeerUUIDs(); len(got) != 1 || got[0] != [16]byte{1} {
+		t.Errorf("PeerUUIDs() = %v, want only {1}", got)
+	}
+}
@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"log"
+	"net"
+	"time"
+)
+
+const (
+	// listenerErrorLimit is the number of consecutive failed reads after
+	// which the listener is considered broken and re-created. Read
+	// timeouts on an idle socket do not count
+	listenerErrorLimit = 10
+
+	// listenerRestartAttempts bounds the attempts to re-create a broken
+	// listener before the node gives up and stays deaf until stopped
+	listenerRestartAttempts = 5
+
+	// listenerRestartBackoff is the delay before the first attempt, doubled
+	// after each failure
+	listenerRestartBackoff = 100 * time.Millisecond
+)
+
+// socket returns the current UDP connection
+func (u *UDPNode) socket() *net.UDPConn {
+	u.connMu.RLock()
+	defer u.connMu.RUnlock()
+	return u.conn
+}
+
+// restartListener closes the broken socket and binds a new one to the same
+// port, e.g. after its interface went down and up. The monitor, peers and
+// workers are kept. It returns false if every attempt failed or the node
+// was stopped meanwhile
+func (u *UDPNode) restartListener() bool {
+	// Sends fail fast on the closed socket until it is replaced
+	u.socket().Close()
+	backoff := listenerRestartBackoff
+	for attempt := 1; attempt <= listenerRestartAttempts; attempt++ {
+		select {
+		case <-u.stopChan:
+			return false
+		case <-time.After(backoff):
+		}
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: int(u.listenPort)})
+		if err != nil {
+			log.Printf("UDP listener broken; restart attempt %d/%d failed: %v", attempt, listenerRestartAttempts, err)
+			backoff *= 2
+			continue
+		}
+		u.connMu.Lock()
+		u.conn = conn
+		u.connMu.Unlock()
+		u.remark()
+		u.rebuffer()
+		u.listenerRestarts.Add(1)
+		log.Printf("UDP listener broken; restarted on %s (attempt %d/%d)", conn.LocalAddr(), attempt, listenerRestartAttempts)
+		return true
+	}
+	log.Printf("UDP listener broken; giving up after %d restart attempts, no heartbeats will be received", listenerRestartAttempts)
+	return false
+}
+
+// stopWorkers shuts the worker pool down once the receive loop exits
+func (u *UDPNode) stopWorkers() {
+	// The scaler must not start workers once the channel is closed
+	u.scalerWg.Wait()
+	// Close packet channel to signal workers to stop
+	close(u.packetChan)
+	// Wait for all workers to finish
+	u.workerWg.Wait()
+}
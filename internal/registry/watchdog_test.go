@@ -0,0 +1,80 @@
+package registry
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/protocol"
+)
+
+func TestUDPNodeListenerRestart(t *testing.T) {
+	monitor := NewMonitor()
+	node, err := NewUDPNode(0, [16]byte{1}, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	node.ioTimeout = 50 * time.Millisecond
+	go node.Start()
+	defer node.Stop()
+
+	// Break the socket under the receive loop, as a dead interface would
+	node.Conn().Close()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for node.Stats().ListenerRestarts == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := node.Stats().ListenerRestarts; got != 1 {
+		t.Fatalf("ListenerRestarts = %d, want 1", got)
+	}
+
+	// The same port receives heartbeats again
+	sender, err := net.Dial("udp", net.JoinHostPort("127.0.0.1", strconv.Itoa(node.Port())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	pkt := protocol.NewPacket([16]byte{2}, 0)
+	pkt.ListenPort = 10001
+	data, err := pkt.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for monitor.GetNodeCount() == 0 && time.Now().Before(deadline) {
+		sender.Write(data)
+		time.Sleep(20 * time.Millisecond)
+	}
+	if monitor.GetNodeCount() != 1 {
+		t.Errorf("no heartbeat received after the restart (stats: %+v)", node.Stats())
+	}
+}
+
+func TestUDPNodeListenerRestartGivesUp(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{1}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	node.Conn().Close()
+	// Take the port so every restart attempt fails
+	squatter, err := net.ListenUDP("udp", &net.UDPAddr{Port: node.Port()})
+	if err != nil {
+		t.Skipf("cannot take the port for the test: %v", err)
+	}
+	defer squatter.Close()
+
+	done := make(chan bool)
+	go func() { done <- node.restartListener() }()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("restartListener() = true with the port taken")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("restartListener() did not give up")
+	}
+	if got := node.Stats().ListenerRestarts; got != 0 {
+		t.Errorf("ListenerRestarts = %d, want 0", got)
+	}
+}
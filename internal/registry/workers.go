@@ -0,0 +1,119 @@
+package registry
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// minWorkers keeps packet processing parallel even on a single-CPU limit
+const minWorkers = 2
+
+// DefaultWorkerCount returns the packet worker count for this process. It
+// follows GOMAXPROCS rather than runtime.NumCPU, and is further capped by a
+// cgroup CPU quota, since in a container NumCPU reports the host's cores
+func DefaultWorkerCount() int {
+	n := runtime.GOMAXPROCS(0)
+	if limit := cgroupCPULimit(); limit > 0 && limit < n {
+		n = limit
+	}
+	if n < minWorkers {
+		n = minWorkers
+	}
+	return n
+}
+
+// cgroupCPULimit returns the CPU quota of the process's cgroup rounded up to
+// whole CPUs, or 0 if there is no quota (or no cgroup filesystem)
+func cgroupCPULimit() int {
+	// cgroup v2: "<quota> <period>" or "max <period>"
+	if data, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 {
+			return cpuQuota(fields[0], fields[1])
+		}
+		return 0
+	}
+	// cgroup v1: quota of -1 means unlimited
+	quota, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	if err != nil {
+		return 0
+	}
+	period, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0
+	}
+	return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// cpuQuota converts a CFS quota and period in microseconds to whole CPUs
+func cpuQuota(quota, period string) int {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0 // "max" or -1
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return int(math.Ceil(q / p))
+}
+
+const (
+	// workerScaleInterval is how often the adaptive pool checks the queue
+	workerScaleInterval = 100 * time.Millisecond
+
+	// workerIdleChecks is how many consecutive checks must find the queue
+	// empty before a worker is retired, so a short lull between bursts
+	// keeps the pool warm
+	workerIdleChecks = 50
+)
+
+// scaleWorkers adapts the worker pool to the packet queue until Stop
+func (u *UDPNode) scaleWorkers() {
+	defer u.scalerWg.Done()
+	ticker := time.NewTicker(workerScaleInterval)
+	defer ticker.Stop()
+
+	idle := 0
+	for {
+		select {
+		case <-u.stopChan:
+			return
+		case <-ticker.C:
+			idle = u.adjustWorkers(idle)
+		}
+	}
+}
+
+// adjustWorkers adds a worker, up to maxWorkers, when the queue is at least
+// three quarters full or a packet was dropped since the last check, and
+// retires one, down to workerCount, once idle checks in a row have found
+// the queue empty. Returns the updated idle count
+func (u *UDPNode) adjustWorkers(idle int) int {
+	depth := len(u.packetChan)
+	workers := int(u.workers.Load())
+	switch {
+	case u.queueOverflow.Swap(false) || depth >= cap(u.packetChan)*3/4:
+		if workers < u.maxWorkers {
+			u.startWorker()
+		}
+		return 0
+	case depth == 0:
+		idle++
+		if idle < workerIdleChecks || workers <= u.workerCount {
+			return idle
+		}
+		select {
+		case u.retire <- struct{}{}:
+			u.workers.Add(-1)
+		case <-u.stopChan:
+		}
+		return 0
+	default:
+		return 0
+	}
+}
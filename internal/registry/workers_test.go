@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCPUQuota(t *testing.T) {
+	testCases := []struct {
+		quota, period string
+		want          int
+	}{
+		{"200000", "100000", 2},
+		{"150000", "100000", 2}, // Rounded up
+		{"50000", "100000", 1},
+		{"max", "100000", 0},
+		{"-1", "100000", 0}, // cgroup v1 unlimited
+		{"100000", "0", 0},
+		{"bogus", "100000", 0},
+	}
+
+	for _, tc := range testCases {
+		if got := cpuQuota(tc.quota, tc.period); got != tc.want {
+			t.Errorf("cpuQuota(%q, %q) = %d, want %d", tc.quota, tc.period, got, tc.want)
+		}
+	}
+}
+
+func TestDefaultWorkerCount(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+
+	// Never below the minimum, even when limited to one CPU
+	if got := DefaultWorkerCount(); got != minWorkers {
+		t.Errorf("DefaultWorkerCount() with GOMAXPROCS=1 = %d, want %d", got, minWorkers)
+	}
+}
+
+func TestUDPNodeSetWorkers(t *testing.T) {
+	var nodeUUID [16]byte
+	node, err := NewUDPNode(0, nodeUUID, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+
+	node.SetWorkers(0)
+	if node.workerCount != DefaultWorkerCount() {
+		t.Errorf("SetWorkers(0) workerCount = %d, want default %d", node.workerCount, DefaultWorkerCount())
+	}
+
+	node.SetWorkers(5)
+	if node.workerCount != 5 || cap(node.packetChan) != 10 {
+		t.Errorf("SetWorkers(5) workerCount = %d, queue = %d, want 5 and 10", node.workerCount, cap(node.packetChan))
+	}
+}
+
+func TestAdjustWorkers(t *testing.T) {
+	node, err := NewUDPNode(0, [16]byte{}, NewMonitor())
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	defer node.Stop()
+	node.SetWorkers(1)
+	node.SetMaxWorkers(3)
+	node.startWorkers()
+	defer func() {
+		close(node.packetChan)
+		node.workerWg.Wait()
+	}()
+
+	waitWorkers := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for node.Stats().Workers != want && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if got := node.Stats().Workers; got != want {
+			t.Fatalf("Workers = %d, want %d", got, want)
+		}
+	}
+	waitWorkers(1)
+
+	// A dropped packet adds a worker, up to the maximum
+	for i := 0; i < 3; i++ {
+		node.queueOverflow.Store(true)
+		node.adjustWorkers(0)
+	}
+	waitWorkers(3)
+
+	// Workers are retired only after a run of idle checks
+	idle := 0
+	for i := 0; i < workerIdleChecks-1; i++ {
+		idle = node.adjustWorkers(idle)
+	}
+	waitWorkers(3)
+	idle = node.adjustWorkers(idle)
+	waitWorkers(2)
+	if idle != 0 {
+		t.Errorf("idle = %d after retiring a worker, want 0", idle)
+	}
+
+	// Never below the minimum
+	for i := 0; i < 3*workerIdleChecks; i++ {
+		idle = node.adjustWorkers(idle)
+	}
+	waitWorkers(1)
+}
@@ -0,0 +1,64 @@
+package relay
+
+import (
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the Ed25519 signature of a pushed report's body,
+// base64 encoded, made with the sending node's signing key
+const SignatureHeader = "X-Pulsecheck-Signature"
+
+// maxSignedReportAge bounds how far a signed report's timestamp may be from
+// the collector's clock, so a captured report cannot be replayed later to
+// keep a dead node alive
+const maxSignedReportAge = 5 * time.Minute
+
+// IngestAuth decides which pushed reports are merged. A report is accepted
+// with the shared Token as a bearer token, or signed by the key TrustedKeys
+// holds for its node UUID. With neither set every report is refused
+type IngestAuth struct {
+	Token       string
+	TrustedKeys map[[16]byte]ed25519.PublicKey
+}
+
+// hasToken reports whether req carries the shared token
+func (a IngestAuth) hasToken(req *http.Request) bool {
+	if a.Token == "" {
+		return false
+	}
+	given, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(given), []byte(a.Token)) == 1
+}
+
+// signedBy reports whether body carries a fresh signature by uuid's
+// trusted key
+func (a IngestAuth) signedBy(req *http.Request, body []byte, uuid [16]byte, timestamp int64) bool {
+	key, ok := a.TrustedKeys[uuid]
+	if !ok {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(req.Header.Get(SignatureHeader))
+	if err != nil || !ed25519.Verify(key, body, sig) {
+		return false
+	}
+	age := time.Since(time.Unix(0, timestamp))
+	return age < maxSignedReportAge && age > -maxSignedReportAge
+}
+
+// RequireToken serves h only to requests carrying the shared token
+func RequireToken(token string, h http.Handler) http.Handler {
+	auth := IngestAuth{Token: token}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !auth.hasToken(req) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,97 @@
+package relay
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+	"github.com/rafaelmarinho/pulsecheck/internal/telemetry"
+)
+
+func TestIngestHandlerAuth(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid := [16]byte{7}
+	auth := IngestAuth{Token: "secret", TrustedKeys: map[[16]byte]ed25519.PublicKey{uuid: pub}}
+
+	report := func(timestamp time.Time) []byte {
+		r := NewReport(uuid, 9999, 0, &telemetry.Metrics{})
+		r.Timestamp = timestamp.UnixNano()
+		body, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+	sign := func(key ed25519.PrivateKey, body []byte) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(key, body))
+	}
+
+	fresh := report(time.Now())
+	stale := report(time.Now().Add(-time.Hour))
+	tests := []struct {
+		name   string
+		body   []byte
+		header map[string]string
+		want   int
+	}{
+		{"no credentials", fresh, nil, http.StatusUnauthorized},
+		{"wrong token", fresh, map[string]string{"Authorization": "Bearer guess"}, http.StatusUnauthorized},
+		{"untrusted key", fresh, map[string]string{SignatureHeader: sign(otherKey, fresh)}, http.StatusUnauthorized},
+		{"signature of another body", fresh, map[string]string{SignatureHeader: sign(key, stale)}, http.StatusUnauthorized},
+		{"replayed signed report", stale, map[string]string{SignatureHeader: sign(key, stale)}, http.StatusUnauthorized},
+		{"token", fresh, map[string]string{"Authorization": "Bearer secret"}, http.StatusNoContent},
+		{"trusted signature", fresh, map[string]string{SignatureHeader: sign(key, fresh)}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			monitor := registry.NewMonitor()
+			handler := IngestHandler(monitor, auth)
+			req := httptest.NewRequest(http.MethodPost, IngestPath, strings.NewReader(string(tt.body)))
+			req.RemoteAddr = "10.0.0.7:41234"
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if _, ok := monitor.GetNodeInfo("10.0.0.7:9999"); ok != (tt.want == http.StatusNoContent) {
+				t.Errorf("node recorded = %v for status %d", ok, rec.Code)
+			}
+		})
+	}
+}
+
+func TestPusherSignsReports(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid := [16]byte{8}
+	monitor := registry.NewMonitor()
+	server := httptest.NewServer(IngestHandler(monitor, IngestAuth{TrustedKeys: map[[16]byte]ed25519.PublicKey{uuid: pub}}))
+	defer server.Close()
+
+	pusher := NewPusher(server.URL+IngestPath, time.Second)
+	pusher.SetAuth("", key)
+	if err := pusher.Push(NewReport(uuid, 9999, 0, &telemetry.Metrics{})); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if _, ok := monitor.GetNodeInfo("127.0.0.1:9999"); !ok {
+		t.Error("signed report was not recorded")
+	}
+}
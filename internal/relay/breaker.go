@@ -0,0 +1,124 @@
+package relay
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+)
+
+// BreakerState is the state of a delivery circuit breaker
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // Deliveries go through
+	BreakerOpen                         // Deliveries are dropped until the cooldown ends
+	BreakerHalfOpen                     // A single trial delivery tests recovery
+)
+
+// String returns the state name used in stats and logs
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerStats is a snapshot of a breaker's state and counters
+type BreakerStats struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Opened              uint64 `json:"opened"`  // Times the circuit has opened
+	Dropped             uint64 `json:"dropped"` // Deliveries skipped while open or half-open
+}
+
+// Breaker stops deliveries to a failing endpoint. After threshold
+// consecutive failures it opens and drops every delivery for the cooldown,
+// then half-opens to let a single trial through. A successful trial closes
+// it; a failed one reopens it for another cooldown. Safe for concurrent use
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	clock     clock.Clock
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trial    bool // A half-open trial is in flight
+	opened   uint64
+	dropped  uint64
+}
+
+// NewBreaker creates a closed breaker that opens after threshold consecutive
+// failures (at least 1) and stays open for cooldown
+func NewBreaker(threshold int, cooldown time.Duration, clk clock.Clock) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{threshold: threshold, cooldown: cooldown, clock: clk}
+}
+
+// Allow reports whether a delivery may be attempted now. A refused delivery
+// is counted as dropped. Every allowed delivery must be followed by Record
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.clock.Now().Sub(b.openedAt) < b.cooldown {
+			b.dropped++
+			return false
+		}
+		b.state = BreakerHalfOpen
+	case BreakerHalfOpen:
+		if b.trial {
+			b.dropped++
+			return false
+		}
+	default:
+		return true
+	}
+	b.trial = true
+	return true
+}
+
+// Record reports the outcome of an allowed delivery. It returns true if a
+// failure opened the circuit from the closed state
+func (b *Breaker) Record(err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if err == nil {
+		b.state = BreakerClosed
+		b.failures = 0
+		return false
+	}
+	b.failures++
+	wasClosed := b.state == BreakerClosed
+	if b.state == BreakerHalfOpen || (wasClosed && b.failures >= b.threshold) {
+		b.state = BreakerOpen
+		b.openedAt = b.clock.Now()
+		b.opened++
+		return wasClosed
+	}
+	return false
+}
+
+// Stats returns the current state and counters
+func (b *Breaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BreakerStats{
+		State:               b.state.String(),
+		ConsecutiveFailures: b.failures,
+		Opened:              b.opened,
+		Dropped:             b.dropped,
+	}
+}
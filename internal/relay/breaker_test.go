@@ -0,0 +1,101 @@
+package relay
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+	"github.com/rafaelmarinho/pulsecheck/internal/telemetry"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1700000000, 0))
+	b := NewBreaker(3, time.Minute, clk)
+	errDown := errors.New("connection refused")
+
+	// Failures below the threshold keep the circuit closed
+	for i := 0; i < 2; i++ {
+		if !b.Allow() {
+			t.Fatalf("Allow() = false after %d failures, want true", i)
+		}
+		if b.Record(errDown) {
+			t.Fatalf("Record() opened the circuit after %d failures", i+1)
+		}
+	}
+	if !b.Allow() || !b.Record(errDown) {
+		t.Fatal("third failure did not open the circuit")
+	}
+	if got := b.Stats(); got.State != "open" || got.Opened != 1 || got.ConsecutiveFailures != 3 {
+		t.Errorf("Stats() = %+v, want open once after 3 failures", got)
+	}
+
+	// Open: everything is dropped until the cooldown ends
+	for i := 0; i < 4; i++ {
+		if b.Allow() {
+			t.Fatal("Allow() = true while open")
+		}
+	}
+	clk.Advance(time.Minute)
+
+	// Half-open: one trial at a time; a failed trial reopens at once
+	if !b.Allow() {
+		t.Fatal("Allow() = false after the cooldown, want a trial")
+	}
+	if b.Allow() {
+		t.Error("Allow() = true while a trial is in flight")
+	}
+	if got := b.Stats().State; got != "half-open" {
+		t.Errorf("state during trial = %s, want half-open", got)
+	}
+	if b.Record(errDown) {
+		t.Error("failed trial reported as opening from closed")
+	}
+	if b.Allow() {
+		t.Error("Allow() = true after a failed trial, want reopened")
+	}
+
+	// A successful trial closes the circuit
+	clk.Advance(time.Minute)
+	if !b.Allow() {
+		t.Fatal("Allow() = false after the second cooldown")
+	}
+	b.Record(nil)
+	got := b.Stats()
+	if got.State != "closed" || got.ConsecutiveFailures != 0 || got.Opened != 2 || got.Dropped != 6 {
+		t.Errorf("Stats() = %+v, want closed, opened 2, dropped 6", got)
+	}
+}
+
+func TestPusherBreakerStopsRequests(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	clk := clock.NewFake(time.Unix(1700000000, 0))
+	p := NewPusher(server.URL, time.Second)
+	p.SetBreaker(NewBreaker(2, time.Minute, clk))
+
+	r := NewReport([16]byte{1}, 9999, 0, &telemetry.Metrics{})
+	for i := 0; i < 5; i++ {
+		p.deliver(r)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("collector received %d requests, want 2 before the circuit opened", got)
+	}
+	if got := p.BreakerStats(); got.State != "open" || got.Dropped != 3 {
+		t.Errorf("BreakerStats() = %+v, want open with 3 dropped", got)
+	}
+
+	clk.Advance(time.Minute)
+	p.deliver(r)
+	if got := requests.Load(); got != 3 {
+		t.Errorf("collector received %d requests, want a trial after the cooldown", got)
+	}
+}
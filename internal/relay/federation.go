@@ -0,0 +1,201 @@
+package relay
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+	"github.com/rafaelmarinho/pulsecheck/internal/telemetry"
+)
+
+// maxStatusSize bounds the body of a pulled status report
+const maxStatusSize = 16 << 20
+
+// federatedReport is the part of another collector's JSON status report
+// that is merged. Stale nodes are merged too; the reaper decides their fate
+type federatedReport struct {
+	Nodes map[string]federatedNode `json:"nodes"`
+	Stale map[string]federatedNode `json:"stale"`
+}
+
+// federatedNode is one node in a pulled status report
+type federatedNode struct {
+	UUID             string               `json:"uuid"`
+	StatusCode       uint8                `json:"status_code"`
+	LastSeen         time.Time            `json:"last_seen"`
+	CPUPercent       float64              `json:"cpu_percent"`
+	RAMPercent       float64              `json:"ram_percent"`
+	DiskPercent      float64              `json:"disk_percent"`
+	HasTelemetry     bool                 `json:"has_telemetry"`
+	Probed           bool                 `json:"probed"`
+	Location         *registry.Location   `json:"location"`
+	Kubernetes       *registry.Kubernetes `json:"kubernetes"`
+	NetRxBytesPerSec float64              `json:"net_rx_bytes_per_sec"`
+	NetTxBytesPerSec float64              `json:"net_tx_bytes_per_sec"`
+}
+
+// Federator pulls the status reports of other collectors and merges their
+// nodes into the local monitor, composing partial views into a global one
+type Federator struct {
+	monitor  *registry.Monitor
+	peers    []string // Status URLs
+	client   *http.Client
+	timeout  time.Duration
+	failures map[string]*telemetry.FailureTracker
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewFederator creates a federator pulling each of the status URLs
+func NewFederator(monitor *registry.Monitor, peers []string, timeout time.Duration) *Federator {
+	failures := make(map[string]*telemetry.FailureTracker, len(peers))
+	for _, peer := range peers {
+		failures[peer] = telemetry.NewFailureTracker(true)
+	}
+	return &Federator{
+		monitor:  monitor,
+		peers:    peers,
+		client:   &http.Client{Timeout: timeout},
+		timeout:  timeout,
+		failures: failures,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Peers returns the status URLs being pulled
+func (f *Federator) Peers() []string {
+	return f.peers
+}
+
+// Start pulls every peer once per interval until Stop is called
+func (f *Federator) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		f.pullAll()
+		select {
+		case <-f.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops the federator. Safe to call more than once
+func (f *Federator) Stop() {
+	f.stopOnce.Do(func() {
+		close(f.stopChan)
+	})
+}
+
+// pullAll pulls each peer in turn, logging failures
+func (f *Federator) pullAll() {
+	for _, peer := range f.peers {
+		_, err := f.Pull(peer)
+		tracker := f.failures[peer]
+		if err != nil {
+			if shouldLog, count := tracker.Failure(); shouldLog {
+				log.Printf("Failed to federate from %s (%d consecutive failures): %v", peer, count, err)
+			}
+		} else if recovered, failures := tracker.Success(); recovered {
+			log.Printf("Federating from %s recovered after %d consecutive failures", peer, failures)
+		}
+	}
+}
+
+// Pull fetches one peer's status report and merges it, returning the
+// number of nodes taken. Nodes are marked as federated from the peer's host.
+// The peer's own entry, keyed by its listening address such as "[::]:9999",
+// is re-keyed by the peer's hostname so it can't overwrite ours or another
+// peer's
+func (f *Federator) Pull(peer string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peer, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("collector returned HTTP status %d", resp.StatusCode)
+	}
+	var report federatedReport
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxStatusSize)).Decode(&report); err != nil {
+		return 0, fmt.Errorf("invalid status report: %w", err)
+	}
+
+	nodes := make([]registry.NodeInfo, 0, len(report.Nodes)+len(report.Stale))
+	for _, section := range []map[string]federatedNode{report.Nodes, report.Stale} {
+		for addr, n := range section {
+			if registry.IsUnspecifiedAddr(addr) {
+				addr = selfAddr(peer, addr)
+			}
+			nodes = append(nodes, n.nodeInfo(addr))
+		}
+	}
+	return f.monitor.MergeFederated(peerName(peer), nodes), nil
+}
+
+// nodeInfo converts a pulled node to the monitor's form
+func (n federatedNode) nodeInfo(addr string) registry.NodeInfo {
+	info := registry.NodeInfo{
+		LastSeen:         n.LastSeen,
+		Address:          addr,
+		CPUPercent:       n.CPUPercent,
+		RAMPercent:       n.RAMPercent,
+		DiskPercent:      n.DiskPercent,
+		HasTelemetry:     n.HasTelemetry,
+		StatusCode:       n.StatusCode,
+		Probed:           n.Probed,
+		NetRxBytesPerSec: n.NetRxBytesPerSec,
+		NetTxBytesPerSec: n.NetTxBytesPerSec,
+		HasNetwork:       n.NetRxBytesPerSec > 0 || n.NetTxBytesPerSec > 0,
+	}
+	if n.Location != nil {
+		info.Location = *n.Location
+	}
+	if n.Kubernetes != nil {
+		info.Kubernetes = *n.Kubernetes
+	}
+	// Peers predating the field, or not knowing the UUID, send none
+	if uuid, err := hex.DecodeString(n.UUID); err == nil && len(uuid) == len(info.UUID) {
+		copy(info.UUID[:], uuid)
+	}
+	return info
+}
+
+// peerName labels federated nodes with the peer's host, or the whole URL
+// if it has none
+func peerName(peer string) string {
+	if u, err := url.Parse(peer); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return peer
+}
+
+// selfAddr re-keys a peer's self-entry addr by the peer's hostname, keeping
+// the UDP port, e.g. "[::]:9999" from http://collector-b:8080 becomes
+// "collector-b:9999"
+func selfAddr(peer, addr string) string {
+	_, port, _ := net.SplitHostPort(addr)
+	host := peer
+	if u, err := url.Parse(peer); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,176 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+	"github.com/rafaelmarinho/pulsecheck/internal/display"
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+func TestFederatorPull(t *testing.T) {
+	start := time.Now()
+	remoteClock := clock.NewFake(start)
+	remote := registry.NewMonitor()
+	remote.SetClock(remoteClock)
+	remote.UpdateWithTelemetry("10.0.1.1:9999", 10, 20, 30, 1) // Only seen remotely
+	remote.UpdateWithStatus("10.0.0.1:9999", 0, 0)             // Seen fresher locally
+	remoteClock.Advance(time.Second)
+	remote.UpdateWithStatus("10.0.0.2:9999", 2, 0) // Seen fresher remotely
+
+	local := registry.NewMonitor()
+	localClock := clock.NewFake(start.Add(500 * time.Millisecond))
+	local.SetClock(localClock)
+	local.UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	local.UpdateWithStatus("10.0.0.2:9999", 0, 0)
+
+	server := httptest.NewServer(display.NewReporter(remote, true).StatusHandler())
+	defer server.Close()
+
+	f := NewFederator(local, []string{server.URL + display.StatusPath}, time.Second)
+	n, err := f.Pull(server.URL + display.StatusPath)
+	if err != nil {
+		t.Fatalf("Pull() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Pull() took %d nodes, want 2", n)
+	}
+
+	source := strings.TrimPrefix(server.URL, "http://")
+	if info, ok := local.GetNodeInfo("10.0.1.1:9999"); !ok || info.FederatedFrom != source || !info.HasTelemetry || info.CPUPercent != 10 || info.StatusCode != 1 {
+		t.Errorf("remote-only node = %+v (found %v)", info, ok)
+	}
+	if info, _ := local.GetNodeInfo("10.0.0.1:9999"); info.FederatedFrom != "" {
+		t.Errorf("locally fresher node was replaced: %+v", info)
+	}
+	if info, _ := local.GetNodeInfo("10.0.0.2:9999"); info.FederatedFrom != source || info.StatusCode != 2 {
+		t.Errorf("remotely fresher node = %+v, want federated CRITICAL", info)
+	}
+
+	// A direct heartbeat takes the node back
+	localClock.Advance(time.Second)
+	local.UpdateWithStatus("10.0.0.2:9999", 0, 0)
+	if info, _ := local.GetNodeInfo("10.0.0.2:9999"); info.FederatedFrom != "" {
+		t.Errorf("node still federated after a direct heartbeat: %+v", info)
+	}
+}
+
+func TestFederatorPullTwoCollectors(t *testing.T) {
+	// Every collector reports itself under its listening address
+	local := registry.NewMonitor()
+	local.UpdateWithStatus("[::]:9999", 0, 0)
+
+	var urls []string
+	for _, c := range []struct {
+		self string
+		node string
+	}{
+		{"[::]:9998", "10.0.1.1:9999"},
+		{"0.0.0.0:9997", "10.0.2.1:9999"},
+	} {
+		remote := registry.NewMonitor()
+		remote.UpdateWithStatus(c.self, 2, 0)
+		remote.UpdateWithStatus(c.node, 1, 0)
+		server := httptest.NewServer(display.NewReporter(remote, true).StatusHandler())
+		defer server.Close()
+		urls = append(urls, server.URL+display.StatusPath)
+	}
+
+	f := NewFederator(local, urls, time.Second)
+	for _, u := range urls {
+		if n, err := f.Pull(u); err != nil || n != 2 {
+			t.Fatalf("Pull(%s) = %d, %v; want 2 nodes", u, n, err)
+		}
+	}
+
+	if info, _ := local.GetNodeInfo("[::]:9999"); info.FederatedFrom != "" || info.StatusCode != 0 {
+		t.Errorf("local self-entry was overwritten: %+v", info)
+	}
+	for _, addr := range []string{"127.0.0.1:9998", "127.0.0.1:9997"} {
+		if info, ok := local.GetNodeInfo(addr); !ok || info.StatusCode != 2 {
+			t.Errorf("peer self-entry %s = %+v (found %v), want CRITICAL", addr, info, ok)
+		}
+	}
+	for _, addr := range []string{"10.0.1.1:9999", "10.0.2.1:9999"} {
+		if _, ok := local.GetNodeInfo(addr); !ok {
+			t.Errorf("federated node %s missing", addr)
+		}
+	}
+	if n := local.GetNodeCount(); n != 5 {
+		t.Errorf("GetNodeCount() = %d, want 5", n)
+	}
+}
+
+func TestFederatorPullDeduplicatesByUUID(t *testing.T) {
+	// A primary/standby pair that also heartbeat each other
+	var uuidA, uuidB [16]byte
+	copy(uuidA[:], "collector-a")
+	copy(uuidB[:], "collector-b")
+	start := time.Now()
+
+	remoteClock := clock.NewFake(start.Add(time.Second))
+	remote := registry.NewMonitor()
+	remote.SetClock(remoteClock)
+	remote.SetSelf(uuidB)
+	remote.UpdateLocal("[::]:9999", registry.LocalUpdate{UUID: uuidB, StatusCode: 1})
+	remote.UpdateWithHeartbeat("10.0.0.1:9999", uuidA, 0, 0)
+
+	local := registry.NewMonitor()
+	local.SetClock(clock.NewFake(start))
+	local.SetSelf(uuidA)
+	local.UpdateLocal("[::]:9999", registry.LocalUpdate{UUID: uuidA})
+	local.UpdateWithHeartbeat("10.0.0.2:9999", uuidB, 0, 0)
+
+	server := httptest.NewServer(display.NewReporter(remote, true).StatusHandler())
+	defer server.Close()
+	f := NewFederator(local, []string{server.URL + display.StatusPath}, time.Second)
+	if n, err := f.Pull(server.URL + display.StatusPath); err != nil || n != 1 {
+		t.Fatalf("Pull() = %d, %v; want 1 node", n, err)
+	}
+
+	// The peer's self-entry folds into the node heard directly, and our own
+	// entry in its view is not merged back under our IP
+	if info, _ := local.GetNodeInfo("10.0.0.2:9999"); info.UUID != uuidB || info.StatusCode != 1 {
+		t.Errorf("peer collector = %+v, want its fresher self-entry", info)
+	}
+	if _, ok := local.GetNodeInfo("10.0.0.1:9999"); ok {
+		t.Error("own entry was merged back from the peer's view")
+	}
+	if n := local.GetNodeCount(); n != 2 {
+		t.Errorf("GetNodeCount() = %d, want 2", n)
+	}
+}
+
+func TestMergeFederatedSkipsUnspecified(t *testing.T) {
+	local := registry.NewMonitor()
+	local.UpdateWithStatus("[::]:9999", 0, 0)
+	n := local.MergeFederated("peer", []registry.NodeInfo{{Address: "[::]:9999", LastSeen: time.Now().Add(time.Hour), StatusCode: 2}})
+	if n != 0 {
+		t.Errorf("MergeFederated() took %d nodes, want 0", n)
+	}
+	if info, _ := local.GetNodeInfo("[::]:9999"); info.FederatedFrom != "" {
+		t.Errorf("local self-entry was overwritten: %+v", info)
+	}
+}
+
+func TestFederatorPullError(t *testing.T) {
+	f := NewFederator(registry.NewMonitor(), nil, time.Second)
+
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	if _, err := f.Pull(notFound.URL + display.StatusPath); err == nil {
+		t.Error("Pull() of a 404 should return error")
+	}
+
+	garbage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+	defer garbage.Close()
+	if _, err := f.Pull(garbage.URL + display.StatusPath); err == nil {
+		t.Error("Pull() of a non-JSON body should return error")
+	}
+}
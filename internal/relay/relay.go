@@ -0,0 +1,302 @@
+// Package relay pushes node reports to a central collector over HTTP, for
+// networks where UDP between hosts is blocked but outbound HTTP(S) works
+package relay
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+	"github.com/rafaelmarinho/pulsecheck/internal/telemetry"
+)
+
+// IngestPath is the collector endpoint that accepts pushed reports
+const IngestPath = "/ingest"
+
+// maxReportSize bounds the body of a pushed report, leaving room for a
+// peer view of some 30,000 UUIDs
+const maxReportSize = 1 << 20
+
+// MaxPeerView is the most peers a report lists in Sees. It keeps a report
+// with a view under the 4KB body limit of collectors older than peer views
+const MaxPeerView = 64
+
+// Report is the JSON body a node pushes to the collector. It carries the
+// same fields as a heartbeat packet plus the node's telemetry
+type Report struct {
+	NodeUUID    string  `json:"node_uuid"`             // 32 hex digits
+	ListenPort  uint16  `json:"listen_port,omitempty"` // Keys the node as it would be keyed over UDP
+	Timestamp   int64   `json:"timestamp"`             // Unix nanoseconds
+	StatusCode  uint8   `json:"status_code"`
+	CPUPercent  float64 `json:"cpu_percent"`
+	RAMPercent  float64 `json:"ram_percent"`
+	DiskPercent float64 `json:"disk_percent"`
+
+	Location   *registry.Location   `json:"location,omitempty"`   // Sender's location, if configured
+	Kubernetes *registry.Kubernetes `json:"kubernetes,omitempty"` // Sender's pod, if run in Kubernetes
+
+	// UUIDs of the peers the sender currently sees, as 32 hex digits, so
+	// the collector can count how many peers see each node. nil if the
+	// sender does not share its view
+	Sees []string `json:"sees"`
+}
+
+// NewReport builds a report for the local node
+func NewReport(nodeUUID [16]byte, listenPort uint16, statusCode uint8, metrics *telemetry.Metrics) Report {
+	return Report{
+		NodeUUID:    hex.EncodeToString(nodeUUID[:]),
+		ListenPort:  listenPort,
+		Timestamp:   time.Now().UnixNano(),
+		StatusCode:  statusCode,
+		CPUPercent:  metrics.CPUPercent,
+		RAMPercent:  metrics.RAMPercent,
+		DiskPercent: metrics.DiskPercent,
+	}
+}
+
+// SetPeerView shares the UUIDs of the peers the node currently sees. A
+// view of more than MaxPeerView peers is not shared, since a truncated one
+// would count the rest as unseen, and false is returned
+func (r *Report) SetPeerView(uuids [][16]byte) bool {
+	if len(uuids) > MaxPeerView {
+		r.Sees = nil
+		return false
+	}
+	r.Sees = make([]string, len(uuids))
+	for i, uuid := range uuids {
+		r.Sees[i] = hex.EncodeToString(uuid[:])
+	}
+	return true
+}
+
+// Pusher posts the most recent report to a collector. Reports offered while
+// a push is in flight replace any older unsent one, so a slow collector
+// never delays heartbeats or builds a backlog
+type Pusher struct {
+	url      string
+	timeout  time.Duration
+	client   *http.Client
+	latest   chan Report // Holds at most the newest unsent report
+	failures *telemetry.FailureTracker
+	breaker  *Breaker           // nil pushes every report
+	token    string             // Sent as a bearer token, see SetAuth
+	key      ed25519.PrivateKey // Signs each report, see SetAuth
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewPusher creates a pusher for the collector ingest URL
+func NewPusher(url string, timeout time.Duration) *Pusher {
+	return &Pusher{
+		url:      url,
+		timeout:  timeout,
+		client:   &http.Client{Timeout: timeout},
+		latest:   make(chan Report, 1),
+		failures: telemetry.NewFailureTracker(true),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Offer queues a report for pushing without blocking
+func (p *Pusher) Offer(r Report) {
+	for {
+		select {
+		case p.latest <- r:
+			return
+		default:
+		}
+		// Discard the stale report and retry
+		select {
+		case <-p.latest:
+		default:
+		}
+	}
+}
+
+// Start pushes offered reports until Stop is called
+func (p *Pusher) Start() {
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case r := <-p.latest:
+			p.deliver(r)
+		}
+	}
+}
+
+// SetBreaker stops pushes to a failing collector while the breaker is open
+// Must be called before Start
+func (p *Pusher) SetBreaker(b *Breaker) {
+	p.breaker = b
+}
+
+// SetAuth sends token as a bearer token with every push and signs each
+// report with key, for a collector checking IngestAuth. Either may be
+// empty. Must be called before Start
+func (p *Pusher) SetAuth(token string, key ed25519.PrivateKey) {
+	p.token = token
+	p.key = key
+}
+
+// BreakerStats returns the breaker state, or a closed state if none is set
+func (p *Pusher) BreakerStats() BreakerStats {
+	if p.breaker == nil {
+		return BreakerStats{State: BreakerClosed.String()}
+	}
+	return p.breaker.Stats()
+}
+
+// deliver pushes one report unless the breaker is open, logging failures
+func (p *Pusher) deliver(r Report) {
+	if p.breaker != nil && !p.breaker.Allow() {
+		return
+	}
+	err := p.Push(r)
+	if p.breaker != nil && p.breaker.Record(err) {
+		log.Printf("Pushing to %s failed %d times in a row; pausing pushes for %v", p.url, p.breaker.threshold, p.breaker.cooldown)
+	}
+	if err != nil {
+		if shouldLog, count := p.failures.Failure(); shouldLog {
+			log.Printf("Failed to push report to %s (%d consecutive failures): %v", p.url, count, err)
+		}
+	} else if recovered, failures := p.failures.Success(); recovered {
+		log.Printf("Pushing to %s recovered after %d consecutive failures", p.url, failures)
+	}
+}
+
+// Stop stops the pusher. Safe to call more than once
+func (p *Pusher) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopChan)
+	})
+}
+
+// Push posts a single report to the collector
+func (p *Pusher) Push(r Report) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	if p.key != nil {
+		req.Header.Set(SignatureHeader, base64.StdEncoding.EncodeToString(ed25519.Sign(p.key, body)))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector returned HTTP status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// IngestHandler accepts pushed reports and records them in the monitor as if
+// the heartbeat had arrived over UDP from the sender's address. Reports
+// that auth does not accept are refused before anything is recorded
+func IngestHandler(monitor *registry.Monitor, auth IngestAuth) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxReportSize))
+		if err != nil {
+			http.Error(w, "invalid report: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		var r Report
+		if err := json.Unmarshal(body, &r); err != nil {
+			http.Error(w, "invalid report: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		uuid, err := parseUUID(r.NodeUUID)
+		if err != nil {
+			http.Error(w, "invalid report: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !auth.hasToken(req) && !auth.signedBy(req, body, uuid, r.Timestamp) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var sees [][16]byte
+		for _, s := range r.Sees {
+			peer, err := parseUUID(s)
+			if err != nil {
+				http.Error(w, "invalid report: sees: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			sees = append(sees, peer)
+		}
+		addr, err := senderAddr(req.RemoteAddr, r.ListenPort)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		monitor.UpdateWithReport(addr, uuid, r.StatusCode, r.Timestamp, r.CPUPercent, r.RAMPercent, r.DiskPercent)
+		if r.Location != nil {
+			monitor.UpdateLocation(addr, *r.Location)
+		}
+		if r.Kubernetes != nil {
+			monitor.UpdateKubernetes(addr, *r.Kubernetes)
+		}
+		if r.Sees != nil {
+			monitor.UpdatePeerView(addr, sees)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// senderAddr combines the request's source IP with the advertised listen
+// port, matching the key a UDP heartbeat from the same node would use
+func senderAddr(remoteAddr string, listenPort uint16) (string, error) {
+	host, port, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return "", fmt.Errorf("invalid remote address %q", remoteAddr)
+	}
+	if listenPort != 0 {
+		port = strconv.Itoa(int(listenPort))
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// parseUUID parses a node UUID written as 32 hex digits
+func parseUUID(s string) ([16]byte, error) {
+	var uuid [16]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(uuid) {
+		return uuid, errors.New("node_uuid must be 32 hex digits")
+	}
+	copy(uuid[:], b)
+	return uuid, nil
+}
@@ -0,0 +1,223 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+	"github.com/rafaelmarinho/pulsecheck/internal/telemetry"
+)
+
+// testAuth accepts reports carrying a shared token
+var testAuth = IngestAuth{Token: "secret"}
+
+func TestIngestHandler(t *testing.T) {
+	monitor := registry.NewMonitor()
+	handler := IngestHandler(monitor, testAuth)
+
+	var uuid [16]byte
+	copy(uuid[:], "pushed-node")
+	metrics := &telemetry.Metrics{CPUPercent: 12.5, RAMPercent: 40, DiskPercent: 70}
+	body := `{"node_uuid":"` + NewReport(uuid, 9999, 1, metrics).NodeUUID + `","listen_port":9999,"timestamp":1,"status_code":1,"cpu_percent":12.5,"ram_percent":40,"disk_percent":70}`
+
+	req := httptest.NewRequest(http.MethodPost, IngestPath, strings.NewReader(body))
+	req.RemoteAddr = "10.0.0.7:41234"
+	req.Header.Set("Authorization", "Bearer "+testAuth.Token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("ingest status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body)
+	}
+
+	// Keyed by source IP and listen port, like a UDP heartbeat
+	info, ok := monitor.GetNodeInfo("10.0.0.7:9999")
+	if !ok {
+		t.Fatalf("pushed report not recorded; nodes = %v", monitor.GetNodes())
+	}
+	if info.UUID != uuid || info.StatusCode != 1 || !info.HasTelemetry || info.CPUPercent != 12.5 || info.DiskPercent != 70 {
+		t.Errorf("recorded node = %+v", info)
+	}
+	if !info.Location.IsZero() {
+		t.Errorf("Location = %+v without one in the report", info.Location)
+	}
+
+	body = strings.Replace(body, `"disk_percent":70`, `"disk_percent":70,"location":{"datacenter":"fra1","lat":50.11,"lon":8.68}`, 1)
+	req = httptest.NewRequest(http.MethodPost, IngestPath, strings.NewReader(body))
+	req.RemoteAddr = "10.0.0.7:41234"
+	req.Header.Set("Authorization", "Bearer "+testAuth.Token)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	info, _ = monitor.GetNodeInfo("10.0.0.7:9999")
+	if want := (registry.Location{Datacenter: "fra1", Latitude: 50.11, Longitude: 8.68, HasCoordinates: true}); info.Location != want {
+		t.Errorf("Location = %+v, want %+v", info.Location, want)
+	}
+	if !info.Kubernetes.IsZero() {
+		t.Errorf("Kubernetes = %+v without one in the report", info.Kubernetes)
+	}
+
+	body = strings.Replace(body, `"disk_percent":70`, `"disk_percent":70,"kubernetes":{"pod":"web-0","namespace":"shop","node":"worker-2"}`, 1)
+	req = httptest.NewRequest(http.MethodPost, IngestPath, strings.NewReader(body))
+	req.RemoteAddr = "10.0.0.7:41234"
+	req.Header.Set("Authorization", "Bearer "+testAuth.Token)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	info, _ = monitor.GetNodeInfo("10.0.0.7:9999")
+	if want := (registry.Kubernetes{Pod: "web-0", Namespace: "shop", Node: "worker-2"}); info.Kubernetes != want {
+		t.Errorf("Kubernetes = %+v, want %+v", info.Kubernetes, want)
+	}
+}
+
+func TestReportPeerViewCap(t *testing.T) {
+	view := make([][16]byte, MaxPeerView+1)
+	for i := range view {
+		view[i] = [16]byte{byte(i), 0xff}
+	}
+	loc := registry.Location{Datacenter: "eu-west-1a", Latitude: 53.35, Longitude: -6.26, HasCoordinates: true}
+	k := registry.Kubernetes{Pod: "api-7d9f8c6b5-x2x9z", Namespace: "production", Node: "ip-10-0-0-1.eu-west-1.compute.internal"}
+	r := NewReport([16]byte{1}, 9999, 0, &telemetry.Metrics{CPUPercent: 12.345678, RAMPercent: 45.678901, DiskPercent: 78.901234})
+	r.Location, r.Kubernetes = &loc, &k
+
+	// A full view still fits the 4KB limit of older collectors
+	if !r.SetPeerView(view[:MaxPeerView]) {
+		t.Fatalf("SetPeerView(%d peers) = false", MaxPeerView)
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) > 4096 {
+		t.Errorf("report with a full view = %d bytes, want at most 4096", len(body))
+	}
+
+	if r.SetPeerView(view) || r.Sees != nil {
+		t.Errorf("SetPeerView(%d peers) shared %d, want none", len(view), len(r.Sees))
+	}
+}
+
+func TestIngestHandlerPeerView(t *testing.T) {
+	monitor := registry.NewMonitor()
+	handler := IngestHandler(monitor, testAuth)
+	a, b, c := [16]byte{0xa}, [16]byte{0xb}, [16]byte{0xc}
+
+	push := func(remote string, uuid [16]byte, sees [][16]byte) {
+		t.Helper()
+		r := NewReport(uuid, 9999, 0, &telemetry.Metrics{})
+		if sees != nil {
+			r.SetPeerView(sees)
+		}
+		body, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, IngestPath, bytes.NewReader(body))
+		req.RemoteAddr = remote
+		req.Header.Set("Authorization", "Bearer "+testAuth.Token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("ingest status = %d: %s", rec.Code, rec.Body)
+		}
+	}
+	push("10.0.0.1:40000", a, [][16]byte{b, c})
+	push("10.0.0.2:40000", b, [][16]byte{a})
+	push("10.0.0.3:40000", c, nil) // Does not share its view
+
+	vis := monitor.Visibility()
+	if got, want := vis["10.0.0.3:9999"], (registry.Visibility{SeenBy: 1, Viewers: 2}); got != want {
+		t.Errorf("Visibility of c = %+v, want %+v", got, want)
+	}
+	if got, want := vis["10.0.0.1:9999"], (registry.Visibility{SeenBy: 1, Viewers: 1}); got != want {
+		t.Errorf("Visibility of a = %+v, want %+v", got, want)
+	}
+}
+
+func TestIngestHandlerRejects(t *testing.T) {
+	handler := IngestHandler(registry.NewMonitor(), testAuth)
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"malformed JSON", http.MethodPost, "{", http.StatusBadRequest},
+		{"bad UUID", http.MethodPost, `{"node_uuid":"xyz"}`, http.StatusBadRequest},
+		{"bad peer UUID", http.MethodPost, `{"node_uuid":"00000000000000000000000000000000","sees":["xyz"]}`, http.StatusBadRequest},
+		{"latitude out of range", http.MethodPost, `{"node_uuid":"00000000000000000000000000000000","location":{"lat":91,"lon":0}}`, http.StatusBadRequest},
+		{"oversized", http.MethodPost, `{"node_uuid":"` + strings.Repeat("a", maxReportSize) + `"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, IngestPath, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+testAuth.Token)
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestPusher(t *testing.T) {
+	monitor := registry.NewMonitor()
+	server := httptest.NewServer(IngestHandler(monitor, testAuth))
+	defer server.Close()
+
+	var uuid [16]byte
+	copy(uuid[:], "pusher-node")
+	pusher := NewPusher(server.URL+IngestPath, time.Second)
+	pusher.SetAuth(testAuth.Token, nil)
+	go pusher.Start()
+	defer pusher.Stop()
+
+	pusher.Offer(NewReport(uuid, 9999, 2, &telemetry.Metrics{CPUPercent: 95}))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for monitor.GetNodeCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	info, ok := monitor.GetNodeInfo("127.0.0.1:9999")
+	if !ok || info.UUID != uuid || info.StatusCode != 2 || info.CPUPercent != 95 {
+		t.Errorf("pushed node = %+v (found=%v), nodes = %v", info, ok, monitor.GetNodes())
+	}
+}
+
+func TestPusherErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	pusher := NewPusher(server.URL, time.Second)
+	if err := pusher.Push(Report{}); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Push() error = %v, want HTTP 503 error", err)
+	}
+}
+
+func TestPusherOfferKeepsLatest(t *testing.T) {
+	// Not started, so offers pile up in the single slot
+	pusher := NewPusher("http://127.0.0.1:0", time.Second)
+	for i := int64(1); i <= 3; i++ {
+		pusher.Offer(Report{Timestamp: i})
+	}
+	if r := <-pusher.latest; r.Timestamp != 3 {
+		t.Errorf("queued report timestamp = %d, want the latest (3)", r.Timestamp)
+	}
+	if len(pusher.latest) != 0 {
+		t.Error("only one report should be queued")
+	}
+}
+
+func TestPusherFederatorStopTwice(t *testing.T) {
+	p := NewPusher("http://127.0.0.1:1/ingest", time.Second)
+	p.Stop()
+	p.Stop()
+	f := NewFederator(registry.NewMonitor(), nil, time.Second)
+	f.Stop()
+	f.Stop()
+}
@@ -0,0 +1,279 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Segment encodings, the first byte of a segment's value. The rest is the
+// segment's records as JSON Lines, gzipped for segmentGzip
+const (
+	segmentPlain byte = iota
+	segmentGzip
+)
+
+// segment is a closed part of the history, keyed in segmentsBucket by the
+// time it was closed
+type segment struct {
+	key        []byte
+	closed     time.Time // Newest record is no later than this
+	compressed bool
+}
+
+// ParseRetention parses a retention window as a Go duration or a whole
+// number of days, e.g. "36h" or "7d". An empty string is 0
+func ParseRetention(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid retention %q: %w", s, err)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("invalid retention %q: %w", s, err)
+		}
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid retention %q: must not be negative", s)
+	}
+	return d, nil
+}
+
+// SetSegments closes the open records into a segment once the oldest is
+// older than every, and has a background goroutine gzip closed segments and
+// delete those closed more than retention ago (0 keeps them). Query reads
+// the segments transparently. A zero every keeps every record open and
+// indexed. Must be called before Start
+func (s *Store) SetSegments(every, retention time.Duration) {
+	s.segmentEvery = every
+	s.retention = retention
+	if every > 0 {
+		s.segmentStart = s.oldestOpen()
+	}
+}
+
+// oldestOpen returns the time of the oldest open record, or zero if there
+// is none
+func (s *Store) oldestOpen() time.Time {
+	var oldest time.Time
+	s.db.View(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(recordsBucket).Cursor().First(); k != nil {
+			oldest = keyTime(k)
+		}
+		return nil
+	})
+	return oldest
+}
+
+// maybeRotate closes the open records into a segment once it is due. Only
+// the writer goroutine calls it
+func (s *Store) maybeRotate() {
+	if s.segmentEvery <= 0 || s.segmentStart.IsZero() || time.Since(s.segmentStart) < s.segmentEvery {
+		return
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var data bytes.Buffer
+		data.WriteByte(segmentPlain)
+		err := tx.Bucket(recordsBucket).ForEach(func(_, v []byte) error {
+			data.Write(v)
+			return data.WriteByte('\n')
+		})
+		if err != nil {
+			return err
+		}
+
+		segments := tx.Bucket(segmentsBucket)
+		closed := time.Now()
+		for segments.Get(timeKey(closed)) != nil {
+			closed = closed.Add(time.Nanosecond)
+		}
+		if err := segments.Put(timeKey(closed), data.Bytes()); err != nil {
+			return err
+		}
+		for _, name := range [][]byte{recordsBucket, nodesBucket} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// Keep the records open rather than losing them
+		log.Printf("Failed to close store segment: %v", err)
+		return
+	}
+	s.segmentStart = time.Time{}
+
+	select {
+	case s.compactChan <- struct{}{}:
+	default:
+	}
+}
+
+// segments lists the closed segments, oldest first
+func (s *Store) segments() ([]segment, error) {
+	var found []segment
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(segmentsBucket).ForEach(func(k, v []byte) error {
+			found = append(found, segment{
+				key:        append([]byte(nil), k...),
+				closed:     keyTime(k),
+				compressed: len(v) > 0 && v[0] == segmentGzip,
+			})
+			return nil
+		})
+	})
+	return found, err
+}
+
+// compactLoop compacts the segments at startup and after every rotation
+// until Stop is called
+func (s *Store) compactLoop() {
+	for {
+		s.compact()
+		select {
+		case <-s.stopChan:
+			return
+		case <-s.compactChan:
+		}
+	}
+}
+
+// compact deletes segments past the retention window and gzips the rest.
+// Compression runs outside any write transaction, so writes and queries
+// go on meanwhile
+func (s *Store) compact() {
+	segments, err := s.segments()
+	if err != nil {
+		log.Printf("Failed to list store segments: %v", err)
+		return
+	}
+	cutoff := time.Now().Add(-s.retention)
+	for _, seg := range segments {
+		select {
+		case <-s.stopChan:
+			return
+		default:
+		}
+		if s.retention > 0 && seg.closed.Before(cutoff) {
+			err := s.db.Update(func(tx *bolt.Tx) error {
+				return tx.Bucket(segmentsBucket).Delete(seg.key)
+			})
+			if err != nil {
+				log.Printf("Failed to prune store segment: %v", err)
+			}
+			continue
+		}
+		if !seg.compressed {
+			if err := s.compress(seg.key); err != nil {
+				log.Printf("Failed to compress store segment: %v", err)
+			}
+		}
+	}
+}
+
+// compress gzips the segment at key and swaps the result in for it
+func (s *Store) compress(key []byte) error {
+	var plain []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(segmentsBucket).Get(key); len(v) > 0 && v[0] == segmentPlain {
+			plain = append([]byte(nil), v[1:]...)
+		}
+		return nil
+	})
+	if err != nil || plain == nil {
+		return err
+	}
+
+	var data bytes.Buffer
+	data.WriteByte(segmentGzip)
+	zw := gzip.NewWriter(&data)
+	if _, err := zw.Write(plain); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		segments := tx.Bucket(segmentsBucket)
+		// Pruned meanwhile
+		if segments.Get(key) == nil {
+			return nil
+		}
+		return segments.Put(key, data.Bytes())
+	})
+}
+
+// copySegments returns copies of the segments that may hold records from
+// from on, oldest first, valid after tx ends. Segments closed before from
+// hold nothing newer, so they are skipped
+func copySegments(tx *bolt.Tx, from time.Time) [][]byte {
+	var segments [][]byte
+	c := tx.Bucket(segmentsBucket).Cursor()
+	k, v := c.First()
+	if !from.IsZero() {
+		k, v = c.Seek(timeKey(from))
+	}
+	for ; k != nil; k, v = c.Next() {
+		segments = append(segments, append([]byte(nil), v...))
+	}
+	return segments
+}
+
+// readSegment appends the records in the segment value v that match to
+// records
+func readSegment(v []byte, match func(Record) bool, records []Record) ([]Record, error) {
+	if len(v) == 0 {
+		return records, errCorrupt
+	}
+	var r io.Reader = bytes.NewReader(v[1:])
+	switch v[0] {
+	case segmentPlain:
+	case segmentGzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return records, fmt.Errorf("failed to read store segment: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return records, errCorrupt
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return records, errCorrupt
+		}
+		if match(rec) {
+			records = append(records, rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return records, fmt.Errorf("failed to read store segment: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,369 @@
+// Package store persists node snapshots and registry events in an embedded
+// bbolt database so history survives restarts. Records are indexed by time
+// and by node, older history can be closed into gzipped segments, and
+// segments are pruned after a retention window
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+// queueSize bounds the records waiting to be written. When full, new records
+// are dropped rather than blocking the monitor
+const queueSize = 1024
+
+// openTimeout is how long Open waits for another process holding the
+// database to let go of it
+const openTimeout = time.Second
+
+// Record kinds
+const (
+	KindSnapshot = "snapshot"
+	KindEvent    = "event"
+)
+
+// Buckets. recordsBucket holds the records not yet closed into a segment,
+// keyed by time and a sequence number (see recordKey). nodesBucket indexes
+// them by node, keyed by address, a 0 byte and the record key.
+// segmentsBucket holds the closed segments, see SetSegments
+var (
+	recordsBucket  = []byte("records")
+	nodesBucket    = []byte("nodes")
+	segmentsBucket = []byte("segments")
+)
+
+// Record is a single persisted entry: either a periodic snapshot of one
+// node or a registry event
+type Record struct {
+	Time         time.Time `json:"time"`
+	Kind         string    `json:"kind"`
+	Event        string    `json:"event,omitempty"`
+	Address      string    `json:"address"`
+	StatusCode   uint8     `json:"status_code"`
+	PrevStatus   uint8     `json:"prev_status,omitempty"`
+	CPUPercent   float64   `json:"cpu_percent,omitempty"`
+	RAMPercent   float64   `json:"ram_percent,omitempty"`
+	DiskPercent  float64   `json:"disk_percent,omitempty"`
+	HasTelemetry bool      `json:"has_telemetry,omitempty"`
+}
+
+// Store is a write-behind sink for node history. Records are queued by
+// RecordEvent and Start's snapshots, and written by a single goroutine
+type Store struct {
+	db       *bolt.DB
+	monitor  *registry.Monitor
+	queue    chan Record
+	dropped  atomic.Uint64
+	stopChan chan struct{}
+	stopOnce sync.Once
+	stopErr  error         // Returned by every Stop
+	doneChan chan struct{} // Closed when the writer loop exits
+	running  atomic.Bool
+
+	// Segmenting, set by SetSegments. segmentStart is the time of the
+	// oldest open record and is owned by the writer goroutine
+	segmentEvery time.Duration
+	retention    time.Duration
+	segmentStart time.Time
+	compactChan  chan struct{} // Wakes the compactor after a rotation
+}
+
+// Open opens or creates the store database at path. It fails if another
+// process has the database open
+func Open(path string, monitor *registry.Monitor) (*Store, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{recordsBucket, nodesBucket, segmentsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	return &Store{
+		db:          db,
+		monitor:     monitor,
+		queue:       make(chan Record, queueSize),
+		stopChan:    make(chan struct{}),
+		doneChan:    make(chan struct{}),
+		compactChan: make(chan struct{}, 1),
+	}, nil
+}
+
+// RecordEvent queues a registry event without blocking
+// Suitable for Monitor.SetEventHandler
+func (s *Store) RecordEvent(e registry.Event) {
+	s.enqueue(Record{
+		Time:       e.Time,
+		Kind:       KindEvent,
+		Event:      string(e.Type),
+		Address:    e.Address,
+		StatusCode: e.StatusCode,
+		PrevStatus: e.PrevStatus,
+	})
+}
+
+// enqueue adds a record to the write queue, dropping it if the queue is full
+func (s *Store) enqueue(r Record) {
+	select {
+	case s.queue <- r:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of records discarded because the queue was full
+func (s *Store) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Start writes queued records and snapshots the monitor every interval until
+// Stop is called
+func (s *Store) Start(interval time.Duration) {
+	if !s.running.CompareAndSwap(false, true) {
+		return
+	}
+	defer close(s.doneChan)
+
+	if s.segmentEvery > 0 {
+		compactDone := make(chan struct{})
+		go func() {
+			defer close(compactDone)
+			s.compactLoop()
+		}()
+		defer func() { <-compactDone }()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var batch []Record
+	for {
+		select {
+		case <-s.stopChan:
+			s.drain()
+			return
+		case r := <-s.queue:
+			// Batch whatever else is already queued into one transaction
+			batch = append(batch[:0], r)
+			for len(s.queue) > 0 {
+				batch = append(batch, <-s.queue)
+			}
+			s.write(batch)
+			s.maybeRotate()
+		case now := <-ticker.C:
+			batch = batch[:0]
+			for addr, info := range s.monitor.GetNodes() {
+				batch = append(batch, Record{
+					Time:         now,
+					Kind:         KindSnapshot,
+					Address:      addr,
+					StatusCode:   info.StatusCode,
+					CPUPercent:   info.CPUPercent,
+					RAMPercent:   info.RAMPercent,
+					DiskPercent:  info.DiskPercent,
+					HasTelemetry: info.HasTelemetry,
+				})
+			}
+			s.write(batch)
+			s.maybeRotate()
+		}
+	}
+}
+
+// drain writes everything still queued
+func (s *Store) drain() {
+	var batch []Record
+	for {
+		select {
+		case r := <-s.queue:
+			batch = append(batch, r)
+		default:
+			s.write(batch)
+			return
+		}
+	}
+}
+
+// write stores records in one transaction
+func (s *Store) write(records []Record) {
+	if len(records) == 0 {
+		return
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, nodes := tx.Bucket(recordsBucket), tx.Bucket(nodesBucket)
+		for _, r := range records {
+			data, err := json.Marshal(r)
+			if err != nil {
+				log.Printf("Failed to encode store record: %v", err)
+				continue
+			}
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			key := recordKey(r.Time, seq)
+			if err := b.Put(key, data); err != nil {
+				return err
+			}
+			if err := nodes.Put(nodeKey(r.Address, key), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to write store: %v", err)
+		return
+	}
+	if s.segmentEvery > 0 {
+		for _, r := range records {
+			if s.segmentStart.IsZero() || r.Time.Before(s.segmentStart) {
+				s.segmentStart = r.Time
+			}
+		}
+	}
+}
+
+// Stop writes pending records and closes the database. Safe to call more
+// than once
+func (s *Store) Stop() error {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+		// Claiming the running flag here keeps a late Start from writing to
+		// a closed database; if Start already claimed it, wait for it to drain
+		if s.running.CompareAndSwap(false, true) {
+			s.drain()
+		} else {
+			<-s.doneChan
+		}
+		s.stopErr = s.db.Close()
+	})
+	return s.stopErr
+}
+
+// Query returns the records for addr between from and to (inclusive), oldest
+// first, across the closed segments and the open records. An empty addr
+// matches all nodes, and a zero from or to is unbounded
+func (s *Store) Query(addr string, from, to time.Time) ([]Record, error) {
+	match := func(r Record) bool {
+		if addr != "" && r.Address != addr {
+			return false
+		}
+		return (from.IsZero() || !r.Time.Before(from)) && (to.IsZero() || !r.Time.After(to))
+	}
+
+	// Segments are copied out of the transaction and decompressed after it
+	// ends. A long read transaction holds up the writer when the database
+	// has to grow, so it must not wait on gzip
+	var segments [][]byte
+	var open []Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		segments = copySegments(tx, from)
+		var err error
+		open, err = readOpen(tx, addr, from, to, nil)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+
+	var records []Record
+	for _, seg := range segments {
+		if records, err = readSegment(seg, match, records); err != nil {
+			return nil, fmt.Errorf("failed to read store: %w", err)
+		}
+	}
+	return append(records, open...), nil
+}
+
+// readOpen appends the open records for addr between from and to to
+// records. A single node is read through its index
+func readOpen(tx *bolt.Tx, addr string, from, to time.Time, records []Record) ([]Record, error) {
+	b := tx.Bucket(recordsBucket)
+	add := func(data []byte) error {
+		var r Record
+		if err := json.Unmarshal(data, &r); err != nil {
+			return errCorrupt
+		}
+		records = append(records, r)
+		return nil
+	}
+
+	var start []byte
+	if !from.IsZero() {
+		start = timeKey(from)
+	}
+	if addr == "" {
+		c := b.Cursor()
+		k, v := c.First()
+		if start != nil {
+			k, v = c.Seek(start)
+		}
+		for ; k != nil && (to.IsZero() || !keyTime(k).After(to)); k, v = c.Next() {
+			if err := add(v); err != nil {
+				return records, err
+			}
+		}
+		return records, nil
+	}
+
+	prefix := nodeKey(addr, nil)
+	c := tx.Bucket(nodesBucket).Cursor()
+	for k, _ := c.Seek(append(prefix, start...)); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		key := k[len(prefix):]
+		if !to.IsZero() && keyTime(key).After(to) {
+			break
+		}
+		if err := add(b.Get(key)); err != nil {
+			return records, err
+		}
+	}
+	return records, nil
+}
+
+// recordKey orders records by time, then by the order they were written
+func recordKey(t time.Time, seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(timeKey(t), seq)
+}
+
+// timeKey encodes t so that byte order is time order. Flipping the sign
+// bit sorts times before 1970 first
+func timeKey(t time.Time) []byte {
+	return binary.BigEndian.AppendUint64(make([]byte, 0, 16), uint64(t.UnixNano())^(1<<63))
+}
+
+// keyTime returns the time a record or segment key starts with
+func keyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key)^(1<<63)))
+}
+
+// nodeKey is the index key of the record at key for addr. Addresses never
+// contain a 0 byte, so one node's keys never run into another's
+func nodeKey(addr string, key []byte) []byte {
+	k := make([]byte, 0, len(addr)+1+len(key))
+	k = append(append(k, addr...), 0)
+	return append(k, key...)
+}
+
+// errCorrupt is returned for a record or segment that cannot be decoded
+var errCorrupt = errors.New("corrupt store entry")
@@ -0,0 +1,253 @@
+package store
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+func TestStorePersistsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	monitor := registry.NewMonitor()
+
+	s, err := Open(path, monitor)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	monitor.SetEventHandler(s.RecordEvent)
+	go s.Start(20 * time.Millisecond)
+
+	monitor.UpdateWithTelemetry("10.0.0.1:9999", 40, 50, 60, 0)
+	monitor.UpdateWithStatus("10.0.0.2:9999", 0, 0)
+	monitor.UpdateWithStatus("10.0.0.2:9999", 2, 0)
+
+	// Wait for at least one snapshot round
+	time.Sleep(60 * time.Millisecond)
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	// Reopen as a restarted collector would
+	s, err = Open(path, registry.NewMonitor())
+	if err != nil {
+		t.Fatalf("Open() after restart error = %v", err)
+	}
+	defer s.Stop()
+
+	records, err := s.Query("10.0.0.2:9999", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	var events []string
+	var snapshots int
+	for _, r := range records {
+		if r.Address != "10.0.0.2:9999" {
+			t.Errorf("Query() returned record for %s", r.Address)
+		}
+		switch r.Kind {
+		case KindEvent:
+			events = append(events, r.Event)
+		case KindSnapshot:
+			snapshots++
+		}
+	}
+	if len(events) != 2 || events[0] != string(registry.EventJoined) || events[1] != string(registry.EventStatusChanged) {
+		t.Errorf("Query() events = %v, want joined then status_changed", events)
+	}
+	if snapshots == 0 {
+		t.Error("Query() returned no snapshots")
+	}
+
+	all, _ := s.Query("", time.Time{}, time.Time{})
+	var sawTelemetry bool
+	for _, r := range all {
+		if r.Address == "10.0.0.1:9999" && r.Kind == KindSnapshot && r.CPUPercent == 40 {
+			sawTelemetry = true
+		}
+	}
+	if !sawTelemetry {
+		t.Error("snapshots should include node telemetry")
+	}
+}
+
+func TestStoreQueryTimeRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	s, err := Open(path, registry.NewMonitor())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	go s.Start(time.Hour)
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		s.RecordEvent(registry.Event{
+			Time:    base.Add(time.Duration(i) * time.Minute),
+			Type:    registry.EventStatusChanged,
+			Address: "10.0.0.1:9999",
+		})
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	s, _ = Open(path, registry.NewMonitor())
+	defer s.Stop()
+	records, err := s.Query("10.0.0.1:9999", base.Add(time.Minute), base.Add(3*time.Minute))
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(records) != 3 {
+		t.Errorf("Query() returned %d records, want 3", len(records))
+	}
+}
+
+func TestStoreDropsWhenFull(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "history.db"), registry.NewMonitor())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Stop()
+
+	// Without a writer running the queue fills up; recording must not block
+	for i := 0; i < queueSize+10; i++ {
+		s.RecordEvent(registry.Event{Time: time.Now(), Type: registry.EventJoined})
+	}
+	if s.Dropped() != 10 {
+		t.Errorf("Dropped() = %d, want 10", s.Dropped())
+	}
+}
+
+func TestStoreSegmentsCompressed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	s, err := Open(path, registry.NewMonitor())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	s.SetSegments(time.Millisecond, 0)
+	go s.Start(time.Hour)
+
+	// Records stamped in the past close a segment as soon as they are written
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		s.RecordEvent(registry.Event{
+			Time:    base.Add(time.Duration(i) * time.Minute),
+			Type:    registry.EventStatusChanged,
+			Address: "10.0.0.1:9999",
+		})
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	waitFor(t, "no compressed segment was written", func() bool {
+		segments, _ := s.segments()
+		return len(segments) > 0 && segments[0].compressed
+	})
+
+	records, err := s.Query("10.0.0.1:9999", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Query() returned %d records, want 3", len(records))
+	}
+	for i, r := range records {
+		if want := base.Add(time.Duration(i) * time.Minute); !r.Time.Equal(want) {
+			t.Errorf("Query()[%d].Time = %v, want %v", i, r.Time, want)
+		}
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+}
+
+func TestStoreSegmentsRetention(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "history.db"), registry.NewMonitor())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	old := timeKey(time.Now().Add(-48 * time.Hour))
+	recent := timeKey(time.Now().Add(-time.Hour))
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		for _, key := range [][]byte{old, recent} {
+			record := `{"time":"2024-01-01T12:00:00Z","kind":"event","address":"10.0.0.1:9999","status_code":0}` + "\n"
+			if err := tx.Bucket(segmentsBucket).Put(key, append([]byte{segmentPlain}, record...)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetSegments(time.Hour, 24*time.Hour)
+	go s.Start(time.Hour)
+	defer s.Stop()
+
+	waitFor(t, "compactor did not prune the old segment and compress the recent one", func() bool {
+		segments, _ := s.segments()
+		return len(segments) == 1 && bytes.Equal(segments[0].key, recent) && segments[0].compressed
+	})
+	records, err := s.Query("", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("Query() returned %d records, want the recent segment's 1", len(records))
+	}
+}
+
+func TestStoreOpenLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	s, err := Open(path, registry.NewMonitor())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Stop()
+	if other, err := Open(path, registry.NewMonitor()); err == nil {
+		other.Stop()
+		t.Error("Open() of a database in use should fail")
+	}
+}
+
+// waitFor fails the test with msg unless cond holds within 2 seconds
+func waitFor(t *testing.T, msg string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	for spec, want := range map[string]time.Duration{"": 0, "7d": 7 * 24 * time.Hour, "36h": 36 * time.Hour} {
+		if got, err := ParseRetention(spec); err != nil || got != want {
+			t.Errorf("ParseRetention(%q) = %v, %v, want %v", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"d", "7x", "-1d", "-5m"} {
+		if _, err := ParseRetention(spec); err == nil {
+			t.Errorf("ParseRetention(%q) should return error", spec)
+		}
+	}
+}
+
+func TestStoreStopTwice(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "history.db"), registry.NewMonitor())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	go s.Start(time.Hour)
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Errorf("second Stop() error = %v", err)
+	}
+}
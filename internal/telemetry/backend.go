@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// MemoryUsage is a memory reading from a Backend
+type MemoryUsage struct {
+	UsedPercent    float64
+	TotalBytes     uint64
+	AvailableBytes uint64 // Memory available for new allocations
+}
+
+// DiskUsage is a filesystem reading from a Backend
+type DiskUsage struct {
+	UsedPercent float64
+	TotalBytes  uint64
+	FreeBytes   uint64
+}
+
+// Backend reads the built-in CPU, memory and disk metrics. GopsutilBackend
+// reads the host; a fixed backend makes status logic testable, and a
+// cgroup-aware or remote one can replace it without touching the
+// heartbeat loop. Calls may block: the Collector bounds each with its
+// timeout and calls them from one goroutine per metric at a time
+type Backend interface {
+	CPUPercent() (float64, error) // Average over all cores since the previous call
+	Memory() (MemoryUsage, error)
+	Disk(path string) (DiskUsage, error) // Usage of the filesystem mounted at path
+}
+
+// GopsutilBackend reads metrics from the host with gopsutil
+type GopsutilBackend struct{}
+
+// CPUPercent samples CPU usage since the previous call
+func (GopsutilBackend) CPUPercent() (float64, error) {
+	cpuPercent, err := cpu.Percent(0, false)
+	if err != nil {
+		return 0, err
+	}
+	if len(cpuPercent) == 0 {
+		return 0, nil
+	}
+	return cpuPercent[0], nil
+}
+
+// Memory samples virtual memory usage
+func (GopsutilBackend) Memory() (MemoryUsage, error) {
+	memInfo, err := mem.VirtualMemory()
+	if err != nil {
+		return MemoryUsage{}, err
+	}
+	return MemoryUsage{
+		UsedPercent:    memInfo.UsedPercent,
+		TotalBytes:     memInfo.Total,
+		AvailableBytes: memInfo.Available,
+	}, nil
+}
+
+// Disk samples the usage of the filesystem mounted at path
+func (GopsutilBackend) Disk(path string) (DiskUsage, error) {
+	diskInfo, err := disk.Usage(path)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	return DiskUsage{
+		UsedPercent: diskInfo.UsedPercent,
+		TotalBytes:  diskInfo.Total,
+		FreeBytes:   diskInfo.Free,
+	}, nil
+}
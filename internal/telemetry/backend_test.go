@@ -0,0 +1,91 @@
+package telemetry
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fixedBackend returns preset readings, or blocks until release is closed
+type fixedBackend struct {
+	cpu, ram, disk float64
+	err            error
+	release        chan struct{}
+}
+
+func (b *fixedBackend) wait() {
+	if b.release != nil {
+		<-b.release
+	}
+}
+
+func (b *fixedBackend) CPUPercent() (float64, error) {
+	b.wait()
+	return b.cpu, b.err
+}
+
+func (b *fixedBackend) Memory() (MemoryUsage, error) {
+	b.wait()
+	return MemoryUsage{UsedPercent: b.ram, TotalBytes: 8 << 30, AvailableBytes: 2 << 30}, b.err
+}
+
+func (b *fixedBackend) Disk(path string) (DiskUsage, error) {
+	b.wait()
+	if path != "/" {
+		return DiskUsage{}, errors.New("unexpected path " + path)
+	}
+	return DiskUsage{UsedPercent: b.disk, TotalBytes: 100 << 30, FreeBytes: 40 << 30}, b.err
+}
+
+func TestCollectorBackendStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		cpu, ram, disk float64
+		want           StatusCode
+	}{
+		{"healthy", 10, 20, 30, StatusOK},
+		{"cpu warn", 75, 20, 30, StatusWarn},
+		{"ram critical", 10, 96, 30, StatusCritical},
+		{"disk critical", 75, 20, 96, StatusCritical},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollectorWithBackend(time.Second, &fixedBackend{cpu: tt.cpu, ram: tt.ram, disk: tt.disk})
+			metrics, err := c.Collect()
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			if metrics.CPUPercent != tt.cpu || metrics.RAMPercent != tt.ram || metrics.DiskPercent != tt.disk {
+				t.Errorf("Collect() = %+v, want the backend's readings", metrics)
+			}
+			if metrics.RAMTotalBytes != 8<<30 || metrics.DiskFreeBytes != 40<<30 {
+				t.Errorf("Collect() sizes = %d/%d, want the backend's", metrics.RAMTotalBytes, metrics.DiskFreeBytes)
+			}
+			if got := CalculateStatus(metrics, DefaultThresholds()); got != tt.want {
+				t.Errorf("CalculateStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectorBackendFailure(t *testing.T) {
+	c := NewCollectorWithBackend(time.Second, &fixedBackend{err: errors.New("cgroup unavailable")})
+	if _, err := c.Collect(); err == nil {
+		t.Error("Collect() should fail when the backend fails with no last-known value")
+	}
+
+	// A backend that hangs is timed out and its metrics reported missing
+	release := make(chan struct{})
+	defer close(release)
+	c = NewCollectorWithBackend(10*time.Millisecond, &fixedBackend{release: release})
+	metrics, err := c.Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(metrics.Missing) != 3 {
+		t.Errorf("Missing = %v, want cpu, memory and disk", metrics.Missing)
+	}
+	if got := CalculateStatus(metrics, DefaultThresholds()); got != StatusUnknown {
+		t.Errorf("CalculateStatus() = %v, want %v", got, StatusUnknown)
+	}
+}
@@ -1,16 +1,42 @@
 package telemetry
 
-import (
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/disk"
-	"github.com/shirou/gopsutil/v3/mem"
-)
-
 // Metrics represents system resource metrics
 type Metrics struct {
 	CPUPercent float64
 	RAMPercent float64
 	DiskPercent float64
+
+	// Absolute values (0 when unknown)
+	RAMTotalBytes  uint64
+	RAMFreeBytes   uint64 // Memory available for new allocations
+	DiskTotalBytes uint64
+	DiskFreeBytes  uint64
+
+	// Throughput summed over the interfaces selected with
+	// Collector.SetNetworkInterfaces, in bytes per second since the previous
+	// sample. HasNetwork is false until two samples have been taken
+	NetRxBytesPerSec float64
+	NetTxBytesPerSec float64
+	HasNetwork       bool
+
+	// Share of CPU time stolen by the hypervisor since the previous sample,
+	// in percent, when enabled with Collector.SetCPUSteal. HasSteal is false
+	// until two samples have been taken
+	CPUSteal float64
+	HasSteal bool
+
+	// Usage of the mounts with their own thresholds, other than the root
+	Mounts []MountUsage
+
+	// Values of the sources added with Collector.AddMetricSource, by name
+	Custom map[string]float64
+
+	// Sources that timed out or failed; their values are last-known or zero
+	Degraded []string
+
+	// Degraded sources judged against thresholds that have no last-known
+	// value either, so their zeros are placeholders rather than readings
+	Missing []string
 }
 
 // Thresholds defines warning and critical thresholds for metrics
@@ -21,6 +47,26 @@ type Thresholds struct {
 	RAMCritical float64
 	DiskWarn    float64
 	DiskCritical float64
+
+	// Optional absolute free-space thresholds in bytes (0 disables)
+	// Status is the worse of the percentage and absolute checks
+	RAMFreeWarnBytes      uint64
+	RAMFreeCriticalBytes  uint64
+	DiskFreeWarnBytes     uint64
+	DiskFreeCriticalBytes uint64
+
+	// Optional CPU steal thresholds in percent (0 disables), only judged
+	// when steal is collected
+	StealWarn     float64
+	StealCritical float64
+
+	// Optional per-mount disk thresholds; the status is the worst across
+	// mounts. A "/" entry replaces DiskWarn and DiskCritical
+	Mounts []MountThreshold
+
+	// Optional thresholds for custom metrics, each of which may be lower
+	// is worse. The status is the worst across them
+	Metrics []MetricThreshold
 }
 
 // DefaultThresholds returns sensible default thresholds
@@ -32,6 +78,9 @@ func DefaultThresholds() Thresholds {
 		RAMCritical: 95.0,
 		DiskWarn:    85.0,
 		DiskCritical: 95.0,
+
+		StealWarn:     10.0,
+		StealCritical: 25.0,
 	}
 }
 
@@ -42,55 +91,138 @@ const (
 	StatusOK StatusCode = iota
 	StatusWarn
 	StatusCritical
+
+	// StatusUnknown means no threshold was breached but some metrics could
+	// not be collected, so the node cannot be called healthy. Peers
+	// predating it count it as critical
+	StatusUnknown
 )
 
 // CollectMetrics gathers current system metrics
+// It waits for every source; use a Collector to bound slow calls
 func CollectMetrics() (*Metrics, error) {
-	// Collect CPU usage
-	cpuPercent, err := cpu.Percent(0, false)
-	if err != nil {
-		return nil, err
-	}
-	cpuUsage := 0.0
-	if len(cpuPercent) > 0 {
-		cpuUsage = cpuPercent[0]
+	return collectFrom(GopsutilBackend{})
+}
+
+// collectFrom gathers the built-in metrics from b, waiting for each
+func collectFrom(b Backend) (*Metrics, error) {
+	metrics := &Metrics{}
+	for _, collect := range []func() (func(*Metrics), error){cpuSource(b), memorySource(b), diskSource(b)} {
+		apply, err := collect()
+		if err != nil {
+			return nil, err
+		}
+		apply(metrics)
 	}
+	return metrics, nil
+}
 
-	// Collect RAM usage
-	memInfo, err := mem.VirtualMemory()
-	if err != nil {
-		return nil, err
+// cpuSource samples CPU usage since the previous call
+func cpuSource(b Backend) func() (func(*Metrics), error) {
+	return func() (func(*Metrics), error) {
+		cpuUsage, err := b.CPUPercent()
+		if err != nil {
+			return nil, err
+		}
+		return func(m *Metrics) {
+			m.CPUPercent = cpuUsage
+		}, nil
 	}
+}
 
-	// Collect disk usage (root partition)
-	diskInfo, err := disk.Usage("/")
-	if err != nil {
-		return nil, err
+// memorySource samples RAM usage
+func memorySource(b Backend) func() (func(*Metrics), error) {
+	return func() (func(*Metrics), error) {
+		memInfo, err := b.Memory()
+		if err != nil {
+			return nil, err
+		}
+		return func(m *Metrics) {
+			m.RAMPercent = memInfo.UsedPercent
+			m.RAMTotalBytes = memInfo.TotalBytes
+			m.RAMFreeBytes = memInfo.AvailableBytes
+		}, nil
 	}
+}
 
-	return &Metrics{
-		CPUPercent:  cpuUsage,
-		RAMPercent:  memInfo.UsedPercent,
-		DiskPercent: diskInfo.UsedPercent,
-	}, nil
+// diskSource samples usage of the root partition
+func diskSource(b Backend) func() (func(*Metrics), error) {
+	return func() (func(*Metrics), error) {
+		diskInfo, err := b.Disk("/")
+		if err != nil {
+			return nil, err
+		}
+		return func(m *Metrics) {
+			m.DiskPercent = diskInfo.UsedPercent
+			m.DiskTotalBytes = diskInfo.TotalBytes
+			m.DiskFreeBytes = diskInfo.FreeBytes
+		}, nil
+	}
 }
 
-// CalculateStatus determines the health status based on metrics and thresholds
+// CalculateStatus determines the health status based on metrics and
+// thresholds. A breach found in the metrics that were collected wins;
+// otherwise missing metrics make the status unknown rather than OK
 func CalculateStatus(metrics *Metrics, thresholds Thresholds) StatusCode {
 	// Check for critical conditions first
-	if metrics.CPUPercent >= thresholds.CPUCritical ||
-		metrics.RAMPercent >= thresholds.RAMCritical ||
-		metrics.DiskPercent >= thresholds.DiskCritical {
-		return StatusCritical
+	for _, breached := range criticalBreaches(metrics, thresholds) {
+		if breached {
+			return StatusCritical
+		}
 	}
 
 	// Check for warning conditions
+	diskWarn, _ := rootDiskThresholds(thresholds)
 	if metrics.CPUPercent >= thresholds.CPUWarn ||
 		metrics.RAMPercent >= thresholds.RAMWarn ||
-		metrics.DiskPercent >= thresholds.DiskWarn {
+		metrics.DiskPercent >= diskWarn ||
+		mountStatus(metrics, thresholds) == StatusWarn ||
+		customStatus(metrics, thresholds) == StatusWarn ||
+		stealStatus(metrics, thresholds) == StatusWarn ||
+		belowFree(metrics.RAMFreeBytes, metrics.RAMTotalBytes, thresholds.RAMFreeWarnBytes) ||
+		belowFree(metrics.DiskFreeBytes, metrics.DiskTotalBytes, thresholds.DiskFreeWarnBytes) {
 		return StatusWarn
 	}
 
-	// All metrics are below warning thresholds
+	// Healthy only if nothing is missing, not by absence of data
+	if len(metrics.Missing) > 0 {
+		return StatusUnknown
+	}
 	return StatusOK
 }
+
+// metric identifies a thresholded metric
+type metric int
+
+const (
+	metricCPU metric = iota
+	metricRAM
+	metricDisk
+	metricRAMFree
+	metricDiskFree
+	metricMounts
+	metricCustom
+	metricSteal
+	numMetrics
+)
+
+// criticalBreaches reports which metrics are past their critical threshold
+func criticalBreaches(metrics *Metrics, thresholds Thresholds) [numMetrics]bool {
+	_, diskCritical := rootDiskThresholds(thresholds)
+	return [numMetrics]bool{
+		metricCPU:      metrics.CPUPercent >= thresholds.CPUCritical,
+		metricRAM:      metrics.RAMPercent >= thresholds.RAMCritical,
+		metricDisk:     metrics.DiskPercent >= diskCritical,
+		metricRAMFree:  belowFree(metrics.RAMFreeBytes, metrics.RAMTotalBytes, thresholds.RAMFreeCriticalBytes),
+		metricDiskFree: belowFree(metrics.DiskFreeBytes, metrics.DiskTotalBytes, thresholds.DiskFreeCriticalBytes),
+		metricMounts:   mountStatus(metrics, thresholds) == StatusCritical,
+		metricCustom:   customStatus(metrics, thresholds) == StatusCritical,
+		metricSteal:    stealStatus(metrics, thresholds) == StatusCritical,
+	}
+}
+
+// belowFree reports whether free bytes dropped below an absolute threshold
+// Disabled thresholds (0) and unknown totals never trigger
+func belowFree(free, total, threshold uint64) bool {
+	return threshold > 0 && total > 0 && free < threshold
+}
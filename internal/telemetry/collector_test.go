@@ -2,6 +2,7 @@ package telemetry
 
 import (
 	"testing"
+	"time"
 )
 
 func TestDefaultThresholds(t *testing.T) {
@@ -181,6 +182,63 @@ func TestCalculateStatusEdgeCases(t *testing.T) {
 	}
 }
 
-// Note: CollectMetrics() is tested indirectly through integration tests
-// as it requires actual system resources and may behave differently
-// across platforms.
+// Note: CollectMetrics() reads the host through GopsutilBackend and is
+// tested indirectly through integration tests; the collection and status
+// logic is tested with a fixed backend in backend_test.go.
+
+func TestCalculateStatusAbsoluteFree(t *testing.T) {
+	const gb = 1 << 30
+	thresholds := DefaultThresholds()
+	thresholds.DiskFreeWarnBytes = 10 * gb
+	thresholds.DiskFreeCriticalBytes = 5 * gb
+	thresholds.RAMFreeCriticalBytes = 512 << 20
+
+	testCases := []struct {
+		name    string
+		metrics *Metrics
+		want    StatusCode
+	}{
+		{"Plenty free", &Metrics{DiskPercent: 50, DiskTotalBytes: 1000 * gb, DiskFreeBytes: 500 * gb, RAMTotalBytes: 16 * gb, RAMFreeBytes: 8 * gb}, StatusOK},
+		// 80% used is below the percent warn, but only 8GB free on a 40GB volume
+		{"Disk free warn", &Metrics{DiskPercent: 80, DiskTotalBytes: 40 * gb, DiskFreeBytes: 8 * gb}, StatusWarn},
+		{"Disk free critical", &Metrics{DiskPercent: 60, DiskTotalBytes: 10 * gb, DiskFreeBytes: 4 * gb}, StatusCritical},
+		{"RAM free critical", &Metrics{RAMPercent: 50, RAMTotalBytes: 1 * gb, RAMFreeBytes: 256 << 20}, StatusCritical},
+		// Percent check is worse than the absolute check
+		{"Percent wins", &Metrics{DiskPercent: 96, DiskTotalBytes: 1000 * gb, DiskFreeBytes: 40 * gb}, StatusCritical},
+		// Unknown totals (e.g. zero-value metrics) never trigger absolute checks
+		{"Unknown totals", &Metrics{}, StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CalculateStatus(tc.metrics, thresholds); got != tc.want {
+				t.Errorf("CalculateStatus() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCalculateStatusMissing(t *testing.T) {
+	thresholds := DefaultThresholds()
+	testCases := []struct {
+		name    string
+		metrics Metrics
+		want    StatusCode
+	}{
+		{"Nothing missing", Metrics{CPUPercent: 10}, StatusOK},
+		{"Degraded with last-known values", Metrics{CPUPercent: 10, Degraded: []string{"disk"}}, StatusOK},
+		{"Missing disk", Metrics{CPUPercent: 10, Degraded: []string{"disk"}, Missing: []string{"disk"}}, StatusUnknown},
+		{"Breach wins over missing", Metrics{CPUPercent: 75, Missing: []string{"disk"}}, StatusWarn},
+		{"Critical wins over missing", Metrics{RAMPercent: 99, Missing: []string{"cpu"}}, StatusCritical},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CalculateStatus(&tc.metrics, thresholds); got != tc.want {
+				t.Errorf("CalculateStatus() = %d, want %d", got, tc.want)
+			}
+			if got := NewEvaluator(thresholds, 0).Evaluate(&tc.metrics, time.Now()); got != tc.want {
+				t.Errorf("Evaluate() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Direction says which way a metric gets worse
+type Direction uint8
+
+const (
+	// HigherIsWorse breaches at or above the thresholds, like CPU usage.
+	// The built-in metrics are all judged this way
+	HigherIsWorse Direction = iota
+
+	// LowerIsWorse breaches at or below the thresholds, like free memory,
+	// remaining battery or available connections
+	LowerIsWorse
+)
+
+// String returns "higher-is-worse" or "lower-is-worse"
+func (d Direction) String() string {
+	switch d {
+	case HigherIsWorse:
+		return "higher-is-worse"
+	case LowerIsWorse:
+		return "lower-is-worse"
+	default:
+		return fmt.Sprintf("Direction(%d)", d)
+	}
+}
+
+// MetricThreshold gives a custom metric, sampled by a source added with
+// Collector.AddMetricSource, its thresholds and the direction it gets worse in
+type MetricThreshold struct {
+	Name      string
+	Warn      float64
+	Critical  float64
+	Direction Direction
+}
+
+// Status judges value against the thresholds in the metric's direction
+func (t MetricThreshold) Status(value float64) StatusCode {
+	breached := func(limit float64) bool {
+		if t.Direction == LowerIsWorse {
+			return value <= limit
+		}
+		return value >= limit
+	}
+	switch {
+	case breached(t.Critical):
+		return StatusCritical
+	case breached(t.Warn):
+		return StatusWarn
+	default:
+		return StatusOK
+	}
+}
+
+// ValidateMetricThresholds checks that every metric is named once, has a
+// known direction, and is warned about before it goes critical
+func ValidateMetricThresholds(metrics []MetricThreshold) error {
+	seen := make(map[string]bool, len(metrics))
+	for _, t := range metrics {
+		if t.Name == "" {
+			return errors.New("metric threshold needs a name")
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("metric %q listed twice", t.Name)
+		}
+		seen[t.Name] = true
+		switch t.Direction {
+		case HigherIsWorse:
+			if t.Warn > t.Critical {
+				return fmt.Errorf("metric %s: warn threshold %v is above critical %v", t.Name, t.Warn, t.Critical)
+			}
+		case LowerIsWorse:
+			if t.Warn < t.Critical {
+				return fmt.Errorf("metric %s: warn threshold %v is below critical %v for a lower-is-worse metric", t.Name, t.Warn, t.Critical)
+			}
+		default:
+			return fmt.Errorf("metric %s: unknown direction %d", t.Name, t.Direction)
+		}
+	}
+	return nil
+}
+
+// AddMetricSource samples a custom metric with every Collect, stored in
+// Metrics.Custom under name and judged against the MetricThreshold of the
+// same name. It is timed out and falls back like the built-in sources, but
+// an error or a NaN or infinite value never fails the Collect: without a
+// last-known value the metric is listed as missing, making the status
+// unknown. Must be called before the first Collect
+func (c *Collector) AddMetricSource(name string, collect func() (float64, error)) {
+	c.sources = append(c.sources, &source{
+		name: "metric " + name,
+		collect: func() (func(*Metrics), error) {
+			v, err := collect()
+			if err != nil {
+				return nil, err
+			}
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("non-finite value %v", v)
+			}
+			return func(m *Metrics) {
+				if m.Custom == nil {
+					m.Custom = make(map[string]float64)
+				}
+				m.Custom[name] = v
+			}, nil
+		},
+		timeouts: NewFailureTracker(true),
+		isolated: true,
+		failures: NewFailureTracker(true),
+	})
+}
+
+// customStatus returns the worst status across the custom metrics with
+// thresholds. Metrics without a sampled value are ignored
+func customStatus(metrics *Metrics, thresholds Thresholds) StatusCode {
+	status := StatusOK
+	for _, t := range thresholds.Metrics {
+		v, ok := metrics.Custom[t.Name]
+		if !ok {
+			continue
+		}
+		if s := t.Status(v); s > status {
+			status = s
+		}
+	}
+	return status
+}
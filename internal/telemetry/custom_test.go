@@ -0,0 +1,132 @@
+package telemetry
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMetricThresholdStatus(t *testing.T) {
+	battery := MetricThreshold{Name: "battery", Warn: 20, Critical: 5, Direction: LowerIsWorse}
+	queue := MetricThreshold{Name: "queue", Warn: 100, Critical: 1000}
+
+	testCases := []struct {
+		threshold MetricThreshold
+		value     float64
+		want      StatusCode
+	}{
+		{battery, 80, StatusOK},
+		{battery, 20, StatusWarn},
+		{battery, 4, StatusCritical},
+		{queue, 10, StatusOK},
+		{queue, 100, StatusWarn},
+		{queue, 5000, StatusCritical},
+	}
+	for _, tc := range testCases {
+		if got := tc.threshold.Status(tc.value); got != tc.want {
+			t.Errorf("%s (%v) Status(%v) = %d, want %d", tc.threshold.Name, tc.threshold.Direction, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestValidateMetricThresholds(t *testing.T) {
+	valid := []MetricThreshold{
+		{Name: "battery", Warn: 20, Critical: 5, Direction: LowerIsWorse},
+		{Name: "queue", Warn: 100, Critical: 1000},
+	}
+	if err := ValidateMetricThresholds(valid); err != nil {
+		t.Errorf("ValidateMetricThresholds() error = %v", err)
+	}
+
+	for _, metrics := range [][]MetricThreshold{
+		{{Warn: 1, Critical: 2}}, // No name
+		{{Name: "battery", Warn: 5, Critical: 20, Direction: LowerIsWorse}}, // Critical before warn
+		{{Name: "queue", Warn: 1000, Critical: 100}},                        // Warn above critical
+		{{Name: "queue", Direction: 7}},                                     // Unknown direction
+		{{Name: "queue", Warn: 1, Critical: 2}, {Name: "queue", Warn: 1, Critical: 2}},
+	} {
+		if err := ValidateMetricThresholds(metrics); err == nil {
+			t.Errorf("ValidateMetricThresholds(%+v) should return error", metrics)
+		}
+	}
+}
+
+func TestCalculateStatusCustomMetrics(t *testing.T) {
+	thresholds := DefaultThresholds()
+	thresholds.Metrics = []MetricThreshold{
+		{Name: "battery", Warn: 20, Critical: 5, Direction: LowerIsWorse},
+		{Name: "connections_free", Warn: 50, Critical: 10, Direction: LowerIsWorse},
+	}
+
+	testCases := []struct {
+		name   string
+		custom map[string]float64
+		want   StatusCode
+	}{
+		{"Plenty left", map[string]float64{"battery": 90, "connections_free": 500}, StatusOK},
+		{"Battery low", map[string]float64{"battery": 15, "connections_free": 500}, StatusWarn},
+		{"Connections exhausted", map[string]float64{"battery": 90, "connections_free": 0}, StatusCritical},
+		{"Unsampled metric ignored", map[string]float64{"battery": 90}, StatusOK},
+		{"Metric without thresholds ignored", map[string]float64{"temperature": -40}, StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			metrics := &Metrics{Custom: tc.custom}
+			if got := CalculateStatus(metrics, thresholds); got != tc.want {
+				t.Errorf("CalculateStatus() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCollectorAddMetricSource(t *testing.T) {
+	c := newCollector(time.Second, nil)
+	c.AddMetricSource("battery", func() (float64, error) { return 42, nil })
+	metrics, err := c.Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if got := metrics.Custom["battery"]; got != 42 {
+		t.Errorf("Custom[battery] = %v, want 42", got)
+	}
+}
+
+func TestCollectorMetricSourceFailure(t *testing.T) {
+	c := newCollector(time.Second, nil)
+	var battery float64
+	var batteryErr error
+	c.AddMetricSource("battery", func() (float64, error) { return battery, batteryErr })
+	c.AddMetricSource("queue", func() (float64, error) { return 7, nil })
+	thresholds := DefaultThresholds()
+	thresholds.Metrics = []MetricThreshold{{Name: "battery", Warn: 20, Critical: 5, Direction: LowerIsWorse}}
+
+	// A failing source with no last-known value does not fail the others
+	for _, bad := range []struct {
+		value float64
+		err   error
+	}{{0, errors.New("no battery")}, {math.NaN(), nil}, {math.Inf(-1), nil}} {
+		battery, batteryErr = bad.value, bad.err
+		metrics, err := c.Collect()
+		if err != nil {
+			t.Fatalf("Collect() with battery %v, %v error = %v", bad.value, bad.err, err)
+		}
+		if _, ok := metrics.Custom["battery"]; ok || metrics.Custom["queue"] != 7 {
+			t.Errorf("Custom = %v, want queue only", metrics.Custom)
+		}
+		if got := CalculateStatus(metrics, thresholds); got != StatusUnknown {
+			t.Errorf("CalculateStatus() with battery %v, %v = %d, want StatusUnknown", bad.value, bad.err, got)
+		}
+	}
+
+	// Once sampled, a failure falls back to the last-known value
+	battery, batteryErr = 50, nil
+	if _, err := c.Collect(); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	battery = math.NaN()
+	metrics, err := c.Collect()
+	if err != nil || metrics.Custom["battery"] != 50 {
+		t.Errorf("Collect() = %v, %v, want the last-known battery 50", metrics.Custom, err)
+	}
+}
@@ -0,0 +1,46 @@
+package telemetry
+
+import "time"
+
+// Evaluator computes status like CalculateStatus, but only escalates to
+// Critical once a metric has stayed past its critical threshold for the
+// sustain duration. Until then the breach reports Warn, so transient
+// spikes are visible without raising alerts. Not safe for concurrent use
+type Evaluator struct {
+	thresholds   Thresholds
+	sustain      time.Duration
+	breachStarts [numMetrics]time.Time // Zero when the metric is not breaching
+}
+
+// NewEvaluator creates an evaluator. A zero sustain escalates immediately
+func NewEvaluator(thresholds Thresholds, sustain time.Duration) *Evaluator {
+	return &Evaluator{
+		thresholds: thresholds,
+		sustain:    sustain,
+	}
+}
+
+// Evaluate records the metrics sampled at now and returns the status
+func (e *Evaluator) Evaluate(metrics *Metrics, now time.Time) StatusCode {
+	status := StatusOK
+	for i, breached := range criticalBreaches(metrics, e.thresholds) {
+		if !breached {
+			e.breachStarts[i] = time.Time{}
+			continue
+		}
+		if e.breachStarts[i].IsZero() {
+			e.breachStarts[i] = now
+		}
+		if now.Sub(e.breachStarts[i]) >= e.sustain {
+			status = StatusCritical
+		} else if status < StatusWarn {
+			status = StatusWarn
+		}
+	}
+	if status != StatusOK {
+		return status
+	}
+
+	// No critical breach - fall back to the warning checks
+	return CalculateStatus(metrics, e.thresholds)
+}
@@ -0,0 +1,61 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluatorSustainedBreach(t *testing.T) {
+	e := NewEvaluator(DefaultThresholds(), 2*time.Minute)
+	start := time.Now()
+	spike := &Metrics{CPUPercent: 95}
+
+	if got := e.Evaluate(spike, start); got != StatusWarn {
+		t.Errorf("Evaluate() at breach start = %d, want Warn", got)
+	}
+	if got := e.Evaluate(spike, start.Add(time.Minute)); got != StatusWarn {
+		t.Errorf("Evaluate() before sustain = %d, want Warn", got)
+	}
+	if got := e.Evaluate(spike, start.Add(2*time.Minute)); got != StatusCritical {
+		t.Errorf("Evaluate() after sustain = %d, want Critical", got)
+	}
+
+	// Dropping below the threshold resets the breach timer
+	if got := e.Evaluate(&Metrics{CPUPercent: 50}, start.Add(3*time.Minute)); got != StatusOK {
+		t.Errorf("Evaluate() after recovery = %d, want OK", got)
+	}
+	if got := e.Evaluate(spike, start.Add(4*time.Minute)); got != StatusWarn {
+		t.Errorf("Evaluate() on new breach = %d, want Warn", got)
+	}
+}
+
+func TestEvaluatorPerMetric(t *testing.T) {
+	e := NewEvaluator(DefaultThresholds(), time.Minute)
+	start := time.Now()
+
+	e.Evaluate(&Metrics{CPUPercent: 95}, start)
+	// CPU recovers as RAM breaches - RAM's timer starts fresh
+	if got := e.Evaluate(&Metrics{RAMPercent: 96}, start.Add(time.Minute)); got != StatusWarn {
+		t.Errorf("Evaluate() = %d, want Warn for a new RAM breach", got)
+	}
+	if got := e.Evaluate(&Metrics{RAMPercent: 96}, start.Add(2*time.Minute)); got != StatusCritical {
+		t.Errorf("Evaluate() = %d, want Critical for a sustained RAM breach", got)
+	}
+}
+
+func TestEvaluatorNoSustain(t *testing.T) {
+	e := NewEvaluator(DefaultThresholds(), 0)
+	metrics := []*Metrics{
+		{CPUPercent: 50},
+		{CPUPercent: 75},
+		{CPUPercent: 95},
+		{DiskPercent: 99},
+	}
+
+	// Without a sustain duration the evaluator matches CalculateStatus
+	for _, m := range metrics {
+		if got, want := e.Evaluate(m, time.Now()), CalculateStatus(m, DefaultThresholds()); got != want {
+			t.Errorf("Evaluate(%+v) = %d, want %d", m, got, want)
+		}
+	}
+}
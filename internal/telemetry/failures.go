@@ -0,0 +1,38 @@
+package telemetry
+
+// FailureTracker counts consecutive collection failures so repeated errors
+// can be logged with exponential suppression (1st, 2nd, 4th, 8th... occurrence)
+// instead of on every heartbeat
+type FailureTracker struct {
+	consecutive int
+	suppress    bool
+}
+
+// NewFailureTracker creates a tracker. If suppress is false, every failure is reported
+func NewFailureTracker(suppress bool) *FailureTracker {
+	return &FailureTracker{suppress: suppress}
+}
+
+// Failure records a failure and reports whether it should be logged,
+// along with the current number of consecutive failures
+func (f *FailureTracker) Failure() (shouldLog bool, count int) {
+	f.consecutive++
+	if !f.suppress {
+		return true, f.consecutive
+	}
+	// Log only when the count is a power of two
+	return f.consecutive&(f.consecutive-1) == 0, f.consecutive
+}
+
+// Success records a success and reports whether it ended a failure streak,
+// along with the length of the streak that just ended
+func (f *FailureTracker) Success() (recovered bool, failures int) {
+	failures = f.consecutive
+	f.consecutive = 0
+	return failures > 0, failures
+}
+
+// Consecutive returns the current number of consecutive failures
+func (f *FailureTracker) Consecutive() int {
+	return f.consecutive
+}
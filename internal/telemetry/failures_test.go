@@ -0,0 +1,67 @@
+package telemetry
+
+import "testing"
+
+func TestFailureTrackerExponentialSuppression(t *testing.T) {
+	f := NewFailureTracker(true)
+
+	var logged []int
+	for i := 0; i < 20; i++ {
+		if shouldLog, count := f.Failure(); shouldLog {
+			logged = append(logged, count)
+		}
+	}
+
+	want := []int{1, 2, 4, 8, 16}
+	if len(logged) != len(want) {
+		t.Fatalf("Failure() logged occurrences %v, want %v", logged, want)
+	}
+	for i := range want {
+		if logged[i] != want[i] {
+			t.Errorf("Failure() logged occurrences %v, want %v", logged, want)
+			break
+		}
+	}
+}
+
+func TestFailureTrackerNoSuppression(t *testing.T) {
+	f := NewFailureTracker(false)
+
+	for i := 1; i <= 5; i++ {
+		shouldLog, count := f.Failure()
+		if !shouldLog {
+			t.Errorf("Failure() #%d shouldLog = false, want true", i)
+		}
+		if count != i {
+			t.Errorf("Failure() count = %d, want %d", count, i)
+		}
+	}
+}
+
+func TestFailureTrackerRecovery(t *testing.T) {
+	f := NewFailureTracker(true)
+
+	if recovered, _ := f.Success(); recovered {
+		t.Error("Success() without prior failures reported recovery")
+	}
+
+	f.Failure()
+	f.Failure()
+	f.Failure()
+
+	recovered, failures := f.Success()
+	if !recovered {
+		t.Error("Success() after failures did not report recovery")
+	}
+	if failures != 3 {
+		t.Errorf("Success() failures = %d, want 3", failures)
+	}
+	if f.Consecutive() != 0 {
+		t.Errorf("Consecutive() after recovery = %d, want 0", f.Consecutive())
+	}
+
+	// Counting restarts after recovery
+	if shouldLog, count := f.Failure(); !shouldLog || count != 1 {
+		t.Errorf("Failure() after recovery = (%v, %d), want (true, 1)", shouldLog, count)
+	}
+}
@@ -0,0 +1,243 @@
+package telemetry
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// MountThreshold gives one mount point its own disk thresholds, so the
+// status weighs mounts by how much their filling up matters: a database
+// volume can go critical at 85% while a scratch mount stays quiet at 99%
+type MountThreshold struct {
+	Path     string
+	Warn     float64
+	Critical float64
+}
+
+// MountUsage is the disk usage of one mount point
+type MountUsage struct {
+	Path        string
+	UsedPercent float64
+}
+
+// ParseMountThresholds parses a comma-separated list of path=warn:critical
+// entries, e.g. "/var/lib/postgresql=75:85,/tmp=99:100"
+func ParseMountThresholds(spec string) ([]MountThreshold, error) {
+	var mounts []MountThreshold
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		path, limits, ok := strings.Cut(entry, "=")
+		warn, critical, ok2 := strings.Cut(limits, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid mount threshold %q: expected path=warn:critical", entry)
+		}
+		m := MountThreshold{Path: path}
+		var err error
+		if m.Warn, err = strconv.ParseFloat(warn, 64); err != nil {
+			return nil, fmt.Errorf("invalid mount threshold %q: %w", entry, err)
+		}
+		if m.Critical, err = strconv.ParseFloat(critical, 64); err != nil {
+			return nil, fmt.Errorf("invalid mount threshold %q: %w", entry, err)
+		}
+		mounts = append(mounts, m)
+	}
+	if err := ValidateMountThresholds(mounts); err != nil {
+		return nil, err
+	}
+	return mounts, nil
+}
+
+// ValidateMountThresholds checks that every path is absolute and listed
+// once, and that its warning threshold does not exceed the critical one
+func ValidateMountThresholds(mounts []MountThreshold) error {
+	seen := make(map[string]bool, len(mounts))
+	for _, m := range mounts {
+		if !filepath.IsAbs(m.Path) {
+			return fmt.Errorf("mount %q must be an absolute path", m.Path)
+		}
+		if seen[m.Path] {
+			return fmt.Errorf("mount %q listed twice", m.Path)
+		}
+		seen[m.Path] = true
+		if m.Warn > m.Critical {
+			return fmt.Errorf("mount %s: warn threshold %v is above critical %v", m.Path, m.Warn, m.Critical)
+		}
+	}
+	return nil
+}
+
+// mountSampler reports the usage of every mount with its own thresholds.
+// The root partition is already sampled by diskSource
+type mountSampler struct {
+	paths []string
+	usage func(path string) (DiskUsage, error)
+}
+
+// newMountSampler samples the mounts in thresholds other than the root
+// through b, or gopsutil if nil
+func newMountSampler(mounts []MountThreshold, b Backend) *mountSampler {
+	if b == nil {
+		b = GopsutilBackend{}
+	}
+	s := &mountSampler{usage: b.Disk}
+	for _, m := range mounts {
+		if m.Path != "/" {
+			s.paths = append(s.paths, m.Path)
+		}
+	}
+	return s
+}
+
+// collect samples every mount; one unreadable mount fails the source so it
+// falls back to the last-known usage instead of silently dropping a volume
+func (s *mountSampler) collect() (func(*Metrics), error) {
+	usages := make([]MountUsage, 0, len(s.paths))
+	for _, path := range s.paths {
+		u, err := s.usage(path)
+		if err != nil {
+			return nil, fmt.Errorf("mount %s: %w", path, err)
+		}
+		usages = append(usages, MountUsage{Path: path, UsedPercent: u.UsedPercent})
+	}
+	return func(m *Metrics) {
+		m.Mounts = usages
+	}, nil
+}
+
+// rootDiskThresholds returns the thresholds for the root partition, which a
+// "/" entry in Mounts overrides
+func rootDiskThresholds(thresholds Thresholds) (warn, critical float64) {
+	for _, m := range thresholds.Mounts {
+		if m.Path == "/" {
+			return m.Warn, m.Critical
+		}
+	}
+	return thresholds.DiskWarn, thresholds.DiskCritical
+}
+
+// mountStatus returns the worst status across mounts, each judged against
+// its own thresholds. Mounts without thresholds are ignored
+func mountStatus(metrics *Metrics, thresholds Thresholds) StatusCode {
+	status := StatusOK
+	for _, u := range metrics.Mounts {
+		for _, m := range thresholds.Mounts {
+			if m.Path != u.Path {
+				continue
+			}
+			if u.UsedPercent >= m.Critical {
+				return StatusCritical
+			}
+			if u.UsedPercent >= m.Warn {
+				status = StatusWarn
+			}
+		}
+	}
+	return status
+}
+
+// DefaultMountExcludes are the mount point and device patterns left out of
+// mount discovery on top of pseudo-filesystems: kernel and runtime trees,
+// container layers, snap loopbacks and the EFI partition
+var DefaultMountExcludes = []string{
+	"/proc", "/sys", "/dev", "/run",
+	"/snap",
+	"/var/lib/docker", "/var/lib/containers", "/var/lib/kubelet",
+	"/boot/efi",
+	"/dev/loop*",
+}
+
+// pseudoFilesystems are filesystem types that hold no real storage
+var pseudoFilesystems = map[string]bool{
+	"autofs": true, "binfmt_misc": true, "bpf": true, "cgroup": true,
+	"cgroup2": true, "configfs": true, "debugfs": true, "devpts": true,
+	"devtmpfs": true, "fuse.lxcfs": true, "fusectl": true, "hugetlbfs": true,
+	"mqueue": true, "nsfs": true, "overlay": true, "proc": true,
+	"pstore": true, "ramfs": true, "rpc_pipefs": true, "securityfs": true,
+	"squashfs": true, "sysfs": true, "tmpfs": true, "tracefs": true,
+}
+
+// DiscoverMounts returns thresholds.Mounts plus every other mounted
+// filesystem, judged against DiskWarn and DiskCritical. Pseudo-filesystems,
+// repeated bind mounts of a device, DefaultMountExcludes and the exclude
+// patterns are left out. Patterns are
+// globs matched against the mount point and the device; a pattern matching
+// a directory also excludes everything mounted beneath it
+func DiscoverMounts(thresholds Thresholds, exclude []string) ([]MountThreshold, error) {
+	parts, err := disk.Partitions(true)
+	if err != nil {
+		return nil, err
+	}
+	patterns := append(append([]string(nil), DefaultMountExcludes...), exclude...)
+	return discoverMounts(parts, thresholds, patterns), nil
+}
+
+// discoverMounts adds the partitions that pass the filters to the
+// configured mount thresholds
+func discoverMounts(parts []disk.PartitionStat, thresholds Thresholds, exclude []string) []MountThreshold {
+	mounts := append([]MountThreshold(nil), thresholds.Mounts...)
+	seen := make(map[string]bool, len(mounts))
+	for _, m := range mounts {
+		seen[m.Path] = true
+	}
+	// A device bind-mounted at several points is watched once, at its first
+	// mount point; the root is sampled by diskSource
+	devices := make(map[string]bool)
+	for _, p := range parts {
+		if p.Mountpoint == "/" {
+			devices[p.Device] = true
+		}
+	}
+	for _, p := range parts {
+		if p.Mountpoint == "/" || seen[p.Mountpoint] || (p.Device != "" && devices[p.Device]) || pseudoFilesystems[p.Fstype] {
+			continue
+		}
+		if mountExcluded(p, exclude) {
+			continue
+		}
+		seen[p.Mountpoint] = true
+		devices[p.Device] = true
+		mounts = append(mounts, MountThreshold{Path: p.Mountpoint, Warn: thresholds.DiskWarn, Critical: thresholds.DiskCritical})
+	}
+	return mounts
+}
+
+// mountExcluded reports whether any pattern matches the partition's mount
+// point, one of its parent directories, or its device
+func mountExcluded(p disk.PartitionStat, exclude []string) bool {
+	for _, pattern := range exclude {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if ok, _ := path.Match(pattern, p.Device); ok {
+			return true
+		}
+		for dir := p.Mountpoint; ; dir = path.Dir(dir) {
+			if ok, _ := path.Match(pattern, dir); ok {
+				return true
+			}
+			if dir == "/" || dir == "." {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// ValidateMountPatterns checks that every exclude pattern is a valid glob
+func ValidateMountPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.TrimSpace(pattern), ""); err != nil {
+			return fmt.Errorf("invalid mount pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
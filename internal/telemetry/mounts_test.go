@@ -0,0 +1,167 @@
+package telemetry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+func TestParseMountThresholds(t *testing.T) {
+	got, err := ParseMountThresholds(" /var/lib/postgresql=75:85, /tmp=99:100,")
+	if err != nil {
+		t.Fatalf("ParseMountThresholds() error = %v", err)
+	}
+	want := []MountThreshold{{"/var/lib/postgresql", 75, 85}, {"/tmp", 99, 100}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("ParseMountThresholds() = %+v, want %+v", got, want)
+	}
+
+	for _, spec := range []string{
+		"/tmp=99",             // Missing critical
+		"/tmp",                // Missing thresholds
+		"tmp=80:90",           // Relative path
+		"/tmp=high:90",        // Not a number
+		"/tmp=95:90",          // Warn above critical
+		"/tmp=80:90,/tmp=1:2", // Duplicate
+	} {
+		if _, err := ParseMountThresholds(spec); err == nil {
+			t.Errorf("ParseMountThresholds(%q) should return error", spec)
+		}
+	}
+}
+
+func TestCalculateStatusMounts(t *testing.T) {
+	thresholds := DefaultThresholds()
+	thresholds.Mounts = []MountThreshold{
+		{Path: "/var/lib/postgresql", Warn: 75, Critical: 85},
+		{Path: "/tmp", Warn: 99, Critical: 100},
+	}
+
+	testCases := []struct {
+		name   string
+		mounts []MountUsage
+		want   StatusCode
+	}{
+		{"All low", []MountUsage{{"/var/lib/postgresql", 50}, {"/tmp", 50}}, StatusOK},
+		{"Scratch mount nearly full", []MountUsage{{"/var/lib/postgresql", 50}, {"/tmp", 99.5}}, StatusWarn},
+		{"Critical mount at 90%", []MountUsage{{"/var/lib/postgresql", 90}, {"/tmp", 10}}, StatusCritical},
+		{"Worst mount wins", []MountUsage{{"/var/lib/postgresql", 80}, {"/tmp", 100}}, StatusCritical},
+		{"Unlisted mount ignored", []MountUsage{{"/mnt/backup", 100}}, StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			metrics := &Metrics{DiskPercent: 10, Mounts: tc.mounts}
+			if got := CalculateStatus(metrics, thresholds); got != tc.want {
+				t.Errorf("CalculateStatus() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRootMountOverridesDiskThresholds(t *testing.T) {
+	thresholds := DefaultThresholds()
+	thresholds.Mounts = []MountThreshold{{Path: "/", Warn: 98, Critical: 99}}
+
+	// 96% is critical under the default 95%, but the root entry relaxes it
+	if got := CalculateStatus(&Metrics{DiskPercent: 96}, thresholds); got != StatusOK {
+		t.Errorf("CalculateStatus(96%%) = %d, want OK", got)
+	}
+	if got := CalculateStatus(&Metrics{DiskPercent: 99}, thresholds); got != StatusCritical {
+		t.Errorf("CalculateStatus(99%%) = %d, want Critical", got)
+	}
+}
+
+func TestEvaluatorSustainsMountBreach(t *testing.T) {
+	thresholds := DefaultThresholds()
+	thresholds.Mounts = []MountThreshold{{Path: "/data", Warn: 80, Critical: 90}}
+	e := NewEvaluator(thresholds, time.Minute)
+	metrics := &Metrics{Mounts: []MountUsage{{"/data", 95}}}
+
+	now := time.Unix(1700000000, 0)
+	if got := e.Evaluate(metrics, now); got != StatusWarn {
+		t.Errorf("Evaluate() at breach start = %d, want Warn", got)
+	}
+	if got := e.Evaluate(metrics, now.Add(time.Minute)); got != StatusCritical {
+		t.Errorf("Evaluate() after sustain = %d, want Critical", got)
+	}
+}
+
+func TestMountSampler(t *testing.T) {
+	s := newMountSampler([]MountThreshold{{Path: "/"}, {Path: "/data"}, {Path: "/tmp"}}, nil)
+	if len(s.paths) != 2 {
+		t.Fatalf("paths = %v, want the root left to diskSource", s.paths)
+	}
+	s.usage = func(path string) (DiskUsage, error) {
+		return DiskUsage{UsedPercent: float64(len(path))}, nil
+	}
+	apply, err := s.collect()
+	if err != nil {
+		t.Fatalf("collect() error: %v", err)
+	}
+	var m Metrics
+	apply(&m)
+	if len(m.Mounts) != 2 || m.Mounts[0] != (MountUsage{"/data", 5}) || m.Mounts[1] != (MountUsage{"/tmp", 4}) {
+		t.Errorf("Mounts = %+v", m.Mounts)
+	}
+
+	s.usage = func(path string) (DiskUsage, error) {
+		return DiskUsage{}, errors.New("stale file handle")
+	}
+	if _, err := s.collect(); err == nil {
+		t.Error("collect() with an unreadable mount should return error")
+	}
+}
+
+func TestDiscoverMounts(t *testing.T) {
+	// A typical Linux host running Docker and snaps
+	parts := []disk.PartitionStat{
+		{Device: "/dev/nvme0n1p2", Mountpoint: "/", Fstype: "ext4"},
+		{Device: "proc", Mountpoint: "/proc", Fstype: "proc"},
+		{Device: "sysfs", Mountpoint: "/sys", Fstype: "sysfs"},
+		{Device: "cgroup2", Mountpoint: "/sys/fs/cgroup", Fstype: "cgroup2"},
+		{Device: "udev", Mountpoint: "/dev", Fstype: "devtmpfs"},
+		{Device: "tmpfs", Mountpoint: "/run", Fstype: "tmpfs"},
+		{Device: "/dev/nvme0n1p1", Mountpoint: "/boot/efi", Fstype: "vfat"},
+		{Device: "/dev/nvme1n1", Mountpoint: "/var/lib/postgresql", Fstype: "xfs"},
+		{Device: "/dev/sdb1", Mountpoint: "/data", Fstype: "ext4"},
+		{Device: "/dev/sdb1", Mountpoint: "/srv/data", Fstype: "ext4"}, // Bind mount
+		{Device: "/dev/nvme0n1p2", Mountpoint: "/var/lib/docker", Fstype: "ext4"},
+		{Device: "overlay", Mountpoint: "/var/lib/docker/overlay2/3f2a/merged", Fstype: "overlay"},
+		{Device: "/dev/loop3", Mountpoint: "/snap/core22/1380", Fstype: "squashfs"},
+		{Device: "/dev/sdc1", Mountpoint: "/mnt/backup-2024", Fstype: "ext4"},
+		{Device: "/dev/nbd0", Mountpoint: "/mnt/scratch", Fstype: "ext4"},
+		{Device: "nas:/export", Mountpoint: "/mnt/nas", Fstype: "nfs4"},
+	}
+	thresholds := DefaultThresholds()
+	thresholds.Mounts = []MountThreshold{{Path: "/var/lib/postgresql", Warn: 75, Critical: 85}}
+	exclude := append(append([]string(nil), DefaultMountExcludes...), "/mnt/backup*", " /dev/nbd* ", "")
+
+	got := discoverMounts(parts, thresholds, exclude)
+	want := []MountThreshold{
+		{"/var/lib/postgresql", 75, 85}, // Listed thresholds are kept
+		{"/data", thresholds.DiskWarn, thresholds.DiskCritical},
+		{"/mnt/nas", thresholds.DiskWarn, thresholds.DiskCritical},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("discoverMounts() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("discoverMounts()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if len(thresholds.Mounts) != 1 {
+		t.Error("discoverMounts() modified the configured mounts")
+	}
+}
+
+func TestValidateMountPatterns(t *testing.T) {
+	if err := ValidateMountPatterns([]string{"/mnt/*", "/dev/sd[a-c]*", ""}); err != nil {
+		t.Errorf("ValidateMountPatterns() error = %v", err)
+	}
+	if err := ValidateMountPatterns([]string{"/mnt/[a-"}); err == nil {
+		t.Error("ValidateMountPatterns() should reject a malformed glob")
+	}
+}
@@ -0,0 +1,100 @@
+package telemetry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+// ValidateInterfaces checks that every named network interface exists, so a
+// typo fails at startup instead of reporting zero throughput forever
+func ValidateInterfaces(names []string) error {
+	counters, err := psnet.IOCounters(true)
+	if err != nil {
+		return fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+	return checkInterfaces(names, counters)
+}
+
+// checkInterfaces reports the first name missing from counters
+func checkInterfaces(names []string, counters []psnet.IOCountersStat) error {
+	known := make(map[string]bool, len(counters))
+	for _, c := range counters {
+		known[c.Name] = true
+	}
+	for _, name := range names {
+		if !known[name] {
+			available := make([]string, 0, len(known))
+			for n := range known {
+				available = append(available, n)
+			}
+			sort.Strings(available)
+			return fmt.Errorf("unknown network interface %q (available: %s)", name, strings.Join(available, ", "))
+		}
+	}
+	return nil
+}
+
+// networkSampler turns the cumulative byte counters of the selected
+// interfaces into rates between consecutive samples
+type networkSampler struct {
+	interfaces map[string]bool
+	counters   func() ([]psnet.IOCountersStat, error)
+	now        func() time.Time
+
+	prevRx, prevTx uint64
+	prevTime       time.Time // Zero until the first sample
+}
+
+// newNetworkSampler samples the named interfaces
+func newNetworkSampler(names []string) *networkSampler {
+	s := &networkSampler{
+		interfaces: make(map[string]bool, len(names)),
+		counters:   func() ([]psnet.IOCountersStat, error) { return psnet.IOCounters(true) },
+		now:        time.Now,
+	}
+	for _, name := range names {
+		s.interfaces[name] = true
+	}
+	return s
+}
+
+// collect samples throughput summed over the selected interfaces
+// The first sample only primes the counters and reports no rates
+func (s *networkSampler) collect() (func(*Metrics), error) {
+	counters, err := s.counters()
+	if err != nil {
+		return nil, err
+	}
+	var rx, tx uint64
+	found := false
+	for _, c := range counters {
+		if s.interfaces[c.Name] {
+			rx += c.BytesRecv
+			tx += c.BytesSent
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("none of the selected network interfaces exist")
+	}
+
+	now := s.now()
+	var rxRate, txRate float64
+	primed := !s.prevTime.IsZero()
+	if elapsed := now.Sub(s.prevTime).Seconds(); primed && elapsed > 0 &&
+		rx >= s.prevRx && tx >= s.prevTx { // Counters reset (e.g. interface re-created) read as idle
+		rxRate = float64(rx-s.prevRx) / elapsed
+		txRate = float64(tx-s.prevTx) / elapsed
+	}
+	s.prevRx, s.prevTx, s.prevTime = rx, tx, now
+
+	return func(m *Metrics) {
+		m.NetRxBytesPerSec = rxRate
+		m.NetTxBytesPerSec = txRate
+		m.HasNetwork = primed
+	}, nil
+}
@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+func TestNetworkSamplerRates(t *testing.T) {
+	counters := []psnet.IOCountersStat{
+		{Name: "eth0", BytesRecv: 1000, BytesSent: 500},
+		{Name: "docker0", BytesRecv: 1 << 30, BytesSent: 1 << 30},
+	}
+	now := time.Unix(1700000000, 0)
+	s := newNetworkSampler([]string{"eth0"})
+	s.counters = func() ([]psnet.IOCountersStat, error) { return counters, nil }
+	s.now = func() time.Time { return now }
+
+	sample := func() Metrics {
+		t.Helper()
+		apply, err := s.collect()
+		if err != nil {
+			t.Fatalf("collect() error: %v", err)
+		}
+		var m Metrics
+		apply(&m)
+		return m
+	}
+
+	if m := sample(); m.HasNetwork {
+		t.Errorf("first sample = %+v, want no rates yet", m)
+	}
+
+	// Only eth0 counts; the bridge traffic must not leak into the rates
+	counters[0].BytesRecv += 4000
+	counters[0].BytesSent += 2000
+	counters[1].BytesRecv += 1 << 20
+	now = now.Add(2 * time.Second)
+	m := sample()
+	if !m.HasNetwork || m.NetRxBytesPerSec != 2000 || m.NetTxBytesPerSec != 1000 {
+		t.Errorf("second sample = rx %v tx %v (has %v), want rx 2000 tx 1000",
+			m.NetRxBytesPerSec, m.NetTxBytesPerSec, m.HasNetwork)
+	}
+
+	// A counter reset reads as idle rather than a huge rate
+	counters[0].BytesRecv = 0
+	now = now.Add(time.Second)
+	if m := sample(); m.NetRxBytesPerSec != 0 || m.NetTxBytesPerSec != 0 {
+		t.Errorf("after reset = rx %v tx %v, want 0", m.NetRxBytesPerSec, m.NetTxBytesPerSec)
+	}
+
+	// The interface vanishing is a source failure
+	counters = counters[1:]
+	if _, err := s.collect(); err == nil {
+		t.Error("collect() with the interface gone succeeded, want error")
+	}
+}
+
+func TestCheckInterfaces(t *testing.T) {
+	counters := []psnet.IOCountersStat{{Name: "lo"}, {Name: "eth0"}}
+	if err := checkInterfaces([]string{"eth0", "lo"}, counters); err != nil {
+		t.Errorf("checkInterfaces(known) = %v, want nil", err)
+	}
+	err := checkInterfaces([]string{"eth0", "eth9"}, counters)
+	if err == nil || !strings.Contains(err.Error(), `"eth9"`) || !strings.Contains(err.Error(), "available: eth0, lo") {
+		t.Errorf("checkInterfaces(unknown) = %v, want error naming eth9 and the available interfaces", err)
+	}
+}
@@ -0,0 +1,189 @@
+package telemetry
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// errTimeout marks a source that did not answer within the collect timeout
+var errTimeout = errors.New("timed out")
+
+// sourceResult carries the outcome of one gopsutil call
+type sourceResult struct {
+	apply func(*Metrics) // Copies the collected values into a Metrics
+	err   error
+}
+
+// source is one independently timed metric collector
+type source struct {
+	name     string
+	collect  func() (func(*Metrics), error)
+	pending  chan sourceResult // Non-nil while a call is still running
+	last     func(*Metrics)    // Last successful result, nil if none yet
+	timeouts *FailureTracker
+
+	// Informational sources have no thresholds, so missing them does not
+	// make the status unknown
+	informational bool
+
+	// Isolated sources that fail with no last-known value are listed as
+	// missing instead of failing the whole Collect. failures rate-limits
+	// the logging of their errors
+	isolated bool
+	failures *FailureTracker
+}
+
+// Collector gathers system metrics with a per-call timeout so a hung source
+// (e.g. disk.Usage on a stuck NFS mount) cannot stall the heartbeat loop.
+// A source that times out falls back to its last-known value and is listed in
+// Metrics.Degraded. A call still running from an earlier round is not
+// restarted, so a permanent hang leaks at most one goroutine per source.
+// Not safe for concurrent use
+type Collector struct {
+	timeout time.Duration
+	sources []*source
+	backend Backend // Reads the built-in metrics and mounts
+}
+
+// NewCollector creates a collector reading the host with gopsutil. A zero
+// timeout waits for every source
+func NewCollector(timeout time.Duration) *Collector {
+	return NewCollectorWithBackend(timeout, GopsutilBackend{})
+}
+
+// NewCollectorWithBackend creates a collector reading the built-in CPU,
+// memory and disk metrics, and any mounts, from b
+func NewCollectorWithBackend(timeout time.Duration, b Backend) *Collector {
+	c := newCollector(timeout, []*source{
+		{name: "cpu", collect: cpuSource(b)},
+		{name: "memory", collect: memorySource(b)},
+		{name: "disk", collect: diskSource(b)},
+	})
+	c.backend = b
+	return c
+}
+
+// newCollector creates a collector over the given sources
+func newCollector(timeout time.Duration, sources []*source) *Collector {
+	for _, s := range sources {
+		s.timeouts = NewFailureTracker(true)
+	}
+	return &Collector{timeout: timeout, sources: sources}
+}
+
+// SetNetworkInterfaces adds a network throughput source sampling only the
+// named interfaces. Validate the names with ValidateInterfaces first. Must be
+// called before the first Collect
+func (c *Collector) SetNetworkInterfaces(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	c.sources = append(c.sources, &source{
+		name:          "network",
+		collect:       newNetworkSampler(names).collect,
+		timeouts:      NewFailureTracker(true),
+		informational: true,
+	})
+}
+
+// SetMounts adds a source sampling the usage of every mount with its own
+// thresholds other than the root. Must be called before the first Collect
+func (c *Collector) SetMounts(mounts []MountThreshold) {
+	sampler := newMountSampler(mounts, c.backend)
+	if len(sampler.paths) == 0 {
+		return
+	}
+	c.sources = append(c.sources, &source{
+		name:     "mounts",
+		collect:  sampler.collect,
+		timeouts: NewFailureTracker(true),
+	})
+}
+
+// Collect gathers current metrics, waiting at most the collect timeout
+// It returns an error only if a source fails outright with no last-known value
+func (c *Collector) Collect() (*Metrics, error) {
+	// Start every source first so they run concurrently
+	for _, s := range c.sources {
+		if s.pending == nil {
+			ch := make(chan sourceResult, 1)
+			go func(collect func() (func(*Metrics), error)) {
+				apply, err := collect()
+				ch <- sourceResult{apply: apply, err: err}
+			}(s.collect)
+			s.pending = ch
+		}
+	}
+
+	var deadline <-chan time.Time
+	if c.timeout > 0 {
+		timer := time.NewTimer(c.timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	metrics := &Metrics{}
+	expired := false
+	for _, s := range c.sources {
+		var res sourceResult
+		ready := false
+		if expired {
+			// Deadline already passed - only take results that are ready
+			select {
+			case res = <-s.pending:
+				ready = true
+			default:
+			}
+		} else {
+			select {
+			case res = <-s.pending:
+				ready = true
+			case <-deadline:
+				expired = true
+				// The source may have finished at the same moment
+				select {
+				case res = <-s.pending:
+					ready = true
+				default:
+				}
+			}
+		}
+		if ready {
+			s.pending = nil
+		} else {
+			res.err = errTimeout
+		}
+
+		if res.err == nil {
+			s.last = res.apply
+			s.timeouts.Success()
+			if s.failures != nil {
+				s.failures.Success()
+			}
+			res.apply(metrics)
+			continue
+		}
+
+		if res.err != errTimeout && !s.isolated && s.last == nil {
+			return nil, res.err
+		}
+		if res.err != errTimeout && s.isolated {
+			if shouldLog, count := s.failures.Failure(); shouldLog {
+				log.Printf("Metric source %s failed (%d consecutive): %v", s.name, count, res.err)
+			}
+		}
+		if res.err == errTimeout {
+			if shouldLog, count := s.timeouts.Failure(); shouldLog {
+				log.Printf("Metric source %s timed out after %v (%d consecutive), using last-known value", s.name, c.timeout, count)
+			}
+		}
+		metrics.Degraded = append(metrics.Degraded, s.name)
+		if s.last != nil {
+			s.last(metrics)
+		} else if !s.informational {
+			metrics.Missing = append(metrics.Missing, s.name)
+		}
+	}
+	return metrics, nil
+}
@@ -0,0 +1,116 @@
+package telemetry
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSource returns a source that sets CPUPercent, blocking while hang is set
+func fakeSource(name string, value *atomic.Int64, hang <-chan struct{}, calls *atomic.Int32) *source {
+	return &source{
+		name: name,
+		collect: func() (func(*Metrics), error) {
+			calls.Add(1)
+			if hang != nil {
+				<-hang
+			}
+			v := float64(value.Load())
+			return func(m *Metrics) { m.CPUPercent = v }, nil
+		},
+	}
+}
+
+func TestCollectorTimeoutUsesLastKnown(t *testing.T) {
+	var value atomic.Int64
+	var calls atomic.Int32
+	value.Store(42)
+
+	hang := make(chan struct{})
+	close(hang) // Not hung for the first round
+	src := fakeSource("cpu", &value, hang, &calls)
+	c := newCollector(50*time.Millisecond, []*source{src})
+
+	m, err := c.Collect()
+	if err != nil || m.CPUPercent != 42 || len(m.Degraded) != 0 {
+		t.Fatalf("Collect() = %+v, %v; want fresh 42", m, err)
+	}
+
+	// Now the source hangs - the collector must return within the timeout
+	stuck := make(chan struct{})
+	defer close(stuck)
+	src.collect = fakeSource("cpu", &value, stuck, &calls).collect
+	value.Store(99)
+
+	start := time.Now()
+	m, err = c.Collect()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Collect() took %v with a hung source", elapsed)
+	}
+	if err != nil || m.CPUPercent != 42 {
+		t.Errorf("Collect() = %+v, %v; want last-known 42", m, err)
+	}
+	if len(m.Degraded) != 1 || m.Degraded[0] != "cpu" {
+		t.Errorf("Collect() Degraded = %v, want [cpu]", m.Degraded)
+	}
+
+	// A second round must not start another call while the first is stuck
+	c.Collect()
+	if got := calls.Load(); got != 2 {
+		t.Errorf("source called %d times, want 2 (hung call not restarted)", got)
+	}
+}
+
+func TestCollectorTimeoutWithoutLastKnown(t *testing.T) {
+	var value atomic.Int64
+	var calls atomic.Int32
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	fast := fakeSource("memory", &value, nil, &calls)
+	fast.collect = func() (func(*Metrics), error) {
+		return func(m *Metrics) { m.RAMPercent = 30 }, nil
+	}
+	c := newCollector(20*time.Millisecond, []*source{fakeSource("disk", &value, stuck, &calls), fast})
+
+	m, err := c.Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	// The hung source is reported unavailable; the healthy one still counts
+	if len(m.Degraded) != 1 || m.Degraded[0] != "disk" || m.RAMPercent != 30 {
+		t.Errorf("Collect() = %+v, want disk degraded and RAM 30", m)
+	}
+	// Its zero usage is a placeholder, so the status cannot be OK
+	if len(m.Missing) != 1 || m.Missing[0] != "disk" {
+		t.Errorf("Collect() Missing = %v, want [disk]", m.Missing)
+	}
+	if got := CalculateStatus(m, DefaultThresholds()); got != StatusUnknown {
+		t.Errorf("CalculateStatus() = %d, want StatusUnknown", got)
+	}
+}
+
+func TestCollectorError(t *testing.T) {
+	failing := &source{
+		name: "disk",
+		collect: func() (func(*Metrics), error) {
+			return nil, errors.New("boom")
+		},
+	}
+	c := newCollector(time.Second, []*source{failing})
+
+	if _, err := c.Collect(); err == nil {
+		t.Error("Collect() should return error when a source fails with no last-known value")
+	}
+}
+
+func TestCollectorRealSources(t *testing.T) {
+	m, err := NewCollector(5 * time.Second).Collect()
+	if err != nil {
+		t.Skipf("metrics unavailable in this environment: %v", err)
+	}
+	if m.RAMTotalBytes == 0 {
+		t.Error("Collect() RAMTotalBytes = 0, want real memory size")
+	}
+}
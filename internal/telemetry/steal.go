@@ -0,0 +1,85 @@
+package telemetry
+
+import (
+	"runtime"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// StealSupported reports whether this platform reports CPU steal time.
+// Elsewhere, e.g. on Windows or macOS, steal is never known
+func StealSupported() bool {
+	return runtime.GOOS == "linux"
+}
+
+// stealSampler turns cumulative CPU times into the share of time stolen by
+// the hypervisor between consecutive samples
+type stealSampler struct {
+	times func() ([]cpu.TimesStat, error)
+
+	prevSteal, prevTotal float64
+	primed               bool
+}
+
+// newStealSampler samples the CPU times of the whole host
+func newStealSampler() *stealSampler {
+	return &stealSampler{
+		times: func() ([]cpu.TimesStat, error) { return cpu.Times(false) },
+	}
+}
+
+// collect samples the steal percentage since the previous sample
+// The first sample only primes the counters and reports no steal
+func (s *stealSampler) collect() (func(*Metrics), error) {
+	times, err := s.times()
+	if err != nil {
+		return nil, err
+	}
+	if len(times) == 0 {
+		return func(*Metrics) {}, nil
+	}
+	t := times[0]
+	// Guest time is already counted in user time
+	total := t.User + t.System + t.Idle + t.Nice + t.Iowait + t.Irq + t.Softirq + t.Steal
+
+	var steal float64
+	primed := s.primed
+	if elapsed := total - s.prevTotal; primed && elapsed > 0 && t.Steal >= s.prevSteal {
+		steal = (t.Steal - s.prevSteal) / elapsed * 100
+	}
+	s.prevSteal, s.prevTotal, s.primed = t.Steal, total, true
+
+	return func(m *Metrics) {
+		m.CPUSteal = steal
+		m.HasSteal = primed
+	}, nil
+}
+
+// SetCPUSteal adds a source sampling CPU steal time, the share of time a
+// virtual machine was ready to run but the hypervisor ran someone else.
+// It does nothing where StealSupported is false. Must be called before the
+// first Collect
+func (c *Collector) SetCPUSteal() {
+	if !StealSupported() {
+		return
+	}
+	c.sources = append(c.sources, &source{
+		name:     "steal",
+		collect:  newStealSampler().collect,
+		timeouts: NewFailureTracker(true),
+	})
+}
+
+// stealStatus judges steal time against its thresholds. Unknown steal and
+// disabled thresholds (0) never trigger
+func stealStatus(metrics *Metrics, thresholds Thresholds) StatusCode {
+	switch {
+	case !metrics.HasSteal:
+		return StatusOK
+	case thresholds.StealCritical > 0 && metrics.CPUSteal >= thresholds.StealCritical:
+		return StatusCritical
+	case thresholds.StealWarn > 0 && metrics.CPUSteal >= thresholds.StealWarn:
+		return StatusWarn
+	}
+	return StatusOK
+}
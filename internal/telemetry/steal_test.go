@@ -0,0 +1,64 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+func TestStealSampler(t *testing.T) {
+	var times cpu.TimesStat
+	s := newStealSampler()
+	s.times = func() ([]cpu.TimesStat, error) { return []cpu.TimesStat{times}, nil }
+	sample := func() *Metrics {
+		t.Helper()
+		apply, err := s.collect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := &Metrics{}
+		apply(m)
+		return m
+	}
+
+	times = cpu.TimesStat{User: 100, Idle: 800, Steal: 100, Guest: 50}
+	if m := sample(); m.HasSteal {
+		t.Errorf("first sample HasSteal = true, want false until primed")
+	}
+
+	// 20 of 100 seconds stolen; guest time is part of user time
+	times = cpu.TimesStat{User: 140, Idle: 840, Steal: 120, Guest: 90}
+	if m := sample(); !m.HasSteal || m.CPUSteal != 20 {
+		t.Errorf("CPUSteal = %v (known %v), want 20", m.CPUSteal, m.HasSteal)
+	}
+
+	// Counters going backwards read as no steal
+	times = cpu.TimesStat{User: 150, Idle: 900, Steal: 10}
+	if m := sample(); m.CPUSteal != 0 {
+		t.Errorf("CPUSteal after a counter reset = %v, want 0", m.CPUSteal)
+	}
+}
+
+func TestCalculateStatusSteal(t *testing.T) {
+	thresholds := DefaultThresholds()
+	testCases := []struct {
+		name    string
+		metrics Metrics
+		want    StatusCode
+	}{
+		{"unknown steal", Metrics{CPUSteal: 50}, StatusOK},
+		{"low steal", Metrics{CPUSteal: 5, HasSteal: true}, StatusOK},
+		{"warn steal", Metrics{CPUSteal: 10, HasSteal: true}, StatusWarn},
+		{"critical steal", Metrics{CPUSteal: 30, HasSteal: true}, StatusCritical},
+	}
+	for _, tc := range testCases {
+		if got := CalculateStatus(&tc.metrics, thresholds); got != tc.want {
+			t.Errorf("%s: CalculateStatus() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	thresholds.StealWarn, thresholds.StealCritical = 0, 0
+	if got := CalculateStatus(&Metrics{CPUSteal: 90, HasSteal: true}, thresholds); got != StatusOK {
+		t.Errorf("CalculateStatus() with steal thresholds disabled = %v, want OK", got)
+	}
+}
@@ -0,0 +1,94 @@
+package telemetry
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WriteTextfile writes the local node's metrics and status to path in the
+// Prometheus text format, for the node_exporter textfile collector. The file
+// is written under a temporary name in the same directory and renamed, so a
+// scrape never reads it half-written; the temporary name lacks the .prom
+// suffix node_exporter looks for
+func WriteTextfile(path string, metrics *Metrics, status StatusCode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".pulsecheck-textfile-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if err := FormatPrometheus(tmp, metrics, status); err != nil {
+		tmp.Close()
+		return err
+	}
+	// CreateTemp uses 0600, but node_exporter often runs as another user
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// FormatPrometheus writes metrics and status in the Prometheus text format.
+// Absolute sizes are omitted while unknown, and throughput until two
+// samples have been taken
+func FormatPrometheus(w io.Writer, metrics *Metrics, status StatusCode) error {
+	bw := bufio.NewWriter(w)
+	gauge := func(name, help string, value float64) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatValue(value))
+	}
+
+	gauge("pulsecheck_status", "Local status code (0 OK, 1 WARN, 2 CRITICAL, 3 UNKNOWN).", float64(status))
+	gauge("pulsecheck_cpu_percent", "CPU utilization in percent.", metrics.CPUPercent)
+	gauge("pulsecheck_ram_percent", "Memory used in percent.", metrics.RAMPercent)
+	gauge("pulsecheck_disk_percent", "Root partition used in percent.", metrics.DiskPercent)
+	if metrics.RAMTotalBytes > 0 {
+		gauge("pulsecheck_ram_total_bytes", "Total memory in bytes.", float64(metrics.RAMTotalBytes))
+		gauge("pulsecheck_ram_available_bytes", "Memory available for new allocations in bytes.", float64(metrics.RAMFreeBytes))
+	}
+	if metrics.DiskTotalBytes > 0 {
+		gauge("pulsecheck_disk_total_bytes", "Root partition size in bytes.", float64(metrics.DiskTotalBytes))
+		gauge("pulsecheck_disk_free_bytes", "Root partition free space in bytes.", float64(metrics.DiskFreeBytes))
+	}
+	if metrics.HasNetwork {
+		gauge("pulsecheck_network_receive_bytes_per_second", "Receive throughput of the selected interfaces.", metrics.NetRxBytesPerSec)
+		gauge("pulsecheck_network_transmit_bytes_per_second", "Transmit throughput of the selected interfaces.", metrics.NetTxBytesPerSec)
+	}
+	if metrics.HasSteal {
+		gauge("pulsecheck_cpu_steal_percent", "CPU time stolen by the hypervisor in percent.", metrics.CPUSteal)
+	}
+	if len(metrics.Mounts) > 0 {
+		fmt.Fprintf(bw, "# HELP pulsecheck_mount_used_percent Mount point used in percent.\n# TYPE pulsecheck_mount_used_percent gauge\n")
+		for _, m := range metrics.Mounts {
+			fmt.Fprintf(bw, "pulsecheck_mount_used_percent{mount=%s} %s\n", quoteLabel(m.Path), formatValue(m.UsedPercent))
+		}
+	}
+	if len(metrics.Degraded) > 0 {
+		fmt.Fprintf(bw, "# HELP pulsecheck_source_degraded Metric sources reporting last-known values after a timeout or failure.\n# TYPE pulsecheck_source_degraded gauge\n")
+		for _, source := range metrics.Degraded {
+			fmt.Fprintf(bw, "pulsecheck_source_degraded{source=%s} 1\n", quoteLabel(source))
+		}
+	}
+	return bw.Flush()
+}
+
+// formatValue formats a sample value as Prometheus expects
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// labelEscaper escapes label values as the text format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabel returns a quoted, escaped label value
+func quoteLabel(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatPrometheus(t *testing.T) {
+	metrics := &Metrics{
+		CPUPercent:     42.5,
+		RAMPercent:     60,
+		DiskPercent:    70,
+		DiskTotalBytes: 1000,
+		DiskFreeBytes:  300,
+		Mounts:         []MountUsage{{Path: `/mnt/"odd"`, UsedPercent: 12}},
+		Degraded:       []string{"disk"},
+	}
+	var buf bytes.Buffer
+	if err := FormatPrometheus(&buf, metrics, StatusWarn); err != nil {
+		t.Fatalf("FormatPrometheus() error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE pulsecheck_status gauge\npulsecheck_status 1\n",
+		"pulsecheck_cpu_percent 42.5\n",
+		"pulsecheck_disk_free_bytes 300\n",
+		`pulsecheck_mount_used_percent{mount="/mnt/\"odd\""} 12` + "\n",
+		`pulsecheck_source_degraded{source="disk"} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	// Unknown values are omitted rather than reported as zero
+	for _, absent := range []string{"pulsecheck_ram_total_bytes", "pulsecheck_network_receive"} {
+		if strings.Contains(out, absent) {
+			t.Errorf("output contains %s without data:\n%s", absent, out)
+		}
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pulsecheck.prom")
+	for _, status := range []StatusCode{StatusOK, StatusCritical} {
+		if err := WriteTextfile(path, &Metrics{CPUPercent: 1}, status); err != nil {
+			t.Fatalf("WriteTextfile() error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "pulsecheck_status 2\n") {
+		t.Errorf("file does not hold the latest sample:\n%s", data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Errorf("file mode = %v, want 0644 for node_exporter", info.Mode().Perm())
+	}
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the textfile", len(entries))
+	}
+
+	if err := WriteTextfile(filepath.Join(dir, "missing", "x.prom"), &Metrics{}, StatusOK); err == nil {
+		t.Error("WriteTextfile() into a missing directory should return error")
+	}
+}
@@ -0,0 +1,153 @@
+package pulsecheck
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/protocol"
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+	"github.com/rafaelmarinho/pulsecheck/internal/telemetry"
+)
+
+// loadGenTick is how often the load generator tops up its sends to the
+// target rate
+const loadGenTick = 5 * time.Millisecond
+
+// MaxLoadGenNodes bounds LoadGenConfig.Nodes: each virtual node advertises
+// its own listen port so the target keys it separately. The packets carry
+// protocol.FlagNoLearn, so the target records these nodes without learning
+// them as peers
+const MaxLoadGenNodes = 65535
+
+// LoadGenConfig describes the synthetic heartbeat traffic LoadGen sends
+type LoadGenConfig struct {
+	Target   string        // Collector address, e.g. "10.0.0.5:9999"
+	Nodes    int           // Virtual nodes, each with its own UUID and advertised port
+	Rate     float64       // Packets per second across all nodes
+	Duration time.Duration // How long to send for
+
+	// Must match the target's settings or every packet is dropped
+	NoChecksum bool
+	Magic      uint16 // 0 uses the default
+}
+
+// LoadGenResult is what LoadGen achieved
+type LoadGenResult struct {
+	Sent       uint64
+	SendErrors uint64
+	Elapsed    time.Duration
+}
+
+// Rate returns the achieved send rate in packets per second
+func (r LoadGenResult) Rate() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Sent) / r.Elapsed.Seconds()
+}
+
+// LoadGen blasts valid heartbeats from cfg.Nodes virtual nodes at the
+// target, cycling through them at cfg.Rate packets per second, to find the
+// load at which a collector's worker pool starts dropping packets. Statuses
+// vary across nodes: every fifth is WARN and every twentieth CRITICAL. It
+// stops after cfg.Duration or when ctx is cancelled
+func LoadGen(ctx context.Context, cfg LoadGenConfig) (LoadGenResult, error) {
+	if cfg.Nodes <= 0 || cfg.Nodes > MaxLoadGenNodes {
+		return LoadGenResult{}, fmt.Errorf("nodes must be between 1 and %d", MaxLoadGenNodes)
+	}
+	if cfg.Rate <= 0 {
+		return LoadGenResult{}, errors.New("rate must be positive")
+	}
+	if cfg.Duration <= 0 {
+		return LoadGenResult{}, errors.New("duration must be positive")
+	}
+	addr, err := registry.ResolvePeerAddr(cfg.Target)
+	if err != nil {
+		return LoadGenResult{}, fmt.Errorf("invalid target: %w", err)
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return LoadGenResult{}, err
+	}
+	defer conn.Close()
+
+	// A random prefix keeps two generators, or a rerun, from colliding
+	var prefix [12]byte
+	if _, err := rand.Read(prefix[:]); err != nil {
+		return LoadGenResult{}, fmt.Errorf("generate node UUIDs: %w", err)
+	}
+	// Each virtual node sends once per cycle through all of them
+	interval := protocol.IntervalUnits(time.Duration(float64(cfg.Nodes) / cfg.Rate * float64(time.Second)))
+	opts := protocol.Options{NoChecksum: cfg.NoChecksum, Magic: cfg.Magic}
+	buf := make([]byte, protocol.MaxPacketSize)
+	pkt := protocol.NewPacket([16]byte{}, 0)
+	pkt.Interval = interval
+	// The advertised ports are made up, so the target must not send to them
+	pkt.Flags = protocol.FlagNoLearn
+
+	var result LoadGenResult
+	var attempted uint64
+	start := time.Now()
+	ticker := time.NewTicker(loadGenTick)
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			result.Elapsed = time.Since(start)
+			return result, nil
+		case now = <-ticker.C:
+		}
+		elapsed := now.Sub(start)
+		if elapsed > cfg.Duration {
+			elapsed = cfg.Duration
+		}
+
+		due := uint64(elapsed.Seconds() * cfg.Rate)
+		for ; attempted < due; attempted++ {
+			i := int(attempted % uint64(cfg.Nodes))
+			pkt.NodeUUID = loadGenUUID(prefix, i)
+			pkt.StatusCode = loadGenStatus(i)
+			pkt.ListenPort = uint16(i + 1)
+			pkt.Timestamp = time.Now().UnixNano()
+			if err := pkt.EncodeIntoWith(buf, opts); err != nil {
+				return result, err
+			}
+			if _, err := conn.Write(buf[:pkt.Size()]); err != nil {
+				result.SendErrors++
+				continue
+			}
+			result.Sent++
+		}
+
+		if elapsed == cfg.Duration {
+			result.Elapsed = time.Since(start)
+			return result, nil
+		}
+	}
+}
+
+// loadGenUUID returns the UUID of virtual node i
+func loadGenUUID(prefix [12]byte, i int) [16]byte {
+	var uuid [16]byte
+	copy(uuid[:], prefix[:])
+	binary.BigEndian.PutUint32(uuid[12:], uint32(i))
+	return uuid
+}
+
+// loadGenStatus returns the status code virtual node i reports
+func loadGenStatus(i int) uint8 {
+	switch {
+	case i%20 == 0:
+		return uint8(telemetry.StatusCritical)
+	case i%5 == 0:
+		return uint8(telemetry.StatusWarn)
+	default:
+		return uint8(telemetry.StatusOK)
+	}
+}
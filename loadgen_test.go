@@ -0,0 +1,70 @@
+package pulsecheck
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+func TestLoadGen(t *testing.T) {
+	monitor := registry.NewMonitor()
+	target, err := registry.NewUDPNode(0, [16]byte{1}, monitor)
+	if err != nil {
+		t.Fatalf("NewUDPNode() error = %v", err)
+	}
+	go target.Start()
+	defer target.Stop()
+
+	result, err := LoadGen(context.Background(), LoadGenConfig{
+		Target:   net.JoinHostPort("127.0.0.1", strconv.Itoa(target.Port())),
+		Nodes:    40,
+		Rate:     200, // One packet per tick, so the small worker queue keeps up
+		Duration: 300 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("LoadGen() error = %v", err)
+	}
+	if result.Sent < 50 || result.SendErrors != 0 {
+		t.Errorf("LoadGen() = %+v, want about 60 sent without errors", result)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for monitor.GetNodeCount() < 40 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := monitor.GetNodeCount(); got != 40 {
+		t.Fatalf("target saw %d nodes, want 40 (stats: %+v)", got, target.Stats())
+	}
+	summary := monitor.Summarize()
+	if summary.Critical != 2 || summary.Warn != 6 {
+		t.Errorf("Summarize() = %+v, want 2 CRITICAL and 6 WARN", summary)
+	}
+	if stats := target.Stats(); stats.ChecksumFailures != 0 || stats.BadMagic != 0 {
+		t.Errorf("target rejected generated packets: %+v", stats)
+	}
+	// The virtual nodes' ports are made up, so the target must not send to them
+	if peers := target.Peers(); len(peers) != 0 {
+		t.Errorf("target learned %d virtual nodes as peers: %v", len(peers), peers)
+	}
+}
+
+func TestLoadGenInvalidConfig(t *testing.T) {
+	valid := LoadGenConfig{Target: "127.0.0.1:9999", Nodes: 10, Rate: 100, Duration: time.Second}
+	for _, mutate := range []func(*LoadGenConfig){
+		func(c *LoadGenConfig) { c.Nodes = 0 },
+		func(c *LoadGenConfig) { c.Nodes = MaxLoadGenNodes + 1 },
+		func(c *LoadGenConfig) { c.Rate = 0 },
+		func(c *LoadGenConfig) { c.Duration = 0 },
+		func(c *LoadGenConfig) { c.Target = "no-port" },
+	} {
+		cfg := valid
+		mutate(&cfg)
+		if _, err := LoadGen(context.Background(), cfg); err == nil {
+			t.Errorf("LoadGen(%+v) should return error", cfg)
+		}
+	}
+}
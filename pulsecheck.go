@@ -0,0 +1,1790 @@
+// Package pulsecheck exposes an embeddable PulseCheck node that wires together
+// telemetry collection, the UDP heartbeat mesh, the reaper, and the status reporter
+package pulsecheck
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+	"github.com/rafaelmarinho/pulsecheck/internal/display"
+	"github.com/rafaelmarinho/pulsecheck/internal/fifo"
+	"github.com/rafaelmarinho/pulsecheck/internal/probe"
+	"github.com/rafaelmarinho/pulsecheck/internal/protocol"
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+	"github.com/rafaelmarinho/pulsecheck/internal/relay"
+	"github.com/rafaelmarinho/pulsecheck/internal/store"
+	"github.com/rafaelmarinho/pulsecheck/internal/telemetry"
+)
+
+// Monitor is the node registry tracking known peers
+type Monitor = registry.Monitor
+
+// NodeInfo is the state tracked for a single peer
+type NodeInfo = registry.NodeInfo
+
+// Summary aggregates peer counts by status
+type Summary = registry.Summary
+
+// NetworkStats is a snapshot of heartbeat traffic counters
+type NetworkStats = registry.NetworkStats
+
+// FIFOStats counts the records read from Config.FIFOPath
+type FIFOStats = fifo.Stats
+
+// TrafficHealth is the node's assessment of its own monitoring traffic
+type TrafficHealth = registry.TrafficHealth
+
+// ReaperStats counts the nodes removed by the reaper
+type ReaperStats = registry.ReaperStats
+
+// BreakerStats is the state of the push relay's circuit breaker
+type BreakerStats = relay.BreakerStats
+
+// NodeSnapshot is a point-in-time dump of a node and its view of the
+// cluster, for attaching to incident tickets
+type NodeSnapshot struct {
+	UUID    string        `json:"uuid"`
+	Port    int           `json:"port"`
+	Peers   []string      `json:"peers"`
+	Network NetworkStats  `json:"network"`
+	FIFO    *FIFOStats    `json:"fifo,omitempty"` // nil unless FIFOPath is set
+	Self    TrafficHealth `json:"self"`
+	registry.Snapshot
+}
+
+// ProbeTarget is an agentless endpoint polled over HTTP or TCP
+type ProbeTarget = probe.Target
+
+// Thresholds defines warning and critical thresholds for telemetry
+type Thresholds = telemetry.Thresholds
+
+// MetricsBackend reads the built-in CPU, memory and disk metrics
+type MetricsBackend = telemetry.Backend
+
+// MemoryUsage is a memory reading from a MetricsBackend
+type MemoryUsage = telemetry.MemoryUsage
+
+// DiskUsage is a filesystem reading from a MetricsBackend
+type DiskUsage = telemetry.DiskUsage
+
+// MetricThreshold gives a custom metric its thresholds and direction
+type MetricThreshold = telemetry.MetricThreshold
+
+// Direction says which way a custom metric gets worse
+type Direction = telemetry.Direction
+
+// Custom metric directions
+const (
+	HigherIsWorse = telemetry.HigherIsWorse
+	LowerIsWorse  = telemetry.LowerIsWorse
+)
+
+// HistoryRecord is a persisted node snapshot or registry event
+type HistoryRecord = store.Record
+
+// ReportFormatter renders periodic status reports, see RegisterFormatter
+type ReportFormatter = display.ReportFormatter
+
+// StatusReport is the data a ReportFormatter renders
+type StatusReport = display.StatusReport
+
+// RegisterFormatter makes a custom formatter selectable by name with
+// Config.Output. It panics if the name is already registered
+func RegisterFormatter(name string, f ReportFormatter) {
+	display.RegisterFormatter(name, f)
+}
+
+// SeverityPolicy decides which statuses are actionable and how they map to exit codes
+type SeverityPolicy = registry.SeverityPolicy
+
+// Location places a node on a map by coordinates, a datacenter code, or both
+type Location = registry.Location
+
+// Kubernetes identifies the pod a node runs in
+type Kubernetes = registry.Kubernetes
+
+// KubernetesFromEnv reads the pod identity from POD_NAME, POD_NAMESPACE and
+// NODE_NAME, leaving unset ones empty
+func KubernetesFromEnv() Kubernetes {
+	return registry.KubernetesFromEnv()
+}
+
+// ParseExitCodes overrides the exit codes of p from condition=code pairs,
+// e.g. "any-warn=0,below-min-nodes=3"; conditions left out keep their code
+func ParseExitCodes(spec string, p SeverityPolicy) (SeverityPolicy, error) {
+	return registry.ParseExitCodes(spec, p)
+}
+
+// ParseLocation parses "datacenter=fra1,lat=50.11,lon=8.68"; every field is optional
+func ParseLocation(s string) (Location, error) {
+	return registry.ParseLocation(s)
+}
+
+// Failure detector modes for the reaper
+const (
+	FailureDetectorTimeout = "timeout"
+	FailureDetectorPhi     = "phi"
+)
+
+// MaxCriticalRetransmit bounds Config.CriticalRetransmit
+const MaxCriticalRetransmit = 5
+
+// MaxPriority bounds Config.Priority; the packet field is one byte wide
+const MaxPriority = 255
+
+// Config holds the settings for a Node
+type Config struct {
+	Port                   int           // UDP port to listen on (0 picks an ephemeral port)
+	NodeID                 string        // Unique identifier (default: hostname)
+	IdentityFile           string        // Keep the node UUID in this file across restarts; it wins over NodeID once written (optional)
+	SeedNode               string        // Seed node address for peer discovery (optional)
+	Peers                  []string      // Addresses to heartbeat from the start, in addition to discovered peers
+	StaticPeers            bool          // Only exchange heartbeats with Peers and Collectors; no discovery
+	PeersPerHeartbeat      int           // Send each heartbeat to at most this many peers, rotating through them (0 sends to all)
+	MaxPeers               int           // Forget the least recently heard learned peer beyond this many (0 is unbounded)
+	MaxNodes               int           // Evict the least recently seen node beyond about this many tracked (0 is unbounded)
+	DSCP                   int           // Mark outgoing packets with this DSCP value, 0-63 (0 leaves them unmarked)
+	SocketReadBuffer       int           // Request this many bytes of UDP socket receive buffer (0 keeps the OS default)
+	HeartbeatInterval      time.Duration // Time between heartbeats
+	TelemetryInterval      time.Duration // Time between telemetry samples (0 samples on every heartbeat)
+	CollectTimeout         time.Duration // Max wait per metric source before using its last-known value (0 waits)
+	Timeout                time.Duration // Time before marking a node offline
+	FailureDetector        string        // FailureDetectorTimeout or FailureDetectorPhi
+	PhiThreshold           float64       // Phi above which a node is considered failed
+	ReapBurst              int           // Expired nodes the reaper removes per shard lock (0 is registry.DefaultReapBurst)
+	Thresholds             Thresholds    // Telemetry thresholds for the local status code
+	CriticalSustain        time.Duration // Time a metric must stay critical before reporting CRITICAL (0 is immediate)
+	SuppressRepeatedErrors bool          // Log repeated collection failures exponentially
+	ReportInterval         time.Duration // Time between periodic reports (0 disables reporting)
+	ReportOnChange         time.Duration // Report at once when a node changes status or leaves, at most this often (0 disables)
+	JSONOutput             bool          // Report in JSON instead of human-readable format
+	JSONCompact            bool          // Single-line JSON instead of indented
+	JSONFull               bool          // Always include telemetry fields in JSON, even when zero
+	GroupBy                string        // Partition reports by "subnet" (empty for a flat list)
+	TimeFormat             string        // Report timestamps as rfc3339 (default), unix, unixmilli or a Go layout
+	MaxDisplayAge          time.Duration // Report nodes older than this in a separate stale section (0 disables)
+	ListNoTelemetry        bool          // List nodes that heartbeat but have never sent telemetry in reports
+	UnbufferedReports      bool          // Write reports as they are rendered instead of in a single write per report
+	NoChecksum             bool          // Skip CRC32 on packets (must match all peers)
+	PacketMagic            uint16        // Prefix identifying our packets on a shared port (0 uses the default; must match all peers)
+	RequireMagic           bool          // Drop legacy v1/v2 packets, which carry no magic
+	WireVersion            int           // Packet version sent; raise to protocol.Version once every peer decodes it
+	IOTimeout              time.Duration // Socket read/write deadline (0 uses the default)
+	Workers                int           // Packet processing workers (0 follows GOMAXPROCS and cgroup CPU limits)
+	MaxWorkers             int           // Grow the workers up to this while the packet queue is nearly full (0 keeps them fixed)
+	ProbeTargets           []ProbeTarget // Agentless targets to poll (optional)
+	ProbeInterval          time.Duration // Time between probe rounds
+	ProbeTimeout           time.Duration // Per-probe timeout
+	ProbeWarnLatency       time.Duration // Probes slower than this report WARN (0 disables)
+	FIFOPath               string        // Read JSON metric records from a named pipe at this path, created if missing (optional)
+
+	// Silences maps addresses to maintenance windows starting at New. Silenced
+	// nodes report as MAINTENANCE and are excluded from the cluster status
+	Silences map[string]time.Duration
+
+	// Dependencies maps addresses to the nodes they depend on. A failing
+	// node with a dependency that is CRITICAL, or was reaped, is reported
+	// with it and excluded from the cluster status, so one root failure
+	// raises one alert. Cycles fail New
+	Dependencies map[string][]string
+
+	// Severity decides which statuses are actionable and the exit codes
+	// returned by ExitCode for one-shot checks
+	Severity SeverityPolicy
+
+	// Collectors lists the addresses of the other collectors in a
+	// primary/standby group. All of them receive every heartbeat, but only
+	// the live one with the lowest UUID reports; the others take over once
+	// its lease (LeaseTTL, default Timeout) lapses
+	Collectors []string
+	LeaseTTL   time.Duration
+
+	// StorePath enables persistent history: registry events and a snapshot
+	// of every node each StoreInterval are written to an embedded database
+	// in this file, queryable with History and on HistoryPath. Every
+	// HistorySegment the records are closed into a segment that is gzipped
+	// in the background, and segments older than HistoryRetention are
+	// deleted (0 disables either)
+	StorePath        string
+	StoreInterval    time.Duration
+	HistorySegment   time.Duration
+	HistoryRetention time.Duration
+
+	// SigningKey signs outgoing heartbeats with Ed25519. When TrustedKeys is
+	// set, only packets signed by the key listed for the sender's UUID are
+	// accepted; the rest are dropped and counted in NetworkStats
+	SigningKey  ed25519.PrivateKey
+	TrustedKeys map[[16]byte]ed25519.PublicKey
+
+	// SignReports also signs every report written to stdout with
+	// SigningKey, followed by a signature line, so stored reports are
+	// tamper-evident. See VerifyReports
+	SignReports bool
+
+	// Anonymize replaces node addresses, federation sources, subnet
+	// names, datacenters and Kubernetes names in stdout reports,
+	// WriteTopology and the dashboard (see Anonymizer) with aliases such as
+	// node-a1b2 that are stable for the life of the Node, so a report can
+	// be shared without leaking internal IPs. The status endpoint and the
+	// registry keep the real addresses
+	Anonymize bool
+
+	// PushURL relays this node's status and telemetry over HTTP on every
+	// heartbeat to a collector serving IngestAddr (e.g. ":8080"), for
+	// networks that block UDP between hosts. Pushed reports are recorded
+	// exactly like heartbeats. IngestAddr serves only the endpoints that
+	// write, /ingest, /silence and /chaos, and is off unless set
+	PushURL    string
+	IngestAddr string
+
+	// IngestToken is a shared secret sent as a bearer token with every
+	// push. A collector serving IngestAddr merges a report only if it
+	// carries IngestToken, or is signed by the TrustedKeys entry of its
+	// node UUID; pushers sign with SigningKey. It needs one or both.
+	// SilencePath is only served with IngestToken
+	IngestToken string
+
+	// StatusAddr serves the read-only HTTP endpoints on their own
+	// listener: /status, /status/nodes, /config, /healthz, /readyz and
+	// /events/stream. Exposing them, e.g. to probes, never allows writes
+	StatusAddr string
+
+	// PushBreakerThreshold consecutive push failures open a circuit
+	// breaker that skips pushes for PushBreakerCooldown, then lets one
+	// trial through to test recovery (0 disables the breaker)
+	PushBreakerThreshold int
+	PushBreakerCooldown  time.Duration
+
+	// PushPeerView lists the peers this node sees in pushed reports, so
+	// the collector can count how many peers see each node. Views over
+	// relay.MaxPeerView peers are not sent. Off by default, as collectors
+	// older than peer views refuse reports over 4KB
+	PushPeerView bool
+
+	// FederateFrom lists the status URLs of other collectors, e.g.
+	// "http://collector-b:8080/status", whose views are pulled every
+	// heartbeat interval and merged into this one. A node known to several
+	// collectors takes the freshest sighting. Serving StatusAddr exposes
+	// this node's own view on /status for the others to pull
+	FederateFrom []string
+
+	// PacketDedupTTL drops a heartbeat whose UUID and timestamp match one
+	// received less than this ago, e.g. a retransmitted or relayed copy,
+	// before it updates the monitor. PacketDedupSize bounds how many are
+	// remembered. Either set to 0 disables the cache
+	PacketDedupTTL  time.Duration
+	PacketDedupSize int
+
+	// DuplicateWindow flags a node UUID reported from two addresses less
+	// than this apart, e.g. hosts cloned from one image (0 disables)
+	DuplicateWindow time.Duration
+
+	// ConditionalHeartbeat broadcasts only when the local status changes,
+	// plus a keepalive every KeepaliveInterval (default Timeout/2) so peers
+	// do not reap the node. The keepalive, plus one heartbeat interval of
+	// lag, must stay under the reaper timeout of every peer
+	ConditionalHeartbeat bool
+	KeepaliveInterval    time.Duration
+
+	// Output selects a registered report formatter by name ("human",
+	// "json", "json-compact", "oneline" or a custom one), overriding JSONOutput.
+	// Reports are rendered with a text/template over StatusReport when
+	// Output is "template" or a template is given, either inline as
+	// Template or read from TemplateFile
+	Output       string
+	Template     string
+	TemplateFile string
+
+	// ClockSkewTolerance is how far ahead of ours a peer's clock may be.
+	// Later packet timestamps are clamped; the measured skew is reported
+	// as the node's ClockSkew
+	ClockSkewTolerance time.Duration
+
+	// NetInterfaces enables network throughput telemetry for the named
+	// interfaces (e.g. "eth0"), summed and reported as the local node's
+	// receive and transmit rates. Unknown names fail New
+	NetInterfaces []string
+
+	// CPUSteal samples the share of CPU time stolen by the hypervisor and
+	// judges it against Thresholds.StealWarn and StealCritical, to catch
+	// noisy neighbours on shared VMs. Only Linux reports steal; elsewhere it
+	// is logged as unavailable and ignored
+	CPUSteal bool
+
+	// HeartbeatBudget caps the heartbeat packets this node sends per second
+	// (0 disables). Every heartbeat goes to every peer, so as the mesh
+	// grows the interval stretches from HeartbeatInterval up to
+	// MaxHeartbeatInterval (default Timeout/3) to stay within the budget.
+	// Slower heartbeats mean slower failure detection; MaxHeartbeatInterval
+	// bounds it and must stay under the Timeout of every peer
+	HeartbeatBudget      float64
+	MaxHeartbeatInterval time.Duration
+
+	// RecomputeStatus re-evaluates the status of every node whose
+	// telemetry reaches this collector (via the HTTP push relay) against
+	// Thresholds. Nodes whose self-reported status differs, e.g. because
+	// they run stale thresholds, are reported with both
+	RecomputeStatus bool
+
+	// EventLog receives every registry event (joined, status_changed,
+	// left, duplicate_identity) as one JSON line, e.g. os.Stderr for log
+	// pipelines. Nil disables it
+	EventLog io.Writer
+
+	// EventStreamClients bounds the clients of the Server-Sent Events
+	// endpoint (GET /events/stream) served on StatusAddr, which pushes
+	// every registry event as it happens (0 disables the endpoint)
+	EventStreamClients int
+
+	// TextfileOut receives the local node's metrics in the Prometheus text
+	// format on every heartbeat, for the node_exporter textfile collector.
+	// The file is replaced atomically. Empty disables it
+	TextfileOut string
+
+	// EnableChaos allows fault injection for testing alerting and failure
+	// detection against a live cluster: a forced status, dropped sends
+	// and paused heartbeats, via Node.InjectStatus and friends or POST
+	// /chaos on IngestAddr with IngestToken. Never enable it in production
+	EnableChaos bool
+
+	// DiskAllMounts watches every mounted filesystem found at New, not just
+	// the root and Thresholds.Mounts; unlisted mounts use the disk
+	// thresholds. Pseudo-filesystems, telemetry.DefaultMountExcludes and
+	// DiskExclude are left out
+	DiskAllMounts bool
+
+	// DiskExclude holds extra glob patterns for mount points and devices
+	// that DiskAllMounts skips, e.g. "/mnt/backup*" or "/dev/nbd*"
+	DiskExclude []string
+
+	// MetricSources samples custom metrics alongside the built-in ones,
+	// keyed by name. Each is judged against the Thresholds.Metrics entry
+	// of the same name, which may be lower is worse, e.g. remaining
+	// battery. Sources are timed out like the built-in ones. A source
+	// that fails or returns NaN or an infinity makes only its own metric
+	// unknown
+	MetricSources map[string]func() (float64, error)
+
+	// MetricsBackend replaces gopsutil for the built-in CPU, memory and
+	// disk metrics, e.g. with cgroup limits inside a container or fixed
+	// values in tests. Its calls are timed out like the other sources
+	// (nil reads the host)
+	MetricsBackend MetricsBackend
+
+	// Location is this node's datacenter and coordinates, shown in JSON
+	// reports for map dashboards. Peers only learn it from pushed reports;
+	// UDP heartbeats do not carry it
+	Location Location
+
+	// Kubernetes is the pod this node runs in, shown in JSON reports so a
+	// node's address can be matched to kubectl output. Like Location, peers
+	// only learn it from pushed reports. See KubernetesFromEnv
+	Kubernetes Kubernetes
+
+	// CriticalRetransmit sends this many extra copies of a heartbeat that
+	// changes the status to or from CRITICAL, registry.RetransmitSpacing
+	// apart, so one lost packet does not delay the change by a whole
+	// interval (0 disables, at most MaxCriticalRetransmit)
+	CriticalRetransmit int
+
+	// AlertGracePeriod marks reports as settling and holds ExitCode at OK
+	// for this long after Start, while discovery fills in the cluster
+	// view, so a restart does not page on a half-empty cluster (0 disables)
+	AlertGracePeriod time.Duration
+
+	// ExpectMinNodes marks reports CRITICAL while fewer peers than this
+	// are known, and makes ExitCode return the below-min-nodes code,
+	// Severity.BelowMinNodesExitCode, falling back to CriticalExitCode
+	// when that is unset. A check that discovered nothing then does not
+	// pass as healthy. This node's own entry is not counted. It is about
+	// detection confidence, not health: the known nodes may all be OK
+	// (0 disables)
+	ExpectMinNodes int
+
+	// SelfCheckAfter reports this node's own monitoring traffic unhealthy
+	// once every send has failed for this long, and warns once nothing was
+	// received for this long while it has peers. It shows in reports,
+	// snapshots, HealthzPath and ReadyzPath. A listener the watchdog gave up
+	// on is always reported. The default (0) is twice the Timeout, and at
+	// least three of the longest heartbeat intervals; negative disables the
+	// rest
+	SelfCheckAfter time.Duration
+
+	// Priority is advertised in every heartbeat, shown in reports, and
+	// preferred by primary/standby election: the live collector with the
+	// highest priority is active, the lowest UUID among equals. 0 is the
+	// default, which older peers that carry no priority also rank as
+	// (at most MaxPriority). Sending it needs WireVersion 5 or later
+	Priority int
+}
+
+// DefaultConfig returns the configuration used by the pulsecheck binary
+func DefaultConfig() Config {
+	return Config{
+		Port:                   9999,
+		HeartbeatInterval:      5 * time.Second,
+		CollectTimeout:         2 * time.Second,
+		Timeout:                15 * time.Second,
+		FailureDetector:        FailureDetectorTimeout,
+		PhiThreshold:           8.0,
+		Thresholds:             telemetry.DefaultThresholds(),
+		Severity:               registry.DefaultSeverityPolicy(),
+		SuppressRepeatedErrors: true,
+		ReportInterval:         10 * time.Second,
+		IOTimeout:              registry.DefaultIOTimeout,
+		ProbeInterval:          10 * time.Second,
+		ProbeTimeout:           3 * time.Second,
+		ProbeWarnLatency:       1 * time.Second,
+		StoreInterval:          1 * time.Minute,
+		HistorySegment:         24 * time.Hour,
+		DuplicateWindow:        15 * time.Second,
+		PacketDedupTTL:         registry.DefaultPacketDedupTTL,
+		PacketDedupSize:        registry.DefaultPacketDedupSize,
+		MaxPeers:               registry.DefaultMaxPeers,
+		MaxNodes:               registry.DefaultMaxNodes,
+		EventStreamClients:     16,
+		ClockSkewTolerance:     registry.DefaultSkewTolerance,
+		PushBreakerThreshold:   5,
+		PushBreakerCooldown:    30 * time.Second,
+		WireVersion:            protocol.VersionV1,
+	}
+}
+
+// Node is a running PulseCheck instance
+type Node struct {
+	config   Config
+	uuid     [16]byte
+	monitor  *registry.Monitor
+	udpNode  *registry.UDPNode
+	reporter *display.Reporter
+	prober   *probe.Prober
+	fifo     *fifo.Reader
+	store    *store.Store
+	eventLog *display.EventLogger
+	stream   *display.EventStream // nil unless served on StatusAddr
+	chaos    *chaos               // nil unless EnableChaos is set
+	metrics  *telemetry.Collector
+	status   *telemetry.Evaluator
+	started  bool
+	startMu  sync.Mutex
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	collectors []string    // Resolved Collectors addresses
+	active     atomic.Bool // Last known lease state, for logging transitions
+
+	pusher     *relay.Pusher
+	federator  *relay.Federator
+	ingest     *http.Server
+	ingestAddr net.Addr // Bound ingest address once started
+	statusSrv  *http.Server
+	statusAddr net.Addr // Bound status address once started
+
+	anonymizer *display.Anonymizer // nil unless Anonymize is set
+
+	// Last broadcast, for ConditionalHeartbeat. Only the heartbeat loop
+	// touches these
+	lastSent       time.Time
+	lastSentStatus telemetry.StatusCode
+
+	// Consecutive TextfileOut write failures. Only the heartbeat loop
+	// touches this
+	textfileFailures *telemetry.FailureTracker
+
+	// Whether the send rotation was last seen too slow for the Timeout, see
+	// checkRotation. Only the heartbeat loop touches this
+	rotationTooSlow bool
+
+	// Set while the peer view is too large to push, so that is logged once
+	peerViewTooLarge bool
+}
+
+// New creates a node and binds its UDP socket. Call Start to begin heartbeating
+func New(cfg Config) (*Node, error) {
+	if cfg.HeartbeatInterval <= 0 {
+		return nil, errors.New("heartbeat interval must be positive")
+	}
+	if cfg.CollectTimeout < 0 {
+		return nil, errors.New("collect timeout must not be negative")
+	}
+	if cfg.TelemetryInterval < 0 {
+		return nil, errors.New("telemetry interval must not be negative")
+	}
+	if cfg.CriticalSustain < 0 {
+		return nil, errors.New("critical sustain must not be negative")
+	}
+	if len(cfg.ProbeTargets) > 0 && cfg.ProbeInterval <= 0 {
+		return nil, errors.New("probe interval must be positive")
+	}
+	if cfg.SignReports && cfg.SigningKey == nil {
+		return nil, errors.New("signing reports requires a signing key")
+	}
+	if cfg.ReportOnChange < 0 {
+		return nil, errors.New("report on change floor must not be negative")
+	}
+	if cfg.EventStreamClients < 0 {
+		return nil, errors.New("event stream clients must not be negative")
+	}
+	if cfg.Priority < 0 || cfg.Priority > MaxPriority {
+		return nil, fmt.Errorf("priority must be between 0 and %d", MaxPriority)
+	}
+	if cfg.WireVersion < protocol.VersionV1 || cfg.WireVersion > protocol.Version {
+		return nil, fmt.Errorf("wire version must be between %d and %d", protocol.VersionV1, protocol.Version)
+	}
+	if cfg.Priority != 0 && cfg.WireVersion < protocol.VersionV5 {
+		return nil, fmt.Errorf("priority is only sent with wire version %d or later", protocol.VersionV5)
+	}
+	if cfg.SigningKey != nil && cfg.WireVersion < protocol.VersionV2 {
+		return nil, fmt.Errorf("signed packets need wire version %d or later", protocol.VersionV2)
+	}
+	if cfg.CriticalRetransmit < 0 || cfg.CriticalRetransmit > MaxCriticalRetransmit {
+		return nil, fmt.Errorf("critical retransmit must be between 0 and %d", MaxCriticalRetransmit)
+	}
+	if err := cfg.Location.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid location: %w", err)
+	}
+	if cfg.MaxWorkers < 0 || (cfg.MaxWorkers > 0 && cfg.MaxWorkers < cfg.Workers) {
+		return nil, errors.New("max workers must not be below workers")
+	}
+	if cfg.AlertGracePeriod < 0 {
+		return nil, errors.New("alert grace period must not be negative")
+	}
+	if err := cfg.Severity.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid severity policy: %w", err)
+	}
+	if cfg.ExpectMinNodes < 0 {
+		return nil, errors.New("expected minimum nodes must not be negative")
+	}
+	if cfg.PushBreakerThreshold < 0 {
+		return nil, errors.New("push breaker threshold must not be negative")
+	}
+	if cfg.PushBreakerThreshold > 0 && cfg.PushBreakerCooldown <= 0 {
+		return nil, errors.New("push breaker cooldown must be positive")
+	}
+	if cfg.StorePath != "" && cfg.StoreInterval <= 0 {
+		return nil, errors.New("store interval must be positive")
+	}
+	if cfg.HistorySegment < 0 || cfg.HistoryRetention < 0 {
+		return nil, errors.New("history segment and retention must not be negative")
+	}
+	if cfg.HistoryRetention > 0 && cfg.HistorySegment == 0 {
+		return nil, errors.New("history retention requires history segments")
+	}
+	if err := telemetry.ValidateMountThresholds(cfg.Thresholds.Mounts); err != nil {
+		return nil, err
+	}
+	if err := telemetry.ValidateMountPatterns(cfg.DiskExclude); err != nil {
+		return nil, err
+	}
+	if err := telemetry.ValidateMetricThresholds(cfg.Thresholds.Metrics); err != nil {
+		return nil, err
+	}
+	for name, collect := range cfg.MetricSources {
+		if name == "" || collect == nil {
+			return nil, fmt.Errorf("metric source %q needs a name and a function", name)
+		}
+	}
+	if cfg.DiskAllMounts {
+		mounts, err := telemetry.DiscoverMounts(cfg.Thresholds, cfg.DiskExclude)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list mounts: %w", err)
+		}
+		cfg.Thresholds.Mounts = mounts
+	}
+	if cfg.TextfileOut != "" {
+		if info, err := os.Stat(filepath.Dir(cfg.TextfileOut)); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("textfile directory %s does not exist", filepath.Dir(cfg.TextfileOut))
+		}
+	}
+	var netInterfaces []string
+	for _, name := range cfg.NetInterfaces {
+		if name != "" {
+			netInterfaces = append(netInterfaces, name)
+		}
+	}
+	if len(netInterfaces) > 0 {
+		if err := telemetry.ValidateInterfaces(netInterfaces); err != nil {
+			return nil, err
+		}
+	}
+	// The longest gap between heartbeat ticks
+	maxInterval := cfg.HeartbeatInterval
+	if cfg.HeartbeatBudget < 0 {
+		return nil, errors.New("heartbeat budget must not be negative")
+	}
+	if cfg.HeartbeatBudget > 0 {
+		if cfg.MaxHeartbeatInterval == 0 {
+			cfg.MaxHeartbeatInterval = cfg.Timeout / 3
+		}
+		if cfg.MaxHeartbeatInterval < cfg.HeartbeatInterval {
+			return nil, fmt.Errorf("max heartbeat interval %v must not be below the heartbeat interval %v",
+				cfg.MaxHeartbeatInterval, cfg.HeartbeatInterval)
+		}
+		if cfg.Timeout > 0 && cfg.MaxHeartbeatInterval >= cfg.Timeout {
+			return nil, fmt.Errorf("max heartbeat interval %v must be under the timeout %v",
+				cfg.MaxHeartbeatInterval, cfg.Timeout)
+		}
+		maxInterval = cfg.MaxHeartbeatInterval
+	}
+	if cfg.ConditionalHeartbeat {
+		if cfg.KeepaliveInterval == 0 {
+			cfg.KeepaliveInterval = cfg.Timeout / 2
+		}
+		if cfg.KeepaliveInterval <= 0 {
+			return nil, errors.New("keepalive interval must be positive")
+		}
+		// Keepalives go out on heartbeat ticks, so they can lag by one interval
+		if cfg.Timeout > 0 && cfg.KeepaliveInterval+maxInterval >= cfg.Timeout {
+			return nil, fmt.Errorf("keepalive interval %v plus heartbeat interval %v must be under the timeout %v",
+				cfg.KeepaliveInterval, maxInterval, cfg.Timeout)
+		}
+	}
+	// Peers only count as gone after the Timeout, so silence shorter than
+	// that is not yet news
+	if cfg.SelfCheckAfter == 0 {
+		cfg.SelfCheckAfter = max(2*cfg.Timeout, 3*maxInterval)
+	}
+	if cfg.PeersPerHeartbeat < 0 {
+		return nil, errors.New("peers per heartbeat must not be negative")
+	}
+	// With rotation each peer hears from us only once every ceil(n/k)
+	// intervals. Learned peers are checked as they come, see checkRotation
+	if cfg.PeersPerHeartbeat > 0 && cfg.Timeout > 0 && len(cfg.Peers) > cfg.PeersPerHeartbeat {
+		rounds := (len(cfg.Peers) + cfg.PeersPerHeartbeat - 1) / cfg.PeersPerHeartbeat
+		if gap := time.Duration(rounds) * maxInterval; gap >= cfg.Timeout {
+			return nil, fmt.Errorf("with %d peers and %d per heartbeat, each peer hears from this node every %v, which must be under the timeout %v",
+				len(cfg.Peers), cfg.PeersPerHeartbeat, gap, cfg.Timeout)
+		}
+	}
+	if cfg.PacketDedupTTL < 0 || cfg.PacketDedupSize < 0 {
+		return nil, errors.New("packet dedup TTL and size must not be negative")
+	}
+	if cfg.MaxPeers < 0 {
+		return nil, errors.New("max peers must not be negative")
+	}
+	if cfg.MaxNodes < 0 {
+		return nil, errors.New("max nodes must not be negative")
+	}
+	if cfg.DSCP < 0 || cfg.DSCP > registry.MaxDSCP {
+		return nil, fmt.Errorf("DSCP must be between 0 and %d", registry.MaxDSCP)
+	}
+	if cycle := registry.DependencyCycle(cfg.Dependencies); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle: %s", registry.FormatCycle(cycle))
+	}
+	if cfg.IngestAddr != "" && cfg.IngestToken == "" && len(cfg.TrustedKeys) == 0 {
+		return nil, errors.New("serving the ingest address requires an ingest token or trusted keys")
+	}
+	if cfg.SocketReadBuffer < 0 {
+		return nil, errors.New("socket receive buffer size must not be negative")
+	}
+	if cfg.StaticPeers && cfg.SeedNode != "" {
+		return nil, errors.New("a seed node cannot be used with static peers")
+	}
+	if cfg.ReapBurst < 0 {
+		return nil, errors.New("reap burst must not be negative")
+	}
+	if cfg.FailureDetector == "" {
+		cfg.FailureDetector = FailureDetectorTimeout
+	}
+	if cfg.FailureDetector != FailureDetectorTimeout && cfg.FailureDetector != FailureDetectorPhi {
+		return nil, fmt.Errorf("invalid failure detector %q: must be %s or %s",
+			cfg.FailureDetector, FailureDetectorTimeout, FailureDetectorPhi)
+	}
+
+	groupBy, err := display.ParseGroupBy(cfg.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	timeFormat, err := display.ParseTimeFormat(cfg.TimeFormat)
+	if err != nil {
+		return nil, err
+	}
+	formatter, err := newFormatter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.NodeID == "" {
+		cfg.NodeID = hostnameNodeID()
+	}
+	nodeUUID := generateNodeUUID(cfg.NodeID)
+	if cfg.IdentityFile != "" {
+		if nodeUUID, err = loadIdentity(cfg.IdentityFile, cfg.NodeID); err != nil {
+			return nil, fmt.Errorf("identity file: %w", err)
+		}
+	}
+	monitor := registry.NewMonitor()
+	monitor.SetDuplicateWindow(cfg.DuplicateWindow)
+	monitor.SetSkewTolerance(cfg.ClockSkewTolerance)
+	monitor.SetReapBurst(cfg.ReapBurst)
+	monitor.SetMaxNodes(cfg.MaxNodes)
+	monitor.SetDependencies(cfg.Dependencies)
+	monitor.SetSelf(nodeUUID)
+	for addr, d := range cfg.Silences {
+		monitor.Silence(addr, time.Now().Add(d))
+	}
+
+	udpNode, err := registry.NewUDPNode(cfg.Port, nodeUUID, monitor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create UDP node: %w", err)
+	}
+	// Every error return from here on releases what was opened so far
+	var release []func()
+	ok := false
+	defer func() {
+		if ok {
+			return
+		}
+		for i := len(release) - 1; i >= 0; i-- {
+			release[i]()
+		}
+	}()
+	release = append(release, udpNode.Stop)
+	udpNode.SetChecksum(!cfg.NoChecksum)
+	if err := udpNode.SetWireVersion(uint8(cfg.WireVersion)); err != nil {
+		return nil, err
+	}
+	udpNode.SetMagic(cfg.PacketMagic, cfg.RequireMagic)
+	udpNode.SetHeartbeatInterval(cfg.HeartbeatInterval)
+	udpNode.SetPriority(uint8(cfg.Priority))
+	udpNode.SetIOTimeout(cfg.IOTimeout)
+	udpNode.SetWorkers(cfg.Workers)
+	udpNode.SetMaxWorkers(cfg.MaxWorkers)
+	udpNode.SetSigning(cfg.SigningKey, cfg.TrustedKeys)
+	udpNode.SetStaticPeers(cfg.StaticPeers)
+	udpNode.SetPeersPerBroadcast(cfg.PeersPerHeartbeat)
+	udpNode.SetMaxPeers(cfg.MaxPeers)
+	udpNode.SetPacketDedup(cfg.PacketDedupTTL, cfg.PacketDedupSize)
+	if err := udpNode.SetDSCP(cfg.DSCP); err != nil {
+		log.Printf("Warning: sending unmarked packets: %v", err)
+	}
+	if cfg.SocketReadBuffer > 0 {
+		granted, err := udpNode.SetReadBuffer(cfg.SocketReadBuffer)
+		switch {
+		case err != nil:
+			log.Printf("Warning: keeping the default socket receive buffer: %v", err)
+		case granted == 0:
+			log.Printf("Socket receive buffer: requested %d bytes", cfg.SocketReadBuffer)
+		case granted < cfg.SocketReadBuffer:
+			log.Printf("Warning: socket receive buffer: requested %d bytes, kernel granted %d; raise net.core.rmem_max or the platform's limit", cfg.SocketReadBuffer, granted)
+		default:
+			log.Printf("Socket receive buffer: requested %d bytes, kernel granted %d", cfg.SocketReadBuffer, granted)
+		}
+	}
+	for _, p := range cfg.Peers {
+		if p == "" {
+			continue
+		}
+		if err := udpNode.AddPeer(p); err != nil {
+			return nil, fmt.Errorf("invalid peer %q: %w", p, err)
+		}
+	}
+
+	reporter := display.NewReporter(monitor, cfg.JSONOutput)
+	reporter.SetJSONOptions(display.JSONOptions{
+		Compact:       cfg.JSONCompact,
+		FullTelemetry: cfg.JSONFull,
+	})
+	reporter.SetGroupBy(groupBy)
+	reporter.SetTimeFormat(timeFormat)
+	reporter.SetMaxDisplayAge(cfg.MaxDisplayAge)
+	reporter.SetTimeout(cfg.Timeout)
+	reporter.SetListNoTelemetry(cfg.ListNoTelemetry)
+	reporter.SetUnbuffered(cfg.UnbufferedReports)
+	reporter.SetReportOnChange(cfg.ReportOnChange)
+	reporter.SetExpectMinNodes(cfg.ExpectMinNodes, udpNode.Conn().LocalAddr().String())
+	if cfg.FailureDetector == FailureDetectorTimeout {
+		reporter.SetReapTimeout(cfg.Timeout)
+	}
+	if cfg.SignReports {
+		reporter.SetSigningKey(cfg.SigningKey)
+	}
+	var anonymizer *display.Anonymizer
+	if cfg.Anonymize {
+		if anonymizer, err = display.NewAnonymizer(); err != nil {
+			return nil, err
+		}
+		reporter.SetAnonymizer(anonymizer)
+	}
+	if formatter != nil {
+		reporter.SetFormatter(formatter)
+	}
+	if cfg.RecomputeStatus {
+		reporter.SetStatusCheck(statusCheck(cfg.Thresholds, udpNode.Conn().LocalAddr().String()))
+	}
+
+	node := &Node{
+		config:   cfg,
+		uuid:     nodeUUID,
+		monitor:  monitor,
+		udpNode:  udpNode,
+		reporter: reporter,
+		metrics:  newCollector(cfg),
+		status:   telemetry.NewEvaluator(cfg.Thresholds, cfg.CriticalSustain),
+		stopChan: make(chan struct{}),
+
+		anonymizer:       anonymizer,
+		textfileFailures: telemetry.NewFailureTracker(cfg.SuppressRepeatedErrors),
+	}
+	node.metrics.SetNetworkInterfaces(netInterfaces)
+	if cfg.CPUSteal {
+		node.metrics.SetCPUSteal()
+	}
+	if cfg.EnableChaos {
+		node.chaos = newChaos()
+		udpNode.SetSendFilter(node.chaos.dropSend)
+	}
+	node.metrics.SetMounts(cfg.Thresholds.Mounts)
+	sources := make([]string, 0, len(cfg.MetricSources))
+	for name := range cfg.MetricSources {
+		sources = append(sources, name)
+	}
+	sort.Strings(sources)
+	for _, name := range sources {
+		node.metrics.AddMetricSource(name, cfg.MetricSources[name])
+	}
+	if len(cfg.ProbeTargets) > 0 {
+		node.prober = probe.NewProber(monitor, cfg.ProbeTargets, cfg.ProbeTimeout, cfg.ProbeWarnLatency)
+	}
+	for _, c := range cfg.Collectors {
+		if c == "" {
+			continue
+		}
+		addr, err := registry.ResolvePeerAddr(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid collector %q: %w", c, err)
+		}
+		// Collectors must exchange heartbeats to see each other's leases
+		udpNode.AddPeer(addr.String())
+		node.collectors = append(node.collectors, addr.String())
+	}
+	if len(node.collectors) > 0 {
+		if node.config.LeaseTTL <= 0 {
+			node.config.LeaseTTL = cfg.Timeout
+		}
+		node.active.Store(true)
+		reporter.SetActive(node.IsActive)
+	}
+	reporter.SetSelfCheck(node.TrafficHealth)
+	if cfg.PushURL != "" {
+		u, err := url.Parse(cfg.PushURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid push URL %q: must be an http or https URL", cfg.PushURL)
+		}
+		// A push slower than the timeout is useless: the collector has already reaped the node
+		node.pusher = relay.NewPusher(cfg.PushURL, cfg.Timeout)
+		node.pusher.SetAuth(cfg.IngestToken, cfg.SigningKey)
+		if cfg.PushBreakerThreshold > 0 {
+			node.pusher.SetBreaker(relay.NewBreaker(cfg.PushBreakerThreshold, cfg.PushBreakerCooldown, clock.Real()))
+		}
+	}
+	var federateFrom []string
+	for _, peer := range cfg.FederateFrom {
+		peer = strings.TrimSpace(peer)
+		if peer == "" {
+			continue
+		}
+		u, err := url.Parse(peer)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid federation URL %q: must be an http or https URL", peer)
+		}
+		federateFrom = append(federateFrom, peer)
+	}
+	if len(federateFrom) > 0 {
+		node.federator = relay.NewFederator(monitor, federateFrom, cfg.Timeout)
+	}
+	if cfg.StorePath != "" {
+		st, err := store.Open(cfg.StorePath, monitor)
+		if err != nil {
+			return nil, err
+		}
+		release = append(release, func() { st.Stop() })
+		st.SetSegments(cfg.HistorySegment, cfg.HistoryRetention)
+		node.store = st
+	}
+	// Opened after every check that can fail, so a bad config does not
+	// leave the pipe open
+	if cfg.FIFOPath != "" {
+		fr, err := fifo.Open(cfg.FIFOPath, monitor)
+		if err != nil {
+			return nil, err
+		}
+		node.fifo = fr
+	}
+	if cfg.EventLog != nil {
+		node.eventLog = display.NewEventLogger(cfg.EventLog)
+	}
+	if cfg.StatusAddr != "" && cfg.EventStreamClients > 0 {
+		node.stream = display.NewEventStream(cfg.EventStreamClients)
+	}
+	node.setEventHandlers()
+
+	ok = true
+	return node, nil
+}
+
+// setEventHandlers routes registry events to the store, the event log, the
+// event stream and the reporter. All of them queue events without blocking
+// the update path
+func (n *Node) setEventHandlers() {
+	var handlers []func(registry.Event)
+	if n.config.ReportInterval > 0 {
+		handlers = append(handlers, n.reporter.RecordEvent)
+	}
+	if n.store != nil {
+		handlers = append(handlers, n.store.RecordEvent)
+	}
+	if n.eventLog != nil {
+		handlers = append(handlers, n.eventLog.RecordEvent)
+	}
+	if n.stream != nil {
+		handlers = append(handlers, n.stream.RecordEvent)
+	}
+	switch len(handlers) {
+	case 0:
+	case 1:
+		n.monitor.SetEventHandler(handlers[0])
+	default:
+		n.monitor.SetEventHandler(func(e registry.Event) {
+			for _, h := range handlers {
+				h(e)
+			}
+		})
+	}
+}
+
+// newFormatter returns the report formatter selected by cfg, or nil for the
+// default human or JSON output. Templates are parsed here so a bad one fails
+// before the node starts
+func newFormatter(cfg Config) (ReportFormatter, error) {
+	if cfg.Template != "" && cfg.TemplateFile != "" {
+		return nil, errors.New("only one of template and template file can be set")
+	}
+	name, text := "template", cfg.Template
+	if cfg.TemplateFile != "" {
+		data, err := os.ReadFile(cfg.TemplateFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report template: %w", err)
+		}
+		name, text = filepath.Base(cfg.TemplateFile), string(data)
+	}
+
+	switch {
+	case text != "":
+		if cfg.Output != "" && cfg.Output != "template" {
+			return nil, fmt.Errorf("a report template cannot be used with output %q", cfg.Output)
+		}
+		f, err := display.NewTemplateFormatter(name, text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid report template: %w", err)
+		}
+		return f, nil
+	case cfg.Output == "template":
+		return nil, errors.New("template output requires a template or template file")
+	case cfg.Output != "":
+		return display.LookupFormatter(cfg.Output)
+	}
+	return nil, nil
+}
+
+// Start launches the listener, reaper, reporter, and heartbeat loop in the
+// background. The node runs until Stop is called or ctx is cancelled
+func (n *Node) Start(ctx context.Context) error {
+	n.startMu.Lock()
+	defer n.startMu.Unlock()
+	if n.started {
+		return errors.New("node already started")
+	}
+
+	// Bind the HTTP endpoints first so a taken port fails Start cleanly
+	var ingestLn, statusLn net.Listener
+	if n.config.IngestAddr != "" {
+		ln, err := net.Listen("tcp", n.config.IngestAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for pushed reports: %w", err)
+		}
+		ingestLn = ln
+	}
+	if n.config.StatusAddr != "" {
+		ln, err := net.Listen("tcp", n.config.StatusAddr)
+		if err != nil {
+			if ingestLn != nil {
+				ingestLn.Close()
+			}
+			return fmt.Errorf("failed to listen for status requests: %w", err)
+		}
+		statusLn = ln
+	}
+	if ingestLn != nil {
+		mux := http.NewServeMux()
+		mux.Handle(relay.IngestPath, relay.IngestHandler(n.monitor, relay.IngestAuth{
+			Token:       n.config.IngestToken,
+			TrustedKeys: n.config.TrustedKeys,
+		}))
+		if n.config.IngestToken != "" {
+			mux.Handle(SilencePath, relay.RequireToken(n.config.IngestToken, n.silenceHandler()))
+		}
+		if n.chaos != nil && n.config.IngestToken != "" {
+			mux.Handle(ChaosPath, relay.RequireToken(n.config.IngestToken, n.chaosHandler()))
+		}
+		n.ingest, n.ingestAddr = n.serveHTTP(ingestLn, mux, "Ingest")
+	}
+	if statusLn != nil {
+		mux := http.NewServeMux()
+		mux.Handle(ConfigPath, n.configHandler())
+		mux.Handle(HealthzPath, n.healthHandler(false))
+		mux.Handle(ReadyzPath, n.healthHandler(true))
+		mux.Handle(StatsPath, n.statsHandler())
+		mux.Handle(display.StatusPath, n.reporter.StatusHandler())
+		mux.Handle(display.NodesPath, n.reporter.NodesHandler())
+		if n.stream != nil {
+			mux.Handle(display.EventStreamPath, n.stream)
+		}
+		if n.store != nil {
+			mux.Handle(HistoryPath, n.historyHandler())
+		}
+		n.statusSrv, n.statusAddr = n.serveHTTP(statusLn, mux, "Status")
+	}
+	n.started = true
+	if n.config.AlertGracePeriod > 0 {
+		n.reporter.SetSettlePeriod(n.config.AlertGracePeriod)
+	}
+
+	// Start UDP listener in background
+	go n.udpNode.Start()
+
+	// Connect to seed node if provided (for peer discovery)
+	if n.config.SeedNode != "" {
+		n.connectSeed()
+	}
+
+	// Start reaper goroutine
+	// The phi detector falls back to the fixed timeout until it has heartbeat history
+	if n.config.FailureDetector == FailureDetectorPhi {
+		go n.monitor.StartPhiReaper(1*time.Second, n.config.PhiThreshold, n.config.Timeout)
+	} else {
+		go n.monitor.StartReaper(1*time.Second, n.config.Timeout)
+	}
+
+	if n.config.ReportInterval > 0 {
+		go n.reporter.Start(n.config.ReportInterval)
+	}
+
+	if n.prober != nil {
+		go n.prober.Start(n.config.ProbeInterval)
+	}
+
+	if n.store != nil {
+		go n.store.Start(n.config.StoreInterval)
+	}
+
+	if n.fifo != nil {
+		go n.fifo.Start()
+	}
+
+	if n.eventLog != nil {
+		go n.eventLog.Start()
+	}
+
+	if n.pusher != nil {
+		go n.pusher.Start()
+	}
+
+	if n.federator != nil {
+		go n.federator.Start(n.config.HeartbeatInterval)
+	}
+
+	n.wg.Add(1)
+	go n.heartbeatLoop(ctx)
+	if len(n.config.Dependencies) > 0 {
+		n.wg.Add(1)
+		go n.warnUnknownDependencies()
+	}
+
+	log.Printf("PulseCheck node started (UUID: %x, Port: %d)", n.uuid, n.Port())
+	log.Printf("Heartbeat interval: %v, Timeout: %v", n.config.HeartbeatInterval, n.config.Timeout)
+	if n.config.HeartbeatBudget > 0 {
+		log.Printf("Adaptive heartbeats: %.1f packets/s, interval up to %v", n.config.HeartbeatBudget, n.config.MaxHeartbeatInterval)
+	}
+	if n.config.TelemetryInterval > 0 {
+		log.Printf("Telemetry interval: %v", n.config.TelemetryInterval)
+	}
+	if n.config.CPUSteal && !telemetry.StealSupported() {
+		log.Printf("Warning: CPU steal time is not reported on %s; ignoring the steal thresholds", runtime.GOOS)
+	}
+	if n.config.FailureDetector == FailureDetectorPhi {
+		log.Printf("Failure detector: phi accrual (threshold: %.1f)", n.config.PhiThreshold)
+	}
+	if n.config.SigningKey != nil {
+		pub := n.config.SigningKey.Public().(ed25519.PublicKey)
+		log.Printf("Signing heartbeats (trusted key entry: %x %s)", n.uuid, base64.StdEncoding.EncodeToString(pub))
+		if n.config.SignReports {
+			log.Printf("Signing reports (verify with: pulsecheck verify-report -public-key %s)", base64.StdEncoding.EncodeToString(pub))
+		}
+	}
+	if n.config.TrustedKeys != nil {
+		log.Printf("Verifying heartbeat signatures from %d trusted nodes", len(n.config.TrustedKeys))
+	}
+	if n.config.SeedNode != "" {
+		log.Printf("Seed node: %s", n.config.SeedNode)
+	}
+	if n.prober != nil {
+		log.Printf("Probing %d agentless targets every %v", len(n.config.ProbeTargets), n.config.ProbeInterval)
+	}
+	if len(n.collectors) > 0 {
+		log.Printf("Collector group: %v (lease TTL: %v)", n.collectors, n.config.LeaseTTL)
+	}
+	if n.store != nil {
+		log.Printf("Persisting history to %s", n.config.StorePath)
+	}
+	if n.fifo != nil {
+		log.Printf("Reading metric records from FIFO %s", n.config.FIFOPath)
+	}
+	if n.pusher != nil {
+		log.Printf("Pushing reports to %s", n.config.PushURL)
+	}
+	if n.federator != nil {
+		log.Printf("Federating from %s", strings.Join(n.federator.Peers(), ", "))
+	}
+	if n.config.TextfileOut != "" {
+		log.Printf("Writing local metrics to %s", n.config.TextfileOut)
+	}
+	if n.ingest != nil {
+		log.Printf("Accepting pushed reports on http://%s%s", n.ingestAddr, relay.IngestPath)
+	}
+	if n.statusSrv != nil {
+		log.Printf("Serving status on http://%s%s and http://%s%s", n.statusAddr, display.StatusPath, n.statusAddr, display.NodesPath)
+		if n.stream != nil {
+			log.Printf("Streaming events on http://%s%s", n.statusAddr, display.EventStreamPath)
+		}
+	}
+	if n.chaos != nil {
+		log.Println("Warning: chaos testing enabled - this node accepts injected faults")
+		if n.ingest != nil && n.config.IngestToken != "" {
+			log.Printf("Injecting faults on http://%s%s", n.ingestAddr, ChaosPath)
+		}
+	}
+	if n.config.NoChecksum {
+		log.Println("Warning: packet checksums disabled - all peers must run with -no-checksum")
+	}
+
+	return nil
+}
+
+// Stop shuts down all background goroutines and closes the socket. Safe to call more than once
+func (n *Node) Stop() {
+	n.stopOnce.Do(func() {
+		close(n.stopChan)
+		n.wg.Wait()
+		n.reporter.Stop()
+		if n.prober != nil {
+			n.prober.Stop()
+		}
+		if n.pusher != nil {
+			n.pusher.Stop()
+		}
+		if n.federator != nil {
+			n.federator.Stop()
+		}
+		if n.ingest != nil {
+			n.ingest.Close()
+		}
+		if n.statusSrv != nil {
+			n.statusSrv.Close()
+		}
+		n.monitor.Stop()
+		n.udpNode.Stop()
+		if n.fifo != nil {
+			n.fifo.Stop()
+		}
+		if n.store != nil {
+			if err := n.store.Stop(); err != nil {
+				log.Printf("Failed to close store: %v", err)
+			}
+		}
+		if n.eventLog != nil {
+			n.eventLog.Stop()
+		}
+	})
+}
+
+// Monitor returns the node registry
+func (n *Node) Monitor() *Monitor {
+	return n.monitor
+}
+
+// Anonymizer returns the aliaser used for printed reports, or nil unless
+// Anonymize is set
+func (n *Node) Anonymizer() *display.Anonymizer {
+	return n.anonymizer
+}
+
+// IngestAddr returns the address accepting pushed reports, or "" if the
+// node is not serving IngestAddr
+func (n *Node) IngestAddr() string {
+	n.startMu.Lock()
+	defer n.startMu.Unlock()
+	if n.ingestAddr == nil {
+		return ""
+	}
+	return n.ingestAddr.String()
+}
+
+// StatusAddr returns the address serving the read-only endpoints, or "" if
+// the node is not serving StatusAddr
+func (n *Node) StatusAddr() string {
+	n.startMu.Lock()
+	defer n.startMu.Unlock()
+	if n.statusAddr == nil {
+		return ""
+	}
+	return n.statusAddr.String()
+}
+
+// serveHTTP serves handler on ln in the background
+func (n *Node) serveHTTP(ln net.Listener, handler http.Handler, name string) (*http.Server, net.Addr) {
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: n.config.Timeout}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("%s server error: %v", name, err)
+		}
+	}()
+	return srv, ln.Addr()
+}
+
+// UUID returns the node's 16-byte identifier
+func (n *Node) UUID() [16]byte {
+	return n.uuid
+}
+
+// Port returns the UDP port the node is listening on
+func (n *Node) Port() int {
+	return n.udpNode.Port()
+}
+
+// Stats returns the packets and bytes sent and received by this node
+func (n *Node) Stats() NetworkStats {
+	return n.udpNode.Stats()
+}
+
+// ReaperStats returns how many nodes the reaper has removed, in total and in
+// its most recent cycle
+func (n *Node) ReaperStats() ReaperStats {
+	return n.monitor.ReaperStats()
+}
+
+// statusCheck recomputes a node's status from its relayed telemetry with
+// thresholds. The local node is skipped: its status already comes from
+// these thresholds, plus the sustain and free-space checks a relayed
+// report cannot carry
+func statusCheck(thresholds Thresholds, localAddr string) func(NodeInfo) (uint8, bool) {
+	return func(info NodeInfo) (uint8, bool) {
+		if !info.HasTelemetry || info.Address == localAddr {
+			return 0, false
+		}
+		metrics := &telemetry.Metrics{
+			CPUPercent:  info.CPUPercent,
+			RAMPercent:  info.RAMPercent,
+			DiskPercent: info.DiskPercent,
+		}
+		return uint8(telemetry.CalculateStatus(metrics, thresholds)), true
+	}
+}
+
+// PushBreakerStats returns the state of the push relay's circuit breaker,
+// including reports dropped while it was open. It reads closed when the
+// node does not push
+func (n *Node) PushBreakerStats() BreakerStats {
+	if n.pusher == nil {
+		return BreakerStats{State: relay.BreakerClosed.String()}
+	}
+	return n.pusher.BreakerStats()
+}
+
+// FIFOStats returns the number of records read from FIFOPath and the
+// malformed lines skipped. It reads zero when no FIFO is configured
+func (n *Node) FIFOStats() FIFOStats {
+	if n.fifo == nil {
+		return FIFOStats{}
+	}
+	return n.fifo.Stats()
+}
+
+// TrafficHealth reports whether this node's own heartbeats still go out and
+// come in, see Config.SelfCheckAfter. An unhealthy node is alive but no
+// longer monitoring, e.g. behind a wedged socket or with every peer
+// unreachable
+func (n *Node) TrafficHealth() TrafficHealth {
+	return n.udpNode.TrafficHealth(max(n.config.SelfCheckAfter, 0))
+}
+
+// Report writes a single status report using the configured format
+func (n *Node) Report() {
+	n.reporter.Report()
+}
+
+// PauseReports skips periodic reports until ResumeReports. Heartbeats and
+// the registry are unaffected
+func (n *Node) PauseReports() {
+	n.reporter.Pause()
+}
+
+// ResumeReports restarts periodic reports with an immediate one
+func (n *Node) ResumeReports() {
+	n.reporter.Resume()
+}
+
+// ReportsPaused reports whether periodic reports are paused
+func (n *Node) ReportsPaused() bool {
+	return n.reporter.Paused()
+}
+
+// IsActive reports whether this node currently holds the collector lease and
+// should report and alert. Always true without Collectors configured
+func (n *Node) IsActive() bool {
+	if len(n.collectors) == 0 {
+		return true
+	}
+	active := n.monitor.IsLeader(n.uuid, uint8(n.config.Priority), n.collectors, n.config.LeaseTTL, n.monitor.Now())
+	if n.active.Swap(active) != active {
+		if active {
+			log.Println("Collector lease acquired - now the active reporter")
+		} else {
+			log.Println("Another collector holds the lease - standing by")
+		}
+	}
+	return active
+}
+
+// InAlertGrace reports whether the node is within its AlertGracePeriod
+func (n *Node) InAlertGrace() bool {
+	return n.reporter.Settling()
+}
+
+// ExitCode returns the exit code for the current cluster status under the
+// configured severity policy. It is OKExitCode during the AlertGracePeriod,
+// and the BelowMinNodes code while fewer than ExpectMinNodes peers are known
+func (n *Node) ExitCode() int {
+	if n.InAlertGrace() {
+		return n.config.Severity.OKExitCode
+	}
+	peers := n.monitor.GetNodeCount()
+	if _, ok := n.monitor.GetNodeInfo(n.udpNode.Conn().LocalAddr().String()); ok {
+		peers--
+	}
+	return n.config.Severity.ExitCodeForMin(n.monitor.Summarize(), peers, n.config.ExpectMinNodes)
+}
+
+// History returns persisted records for addr between from and to, oldest
+// first. An empty addr matches all nodes and zero times are unbounded.
+// Requires Config.StorePath
+func (n *Node) History(addr string, from, to time.Time) ([]HistoryRecord, error) {
+	if n.store == nil {
+		return nil, errors.New("no store configured")
+	}
+	return n.store.Query(addr, from, to)
+}
+
+// WriteTopology writes this node's view of the mesh as a Graphviz DOT graph
+func (n *Node) WriteTopology(w io.Writer) error {
+	self := n.udpNode.Conn().LocalAddr().String()
+	peers := n.udpNode.Peers()
+	nodes := n.monitor.GetNodes()
+	if n.anonymizer != nil {
+		self = n.anonymizer.Addr(self)
+		for i, peer := range peers {
+			peers[i] = n.anonymizer.Addr(peer)
+		}
+		nodes = n.anonymizer.Nodes(nodes)
+	}
+	return display.WriteDOT(w, self, peers, nodes)
+}
+
+// DumpSnapshot writes the full node state as indented JSON. The monitor
+// state is copied atomically with respect to ongoing updates
+func (n *Node) DumpSnapshot(w io.Writer) error {
+	peers := n.udpNode.Peers()
+	sort.Strings(peers)
+	snapshot := NodeSnapshot{
+		UUID:     hex.EncodeToString(n.uuid[:]),
+		Port:     n.Port(),
+		Peers:    peers,
+		Network:  n.udpNode.Stats(),
+		Self:     n.TrafficHealth(),
+		Snapshot: n.monitor.Snapshot(),
+	}
+	if n.fifo != nil {
+		stats := n.fifo.Stats()
+		snapshot.FIFO = &stats
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(snapshot)
+}
+
+// ExportState encodes every node this node knows about in the compact
+// binary state format, e.g. for a standby collector to load with ImportState
+func (n *Node) ExportState() []byte {
+	return n.monitor.ExportState()
+}
+
+// ImportState loads nodes from a state written by ExportState, keeping any
+// node seen more recently here. Returns the number of nodes loaded
+func (n *Node) ImportState(data []byte) (int, error) {
+	return n.monitor.ImportState(data)
+}
+
+// Silence reports addr as MAINTENANCE for the given duration and excludes it
+// from the cluster status. The window expires on its own
+func (n *Node) Silence(addr string, d time.Duration) {
+	n.monitor.Silence(addr, time.Now().Add(d))
+}
+
+// ParseSilences parses a comma-separated list of addr=duration maintenance
+// windows, e.g. "10.0.0.5:9999=2h,[fd00::1]:9999=30m"
+func ParseSilences(spec string) (map[string]time.Duration, error) {
+	silences := make(map[string]time.Duration)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		addr, window, ok := strings.Cut(entry, "=")
+		if !ok || addr == "" {
+			return nil, fmt.Errorf("invalid silence %q: expected addr=duration", entry)
+		}
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid silence %q: %w", entry, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid silence %q: duration must be positive", entry)
+		}
+		silences[addr] = d
+	}
+	return silences, nil
+}
+
+// warnUnknownDependencies logs the dependencies not heard from within a
+// timeout of starting. They never count as down, so a mistyped address
+// would otherwise silently disable suppression for its dependents
+func (n *Node) warnUnknownDependencies() {
+	defer n.wg.Done()
+	select {
+	case <-n.stopChan:
+		return
+	case <-time.After(n.config.Timeout):
+	}
+	for _, addr := range n.monitor.UnknownDependencies() {
+		log.Printf("Warning: dependency %s has not been heard from; check the address matches the node's key in reports", addr)
+	}
+}
+
+// ParseDependencies parses a comma-separated list of dependent=dependency
+// addresses, e.g. "10.0.0.3:9999=10.0.0.1:9999". A node with several
+// dependencies is listed once per dependency. Cycles are rejected
+func ParseDependencies(spec string) (map[string][]string, error) {
+	deps := make(map[string][]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		dependent, dependency, ok := strings.Cut(entry, "=")
+		if !ok || dependent == "" || dependency == "" {
+			return nil, fmt.Errorf("invalid dependency %q: expected dependent=dependency", entry)
+		}
+		if dependent == dependency {
+			return nil, fmt.Errorf("invalid dependency %q: a node cannot depend on itself", entry)
+		}
+		deps[dependent] = append(deps[dependent], dependency)
+	}
+	if cycle := registry.DependencyCycle(deps); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle: %s", registry.FormatCycle(cycle))
+	}
+	return deps, nil
+}
+
+// ParseRetention parses a history retention window as a Go duration or a
+// whole number of days, e.g. "7d"
+func ParseRetention(s string) (time.Duration, error) {
+	return store.ParseRetention(s)
+}
+
+// newCollector returns the metrics collector for cfg's backend
+func newCollector(cfg Config) *telemetry.Collector {
+	if cfg.MetricsBackend == nil {
+		return telemetry.NewCollector(cfg.CollectTimeout)
+	}
+	return telemetry.NewCollectorWithBackend(cfg.CollectTimeout, cfg.MetricsBackend)
+}
+
+// connectSeed sends an initial heartbeat to the configured seed node
+func (n *Node) connectSeed() {
+	// Collect initial metrics for seed node connection
+	statusCode := telemetry.StatusUnknown
+	if metrics, err := n.metrics.Collect(); err != nil {
+		log.Printf("Warning: Failed to collect metrics for seed node: %v", err)
+	} else {
+		statusCode = n.status.Evaluate(metrics, time.Now())
+	}
+
+	// Send initial heartbeat to seed node
+	if err := n.udpNode.SendToSeedNode(n.config.SeedNode, uint8(statusCode)); err != nil {
+		log.Printf("Warning: Failed to connect to seed node %s: %v", n.config.SeedNode, err)
+		log.Println("Continuing without seed node - peer discovery may be limited")
+	} else {
+		log.Printf("Connected to seed node: %s", n.config.SeedNode)
+	}
+}
+
+// sample is the most recent telemetry reading and the status derived from it
+type sample struct {
+	metrics *telemetry.Metrics
+	status  telemetry.StatusCode
+	failed  bool // Collection failed: metrics are zero and status is unknown
+}
+
+// heartbeatLoop collects telemetry and broadcasts heartbeats until stopped
+// With a TelemetryInterval set, metrics are sampled on their own ticker and
+// heartbeats in between reuse the last sample
+func (n *Node) heartbeatLoop(ctx context.Context) {
+	defer n.wg.Done()
+
+	// Track consecutive collection failures to avoid flooding the log
+	collectFailures := telemetry.NewFailureTracker(n.config.SuppressRepeatedErrors)
+
+	interval := n.nextHeartbeatInterval()
+	n.udpNode.SetHeartbeatInterval(interval)
+	heartbeatTicker := time.NewTicker(interval)
+	defer heartbeatTicker.Stop()
+
+	// A nil channel never fires, so telemetry follows heartbeats unless configured
+	var telemetryC <-chan time.Time
+	if n.config.TelemetryInterval > 0 {
+		telemetryTicker := time.NewTicker(n.config.TelemetryInterval)
+		defer telemetryTicker.Stop()
+		telemetryC = telemetryTicker.C
+	}
+
+	var last *sample
+	for {
+		select {
+		case <-n.stopChan:
+			return
+
+		case <-ctx.Done():
+			// Stop waits for this goroutine, so run it separately
+			go n.Stop()
+			return
+
+		case <-telemetryC:
+			last = n.collect(collectFailures)
+
+		case <-heartbeatTicker.C:
+			// Sample on every heartbeat, or until the first telemetry tick
+			if telemetryC == nil || last == nil {
+				last = n.collect(collectFailures)
+			}
+			n.heartbeat(last)
+			if next := n.nextHeartbeatInterval(); next != interval {
+				log.Printf("Heartbeat interval now %v for %d peers", next, n.udpNode.PeerCount())
+				interval = next
+				heartbeatTicker.Reset(interval)
+				n.udpNode.SetHeartbeatInterval(interval)
+			}
+		}
+	}
+}
+
+// collect samples telemetry and computes the local status. When collection
+// fails the sample is StatusUnknown, so peers still hear from this node
+// without it passing for OK on zero metrics
+func (n *Node) collect(collectFailures *telemetry.FailureTracker) *sample {
+	metrics, err := n.metrics.Collect()
+	if err != nil {
+		if shouldLog, count := collectFailures.Failure(); shouldLog {
+			log.Printf("Failed to collect metrics (%d consecutive failures): %v", count, err)
+		}
+		return &sample{metrics: &telemetry.Metrics{}, status: telemetry.StatusUnknown, failed: true}
+	}
+	if recovered, failures := collectFailures.Success(); recovered {
+		log.Printf("Metrics collection recovered after %d consecutive failures", failures)
+	}
+
+	return &sample{
+		metrics: metrics,
+		status:  n.status.Evaluate(metrics, time.Now()),
+	}
+}
+
+// heartbeat records the sample locally and broadcasts it to peers
+func (n *Node) heartbeat(s *sample) {
+	now := time.Now()
+	if n.chaos != nil {
+		s = &sample{metrics: s.metrics, status: n.chaos.statusAt(s.status, now), failed: s.failed}
+	}
+
+	// Update local monitor with telemetry (use local address). A failed
+	// sample has none, so the last-known readings stay
+	localAddr := n.udpNode.Conn().LocalAddr().String()
+	n.monitor.UpdateLocal(localAddr, registry.LocalUpdate{
+		UUID:             n.uuid,
+		StatusCode:       uint8(s.status),
+		HasTelemetry:     !s.failed,
+		CPUPercent:       s.metrics.CPUPercent,
+		RAMPercent:       s.metrics.RAMPercent,
+		DiskPercent:      s.metrics.DiskPercent,
+		HasNetwork:       s.metrics.HasNetwork,
+		NetRxBytesPerSec: s.metrics.NetRxBytesPerSec,
+		NetTxBytesPerSec: s.metrics.NetTxBytesPerSec,
+		Location:         n.config.Location,
+		Kubernetes:       n.config.Kubernetes,
+	})
+	if n.config.TextfileOut != "" {
+		n.writeTextfile(s)
+	}
+
+	if n.chaos != nil && n.chaos.pausedAt(now) {
+		return
+	}
+	prevStatus, first := n.lastSentStatus, n.lastSent.IsZero()
+	if !n.shouldBroadcast(s.status, now) {
+		return
+	}
+
+	// Broadcast heartbeat, repeating changes to or from CRITICAL
+	var err error
+	if n.config.CriticalRetransmit > 0 && !first &&
+		(prevStatus == telemetry.StatusCritical) != (s.status == telemetry.StatusCritical) {
+		err = n.udpNode.BroadcastHeartbeatRetransmit(uint8(s.status), n.config.CriticalRetransmit)
+	} else {
+		err = n.udpNode.BroadcastHeartbeat(uint8(s.status))
+	}
+	if err != nil {
+		log.Printf("Failed to broadcast heartbeat: %v", err)
+	}
+	n.checkRotation()
+
+	if n.pusher != nil {
+		report := relay.NewReport(n.uuid, uint16(n.Port()), uint8(s.status), s.metrics)
+		if !n.config.Location.IsZero() {
+			report.Location = &n.config.Location
+		}
+		if !n.config.Kubernetes.IsZero() {
+			report.Kubernetes = &n.config.Kubernetes
+		}
+		if n.config.PushPeerView {
+			tooLarge := !report.SetPeerView(n.monitor.PeerUUIDs())
+			if tooLarge && !n.peerViewTooLarge {
+				log.Printf("Not pushing the peer view: more than %d peers", relay.MaxPeerView)
+			}
+			n.peerViewTooLarge = tooLarge
+		}
+		n.pusher.Offer(report)
+	}
+}
+
+// checkRotation warns once each time learned peers stretch the send
+// rotation so far that a peer hears from this node no more often than the
+// Timeout, and would reap it between heartbeats
+func (n *Node) checkRotation() {
+	if n.config.PeersPerHeartbeat == 0 || n.config.Timeout <= 0 {
+		return
+	}
+	gap := n.udpNode.AdvertisedInterval()
+	tooSlow := gap >= n.config.Timeout
+	if tooSlow && !n.rotationTooSlow {
+		log.Printf("Warning: with %d peers and %d per heartbeat, each peer hears from this node every %v, not under the %v timeout; raise -peers-per-heartbeat",
+			n.udpNode.PeerCount(), n.config.PeersPerHeartbeat, gap, n.config.Timeout)
+	}
+	n.rotationTooSlow = tooSlow
+}
+
+// writeTextfile replaces TextfileOut with the sample, logging repeated
+// failures with suppression
+func (n *Node) writeTextfile(s *sample) {
+	if err := telemetry.WriteTextfile(n.config.TextfileOut, s.metrics, s.status); err != nil {
+		if shouldLog, count := n.textfileFailures.Failure(); shouldLog {
+			log.Printf("Failed to write %s (%d consecutive failures): %v", n.config.TextfileOut, count, err)
+		}
+		return
+	}
+	if recovered, failures := n.textfileFailures.Success(); recovered {
+		log.Printf("Writing %s recovered after %d consecutive failures", n.config.TextfileOut, failures)
+	}
+}
+
+// shouldBroadcast reports whether a heartbeat with status is due. Every
+// heartbeat is, unless ConditionalHeartbeat suppresses an unchanged status
+// sent less than KeepaliveInterval ago
+func (n *Node) shouldBroadcast(status telemetry.StatusCode, now time.Time) bool {
+	if n.config.ConditionalHeartbeat && !n.lastSent.IsZero() &&
+		status == n.lastSentStatus && now.Sub(n.lastSent) < n.config.KeepaliveInterval {
+		return false
+	}
+	n.lastSent = now
+	n.lastSentStatus = status
+	return true
+}
+
+// hostnameNodeID returns the node ID used when none is configured: the
+// hostname, or "unknown" if it cannot be read
+func hostnameNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
+
+// generateNodeUUID generates a 16-byte UUID from node ID or random
+func generateNodeUUID(nodeID string) [16]byte {
+	var uuid [16]byte
+
+	if nodeID == "" {
+		nodeID = hostnameNodeID()
+	}
+
+	// Generate UUID from node ID (simple hash-based approach)
+	// For production, consider using a proper UUID library
+	hash := simpleHash(nodeID)
+	copy(uuid[:], hash[:16])
+
+	// Fill remaining bytes with random if needed
+	if len(nodeID) < 16 {
+		rand.Read(uuid[len(nodeID):])
+	}
+
+	return uuid
+}
+
+// simpleHash creates a simple hash from string
+func simpleHash(s string) []byte {
+	hash := make([]byte, 16)
+	for i := 0; i < len(s) && i < 16; i++ {
+		hash[i] = s[i]
+	}
+	return hash
+}
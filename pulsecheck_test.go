@@ -0,0 +1,1289 @@
+package pulsecheck
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/clock"
+	"github.com/rafaelmarinho/pulsecheck/internal/protocol"
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+	"github.com/rafaelmarinho/pulsecheck/internal/store"
+	"github.com/rafaelmarinho/pulsecheck/internal/telemetry"
+)
+
+func TestDefaultConfig(t *testing.T) {
+	cfg := DefaultConfig()
+
+	if cfg.Port != 9999 {
+		t.Errorf("DefaultConfig() Port = %d, want 9999", cfg.Port)
+	}
+	if cfg.HeartbeatInterval != 5*time.Second {
+		t.Errorf("DefaultConfig() HeartbeatInterval = %v, want 5s", cfg.HeartbeatInterval)
+	}
+	if cfg.Timeout != 15*time.Second {
+		t.Errorf("DefaultConfig() Timeout = %v, want 15s", cfg.Timeout)
+	}
+	if cfg.FailureDetector != FailureDetectorTimeout {
+		t.Errorf("DefaultConfig() FailureDetector = %s, want %s", cfg.FailureDetector, FailureDetectorTimeout)
+	}
+	if cfg.Severity.WarnExitCode != 1 || cfg.Severity.CriticalExitCode != 2 || !cfg.Severity.WarnActionable {
+		t.Errorf("DefaultConfig() Severity = %+v, want WARN=1 CRITICAL=2 with WARN actionable", cfg.Severity)
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.FailureDetector = "bogus"
+
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for invalid failure detector")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.HeartbeatInterval = 0
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for zero heartbeat interval")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.StaticPeers = true
+	cfg.SeedNode = "127.0.0.1:1"
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a seed node with static peers")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.Output = "bogus"
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for an unknown output format")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.Template = "{{.Nodes"
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for an invalid report template")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.Output = "template"
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for template output without a template")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.Peers = []string{"not a host:port"}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for an invalid peer address")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.NetInterfaces = []string{"no-such-interface0"}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for an unknown network interface")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.PushBreakerCooldown = 0
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a push breaker without a cooldown")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.TextfileOut = filepath.Join(t.TempDir(), "missing", "pulsecheck.prom")
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a textfile in a missing directory")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.Workers = 4
+	cfg.MaxWorkers = 2
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for max workers below workers")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.Location = Location{Latitude: 123, HasCoordinates: true}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a latitude out of range")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.FederateFrom = []string{"collector-b:8080/status"}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a federation URL without a scheme")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.PeersPerHeartbeat = -1
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for negative peers per heartbeat")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportOnChange = -time.Second
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a negative report on change floor")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.SignReports = true
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for signing reports without a signing key")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.PacketDedupSize = -1
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a negative packet dedup size")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.ExpectMinNodes = -1
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a negative expected minimum node count")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.HistorySegment = 0
+	cfg.HistoryRetention = 24 * time.Hour
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for history retention without segments")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	code := 300
+	cfg.Severity.BelowMinNodesExitCode = &code
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for an exit code above 255")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.FIFOPath = filepath.Join(t.TempDir(), "metrics")
+	if err := os.WriteFile(cfg.FIFOPath, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a FIFO path that is a regular file")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.ReapBurst = -1
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for negative reap burst")
+	}
+
+	// 10 peers, 2 per heartbeat: each hears every 5 x 5s, over the 15s timeout
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.PeersPerHeartbeat = 2
+	for i := 1; i <= 10; i++ {
+		cfg.Peers = append(cfg.Peers, "10.0.0."+strconv.Itoa(i)+":9999")
+	}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a rotation slower than the timeout")
+	}
+
+	for _, priority := range []int{-1, MaxPriority + 1} {
+		cfg = DefaultConfig()
+		cfg.Port = 0
+		cfg.Priority = priority
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New() should return error for priority %d", priority)
+		}
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.Dependencies = map[string][]string{"10.0.0.1:9999": {"10.0.0.2:9999"}, "10.0.0.2:9999": {"10.0.0.1:9999"}}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a dependency cycle")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.IngestAddr = "127.0.0.1:0"
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for an ingest address without a token or trusted keys")
+	}
+
+	for _, version := range []int{0, protocol.Version + 1} {
+		cfg = DefaultConfig()
+		cfg.Port = 0
+		cfg.WireVersion = version
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New() should return error for wire version %d", version)
+		}
+	}
+
+	// Older peers would never see the priority
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.Priority = 10
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a priority with the default wire version")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.SocketReadBuffer = -1
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a negative socket receive buffer size")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.DSCP = 64
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a DSCP value above 63")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.Thresholds.Metrics = []MetricThreshold{{Name: "battery", Warn: 5, Critical: 20, Direction: LowerIsWorse}}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a lower-is-worse metric warning below critical")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.AlertGracePeriod = -time.Second
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a negative alert grace period")
+	}
+}
+
+func TestNodeStartStop(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.NodeID = "embedded-test-node"
+	cfg.HeartbeatInterval = 20 * time.Millisecond
+	cfg.ReportInterval = 0
+
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if node.Port() == 0 {
+		t.Error("Port() = 0, want assigned ephemeral port")
+	}
+
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := node.Start(context.Background()); err == nil {
+		t.Error("Start() twice should return error")
+	}
+
+	// The heartbeat loop records the local node's own telemetry
+	deadline := time.Now().Add(2 * time.Second)
+	for node.Monitor().GetNodeCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if node.Monitor().GetNodeCount() == 0 {
+		t.Error("Monitor() has no nodes after heartbeats, want local node")
+	}
+
+	node.Stop()
+	node.Stop() // Must be safe to call twice
+}
+
+// decodeBaseline decodes a heartbeat the way the first release does: it
+// accepts exactly 30 bytes, 26 of data and a CRC32 over them
+func decodeBaseline(data []byte) ([16]byte, uint8, error) {
+	var nodeUUID [16]byte
+	if len(data) != 30 {
+		return nodeUUID, 0, errors.New("invalid packet size")
+	}
+	if binary.BigEndian.Uint32(data[26:30]) != crc32.ChecksumIEEE(data[:26]) {
+		return nodeUUID, 0, errors.New("packet checksum verification failed")
+	}
+	copy(nodeUUID[:], data[1:17])
+	return nodeUUID, data[25], nil
+}
+
+func TestNodeDefaultWireVersionDecodesOnFirstRelease(t *testing.T) {
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP() error = %v", err)
+	}
+	defer peer.Close()
+
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.HeartbeatInterval = 20 * time.Millisecond
+	cfg.ReportInterval = 0
+	cfg.Peers = []string{peer.LocalAddr().String()}
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer node.Stop()
+
+	buf := make([]byte, protocol.MaxPacketSize)
+	peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := peer.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("ReadFromUDP() error = %v", err)
+	}
+	nodeUUID, _, err := decodeBaseline(buf[:n])
+	if err != nil {
+		t.Fatalf("first release decode of a %d-byte default heartbeat: %v", n, err)
+	}
+	if nodeUUID != node.UUID() {
+		t.Errorf("decoded UUID = %x, want %x", nodeUUID, node.UUID())
+	}
+}
+
+func TestNodeStopsOnContextCancel(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := node.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	cancel()
+
+	select {
+	case <-node.stopChan:
+	case <-time.After(time.Second):
+		t.Error("Node did not stop after context cancellation")
+	}
+	node.Stop()
+}
+
+func TestGenerateNodeUUID(t *testing.T) {
+	a := generateNodeUUID("node-a-long-identifier")
+	b := generateNodeUUID("node-a-long-identifier")
+
+	if a != b {
+		t.Error("generateNodeUUID() should be deterministic for IDs of 16+ bytes")
+	}
+}
+
+func TestParseSilences(t *testing.T) {
+	got, err := ParseSilences("10.0.0.5:9999=2h, [fd00::1]:9999=30m")
+	if err != nil {
+		t.Fatalf("ParseSilences() error = %v", err)
+	}
+	if got["10.0.0.5:9999"] != 2*time.Hour || got["[fd00::1]:9999"] != 30*time.Minute {
+		t.Errorf("ParseSilences() = %v", got)
+	}
+
+	if got, err := ParseSilences(""); err != nil || len(got) != 0 {
+		t.Errorf("ParseSilences(\"\") = %v, %v; want empty", got, err)
+	}
+
+	for _, spec := range []string{"10.0.0.5:9999", "=2h", "10.0.0.5:9999=soon", "10.0.0.5:9999=-1h"} {
+		if _, err := ParseSilences(spec); err == nil {
+			t.Errorf("ParseSilences(%q) should return error", spec)
+		}
+	}
+}
+
+func TestParseDependencies(t *testing.T) {
+	got, err := ParseDependencies("10.0.0.3:9999=10.0.0.1:9999, 10.0.0.3:9999=10.0.0.2:9999,10.0.0.4:9999=10.0.0.1:9999")
+	if err != nil {
+		t.Fatalf("ParseDependencies() error = %v", err)
+	}
+	want := map[string][]string{
+		"10.0.0.3:9999": {"10.0.0.1:9999", "10.0.0.2:9999"},
+		"10.0.0.4:9999": {"10.0.0.1:9999"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDependencies() = %v, want %v", got, want)
+	}
+
+	if got, err := ParseDependencies(""); err != nil || len(got) != 0 {
+		t.Errorf("ParseDependencies(\"\") = %v, %v; want empty", got, err)
+	}
+
+	for _, spec := range []string{"10.0.0.3:9999", "=10.0.0.1:9999", "10.0.0.3:9999=", "10.0.0.3:9999=10.0.0.3:9999", "a:1=b:1,b:1=c:1,c:1=a:1"} {
+		if _, err := ParseDependencies(spec); err == nil {
+			t.Errorf("ParseDependencies(%q) should return error", spec)
+		}
+	}
+}
+
+func TestNodeTelemetryInterval(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.HeartbeatInterval = 20 * time.Millisecond
+	cfg.TelemetryInterval = time.Hour
+	cfg.ReportInterval = 0
+
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer node.Stop()
+
+	// The first heartbeat samples telemetry rather than waiting an hour
+	localAddr := node.udpNode.Conn().LocalAddr().String()
+	deadline := time.Now().Add(2 * time.Second)
+	var first NodeInfo
+	for time.Now().Before(deadline) {
+		if info, ok := node.Monitor().GetNodeInfo(localAddr); ok {
+			first = info
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !first.HasTelemetry {
+		t.Fatal("local node has no telemetry after first heartbeat")
+	}
+
+	// Later heartbeats reuse the sample and keep the local node fresh
+	time.Sleep(100 * time.Millisecond)
+	info, _ := node.Monitor().GetNodeInfo(localAddr)
+	if !info.LastSeen.After(first.LastSeen) {
+		t.Error("heartbeats between telemetry samples did not refresh the local node")
+	}
+	if info.CPUPercent != first.CPUPercent || info.RAMPercent != first.RAMPercent {
+		t.Error("heartbeats between telemetry samples should reuse the last metrics")
+	}
+
+	cfg.TelemetryInterval = -time.Second
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should reject a negative telemetry interval")
+	}
+}
+
+func TestNewInvalidConfigReleasesStore(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.StorePath = filepath.Join(t.TempDir(), "history.db")
+	cfg.FIFOPath = filepath.Join(t.TempDir(), "metrics")
+	if err := os.WriteFile(cfg.FIFOPath, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(cfg); err == nil {
+		t.Fatal("New() should return error for a FIFO path that is a regular file")
+	}
+
+	// The failed New closed the database, so it is not still locked
+	st, err := store.Open(cfg.StorePath, registry.NewMonitor())
+	if err != nil {
+		t.Fatalf("store.Open() after a failed New() error = %v", err)
+	}
+	st.Stop()
+}
+
+func TestNodeHistory(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.HeartbeatInterval = 20 * time.Millisecond
+	cfg.ReportInterval = 0
+
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := node.History("", time.Time{}, time.Time{}); err == nil {
+		t.Error("History() without a store should return error")
+	}
+	node.Stop()
+
+	cfg.StorePath = filepath.Join(t.TempDir(), "history.db")
+	node, err = New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	defer node.Stop()
+
+	// The first heartbeat registers the local node, which is recorded as
+	// joined once the store's writer gets to it
+	var records []HistoryRecord
+	deadline := time.Now().Add(2 * time.Second)
+	for len(records) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		if records, err = node.History("", time.Time{}, time.Time{}); err != nil {
+			t.Fatalf("History() error = %v", err)
+		}
+	}
+	if len(records) == 0 || records[0].Event != "joined" {
+		t.Fatalf("History() = %+v, want a joined event first", records)
+	}
+
+	// The same records are served on HistoryPath
+	handler := node.historyHandler()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, HistoryPath+"?node="+records[0].Address, nil))
+	var served []HistoryRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil || len(served) == 0 || served[0].Event != "joined" {
+		t.Errorf("GET %s = %d %s, want the joined event", HistoryPath, rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, HistoryPath+"?from=2030-01-01T00:00:00Z", nil))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("GET %s in the future = %d %s, want an empty array", HistoryPath, rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, HistoryPath+"?to=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET %s with an invalid time = %d, want 400", HistoryPath, rec.Code)
+	}
+}
+
+func TestNodeIsActive(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !node.IsActive() {
+		t.Error("IsActive() = false without collectors, want true")
+	}
+	node.Stop()
+
+	cfg.Collectors = []string{"not a host:port"}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for invalid collector address")
+	}
+
+	cfg.Collectors = []string{"127.0.0.1:1"}
+	cfg.LeaseTTL = 50 * time.Millisecond
+	node, err = New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer node.Stop()
+	fake := clock.NewFake(time.Now())
+	node.Monitor().SetClock(fake)
+
+	if !node.IsActive() {
+		t.Error("IsActive() = false before the other collector is seen, want true")
+	}
+
+	// A live collector with a lower UUID holds the lease
+	var lower [16]byte
+	lower[15] = 1
+	node.Monitor().UpdateWithHeartbeat("127.0.0.1:1", lower, 0, time.Now().UnixNano())
+	if node.IsActive() {
+		t.Error("IsActive() = true with a live lower-UUID collector, want standby")
+	}
+
+	// Its lease lapses once it goes silent
+	fake.Advance(2 * cfg.LeaseTTL)
+	if !node.IsActive() {
+		t.Error("IsActive() = false after the other collector's lease lapsed, want true")
+	}
+}
+
+func TestNodePushRelay(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	cfg.IngestAddr = "127.0.0.1:0"
+	cfg.StatusAddr = "127.0.0.1:0"
+	cfg.IngestToken = "secret"
+	collector, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() collector error = %v", err)
+	}
+	if err := collector.Start(context.Background()); err != nil {
+		t.Fatalf("Start() collector error = %v", err)
+	}
+	defer collector.Stop()
+	if collector.IngestAddr() == "" {
+		t.Fatal("IngestAddr() is empty after Start")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	cfg.HeartbeatInterval = 20 * time.Millisecond
+	cfg.PushURL = "http://" + collector.IngestAddr() + "/ingest"
+	cfg.IngestToken = "secret"
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() node error = %v", err)
+	}
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Start() node error = %v", err)
+	}
+	defer node.Stop()
+
+	// The collector learns of the node only through pushed reports
+	pushedAddr := "127.0.0.1:" + strconv.Itoa(node.Port())
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := collector.Monitor().GetNodeInfo(pushedAddr); ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	info, ok := collector.Monitor().GetNodeInfo(pushedAddr)
+	if !ok || info.UUID != node.UUID() || !info.HasTelemetry {
+		t.Errorf("pushed node at %s = %+v (found=%v)", pushedAddr, info, ok)
+	}
+
+	// Reads and writes are served on separate listeners, and writes need
+	// the token
+	client := &http.Client{Timeout: time.Second}
+	for _, tt := range []struct {
+		method, url string
+		want        int
+	}{
+		{http.MethodGet, "http://" + collector.IngestAddr() + "/status", http.StatusNotFound},
+		{http.MethodPost, "http://" + collector.StatusAddr() + "/ingest", http.StatusNotFound},
+		{http.MethodPost, "http://" + collector.IngestAddr() + "/ingest", http.StatusUnauthorized},
+		{http.MethodGet, "http://" + collector.StatusAddr() + "/status", http.StatusOK},
+	} {
+		req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(`{"node_uuid":"00000000000000000000000000000001"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s error = %v", tt.method, tt.url, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.url, resp.StatusCode, tt.want)
+		}
+	}
+
+	cfg.PushURL = "udp://collector:9999"
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should reject a non-HTTP push URL")
+	}
+}
+
+func TestNodeDumpSnapshot(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer node.Stop()
+	node.Monitor().UpdateWithStatus("10.0.0.1:9999", 1, 0)
+
+	var buf bytes.Buffer
+	if err := node.DumpSnapshot(&buf); err != nil {
+		t.Fatalf("DumpSnapshot() error = %v", err)
+	}
+	var snap NodeSnapshot
+	if err := json.Unmarshal(buf.Bytes(), &snap); err != nil {
+		t.Fatalf("DumpSnapshot() wrote invalid JSON: %v", err)
+	}
+	if snap.Port != node.Port() || snap.UUID == "" {
+		t.Errorf("snapshot identity = %s port %d, want node's", snap.UUID, snap.Port)
+	}
+	if info, ok := snap.Nodes["10.0.0.1:9999"]; !ok || info.StatusCode != 1 || snap.Summary.Warn != 1 {
+		t.Errorf("snapshot nodes = %+v, summary = %+v", snap.Nodes, snap.Summary)
+	}
+}
+
+func TestNodeConditionalHeartbeat(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ConditionalHeartbeat = true
+	cfg.KeepaliveInterval = cfg.Timeout
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a keepalive interval not under the timeout")
+	}
+
+	cfg.KeepaliveInterval = 0
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer node.Stop()
+	if node.config.KeepaliveInterval != cfg.Timeout/2 {
+		t.Errorf("KeepaliveInterval = %v, want default %v", node.config.KeepaliveInterval, cfg.Timeout/2)
+	}
+
+	now := time.Now()
+	steps := []struct {
+		after  time.Duration
+		status telemetry.StatusCode
+		want   bool
+	}{
+		{0, telemetry.StatusOK, true},                // First heartbeat
+		{5 * time.Second, telemetry.StatusOK, false}, // Unchanged
+		{5 * time.Second, telemetry.StatusWarn, true},
+		{5 * time.Second, telemetry.StatusWarn, false},
+		{10 * time.Second, telemetry.StatusWarn, true}, // Keepalive due
+	}
+	for i, step := range steps {
+		now = now.Add(step.after)
+		if got := node.shouldBroadcast(step.status, now); got != step.want {
+			t.Errorf("step %d: shouldBroadcast(%v) = %v, want %v", i, step.status, got, step.want)
+		}
+	}
+
+	// Without the option every heartbeat is sent
+	node.config.ConditionalHeartbeat = false
+	if !node.shouldBroadcast(telemetry.StatusWarn, now) {
+		t.Error("shouldBroadcast() = false without ConditionalHeartbeat, want true")
+	}
+}
+
+func TestNodeTemplateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.tmpl")
+	if err := os.WriteFile(path, []byte("{{range .Nodes}}{{.Adress}}{{end}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.Output = "template"
+	cfg.TemplateFile = path
+	if _, err := New(cfg); err == nil || !strings.Contains(err.Error(), "nodes.tmpl") {
+		t.Errorf("New() error = %v, want a template error naming nodes.tmpl", err)
+	}
+
+	if err := os.WriteFile(path, []byte("{{.NodeCount}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	node.Stop()
+}
+
+func TestStatusCheck(t *testing.T) {
+	check := statusCheck(telemetry.DefaultThresholds(), "127.0.0.1:9999")
+
+	remote := NodeInfo{Address: "10.0.0.1:9999", HasTelemetry: true, CPUPercent: 95}
+	if code, ok := check(remote); !ok || code != uint8(telemetry.StatusCritical) {
+		t.Errorf("check(remote at 95%% CPU) = %d, %v; want CRITICAL", code, ok)
+	}
+	if _, ok := check(NodeInfo{Address: "10.0.0.2:9999"}); ok {
+		t.Error("check() recomputed a node without telemetry")
+	}
+	local := remote
+	local.Address = "127.0.0.1:9999"
+	if _, ok := check(local); ok {
+		t.Error("check() recomputed the local node")
+	}
+}
+
+func TestNodeEventLogWithStore(t *testing.T) {
+	var events bytes.Buffer
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	cfg.EventLog = &events
+	cfg.StorePath = filepath.Join(t.TempDir(), "history.db")
+
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	node.Monitor().UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	node.Stop()
+
+	// Both sinks see the event
+	if !strings.Contains(events.String(), `"type":"joined","addr":"10.0.0.1:9999"`) {
+		t.Errorf("event log = %q, want the join", events.String())
+	}
+	st, err := store.Open(cfg.StorePath, nil)
+	if err != nil {
+		t.Fatalf("store.Open() error = %v", err)
+	}
+	defer st.Stop()
+	history, err := st.Query("10.0.0.1:9999", time.Time{}, time.Time{})
+	if err != nil || len(history) != 1 || history[0].Event != "joined" {
+		t.Errorf("history = %+v, %v; want the join", history, err)
+	}
+}
+
+func TestNodeStatsHandler(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer node.Stop()
+
+	rec := httptest.NewRecorder()
+	node.statsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, StatsPath, nil))
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d %s", StatsPath, rec.Code, rec.Body)
+	}
+	if !strings.Contains(string(body["network"]), `"ChecksumFailures":0`) || !strings.Contains(string(body["reaper"]), `"nodes_reaped_total":0`) {
+		t.Errorf("GET %s = %s, want the network and reaper counters", StatsPath, rec.Body)
+	}
+	if _, ok := body["push_breaker"]; ok {
+		t.Errorf("GET %s = %s, want no push breaker without PushURL", StatsPath, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	node.statsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, StatsPath, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST %s = %d, want 405", StatsPath, rec.Code)
+	}
+}
+
+func TestNodeEventStream(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	cfg.StatusAddr = "127.0.0.1:0"
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer node.Stop()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + node.StatusAddr() + "/events/stream")
+	if err != nil {
+		t.Fatalf("GET /events/stream error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /events/stream status = %d", resp.StatusCode)
+	}
+
+	// The headers are flushed only after the client is subscribed
+	node.Monitor().UpdateWithStatus("10.0.0.9:9999", 0, 0)
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		if strings.Contains(lines.Text(), `"type":"joined","addr":"10.0.0.9:9999"`) {
+			return
+		}
+	}
+	t.Errorf("stream ended without the join: %v", lines.Err())
+}
+
+func TestNodeConfigEndpoint(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	cfg.StatusAddr = "127.0.0.1:0"
+	cfg.NodeID = "web-1"
+	cfg.Thresholds.CPUWarn = 55
+	cfg.Thresholds.DiskFreeCriticalBytes = 1 << 30
+	cfg.CriticalSustain = 30 * time.Second
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer node.Stop()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + node.StatusAddr() + ConfigPath)
+	if err != nil {
+		t.Fatalf("GET /config error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /config status = %d", resp.StatusCode)
+	}
+	var got configJSON
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode /config: %v", err)
+	}
+	if got.NodeID != "web-1" || got.CriticalSustain != "30s" {
+		t.Errorf("config = %+v, want node web-1 with 30s sustain", got)
+	}
+	if got.Thresholds.CPUWarn != 55 || got.Thresholds.CPUCritical != 90 || got.Thresholds.DiskFreeCriticalBytes != 1<<30 {
+		t.Errorf("thresholds = %+v, want configured values", got.Thresholds)
+	}
+
+	// Without a node ID the hostname it defaults to is reported
+	cfg.NodeID = ""
+	cfg.StatusAddr = ""
+	defaulted, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer defaulted.Stop()
+	rec := httptest.NewRecorder()
+	defaulted.configHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ConfigPath, nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got.NodeID != hostnameNodeID() {
+		t.Errorf("node_id = %q, %v; want the hostname %q", got.NodeID, err, hostnameNodeID())
+	}
+
+	post, err := client.Post("http://"+node.StatusAddr()+ConfigPath, "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST /config error: %v", err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST /config status = %d, want 405", post.StatusCode)
+	}
+}
+
+func TestNodeHealthEndpoints(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	cfg.HeartbeatInterval = 20 * time.Millisecond
+	cfg.StatusAddr = "127.0.0.1:0"
+	cfg.AlertGracePeriod = time.Minute
+	cfg.SelfCheckAfter = 200 * time.Millisecond
+	cfg.Peers = []string{"127.0.0.1:9"} // Discards our heartbeats and never answers
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer node.Stop()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	get := func(path string) (int, healthJSON) {
+		t.Helper()
+		resp, err := client.Get("http://" + node.StatusAddr() + path)
+		if err != nil {
+			t.Fatalf("GET %s error: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var body healthJSON
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decode %s: %v", path, err)
+		}
+		return resp.StatusCode, body
+	}
+
+	if code, body := get(HealthzPath); code != http.StatusOK || !body.Healthy {
+		t.Errorf("GET /healthz at startup = %d %+v, want 200 healthy", code, body)
+	}
+	if code, body := get(ReadyzPath); code != http.StatusServiceUnavailable || !body.Settling {
+		t.Errorf("GET /readyz in the grace period = %d %+v, want 503 settling", code, body)
+	}
+
+	// The peer never answers. That is not this node's fault, so a restart
+	// would not help: it is live, but not ready
+	deadline := time.Now().Add(3 * time.Second)
+	for node.TrafficHealth().Ready() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	code, body := get(HealthzPath)
+	if code != http.StatusOK || !body.Healthy || body.Ready || body.LastSent == nil {
+		t.Fatalf("GET /healthz with a silent peer = %d %+v, want 200 healthy with sends", code, body)
+	}
+	if len(body.Warnings) != 1 || !strings.HasPrefix(body.Warnings[0], "nothing received") {
+		t.Errorf("warnings = %q, want nothing received", body.Warnings)
+	}
+	if code, body := get(ReadyzPath); code != http.StatusServiceUnavailable || body.Ready {
+		t.Errorf("GET /readyz with a silent peer = %d %+v, want 503", code, body)
+	}
+}
+
+func TestSelfCheckWindowDefault(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.Timeout = 20 * time.Second
+	cfg.HeartbeatInterval = 5 * time.Second
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if node.config.SelfCheckAfter != 40*time.Second {
+		t.Errorf("SelfCheckAfter = %v, want twice the timeout", node.config.SelfCheckAfter)
+	}
+
+	cfg.HeartbeatInterval = 19 * time.Second
+	if node, err = New(cfg); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if node.config.SelfCheckAfter != 57*time.Second {
+		t.Errorf("SelfCheckAfter = %v, want three heartbeat intervals", node.config.SelfCheckAfter)
+	}
+
+	cfg.SelfCheckAfter = -time.Second
+	if node, err = New(cfg); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if h := node.TrafficHealth(); !h.Healthy {
+		t.Errorf("TrafficHealth() with the check disabled = %+v", h)
+	}
+}
+
+func TestNodeTextfileOut(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.HeartbeatInterval = 20 * time.Millisecond
+	cfg.ReportInterval = 0
+	cfg.TextfileOut = filepath.Join(t.TempDir(), "pulsecheck.prom")
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer node.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(cfg.TextfileOut); err == nil {
+			if !strings.Contains(string(data), "pulsecheck_status ") {
+				t.Errorf("textfile missing pulsecheck_status:\n%s", data)
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Error("heartbeat did not write the textfile")
+}
+
+func TestNodeAlertGracePeriod(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	cfg.AlertGracePeriod = time.Hour
+
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	fake := clock.NewFake(time.Now())
+	node.reporter.SetClock(fake)
+	if node.InAlertGrace() {
+		t.Error("InAlertGrace() = true before Start")
+	}
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer node.Stop()
+
+	node.Monitor().UpdateWithStatus("10.0.0.9:9999", 2, 0)
+	if !node.InAlertGrace() {
+		t.Error("InAlertGrace() = false right after Start")
+	}
+	if got := node.ExitCode(); got != cfg.Severity.OKExitCode {
+		t.Errorf("ExitCode() during grace = %d, want %d", got, cfg.Severity.OKExitCode)
+	}
+
+	fake.Advance(cfg.AlertGracePeriod)
+	if node.InAlertGrace() {
+		t.Error("InAlertGrace() = true after AlertGracePeriod")
+	}
+	if got := node.ExitCode(); got != cfg.Severity.CriticalExitCode {
+		t.Errorf("ExitCode() after grace = %d, want %d", got, cfg.Severity.CriticalExitCode)
+	}
+}
+
+func TestNodeExpectMinNodes(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	cfg.ExpectMinNodes = 2
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer node.Stop()
+
+	if got := node.ExitCode(); got != cfg.Severity.CriticalExitCode {
+		t.Errorf("ExitCode() with no nodes = %d, want %d", got, cfg.Severity.CriticalExitCode)
+	}
+	node.Monitor().UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	if got := node.ExitCode(); got != cfg.Severity.CriticalExitCode {
+		t.Errorf("ExitCode() with 1 OK node = %d, want %d", got, cfg.Severity.CriticalExitCode)
+	}
+	node.Monitor().UpdateWithStatus("10.0.0.2:9999", 0, 0)
+	if got := node.ExitCode(); got != cfg.Severity.OKExitCode {
+		t.Errorf("ExitCode() with 2 OK nodes = %d, want %d", got, cfg.Severity.OKExitCode)
+	}
+}
+
+// brokenBackend fails every reading
+type brokenBackend struct{}
+
+func (brokenBackend) CPUPercent() (float64, error)   { return 0, errors.New("no cpu") }
+func (brokenBackend) Memory() (MemoryUsage, error)   { return MemoryUsage{}, errors.New("no memory") }
+func (brokenBackend) Disk(string) (DiskUsage, error) { return DiskUsage{}, errors.New("no disk") }
+
+func TestNodeCollectFailureIsUnknown(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	cfg.MetricsBackend = brokenBackend{}
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer node.Stop()
+
+	// The heartbeat still goes out, as UNKNOWN rather than OK on zeros
+	s := node.collect(telemetry.NewFailureTracker(true))
+	if s.status != telemetry.StatusUnknown {
+		t.Errorf("collect() status = %v, want StatusUnknown", s.status)
+	}
+	node.heartbeat(s)
+	info, ok := node.Monitor().GetNodeInfo(node.udpNode.Conn().LocalAddr().String())
+	if !ok || info.StatusCode != uint8(telemetry.StatusUnknown) || info.HasTelemetry {
+		t.Errorf("local entry = %+v, %v, want UNKNOWN without telemetry", info, ok)
+	}
+}
+
+func TestNodeHeartbeatLocalEntryIsAtomic(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	cfg.Location = Location{Datacenter: "eu-west-1a", Latitude: 53.35, Longitude: -6.26, HasCoordinates: true}
+	cfg.Kubernetes = Kubernetes{Pod: "api-0", Namespace: "prod", Node: "ip-10-0-0-1"}
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer node.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		metrics := &telemetry.Metrics{CPUPercent: 10, HasNetwork: true, NetRxBytesPerSec: 1}
+		for i := 0; i < 20000; i++ {
+			node.heartbeat(&sample{metrics: metrics, status: telemetry.StatusOK, failed: i%3 == 0})
+		}
+	}()
+
+	// Readers such as /status and federating peers never see the local
+	// entry without the fields every heartbeat carries
+	for {
+		for addr, info := range node.Monitor().GetNodes() {
+			if info.UUID != node.UUID() || info.Location != cfg.Location || info.Kubernetes != cfg.Kubernetes {
+				t.Fatalf("local entry %s read as %+v, want UUID %x, location %+v and pod %+v",
+					addr, info, node.UUID(), cfg.Location, cfg.Kubernetes)
+			}
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
+func TestNodeExpectMinNodesWithoutPeers(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	cfg.ExpectMinNodes = 1
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer node.Stop()
+
+	// An isolated node only knows its own entry, which is not a peer
+	node.heartbeat(&sample{metrics: &telemetry.Metrics{}, status: telemetry.StatusOK})
+	if got := node.Monitor().GetNodeCount(); got != 1 {
+		t.Fatalf("GetNodeCount() = %d, want the local entry only", got)
+	}
+	if got := node.ExitCode(); got != cfg.Severity.CriticalExitCode {
+		t.Errorf("ExitCode() with no peers = %d, want %d", got, cfg.Severity.CriticalExitCode)
+	}
+	node.Monitor().UpdateWithStatus("10.0.0.1:9999", 0, 0)
+	if got := node.ExitCode(); got != cfg.Severity.OKExitCode {
+		t.Errorf("ExitCode() with 1 peer = %d, want %d", got, cfg.Severity.OKExitCode)
+	}
+}
+
+func TestNodeIdentityFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identity")
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.NodeID = "short" // Padded with random bytes, so unstable without the file
+	cfg.IdentityFile = path
+
+	first, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	first.Stop()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("identity file not written: %v", err)
+	}
+	if got, want := strings.TrimSpace(string(data)), hex.EncodeToString(first.uuid[:]); got != want {
+		t.Errorf("identity file = %q, want %q", got, want)
+	}
+
+	second, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	second.Stop()
+	if second.uuid != first.uuid {
+		t.Errorf("UUID after restart = %x, want %x", second.uuid, first.uuid)
+	}
+
+	if err := os.WriteFile(path, []byte("not a uuid\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() should return error for a corrupt identity file")
+	}
+}
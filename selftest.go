@@ -0,0 +1,75 @@
+package pulsecheck
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/registry"
+)
+
+// selfTestStatus is the status code carried by the self-test heartbeat, so
+// a stale or foreign packet cannot pass for it
+const selfTestStatus = 1
+
+// SelfTest checks that this host can run a node: it binds an ephemeral UDP
+// port, sends a heartbeat to itself over loopback and waits for the listener
+// to decode it into a monitor. This is the same network, protocol and
+// registry path a running node uses. It returns the round-trip time, or an
+// error naming the step that failed
+func SelfTest(timeout time.Duration) (time.Duration, error) {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		return 0, fmt.Errorf("generate node UUID: %w", err)
+	}
+
+	monitor := registry.NewMonitor()
+	joined := make(chan struct{}, 1)
+	monitor.SetEventHandler(func(e registry.Event) {
+		if e.Type == registry.EventJoined {
+			select {
+			case joined <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	udpNode, err := registry.NewUDPNode(0, uuid, monitor)
+	if err != nil {
+		return 0, fmt.Errorf("bind: %w", err)
+	}
+	udpNode.SetAcceptSelf(true)
+	go udpNode.Start()
+	defer udpNode.Stop()
+
+	self := net.JoinHostPort("127.0.0.1", strconv.Itoa(udpNode.Port()))
+	if err := udpNode.AddPeer(self); err != nil {
+		return 0, fmt.Errorf("resolve loopback address: %w", err)
+	}
+
+	start := time.Now()
+	if err := udpNode.BroadcastHeartbeat(selfTestStatus); err != nil {
+		return 0, fmt.Errorf("encode and send: %w", err)
+	}
+
+	select {
+	case <-joined:
+	case <-time.After(timeout):
+		stats := udpNode.Stats()
+		return 0, fmt.Errorf("receive: no heartbeat decoded within %v (sent %d, received %d, invalid size %d, checksum failures %d)",
+			timeout, stats.PacketsSent, stats.PacketsReceived, stats.InvalidSize, stats.ChecksumFailures)
+	}
+	rtt := time.Since(start)
+
+	info, ok := monitor.GetNodeInfo(self)
+	if !ok {
+		return 0, fmt.Errorf("monitor: heartbeat recorded under an unexpected address, want %s", self)
+	}
+	if info.UUID != uuid || info.StatusCode != selfTestStatus {
+		return 0, errors.New("monitor: recorded heartbeat does not match the one sent")
+	}
+	return rtt, nil
+}
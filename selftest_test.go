@@ -0,0 +1,16 @@
+package pulsecheck
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelfTest(t *testing.T) {
+	rtt, err := SelfTest(2 * time.Second)
+	if err != nil {
+		t.Fatalf("SelfTest() error = %v", err)
+	}
+	if rtt <= 0 {
+		t.Errorf("SelfTest() rtt = %v, want positive", rtt)
+	}
+}
@@ -0,0 +1,95 @@
+package pulsecheck
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rafaelmarinho/pulsecheck/internal/display"
+)
+
+// LoadSigningKey reads an Ed25519 private key from a PEM-encoded PKCS#8 file,
+// as written by `openssl genpkey -algorithm ed25519`
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 private key", path)
+	}
+	return key, nil
+}
+
+// LoadTrustedKeys reads the public keys of the nodes allowed to send
+// heartbeats. Each line holds a node UUID in hex and its base64 public key,
+// as logged by that node on start. Blank lines and # comments are ignored
+func LoadTrustedKeys(path string) (map[[16]byte]ed25519.PublicKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	keys := make(map[[16]byte]ed25519.PublicKey)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected <uuid> <public key>", path, line)
+		}
+		uuid, err := parseNodeUUID(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		pub, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s:%d: invalid Ed25519 public key", path, line)
+		}
+		keys[uuid] = ed25519.PublicKey(pub)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// VerifyReports checks the signed reports in a stream written with
+// Config.SignReports against the node's public key, returning how many
+// verified. It fails on the first report that was altered, or that does
+// not follow the one before it
+func VerifyReports(r io.Reader, key ed25519.PublicKey) (int, error) {
+	return display.VerifyReports(r, key)
+}
+
+// parseNodeUUID parses a 16-byte node UUID written as 32 hex digits
+func parseNodeUUID(s string) ([16]byte, error) {
+	var uuid [16]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(uuid) {
+		return uuid, errors.New("invalid node UUID: expected 32 hex digits")
+	}
+	copy(uuid[:], b)
+	return uuid, nil
+}
@@ -0,0 +1,75 @@
+package pulsecheck
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSigningKey(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "node.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := LoadSigningKey(path)
+	if err != nil {
+		t.Fatalf("LoadSigningKey() error = %v", err)
+	}
+	if !key.Equal(priv) {
+		t.Error("LoadSigningKey() returned a different key")
+	}
+
+	bad := filepath.Join(dir, "bad.pem")
+	os.WriteFile(bad, []byte("not a key"), 0o600)
+	if _, err := LoadSigningKey(bad); err == nil {
+		t.Error("LoadSigningKey() should reject a file without a PEM block")
+	}
+}
+
+func TestLoadTrustedKeys(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	uuid := generateNodeUUID("trusted-node")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keys.txt")
+	content := fmt.Sprintf("# trusted nodes\n\n%x %s # node a\n", uuid, base64.StdEncoding.EncodeToString(pub))
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadTrustedKeys(path)
+	if err != nil {
+		t.Fatalf("LoadTrustedKeys() error = %v", err)
+	}
+	if len(keys) != 1 || !keys[uuid].Equal(pub) {
+		t.Errorf("LoadTrustedKeys() = %v, want the key for %x", keys, uuid)
+	}
+
+	for _, line := range []string{
+		fmt.Sprintf("%x", uuid),
+		fmt.Sprintf("not-hex %s", base64.StdEncoding.EncodeToString(pub)),
+		fmt.Sprintf("%x c2hvcnQ=", uuid),
+	} {
+		os.WriteFile(path, []byte(line+"\n"), 0o600)
+		if _, err := LoadTrustedKeys(path); err == nil {
+			t.Errorf("LoadTrustedKeys() should reject %q", line)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package pulsecheck
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SilencePath is the HTTP endpoint managing maintenance windows, served on
+// IngestAddr to requests carrying IngestToken
+const SilencePath = "/silence"
+
+// silenceHandler serves SilencePath. GET lists the active windows as
+// address to end time, POST silences the node in addr for a duration in
+// for or until an RFC 3339 time in until, and DELETE ends the window of
+// the node in addr early. Every method replies with the active windows
+func (n *Node) silenceHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			addr, until, err := parseSilenceForm(req, time.Now())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			n.monitor.Silence(addr, until)
+		case http.MethodDelete:
+			if err := req.ParseForm(); err != nil || req.Form.Get("addr") == "" {
+				http.Error(w, "addr is required", http.StatusBadRequest)
+				return
+			}
+			n.monitor.Unsilence(req.Form.Get("addr"))
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(n.monitor.Silences())
+	})
+}
+
+// parseSilenceForm reads the node and window end of a POST to SilencePath.
+// Exactly one of for and until must be given, and the window must end
+// after now
+func parseSilenceForm(req *http.Request, now time.Time) (string, time.Time, error) {
+	if err := req.ParseForm(); err != nil {
+		return "", time.Time{}, err
+	}
+	addr := req.Form.Get("addr")
+	if addr == "" {
+		return "", time.Time{}, errors.New("addr is required")
+	}
+	window, end := req.Form.Get("for"), req.Form.Get("until")
+	var until time.Time
+	switch {
+	case window != "" && end != "":
+		return "", time.Time{}, errors.New("give either for or until, not both")
+	case window != "":
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("invalid for %q: %w", window, err)
+		}
+		until = now.Add(d)
+	case end != "":
+		t, err := time.Parse(time.RFC3339, end)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("invalid until %q: expected an RFC 3339 time", end)
+		}
+		until = t
+	default:
+		return "", time.Time{}, errors.New("for or until is required, e.g. for=2h")
+	}
+	if !until.After(now) {
+		return "", time.Time{}, errors.New("the window must end in the future")
+	}
+	return addr, until, nil
+}
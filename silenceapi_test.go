@@ -0,0 +1,69 @@
+package pulsecheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSilenceHandler(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.ReportInterval = 0
+	node, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(node.Stop)
+	handler := node.silenceHandler()
+	do := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/silence?addr=10.0.0.5:9999&for=2h"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"10.0.0.5:9999"`) {
+		t.Fatalf("POST = %d %s, want the new window", rec.Code, rec.Body)
+	}
+	until := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	if rec := do(http.MethodPost, "/silence?addr=10.0.0.6:9999&until="+until); rec.Code != http.StatusOK {
+		t.Fatalf("POST with until = %d %s", rec.Code, rec.Body)
+	}
+	if got := len(node.monitor.Silences()); got != 2 {
+		t.Errorf("%d windows active, want 2", got)
+	}
+
+	// Invalid requests silence nothing
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	for _, target := range []string{
+		"/silence?for=2h",
+		"/silence?addr=10.0.0.7:9999",
+		"/silence?addr=10.0.0.7:9999&for=soon",
+		"/silence?addr=10.0.0.7:9999&for=-1h",
+		"/silence?addr=10.0.0.7:9999&until=tomorrow",
+		"/silence?addr=10.0.0.7:9999&until=" + past,
+		"/silence?addr=10.0.0.7:9999&for=1h&until=" + until,
+	} {
+		if rec := do(http.MethodPost, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST %s status = %d, want 400", target, rec.Code)
+		}
+	}
+	if got := len(node.monitor.Silences()); got != 2 {
+		t.Errorf("%d windows active after rejected requests, want 2", got)
+	}
+
+	if rec := do(http.MethodDelete, "/silence?addr=10.0.0.5:9999"); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "10.0.0.5") {
+		t.Errorf("DELETE = %d %s, want the window ended", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodDelete, "/silence"); rec.Code != http.StatusBadRequest {
+		t.Errorf("DELETE without addr status = %d, want 400", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/silence"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "10.0.0.6") {
+		t.Errorf("GET = %d %s, want the remaining window", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPut, "/silence"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT status = %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package pulsecheck
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// StatsPath is the HTTP endpoint serving the node's counters, served on
+// StatusAddr
+const StatsPath = "/stats"
+
+// statsJSON is the body served on StatsPath
+type statsJSON struct {
+	Network     NetworkStats  `json:"network"`
+	Reaper      ReaperStats   `json:"reaper"`
+	PushBreaker *BreakerStats `json:"push_breaker,omitempty"` // nil unless PushURL is set
+	FIFO        *FIFOStats    `json:"fifo,omitempty"`         // nil unless FIFOPath is set
+}
+
+// statsHandler serves StatsPath: the network counters, including drops by
+// cause, the reaper counters, and the push circuit breaker, so they can be
+// scraped and alerted on without sending SIGUSR1
+func (n *Node) statsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body := statsJSON{
+			Network: n.Stats(),
+			Reaper:  n.ReaperStats(),
+		}
+		if n.pusher != nil {
+			stats := n.PushBreakerStats()
+			body.PushBreaker = &stats
+		}
+		if n.fifo != nil {
+			stats := n.FIFOStats()
+			body.FIFO = &stats
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	})
+}